@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// stdinBatchSize is how many queries are embedded per SearchBatch call.
+const stdinBatchSize = 32
+
+// batchLine is one NDJSON output record of `sift search --stdin`.
+type batchLine struct {
+	Query   string               `json:"query"`
+	Results []index.SearchResult `json:"results"`
+	Error   string               `json:"error,omitempty"`
+}
+
+// searchBatchFunc runs a batch of queries; *index.Index.SearchBatch with k bound.
+type searchBatchFunc func(queries []string) []index.BatchResult
+
+// writeBatchNDJSON reads one query per line from r and writes one NDJSON
+// record per line to w in input order, flushing after every batch so
+// consumers see results while later queries are still running.
+func writeBatchNDJSON(r io.Reader, w io.Writer, search searchBatchFunc) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	var pending []string
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		for i, br := range search(pending) {
+			line := batchLine{Query: pending[i], Results: br.Results}
+			if line.Results == nil {
+				line.Results = []index.SearchResult{}
+			}
+			if br.Err != nil {
+				line.Error = br.Err.Error()
+			}
+			if err := enc.Encode(line); err != nil {
+				return err
+			}
+		}
+		pending = pending[:0]
+		return bw.Flush()
+	}
+
+	for sc.Scan() {
+		pending = append(pending, strings.TrimSpace(sc.Text()))
+		if len(pending) == stdinBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read stdin: %w", err)
+	}
+	return flush()
+}
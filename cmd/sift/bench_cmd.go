@@ -1,21 +1,37 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/tejas242/sift/internal/config"
 	"github.com/tejas242/sift/internal/embed"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/logging"
+)
+
+var (
+	benchIndex  bool
+	benchFiles  int
+	benchSizeKB int
+	benchJSON   bool
 )
 
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
+	benchCmd := &cobra.Command{
 		Use:   "bench",
 		Short: "Benchmark tokenizer and ONNX inference speed on this machine",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if benchIndex {
+				return runBenchIndex(cmd.OutOrStdout())
+			}
 			fmt.Fprint(os.Stderr, "Loading model… ")
 			resolved := config.ResolveOrtLib(ortLib)
 			e, err := embed.New(modelDir, resolved, numThreads)
@@ -48,7 +64,64 @@ func init() {
 			}
 			fmt.Printf("\nIf inference >500ms, try: sift --threads 1 index <dir>\n")
 			fmt.Printf("Set SIFT_DEBUG=1 for per-batch timing during indexing.\n")
+			fmt.Printf("Run `sift bench --index` to time a full indexing run.\n")
 			return nil
 		},
+	}
+	f := benchCmd.Flags()
+	f.BoolVar(&benchIndex, "index", false, "benchmark end-to-end indexing of a generated corpus")
+	f.IntVar(&benchFiles, "files", 200, "corpus size in files (with --index)")
+	f.IntVar(&benchSizeKB, "size", 8, "approximate size of each corpus file in KB (with --index)")
+	f.BoolVar(&benchJSON, "json", false, "output the --index report as JSON")
+	rootCmd.AddCommand(benchCmd)
+}
+
+// runBenchIndex generates a corpus in a temp dir and indexes it with the
+// real model into a throwaway index.
+func runBenchIndex(w io.Writer) error {
+	tmp, err := os.MkdirTemp("", "sift-bench-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	corpus := filepath.Join(tmp, "corpus")
+	if _, err := generateCorpus(corpus, benchFiles, benchSizeKB, 1); err != nil {
+		return fmt.Errorf("generate corpus: %w", err)
+	}
+
+	idx, err := openIndexFunc(index.Options{
+		Dir:       filepath.Join(tmp, ".sift"),
+		ModelDir:  modelDir,
+		OrtLib:    config.ResolveOrtLib(ortLib),
+		Threads:   numThreads,
+		MaxFileKB: maxFileKB,
 	})
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	// Per-file progress would distort the timings.
+	idx.SetLogger(logging.New(os.Stderr, logging.LevelWarn))
+	fmt.Fprint(os.Stderr, "Loading model… ")
+	if err := idx.LoadEmbedder(); err != nil {
+		fmt.Fprintln(os.Stderr)
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "ready. Indexing %d files of ~%d KB…\n", benchFiles, benchSizeKB)
+
+	r, err := runIndexBench(context.Background(), idx, corpus)
+	if err != nil {
+		return err
+	}
+	if benchJSON {
+		j, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal json: %w", err)
+		}
+		fmt.Fprintln(w, string(j))
+		return nil
+	}
+	writeBenchIndexReport(w, r)
+	return nil
 }
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// benchPhases is the order phases are reported in.
+var benchPhases = []string{
+	index.PhaseHash, index.PhaseChunk, index.PhaseTokenize, index.PhaseInference,
+	index.PhaseEmbed, index.PhaseInsert, index.PhaseFlush,
+}
+
+// benchIndexReport is the `sift bench --index --json` schema.
+type benchIndexReport struct {
+	Files        int                `json:"files"`
+	Chunks       int                `json:"chunks"`
+	Bytes        int64              `json:"bytes"`
+	ElapsedMS    float64            `json:"elapsed_ms"`
+	FilesPerSec  float64            `json:"files_per_sec"`
+	ChunksPerSec float64            `json:"chunks_per_sec"`
+	PhasesMS     map[string]float64 `json:"phases_ms"`
+	PeakRSSBytes *int64             `json:"peak_rss_bytes"`
+}
+
+// Words used to build the synthetic corpus.
+var (
+	proseWords = strings.Fields(`the index stores each chunk with its path and line so results
+		point straight at the source while the graph keeps neighbours close enough
+		that a search visits only a small fraction of the vectors before it settles`)
+	codeIdents = strings.Fields(`index chunk vector graph node layer query result cache
+		path file score batch embed token config`)
+)
+
+// generateCorpus writes files synthetic files of about sizeKB each into dir,
+// alternating Go source and Markdown prose. It is deterministic for a seed
+// and returns the total bytes written.
+func generateCorpus(dir string, files, sizeKB int, seed int64) (int64, error) {
+	rng := rand.New(rand.NewSource(seed))
+	var total int64
+	for i := range files {
+		var sb strings.Builder
+		var name string
+		if i%2 == 0 {
+			name = filepath.Join(fmt.Sprintf("pkg%02d", i%10), fmt.Sprintf("file%04d.go", i))
+			fmt.Fprintf(&sb, "package pkg%02d\n\n", i%10)
+			for n := 0; sb.Len() < sizeKB*1024; n++ {
+				a, b := codeIdents[rng.Intn(len(codeIdents))], codeIdents[rng.Intn(len(codeIdents))]
+				fmt.Fprintf(&sb, "// %s%d returns the %s for %s.\nfunc %s%d(%s int) int {\n\treturn %s * %d\n}\n\n",
+					a, n, a, b, a, n, b, b, rng.Intn(100))
+			}
+		} else {
+			name = filepath.Join("docs", fmt.Sprintf("note%04d.md", i))
+			fmt.Fprintf(&sb, "# Note %d\n\n", i)
+			for sb.Len() < sizeKB*1024 {
+				for w := 0; w < 40+rng.Intn(40); w++ {
+					sb.WriteString(proseWords[rng.Intn(len(proseWords))])
+					sb.WriteByte(' ')
+				}
+				sb.WriteString("\n\n")
+			}
+		}
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			return total, err
+		}
+		if err := os.WriteFile(p, []byte(sb.String()), 0o644); err != nil {
+			return total, err
+		}
+		total += int64(sb.Len())
+	}
+	return total, nil
+}
+
+// runIndexBench indexes corpusDir into idx through the normal IndexDir and
+// Flush path and reports throughput and per-phase time.
+func runIndexBench(ctx context.Context, idx *index.Index, corpusDir string) (benchIndexReport, error) {
+	phases := make(map[string]time.Duration)
+	idx.SetPhaseFunc(func(phase string, d time.Duration) { phases[phase] += d })
+	defer idx.SetPhaseFunc(nil)
+
+	start := time.Now()
+	if err := idx.IndexDir(ctx, corpusDir); err != nil {
+		return benchIndexReport{}, err
+	}
+	if err := idx.Flush(); err != nil {
+		return benchIndexReport{}, err
+	}
+	elapsed := time.Since(start)
+
+	s := idx.Stats()
+	r := benchIndexReport{
+		Files:     s.NumFiles,
+		Chunks:    s.NumChunks,
+		Bytes:     dirSize(corpusDir),
+		ElapsedMS: ms(elapsed),
+		PhasesMS:  make(map[string]float64, len(phases)),
+	}
+	if secs := elapsed.Seconds(); secs > 0 {
+		r.FilesPerSec = float64(r.Files) / secs
+		r.ChunksPerSec = float64(r.Chunks) / secs
+	}
+	for p, d := range phases {
+		r.PhasesMS[p] = ms(d)
+	}
+	if rss, ok := peakRSS(); ok {
+		r.PeakRSSBytes = &rss
+	}
+	return r, nil
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func writeBenchIndexReport(w io.Writer, r benchIndexReport) {
+	fmt.Fprintf(w, "\n%d files (%s) → %d chunks in %.1fs\n", r.Files, sizeText(r.Bytes), r.Chunks, r.ElapsedMS/1000)
+	fmt.Fprintf(w, "%.1f files/s, %.1f chunks/s\n\n", r.FilesPerSec, r.ChunksPerSec)
+	fmt.Fprintf(w, "%-10s  %10s  %6s\n", "phase", "time", "share")
+	fmt.Fprintln(w, strings.Repeat("─", 30))
+	for _, p := range benchPhases {
+		d, ok := r.PhasesMS[p]
+		if !ok {
+			continue
+		}
+		name := p
+		if p == index.PhaseTokenize || p == index.PhaseInference {
+			name = "  " + p // part of embed
+		}
+		share := 0.0
+		if r.ElapsedMS > 0 {
+			share = 100 * d / r.ElapsedMS
+		}
+		fmt.Fprintf(w, "%-10s  %8.0fms  %5.1f%%\n", name, d, share)
+	}
+	if r.PeakRSSBytes != nil {
+		fmt.Fprintf(w, "\npeak RSS: %d MB\n", *r.PeakRSSBytes>>20)
+	}
+}
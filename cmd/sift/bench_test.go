@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestGenerateCorpus(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	n, err := generateCorpus(a, 6, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generateCorpus(b, 6, 2, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	var goFiles, mdFiles int
+	var total int64
+	err = filepath.WalkDir(a, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		got, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(a, p)
+		want, err := os.ReadFile(filepath.Join(b, rel))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s differs between runs with the same seed", rel)
+		}
+		if len(got) < 2*1024 {
+			t.Errorf("%s is %d bytes, want at least 2 KB", rel, len(got))
+		}
+		switch filepath.Ext(p) {
+		case ".go":
+			goFiles++
+		case ".md":
+			mdFiles++
+		}
+		total += int64(len(got))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if goFiles != 3 || mdFiles != 3 {
+		t.Errorf("got %d .go and %d .md files, want 3 of each", goFiles, mdFiles)
+	}
+	if total != n {
+		t.Errorf("generateCorpus reported %d bytes, wrote %d", n, total)
+	}
+}
+
+func TestRunIndexBench(t *testing.T) {
+	dir := t.TempDir()
+	corpus := filepath.Join(dir, "corpus")
+	if _, err := generateCorpus(corpus, 4, 3, 1); err != nil {
+		t.Fatal(err)
+	}
+	idx := index.NewTestIndex(filepath.Join(dir, ".sift"), &mockEmbedder{})
+
+	r, err := runIndexBench(context.Background(), idx, corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Files != 4 {
+		t.Errorf("Files = %d, want 4", r.Files)
+	}
+	if r.Chunks < r.Files {
+		t.Errorf("Chunks = %d, want at least one per file", r.Chunks)
+	}
+	if r.Bytes < 4*3*1024 {
+		t.Errorf("Bytes = %d, want at least 12 KB", r.Bytes)
+	}
+	// The mock embedder has no tokenizer or session, so only the index's
+	// own phases are reported.
+	for _, p := range []string{index.PhaseChunk, index.PhaseEmbed, index.PhaseInsert, index.PhaseFlush} {
+		if _, ok := r.PhasesMS[p]; !ok {
+			t.Errorf("phase %q missing from %v", p, r.PhasesMS)
+		}
+	}
+	if _, ok := r.PhasesMS[index.PhaseInference]; ok {
+		t.Errorf("unexpected %q phase from the mock embedder", index.PhaseInference)
+	}
+
+	var out bytes.Buffer
+	writeBenchIndexReport(&out, r)
+	for _, want := range []string{"4 files", "files/s", "chunk", "flush"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -1,40 +1,277 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/tejas242/sift/internal/config"
+	"github.com/tejas242/sift/internal/index"
 )
 
-var forceFlag bool
+var (
+	forceFlag      bool
+	clearCache     bool
+	clearIndex     bool
+	clearDryRun    bool
+	clearSnapshots bool
+)
+
+// snapshotsDir is the subdirectory of the index dir holding snapshots,
+// which a plain clear keeps unless --snapshots is given.
+const snapshotsDir = "snapshots"
 
 func init() {
 	clearCmd := &cobra.Command{
 		Use:   "clear",
 		Short: "Remove the sift index (.sift/ directory)",
+		Long: `Remove the sift index (.sift/ directory).
+
+--cache only resets the skip cache, so the next ` + "`sift index`" + ` re-embeds every
+file while the current index keeps answering searches. --index removes the
+indexed data but keeps the manifest (model, roots, patterns).
+
+Snapshots under .sift/snapshots are kept unless --snapshots is given.
+--dry-run lists every file with its size, what would be removed, and what
+the index holds, and deletes nothing.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if _, err := os.Stat(config.DefaultSiftDir); os.IsNotExist(err) {
-				fmt.Println("No index found — nothing to clear.")
+			out := cmd.OutOrStdout()
+			if _, err := os.Stat(indexDir); os.IsNotExist(err) {
+				fmt.Fprintln(out, "No index found — nothing to clear.")
+				return nil
+			}
+			if clearCache {
+				return clearSkipCache(out)
+			}
+			files, snaps := planClear(indexDir, clearIndex, clearSnapshots)
+			if clearDryRun {
+				writeClearReport(out, indexDir, files, snaps, time.Now())
 				return nil
 			}
+			target := indexDir
+			if clearIndex {
+				target = "the indexed data in " + indexDir
+			}
 			if !forceFlag {
-				fmt.Printf("Remove %s? This cannot be undone. [y/N] ", config.DefaultSiftDir)
+				fmt.Fprintf(out, "Remove %s (%s)? This cannot be undone. [y/N] ",
+					target, clearSummary(indexDir, removedSize(files)+removedSize(snaps), time.Now()))
 				var ans string
-				fmt.Scanln(&ans)
+				fmt.Fscanln(cmd.InOrStdin(), &ans)
 				if ans != "y" && ans != "Y" {
-					fmt.Println("Aborted.")
+					fmt.Fprintln(out, "Aborted.")
 					return nil
 				}
 			}
-			if err := os.RemoveAll(config.DefaultSiftDir); err != nil {
+			if clearIndex {
+				return clearDataFiles(out)
+			}
+			if len(snaps) > 0 && !clearSnapshots {
+				return clearKeepingSnapshots(out, snaps)
+			}
+			size := dirSize(indexDir)
+			if err := os.RemoveAll(indexDir); err != nil {
 				return fmt.Errorf("clear: %w", err)
 			}
-			fmt.Println("Index cleared.")
+			fmt.Fprintf(out, "Index cleared (%s).\n", sizeText(size))
 			return nil
 		},
 	}
-	clearCmd.Flags().BoolVar(&forceFlag, "force", false, "skip confirmation prompt")
+	f := clearCmd.Flags()
+	f.BoolVar(&forceFlag, "force", false, "skip confirmation prompt")
+	f.BoolVar(&clearCache, "cache", false, "only reset the skip cache so the next index run re-embeds everything")
+	f.BoolVar(&clearIndex, "index", false, "remove the graph and metadata but keep the manifest")
+	f.BoolVar(&clearDryRun, "dry-run", false, "list what would be removed, with sizes, and delete nothing")
+	f.BoolVar(&clearSnapshots, "snapshots", false, "also delete the snapshots under .sift/snapshots")
+	clearCmd.MarkFlagsMutuallyExclusive("cache", "index")
+	clearCmd.MarkFlagsMutuallyExclusive("cache", "dry-run")
+	clearCmd.MarkFlagsMutuallyExclusive("index", "snapshots")
+	clearCmd.MarkFlagsMutuallyExclusive("cache", "snapshots")
 	rootCmd.AddCommand(clearCmd)
 }
+
+// clearSkipCache implements --cache.
+func clearSkipCache(out io.Writer) error {
+	idx, err := index.OpenWithoutModel(indexDir)
+	if errors.Is(err, index.ErrNoIndex) {
+		fmt.Fprintln(out, "No index found — nothing to clear.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	n := idx.ResetSkipCache()
+	if err := idx.Close(); err != nil {
+		return fmt.Errorf("clear: %w", err)
+	}
+	fmt.Fprintf(out, "Reset the skip cache for %d files; the next index run re-embeds them.\n", n)
+	return nil
+}
+
+// clearDataFiles implements --index.
+func clearDataFiles(out io.Writer) error {
+	var total int64
+	for _, name := range index.DataFiles {
+		p := filepath.Join(indexDir, name)
+		fi, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			return fmt.Errorf("clear: %w", err)
+		}
+		fmt.Fprintf(out, "removed %-12s %10s\n", name, sizeText(fi.Size()))
+		total += fi.Size()
+	}
+	fmt.Fprintf(out, "Indexed data cleared (%s); the manifest was kept.\n", sizeText(total))
+	return nil
+}
+
+// clearEntry is one file (or, under snapshots, one snapshot) in the index
+// dir, and whether clear would remove it.
+type clearEntry struct {
+	Name   string
+	Size   int64
+	Remove bool
+}
+
+// planClear lists the files in dir, by slash-separated relative path, and
+// the top-level entries of its snapshots directory separately. dataOnly
+// marks only index.DataFiles for removal (--index); snapshots are marked
+// only when withSnapshots is set.
+func planClear(dir string, dataOnly, withSnapshots bool) (files, snaps []clearEntry) {
+	filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel == snapshotsDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		files = append(files, clearEntry{Name: rel, Size: fi.Size(), Remove: !dataOnly || slices.Contains(index.DataFiles, rel)})
+		return nil
+	})
+	entries, _ := os.ReadDir(filepath.Join(dir, snapshotsDir))
+	for _, e := range entries {
+		snaps = append(snaps, clearEntry{
+			Name:   e.Name(),
+			Size:   dirSize(filepath.Join(dir, snapshotsDir, e.Name())),
+			Remove: withSnapshots && !dataOnly,
+		})
+	}
+	return files, snaps
+}
+
+func removedSize(entries []clearEntry) int64 {
+	var n int64
+	for _, e := range entries {
+		if e.Remove {
+			n += e.Size
+		}
+	}
+	return n
+}
+
+// writeClearReport implements --dry-run.
+func writeClearReport(w io.Writer, dir string, files, snaps []clearEntry, now time.Time) {
+	section := func(title string, entries []clearEntry) {
+		var total int64
+		for _, e := range entries {
+			total += e.Size
+		}
+		fmt.Fprintf(w, "%s (%s)\n", title, sizeText(total))
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, e := range entries {
+			action := "remove"
+			if !e.Remove {
+				action = "keep"
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t\n", e.Name, sizeText(e.Size), action)
+		}
+		tw.Flush()
+	}
+	section(dir, files)
+	if len(snaps) > 0 {
+		fmt.Fprintln(w)
+		title := filepath.Join(dir, snapshotsDir)
+		if !snaps[0].Remove {
+			title += ", kept without --snapshots"
+		}
+		section(title, snaps)
+	}
+	fmt.Fprintf(w, "\nIndex: %s.\n", clearSummary(dir, -1, now))
+	fmt.Fprintf(w, "Would remove %s. Dry run: nothing was deleted.\n", sizeText(removedSize(files)+removedSize(snaps)))
+}
+
+// clearSummary describes the index in dir for the prompt, e.g. "84.2 MB,
+// 120k chunks, 300 files, updated 2h ago". size < 0 leaves the size out.
+func clearSummary(dir string, size int64, now time.Time) string {
+	var parts []string
+	if size >= 0 {
+		parts = append(parts, sizeText(size))
+	}
+	s, err := readStatsSummary(dir)
+	if err != nil {
+		logger.Debugf("clear: %v", err)
+		return strings.Join(append(parts, "contents unknown"), ", ")
+	}
+	parts = append(parts, countText(s.Chunks)+" chunks", countText(s.Files)+" files")
+	if !s.Updated.IsZero() {
+		parts = append(parts, "updated "+agoText(now, s.Updated))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// clearKeepingSnapshots removes everything in the index dir except its
+// snapshots directory.
+func clearKeepingSnapshots(out io.Writer, snaps []clearEntry) error {
+	entries, err := os.ReadDir(indexDir)
+	if err != nil {
+		return fmt.Errorf("clear: %w", err)
+	}
+	var size int64
+	for _, e := range entries {
+		if e.Name() == snapshotsDir {
+			continue
+		}
+		p := filepath.Join(indexDir, e.Name())
+		size += dirSize(p)
+		if err := os.RemoveAll(p); err != nil {
+			return fmt.Errorf("clear: %w", err)
+		}
+	}
+	var kept int64
+	for _, s := range snaps {
+		kept += s.Size
+	}
+	fmt.Fprintf(out, "Index cleared (%s); kept %d snapshots (%s), pass --snapshots to delete them.\n",
+		sizeText(size), len(snaps), sizeText(kept))
+	return nil
+}
+
+// dirSize returns the total size of the files below dir.
+func dirSize(dir string) int64 {
+	var n int64
+	filepath.WalkDir(dir, func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if fi, err := d.Info(); err == nil {
+				n += fi.Size()
+			}
+		}
+		return nil
+	})
+	return n
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// populatedIndex indexes one file into ./.sift of a fresh working directory
+// and returns the .sift path and the file.
+func populatedIndex(t *testing.T) (string, string) {
+	t.Helper()
+	project := t.TempDir()
+	t.Chdir(project)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	siftDir := filepath.Join(project, ".sift")
+	doc := filepath.Join(project, "notes.md")
+	if err := os.WriteFile(doc, []byte("wireguard config lives here"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	if _, err := idx.AddFile(doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	return siftDir, doc
+}
+
+func runClear(t *testing.T, args ...string) string {
+	t.Helper()
+	clear := findCmd(t, "clear")
+	// Flags keep their values between Execute calls; start clean.
+	forceFlag, clearCache, clearIndex, clearDryRun, clearSnapshots = false, false, false, false, false
+	for _, name := range []string{"force", "cache", "index", "dry-run", "snapshots"} {
+		clear.Flags().Lookup(name).Changed = false
+	}
+	var out bytes.Buffer
+	clear.SetOut(&out)
+	clear.SetIn(strings.NewReader(""))
+	t.Cleanup(func() { clear.SetOut(nil); clear.SetIn(nil) })
+	reachedRun = false
+	rootCmd.SetArgs(append([]string{"clear"}, args...))
+	if err := Execute(); err != nil {
+		t.Fatalf("clear %v: %v", args, err)
+	}
+	return out.String()
+}
+
+func exists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
+
+func TestClear(t *testing.T) {
+	t.Run("everything", func(t *testing.T) {
+		siftDir, _ := populatedIndex(t)
+		out := runClear(t)
+		if !strings.Contains(out, "Aborted") || !exists(siftDir) {
+			t.Fatalf("unconfirmed clear removed the index:\n%s", out)
+		}
+		if !strings.Contains(out, "KB, 1 chunks, 1 files, updated just now)?") {
+			t.Errorf("prompt does not summarise the index:\n%s", out)
+		}
+		out = runClear(t, "--force")
+		if exists(siftDir) || !strings.Contains(out, "Index cleared") {
+			t.Errorf("clear --force left %s:\n%s", siftDir, out)
+		}
+	})
+
+	t.Run("index", func(t *testing.T) {
+		siftDir, _ := populatedIndex(t)
+		out := runClear(t, "--index", "--force")
+		for _, name := range index.DataFiles {
+			if exists(filepath.Join(siftDir, name)) {
+				t.Errorf("%s survived clear --index", name)
+			}
+		}
+		if !exists(filepath.Join(siftDir, "manifest.json")) {
+			t.Error("clear --index removed the manifest")
+		}
+		if !strings.Contains(out, "removed meta.json") || !strings.Contains(out, "removed hnsw.bin") {
+			t.Errorf("clear --index output does not list what it removed:\n%s", out)
+		}
+	})
+
+	t.Run("dry-run", func(t *testing.T) {
+		siftDir, _ := populatedIndex(t)
+		addSnapshot(t, siftDir)
+		out := runClear(t, "--dry-run")
+		for _, want := range []string{"meta.json", "hnsw.bin", "manifest.json", "summary.json",
+			"snapshots, kept without --snapshots", "monday", "1 chunks, 1 files", "nothing was deleted"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("dry-run report lacks %q:\n%s", want, out)
+			}
+		}
+		if strings.Contains(out, "snapshots/") {
+			t.Errorf("snapshot files are listed with the index files:\n%s", out)
+		}
+		if !exists(filepath.Join(siftDir, "meta.json")) || !exists(filepath.Join(siftDir, "snapshots", "monday")) {
+			t.Error("dry run removed files")
+		}
+
+		out = runClear(t, "--index", "--dry-run")
+		if !regexp.MustCompile(`manifest\.json +\d+ B +keep`).MatchString(out) ||
+			!regexp.MustCompile(`meta\.json +\d+ B +remove`).MatchString(out) {
+			t.Errorf("--index --dry-run does not mark what it keeps:\n%s", out)
+		}
+	})
+
+	t.Run("snapshots", func(t *testing.T) {
+		siftDir, _ := populatedIndex(t)
+		addSnapshot(t, siftDir)
+		out := runClear(t, "--force")
+		if exists(filepath.Join(siftDir, "meta.json")) || !exists(filepath.Join(siftDir, "snapshots", "monday", "meta.json")) {
+			t.Errorf("clear without --snapshots should remove the index and keep snapshots:\n%s", out)
+		}
+		if !strings.Contains(out, "kept 1 snapshots") {
+			t.Errorf("clear does not mention the kept snapshots:\n%s", out)
+		}
+		runClear(t, "--force", "--snapshots")
+		if exists(siftDir) {
+			t.Error("clear --snapshots left the index dir")
+		}
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		siftDir, doc := populatedIndex(t)
+		out := runClear(t, "--cache")
+		if !strings.Contains(out, "for 1 files") {
+			t.Errorf("clear --cache output:\n%s", out)
+		}
+		idx, err := index.OpenReadOnly(siftDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := idx.Stats(); s.NumChunks != 1 {
+			t.Errorf("clear --cache dropped chunks: %d left", s.NumChunks)
+		}
+		if c := idx.FileChunks(doc); len(c) != 1 || !c[0].Mtime.IsZero() {
+			t.Errorf("clear --cache kept the recorded mtime: %+v", c)
+		}
+		if out := runClear(t, "--cache"); !strings.Contains(out, "for 1 files") {
+			t.Errorf("second clear --cache:\n%s", out)
+		}
+	})
+}
+
+// addSnapshot puts a fake snapshot under siftDir/snapshots.
+func addSnapshot(t *testing.T, siftDir string) {
+	t.Helper()
+	dir := filepath.Join(siftDir, "snapshots", "monday")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "meta.json"), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+
+	"github.com/mattn/go-isatty"
+)
+
+var noColor bool
+
+// colorEnabled reports whether output may contain ANSI colour. It is off
+// with --no-color, when NO_COLOR is set to any non-empty value
+// (https://no-color.org), or when stdout is not a terminal.
+func colorEnabled() bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return stdoutIsTTY()
+}
+
+// stdoutIsTTY reports whether stdout is a terminal; tests replace it.
+var stdoutIsTTY = func() bool {
+	fd := os.Stdout.Fd()
+	return isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+}
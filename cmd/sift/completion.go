@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+)
+
+// Dynamic shell completion runs on every <Tab>, so it reads only the
+// index's metadata (index.OpenMeta), never the graph or the model, and
+// quietly suggests nothing when there is no index.
+
+// completionIndex opens the configured index for completion, or returns nil.
+func completionIndex(cmd *cobra.Command) *index.Index {
+	// Flags are parsed, but PersistentPreRunE does not run for completion.
+	if err := resolveConfig(cmd); err != nil {
+		return nil
+	}
+	idx, err := index.OpenMeta(indexDir)
+	if err != nil {
+		return nil
+	}
+	return idx
+}
+
+// completeRoots suggests the directories already in the index (relative
+// to the working directory when below it) for index, rebuild, and watch,
+// falling back to directory completion.
+func completeRoots(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	idx := completionIndex(cmd)
+	if idx == nil {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	defer idx.Close()
+
+	cwd, _ := os.Getwd()
+	var out []string
+	for _, root := range idx.Roots() {
+		if rel, err := filepath.Rel(cwd, root); err == nil && filepath.IsLocal(rel) {
+			root = rel
+		}
+		if strings.HasPrefix(root, toComplete) && !slices.Contains(args, root) {
+			out = append(out, root)
+		}
+	}
+	if len(out) == 0 {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	return out, cobra.ShellCompDirectiveDefault
+}
+
+// completeExtGlobs suggests "*.<ext>" for every extension in the index,
+// for --exclude and --include-only.
+func completeExtGlobs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	idx := completionIndex(cmd)
+	if idx == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer idx.Close()
+
+	var out []string
+	for ext := range idx.Stats().Extensions {
+		if g := "*" + ext; ext != "" && strings.HasPrefix(g, toComplete) {
+			out = append(out, g)
+		}
+	}
+	slices.Sort(out)
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestCompletion(t *testing.T) {
+	project := t.TempDir()
+	t.Chdir(project)
+	for name, body := range map[string]string{
+		"docs/guide.md": "# Guide\n\nhow to configure the server",
+		"src/main.go":   "package main\n\nfunc main() {}",
+		"src/util.py":   "def helper():\n    return 1",
+	} {
+		p := filepath.Join(project, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	siftDir := filepath.Join(project, ".sift")
+	setGlobals(t, siftDir)
+
+	indexCmd := findCmd(t, "index")
+
+	// No index yet: fall back to the shell's own directory completion.
+	if got, dir := completeRoots(indexCmd, nil, ""); got != nil || dir != cobra.ShellCompDirectiveFilterDirs {
+		t.Errorf("completeRoots without an index = %q, %v", got, dir)
+	}
+	if got, _ := completeExtGlobs(indexCmd, nil, ""); got != nil {
+		t.Errorf("completeExtGlobs without an index = %q", got)
+	}
+
+	newCompletionFixture(t, siftDir, filepath.Join(project, "docs"), filepath.Join(project, "src"))
+
+	got, _ := completeRoots(indexCmd, nil, "")
+	if want := []string{"docs", "src"}; !slices.Equal(got, want) {
+		t.Errorf("completeRoots = %q, want %q", got, want)
+	}
+	got, _ = completeRoots(indexCmd, []string{"docs"}, "")
+	if want := []string{"src"}; !slices.Equal(got, want) {
+		t.Errorf("completeRoots after docs = %q, want %q", got, want)
+	}
+
+	got, dir := completeExtGlobs(indexCmd, nil, "")
+	if want := []string{"*.go", "*.md", "*.py"}; !slices.Equal(got, want) {
+		t.Errorf("completeExtGlobs = %q, want %q", got, want)
+	}
+	if dir != cobra.ShellCompDirectiveNoFileComp {
+		t.Errorf("completeExtGlobs directive = %v, want NoFileComp", dir)
+	}
+	got, _ = completeExtGlobs(indexCmd, nil, "*.p")
+	if want := []string{"*.py"}; !slices.Equal(got, want) {
+		t.Errorf("completeExtGlobs(*.p) = %q, want %q", got, want)
+	}
+
+	// Completion must not rewrite the index it reads.
+	idx, err := index.OpenReadOnly(siftDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := idx.Stats(); s.NumFiles != 3 {
+		t.Errorf("index has %d files after completion, want 3", s.NumFiles)
+	}
+}
+
+// newCompletionFixture indexes roots into a fresh index at siftDir.
+func newCompletionFixture(t *testing.T, siftDir string, roots ...string) {
+	t.Helper()
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	for _, root := range roots {
+		if err := idx.IndexDir(context.Background(), root); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
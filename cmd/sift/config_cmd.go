@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/config"
+)
+
+func init() {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show or change settings in the config file",
+		Long: `Read and edit sift's settings without hand-editing TOML.
+
+get and list print effective values and the layer each came from (flag,
+env, file, user file, default). set writes the project config file (the
+one --config or $SIFT_CONFIG names, else ./.sift.toml), or with --global
+the user config file, keeping comments and the other keys as they are.
+Unknown keys and invalid values are rejected.`,
+	}
+	configCmd.AddCommand(
+		&cobra.Command{
+			Use:   "list",
+			Short: "List every setting with its effective value and source",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				writeSettings(cmd.OutOrStdout(), cfg)
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:               "get <key>",
+			Short:             "Print a setting's effective value and source",
+			Args:              cobra.ExactArgs(1),
+			ValidArgsFunction: completeSettingKeys,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				if _, ok := config.Lookup(args[0]); !ok {
+					return &usageError{config.Validate(args[0], "")}
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s\t(%s)\n", displayValue(cfg.Value(args[0])), cfg.Sources[args[0]])
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:               "set <key> <value>",
+			Short:             "Write a setting to the project (or --global user) config file",
+			Args:              cobra.ExactArgs(2),
+			ValidArgsFunction: completeSettingKeys,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				key, value := args[0], args[1]
+				if err := config.Validate(key, value); err != nil {
+					return &usageError{err}
+				}
+				path, err := configTarget()
+				if err != nil {
+					return err
+				}
+				if err := config.SetFile(path, key, value); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Set %s = %s in %s\n", key, value, path)
+
+				next, err := config.Resolve(os.Getenv, setFlags(cmd))
+				if err != nil {
+					return fmt.Errorf("configuration: %w", err)
+				}
+				if src := next.Sources[key]; src == config.SourceEnv || src == config.SourceFlag ||
+					(globalIndex && src == config.SourceFile) {
+					logger.Warnf("%s is overridden by the %s (effective value %s)", key, src, displayValue(next.Value(key)))
+				}
+				return nil
+			},
+		},
+	)
+	rootCmd.AddCommand(configCmd)
+}
+
+// configTarget returns the file `config set` writes: the user config file
+// with --global, else the project file that was read, else .sift.toml in
+// the project root.
+func configTarget() (string, error) {
+	if globalIndex {
+		p := config.UserConfigFile(os.Getenv)
+		if p == "" {
+			return "", errors.New("no user config directory could be determined (set XDG_CONFIG_HOME)")
+		}
+		return p, nil
+	}
+	if cfg.File != "" {
+		return cfg.File, nil
+	}
+	return filepath.Join(cfg.Root, config.DefaultFile), nil
+}
+
+// completeSettingKeys completes the first argument to a setting name.
+func completeSettingKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return config.Keys(), cobra.ShellCompDirectiveNoFileComp
+}
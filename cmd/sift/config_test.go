@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestConfigCmd(t *testing.T) {
+	project := t.TempDir()
+	t.Chdir(project)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	setGlobals(t, filepath.Join(project, ".sift"))
+
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs(append([]string{"config"}, args...))
+		t.Cleanup(func() { rootCmd.SetOut(nil); rootCmd.SetArgs(nil) })
+		reachedRun = false
+		err := Execute()
+		return out.String(), err
+	}
+
+	if out, err := run("get", "max-file-kb"); err != nil || out != "512\t(default)\n" {
+		t.Errorf("get before set = %q, %v", out, err)
+	}
+	if _, err := run("set", "max-file-kb", "64"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := run("get", "max-file-kb"); err != nil || out != "64\t(file)\n" {
+		t.Errorf("get after set = %q, %v", out, err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(project, ".sift.toml")); string(b) != "max-file-kb = 64\n" {
+		t.Errorf(".sift.toml = %q", b)
+	}
+	out, err := run("list")
+	if err != nil || !strings.Contains(out, "max-file-kb") || !strings.Contains(out, "(file)") {
+		t.Errorf("list = %v:\n%s", err, out)
+	}
+
+	for _, args := range [][]string{
+		{"get", "max-file-mb"},
+		{"set", "max-file-mb", "1"},
+		{"set", "threads", "many"},
+	} {
+		if _, err := run(args...); exitCode(err) != exitUsage {
+			t.Errorf("config %v: err = %v, want a usage error", args, err)
+		}
+	}
+}
+
+func TestProfileSearch(t *testing.T) {
+	project := t.TempDir()
+	t.Chdir(project)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	setGlobals(t, filepath.Join(project, ".sift"))
+	conf := "[profile.docs]\nmax-file-kb = 1024\n\n[profile.code]\nindex-dir = \"idx-code\"\n"
+	if err := os.WriteFile(filepath.Join(project, ".sift.toml"), []byte(conf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// One index per profile, each holding a different file.
+	indexes := make(map[string]*index.Index)
+	for dir, doc := range map[string]string{".sift-docs": "guide.md", "idx-code": "main.go"} {
+		siftDir := filepath.Join(project, dir)
+		p := filepath.Join(project, doc)
+		if err := os.WriteFile(p, []byte("wireguard config lives here"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+		if err := idx.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		indexes[siftDir] = idx
+	}
+	openIndexFunc = func(o index.Options) (*index.Index, error) {
+		if idx, ok := indexes[o.Dir]; ok {
+			return idx, nil
+		}
+		return nil, index.ErrNoIndex
+	}
+	profileFlag := rootCmd.PersistentFlags().Lookup("profile")
+	t.Cleanup(func() { profileFlag.Value.Set(""); profileFlag.Changed = false })
+
+	for profile, want := range map[string]string{"docs": "guide.md", "code": "main.go"} {
+		var out bytes.Buffer
+		search := findCmd(t, "search")
+		search.SetOut(&out)
+		reachedRun = false
+		rootCmd.SetArgs([]string{"--profile", profile, "search", "wireguard"})
+		err := Execute()
+		search.SetOut(nil)
+		rootCmd.SetArgs(nil)
+		if err != nil || !strings.Contains(out.String(), want) {
+			t.Errorf("--profile %s: %v\n%s", profile, err, out.String())
+		}
+		if profile == "docs" && maxFileKB != 1024 {
+			t.Errorf("--profile docs: max-file-kb = %d, want 1024", maxFileKB)
+		}
+	}
+}
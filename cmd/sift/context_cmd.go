@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/bundle"
+	"github.com/tejas242/sift/internal/embed"
+	"github.com/tejas242/sift/internal/index"
+)
+
+var (
+	contextBudget int
+	contextTopK   int
+	contextJSON   bool
+
+	// tokenCounterFunc loads the tokenizer used to enforce --budget; tests
+	// replace it to avoid the native tokenizer.
+	tokenCounterFunc = func(modelDir string) (count func(string) int, closeFn func(), err error) {
+		tc, err := embed.NewTokenCounter(modelDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", index.ErrEmbedder, err)
+		}
+		return tc.Count, tc.Close, nil
+	}
+)
+
+func init() {
+	contextCmd := &cobra.Command{
+		Use:   "context <query>",
+		Short: "Print the best-matching chunks as a token-budgeted bundle for LLM prompts",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query := strings.Join(args, " ")
+			count, closeCount, err := tokenCounterFunc(modelDir)
+			if err != nil {
+				return err
+			}
+			defer closeCount()
+
+			idx, err := openExistingIndex(ortLib)
+			if err != nil {
+				return err
+			}
+			defer idx.Close()
+			results, err := idx.Search(cmd.Context(), query, contextTopK)
+			if err != nil {
+				return err
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("getwd: %w", err)
+			}
+			b := bundle.Build(query, results, bundle.Options{
+				Budget:     contextBudget,
+				Count:      count,
+				FileChunks: idx.FileChunks,
+				Label:      func(path string) string { return displayPath(cwd, path) },
+			})
+
+			if contextJSON {
+				j, err := json.MarshalIndent(b, "", "  ")
+				if err != nil {
+					return fmt.Errorf("marshal json: %w", err)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(j))
+			} else {
+				fmt.Fprint(cmd.OutOrStdout(), bundle.Markdown(b))
+			}
+			if len(b.Sections) == 0 {
+				return errNoResults
+			}
+			return nil
+		},
+	}
+	contextCmd.Flags().IntVar(&contextBudget, "budget", 4000, "maximum bundle size in tokens")
+	contextCmd.Flags().IntVar(&contextTopK, "top-k", 20, "number of search hits to consider")
+	contextCmd.Flags().BoolVar(&contextJSON, "json", false, "output the bundle as JSON (size counts the Markdown form)")
+	rootCmd.AddCommand(contextCmd)
+}
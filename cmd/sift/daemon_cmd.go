@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/server"
+)
+
+// daemonLog is the file in the index directory a started daemon logs to.
+const daemonLog = "daemon.log"
+
+// daemonStartTimeout bounds how long `daemon start` waits for the model
+// to load and the socket to answer.
+var daemonStartTimeout = 2 * time.Minute
+
+func init() {
+	daemonCmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run a background server so searches skip the model load",
+		Long: `Keep the index and model loaded in a background ` + "`sift serve`" + ` listening
+on the index's socket (<index-dir>/sift.sock). While it runs, search
+answers through it in milliseconds; without it, search loads the model
+itself. One daemon serves one index, and it reloads the index whenever
+index, watch, or reindex save it.`,
+	}
+	daemonCmd.AddCommand(&cobra.Command{
+		Use:   "start",
+		Short: "Start the daemon for this index in the background",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return startDaemon(cmd.OutOrStdout())
+		},
+	}, &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the daemon for this index",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return stopDaemon(cmd.OutOrStdout())
+		},
+	}, &cobra.Command{
+		Use:   "status",
+		Short: "Report whether a daemon serves this index (exit code 1 if not)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return daemonStatus(cmd.OutOrStdout())
+		},
+	})
+	rootCmd.AddCommand(daemonCmd)
+}
+
+// startDaemon runs `sift serve` for the current index as a detached
+// process, with the same global flags, and waits until it answers.
+func startDaemon(w io.Writer) error {
+	sock := server.DefaultSocketPath(indexDir)
+	if c, err := server.Dial(sock); err == nil {
+		c.Close()
+		fmt.Fprintf(w, "A daemon is already running on %s.\n", sock)
+		return nil
+	}
+	if !index.Exists(indexDir) {
+		return noIndexError()
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+	logPath := filepath.Join(indexDir, daemonLog)
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+	defer logFile.Close()
+
+	child := exec.Command(exe, append([]string{"serve"}, globalFlagArgs()...)...)
+	child.Stdout, child.Stderr = logFile, logFile
+	child.SysProcAttr = detachedProcAttr()
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+
+	deadline := time.After(daemonStartTimeout)
+	for {
+		if c, err := server.Dial(sock); err == nil {
+			c.Close()
+			fmt.Fprintf(w, "Daemon started (pid %d) on %s; log: %s\n", child.Process.Pid, sock, logPath)
+			return nil
+		}
+		select {
+		case err := <-exited:
+			return fmt.Errorf("daemon exited during startup (%v); see %s", err, logPath)
+		case <-deadline:
+			child.Process.Kill()
+			return fmt.Errorf("daemon did not answer within %s; see %s", daemonStartTimeout, logPath)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// globalFlagArgs returns the persistent flags set on this invocation, so
+// a started daemon resolves the same index and settings.
+func globalFlagArgs() []string {
+	var args []string
+	rootCmd.PersistentFlags().VisitAll(func(fl *pflag.Flag) {
+		if fl.Changed {
+			args = append(args, "--"+fl.Name+"="+fl.Value.String())
+		}
+	})
+	return args
+}
+
+// stopDaemon asks the daemon for the current index to shut down and
+// waits for its socket to disappear.
+func stopDaemon(w io.Writer) error {
+	sock := server.DefaultSocketPath(indexDir)
+	c, err := server.Dial(sock)
+	if err != nil {
+		fmt.Fprintf(w, "No daemon is running on %s.\n", sock)
+		return nil
+	}
+	defer c.Close()
+	if _, err := c.Do(server.Request{Op: "shutdown"}); err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if _, err := os.Stat(sock); errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintln(w, "Daemon stopped.")
+			return nil
+		}
+	}
+	return fmt.Errorf("daemon on %s did not stop within 10s", sock)
+}
+
+// daemonStatus prints whether a daemon serves the current index, and
+// returns errNoResults (exit code 1) when none does.
+func daemonStatus(w io.Writer) error {
+	sock := server.DefaultSocketPath(indexDir)
+	c, err := server.Dial(sock)
+	if err != nil {
+		fmt.Fprintf(w, "No daemon is running on %s.\n", sock)
+		return errNoResults
+	}
+	defer c.Close()
+	resp, err := c.Do(server.Request{Op: "stats"})
+	if err != nil {
+		return fmt.Errorf("daemon: %w", err)
+	}
+	fmt.Fprintf(w, "Daemon running on %s: %d chunks, %d files", sock, resp.Stats.Chunks, resp.Stats.Files)
+	if !resp.Stats.LastUpdated.IsZero() {
+		fmt.Fprintf(w, ", updated %s", whenText(time.Now(), resp.Stats.LastUpdated))
+	}
+	fmt.Fprintln(w, ".")
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/server"
+)
+
+func TestSearchThroughDaemon(t *testing.T) {
+	useTestIndex(t, true)
+	idx, err := openIndexFunc(index.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := openIndexFunc
+
+	// The daemon, in-process on the index's default socket.
+	ln, err := server.Listen(server.DefaultSocketPath(indexDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv := server.New(refreshingIndex{idx})
+	srv.SetShutdownFunc(cancel)
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln) }()
+
+	// Searching must not open the index while the daemon answers.
+	openIndexFunc = func(index.Options) (*index.Index, error) {
+		t.Error("search opened the index although a daemon is running")
+		return nil, errors.New("not expected")
+	}
+	results, err := runSearch(context.Background(), "wireguard", nil)
+	if err != nil || len(results) != 1 || results[0].Meta.Mtime.IsZero() {
+		t.Fatalf("search through the daemon = %+v, %v", results, err)
+	}
+
+	var out bytes.Buffer
+	if err := daemonStatus(&out); err != nil || !strings.Contains(out.String(), "1 chunks, 1 files") {
+		t.Errorf("status = %v:\n%s", err, out.String())
+	}
+
+	out.Reset()
+	if err := stopDaemon(&out); err != nil || !strings.Contains(out.String(), "stopped") {
+		t.Fatalf("stop = %v:\n%s", err, out.String())
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon still running after stop")
+	}
+
+	// Without a daemon, search falls back to loading the index itself.
+	openIndexFunc = local
+	if results, err := runSearch(context.Background(), "wireguard", nil); err != nil || len(results) != 1 {
+		t.Errorf("in-process fallback = %+v, %v", results, err)
+	}
+	out.Reset()
+	if err := daemonStatus(&out); !errors.Is(err, errNoResults) || !strings.Contains(out.String(), "No daemon") {
+		t.Errorf("status without a daemon = %v:\n%s", err, out.String())
+	}
+}
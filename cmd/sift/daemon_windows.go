@@ -0,0 +1,9 @@
+package main
+
+import "syscall"
+
+// detachedProcAttr starts the daemon in its own process group, so Ctrl+C
+// in the console that ran `sift daemon start` does not stop it.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/config"
+	"github.com/tejas242/sift/internal/doctor"
+)
+
+func init() {
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose model, onnxruntime, and index setup problems",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			printSettings()
+			env := doctor.DefaultEnv(modelDir, config.ResolveOrtLib(ortLib), indexDir, numThreads)
+			env.Prefixes = embedPrefixes
+			for _, f := range []string{cfg.UserFile, cfg.File} {
+				if f != "" {
+					env.ConfigFiles = append(env.ConfigFiles, f)
+				}
+			}
+			results := doctor.Run(env)
+			for _, r := range results {
+				fmt.Printf("  %s %-16s %s\n", statusGlyph(r.Status), r.Name, r.Detail)
+				if r.Hint != "" && (r.Status == doctor.Warn || r.Status == doctor.Fail) {
+					fmt.Printf("    %-16s → %s\n", "", r.Hint)
+				}
+			}
+			if doctor.Failed(results) {
+				return errors.New("doctor: one or more checks failed")
+			}
+			return nil
+		},
+	})
+}
+
+// printSettings lists each effective setting and the layer it came from.
+func printSettings() {
+	fmt.Printf("Settings (user config: %s, project config: %s, profile: %s)\n", orNone(cfg.UserFile), orNone(cfg.File), orNone(cfg.Profile))
+	writeSettings(os.Stdout, cfg)
+	fmt.Println("\nChecks")
+}
+
+// writeSettings writes one line per setting of c: key, value, source.
+func writeSettings(w io.Writer, c *config.Config) {
+	for _, s := range config.Settings {
+		fmt.Fprintf(w, "  %-14s %-28s (%s)\n", s.Key, displayValue(c.Value(s.Key)), c.Sources[s.Key])
+	}
+}
+
+// displayValue shows an empty setting as "".
+func displayValue(v string) string {
+	if v == "" {
+		return `""`
+	}
+	return v
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+func statusGlyph(s doctor.Status) string {
+	switch s {
+	case doctor.Pass:
+		return "✓"
+	case doctor.Warn:
+		return "!"
+	case doctor.Fail:
+		return "✗"
+	default:
+		return "-"
+	}
+}
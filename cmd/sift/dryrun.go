@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+var dryRun bool
+
+// runDryRun prints what indexing dirs would do. The index is opened without
+// loading the model, so this is fast and works even when ONNX is missing.
+func runDryRun(w io.Writer, dirs []string, fresh bool) error {
+	idx, err := openIndexLazy(ortLib, false, false, false)
+	if err != nil {
+		return err
+	}
+	// No Close: it would flush, and a dry run must not write anything.
+	if err := idx.SetPatterns(excludeGlobs, includeOnlyGlobs); err != nil {
+		return &usageError{err}
+	}
+	if dirs, err = indexRoots(idx, dirs); err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		p, err := idx.PlanDir(dir, fresh)
+		if err != nil {
+			return err
+		}
+		writePlan(w, dir, p)
+	}
+	return nil
+}
+
+// writePlan renders a dry-run report for one root.
+func writePlan(w io.Writer, root string, p *index.Plan) {
+	reasons := make(map[string]int)
+	for _, s := range p.Skipped {
+		reasons[s.Reason]++
+	}
+
+	fmt.Fprintf(w, "Dry run for %s (nothing was indexed)\n\n", root)
+	fmt.Fprintf(w, "  %-14s %6d files   ~%d chunks, %s\n", "to embed", len(p.ToEmbed), p.EstChunks, sizeText(p.EstBytes))
+	fmt.Fprintf(w, "  %-14s %6d files\n", "cached", len(p.Cached))
+	fmt.Fprintf(w, "  %-14s %6d\n", "skipped", len(p.Skipped))
+	for _, r := range []string{index.SkipIgnored, index.SkipTooLarge, index.SkipUnsupported, index.SkipUnreadable} {
+		if n := reasons[r]; n > 0 {
+			fmt.Fprintf(w, "    %-12s %6d\n", r, n)
+		}
+	}
+
+	if len(p.ToEmbed) > 0 {
+		fmt.Fprintln(w, "\nTo embed:")
+		for _, path := range sorted(p.ToEmbed) {
+			fmt.Fprintf(w, "  %s\n", path)
+		}
+	}
+	if len(p.Skipped) > 0 {
+		fmt.Fprintln(w, "\nSkipped:")
+		for _, s := range p.Skipped {
+			path := s.Path
+			if s.IsDir {
+				path += "/"
+			}
+			fmt.Fprintf(w, "  %s  (%s)\n", path, s.Reason)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+func sorted(s []string) []string {
+	out := append([]string(nil), s...)
+	sort.Strings(out)
+	return out
+}
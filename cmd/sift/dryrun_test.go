@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndexDryRun(t *testing.T) {
+	root := t.TempDir()
+	for name, body := range map[string]string{
+		"main.go":    "package main",
+		"notes.md":   "# notes",
+		"logo.png":   "\x89PNG\x00\x00",
+		"gen/out.go": "package gen",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	siftDir := filepath.Join(t.TempDir(), ".sift")
+	setGlobals(t, siftDir)
+	// No model files: a dry run that tried to load one would fail.
+	modelDir = t.TempDir()
+	oldDry, oldExclude := dryRun, excludeGlobs
+	t.Cleanup(func() { dryRun, excludeGlobs = oldDry, oldExclude })
+	dryRun, excludeGlobs = true, []string{"gen/**"}
+
+	cmd := findCmd(t, "index")
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	t.Cleanup(func() { cmd.SetOut(nil) })
+	if err := cmd.RunE(cmd, []string{root}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"nothing was indexed",
+		filepath.Join(root, "main.go"),
+		filepath.Join(root, "notes.md"),
+		filepath.Join(root, "gen") + "/  (ignored)",
+		filepath.Join(root, "logo.png") + "  (unsupported)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report missing %q:\n%s", want, got)
+		}
+	}
+	if _, err := os.Stat(siftDir); !os.IsNotExist(err) {
+		t.Errorf("dry run created %s", siftDir)
+	}
+}
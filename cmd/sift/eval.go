@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// evalQuery is one labeled query of a `sift eval` query file.
+type evalQuery struct {
+	Query string
+	// Relevant lists the paths a good search returns, as written in the
+	// file: relative paths are taken relative to the file's directory.
+	Relevant []string
+}
+
+// parseEvalQueries reads a query file: a YAML list of mappings, each with
+// a query and the paths relevant to it as a single path, a block list or
+// a flow list ("[a.md, b.md]"); the eval command's help shows an example.
+// Only this subset of YAML is understood: plain or quoted scalars, those
+// lists, and # comments.
+func parseEvalQueries(data []byte) ([]evalQuery, error) {
+	var (
+		qs     []evalQuery
+		cur    *evalQuery
+		inList bool // reading a block list under "relevant:"
+		itemAt int  // indent of the current item's keys
+	)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := stripYAMLComment(sc.Text())
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		text := strings.TrimSpace(line)
+		bad := func(format string, args ...any) error {
+			return fmt.Errorf("line %d: %s", n, fmt.Sprintf(format, args...))
+		}
+
+		if cur != nil && inList && indent > itemAt && strings.HasPrefix(text, "-") {
+			v, err := yamlScalar(strings.TrimSpace(text[1:]))
+			if err != nil {
+				return nil, bad("%v", err)
+			}
+			cur.Relevant = append(cur.Relevant, v)
+			continue
+		}
+		inList = false
+
+		switch {
+		case strings.HasPrefix(text, "- ") || text == "-":
+			qs = append(qs, evalQuery{})
+			cur = &qs[len(qs)-1]
+			itemAt = indent + 2
+			text = strings.TrimSpace(text[1:])
+			if text == "" {
+				continue
+			}
+		case cur == nil || indent != itemAt:
+			return nil, bad("want a list item (\"- query: …\"), got %q", text)
+		}
+
+		key, val, ok := strings.Cut(text, ":")
+		if !ok {
+			return nil, bad("want \"key: value\", got %q", text)
+		}
+		val = strings.TrimSpace(val)
+		switch strings.TrimSpace(key) {
+		case "query":
+			v, err := yamlScalar(val)
+			if err != nil {
+				return nil, bad("%v", err)
+			}
+			cur.Query = v
+		case "relevant":
+			switch {
+			case val == "":
+				inList = true
+			case strings.HasPrefix(val, "["):
+				if !strings.HasSuffix(val, "]") {
+					return nil, bad("unterminated list %q", val)
+				}
+				for _, item := range strings.Split(val[1:len(val)-1], ",") {
+					if item = strings.TrimSpace(item); item == "" {
+						continue
+					}
+					v, err := yamlScalar(item)
+					if err != nil {
+						return nil, bad("%v", err)
+					}
+					cur.Relevant = append(cur.Relevant, v)
+				}
+			default:
+				v, err := yamlScalar(val)
+				if err != nil {
+					return nil, bad("%v", err)
+				}
+				cur.Relevant = append(cur.Relevant, v)
+			}
+		default:
+			return nil, bad("unknown key %q (want query or relevant)", strings.TrimSpace(key))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for i, q := range qs {
+		switch {
+		case strings.TrimSpace(q.Query) == "":
+			return nil, fmt.Errorf("query %d: no query", i+1)
+		case len(q.Relevant) == 0:
+			return nil, fmt.Errorf("query %d (%q): no relevant paths", i+1, q.Query)
+		}
+	}
+	return qs, nil
+}
+
+// stripYAMLComment drops a # comment: one starting the line or following
+// whitespace, outside quotes.
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// yamlScalar unquotes a plain, single- or double-quoted scalar.
+func yamlScalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("unterminated string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, nil
+}
+
+// evalResult is how one query fared.
+type evalResult struct {
+	Query    string   `json:"query"`
+	Relevant []string `json:"relevant"`
+	// Rank is that of the first relevant result, 1-based; 0 is a miss.
+	Rank int `json:"rank"`
+	// Recall is the fraction of Relevant found in the top k.
+	Recall float64 `json:"recall"`
+	// Top lists the files returned, best first.
+	Top   []string `json:"top"`
+	Error string   `json:"error,omitempty"`
+}
+
+// evalReport is the outcome of a `sift eval` run; its JSON form is what
+// --compare reads back.
+type evalReport struct {
+	K       int          `json:"k"`
+	Queries int          `json:"queries"`
+	Hits    int          `json:"hits"`
+	Recall  float64      `json:"recall"`
+	MRR     float64      `json:"mrr"`
+	Results []evalResult `json:"results"`
+	// Compare is set when the run was compared with an earlier one.
+	Compare *evalComparison `json:"compare,omitempty"`
+}
+
+// runEval answers every query with search, stdinBatchSize at a time, and
+// scores the results against the relevant paths, which are resolved
+// against base. Paths in the report are shown relative to base where
+// they lie under it, so reports from different checkouts compare.
+func runEval(search searchBatchFunc, queries []evalQuery, base string, k int) evalReport {
+	r := evalReport{K: k, Queries: len(queries), Results: make([]evalResult, 0, len(queries))}
+	for start := 0; start < len(queries); start += stdinBatchSize {
+		batch := queries[start:min(start+stdinBatchSize, len(queries))]
+		texts := make([]string, len(batch))
+		for i, q := range batch {
+			texts[i] = q.Query
+		}
+		for i, br := range search(texts) {
+			r.Results = append(r.Results, scoreEval(batch[i], br, base))
+		}
+	}
+	for _, res := range r.Results {
+		r.Recall += res.Recall
+		if res.Rank > 0 {
+			r.Hits++
+			r.MRR += 1 / float64(res.Rank)
+		}
+	}
+	if n := float64(len(r.Results)); n > 0 {
+		r.Recall /= n
+		r.MRR /= n
+	}
+	return r
+}
+
+// scoreEval scores one query's results.
+func scoreEval(q evalQuery, br index.BatchResult, base string) evalResult {
+	res := evalResult{Query: q.Query, Relevant: q.Relevant, Top: []string{}}
+	if br.Err != nil {
+		res.Error = br.Err.Error()
+		return res
+	}
+	want := make(map[string]bool, len(q.Relevant))
+	for _, p := range q.Relevant {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(base, p)
+		}
+		want[filepath.Clean(p)] = true
+	}
+	found := 0
+	for i, hit := range br.Results {
+		p := hit.Meta.Path
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		res.Top = append(res.Top, relativeTo(base, p))
+		if want[p] {
+			if res.Rank == 0 {
+				res.Rank = i + 1
+			}
+			delete(want, p) // a file counts once, however many chunks hit
+			found++
+		}
+	}
+	res.Recall = float64(found) / float64(len(q.Relevant))
+	return res
+}
+
+// relativeTo returns p relative to base when it lies under base.
+func relativeTo(base, p string) string {
+	if rel, err := filepath.Rel(base, p); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(rel)
+	}
+	return p
+}
+
+// evalComparison is how a run differs from an earlier one.
+type evalComparison struct {
+	RecallDelta float64      `json:"recall_delta"`
+	MRRDelta    float64      `json:"mrr_delta"`
+	Regressed   []evalChange `json:"regressed"`
+	Improved    []evalChange `json:"improved"`
+	// Added lists queries the earlier run did not have.
+	Added []string `json:"added"`
+}
+
+// evalChange is a query whose first relevant rank moved; 0 is a miss.
+type evalChange struct {
+	Query   string `json:"query"`
+	OldRank int    `json:"old_rank"`
+	NewRank int    `json:"new_rank"`
+}
+
+// compareEval diffs cur against old, matching queries by their text. A
+// query regresses when its first relevant result drops or disappears.
+func compareEval(old, cur evalReport) *evalComparison {
+	c := &evalComparison{
+		RecallDelta: cur.Recall - old.Recall,
+		MRRDelta:    cur.MRR - old.MRR,
+		Regressed:   []evalChange{},
+		Improved:    []evalChange{},
+		Added:       []string{},
+	}
+	before := make(map[string]int, len(old.Results))
+	for _, r := range old.Results {
+		before[r.Query] = r.Rank
+	}
+	for _, r := range cur.Results {
+		was, ok := before[r.Query]
+		if !ok {
+			c.Added = append(c.Added, r.Query)
+			continue
+		}
+		ch := evalChange{Query: r.Query, OldRank: was, NewRank: r.Rank}
+		switch {
+		case rankWorse(r.Rank, was):
+			c.Regressed = append(c.Regressed, ch)
+		case rankWorse(was, r.Rank):
+			c.Improved = append(c.Improved, ch)
+		}
+	}
+	return c
+}
+
+// rankWorse reports whether rank a is worse than rank b, 0 being a miss.
+func rankWorse(a, b int) bool {
+	switch {
+	case a == b || b == 0:
+		return false
+	case a == 0:
+		return true
+	}
+	return a > b
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+)
+
+var (
+	evalK       int
+	evalJSON    bool
+	evalCompare string
+)
+
+func init() {
+	evalCmd := &cobra.Command{
+		Use:   "eval <queries.yaml>",
+		Short: "Measure retrieval quality against a labeled query set",
+		Long: `Run every query in a labeled query file against the index and report
+recall@k, mean reciprocal rank (MRR), and for each query the rank of its
+first relevant result. The file is a YAML list:
+
+  - query: where is the wireguard config
+    relevant: docs/vpn.md
+  - query: backup schedule
+    relevant: [ops/backup.md, ops/cron.md]
+
+Relative paths are taken relative to the query file's directory.
+
+Save a run with --json and pass it to --compare after changing the model,
+chunking or settings: the deltas are shown and queries whose first
+relevant result dropped are listed as regressions, which make sift eval
+exit with status 1.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if evalK < 1 {
+				return &usageError{fmt.Errorf("--top-k must be at least 1, got %d", evalK)}
+			}
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				return &usageError{err}
+			}
+			queries, err := parseEvalQueries(data)
+			if err != nil {
+				return &usageError{fmt.Errorf("%s: %w", args[0], err)}
+			}
+			var old *evalReport
+			if evalCompare != "" {
+				if old, err = readEvalReport(evalCompare); err != nil {
+					return &usageError{err}
+				}
+			}
+			base, err := filepath.Abs(filepath.Dir(args[0]))
+			if err != nil {
+				return err
+			}
+
+			idx, err := openExistingIndex(ortLib)
+			if err != nil {
+				return err
+			}
+			defer idx.Close()
+			report := runEval(func(qs []string) []index.BatchResult {
+				return idx.SearchBatch(qs, evalK)
+			}, queries, base, evalK)
+			if old != nil {
+				report.Compare = compareEval(*old, report)
+			}
+
+			w := cmd.OutOrStdout()
+			if evalJSON {
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				writeEvalReport(w, report, old, colorEnabled())
+			}
+			if report.Compare != nil && len(report.Compare.Regressed) > 0 {
+				return fmt.Errorf("%d queries regressed since %s", len(report.Compare.Regressed), evalCompare)
+			}
+			return nil
+		},
+	}
+	f := evalCmd.Flags()
+	f.IntVar(&evalK, "top-k", 10, "number of results each query is scored on")
+	f.BoolVar(&evalJSON, "json", false, "output the report as JSON (the format --compare reads)")
+	f.StringVar(&evalCompare, "compare", "", "compare with an earlier --json report and list regressions")
+	rootCmd.AddCommand(evalCmd)
+}
+
+// readEvalReport reads a report saved with `sift eval --json`.
+func readEvalReport(path string) (*evalReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r evalReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s: not a sift eval --json report: %w", path, err)
+	}
+	return &r, nil
+}
+
+// writeEvalReport prints a per-query table and the totals, followed by
+// the comparison with old when there is one.
+func writeEvalReport(w io.Writer, r evalReport, old *evalReport, color bool) {
+	re := lipgloss.NewRenderer(w)
+	re.SetColorProfile(termenv.Ascii)
+	if color {
+		re.SetColorProfile(termenv.ANSI)
+	}
+	sMiss := re.NewStyle().Foreground(lipgloss.Color("1"))
+	sGood := re.NewStyle().Foreground(lipgloss.Color("2"))
+
+	// Columns are padded before styling: tabwriter would count the
+	// escape codes as text.
+	fmt.Fprintln(w, "RANK   RECALL  QUERY")
+	for _, q := range r.Results {
+		rank := fmt.Sprintf("%-5d", q.Rank)
+		switch {
+		case q.Error != "":
+			rank = sMiss.Render("error")
+		case q.Rank == 0:
+			rank = sMiss.Render("miss ")
+		}
+		fmt.Fprintf(w, "%s  %6.2f  %s\n", rank, q.Recall, q.Query)
+	}
+	fmt.Fprintf(w, "\n%d of %d queries hit in the top %d\n", r.Hits, r.Queries, r.K)
+	fmt.Fprintf(w, "recall@%d  %.3f\n", r.K, r.Recall)
+	fmt.Fprintf(w, "MRR        %.3f\n", r.MRR)
+	for _, q := range r.Results {
+		if q.Error != "" {
+			fmt.Fprintf(w, "error: %q: %s\n", q.Query, q.Error)
+		}
+	}
+
+	c := r.Compare
+	if c == nil || old == nil {
+		return
+	}
+	delta := func(d float64) string {
+		s := fmt.Sprintf("%+.3f", d)
+		switch {
+		case d < 0:
+			return sMiss.Render(s)
+		case d > 0:
+			return sGood.Render(s)
+		}
+		return s
+	}
+	fmt.Fprintf(w, "\nCompared with the earlier run:\n")
+	fmt.Fprintf(w, "recall@%d  %.3f → %.3f  (%s)\n", r.K, old.Recall, r.Recall, delta(c.RecallDelta))
+	fmt.Fprintf(w, "MRR        %.3f → %.3f  (%s)\n", old.MRR, r.MRR, delta(c.MRRDelta))
+	if old.K != r.K {
+		fmt.Fprintf(w, "note: the earlier run scored the top %d, this one the top %d\n", old.K, r.K)
+	}
+	list := func(title string, changes []evalChange, style lipgloss.Style) {
+		if len(changes) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\n%s (%d):\n", title, len(changes))
+		width := 0
+		for _, ch := range changes {
+			width = max(width, utf8.RuneCountInString(rankName(ch.OldRank)+" → "+rankName(ch.NewRank)))
+		}
+		for _, ch := range changes {
+			move := fmt.Sprintf("%-*s", width, rankName(ch.OldRank)+" → "+rankName(ch.NewRank))
+			fmt.Fprintf(w, "  %s  %s\n", style.Render(move), ch.Query)
+		}
+	}
+	list("Regressed", c.Regressed, sMiss)
+	list("Improved", c.Improved, sGood)
+	if len(c.Added) > 0 {
+		fmt.Fprintf(w, "\n%d queries are new since the earlier run.\n", len(c.Added))
+	}
+}
+
+// rankName renders a first relevant rank, 0 being a miss.
+func rankName(rank int) string {
+	if rank == 0 {
+		return "miss"
+	}
+	return strconv.Itoa(rank)
+}
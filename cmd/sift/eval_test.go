@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestParseEvalQueries(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "eval", "queries.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	qs, err := parseEvalQueries(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []evalQuery{
+		{"wireguard tunnel config", []string{"corpus/vpn.md"}},
+		{"nightly backup schedule", []string{"corpus/backup.md", "corpus/cron.md"}},
+		{"homelab wireguard overview", []string{"corpus/vpn.md"}},
+		{"homelab nightly backup", []string{"corpus/retry.md"}},
+	}
+	if len(qs) != len(want) {
+		t.Fatalf("got %d queries, want %d: %+v", len(qs), len(want), qs)
+	}
+	for i := range want {
+		if qs[i].Query != want[i].Query || strings.Join(qs[i].Relevant, ",") != strings.Join(want[i].Relevant, ",") {
+			t.Errorf("query %d = %+v, want %+v", i, qs[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{
+		"- query: no paths\n",
+		"- relevant: a.md\n",
+		"- query: q\n  relevant: a.md\n  weight: 2\n",
+		"query: not a list\n",
+	} {
+		if _, err := parseEvalQueries([]byte(bad)); err == nil {
+			t.Errorf("parseEvalQueries(%q) succeeded", bad)
+		}
+	}
+}
+
+// TestEval runs the fixture query set against the fixture corpus and
+// checks the expected ranks, then compares with an earlier run.
+func TestEval(t *testing.T) {
+	base, err := filepath.Abs(filepath.Join("testdata", "eval"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx := index.NewTestIndex(filepath.Join(t.TempDir(), ".sift"), &mockEmbedder{})
+	entries, err := os.ReadDir(filepath.Join(base, "corpus"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if _, err := idx.AddFile(filepath.Join(base, "corpus", e.Name())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(base, "queries.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	qs, err := parseEvalQueries(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := runEval(func(queries []string) []index.BatchResult {
+		return idx.SearchBatch(queries, 3)
+	}, qs, base, 3)
+	for i, want := range []struct {
+		rank   int
+		recall float64
+	}{{1, 1}, {1, 1}, {2, 1}, {0, 0}} {
+		if got := r.Results[i]; got.Rank != want.rank || got.Recall != want.recall {
+			t.Errorf("%q: rank %d, recall %.2f; want %d, %.2f (top %v)",
+				got.Query, got.Rank, got.Recall, want.rank, want.recall, got.Top)
+		}
+	}
+	if r.Hits != 3 || r.Recall != 0.75 || r.MRR != 0.625 {
+		t.Errorf("hits %d, recall %.3f, MRR %.3f; want 3, 0.750, 0.625", r.Hits, r.Recall, r.MRR)
+	}
+	if top := r.Results[0].Top; len(top) == 0 || top[0] != "corpus/vpn.md" {
+		t.Errorf("top paths %v are not relative to the query file", top)
+	}
+
+	old := evalReport{K: 3, Recall: 0.5, MRR: 0.5, Results: []evalResult{
+		{Query: "wireguard tunnel config", Rank: 2},
+		{Query: "homelab wireguard overview", Rank: 1},
+		{Query: "homelab nightly backup", Rank: 3},
+	}}
+	r.Compare = compareEval(old, r)
+	c := r.Compare
+	if len(c.Regressed) != 2 || c.Regressed[0].NewRank != 2 || c.Regressed[1].NewRank != 0 {
+		t.Errorf("regressed = %+v, want overview 1 → 2 and nightly backup 3 → miss", c.Regressed)
+	}
+	if len(c.Improved) != 1 || c.Improved[0].Query != "wireguard tunnel config" {
+		t.Errorf("improved = %+v, want wireguard tunnel config", c.Improved)
+	}
+	if len(c.Added) != 1 || c.Added[0] != "nightly backup schedule" {
+		t.Errorf("added = %v", c.Added)
+	}
+
+	var out bytes.Buffer
+	writeEvalReport(&out, r, &old, false)
+	for _, want := range []string{
+		"2        1.00  homelab wireguard overview",
+		"miss     0.00  homelab nightly backup",
+		"recall@3  0.750",
+		"MRR        0.500 → 0.625  (+0.125)",
+		"Regressed (2):\n  1 → 2     homelab wireguard overview\n  3 → miss  homelab nightly backup",
+		"Improved (1):",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, out.String())
+		}
+	}
+}
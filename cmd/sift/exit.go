@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/embed"
+	"github.com/tejas242/sift/internal/index"
+)
+
+// Exit codes form a stable contract for scripts:
+//
+//	0  success (search: at least one result)
+//	1  no results, or any other failure
+//	2  usage error (bad flags, arguments, or settings)
+//	3  index missing or corrupt
+//	4  model or ONNX Runtime failure
+const (
+	exitOK        = 0
+	exitNoResults = 1
+	exitUsage     = 2
+	exitIndex     = 3
+	exitModel     = 4
+)
+
+// errNoResults is returned by commands that ran fine but found nothing.
+// main exits with exitNoResults without printing it.
+var errNoResults = errors.New("no results")
+
+// usageError marks errors caused by how sift was invoked.
+type usageError struct{ err error }
+
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+
+// exitCode maps an error returned from command execution to an exit code.
+func exitCode(err error) int {
+	var ue *usageError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &ue), errors.Is(err, index.ErrIndexInRoot), errors.Is(err, index.ErrEmptyQuery):
+		return exitUsage
+	case errors.Is(err, index.ErrNoIndex), errors.Is(err, index.ErrCorrupt):
+		return exitIndex
+	case errors.Is(err, index.ErrEmbedder), errors.Is(err, index.ErrEmbedQuery),
+		errors.Is(err, embed.ErrRerankerMissing):
+		return exitModel
+	default:
+		return exitNoResults
+	}
+}
+
+// Error codes name failure classes for tools that wrap the CLI; like the
+// exit codes they are a stable contract. See errorCode.
+const (
+	codeUsage        = "E_USAGE"
+	codeNoIndex      = "E_NO_INDEX"
+	codeIndexCorrupt = "E_INDEX_CORRUPT"
+	codeIndexInRoot  = "E_INDEX_IN_ROOT"
+	codeModelMissing = "E_MODEL_MISSING"
+	codeORTInit      = "E_ORT_INIT"
+	codeModelLoad    = "E_MODEL_LOAD"
+	codeQueryEmbed   = "E_QUERY_EMBED"
+	codeFailed       = "E_FAILED"
+)
+
+// errorCode classifies err and returns its code with a hint on what to
+// do about it ("" when there is nothing specific to suggest).
+func errorCode(err error) (code, hint string) {
+	var ue *usageError
+	switch {
+	case errors.As(err, &ue):
+		return codeUsage, "run 'sift --help' for usage"
+	case errors.Is(err, index.ErrEmptyQuery):
+		return codeUsage, "give a query with at least one word"
+	case errors.Is(err, index.ErrIndexInRoot):
+		return codeIndexInRoot, "move the index out of the root, or name it with a leading dot"
+	case errors.Is(err, index.ErrNoIndex):
+		return codeNoIndex, "run `sift index <dir>` first"
+	case errors.Is(err, index.ErrCorrupt):
+		return codeIndexCorrupt, "run `sift rebuild` to re-create the index"
+	case errors.Is(err, embed.ErrRerankerMissing):
+		return codeModelMissing, "run `make download-reranker`, or drop --rerank"
+	case errors.Is(err, embed.ErrModelMissing):
+		return codeModelMissing, "run `make download-model`, or point --model-dir at the model"
+	case errors.Is(err, embed.ErrRuntime):
+		return codeORTInit, "install ONNX Runtime, or point --ort-lib (SIFT_ORT_LIB) at the library"
+	case errors.Is(err, index.ErrEmbedder):
+		return codeModelLoad, "run `sift doctor` to check the model and ONNX Runtime"
+	case errors.Is(err, index.ErrEmbedQuery):
+		return codeQueryEmbed, ""
+	default:
+		return codeFailed, ""
+	}
+}
+
+// errorReport is the object written to stderr with --errors-json:
+// {"error": {"code": ..., "message": ..., "hint": ...}}.
+type errorReport struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// writeErrorJSON writes err to w as a single-line errorReport.
+func writeErrorJSON(w io.Writer, err error) error {
+	code, hint := errorCode(err)
+	return json.NewEncoder(w).Encode(errorReport{errorDetail{Code: code, Message: err.Error(), Hint: hint}})
+}
+
+// wantErrorsJSON reports whether failures of cmd (the command that ran,
+// nil if none was found) are reported as JSON: with --errors-json, or
+// when cmd was asked for JSON output with --json.
+func wantErrorsJSON(cmd *cobra.Command) bool {
+	if errorsJSON {
+		return true
+	}
+	if cmd == nil {
+		return false
+	}
+	asJSON, err := cmd.Flags().GetBool("json")
+	return err == nil && asJSON
+}
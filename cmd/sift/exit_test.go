@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/embed"
+	"github.com/tejas242/sift/internal/index"
+)
+
+// useTestIndex points the CLI globals at a temp index backed by the mock
+// embedder, optionally seeded with one document.
+func useTestIndex(t *testing.T, seed bool) {
+	t.Helper()
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	if err := os.MkdirAll(siftDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// An empty index still counts as existing.
+	if err := os.WriteFile(filepath.Join(siftDir, "meta.json"), []byte("[]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	if seed {
+		doc := filepath.Join(dir, "notes.md")
+		if err := os.WriteFile(doc, []byte("wireguard config lives here"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	setGlobals(t, siftDir)
+	openIndexFunc = func(index.Options) (*index.Index, error) {
+		return idx, nil
+	}
+}
+
+// setGlobals points the CLI at siftDir and restores the previous state after the test.
+func setGlobals(t *testing.T, siftDir string) {
+	t.Helper()
+	oldDir, oldOpen, oldQuiet, oldModel := indexDir, openIndexFunc, quiet, modelDir
+	t.Cleanup(func() {
+		indexDir, openIndexFunc, quiet, modelDir = oldDir, oldOpen, oldQuiet, oldModel
+	})
+	indexDir = siftDir
+	quiet = true
+}
+
+func findCmd(t *testing.T, name string) *cobra.Command {
+	t.Helper()
+	cmd, _, err := rootCmd.Find([]string{name})
+	if err != nil || cmd.Name() != name {
+		t.Fatalf("command %q not registered: %v", name, err)
+	}
+	return cmd
+}
+
+func TestExitCodes(t *testing.T) {
+	search := findCmd(t, "search")
+	stats := findCmd(t, "stats")
+
+	cases := []struct {
+		name  string
+		setup func(t *testing.T)
+		cmd   *cobra.Command
+		args  []string
+		want  int
+	}{
+		{"search hit", func(t *testing.T) { useTestIndex(t, true) }, search, []string{"wireguard"}, exitOK},
+		{"search no results", func(t *testing.T) { useTestIndex(t, false) }, search, []string{"wireguard"}, exitNoResults},
+		{"search missing index", func(t *testing.T) {
+			setGlobals(t, filepath.Join(t.TempDir(), "none"))
+		}, search, []string{"wireguard"}, exitIndex},
+		{"search corrupt index", func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "meta.json"), []byte("{not json"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			setGlobals(t, dir)
+		}, search, []string{"wireguard"}, exitIndex},
+		{"search model failure", func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "meta.json"), []byte("[]"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			setGlobals(t, dir)
+			modelDir = t.TempDir() // no model.onnx
+		}, search, []string{"wireguard"}, exitModel},
+		{"search query embedding failure", func(t *testing.T) {
+			useTestIndex(t, false)
+			idx := index.NewTestIndex(indexDir, &queryFailEmbedder{})
+			openIndexFunc = func(index.Options) (*index.Index, error) { return idx, nil }
+		}, search, []string{"wireguard"}, exitModel},
+		{"stats ok", func(t *testing.T) { useTestIndex(t, true) }, stats, nil, exitOK},
+		{"stats missing index", func(t *testing.T) {
+			setGlobals(t, filepath.Join(t.TempDir(), "none"))
+		}, stats, nil, exitIndex},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setup(t)
+			err := tc.cmd.RunE(tc.cmd, tc.args)
+			if got := exitCode(err); got != tc.want {
+				t.Errorf("exit code = %d, want %d (err: %v)", got, tc.want, err)
+			}
+		})
+	}
+}
+
+func TestExitCodeUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{"search"},                     // missing query
+		{"search", "--bogus", "x"},     // unknown flag
+		{"--bogus", "x"},               // unknown flag on the root search
+		{"--threads", "many", "stats"}, // bad flag value
+	} {
+		reachedRun = false
+		rootCmd.SetArgs(args)
+		err := Execute()
+		if got := exitCode(err); got != exitUsage {
+			t.Errorf("%v: exit code = %d, want %d (err: %v)", args, got, exitUsage, err)
+		}
+	}
+	rootCmd.SetArgs(nil)
+}
+
+// queryFailEmbedder embeds documents but fails on every query.
+type queryFailEmbedder struct{ mockEmbedder }
+
+func (*queryFailEmbedder) EmbedQuery(string) ([]float32, error) {
+	return nil, errors.New("tokenizer exploded")
+}
+
+func TestErrorCodes(t *testing.T) {
+	search := findCmd(t, "search")
+	cases := []struct {
+		name  string
+		setup func(t *testing.T)
+		want  string
+	}{
+		{"missing index", func(t *testing.T) {
+			setGlobals(t, filepath.Join(t.TempDir(), "none"))
+		}, codeNoIndex},
+		{"corrupt index", func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "meta.json"), []byte("{not json"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			setGlobals(t, dir)
+		}, codeIndexCorrupt},
+		{"model missing", func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "meta.json"), []byte("[]"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			setGlobals(t, dir)
+			modelDir = t.TempDir()
+		}, codeModelMissing},
+		{"ort init", func(t *testing.T) {
+			useTestIndex(t, false)
+			openIndexFunc = func(index.Options) (*index.Index, error) {
+				return nil, fmt.Errorf("%w: %w", index.ErrEmbedder,
+					fmt.Errorf("%w: libonnxruntime.so: cannot open shared object file", embed.ErrRuntime))
+			}
+		}, codeORTInit},
+		{"query embedding", func(t *testing.T) {
+			useTestIndex(t, false)
+			idx := index.NewTestIndex(indexDir, &queryFailEmbedder{})
+			openIndexFunc = func(index.Options) (*index.Index, error) { return idx, nil }
+		}, codeQueryEmbed},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.setup(t)
+			err := search.RunE(search, []string{"wireguard"})
+			if err == nil {
+				t.Fatal("search succeeded")
+			}
+			var buf bytes.Buffer
+			if err := writeErrorJSON(&buf, err); err != nil {
+				t.Fatal(err)
+			}
+			var rep errorReport
+			if err := json.Unmarshal(buf.Bytes(), &rep); err != nil {
+				t.Fatalf("not a JSON error report: %v\n%s", err, buf.String())
+			}
+			if rep.Error.Code != tc.want || rep.Error.Message != err.Error() {
+				t.Errorf("report = %+v, want code %s for %q", rep.Error, tc.want, err)
+			}
+			if bytes.Count(buf.Bytes(), []byte("\n")) != 1 {
+				t.Errorf("report is not a single line:\n%s", buf.String())
+			}
+		})
+	}
+
+	if code, hint := errorCode(&usageError{errors.New("bad flag")}); code != codeUsage || hint == "" {
+		t.Errorf("usage error = %s, %q", code, hint)
+	}
+}
+
+func TestWantErrorsJSON(t *testing.T) {
+	setGlobals(t, filepath.Join(t.TempDir(), "none"))
+	statsCmd := findCmd(t, "stats")
+	t.Cleanup(func() {
+		statsJSON, errorsJSON = false, false
+		statsCmd.Flags().Lookup("json").Changed = false
+		rootCmd.PersistentFlags().Lookup("errors-json").Changed = false
+		rootCmd.SetArgs(nil)
+	})
+	for _, tc := range []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"stats"}, false},
+		{[]string{"stats", "--json"}, true},
+		{[]string{"--errors-json", "stats"}, true},
+	} {
+		statsJSON, errorsJSON = false, false
+		reachedRun = false
+		rootCmd.SetArgs(tc.args)
+		if err := Execute(); !errors.Is(err, index.ErrNoIndex) {
+			t.Fatalf("%v: err = %v, want ErrNoIndex", tc.args, err)
+		}
+		if got := wantErrorsJSON(executedCmd); got != tc.want {
+			t.Errorf("%v: wantErrorsJSON = %v, want %v", tc.args, got, tc.want)
+		}
+	}
+}
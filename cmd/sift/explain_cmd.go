@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+)
+
+var (
+	explainPool int
+	explainJSON bool
+)
+
+func init() {
+	explainCmd := &cobra.Command{
+		Use:   "explain <query> <path>",
+		Short: "Show how a file's rank for a query is computed",
+		Long: `Run query as search does, but over a wider candidate pool, and report how
+path ranks: the vector (cosine) and keyword components of each of its
+candidate chunks, the query words that earned a keyword boost, its rank
+before and after one-hit-per-file dedup, and the files that beat it.
+
+The report names the query plan search follows: "lexical" for a query of
+one or two words that few chunks hold, whose candidates are those chunks,
+and "hnsw" for the rest, whose candidates come from the graph. With
+expand-queries set, it lists the synonym variants searched with the query
+and names the one that surfaced each chunk found through a variant.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if explainPool < 1 {
+				return &usageError{fmt.Errorf("--pool must be at least 1, got %d", explainPool)}
+			}
+			idx, err := openExistingIndex(ortLib)
+			if err != nil {
+				return err
+			}
+			defer idx.Close()
+
+			ex, err := idx.Explain(args[0], args[1], explainPool)
+			if err != nil {
+				return err
+			}
+			if explainJSON {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(ex)
+			}
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("getwd: %w", err)
+			}
+			writeExplanation(cmd.OutOrStdout(), ex, cwd)
+			return nil
+		},
+	}
+	explainCmd.Flags().IntVar(&explainPool, "pool", 200, "number of candidate chunks to rank")
+	explainCmd.Flags().BoolVar(&explainJSON, "json", false, "output the breakdown as JSON")
+	rootCmd.AddCommand(explainCmd)
+}
+
+// writeExplanation prints ex as a readable report, with paths relative to cwd.
+func writeExplanation(w io.Writer, ex *index.Explanation, cwd string) {
+	fmt.Fprintf(w, "Query: %q\nFile:  %s\n", ex.Query, displayPath(cwd, ex.Path))
+	switch ex.Plan {
+	case index.QueryPlanLexical:
+		fmt.Fprintln(w, "Plan:  lexical (every chunk holding a query word, scored exactly)")
+	case index.QueryPlanHNSW:
+		fmt.Fprintln(w, "Plan:  hnsw (graph search)")
+	}
+	if len(ex.Variants) > 0 {
+		fmt.Fprintf(w, "Variants: %s (scores weighted %.1f× per variant)\n", quotedList(ex.Variants), index.VariantDecay)
+	}
+	fmt.Fprintln(w)
+	switch {
+	case !ex.Indexed:
+		fmt.Fprintln(w, "Not in the index (excluded, unsupported, too large, or not yet indexed).")
+		return
+	case ex.Rank == 0 && ex.Plan == index.QueryPlanLexical:
+		fmt.Fprintf(w, "Not among the %d chunks holding a query word.\n", ex.Candidates)
+	case ex.Rank == 0:
+		fmt.Fprintf(w, "Not among the top %d candidate chunks; raise --pool to look further.\n", ex.Candidates)
+	default:
+		fmt.Fprintf(w, "Rank %d of %d files (best chunk #%d of %d candidates).\n",
+			ex.Rank, ex.Files, ex.ChunkRank, ex.Candidates)
+		fmt.Fprintf(w, "Score = vector + keyword, where each matched query word adds %.2f, weighted 0.5–2× by how rare it is in the index", index.KeywordBoost)
+		if len(ex.BoostWords) == 0 {
+			fmt.Fprint(w, "; this query has too few words besides stopwords to be boosted.\n\n")
+		} else {
+			fmt.Fprintf(w, " (boosted words: %s).\n\n", strings.Join(ex.BoostWords, ", "))
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "CHUNK\tLINE\tRANK\tSCORE\tVECTOR\tKEYWORD\tMATCHED")
+		for _, c := range ex.Chunks {
+			fmt.Fprintf(tw, "%d\t%d\t%d\t%.4f\t%.4f\t%.4f\t%s%s\n",
+				c.ChunkIndex, c.Line, c.Rank, c.Score, c.Vector, c.Keyword, matchedList(c.Matched, c.MatchedTokens), viaVariant(c.Variant))
+		}
+		tw.Flush()
+	}
+
+	if len(ex.Ahead) == 0 {
+		return
+	}
+	if ex.Rank == 0 {
+		fmt.Fprintln(w, "\nCandidate files:")
+	} else {
+		fmt.Fprintln(w, "\nRanked above it:")
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, c := range ex.Ahead {
+		if i == maxExplainAhead {
+			fmt.Fprintf(tw, "\t… and %d more (see --json)\n", len(ex.Ahead)-i)
+			break
+		}
+		fmt.Fprintf(tw, "%d.\t%s:%d\t%.4f\t(%.4f + %.4f)\t%s%s\n",
+			i+1, displayPath(cwd, c.Path), c.Line, c.Score, c.Vector, c.Keyword, matchedList(c.Matched, c.MatchedTokens), viaVariant(c.Variant))
+	}
+	tw.Flush()
+}
+
+// maxExplainAhead caps the files listed above the target in the text report.
+const maxExplainAhead = 20
+
+// matchedList lists the matched query words, each followed by the word of
+// the chunk it matched when that is spelled otherwise (oauth (OAuthToken)).
+func matchedList(words, tokens []string) string {
+	if len(words) == 0 {
+		return "-"
+	}
+	out := make([]string, len(words))
+	for i, w := range words {
+		out[i] = w
+		if i < len(tokens) && !strings.EqualFold(tokens[i], w) {
+			out[i] += " (" + tokens[i] + ")"
+		}
+	}
+	return strings.Join(out, ", ")
+}
+
+// viaVariant names the query variant a chunk was found through, if any.
+func viaVariant(v string) string {
+	if v == "" {
+		return ""
+	}
+	return fmt.Sprintf("  via %q", v)
+}
+
+// quotedList quotes each of words and joins them with commas.
+func quotedList(words []string) string {
+	out := make([]string, len(words))
+	for i, w := range words {
+		out[i] = strconv.Quote(w)
+	}
+	return strings.Join(out, ", ")
+}
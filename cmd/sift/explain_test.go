@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestWriteExplanation(t *testing.T) {
+	ex := &index.Explanation{
+		Query: "wireguard tunnel", Path: "/proj/docs/c.md", Indexed: true, Plan: index.QueryPlanHNSW,
+		BoostWords: []string{"wireguard", "tunnel"},
+		Candidates: 4, Files: 4, Rank: 2, ChunkRank: 3,
+		Chunks: []index.ChunkScore{
+			{Path: "/proj/docs/c.md", Line: 1, Rank: 3, Score: 0.7571, Vector: 0.7071, Keyword: 0.05, Matched: []string{"tunnel"}},
+		},
+		Ahead: []index.ChunkScore{
+			{Path: "/proj/docs/a.md", Line: 4, Rank: 1, Score: 1.1, Vector: 1, Keyword: 0.1, Matched: []string{"wireguard", "tunnel"}},
+		},
+	}
+	var out bytes.Buffer
+	writeExplanation(&out, ex, "/proj")
+	for _, want := range []string{
+		"File:  docs/c.md\nPlan:  hnsw (graph search)",
+		"Rank 2 of 4 files (best chunk #3 of 4 candidates)",
+		"(boosted words: wireguard, tunnel)",
+		"0.7571  0.7071  0.0500   tunnel",
+		"Ranked above it:",
+		"1.  docs/a.md:4  1.1000  (1.0000 + 0.1000)  wireguard, tunnel",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, out.String())
+		}
+	}
+
+	// With expansion, the variants are listed, and each chunk a variant
+	// surfaced names it.
+	ex.Variants = []string{"wireguard channel"}
+	ex.Chunks[0].Variant = "wireguard channel"
+	out.Reset()
+	writeExplanation(&out, ex, "/proj")
+	for _, want := range []string{
+		`Variants: "wireguard channel" (scores weighted 0.9× per variant)`,
+		`0.7571  0.7071  0.0500   tunnel  via "wireguard channel"`,
+		"1.  docs/a.md:4  1.1000  (1.0000 + 0.1000)  wireguard, tunnel\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report with variants missing %q:\n%s", want, out.String())
+		}
+	}
+
+	ex.BoostWords = nil
+	out.Reset()
+	writeExplanation(&out, ex, "/proj")
+	if !strings.Contains(out.String(), "too few words besides stopwords") {
+		t.Errorf("report of an unboosted query:\n%s", out.String())
+	}
+
+	ex.Plan, ex.Rank = index.QueryPlanLexical, 0
+	out.Reset()
+	writeExplanation(&out, ex, "/proj")
+	if !strings.Contains(out.String(), "Plan:  lexical") || !strings.Contains(out.String(), "Not among the 4 chunks holding a query word") {
+		t.Errorf("report of a lexical plan:\n%s", out.String())
+	}
+
+	ex.Indexed = false
+	out.Reset()
+	writeExplanation(&out, ex, "/proj")
+	if !strings.Contains(out.String(), "Not in the index") || strings.Contains(out.String(), "Ranked above") {
+		t.Errorf("unindexed report:\n%s", out.String())
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+)
+
+var (
+	exportFormat string
+	exportOut    string
+)
+
+func init() {
+	exportCmd := &cobra.Command{
+		Use:   "export-vectors",
+		Short: "Export every chunk's embedding with its provenance (jsonl or csv)",
+		Long: `Export every chunk's embedding with its path, line, byte range and mtime,
+for analysis outside sift (clustering, plotting). Rows are streamed, so
+large indexes are not held in memory twice.
+
+jsonl writes one object per chunk; csv writes the vector as a JSON array in
+its last column. Parquet is not supported: it would need a dependency sift
+does not carry, and every dataframe library reads jsonl.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if exportFormat != "jsonl" && exportFormat != "csv" {
+				return &usageError{fmt.Errorf("--format: want jsonl or csv, got %q", exportFormat)}
+			}
+			// The graph holds the vectors; no need to load the model.
+			idx, err := index.OpenReadOnly(indexDir)
+			if errors.Is(err, index.ErrNoIndex) {
+				return noIndexError()
+			}
+			if err != nil {
+				return err
+			}
+			defer idx.Close()
+
+			w := cmd.OutOrStdout()
+			if exportOut != "-" {
+				f, err := os.Create(exportOut)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			bw := bufio.NewWriter(w)
+			n, err := exportVectors(bw, idx, exportFormat)
+			if err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			if err := bw.Flush(); err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			if exportOut != "-" {
+				logger.Infof("Exported %d chunks to %s", n, exportOut)
+			}
+			return nil
+		},
+	}
+	exportCmd.Flags().StringVar(&exportFormat, "format", "jsonl", "output format: jsonl or csv")
+	exportCmd.Flags().StringVarP(&exportOut, "out", "o", "-", "output file (- for stdout)")
+	rootCmd.AddCommand(exportCmd)
+}
+
+// vectorRecord is one exported chunk; the jsonl schema.
+type vectorRecord struct {
+	ID         int       `json:"id"`
+	ChunkID    string    `json:"chunk_id"`
+	Path       string    `json:"path"`
+	Line       int       `json:"line"`
+	ChunkIndex int       `json:"chunk_index"`
+	StartByte  int64     `json:"start_byte"`
+	EndByte    int64     `json:"end_byte"`
+	Mtime      time.Time `json:"mtime"`
+	Vector     []float32 `json:"vector"`
+}
+
+// vectorCSVHeader names the csv columns, matching vectorRecord.
+var vectorCSVHeader = []string{"id", "chunk_id", "path", "line", "chunk_index", "start_byte", "end_byte", "mtime", "vector"}
+
+// exportVectors streams every chunk of idx to w in format and returns how
+// many were written.
+func exportVectors(w io.Writer, idx *index.Index, format string) (int, error) {
+	var write func(vectorRecord) error
+	var cw *csv.Writer
+	switch format {
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		write = func(r vectorRecord) error { return enc.Encode(r) }
+	case "csv":
+		cw = csv.NewWriter(w)
+		if err := cw.Write(vectorCSVHeader); err != nil {
+			return 0, err
+		}
+		write = func(r vectorRecord) error {
+			vec, err := json.Marshal(r.Vector)
+			if err != nil {
+				return err
+			}
+			return cw.Write([]string{
+				strconv.Itoa(r.ID), r.ChunkID, r.Path, strconv.Itoa(r.Line), strconv.Itoa(r.ChunkIndex),
+				strconv.FormatInt(r.StartByte, 10), strconv.FormatInt(r.EndByte, 10),
+				r.Mtime.UTC().Format(time.RFC3339Nano), string(vec),
+			})
+		}
+	default:
+		return 0, fmt.Errorf("unknown format %q", format)
+	}
+
+	n := 0
+	err := idx.EachVector(func(id int, c index.ChunkMeta, vec []float32) error {
+		n++
+		return write(vectorRecord{
+			ID:         id,
+			ChunkID:    c.ID,
+			Path:       c.Path,
+			Line:       c.LineNum,
+			ChunkIndex: c.ChunkIndex,
+			StartByte:  c.StartByte,
+			EndByte:    c.EndByte,
+			Mtime:      c.Mtime.UTC(),
+			Vector:     vec,
+		})
+	})
+	if cw != nil {
+		cw.Flush()
+		if err == nil {
+			err = cw.Error()
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// textEmbedder derives a distinct, exactly representable vector from each
+// text, so exported values can be checked against a fresh embedding.
+type textEmbedder struct{}
+
+func (textEmbedder) Embed(texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i, s := range texts {
+		v := make([]float32, 384)
+		for j := range v {
+			v[j] = float32(int(s[j%len(s)])%16) * 0.125
+		}
+		vecs[i] = v
+	}
+	return vecs, nil
+}
+
+func (e textEmbedder) EmbedQuery(q string) ([]float32, error) {
+	v, err := e.Embed([]string{q})
+	return v[0], err
+}
+
+func (textEmbedder) Close() {}
+
+func exportFixture(t *testing.T) *index.Index {
+	t.Helper()
+	dir := t.TempDir()
+	idx := index.NewTestIndex(filepath.Join(dir, ".sift"), textEmbedder{})
+	for name, body := range map[string]string{
+		"vpn.md":    "wireguard config lives here",
+		"backup.md": "homelab backup schedule runs nightly",
+		"main.go":   "package main\n\nfunc main() {}",
+	} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return idx
+}
+
+func TestExportVectors_JSONL(t *testing.T) {
+	idx := exportFixture(t)
+	var buf bytes.Buffer
+	n, err := exportVectors(&buf, idx, "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := idx.Stats().NumChunks; n != want {
+		t.Errorf("exported %d chunks, index has %d", n, want)
+	}
+
+	sc := bufio.NewScanner(&buf)
+	sc.Buffer(nil, 1<<20)
+	rows := 0
+	for sc.Scan() {
+		var r vectorRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("row %d: %v", rows, err)
+		}
+		chunks := idx.FileChunks(r.Path)
+		if len(chunks) != 1 || r.Line != chunks[0].LineNum || r.EndByte != chunks[0].EndByte || !r.Mtime.Equal(chunks[0].Mtime) {
+			t.Errorf("row %d provenance %+v does not match %+v", rows, r, chunks)
+			continue
+		}
+		want, _ := textEmbedder{}.Embed([]string{chunks[0].Text})
+		if !slices.Equal(r.Vector, want[0]) {
+			t.Errorf("row %d (%s): vector does not round-trip", rows, filepath.Base(r.Path))
+		}
+		rows++
+	}
+	if rows != n {
+		t.Errorf("read %d rows, wrote %d", rows, n)
+	}
+}
+
+func TestExportVectors_CSV(t *testing.T) {
+	idx := exportFixture(t)
+	var buf bytes.Buffer
+	if _, err := exportVectors(&buf, idx, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || !slices.Equal(records[0], vectorCSVHeader) {
+		t.Fatalf("got %d records, header %q", len(records), records[0])
+	}
+	for _, rec := range records[1:] {
+		var vec []float32
+		if err := json.Unmarshal([]byte(rec[len(rec)-1]), &vec); err != nil || len(vec) != 384 {
+			t.Errorf("vector column of %s: %d values, %v", rec[1], len(vec), err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+)
+
+var getJSON bool
+
+// chunkRecord is the --json form of `sift get`.
+type chunkRecord struct {
+	ID         string    `json:"id"`
+	Path       string    `json:"path"`
+	RelPath    string    `json:"rel_path"`
+	Line       int       `json:"line"`
+	StartByte  int64     `json:"start_byte"`
+	EndByte    int64     `json:"end_byte"`
+	ChunkIndex int       `json:"chunk_index"`
+	Mtime      time.Time `json:"mtime"`
+	Text       string    `json:"text"`
+}
+
+func init() {
+	getCmd := &cobra.Command{
+		Use:   "get <chunk-id>",
+		Short: "Print an indexed chunk's full text and where it came from",
+		Long: `Look up a chunk by the stable ID that search, explain, export and the
+MCP and HTTP servers report for it, and print its location followed by its
+full text. The ID stays the same across re-indexing and compaction for as
+long as the chunk's file, position and text are unchanged.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Lookup only reads meta.json; no need to load the model.
+			idx, err := index.OpenReadOnly(indexDir)
+			if errors.Is(err, index.ErrNoIndex) {
+				return noIndexError()
+			}
+			if err != nil {
+				return err
+			}
+			defer idx.Close()
+
+			c, ok := idx.GetChunk(args[0])
+			if !ok {
+				return fmt.Errorf("no chunk with ID %q in the index (IDs change when a chunk's file is edited)", args[0])
+			}
+			text, err := c.FullText()
+			if err != nil {
+				return err
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("getwd: %w", err)
+			}
+			w := cmd.OutOrStdout()
+			if getJSON {
+				j, err := json.MarshalIndent(chunkRecord{
+					ID:         c.ID,
+					Path:       c.Path,
+					RelPath:    displayPath(cwd, c.Path),
+					Line:       c.LineNum,
+					StartByte:  c.StartByte,
+					EndByte:    c.EndByte,
+					ChunkIndex: c.ChunkIndex,
+					Mtime:      c.Mtime,
+					Text:       text,
+				}, "", "  ")
+				if err != nil {
+					return fmt.Errorf("marshal json: %w", err)
+				}
+				fmt.Fprintln(w, string(j))
+				return nil
+			}
+			fmt.Fprintf(w, "%s:%d  (chunk %d, bytes %d–%d, modified %s)\n",
+				displayPath(cwd, c.Path), c.LineNum, c.ChunkIndex,
+				c.StartByte, c.EndByte, c.Mtime.Local().Format(time.DateTime))
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, text)
+			return nil
+		},
+	}
+	getCmd.Flags().BoolVar(&getJSON, "json", false, "output the chunk and its provenance as JSON")
+	rootCmd.AddCommand(getCmd)
+}
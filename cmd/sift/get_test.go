@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestGet(t *testing.T) {
+	useTestIndex(t, true)
+	idx, err := index.OpenReadOnly(indexDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id string
+	idx.EachVector(func(_ int, meta index.ChunkMeta, _ []float32) error {
+		id = meta.ID
+		return nil
+	})
+	idx.Close()
+
+	get := findCmd(t, "get")
+	var out bytes.Buffer
+	get.SetOut(&out)
+	t.Cleanup(func() { get.SetOut(nil); getJSON = false })
+
+	if err := get.RunE(get, []string{id}); err != nil {
+		t.Fatal(err)
+	}
+	if s := out.String(); !strings.Contains(s, "notes.md:1  (chunk 0, bytes 0–27") || !strings.HasSuffix(s, "\nwireguard config lives here\n") {
+		t.Errorf("output:\n%s", s)
+	}
+
+	out.Reset()
+	getJSON = true
+	if err := get.RunE(get, []string{id}); err != nil {
+		t.Fatal(err)
+	}
+	var rec chunkRecord
+	if err := json.Unmarshal(out.Bytes(), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec.ID != id || rec.Text != "wireguard config lives here" || !strings.HasSuffix(rec.Path, "notes.md") {
+		t.Errorf("record = %+v", rec)
+	}
+
+	if err := get.RunE(get, []string{"0000000000000000"}); err == nil || !strings.Contains(err.Error(), "0000000000000000") {
+		t.Errorf("unknown ID: err = %v", err)
+	}
+}
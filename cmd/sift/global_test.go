@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestGlobalIndex(t *testing.T) {
+	base := t.TempDir()
+	home := filepath.Join(base, "home")
+	project := filepath.Join(base, "project")
+	notes := filepath.Join(home, "notes")
+	for _, d := range []string{notes, project} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(notes, "vpn.md"), []byte("wireguard config lives here"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", filepath.Join(base, "data"))
+	globalDir := filepath.Join(base, "data", "sift", "global")
+
+	setGlobals(t, "")
+	opened := make(map[string]*index.Index)
+	openIndexFunc = func(o index.Options) (*index.Index, error) {
+		if opened[o.Dir] == nil {
+			opened[o.Dir] = index.NewTestIndex(o.Dir, &mockEmbedder{})
+		}
+		return opened[o.Dir], nil
+	}
+	globalFlag := rootCmd.PersistentFlags().Lookup("global")
+	run := func(args ...string) error {
+		// Flags keep their values between Execute calls; start clean.
+		globalIndex, globalFlag.Changed = false, false
+		reachedRun = false
+		rootCmd.SetArgs(args)
+		return Execute()
+	}
+	t.Cleanup(func() {
+		globalIndex, globalFlag.Changed = false, false
+		rootCmd.SetArgs(nil)
+	})
+
+	// A relative root is remembered as an absolute path.
+	t.Chdir(home)
+	if err := run("--global", "index", "notes"); err != nil {
+		t.Fatal(err)
+	}
+	if indexDir != globalDir {
+		t.Errorf("indexDir = %q, want %q", indexDir, globalDir)
+	}
+	m, err := index.ReadManifest(globalDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(m.Roots, []string{notes}) {
+		t.Errorf("persisted roots = %v, want [%s]", m.Roots, notes)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".sift")); !os.IsNotExist(err) {
+		t.Error("--global created a project index")
+	}
+
+	// From any directory: no args re-indexes the remembered roots.
+	t.Chdir(project)
+	if err := run("--global", "index"); err != nil {
+		t.Fatalf("index remembered roots: %v", err)
+	}
+	if err := run("--global", "search", "wireguard"); err != nil {
+		t.Fatalf("global search: %v", err)
+	}
+
+	// Without the flag the global index is never used implicitly.
+	err = run("search", "wireguard")
+	if got := exitCode(err); got != exitIndex || !strings.Contains(err.Error(), "--global") {
+		t.Errorf("project search without index: exit %d, err %v; want %d with a --global hint", got, err, exitIndex)
+	}
+	if err := run("index"); exitCode(err) != exitUsage {
+		t.Errorf("index without dirs or --global: err = %v, want usage error", err)
+	}
+}
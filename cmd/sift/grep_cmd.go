@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+)
+
+var (
+	grepAbout      string
+	grepFixed      bool
+	grepIgnoreCase bool
+	grepTopK       int
+	grepIn         []string
+	grepJSON       bool
+)
+
+func init() {
+	grepCmd := &cobra.Command{
+		Use:   "grep <pattern>",
+		Short: "Find exact text, ranked by relevance to a query",
+		Long: `Find the indexed chunks whose text matches pattern, a Go regular expression
+(or, with --fixed-strings, literal text), and rank them by how close they are
+in meaning to the --about query, or to pattern itself without one: the
+pattern finds the occurrences, the query says which matter. Every chunk is
+scanned, so unlike search this finds every occurrence, however few.
+
+Output is grouped by file, ripgrep-style, with each file's best score; on a
+terminal, "--" separates the file's chunks and shows each one's score.
+Plain output (not a terminal, or --plain) is one path:line:score:text line
+per match.`,
+		Example: `  sift grep "connection reset by peer" --about "retry logic"
+  sift grep -F "os.Exit(1)" --about "fatal errors"
+  sift grep -i 'todo|fixme' --about "error handling" --in internal`,
+		Args: cobra.ExactArgs(1),
+		RunE: runGrep,
+	}
+	f := grepCmd.Flags()
+	f.StringVar(&grepAbout, "about", "", "rank matches by relevance to this query (default the pattern)")
+	f.BoolVarP(&grepFixed, "fixed-strings", "F", false, "treat the pattern as literal text, not a regular expression")
+	f.BoolVarP(&grepIgnoreCase, "ignore-case", "i", false, "match case-insensitively")
+	f.IntVar(&grepTopK, "top-k", 20, "number of matching chunks to show; 0 shows all")
+	f.StringArrayVar(&grepIn, "in", nil, "only search files under this directory, relative to the working directory (repeatable)")
+	f.BoolVar(&grepJSON, "json", false, "output matches as JSON")
+	f.BoolVar(&plainOutput, "plain", false, "plain path:line:score:text output even on a terminal")
+	rootCmd.AddCommand(grepCmd)
+}
+
+func runGrep(cmd *cobra.Command, args []string) error {
+	if grepTopK < 0 {
+		return &usageError{fmt.Errorf("--top-k must not be negative, got %d", grepTopK)}
+	}
+	pattern := args[0]
+	if pattern == "" {
+		return &usageError{errors.New("the pattern must not be empty")}
+	}
+	expr := pattern
+	if grepFixed {
+		expr = regexp.QuoteMeta(pattern)
+	}
+	if grepIgnoreCase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return &usageError{fmt.Errorf("pattern: %w (pass --fixed-strings to match it literally)", err)}
+	}
+	about := grepAbout
+	if about == "" {
+		about = pattern
+	}
+	var f index.Filter
+	for _, d := range grepIn {
+		abs, err := filepath.Abs(d)
+		if err != nil {
+			return &usageError{fmt.Errorf("--in %s: %w", d, err)}
+		}
+		f.Dirs = append(f.Dirs, abs)
+	}
+
+	idx, err := openExistingIndex(ortLib)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	matches, err := idx.Grep(cmd.Context(), re, about, grepTopK, f)
+	if err != nil {
+		return err
+	}
+	if grepJSON {
+		if matches == nil {
+			matches = []index.GrepMatch{}
+		}
+		j, err := json.MarshalIndent(matches, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal json: %w", err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(j))
+	} else {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("getwd: %w", err)
+		}
+		if plainOutput || !stdoutIsTTY() {
+			writeGrepPlain(cmd.OutOrStdout(), matches, cwd)
+		} else {
+			writeGrepPretty(cmd.OutOrStdout(), matches, cwd, colorEnabled())
+		}
+	}
+	if len(matches) == 0 {
+		return errNoResults
+	}
+	return nil
+}
+
+// writeGrepPlain prints one path:line:score:text line per matching line,
+// best chunk first.
+func writeGrepPlain(w io.Writer, matches []index.GrepMatch, cwd string) {
+	for _, m := range matches {
+		path := displayPath(cwd, m.Meta.Path)
+		for _, l := range m.Lines {
+			fmt.Fprintf(w, "%s:%d:%.3f:%s\n", path, l.Num, m.Score, l.Text)
+		}
+	}
+}
+
+// writeGrepPretty renders matches as writePretty does search results: a
+// path header per file, in the order of its best chunk, with that chunk's
+// score dimmed after it, then the matching lines with the matches
+// highlighted; a dimmed "--" and score introduce each further chunk.
+func writeGrepPretty(w io.Writer, matches []index.GrepMatch, cwd string, color bool) {
+	r := lipgloss.NewRenderer(w)
+	r.SetColorProfile(termenv.Ascii)
+	if color {
+		r.SetColorProfile(termenv.ANSI)
+	}
+	sPath := r.NewStyle().Foreground(lipgloss.Color("5")).Bold(true)
+	sLineNum := r.NewStyle().Foreground(lipgloss.Color("2"))
+	sMatch := r.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
+	sDim := r.NewStyle().Faint(true)
+
+	var order []string
+	byPath := make(map[string][]index.GrepMatch)
+	for _, m := range matches {
+		if _, ok := byPath[m.Meta.Path]; !ok {
+			order = append(order, m.Meta.Path)
+		}
+		byPath[m.Meta.Path] = append(byPath[m.Meta.Path], m)
+	}
+	for i, path := range order {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		chunks := byPath[path]
+		fmt.Fprintf(w, "%s  %s\n", sPath.Render(displayPath(cwd, path)), sDim.Render(fmt.Sprintf("%.3f", chunks[0].Score)))
+		for ci, m := range chunks {
+			if ci > 0 {
+				fmt.Fprintln(w, sDim.Render(fmt.Sprintf("-- %.3f", m.Score)))
+			}
+			for _, l := range m.Lines {
+				var line string
+				at := 0
+				for _, s := range l.Spans {
+					line += l.Text[at:s[0]] + sMatch.Render(l.Text[s[0]:s[1]])
+					at = s[1]
+				}
+				line += l.Text[at:]
+				fmt.Fprintf(w, "%s:%s\n", sLineNum.Render(strconv.Itoa(l.Num)), line)
+			}
+		}
+	}
+}
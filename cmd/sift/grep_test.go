@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestGrep(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	for name, body := range map[string]string{
+		"net/conn.go": "func dial() {\n\t// connection reset by peer\n}",
+		"notes.md":    "nothing to see",
+	} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	openIndexFunc = func(index.Options) (*index.Index, error) { return idx, nil }
+	oldFixed, oldPlain := grepFixed, plainOutput
+	t.Cleanup(func() { grepFixed, plainOutput = oldFixed, oldPlain })
+	plainOutput = true
+	t.Chdir(dir)
+
+	grep := findCmd(t, "grep")
+	grep.SetContext(context.Background())
+	var out bytes.Buffer
+	grep.SetOut(&out)
+	t.Cleanup(func() { grep.SetOut(nil) })
+	if err := grep.RunE(grep, []string{"reset by (peer|host)"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "net/conn.go:2:1.000:\t// connection reset by peer\n"; out.String() != want {
+		t.Errorf("output %q, want %q", out.String(), want)
+	}
+
+	// An invalid expression is a usage error, unless matched literally.
+	if err := grep.RunE(grep, []string{"dial("}); exitCode(err) != exitUsage {
+		t.Errorf("grep 'dial(': %v, want a usage error", err)
+	}
+	grepFixed = true
+	out.Reset()
+	if err := grep.RunE(grep, []string{"dial("}); err != nil || out.String() != "net/conn.go:1:1.000:func dial() {\n" {
+		t.Errorf("grep -F 'dial(': %v, output %q", err, out.String())
+	}
+	if err := grep.RunE(grep, []string{"no such text"}); exitCode(err) != exitNoResults {
+		t.Errorf("grep for missing text: %v, want exit code %d", err, exitNoResults)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// previewRunes is how much chunk text a hit carries without --full-text.
+const previewRunes = 200
+
+// searchHit is the documented `sift search --full-text` / `--ndjson` record.
+// It is decoupled from index.SearchResult so internal changes don't leak
+// into the output; field names are stable snake_case.
+type searchHit struct {
+	Rank       int          `json:"rank"`
+	ID         string       `json:"id"`       // stable chunk ID; see `sift get`
+	Path       string       `json:"path"`     // relative to the index root when inside it
+	AbsPath    string       `json:"abs_path"` // absolute
+	RelPath    string       `json:"rel_path"` // relative to the working directory when below it
+	Line       int          `json:"line"`
+	StartByte  int64        `json:"start_byte"`
+	EndByte    int64        `json:"end_byte"`
+	ChunkIndex int          `json:"chunk_index"`
+	Mtime      time.Time    `json:"mtime"`
+	Score      float32      `json:"score"`
+	Scores     searchScores `json:"scores"`
+	Preview    string       `json:"preview"`
+	Text       *string      `json:"text,omitempty"` // only with --full-text
+	// Promoted marks a hit moved up by the max-per-dir cap.
+	Promoted bool `json:"promoted,omitempty"`
+	// Stale marks a hit whose file was modified after it was indexed.
+	Stale bool `json:"stale,omitempty"`
+	// Adjacent holds the chunks around the hit in its file; only with
+	// --with-adjacent.
+	Adjacent *adjacentChunks `json:"adjacent,omitempty"`
+}
+
+// adjacentChunks are the chunks before and after a hit, nearest last and
+// first respectively, as --with-adjacent adds them.
+type adjacentChunks struct {
+	Before []adjacentChunk `json:"before"`
+	After  []adjacentChunk `json:"after"`
+}
+
+// adjacentChunk is one chunk next to a hit; like the hit, it carries its
+// complete text only with --full-text.
+type adjacentChunk struct {
+	ID         string  `json:"id"`
+	Line       int     `json:"line"`
+	StartByte  int64   `json:"start_byte"`
+	EndByte    int64   `json:"end_byte"`
+	ChunkIndex int     `json:"chunk_index"`
+	Preview    string  `json:"preview"`
+	Text       *string `json:"text,omitempty"`
+}
+
+// adjacentFunc returns the chunks before and after the chunk with the
+// given ID; see index.Index.AdjacentChunks.
+type adjacentFunc func(id string) (before, after []index.ChunkMeta)
+
+// newAdjacentChunks converts the chunks around a hit.
+func newAdjacentChunks(before, after []index.ChunkMeta, fullText bool) *adjacentChunks {
+	convert := func(chunks []index.ChunkMeta) []adjacentChunk {
+		out := make([]adjacentChunk, len(chunks))
+		for i, c := range chunks {
+			out[i] = adjacentChunk{
+				ID:         c.ID,
+				Line:       c.LineNum,
+				StartByte:  c.StartByte,
+				EndByte:    c.EndByte,
+				ChunkIndex: c.ChunkIndex,
+				Preview:    preview(c.Text),
+			}
+			if fullText {
+				text, _ := c.FullText()
+				out[i].Text = &text
+			}
+		}
+		return out
+	}
+	return &adjacentChunks{Before: convert(before), After: convert(after)}
+}
+
+// searchScores breaks Score down by signal. Rerank is only present with
+// --rerank, and is then what Score holds; Calibrated only with the
+// calibrate setting, and is then what --threshold compares.
+type searchScores struct {
+	Vector     float32  `json:"vector"`
+	Keyword    float32  `json:"keyword"`
+	Rerank     *float32 `json:"rerank,omitempty"`
+	Calibrated *float32 `json:"calibrated,omitempty"`
+	// Matched lists the query words that earned the keyword boost, and
+	// MatchedTokens the word of the chunk each matched.
+	Matched       []string `json:"matched,omitempty"`
+	MatchedTokens []string `json:"matched_tokens,omitempty"`
+}
+
+// newSearchHit converts the rank-th (1-based) result; Path is relative to
+// the index root and RelPath to cwd, each when the file lies inside it.
+func newSearchHit(rank int, r index.SearchResult, cwd string, fullText bool) searchHit {
+	h := searchHit{
+		Rank:       rank,
+		ID:         r.Meta.ID,
+		Path:       index.RootRelative(indexDir, r.Meta.Path),
+		AbsPath:    r.Meta.Path,
+		RelPath:    r.Meta.Path,
+		Line:       r.Meta.LineNum,
+		StartByte:  r.Meta.StartByte,
+		EndByte:    r.Meta.EndByte,
+		ChunkIndex: r.Meta.ChunkIndex,
+		Mtime:      r.Meta.Mtime.UTC(),
+		Score:      r.Score,
+		Scores:     searchScores{Vector: r.Vector, Keyword: r.Keyword, Rerank: r.Rerank, Calibrated: r.Calibrated, Matched: r.Matched, MatchedTokens: r.MatchedTokens},
+		Preview:    preview(r.Meta.Text),
+		Promoted:   r.Promoted,
+		Stale:      r.Stale,
+	}
+	if rel, err := filepath.Rel(cwd, r.Meta.Path); err == nil && filepath.IsLocal(rel) {
+		h.RelPath = filepath.ToSlash(rel)
+	}
+	if fullText {
+		text, _ := r.Meta.FullText()
+		h.Text = &text
+	}
+	return h
+}
+
+func preview(s string) string {
+	n := 0
+	for i := range s {
+		if n == previewRunes {
+			return s[:i]
+		}
+		n++
+	}
+	return s
+}
+
+// jsonResult is a `sift search --json` result: the index's own record,
+// with Meta.Path relative to the index root (as searchHit.Path is) and
+// the absolute path alongside.
+type jsonResult struct {
+	index.SearchResult
+	AbsPath string `json:"abs_path"`
+}
+
+func jsonResults(results []index.SearchResult) []jsonResult {
+	out := make([]jsonResult, len(results))
+	for i, r := range results {
+		out[i] = jsonResult{SearchResult: r, AbsPath: r.Meta.Path}
+		out[i].Meta.Path = index.RootRelative(indexDir, r.Meta.Path)
+	}
+	return out
+}
+
+// writeSearchHits writes results as a JSON array, or with ndjson set as one
+// object per line so consumers can process large outputs incrementally.
+// With adjacent set each hit carries the chunks around it.
+func writeSearchHits(w io.Writer, results []index.SearchResult, cwd string, fullText, ndjson bool, adjacent adjacentFunc) error {
+	hits := make([]searchHit, len(results))
+	for i, r := range results {
+		hits[i] = newSearchHit(i+1, r, cwd, fullText)
+		if adjacent != nil {
+			before, after := adjacent(r.Meta.ID)
+			hits[i].Adjacent = newAdjacentChunks(before, after, fullText)
+		}
+	}
+	if ndjson {
+		enc := json.NewEncoder(w)
+		for _, h := range hits {
+			if err := enc.Encode(h); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	j, err := json.MarshalIndent(hits, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal json: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(j))
+	return err
+}
+
+// hitColumn is one field of a searchHit that tabular output can select.
+type hitColumn struct {
+	name  string
+	value func(h searchHit) string
+}
+
+// hitColumns lists the selectable fields, in their documented order.
+var hitColumns = []hitColumn{
+	{"rank", func(h searchHit) string { return strconv.Itoa(h.Rank) }},
+	{"score", func(h searchHit) string { return formatScore(h.Score) }},
+	{"path", func(h searchHit) string { return h.Path }},
+	{"abs_path", func(h searchHit) string { return h.AbsPath }},
+	{"rel_path", func(h searchHit) string { return h.RelPath }},
+	{"line", func(h searchHit) string { return strconv.Itoa(h.Line) }},
+	{"mtime", func(h searchHit) string { return h.Mtime.Format(time.RFC3339) }},
+	{"snippet", func(h searchHit) string { return strings.Join(strings.Fields(h.Preview), " ") }},
+	{"chunk_index", func(h searchHit) string { return strconv.Itoa(h.ChunkIndex) }},
+	{"id", func(h searchHit) string { return h.ID }},
+	{"start_byte", func(h searchHit) string { return strconv.FormatInt(h.StartByte, 10) }},
+	{"end_byte", func(h searchHit) string { return strconv.FormatInt(h.EndByte, 10) }},
+	{"vector", func(h searchHit) string { return formatScore(h.Scores.Vector) }},
+	{"keyword", func(h searchHit) string { return formatScore(h.Scores.Keyword) }},
+	{"matched", func(h searchHit) string { return strings.Join(h.Scores.Matched, " ") }},
+	{"matched_tokens", func(h searchHit) string { return strings.Join(h.Scores.MatchedTokens, " ") }},
+	{"rerank", func(h searchHit) string {
+		if h.Scores.Rerank == nil {
+			return ""
+		}
+		return formatScore(*h.Scores.Rerank)
+	}},
+	{"stale", func(h searchHit) string { return strconv.FormatBool(h.Stale) }},
+	{"calibrated", func(h searchHit) string {
+		if h.Scores.Calibrated == nil {
+			return ""
+		}
+		return formatScore(*h.Scores.Calibrated)
+	}},
+	{"text", func(h searchHit) string {
+		if h.Text == nil {
+			return ""
+		}
+		return *h.Text
+	}},
+}
+
+// defaultHitColumns is the --columns default for csv output.
+const defaultHitColumns = "rank,score,path,line,mtime,snippet"
+
+// selectHitColumns parses a comma-separated --columns list.
+func selectHitColumns(spec string) ([]hitColumn, error) {
+	var cols []hitColumn
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		i := slices.IndexFunc(hitColumns, func(c hitColumn) bool { return c.name == name })
+		if i < 0 {
+			known := make([]string, len(hitColumns))
+			for j, c := range hitColumns {
+				known[j] = c.name
+			}
+			return nil, fmt.Errorf("unknown column %q (known: %s)", name, strings.Join(known, ", "))
+		}
+		cols = append(cols, hitColumns[i])
+	}
+	return cols, nil
+}
+
+func formatScore(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', 4, 32)
+}
+
+// writeSearchCSV writes results as CSV with a header row naming cols. The
+// chunk text is only read when the "text" column is selected.
+func writeSearchCSV(w io.Writer, results []index.SearchResult, cwd string, cols []hitColumn) error {
+	withText := slices.ContainsFunc(cols, func(c hitColumn) bool { return c.name == "text" })
+	cw := csv.NewWriter(w)
+	row := make([]string, len(cols))
+	for i, c := range cols {
+		row[i] = c.name
+	}
+	if err := cw.Write(row); err != nil {
+		return err
+	}
+	for i, r := range results {
+		h := newSearchHit(i+1, r, cwd, withText)
+		for j, c := range cols {
+			row[j] = c.value(h)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
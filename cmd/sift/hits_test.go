@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func goldenResults() []index.SearchResult {
+	mtime := time.Date(2025, 3, 14, 9, 26, 53, 0, time.UTC)
+	return []index.SearchResult{
+		{Score: 0.8731, Vector: 0.8231, Keyword: 0.05, Meta: index.ChunkMeta{
+			ID: "3f9a1c0e5b7d2468", Path: "/work/docs/vpn.md", LineNum: 12, StartByte: 950, EndByte: 2150, ChunkIndex: 1,
+			Text: "## WireGuard\n\nThe config lives in /etc/wireguard/wg0.conf.", Mtime: mtime,
+		}},
+		{Score: 0.5, Vector: 0.5, Meta: index.ChunkMeta{
+			ID: "c41d8e2f90ab7351", Path: "/elsewhere/notes.txt", LineNum: 1, EndByte: 420,
+			Text: strings.Repeat("x", 250), Mtime: mtime,
+		}},
+	}
+}
+
+func TestSearchHitsGolden(t *testing.T) {
+	setGlobals(t, "/work/.sift")
+	for _, tc := range []struct {
+		file             string
+		fullText, ndjson bool
+	}{
+		{"full_text.golden.json", true, false},
+		{"ndjson.golden", false, true},
+	} {
+		var buf bytes.Buffer
+		if err := writeSearchHits(&buf, goldenResults(), "/work", tc.fullText, tc.ndjson, nil); err != nil {
+			t.Fatal(err)
+		}
+		golden := filepath.Join("testdata", "search", tc.file)
+		if *update {
+			if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("output drifted from %s (run with -update if intended):\n%s", golden, buf.Bytes())
+		}
+	}
+}
+
+func TestWriteSearchCSV(t *testing.T) {
+	setGlobals(t, "/work/.sift")
+	results := goldenResults()
+	results[0].Meta.Text = "retention: \"90 days\",\n\tthen   purge,\r\nper GDPR"
+	cols, err := selectHitColumns(defaultHitColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := writeSearchCSV(&buf, results, "/work", cols); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("output is not valid CSV: %v\n%s", err, buf.String())
+	}
+	want := [][]string{
+		{"rank", "score", "path", "line", "mtime", "snippet"},
+		{"1", "0.8731", "docs/vpn.md", "12", "2025-03-14T09:26:53Z", `retention: "90 days", then purge, per GDPR`},
+		{"2", "0.5000", "/elsewhere/notes.txt", "1", "2025-03-14T09:26:53Z", strings.Repeat("x", previewRunes)},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q\nwant %q", rows, want)
+	}
+
+	// Columns are chosen and reordered; text keeps its newlines, quoted.
+	cols, err = selectHitColumns("text, abs_path,rank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := writeSearchCSV(&buf, results[:1], "/work", cols); err != nil {
+		t.Fatal(err)
+	}
+	rows, err = csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// encoding/csv reads a quoted \r\n back as \n.
+	want = [][]string{{"text", "abs_path", "rank"}, {"retention: \"90 days\",\n\tthen   purge,\nper GDPR", "/work/docs/vpn.md", "1"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q\nwant %q", rows, want)
+	}
+
+	if _, err := selectHitColumns("rank,bogus"); err == nil || !strings.Contains(err.Error(), `"bogus"`) {
+		t.Errorf("selectHitColumns(bogus) = %v, want an unknown-column error", err)
+	}
+}
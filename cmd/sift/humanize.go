@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/tejas242/sift/internal/format"
+)
+
+// The helpers below format numbers in text output through internal/format
+// ("84.2 MB", "2 hours ago"), or exactly with --raw, for scripts that
+// parse it. JSON output is always exact and does not use them.
+
+// sizeText formats n bytes: "84.2 MB", or "88290918 bytes" with --raw.
+func sizeText(n int64) string {
+	if rawOutput {
+		return strconv.FormatInt(n, 10) + " bytes"
+	}
+	return format.Bytes(n)
+}
+
+// sizeDeltaText is sizeText for a change in size, signed: "+1.5 KB".
+func sizeDeltaText(d int64) string {
+	if d >= 0 {
+		return "+" + sizeText(d)
+	}
+	return sizeText(d)
+}
+
+// countText abbreviates a large count, "120k", except with --raw.
+func countText(n int) string {
+	if rawOutput {
+		return strconv.Itoa(n)
+	}
+	return format.Count(n)
+}
+
+// durationText formats d as "14.2s", or in whole milliseconds with --raw.
+func durationText(d time.Duration) string {
+	if rawOutput {
+		return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+	}
+	return format.Duration(d)
+}
+
+// whenText gives t's age at now and the local time, "2 hours ago
+// (2026-10-16 09:12)", or t in RFC 3339 with --raw.
+func whenText(now, t time.Time) string {
+	if rawOutput {
+		return t.Format(time.RFC3339)
+	}
+	return format.Ago(now.Sub(t)) + " (" + t.Local().Format("2006-01-02 15:04") + ")"
+}
+
+// agoText gives t's age at now in short, "2h ago", or t in RFC 3339 with
+// --raw.
+func agoText(now, t time.Time) string {
+	if rawOutput {
+		return t.Format(time.RFC3339)
+	}
+	return format.AgoShort(now.Sub(t))
+}
@@ -2,38 +2,88 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
-	"syscall"
 
 	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+)
+
+var (
+	excludeGlobs     []string
+	includeOnlyGlobs []string
 )
 
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "index <dir> [dir...]",
-		Short: "Index all supported files in a directory",
-		Args:  cobra.MinimumNArgs(1),
+	indexCmd := &cobra.Command{
+		Use:               "index <path> [path...]",
+		Short:             "Index all supported files in directories, or single files",
+		Args:              dirArgs,
+		ValidArgsFunction: completeRoots,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			if dryRun {
+				return runDryRun(cmd.OutOrStdout(), args, false)
+			}
+			sink, err := newProgressSink(cmd.OutOrStdout(), "Scanning")
+			if err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
 			defer stop()
 
-			idx, err := openIndex(ortLib)
+			idx, err := openIndexWithPatterns()
 			if err != nil {
 				return err
 			}
 			defer idx.Close()
-
-			if err := indexDirs(ctx, idx, args); err != nil {
+			if args, err = indexRoots(idx, args); err != nil {
 				return err
 			}
+
+			// Arguments that could not be indexed are reported once the
+			// others are saved.
+			badArgs := indexDirs(ctx, idx, args, false, sink)
+			if badArgs != nil && !errors.As(badArgs, new(argErrors)) {
+				return abortRun(cmd.OutOrStdout(), "index", idx, badArgs)
+			}
 			if err := idx.Flush(); err != nil {
 				return err
 			}
 			s := idx.Stats()
 			fmt.Fprintf(os.Stderr, "Done. %d chunks from %d files indexed.\n", s.NumChunks, s.NumFiles)
-			return nil
+			if err := finishRun(cmd.OutOrStdout(), "index", idx); err != nil {
+				return err
+			}
+			return badArgs
 		},
-	})
+	}
+	addPatternFlags(indexCmd)
+	addProgressFlag(indexCmd)
+	addRunFlags(indexCmd)
+	indexCmd.Flags().BoolVar(&dryRun, "dry-run", false, "list what would be indexed without loading the model or writing anything")
+	rootCmd.AddCommand(indexCmd)
+}
+
+// addPatternFlags registers --exclude and --include-only on cmd.
+func addPatternFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&excludeGlobs, "exclude", nil, "skip paths matching this glob (repeatable; merged with .siftignore)")
+	cmd.Flags().StringArrayVar(&includeOnlyGlobs, "include-only", nil, "index only files matching this glob (repeatable)")
+	cmd.RegisterFlagCompletionFunc("exclude", completeExtGlobs)
+	cmd.RegisterFlagCompletionFunc("include-only", completeExtGlobs)
+}
+
+// openIndexWithPatterns opens the index and applies the --exclude and
+// --include-only globs.
+func openIndexWithPatterns() (*index.Index, error) {
+	idx, err := openIndexWith(ortLib, false, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := idx.SetPatterns(excludeGlobs, includeOnlyGlobs); err != nil {
+		idx.Close()
+		return nil, &usageError{err}
+	}
+	return idx, nil
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// TestCustomIndexDir runs index then search through the CLI with the index
+// stored outside the working directory, given as a relative path.
+func TestCustomIndexDir(t *testing.T) {
+	base := t.TempDir()
+	work := filepath.Join(base, "work")
+	if err := os.MkdirAll(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(work, "notes.md"), []byte("wireguard config lives here"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(work)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("SIFT_INDEX_DIR", "../indexes/work")
+
+	setGlobals(t, "")
+	opened := make(map[string]*index.Index)
+	openIndexFunc = func(o index.Options) (*index.Index, error) {
+		if opened[o.Dir] == nil {
+			opened[o.Dir] = index.NewTestIndex(o.Dir, &mockEmbedder{})
+		}
+		return opened[o.Dir], nil
+	}
+	t.Cleanup(func() { rootCmd.SetArgs(nil) })
+
+	for _, args := range [][]string{{"index", "."}, {"search", "wireguard"}} {
+		reachedRun = false
+		rootCmd.SetArgs(args)
+		if err := Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+	}
+
+	want := filepath.Join(base, "indexes", "work")
+	if indexDir != want {
+		t.Errorf("indexDir = %q, want %q", indexDir, want)
+	}
+	if !index.Exists(want) {
+		t.Errorf("no index written to %s", want)
+	}
+	if _, err := os.Stat(filepath.Join(work, ".sift")); !os.IsNotExist(err) {
+		t.Error("./.sift was created")
+	}
+
+	// A visible index directory inside the indexed root is refused.
+	t.Setenv("SIFT_INDEX_DIR", "index")
+	reachedRun = false
+	rootCmd.SetArgs([]string{"index", "."})
+	if got := exitCode(Execute()); got != exitUsage {
+		t.Errorf("index dir inside root: exit code = %d, want %d", got, exitUsage)
+	}
+}
+
+// TestIndexRunSummary checks index --json --record: the summary on stdout
+// matches the index, and each run appends one line to runs.jsonl.
+func TestIndexRunSummary(t *testing.T) {
+	work := t.TempDir()
+	for name, body := range map[string]string{"a.md": "wireguard config lives here", "b.go": "package b"} {
+		if err := os.WriteFile(filepath.Join(work, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(work)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	setGlobals(t, "")
+	var idx *index.Index
+	openIndexFunc = func(o index.Options) (*index.Index, error) {
+		if idx == nil {
+			idx = index.NewTestIndex(o.Dir, &mockEmbedder{})
+		}
+		return idx, nil
+	}
+	indexCmd := findCmd(t, "index")
+	t.Cleanup(func() {
+		rootCmd.SetArgs(nil)
+		rootCmd.SetOut(nil)
+		runJSON, runRecord = false, false
+		for _, name := range []string{"json", "record"} {
+			indexCmd.Flags().Lookup(name).Changed = false
+		}
+	})
+
+	run := func() runReport {
+		t.Helper()
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		reachedRun = false
+		rootCmd.SetArgs([]string{"index", "--json", "--record", "."})
+		if err := Execute(); err != nil {
+			t.Fatal(err)
+		}
+		var r runReport
+		if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+			t.Fatalf("summary is not JSON: %v\n%s", err, out.String())
+		}
+		return r
+	}
+
+	r := run()
+	if r.Command != "index" || r.Files.Embedded != 2 || r.Files.Skipped != 0 || r.Chunks != idx.Stats().NumChunks {
+		t.Errorf("first run: %+v", r)
+	}
+	if r.Bytes != int64(len("wireguard config lives here")+len("package b")) {
+		t.Errorf("bytes = %d", r.Bytes)
+	}
+	if r = run(); r.Files.Embedded != 0 || r.Files.Skipped != 2 || r.Chunks != 0 {
+		t.Errorf("second run: %+v, want both files skipped", r)
+	}
+
+	// A file moved keeps its embeddings.
+	if err := os.Mkdir(filepath.Join(work, "notes"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(work, "a.md"), filepath.Join(work, "notes", "a.md")); err != nil {
+		t.Fatal(err)
+	}
+	moved := run()
+	if moved.Files.Moved != 1 || moved.Files.Embedded != 0 || moved.Chunks != 0 {
+		t.Errorf("run after a move: %+v, want the file moved, nothing embedded", moved)
+	}
+	var table bytes.Buffer
+	writeRunSummary(&table, moved)
+	if !strings.Contains(table.String(), "1 files moved (reused embeddings)") {
+		t.Errorf("summary does not report the move:\n%s", table.String())
+	}
+
+	data, err := os.ReadFile(filepath.Join(work, ".sift", index.RunsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("%s has %d lines, want one per run:\n%s", index.RunsFile, len(lines), data)
+	}
+	var last runReport
+	if err := json.Unmarshal([]byte(lines[2]), &last); err != nil || last.Files.Moved != 1 || last.Files.Skipped != 1 {
+		t.Errorf("last record = %+v, %v", last, err)
+	}
+}
+
+// TestIndexMixedArgs indexes a directory and a single file in one run,
+// with a missing path among them that fails on its own.
+func TestIndexMixedArgs(t *testing.T) {
+	work := t.TempDir()
+	for name, body := range map[string]string{"docs/guide.md": "wireguard guide", "docs/faq.md": "questions", "TODO.md": "write more docs", "other.md": "not named"} {
+		p := filepath.Join(work, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(work)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	setGlobals(t, "")
+	var idx *index.Index
+	openIndexFunc = func(o index.Options) (*index.Index, error) {
+		if idx == nil {
+			idx = index.NewTestIndex(o.Dir, &mockEmbedder{})
+		}
+		return idx, nil
+	}
+	t.Cleanup(func() { rootCmd.SetArgs(nil) })
+
+	reachedRun = false
+	rootCmd.SetArgs([]string{"index", "docs", "missing.md", "TODO.md"})
+	err := Execute()
+	if err == nil || !strings.Contains(err.Error(), "missing.md") {
+		t.Fatalf("err = %v, want one naming missing.md", err)
+	}
+	if got := exitCode(err); got != exitNoResults {
+		t.Errorf("exit code = %d, want %d", got, exitNoResults)
+	}
+
+	if s := idx.Stats(); s.NumFiles != 3 {
+		t.Errorf("indexed %d files, want the 2 under docs and TODO.md", s.NumFiles)
+	}
+	if c := idx.FileChunks(filepath.Join(work, "other.md")); len(c) != 0 {
+		t.Error("other.md was indexed without being named")
+	}
+	if !index.Exists(filepath.Join(work, ".sift")) {
+		t.Error("the arguments that worked were not saved")
+	}
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 )
 
 func main() {
-	if err := Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+	err := Execute()
+	if err != nil && !errors.Is(err, errNoResults) {
+		if wantErrorsJSON(executedCmd) {
+			writeErrorJSON(os.Stderr, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			var ue *usageError
+			if errors.As(err, &ue) {
+				fmt.Fprintln(os.Stderr, "Run 'sift --help' for usage.")
+			}
+		}
 	}
+	os.Exit(exitCode(err))
 }
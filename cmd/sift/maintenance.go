@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/config"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/maintain"
+)
+
+// maintenanceCheck is how often watch checks whether maintenance is due;
+// see startMaintenance.
+var maintenanceCheck = 5 * time.Minute
+
+// noMaintenance is watch's --no-maintenance flag.
+var noMaintenance bool
+
+// addMaintenanceFlag adds --no-maintenance to cmd.
+func addMaintenanceFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&noMaintenance, "no-maintenance", false, "never compact the index from this process (see maintenance-interval)")
+}
+
+// parseMaintenancePolicy maps the maintenance settings, validated by
+// config.Resolve, to a policy.
+func parseMaintenancePolicy(c *config.Config) maintain.Policy {
+	interval, _ := config.ParseMaintenanceDuration(c.MaintenanceInterval)
+	idle, _ := config.ParseMaintenanceDuration(c.MaintenanceIdle)
+	return maintain.Policy{Interval: interval, StalePct: c.MaintenanceStalePct, Idle: idle}
+}
+
+// startMaintenance compacts idx in the background as maintenancePolicy
+// allows, until ctx is done, unless --no-maintenance is set. The returned
+// function waits for a compaction cut short by ctx to give up: call it
+// before closing idx.
+func startMaintenance(ctx context.Context, idx *index.Index) (wait func()) {
+	if noMaintenance || !maintenancePolicy.Enabled() {
+		return func() {}
+	}
+	s := maintain.New(idx, maintenancePolicy, logger, time.Now())
+	ticker := time.NewTicker(maintenanceCheck)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer ticker.Stop()
+		s.Run(ctx, ticker.C)
+	}()
+	return wg.Wait
+}
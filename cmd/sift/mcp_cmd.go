@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/mcp"
+)
+
+func init() {
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "mcp",
+		Short: "Serve the index to coding agents over MCP (JSON-RPC on stdio)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// stdout carries the protocol; all diagnostics go to stderr.
+			var idx *index.Index
+			defer func() {
+				if idx != nil {
+					idx.Close()
+				}
+			}()
+			srv := mcp.NewServer(version, func() (mcp.Backend, error) {
+				var err error
+				idx, err = openExistingIndex(ortLib)
+				if err != nil {
+					return nil, err
+				}
+				return idx, nil
+			})
+			return srv.Serve(os.Stdin, os.Stdout)
+		},
+	})
+}
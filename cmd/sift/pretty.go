@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/resultview"
+)
+
+// snippetLines is how many lines of each hit the pretty output shows.
+const snippetLines = 4
+
+var plainOutput bool
+
+// writeResults prints results for humans: grouped and highlighted when
+// stdout is a terminal, otherwise (or with --plain) the stable plain format
+// scripts parse. in is the --in directories the search was scoped to.
+func writeResults(w io.Writer, query string, results []index.SearchResult, cwd string, in []string) {
+	if plainOutput || !stdoutIsTTY() {
+		writePlain(w, results, cwd)
+		return
+	}
+	writePretty(w, query, results, cwd, in, colorEnabled())
+}
+
+// staleTag follows a result whose file was modified since it was indexed.
+const staleTag = "(modified since indexed)"
+
+func writePlain(w io.Writer, results []index.SearchResult, cwd string) {
+	for i, r := range results {
+		tag := ""
+		if r.Stale {
+			tag = "  " + staleTag
+		}
+		fmt.Fprintf(w, "%2d  %.3f  %s:%d%s\n    %s\n\n",
+			i+1, r.Score, displayPath(cwd, r.Meta.Path), r.Meta.LineNum, tag, r.Meta.Text)
+	}
+}
+
+// writePretty renders ripgrep-style output: a coloured path header per
+// file with the best score dimmed after it, then line-numbered snippets
+// with query terms highlighted; "--" separates hits within a file. A
+// scoped search starts with a dimmed badge naming the directories in.
+func writePretty(w io.Writer, query string, results []index.SearchResult, cwd string, in []string, color bool) {
+	r := lipgloss.NewRenderer(w)
+	r.SetColorProfile(termenv.Ascii)
+	if color {
+		r.SetColorProfile(termenv.ANSI)
+	}
+	sPath := r.NewStyle().Foreground(lipgloss.Color("5")).Bold(true)
+	sLineNum := r.NewStyle().Foreground(lipgloss.Color("2"))
+	sMatch := r.NewStyle().Foreground(lipgloss.Color("1")).Bold(true)
+	sDim := r.NewStyle().Faint(true)
+
+	mark := func(s string) string { return sMatch.Render(s) }
+	terms := resultview.Terms(query)
+	if len(in) > 0 {
+		fmt.Fprintf(w, "%s\n\n", sDim.Render(scopeBadge(cwd, in)))
+	}
+	for gi, g := range resultview.Group(results) {
+		if gi > 0 {
+			fmt.Fprintln(w)
+		}
+		header := fmt.Sprintf("%.3f", g.Best)
+		if g.Hits[0].Stale {
+			header += "  " + staleTag
+		}
+		fmt.Fprintf(w, "%s  %s\n", sPath.Render(displayPath(cwd, g.Path)), sDim.Render(header))
+		for hi, h := range g.Hits {
+			if hi > 0 {
+				fmt.Fprintln(w, sDim.Render("--"))
+			}
+			first, lines := snippet(h.Meta, terms)
+			for i, line := range lines {
+				fmt.Fprintf(w, "%s:%s\n", sLineNum.Render(strconv.Itoa(first+i)),
+					resultview.Highlight(line, terms, mark))
+			}
+		}
+	}
+}
+
+// snippet picks up to snippetLines lines of the chunk, starting at the
+// first line that mentions a query term, and returns the first line's number.
+func snippet(m index.ChunkMeta, terms []string) (int, []string) {
+	lines := strings.Split(m.Text, "\n")
+	start := 0
+	for i, l := range lines {
+		if len(resultview.Find(l, terms)) > 0 {
+			start = i
+			break
+		}
+	}
+	end := min(start+snippetLines, len(lines))
+	return m.LineNum + start, lines[start:end]
+}
+
+// scopeBadge describes the directories a search was scoped to, as
+// "[in docs/, src/]".
+func scopeBadge(cwd string, in []string) string {
+	dirs := make([]string, len(in))
+	for i, d := range in {
+		if !filepath.IsAbs(d) {
+			d = filepath.Join(cwd, d)
+		}
+		dirs[i] = strings.TrimSuffix(displayPath(cwd, d), "/") + "/"
+	}
+	return "[in " + strings.Join(dirs, ", ") + "]"
+}
+
+// displayPath shows path relative to cwd when it lies below it, or below
+// the project root cwd is in (as ../../README.md). It always uses forward
+// slashes, so it is for display only, never for opening files. With --abs
+// it shows path whole.
+func displayPath(cwd, path string) string {
+	if absPaths {
+		return filepath.ToSlash(path)
+	}
+	if rel, err := filepath.Rel(cwd, path); err == nil && (filepath.IsLocal(rel) || inProject(cwd, path)) {
+		path = rel
+	}
+	return filepath.ToSlash(path)
+}
+
+// inProject reports whether cwd and path both lie within projectRoot.
+func inProject(cwd, path string) bool {
+	if projectRoot == "" {
+		return false
+	}
+	for _, p := range []string{cwd, path} {
+		if rel, err := filepath.Rel(projectRoot, p); err != nil || !(rel == "." || filepath.IsLocal(rel)) {
+			return false
+		}
+	}
+	return true
+}
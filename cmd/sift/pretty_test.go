@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func prettyResults() []index.SearchResult {
+	mtime := time.Date(2025, 3, 14, 9, 26, 53, 0, time.UTC)
+	return []index.SearchResult{
+		{Score: 0.873, Meta: index.ChunkMeta{Path: "/work/docs/vpn.md", LineNum: 10, Mtime: mtime,
+			Text: "# VPN\n\n## WireGuard\nThe config lives in /etc/wireguard/wg0.conf.\nRestart with wg-quick.\nSee also: firewall."}},
+		{Score: 0.612, Meta: index.ChunkMeta{Path: "/elsewhere/notes.txt", LineNum: 1, Mtime: mtime,
+			Text: "todo: rotate keys"}},
+		{Score: 0.598, Meta: index.ChunkMeta{Path: "/work/docs/vpn.md", LineNum: 40, ChunkIndex: 3, Mtime: mtime,
+			Text: "Peers are listed under [Peer] in the wireguard CONFIG."}},
+	}
+}
+
+func TestWriteResultsGolden(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	oldTTY, oldPlain, oldNoColor := stdoutIsTTY, plainOutput, noColor
+	t.Cleanup(func() { stdoutIsTTY, plainOutput, noColor = oldTTY, oldPlain, oldNoColor })
+	noColor = false
+
+	for _, tc := range []struct {
+		golden string
+		tty    bool
+		plain  bool
+		in     []string
+	}{
+		{"pretty_tty.golden", true, false, nil},
+		{"plain.golden", false, false, nil},
+		{"plain.golden", true, true, nil}, // --plain on a terminal
+		{"pretty_tty_in.golden", true, false, []string{"docs", "/elsewhere"}},
+		{"plain.golden", false, false, []string{"docs"}}, // no badge for scripts
+	} {
+		stdoutIsTTY = func() bool { return tc.tty }
+		plainOutput = tc.plain
+
+		var buf bytes.Buffer
+		writeResults(&buf, "wireguard config", prettyResults(), "/work", tc.in)
+		golden := filepath.Join("testdata", "search", tc.golden)
+		if *update {
+			if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("tty=%v plain=%v: output drifted from %s (run with -update if intended):\n%s", tc.tty, tc.plain, golden, buf.Bytes())
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import "github.com/tejas242/sift/internal/throttle"
+
+var (
+	// pace paces indexing by the low-priority setting; see applyPriority.
+	// Indexes and watchers share it, so a reload changes them all.
+	pace = throttle.New(throttle.Derive(false, 0, 0))
+	// reniced is the nice level applyPriority last set the process to.
+	reniced int
+)
+
+// priorityLimits derives the resource limits of the low-priority,
+// threads and background-sessions settings.
+func priorityLimits() throttle.Limits {
+	return throttle.Derive(lowPriority, numThreads, backgroundSessions)
+}
+
+// applyPriority paces indexing by l and moves the process to l's nice
+// level, warning where it cannot: returning to 0 from low-priority mode
+// usually needs privileges, so the process stays niced until restarted.
+func applyPriority(l throttle.Limits) {
+	pace.Set(l)
+	if l.Nice == reniced {
+		return
+	}
+	if err := throttle.Renice(l.Nice); err != nil {
+		logger.Warnf("priority: %v; the nice level stays at %d until restart", err, reniced)
+		return
+	}
+	reniced = l.Nice
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/protocol"
+)
+
+// Values accepted by --progress.
+const (
+	progressHuman = "human"
+	progressJSON  = "json"
+)
+
+var progressMode string
+
+// addProgressFlag registers --progress on cmd.
+func addProgressFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&progressMode, "progress", progressHuman, `progress output: "human" (stderr) or "json" (NDJSON events on stdout)`)
+}
+
+// progressSink receives the events of an indexing run, one root at a time.
+// Chunk reports progress within a file of several embedding batches,
+// before its File event. Finish and Error are told which of the root's
+// files failed to embed.
+type progressSink interface {
+	Start(root string)
+	Chunk(path string, done, total int)
+	File(done, total int, path string, skipped bool)
+	Finish(root string, chunks int, failed rootFailures, elapsed time.Duration)
+	Error(root string, err error, failed rootFailures, elapsed time.Duration)
+}
+
+// rootFailures are the files of one root that could not be embedded: how
+// many, and the first few (index.RunStats keeps a sample per run).
+type rootFailures struct {
+	Count  int
+	Sample []index.EmbedFailure
+}
+
+// newRootFailures returns the failures a root added to a run, given the
+// run's stats from before and after it.
+func newRootFailures(before, after index.RunStats) rootFailures {
+	return rootFailures{
+		Count:  after.EmbedFailed - before.EmbedFailed,
+		Sample: after.EmbedFailures[len(before.EmbedFailures):],
+	}
+}
+
+// newProgressSink returns the sink selected by --progress; JSON events go
+// to stdout.
+func newProgressSink(stdout io.Writer, verb string) (progressSink, error) {
+	switch progressMode {
+	case progressHuman, "":
+		if quiet {
+			return nopProgress{}, nil
+		}
+		return &humanProgress{w: os.Stderr, verb: verb}, nil
+	case progressJSON:
+		return &jsonProgress{enc: protocol.NewEncoder(stdout)}, nil
+	default:
+		return nil, &usageError{fmt.Errorf("--progress: want %q or %q, got %q", progressHuman, progressJSON, progressMode)}
+	}
+}
+
+type nopProgress struct{}
+
+func (nopProgress) Start(string)                                     {}
+func (nopProgress) Chunk(string, int, int)                           {}
+func (nopProgress) File(int, int, string, bool)                      {}
+func (nopProgress) Finish(string, int, rootFailures, time.Duration)  {}
+func (nopProgress) Error(string, error, rootFailures, time.Duration) {}
+
+// humanProgress rewrites a single status line on a terminal.
+type humanProgress struct {
+	w    io.Writer
+	verb string // e.g. "Scanning"
+	// done and total are the root's file counts as of the last File
+	// event; chunked is set while the line shows a file's chunks.
+	done, total int
+	chunked     bool
+}
+
+func (p *humanProgress) Start(root string) {
+	p.done, p.total = 0, 0
+	fmt.Fprintf(p.w, "%s %s…\n", p.verb, root)
+}
+
+// Chunk adds the file's chunk count to the line while a large file is
+// embedded: "  [12/240]  ⋯   docs/big.md  chunks 64/300".
+func (p *humanProgress) Chunk(path string, done, total int) {
+	files := "…"
+	if p.total > 0 {
+		files = fmt.Sprintf("%d/%d", p.done+1, p.total)
+	}
+	fmt.Fprintf(p.w, "\r  [%s]  ⋯   %-50s  chunks %d/%d ", files, shortPath(path), done, total)
+	p.chunked = true
+}
+
+func (p *humanProgress) File(done, total int, path string, skipped bool) {
+	p.done, p.total = done, total
+	if p.chunked {
+		fmt.Fprintf(p.w, "\r%90s", "") // clear the chunk counts
+		p.chunked = false
+	}
+	short := shortPath(path)
+	if skipped {
+		fmt.Fprintf(p.w, "\r  [%d/%d]  ·   %-50s", done, total, short)
+	} else {
+		pct := 100 * done / total
+		if done < total {
+			fmt.Fprintf(p.w, "\r  [%d/%d] %3d%%  %-50s",
+				done, total, pct, short)
+		} else {
+			fmt.Fprintf(p.w, "\r  [%d/%d] 100%%  %-50s\n",
+				done, total, short)
+		}
+	}
+}
+
+// shortPath is path's file and directory names, as progress lines show it.
+func shortPath(path string) string {
+	return filepath.Base(filepath.Dir(path)) + "/" + filepath.Base(path)
+}
+
+// Finish and Error print nothing: the index logs each sampled embedding
+// failure as it happens, and the run summary lists them.
+func (p *humanProgress) Finish(string, int, rootFailures, time.Duration)  {}
+func (p *humanProgress) Error(string, error, rootFailures, time.Duration) {}
+
+// jsonProgress writes one protocol message per event, of type
+// "index.start", "index.chunk", "index.file", "index.finish" or
+// "index.error".
+type jsonProgress struct {
+	enc *protocol.Encoder
+}
+
+func (p *jsonProgress) Start(root string) {
+	p.enc.Encode(protocol.TypeIndexStart, protocol.IndexStart{Root: root})
+}
+
+func (p *jsonProgress) Chunk(path string, done, total int) {
+	p.enc.Encode(protocol.TypeIndexChunk, protocol.IndexChunk{Path: path, Done: done, Total: total})
+}
+
+func (p *jsonProgress) File(done, total int, path string, skipped bool) {
+	p.enc.Encode(protocol.TypeIndexFile, protocol.IndexFile{Done: done, Total: total, Path: path, Skipped: skipped})
+}
+
+func (p *jsonProgress) Finish(root string, chunks int, failed rootFailures, elapsed time.Duration) {
+	p.enc.Encode(protocol.TypeIndexFinish, protocol.IndexFinish{Root: root, Chunks: chunks, DurationMS: elapsed.Milliseconds(),
+		EmbedFailed: failed.Count, EmbedFailures: newEmbedFailures(failed.Sample)})
+}
+
+func (p *jsonProgress) Error(root string, err error, failed rootFailures, elapsed time.Duration) {
+	p.enc.Encode(protocol.TypeIndexError, protocol.IndexError{Root: root, Error: err.Error(), DurationMS: elapsed.Milliseconds(),
+		EmbedFailed: failed.Count, EmbedFailures: newEmbedFailures(failed.Sample)})
+}
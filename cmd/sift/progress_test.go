@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/logging"
+	"github.com/tejas242/sift/internal/protocol"
+)
+
+// runIndexJSON runs `sift index --progress=json dirs...` and decodes the
+// NDJSON it writes to stdout, flattening each message's payload into a map
+// with its "type".
+func runIndexJSON(t *testing.T, dirs ...string) ([]map[string]any, error) {
+	t.Helper()
+	old := progressMode
+	t.Cleanup(func() { progressMode = old })
+	progressMode = progressJSON
+
+	cmd := findCmd(t, "index")
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	defer cmd.SetOut(nil)
+	err := cmd.RunE(cmd, dirs)
+
+	var events []map[string]any
+	sc := bufio.NewScanner(&out)
+	for sc.Scan() {
+		var ev map[string]any
+		env, perr := protocol.Unmarshal(sc.Bytes())
+		switch {
+		case errors.Is(perr, protocol.ErrVersion):
+			// The --run-json summary, a plain object.
+			if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+				t.Fatalf("invalid NDJSON line %q: %v", sc.Text(), err)
+			}
+		case perr != nil:
+			t.Fatalf("invalid NDJSON line %q: %v", sc.Text(), perr)
+		default:
+			if err := env.Decode(&ev); err != nil {
+				t.Fatal(err)
+			}
+			if ev == nil {
+				ev = map[string]any{}
+			}
+			ev["type"] = env.Type
+		}
+		events = append(events, ev)
+	}
+	return events, err
+}
+
+func TestProgressJSON(t *testing.T) {
+	useTestIndex(t, false)
+	root := t.TempDir()
+	for _, name := range []string{"a.md", "b.go", "logo.png"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("content of "+name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := runIndexJSON(t, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want start, 2 files, finish: %v", len(events), events)
+	}
+	if events[0]["type"] != "index.start" || events[0]["root"] != root {
+		t.Errorf("first event = %v, want start of %s", events[0], root)
+	}
+	for i, ev := range events[1:3] {
+		if ev["type"] != "index.file" || ev["done"] != float64(i+1) || ev["total"] != 2.0 || ev["skipped"] != false {
+			t.Errorf("file event %d = %v", i, ev)
+		}
+		if _, ok := ev["path"].(string); !ok {
+			t.Errorf("file event %d has no path", i)
+		}
+	}
+	last := events[3]
+	if last["type"] != "index.finish" || last["chunks"] != 2.0 {
+		t.Errorf("last event = %v, want finish with 2 chunks", last)
+	}
+	if _, ok := last["duration_ms"].(float64); !ok {
+		t.Errorf("finish event has no duration_ms: %v", last)
+	}
+
+	// A second run hits the skip cache.
+	events, err = runIndexJSON(t, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range events {
+		if ev["type"] == "index.file" && ev["skipped"] != true {
+			t.Errorf("re-run: %v not skipped", ev)
+		}
+	}
+
+	// Failures end the stream with an error event.
+	missing := filepath.Join(root, "missing")
+	events, err = runIndexJSON(t, missing)
+	if err == nil {
+		t.Fatal("expected an error for a missing root")
+	}
+	if n := len(events); n != 2 || events[1]["type"] != "index.error" || events[1]["root"] != missing || events[1]["error"] == "" {
+		t.Errorf("events = %v, want start then error", events)
+	}
+}
+
+func TestProgressJSON_Chunks(t *testing.T) {
+	useTestIndex(t, false)
+	root := t.TempDir()
+	var text strings.Builder
+	for i := range 1200 {
+		fmt.Fprintf(&text, "line %d of a file long enough to take several embedding batches\n", i)
+	}
+	big := filepath.Join(root, "big.md")
+	if err := os.WriteFile(big, []byte(text.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := runIndexJSON(t, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, ev := range events {
+		types = append(types, ev["type"].(string))
+	}
+	if len(events) < 4 || events[1]["type"] != "index.chunk" {
+		t.Fatalf("events = %q, want chunk events before the file's", types)
+	}
+	// start, chunk..., file, finish; chunk counts rise by batch.
+	file := events[len(events)-2]
+	if file["type"] != "index.file" || events[len(events)-1]["type"] != "index.finish" {
+		t.Fatalf("events = %q, want the file and finish events last", types)
+	}
+	for i, ev := range events[1 : len(events)-2] {
+		if ev["type"] != "index.chunk" || ev["path"] != big || ev["done"] != float64(32*(i+1)) || ev["total"].(float64) <= ev["done"].(float64) {
+			t.Errorf("chunk event %d = %v", i, ev)
+		}
+	}
+}
+
+// brokenEmbedder fails to embed any batch containing "bad".
+type brokenEmbedder struct{ mockEmbedder }
+
+func (e *brokenEmbedder) Embed(texts []string) ([][]float32, error) {
+	for _, t := range texts {
+		if strings.Contains(t, "bad") {
+			return nil, errors.New("onnxruntime: session closed")
+		}
+	}
+	return e.mockEmbedder.Embed(texts)
+}
+
+func TestProgressJSON_EmbedFailures(t *testing.T) {
+	dir := t.TempDir()
+	idx := index.NewTestIndex(filepath.Join(dir, ".sift"), &brokenEmbedder{})
+	idx.SetLogger(logging.Discard())
+	setGlobals(t, filepath.Join(dir, ".sift"))
+	openIndexFunc = func(index.Options) (*index.Index, error) { return idx, nil }
+	oldLimit, oldJSON := embedLimit, runJSON
+	t.Cleanup(func() { embedLimit, runJSON = oldLimit, oldJSON })
+	runJSON = true
+
+	root := t.TempDir()
+	write := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.md", "good notes")
+	write("b.md", "bad notes")
+	write("c.md", "more good notes")
+
+	// Under the limit: the run finishes and reports the failure.
+	embedLimit = index.EmbedFailureLimit{Consecutive: 2}
+	events, err := runIndexJSON(t, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finish, report := events[len(events)-2], events[len(events)-1]
+	failures, _ := finish["embed_failures"].([]any)
+	if finish["type"] != "index.finish" || finish["embed_failed"] != 1.0 || len(failures) != 1 {
+		t.Fatalf("finish event = %v, want one embed failure", finish)
+	}
+	if f := failures[0].(map[string]any); f["path"] != filepath.Join(root, "b.md") || !strings.Contains(f["error"].(string), "session closed") {
+		t.Errorf("failure = %v", f)
+	}
+	if files := report["files"].(map[string]any); files["embed_failed"] != 1.0 || files["embedded"] != 2.0 || report["error"] != nil {
+		t.Errorf("run summary = %v, want 2 embedded and 1 embed failure", report)
+	}
+
+	// b.md fails again and, with the unchanged c.md skipped, so does d.md:
+	// two in a row give up.
+	write("d.md", "bad again")
+	write("e.md", "never reached")
+	events, err = runIndexJSON(t, root)
+	if !errors.Is(err, index.ErrEmbedder) || exitCode(err) != exitModel {
+		t.Fatalf("err = %v (exit %d), want an embedder failure", err, exitCode(err))
+	}
+	if code, _ := errorCode(err); code != codeModelLoad {
+		t.Errorf("error code = %s, want %s", code, codeModelLoad)
+	}
+	last, report := events[len(events)-2], events[len(events)-1]
+	if last["type"] != "index.error" || last["embed_failed"] != 2.0 || !strings.Contains(last["error"].(string), "giving up") {
+		t.Errorf("last event = %v, want an error with 2 embed failures", last)
+	}
+	if msg, _ := report["error"].(string); !strings.Contains(msg, "d.md") || report["embed_failures"] == nil {
+		t.Errorf("run summary = %v, want the failures and the error", report)
+	}
+	for _, ev := range events {
+		if ev["path"] == filepath.Join(root, "e.md") {
+			t.Errorf("indexing went on after giving up: %v", ev)
+		}
+	}
+}
+
+func TestWriteEmbedFailures(t *testing.T) {
+	var b bytes.Buffer
+	writeEmbedFailures(&b, 0, nil)
+	if b.Len() != 0 {
+		t.Errorf("no failures printed %q", b.String())
+	}
+	writeEmbedFailures(&b, 12, []embedFailure{{Path: "a.md", Error: "boom"}, {Path: "b.md", Error: "boom"}})
+	want := "embedding failed for 12 files:\n  a.md: boom\n  b.md: boom\n  … and 10 more\n"
+	if b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
+
+func TestWriteTruncated(t *testing.T) {
+	var b bytes.Buffer
+	writeTruncated(&b, 0, 146, 0)
+	if b.Len() != 0 {
+		t.Errorf("nothing truncated printed %q", b.String())
+	}
+	writeTruncated(&b, 12, 146, 5)
+	want := "warning: 8.2% of chunks (12 of 146, in 5 files) were longer than the model's 256-token input and truncated; search cannot find their ends by meaning\n"
+	if b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
+
+func TestWriteLanguages(t *testing.T) {
+	for _, tc := range []struct {
+		langs  map[string]int
+		chunks int
+		want   string
+	}{
+		{nil, 0, ""},
+		{map[string]int{"en": 146}, 146, ""},
+		// Under the threshold, non-English chunks pass without a warning.
+		{map[string]int{"en": 140, "de": 6}, 146, ""},
+		{map[string]int{"en": 77, "de": 15, "ja": 8}, 100,
+			"warning: 23% of chunks (23 of 100) were detected as non-English (de 15%, ja 8%); the default model is English-only, consider a multilingual one\n"},
+		{map[string]int{"fr": 10, "ja": 10}, 20,
+			"warning: 100% of chunks (20 of 20) were detected as non-English (fr 50%, ja 50%); the default model is English-only, consider a multilingual one\n"},
+	} {
+		var b bytes.Buffer
+		writeLanguages(&b, tc.langs, tc.chunks)
+		if b.String() != tc.want {
+			t.Errorf("writeLanguages(%v, %d) = %q, want %q", tc.langs, tc.chunks, b.String(), tc.want)
+		}
+	}
+}
+
+func TestWriteStatus(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start.Add(10 * time.Minute)
+	for _, tc := range []struct {
+		s    index.RunStatus
+		want string
+	}{
+		{index.RunStatus{}, "status: starting, 0s elapsed, 0 chunks\n"},
+		{index.RunStatus{Root: "docs", RootStarted: start, Run: index.RunStats{Started: start, Elapsed: 10 * time.Minute}},
+			"status: walking docs, 10m0s elapsed, 0 chunks\n"},
+		{index.RunStatus{Root: "docs", Done: 200, Total: 1000, Current: "/src/docs/api/auth.md", RootStarted: start,
+			Run: index.RunStats{Started: start.Add(-time.Minute), Elapsed: 11 * time.Minute, Chunks: 4821}},
+			"status: docs 200/1000 files, 11m0s elapsed, ETA 40m0s, 4821 chunks, now api/auth.md\n"},
+	} {
+		var b bytes.Buffer
+		writeStatus(&b, tc.s, now)
+		if b.String() != tc.want {
+			t.Errorf("got %q, want %q", b.String(), tc.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+)
+
+func init() {
+	pruneCmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Drop deleted files, and files over max-file-kb, from the index",
+		Long: `Remove the chunks of indexed files that no longer exist on disk, and of
+files larger than max-file-kb (after lowering it), and save the index. No
+model is loaded.
+
+Index runs skip files over the limit but leave the chunks of ones indexed
+under a higher limit; prune drops those. Skipped files are remembered, so
+raising the limit again indexes them on the next run.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			idx, err := index.OpenWithoutModel(indexDir)
+			if errors.Is(err, index.ErrNoIndex) {
+				return noIndexError()
+			}
+			if err != nil {
+				return err
+			}
+			idx.SetMaxFileKB(maxFileKB)
+			idx.SetOversizedPolicy(oversizedPolicy)
+			before := idx.Stats().NumChunks
+			deleted := idx.PruneDeleted()
+			oversized := idx.PruneOversized()
+			chunks := before - idx.Stats().NumChunks
+			if err := idx.Close(); err != nil {
+				return fmt.Errorf("prune: %w", err)
+			}
+			writePruneReport(cmd.OutOrStdout(), deleted, oversized, chunks)
+			return nil
+		},
+	}
+	rootCmd.AddCommand(pruneCmd)
+}
+
+// writePruneReport prints a line per pruned file and a total.
+func writePruneReport(w io.Writer, deleted, oversized []string, chunks int) {
+	if len(deleted)+len(oversized) == 0 {
+		fmt.Fprintln(w, "Nothing to prune.")
+		return
+	}
+	cwd, _ := os.Getwd()
+	for _, p := range deleted {
+		fmt.Fprintf(w, "  %s  deleted\n", displayPath(cwd, p))
+	}
+	for _, p := range oversized {
+		fmt.Fprintf(w, "  %s  over %d KB\n", displayPath(cwd, p), maxFileKB)
+	}
+	fmt.Fprintf(w, "Pruned %d files (%d chunks).\n", len(deleted)+len(oversized), chunks)
+}
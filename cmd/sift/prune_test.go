@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	paths := map[string]string{
+		"gone.md":  "deleted after indexing",
+		"big.md":   strings.Repeat("wireguard tunnel ", 200),
+		"small.md": "backup schedule",
+	}
+	for name, body := range paths {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "gone.md")); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	oldMax := maxFileKB
+	t.Cleanup(func() { maxFileKB = oldMax })
+	maxFileKB = 1
+
+	prune := findCmd(t, "prune")
+	var out bytes.Buffer
+	prune.SetOut(&out)
+	t.Cleanup(func() { prune.SetOut(nil) })
+	if err := prune.RunE(prune, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"gone.md  deleted", "big.md  over 1 KB", "Pruned 2 files ("} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+
+	idx, err := index.OpenReadOnly(siftDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	idx.SetMaxFileKB(maxFileKB)
+	if s := idx.Stats(); s.NumFiles != 1 || s.Oversized != 1 {
+		t.Errorf("after prune: %d files, %d oversized; want small.md left and big.md recorded", s.NumFiles, s.Oversized)
+	}
+
+	out.Reset()
+	if err := prune.RunE(prune, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Nothing to prune.\n" {
+		t.Errorf("second prune: %q", out.String())
+	}
+}
+
+func TestPruneAfterSkipsReadOnlyOpens(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	p := filepath.Join(dir, "gone.md")
+	if err := os.WriteFile(p, []byte("deleted after indexing"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.AddFile(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(p); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	openIndexFunc = func(index.Options) (*index.Index, error) { return idx, nil }
+	oldAfter := pruneAfter
+	t.Cleanup(func() { pruneAfter = oldAfter })
+	pruneAfter = time.Nanosecond
+
+	// A read-only open must not change the index, however stale it is.
+	if _, err := openIndexLazy("", false, false, true); err != nil {
+		t.Fatal(err)
+	}
+	if n := idx.Stats().NumFiles; n != 1 {
+		t.Errorf("read-only open left %d files, want gone.md kept", n)
+	}
+	if _, err := openIndexLazy("", false, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if n := idx.Stats().NumFiles; n != 0 {
+		t.Errorf("writable open left %d files, want gone.md pruned", n)
+	}
+}
@@ -2,44 +2,58 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
-	"syscall"
 
 	"github.com/spf13/cobra"
 )
 
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "rebuild <dir> [dir...]",
-		Short: "Wipe and rebuild the index from scratch (ignores skip-cache)",
-		Args:  cobra.MinimumNArgs(1),
+	rebuildCmd := &cobra.Command{
+		Use:               "rebuild <path> [path...]",
+		Short:             "Wipe and rebuild the index from scratch (ignores skip-cache)",
+		Args:              dirArgs,
+		ValidArgsFunction: completeRoots,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			if dryRun {
+				return runDryRun(cmd.OutOrStdout(), args, true)
+			}
+			sink, err := newProgressSink(cmd.OutOrStdout(), "Rebuilding index for")
+			if err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
 			defer stop()
 
-			idx, err := openIndex(ortLib)
+			idx, err := openIndexWithPatterns()
 			if err != nil {
 				return err
 			}
 			defer idx.Close()
+			if args, err = indexRoots(idx, args); err != nil {
+				return err
+			}
 
-			for _, dir := range args {
-				fmt.Fprintf(os.Stderr, "Rebuilding index for %s…\n", dir)
-				if err := idx.RebuildFromDir(ctx, dir); err != nil {
-					if !isInterrupted(err) {
-						return err
-					}
-					fmt.Fprintln(os.Stderr, "\nInterrupted — saving partial index…")
-				}
+			badArgs := indexDirs(ctx, idx, args, true, sink)
+			if badArgs != nil && !errors.As(badArgs, new(argErrors)) {
+				return abortRun(cmd.OutOrStdout(), "rebuild", idx, badArgs)
 			}
 			if err := idx.Flush(); err != nil {
 				return err
 			}
 			s := idx.Stats()
 			fmt.Fprintf(os.Stderr, "Done. %d chunks from %d files.\n", s.NumChunks, s.NumFiles)
-			return nil
+			if err := finishRun(cmd.OutOrStdout(), "rebuild", idx); err != nil {
+				return err
+			}
+			return badArgs
 		},
-	})
+	}
+	addPatternFlags(rebuildCmd)
+	addProgressFlag(rebuildCmd)
+	addRunFlags(rebuildCmd)
+	rebuildCmd.Flags().BoolVar(&dryRun, "dry-run", false, "list what would be indexed without loading the model or writing anything")
+	rootCmd.AddCommand(rebuildCmd)
 }
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/ignore"
+	"github.com/tejas242/sift/internal/index"
+)
+
+func init() {
+	reindexCmd := &cobra.Command{
+		Use:   "reindex <path|glob> [path|glob...]",
+		Short: "Force specific files to be re-chunked and re-embedded",
+		Long: `Re-index the given files even if their mtime has not changed: drop their
+chunks, chunk and embed them again, and save the index. Cheaper than
+rebuild when the watcher missed a change or two.
+
+A quoted glob ("docs/**/*.md") is matched against the paths already in the
+index rather than the filesystem. Files that cannot be indexed are reported
+and the rest are still processed.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+			defer stop()
+
+			if !index.Exists(indexDir) {
+				return noIndexError()
+			}
+			idx, err := openIndexWith(ortLib, false, false)
+			if err != nil {
+				return err
+			}
+			defer idx.Close()
+
+			failed := reindexPaths(ctx, cmd.OutOrStdout(), idx, args)
+			if err := idx.Flush(); err != nil {
+				return err
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of the given paths could not be re-indexed", failed)
+			}
+			return nil
+		},
+	}
+	rootCmd.AddCommand(reindexCmd)
+}
+
+// reindexFiles re-indexes paths, as `sift reindex` does, and saves the
+// index; its report goes to stderr unless --quiet.
+func reindexFiles(ctx context.Context, paths []string) error {
+	idx, err := openIndexWith(ortLib, false, false)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	var w io.Writer = os.Stderr
+	if quiet {
+		w = io.Discard
+	}
+	failed := reindexPaths(ctx, w, idx, paths)
+	if err := idx.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of the stale files could not be re-indexed", failed)
+	}
+	return nil
+}
+
+// reindexPaths re-indexes every file named by args, printing one line per
+// file to w and logging failures, and returns how many paths failed. It
+// stops early only if ctx is cancelled.
+func reindexPaths(ctx context.Context, w io.Writer, idx *index.Index, args []string) (failed int) {
+	cwd, _ := os.Getwd()
+	start := time.Now()
+	var files, chunks int
+	for _, p := range expandReindexArgs(idx.Files(), args, func(arg string) {
+		logger.Errorf("%s: no indexed file matches", arg)
+		failed++
+	}) {
+		if ctx.Err() != nil {
+			break
+		}
+		t := time.Now()
+		n, err := idx.ReindexFile(ctx, p)
+		if err != nil {
+			logger.Errorf("%v", err)
+			failed++
+			continue
+		}
+		files++
+		chunks += n
+		fmt.Fprintf(w, "  %s  %d chunks  %s\n", displayPath(cwd, p), n, time.Since(t).Round(time.Millisecond))
+	}
+	fmt.Fprintf(w, "Re-indexed %d files (%d chunks) in %s.\n", files, chunks, time.Since(start).Round(time.Millisecond))
+	return failed
+}
+
+// expandReindexArgs turns args into absolute paths. Globs are matched
+// against the indexed paths (with ignore.Match, so "**" works) and
+// noMatch is called for each that matches nothing; plain paths pass
+// through, so missing files surface as errors from ReindexFile.
+func expandReindexArgs(indexed, args []string, noMatch func(arg string)) []string {
+	var out []string
+	seen := make(map[string]bool)
+	add := func(p string) {
+		if !seen[p] {
+			seen[p] = true
+			out = append(out, p)
+		}
+	}
+	for _, arg := range args {
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			abs = arg
+		}
+		if !strings.ContainsAny(arg, "*?[") {
+			add(abs)
+			continue
+		}
+		glob := filepath.ToSlash(abs)
+		matched := false
+		for _, p := range indexed {
+			if ignore.Match(glob, filepath.ToSlash(p)) {
+				add(p)
+				matched = true
+			}
+		}
+		if !matched {
+			noMatch(arg)
+		}
+	}
+	return out
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestExpandReindexArgs(t *testing.T) {
+	project := t.TempDir()
+	t.Chdir(project)
+	indexed := []string{
+		filepath.Join(project, "docs", "a.md"),
+		filepath.Join(project, "docs", "deep", "b.md"),
+		filepath.Join(project, "src", "main.go"),
+	}
+	var missed []string
+	got := expandReindexArgs(indexed, []string{"docs/**/*.md", "src/main.go", "docs/a.md", "*.txt", "new.md"},
+		func(arg string) { missed = append(missed, arg) })
+	want := []string{indexed[0], indexed[1], indexed[2], filepath.Join(project, "new.md")}
+	if !slices.Equal(got, want) {
+		t.Errorf("expandReindexArgs = %q, want %q", got, want)
+	}
+	if !slices.Equal(missed, []string{"*.txt"}) {
+		t.Errorf("unmatched globs = %q, want [*.txt]", missed)
+	}
+}
+
+func TestReindexPaths(t *testing.T) {
+	project := t.TempDir()
+	t.Chdir(project)
+	idx := index.NewTestIndex(filepath.Join(project, ".sift"), &mockEmbedder{})
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(project, name), []byte("wireguard config"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(filepath.Join(project, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	failed := reindexPaths(context.Background(), &out, idx, []string{"missing.md", "*.md"})
+	if failed != 1 {
+		t.Errorf("failed = %d, want 1 (missing.md)", failed)
+	}
+	for _, want := range []string{"a.md  1 chunks", "b.md  1 chunks", "Re-indexed 2 files (2 chunks)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
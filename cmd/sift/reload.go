@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/config"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/server"
+	"github.com/tejas242/sift/internal/watcher"
+)
+
+// reloadMu serialises reloads, which update the global settings.
+var reloadMu sync.Mutex
+
+// reloadConfig re-resolves the configuration the way cmd started (same
+// flags, fresh reads of the config files) and applies what can change in
+// a running process: live settings go to idx, and w, if non-nil, re-reads
+// its ignore rules and, after a change of max-file-kb or oversized, indexes
+// the files skipped as too large that are now admitted. Everything else is reported as
+// needing a restart.
+func reloadConfig(cmd *cobra.Command, idx *index.Index, w *watcher.Watcher) (*server.ReloadReply, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	next, err := config.Resolve(os.Getenv, setFlags(cmd))
+	if err != nil {
+		return nil, fmt.Errorf("reload: configuration: %w", err)
+	}
+	reply := &server.ReloadReply{Applied: []string{}, Restart: []string{}}
+	applied, restart := cfg.Reload(next)
+	sizeLimit := false
+	for _, ch := range applied {
+		switch ch.Key {
+		case "max-file-kb":
+			maxFileKB = cfg.MaxFileKB
+			idx.SetMaxFileKB(maxFileKB)
+			sizeLimit = true
+		case "oversized":
+			oversizedPolicy = parseOversized(cfg.Oversized)
+			idx.SetOversizedPolicy(oversizedPolicy)
+			sizeLimit = true
+		case "preview":
+			storedPreview, _ = config.ParsePreview(cfg.Preview)
+			idx.SetPreview(storedPreview)
+		case "redact-pattern":
+			redactPattern, _ = config.ParseRedactPattern(cfg.RedactPattern)
+			idx.SetRedactPattern(redactPattern)
+		case "categories":
+			categoryRules = parseCategories(cfg.Categories)
+			idx.SetCategoryRules(categoryRules)
+		case "compress-meta":
+			metaCompression = parseMetaCompression(cfg.CompressMeta)
+			idx.SetMetaCompression(metaCompression)
+		case "calibrate":
+			calibrateScores = cfg.Calibrate
+			idx.SetCalibrate(calibrateScores)
+		case "max-per-dir":
+			maxPerDir = cfg.MaxPerDir
+			idx.SetMaxPerDir(maxPerDir)
+		case "result-cache", "result-cache-ttl":
+			resultCache = parseResultCache(cfg)
+			idx.SetResultCache(resultCache)
+		case "stopwords", "min-boost-words":
+			keywordPolicy = parseKeywordPolicy(cfg)
+			idx.SetKeywordPolicy(keywordPolicy)
+		case "expand-queries", "synonyms":
+			queryExpansion = parseExpansion(cfg)
+			idx.SetExpansion(queryExpansion)
+		case "low-priority":
+			lowPriority = cfg.LowPriority
+			if w == nil {
+				break // only a watch indexes; serve's searches are never paced
+			}
+			limits := priorityLimits()
+			applyPriority(limits)
+			if err := idx.SetEmbedLimits(limits.Threads, limits.BackgroundSessions); err != nil {
+				logger.Warnf("reload: low-priority: %v", err)
+			}
+		case "max-embed-failures", "max-embed-failure-pct":
+			embedLimit = index.EmbedFailureLimit{Consecutive: cfg.MaxEmbedFailures, Percent: cfg.MaxEmbedFailurePct}
+			idx.SetEmbedFailureLimit(embedLimit)
+		}
+		reply.Applied = append(reply.Applied, ch.Key+"="+ch.New)
+	}
+	if w != nil {
+		if err := w.Reload(); err != nil {
+			return nil, err
+		}
+		reply.Applied = append(reply.Applied, "ignore rules")
+		if sizeLimit {
+			if err := recheckOversized(idx); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, ch := range restart {
+		reply.Restart = append(reply.Restart, ch.Key)
+	}
+
+	if len(reply.Applied) == 0 {
+		logger.Infof("Reloaded configuration: nothing changed")
+	} else {
+		logger.Infof("Reloaded configuration: applied %s", strings.Join(reply.Applied, ", "))
+	}
+	if len(reply.Restart) > 0 {
+		logger.Warnf("Changed settings that need a restart: %s", strings.Join(reply.Restart, ", "))
+	}
+	return reply, nil
+}
+
+// recheckOversized indexes and saves the files idx skipped as too large
+// that a raised max-file-kb or a sampling oversized policy admits.
+func recheckOversized(idx *index.Index) error {
+	n, err := idx.RecheckOversized(context.Background())
+	if n > 0 {
+		logger.Infof("Indexed %d files previously skipped as too large", n)
+		if ferr := idx.Flush(); err == nil {
+			err = ferr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("reload: max-file-kb: %w", err)
+	}
+	return nil
+}
+
+// onReloadSignal calls reload on every reload signal (SIGHUP) until ctx
+// is done. It does nothing where there are no reload signals.
+func onReloadSignal(ctx context.Context, reload func()) {
+	onSignal(ctx, reloadSignals, func(os.Signal) { reload() })
+}
+
+// onSignal calls fn with each of sigs received until ctx is done. It does
+// nothing when sigs is empty, as on platforms without them.
+func onSignal(ctx context.Context, sigs []os.Signal, fn func(os.Signal)) {
+	if len(sigs) == 0 {
+		return
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-ch:
+				fn(sig)
+			}
+		}
+	}()
+}
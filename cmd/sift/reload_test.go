@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestReloadConfig(t *testing.T) {
+	project := t.TempDir()
+	t.Chdir(project)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	setGlobals(t, filepath.Join(project, ".sift"))
+	oldCfg, oldMax := cfg, maxFileKB
+	t.Cleanup(func() { cfg, maxFileKB = oldCfg, oldMax })
+
+	watch := findCmd(t, "watch")
+	if err := resolveConfig(watch); err != nil {
+		t.Fatal(err)
+	}
+	idx := index.NewTestIndex(filepath.Join(project, ".sift"), &mockEmbedder{})
+
+	// Edit the config file under the running process.
+	toml := "max-file-kb = 1\nmodel-dir = \"other-models\"\n"
+	if err := os.WriteFile(".sift.toml", []byte(toml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := reloadConfig(watch, idx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(reply.Applied, []string{"max-file-kb=1"}) || !slices.Equal(reply.Restart, []string{"model-dir"}) {
+		t.Errorf("reply = %+v, want max-file-kb applied and model-dir needing a restart", reply)
+	}
+	if maxFileKB != 1 || modelDir == "other-models" {
+		t.Errorf("maxFileKB = %d, modelDir = %q; only the live setting should change", maxFileKB, modelDir)
+	}
+
+	// The new limit reaches the open index.
+	big := filepath.Join(project, "big.md")
+	if err := os.WriteFile(big, []byte(strings.Repeat("word ", 500)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.AddFile(big); err != nil {
+		t.Fatal(err)
+	}
+	if c := idx.FileChunks(big); len(c) != 0 {
+		t.Errorf("a 2.5 KB file was indexed after lowering max-file-kb to 1")
+	}
+}
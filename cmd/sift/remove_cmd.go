@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+)
+
+func init() {
+	removeCmd := &cobra.Command{
+		Use:   "remove <path|glob> [path|glob...]",
+		Short: "Take specific files out of the index",
+		Long: `Drop the chunks of the given files from the index and save it, without a
+full rebuild. The files need not exist on disk: removing a deleted file is
+what this is most often for. No model is loaded.
+
+A quoted glob ("old/**/*.md") is matched against the paths already in the
+index, as reindex does. A file still on disk and not ignored comes back
+the next time its directory is indexed; add it to .siftignore to keep it
+out.`,
+		Example: `  sift remove docs/draft.md
+  sift remove "vendor/**"`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			idx, err := index.OpenWithoutModel(indexDir)
+			if errors.Is(err, index.ErrNoIndex) {
+				return noIndexError()
+			}
+			if err != nil {
+				return err
+			}
+			defer idx.Close()
+
+			failed := removePaths(cmd.OutOrStdout(), idx, args)
+			if err := idx.Flush(); err != nil {
+				return err
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of the given paths could not be removed", failed)
+			}
+			return nil
+		},
+	}
+	rootCmd.AddCommand(removeCmd)
+}
+
+// removePaths removes every file named by args from idx, printing one
+// line per file to w and logging failures, and returns how many paths
+// failed.
+func removePaths(w io.Writer, idx *index.Index, args []string) (failed int) {
+	cwd, _ := os.Getwd()
+	var files, chunks int
+	for _, p := range expandReindexArgs(idx.Files(), args, func(arg string) {
+		logger.Errorf("%s: no indexed file matches", arg)
+		failed++
+	}) {
+		n, err := idx.RemoveFile(p)
+		if err != nil {
+			logger.Errorf("%v", err)
+			failed++
+			continue
+		}
+		files++
+		chunks += n
+		fmt.Fprintf(w, "  %s  %d chunks\n", displayPath(cwd, p), n)
+	}
+	fmt.Fprintf(w, "Removed %d files (%d chunks).\n", files, chunks)
+	return failed
+}
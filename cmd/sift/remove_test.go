@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestRemovePaths(t *testing.T) {
+	project := t.TempDir()
+	t.Chdir(project)
+	idx := index.NewTestIndex(filepath.Join(project, ".sift"), &mockEmbedder{})
+	for _, name := range []string{"a.md", "b.md", "notes/c.md"} {
+		p := filepath.Join(project, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("wireguard config"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Removing a file deleted from disk is the common case.
+	if err := os.Remove(filepath.Join(project, "a.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	failed := removePaths(&out, idx, []string{"a.md", "notes/*.md", "never.md", "*.txt"})
+	if failed != 2 {
+		t.Errorf("failed = %d, want 2 (never.md, *.txt)", failed)
+	}
+	for _, want := range []string{"  a.md  1 chunks\n", "  notes/c.md  1 chunks\n", "Removed 2 files (2 chunks)."} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	if files := idx.Files(); len(files) != 1 || filepath.Base(files[0]) != "b.md" {
+		t.Errorf("Files() after remove = %q, want only b.md", files)
+	}
+}
@@ -4,60 +4,464 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/tejas242/sift/internal/config"
+	"github.com/tejas242/sift/internal/embed"
 	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/logging"
+	"github.com/tejas242/sift/internal/maintain"
+	"github.com/tejas242/sift/internal/throttle"
+	"github.com/tejas242/sift/internal/tui"
 )
 
 var (
 	rootCmd = &cobra.Command{
-		Use:   "sift",
+		Use:   "sift [query...]",
 		Short: "Local semantic search for developers",
-		Long:  "sift — fast, offline semantic file search powered by BGE-small-en-v1.5 and HNSW.",
+		Long: `sift — fast, offline semantic file search powered by BGE-small-en-v1.5 and HNSW.
+
+Bare sift opens the TUI on a terminal; sift <words> is sift search <words>.
+Subcommand names win, so search for one with sift -- index or sift search index.`,
+		// main prints errors and picks the exit code; see exit.go.
+		SilenceErrors: true,
+		SilenceUsage:  true,
 	}
 
+	// reachedRun is set once flag and argument validation have passed, so
+	// errors returned before it are usage errors.
+	reachedRun bool
+
+	// openIndexFunc loads an index; tests replace it to avoid the ONNX model.
+	openIndexFunc = index.OpenWith
+
 	cfg        *config.Config
+	configPath string
 	modelDir   string
 	ortLib     string
 	numThreads int
 	maxFileKB  int
-	quiet      bool
+	// embedSessions is the embed-sessions setting: inference sessions the
+	// model gets.
+	embedSessions int
+	// backgroundSessions is the background-sessions setting: how many of
+	// them the watcher's indexing holds at once, 0 for all.
+	backgroundSessions int
+	// lowPriority is the low-priority setting; priorityLimits derives
+	// what it limits.
+	lowPriority bool
+	// oversizedPolicy is the parsed oversized setting; see
+	// index.SetOversizedPolicy.
+	oversizedPolicy index.OversizedPolicy
+	// storedPreview is the parsed preview setting: characters of chunk
+	// text the index stores, 0 for all of it.
+	storedPreview int
+	// redactPattern is the parsed redact-pattern setting, or nil.
+	redactPattern *regexp.Regexp
+	// categoryRules is the parsed categories setting; see
+	// index.SetCategoryRules.
+	categoryRules []index.CategoryRule
+	// metaCompression is the parsed compress-meta setting.
+	metaCompression index.MetaCompression
+	// pruneAfter is the parsed prune-after setting; 0 disables the pass.
+	pruneAfter time.Duration
+	// lowMemory makes search-only commands open the index with
+	// index.OpenLowMemory.
+	lowMemory bool
+	// preload and preloadAuto are the parsed preload setting; see
+	// preloads.
+	preload, preloadAuto bool
+	// calibrateScores is the calibrate setting; see index.SetCalibrate.
+	calibrateScores bool
+	// maxPerDir is the max-per-dir setting; see index.SetMaxPerDir.
+	maxPerDir int
+	// resultCache holds the result-cache and result-cache-ttl settings;
+	// see index.SetResultCache.
+	resultCache = index.DefaultResultCache
+	// keywordPolicy holds the stopwords and min-boost-words settings;
+	// see index.SetKeywordPolicy.
+	keywordPolicy = index.DefaultKeywordPolicy
+	// queryExpansion holds the expand-queries and synonyms settings; see
+	// index.SetExpansion.
+	queryExpansion index.Expansion
+	// embedLimit holds the max-embed-failures and max-embed-failure-pct
+	// settings; see index.SetEmbedFailureLimit.
+	embedLimit = index.DefaultEmbedFailureLimit
+	// embedPrefixes holds the query-prefix, document-prefix and symmetric
+	// settings; see index.SetPrefixes.
+	embedPrefixes = embed.DefaultPrefixes
+	// maintenancePolicy holds the maintenance-interval,
+	// maintenance-stale-pct and maintenance-idle settings; see
+	// startMaintenance.
+	maintenancePolicy maintain.Policy
+	// pinExport and pinFormat are the pin-export and pin-format settings;
+	// see exportPins.
+	pinExport, pinFormat string
+	indexDir             string
+	// projectRoot is the discovered project root (see config.Config.Root),
+	// or "" with --global.
+	projectRoot string
+	// globalIndex selects the personal index under the user data dir.
+	globalIndex bool
+	quiet       bool
+	verbose     bool
+	// errorsJSON reports failures on stderr as JSON; see writeErrorJSON.
+	errorsJSON bool
+	// rawOutput prints exact sizes, counts, durations and timestamps
+	// instead of humanized ones; see humanize.go.
+	rawOutput bool
+	// absPaths prints absolute file paths instead of ones relative to
+	// the working directory; see displayPath.
+	absPaths bool
+	// executedCmd is the command the last Execute ran (or failed to).
+	executedCmd *cobra.Command
+
+	// logger receives diagnostics from index, embed, and watcher.
+	logger = logging.Default()
 )
 
 func init() {
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		reachedRun = true
+		if err := resolveConfig(cmd); err != nil {
+			return &usageError{err}
+		}
+		return nil
+	}
+	rootCmd.Args = func(cmd *cobra.Command, args []string) error {
+		if fromStdin {
+			return cobra.NoArgs(cmd, args)
+		}
+		return nil
+	}
+	rootCmd.RunE = runRoot
+	addSearchFlags(rootCmd)
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &usageError{err}
+	})
+
+	f := rootCmd.PersistentFlags()
+	f.StringVar(&configPath, "config", "", "config file (default .sift.toml, or $SIFT_CONFIG)")
+	f.StringVar(&modelDir, "model-dir", config.DefaultModelDir, "directory containing ONNX model files")
+	f.StringVar(&ortLib, "ort-lib", config.DefaultOrtLib, "path to the onnxruntime library, onnxruntime.so or .dll (auto-detected if empty)")
+	f.IntVar(&numThreads, "threads", config.DefaultThreads, "ONNX intra-op thread count (0 = auto, usually NumCPU capped at 4)")
+	f.IntVar(&maxFileKB, "max-file-kb", config.DefaultMaxFile, "skip indexing files larger than this (in KB)")
+	f.IntVar(&embedSessions, "embed-sessions", config.DefaultEmbedSessions, "ONNX sessions embedding runs on: 1 serializes concurrent searches and indexing, more run them in parallel at --threads each")
+	f.IntVar(&backgroundSessions, "background-sessions", 0, "most of the --embed-sessions the watcher's background indexing holds at once, leaving the rest to searches (0 = all)")
+	f.BoolVar(&lowPriority, "low-priority", false, "index in the background's way: nice 10 and lowest I/O priority, 1 ONNX thread and batch at a time, pausing between files while the system is busy")
+	f.String("oversized", config.OversizedSkip, `files over --max-file-kb: "skip", "sample" (index their first chunks and evenly spaced ones), or per extension, as "skip,md=sample"`)
+	f.String("preview", config.PreviewFull, `how much of each chunk's text the index stores: "full" or a number of characters`)
+	f.String("redact-pattern", "", "regular expression for secrets to replace with [REDACTED] in stored chunk text, besides AWS keys, bearer tokens, GitHub tokens and private keys")
+	f.String("categories", "", `the project's own rules for the file categories "type:" filters on, tried before the built-in ones, as "category=glob,…" ("tests=spec/**,docs=handbook/**")`)
+	f.String("compress-meta", config.CompressAuto, `store meta.json gzipped: "auto" (once it reaches 1 MB), "always" or "never"`)
+	f.String("prune-after", config.DefaultPruneAfter, "when the index is older than this, opening it drops files deleted from disk (0 disables)")
+	f.BoolVar(&lowMemory, "low-memory", false, "search-only commands read vectors from disk on demand, for a smaller memory footprint on large indexes")
+	f.String("preload", config.PreloadAuto, `read the index files left on disk through in the background on opening, so the first search finds them cached: "true", "false", or "auto" (for tui and serve)`)
+	f.BoolVar(&calibrateScores, "calibrate", false, "rescale each query's scores onto 0–1 across its candidates, so --threshold means the same for every query")
+	f.IntVar(&maxPerDir, "max-per-dir", 0, "return at most this many results from one directory, promoting the next-best from others (0 = unlimited)")
+	f.Int("result-cache", config.DefaultResultCache, "keep the results of this many recent searches for reuse until the index changes (0 disables)")
+	f.String("result-cache-ttl", config.DefaultResultCacheTTL, "reuse a cached search's results for at most this long (0 = until the index changes)")
+	f.String("stopwords", config.StopwordsDefault, `query words never given the keyword boost: "default" (common English words), "none", or a comma-separated list ("default,foo" extends the default)`)
+	f.Int("min-boost-words", config.DefaultMinBoostWords, "give the keyword boost only to queries with at least this many words besides stopwords")
+	f.Int("expand-queries", 0, "also search this many variants of each query with a word swapped for a synonym, weighting their results down (0 = off, at most 4)")
+	f.String("synonyms", config.SynonymsDefault, `synonyms expand-queries swaps words for: "default" (common programming synonyms), "none", or a comma-separated list of words joined by | ("default,ship|deploy" extends the default)`)
+	f.Int("max-embed-failures", config.DefaultMaxEmbedFailures, "give up indexing after this many files in a row fail to embed (0 = never)")
+	f.Int("max-embed-failure-pct", config.DefaultMaxEmbedFailurePct, "give up indexing once more than this percentage of files fail to embed (0 = never)")
+	f.String("maintenance-interval", config.DefaultMaintenanceInterval, "watch compacts the index this often, once it is idle (0 disables)")
+	f.Int("maintenance-stale-pct", config.DefaultMaintenanceStalePct, "watch compacts the index once this percentage of indexed files are gone from disk (0 disables)")
+	f.String("maintenance-idle", config.DefaultMaintenanceIdle, "how long the index must go unchanged before watch compacts it")
+	f.String("editor-command", "", `command line the TUI opens results with, such as "hx {path}:{line}"; {path}, {line} and {col} are filled in (default: $EDITOR)`)
+	f.String("pin-export", config.PinExportStdout, `where the TUI writes the results pinned in a session when it quits: "stdout", "clipboard", "none", or a file path`)
+	f.String("pin-format", config.PinFormatLines, `how pinned results are exported: "lines" (path:line) or "markdown"`)
+	f.String("query-prefix", config.DefaultQueryPrefix, "text put in front of queries before embedding them, as the model expects")
+	f.String("document-prefix", "", "text put in front of document chunks before embedding them, as the model expects")
+	f.Bool("symmetric", false, "embed queries like documents, with --document-prefix, for symmetric models")
+	f.StringVar(&indexDir, "index-dir", config.DefaultSiftDir, "directory where the index is stored")
+	f.BoolVar(&globalIndex, "global", false, "use the personal global index (user data dir) instead of the project's")
+	rootCmd.MarkFlagsMutuallyExclusive("global", "index-dir")
+	f.Bool("here", false, "treat the working directory as the project root instead of searching parent directories for one")
+	f.String("profile", "", "use the [profile.<name>] settings of the config file, and that profile's index (also SIFT_PROFILE)")
+	f.String("index-location", config.LocationProject, `where the index lives by default: "project" (./.sift) or "xdg" (user data dir)`)
+	f.BoolVarP(&quiet, "quiet", "q", false, "suppress progress and model loading output")
+	f.BoolVarP(&verbose, "verbose", "v", false, "log per-file and debug detail (also SIFT_DEBUG=1)")
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "verbose")
+	f.BoolVar(&noColor, "no-color", false, "disable colour output (also NO_COLOR)")
+	f.BoolVar(&rawOutput, "raw", false, "print exact byte counts, durations and RFC 3339 timestamps instead of \"84.2 MB\" and \"2 hours ago\" (JSON is always exact)")
+	f.BoolVar(&absPaths, "abs", false, "print absolute file paths instead of paths relative to the current directory (JSON carries both)")
+	f.BoolVar(&errorsJSON, "errors-json", false, `report failures on stderr as JSON, {"error": {"code", "message", "hint"}} (implied by --json)`)
+}
+
+// resolveConfig merges flags, SIFT_* environment variables, the config file,
+// and defaults (in that order of precedence) into the global settings.
+func resolveConfig(cmd *cobra.Command) error {
 	var err error
-	cfg, err = config.Load()
+	cfg, err = config.Resolve(os.Getenv, setFlags(cmd))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading configuration: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("configuration: %w", err)
+	}
+	modelDir = cfg.ModelDir
+	ortLib = cfg.OrtLib
+	numThreads = cfg.Threads
+	maxFileKB = cfg.MaxFileKB
+	embedSessions = cfg.EmbedSessions
+	backgroundSessions = cfg.BackgroundSessions
+	lowPriority = cfg.LowPriority
+	oversizedPolicy = parseOversized(cfg.Oversized)
+	storedPreview, _ = config.ParsePreview(cfg.Preview)             // validated by Resolve
+	redactPattern, _ = config.ParseRedactPattern(cfg.RedactPattern) // validated by Resolve
+	categoryRules = parseCategories(cfg.Categories)
+	metaCompression = parseMetaCompression(cfg.CompressMeta)
+	pruneAfter, _ = config.ParsePruneAfter(cfg.PruneAfter) // validated by Resolve
+	lowMemory = cfg.LowMemory
+	preload, preloadAuto, _ = config.ParsePreload(cfg.Preload) // validated by Resolve
+	calibrateScores = cfg.Calibrate
+	maxPerDir = cfg.MaxPerDir
+	resultCache = parseResultCache(cfg)
+	keywordPolicy = parseKeywordPolicy(cfg)
+	queryExpansion = parseExpansion(cfg)
+	embedLimit = index.EmbedFailureLimit{Consecutive: cfg.MaxEmbedFailures, Percent: cfg.MaxEmbedFailurePct}
+	embedPrefixes = embed.Prefixes{Query: cfg.QueryPrefix, Document: cfg.DocumentPrefix, Symmetric: cfg.Symmetric}
+	maintenancePolicy = parseMaintenancePolicy(cfg)
+	projectRoot = cfg.Root
+	if cfg.Global {
+		projectRoot = ""
 	}
+	if indexDir, err = cfg.IndexPath(); err != nil {
+		return fmt.Errorf("index-dir: %w", err)
+	}
+	logger = logging.New(os.Stderr, logLevel())
+	tui.SetColor(colorEnabled())
+	tui.SetAbsPaths(absPaths)
+	editor, _ := config.ParseEditorCommand(cfg.EditorCommand) // validated by Resolve
+	tui.SetEditorCommand(editor)
+	pinExport, pinFormat = cfg.PinExport, cfg.PinFormat
+	return nil
+}
 
-	rootCmd.PersistentFlags().StringVar(&modelDir, "model-dir", cfg.ModelDir, "directory containing ONNX model files")
-	rootCmd.PersistentFlags().StringVar(&ortLib, "ort-lib", cfg.OrtLib, "path to onnxruntime.so (auto-detected if empty)")
-	rootCmd.PersistentFlags().IntVar(&numThreads, "threads", cfg.Threads, "ONNX intra-op thread count (0 = auto, usually NumCPU capped at 4)")
-	rootCmd.PersistentFlags().IntVar(&maxFileKB, "max-file-kb", cfg.MaxFileKB, "skip indexing files larger than this (in KB)")
-	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "suppress progress and model loading output")
+// runRoot makes bare `sift` open the TUI (or print help when stdout is not
+// a terminal) and `sift <words>` search. Cobra matches subcommands first,
+// and everything after "--" is query text.
+func runRoot(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && !fromStdin {
+		if !stdoutIsTTY() {
+			return cmd.Help()
+		}
+		return runTUI()
+	}
+	return runSearchCmd(cmd, args)
+}
+
+// parseMetaCompression maps a compress-meta setting, validated by
+// config.Resolve, to the index's mode.
+func parseMetaCompression(v string) index.MetaCompression {
+	switch v {
+	case config.CompressAlways:
+		return index.CompressAlways
+	case config.CompressNever:
+		return index.CompressNever
+	default:
+		return index.CompressAuto
+	}
+}
+
+// parseOversized converts a validated oversized setting to the index's
+// policy.
+func parseOversized(v string) index.OversizedPolicy {
+	sample, exts, _ := config.ParseOversized(v) // validated by Resolve
+	return index.OversizedPolicy{Sample: sample, Exts: exts}
+}
+
+// parseCategories converts the validated categories setting v to the
+// index's rules.
+func parseCategories(v string) []index.CategoryRule {
+	parsed, _ := config.ParseCategories(v) // validated by Resolve
+	var rules []index.CategoryRule
+	for _, r := range parsed {
+		rules = append(rules, index.CategoryRule{Category: r.Category, Glob: r.Glob})
+	}
+	return rules
+}
+
+// parseKeywordPolicy converts the validated stopwords and min-boost-words
+// settings of c to the index's policy.
+func parseKeywordPolicy(c *config.Config) index.KeywordPolicy {
+	words, builtin, _ := config.ParseStopwords(c.Stopwords) // validated by Resolve
+	if builtin {
+		words = append(slices.Clone(index.DefaultStopwords), words...)
+	}
+	return index.KeywordPolicy{Stopwords: words, MinWords: c.MinBoostWords}
+}
+
+// parseExpansion converts the validated expand-queries and synonyms
+// settings of c to the index's expansion.
+func parseExpansion(c *config.Config) index.Expansion {
+	groups, builtin, _ := config.ParseSynonyms(c.Synonyms) // validated by Resolve
+	if builtin {
+		groups = append(slices.Clone(index.DefaultSynonyms), groups...)
+	}
+	return index.Expansion{Variants: c.ExpandQueries, Synonyms: groups}
+}
+
+// parseResultCache converts the validated result-cache and
+// result-cache-ttl settings of c to the index's cache size.
+func parseResultCache(c *config.Config) index.ResultCache {
+	ttl, _ := config.ParseResultCacheTTL(c.ResultCacheTTL) // validated by Resolve
+	return index.ResultCache{Size: c.ResultCache, TTL: ttl}
+}
+
+// setFlags returns the flags the user set explicitly on cmd, by name.
+func setFlags(cmd *cobra.Command) map[string]string {
+	flags := make(map[string]string)
+	cmd.Flags().Visit(func(fl *pflag.Flag) {
+		flags[fl.Name] = fl.Value.String()
+	})
+	return flags
+}
+
+// logLevel maps --quiet, --verbose, and SIFT_DEBUG to a logging level.
+func logLevel() logging.Level {
+	switch {
+	case quiet:
+		return logging.LevelError
+	case verbose || os.Getenv("SIFT_DEBUG") == "1":
+		return logging.LevelDebug
+	default:
+		return logging.LevelInfo
+	}
 }
 
 // Execute executes the root command.
 func Execute() error {
-	return rootCmd.Execute()
+	var err error
+	executedCmd, err = rootCmd.ExecuteC()
+	var ue *usageError
+	if err != nil && !reachedRun && !errors.As(err, &ue) {
+		// Unknown command or failed argument validation.
+		err = &usageError{err}
+	}
+	return err
+}
+
+// openExistingIndex opens the index and loads the model for read-only
+// commands, which mostly search: with the low-memory setting the vectors
+// stay on disk. It fails with index.ErrNoIndex instead of creating an
+// empty index, and the index it returns never writes, not even to prune
+// (see Index.SetReadOnly).
+func openExistingIndex(ortLibFlag string) (*index.Index, error) {
+	if !index.Exists(indexDir) {
+		return nil, noIndexError()
+	}
+	idx, err := openIndexLazy(ortLibFlag, lowMemory, preloads(false), true)
+	if err != nil {
+		return nil, err
+	}
+	return loadModel(idx)
 }
 
-func openIndex(ortLibFlag string) (*index.Index, error) {
+// noIndexError is index.ErrNoIndex, plus a hint when only the global index
+// exists. sift never falls back to it silently: which index answers must
+// be clear, so using it always takes --global.
+func noIndexError() error {
+	if !globalIndex {
+		if g := config.GlobalIndexDir(os.Getenv); g != "" && index.Exists(g) {
+			return fmt.Errorf("%w (a global index exists; pass --global to use it)", index.ErrNoIndex)
+		}
+	}
+	return index.ErrNoIndex
+}
+
+// dirArgs requires at least one directory, except with --global, where
+// the index's remembered roots are used instead; see indexRoots.
+func dirArgs(cmd *cobra.Command, args []string) error {
+	if globalIndex {
+		return nil
+	}
+	return cobra.MinimumNArgs(1)(cmd, args)
+}
+
+// indexRoots returns args, or the roots remembered by idx when args is
+// empty (only allowed with --global), without the ones given twice or
+// inside another (see index.CoverRoots), noting each left out.
+func indexRoots(idx *index.Index, args []string) ([]string, error) {
+	roots := args
+	if len(roots) == 0 {
+		if roots = idx.Roots(); len(roots) == 0 {
+			return nil, &usageError{errors.New("the global index has no roots yet; run `sift --global index <dir>` first")}
+		}
+	}
+	roots, merged := idx.CoverRoots(roots)
+	for _, m := range merged {
+		if m.Same {
+			logger.Infof("%s is the same as %s; indexing it once", m.Root, m.Into)
+		} else {
+			logger.Infof("%s is inside %s; indexing it with it", m.Root, m.Into)
+		}
+	}
+	return roots, nil
+}
+
+// openInteractiveIndex opens the index and loads the model for the commands
+// that keep it open for searches typed later (tui, serve), which preload it
+// by default. serve opens it readOnly: it only searches and reloads what
+// other processes save (see Index.Refresh), so it must never save its own,
+// older copy over theirs. Unlike openExistingIndex, the index need not
+// exist yet.
+func openInteractiveIndex(ortLibFlag string, readOnly bool) (*index.Index, error) {
+	idx, err := openIndexLazy(ortLibFlag, lowMemory, preloads(true), readOnly)
+	if err != nil {
+		return nil, err
+	}
+	return loadModel(idx)
+}
+
+// preloads reports whether opening the index warms it up (see
+// index.Options.Preload): as the preload setting says, or, with "auto",
+// for interactive commands.
+func preloads(interactive bool) bool {
+	if preloadAuto {
+		return interactive
+	}
+	return preload
+}
+
+// openIndexWith opens the index, creating it if needed, and loads the
+// model, reading vectors on demand when lowMem is set and warming the
+// index up when preload is. Commands that embed many files (index,
+// rebuild, watch, reindex) pass false: they touch every vector anyway
+// when saving. These are the commands the low-priority setting is for, so
+// only they are paced and reniced by it (see applyPriority); searches run
+// at full speed.
+func openIndexWith(ortLibFlag string, lowMem, preload bool) (*index.Index, error) {
+	idx, err := openIndexLazy(ortLibFlag, lowMem, preload, false)
+	if err != nil {
+		return nil, err
+	}
+	limits := priorityLimits()
+	applyPriority(limits)
+	if err := idx.SetEmbedLimits(limits.Threads, limits.BackgroundSessions); err != nil {
+		idx.Close()
+		return nil, err
+	}
+	return loadModel(idx)
+}
+
+// loadModel loads idx's embedding model, closing idx if that fails.
+func loadModel(idx *index.Index) (*index.Index, error) {
 	if !quiet {
 		fmt.Fprint(os.Stderr, "Loading model… ")
 	}
-	resolved := config.ResolveOrtLib(ortLibFlag)
-	idx, err := index.Open(config.DefaultSiftDir, modelDir, resolved, numThreads, maxFileKB)
-	if err != nil {
+	if err := idx.LoadEmbedder(); err != nil {
 		if !quiet {
 			fmt.Fprintln(os.Stderr, "")
 		}
+		idx.Close()
 		return nil, err
 	}
 	if !quiet {
@@ -66,11 +470,73 @@ func openIndex(ortLibFlag string) (*index.Index, error) {
 	return idx, nil
 }
 
-func indexDirs(ctx context.Context, idx *index.Index, dirs []string) error {
+// openIndexLazy opens the index without loading the embedding model; it is
+// loaded on first embed or search. lowMem and preload are as for
+// openIndexWith. A readOnly index is made read-only before anything can
+// change it, so the stale-file prune is skipped.
+func openIndexLazy(ortLibFlag string, lowMem, preload, readOnly bool) (*index.Index, error) {
+	limits := throttle.Derive(false, numThreads, backgroundSessions)
+	idx, err := openIndexFunc(index.Options{
+		Dir:                indexDir,
+		ModelDir:           modelDir,
+		OrtLib:             config.ResolveOrtLib(ortLibFlag),
+		Threads:            limits.Threads,
+		Sessions:           embedSessions,
+		MaxFileKB:          maxFileKB,
+		LowMemory:          lowMem,
+		Preload:            preload,
+		BackgroundSessions: limits.BackgroundSessions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	idx.SetReadOnly(readOnly)
+	idx.SetLogger(logger)
+	idx.SetOversizedPolicy(oversizedPolicy)
+	idx.SetPreview(storedPreview)
+	idx.SetRedactPattern(redactPattern)
+	idx.SetCategoryRules(categoryRules)
+	idx.SetMetaCompression(metaCompression)
+	idx.SetCalibrate(calibrateScores)
+	idx.SetMaxPerDir(maxPerDir)
+	idx.SetResultCache(resultCache)
+	idx.SetKeywordPolicy(keywordPolicy)
+	idx.SetExpansion(queryExpansion)
+	idx.SetEmbedFailureLimit(embedLimit)
+	idx.SetPrefixes(embedPrefixes)
+	idx.SetThrottle(pace)
+	if n := pruneStale(idx, readOnly); n > 0 {
+		logger.Infof("Pruned %d indexed files missing on disk", n)
+	}
+	if globalIndex {
+		logger.Infof("Using global index %s", indexDir)
+	}
+	return idx, nil
+}
+
+// pruneStale runs the prune-after pass on idx, unless it is read-only:
+// read-only commands must leave the index as they found it.
+func pruneStale(idx *index.Index, readOnly bool) int {
+	if readOnly {
+		return 0
+	}
+	return idx.PruneIfStale(pruneAfter)
+}
+
+// indexDirs indexes each of dirs into idx, reporting to sink. With fresh
+// set the index is rebuilt from scratch (sift rebuild), replacing the old
+// contents only once every root is indexed.
+func indexDirs(ctx context.Context, idx *index.Index, dirs []string, fresh bool, sink progressSink) error {
+	idx.BeginRun()
 	done := make(chan struct{})
+	var wg sync.WaitGroup
+	// The goroutine reads globals, so it must be gone when we return.
+	defer wg.Wait()
 	defer close(done)
 
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		select {
 		case <-done:
 			return // clean exit — do nothing
@@ -90,46 +556,104 @@ func indexDirs(ctx context.Context, idx *index.Index, dirs []string) error {
 		}
 	}()
 
-	prog := makeProgressPrinter()
-	for _, dir := range dirs {
+	if fresh {
+		// Searches sharing idx see the old index until every root is
+		// rebuilt; an interrupted rebuild leaves it as it was. Arguments
+		// that could not be indexed do not hold back the others.
+		var bad argErrors
+		err := idx.Rebuild(func(stage *index.Index) error {
+			err := indexEach(ctx, stage, dirs, sink)
+			if errors.As(err, &bad) {
+				return nil
+			}
+			return err
+		})
+		if isInterrupted(err) {
+			if !quiet {
+				fmt.Fprintln(os.Stderr, "\nInterrupted — the index is left as it was.")
+			}
+			return nil
+		}
+		if err == nil && bad != nil {
+			return bad
+		}
+		return err
+	}
+	err := indexEach(ctx, idx, dirs, sink)
+	if isInterrupted(err) {
 		if !quiet {
-			fmt.Fprintf(os.Stderr, "Scanning %s…\n", dir)
+			fmt.Fprintln(os.Stderr, "\nInterrupted — saving partial index…")
 		}
-		err := idx.IndexDirWithProgress(ctx, dir, prog)
+		return nil
+	}
+	return err
+}
+
+// indexEach indexes each of dirs (directories or single files) into idx
+// in turn, reporting to sink. An argument that cannot be indexed at all —
+// missing, unreadable, or a file of a type sift does not index — is
+// reported and passed over, and indexEach returns the argErrors once the
+// rest are done; any other error stops it at once. Meanwhile SIGUSR1 and
+// SIGUSR2 ask it for its status and a checkpoint (see onRunSignals).
+func indexEach(ctx context.Context, idx *index.Index, dirs []string, sink progressSink) error {
+	idx.SetChunkProgress(sink.Chunk)
+	defer idx.SetChunkProgress(nil)
+	sigCtx, stopSignals := context.WithCancel(ctx)
+	defer stopSignals()
+	onRunSignals(sigCtx, idx, os.Stderr)
+	var bad argErrors
+	for _, dir := range dirs {
+		sink.Start(dir)
+		start, before := time.Now(), idx.RunStats()
+		err := idx.IndexDirWithProgress(ctx, dir, sink.File)
+		failed := newRootFailures(before, idx.RunStats())
 		if err != nil {
-			if isInterrupted(err) {
-				if !quiet {
-					fmt.Fprintln(os.Stderr, "\nInterrupted — saving partial index…")
-				}
-				return nil
+			sink.Error(dir, err, failed, time.Since(start))
+			if !isArgError(err) {
+				return err
 			}
-			return err
+			bad = append(bad, argError{dir, err})
+			continue
 		}
+		sink.Finish(dir, idx.Stats().NumChunks, failed, time.Since(start))
+	}
+	if bad != nil {
+		return bad
 	}
 	return nil
 }
 
-func isInterrupted(err error) bool {
-	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+// argErrors are the arguments of an index run that could not be indexed;
+// the run indexed the others, and its work is saved like any other.
+type argErrors []argError
+
+// argError is why the argument arg could not be indexed.
+type argError struct {
+	arg string
+	err error
 }
 
-func makeProgressPrinter() index.ProgressFunc {
-	if quiet {
-		return func(done, total int, path string, skipped bool) {}
-	}
-	return func(done, total int, path string, skipped bool) {
-		short := filepath.Base(filepath.Dir(path)) + "/" + filepath.Base(path)
-		if skipped {
-			fmt.Fprintf(os.Stderr, "\r  [%d/%d]  ·   %-50s", done, total, short)
-		} else {
-			pct := 100 * done / total
-			if done < total {
-				fmt.Fprintf(os.Stderr, "\r  [%d/%d] %3d%%  %-50s",
-					done, total, pct, short)
-			} else {
-				fmt.Fprintf(os.Stderr, "\r  [%d/%d] 100%%  %-50s\n",
-					done, total, short)
-			}
-		}
+func (e argErrors) Error() string { return errors.Join(e.Unwrap()...).Error() }
+
+func (e argErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, a := range e {
+		errs[i] = a.err
 	}
+	return errs
+}
+
+// has reports whether arg is one of the arguments that failed.
+func (e argErrors) has(arg string) bool {
+	return slices.ContainsFunc(e, func(a argError) bool { return a.arg == arg })
+}
+
+// isArgError reports whether err, from indexing one argument, concerns
+// only that argument; see indexEach.
+func isArgError(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) || errors.Is(err, index.ErrUnsupported)
+}
+
+func isInterrupted(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
 }
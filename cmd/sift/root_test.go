@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestRootFind(t *testing.T) {
+	for _, tc := range []struct {
+		args     []string
+		wantCmd  string
+		wantArgs []string
+	}{
+		{[]string{"how", "does", "caching", "work"}, "sift", []string{"how", "does", "caching", "work"}},
+		{[]string{"index"}, "index", []string{}},
+		{[]string{"index", "./docs"}, "index", []string{"./docs"}},
+		{[]string{"--", "index"}, "sift", []string{"--", "index"}},
+		{[]string{"--json", "index", "policy"}, "index", []string{"--json", "policy"}},
+		{[]string{"--", "-v", "flag"}, "sift", []string{"--", "-v", "flag"}},
+	} {
+		cmd, args, err := rootCmd.Find(tc.args)
+		if err != nil {
+			t.Errorf("Find(%q): %v", tc.args, err)
+			continue
+		}
+		if cmd.Name() != tc.wantCmd || !slices.Equal(args, tc.wantArgs) {
+			t.Errorf("Find(%q) = %s %q, want %s %q", tc.args, cmd.Name(), args, tc.wantCmd, tc.wantArgs)
+		}
+	}
+}
+
+func TestRootSearch(t *testing.T) {
+	useTestIndex(t, true)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	// resolveConfig resets indexDir, so find the test index as ./.sift.
+	t.Chdir(filepath.Dir(indexDir))
+	oldTTY := stdoutIsTTY
+	stdoutIsTTY = func() bool { return false }
+	t.Cleanup(func() { stdoutIsTTY = oldTTY })
+
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		t.Cleanup(func() { rootCmd.SetOut(nil) })
+		reachedRun = false
+		rootCmd.SetArgs(args)
+		t.Cleanup(func() { rootCmd.SetArgs(nil) })
+		err := Execute()
+		return out.String(), err
+	}
+
+	// Words that are not a subcommand search.
+	out, err := run("wireguard", "config")
+	if err != nil || !strings.Contains(out, "notes.md") {
+		t.Errorf("sift wireguard config = %v:\n%s", err, out)
+	}
+	// After "--", words that look like flags or subcommands are query text.
+	if out, err = run("--", "index", "--weird"); err != nil || !strings.Contains(out, "notes.md") {
+		t.Errorf("sift -- index --weird = %v:\n%s", err, out)
+	}
+	// Without a terminal, bare sift prints help instead of the TUI.
+	if out, err = run(); err != nil || !strings.Contains(out, "Usage:") {
+		t.Errorf("bare sift = %v:\n%s", err, out)
+	}
+}
+
+// TestSearchFromSubdirectory checks that the project's index is found from
+// below the project root, and that --here opts out.
+func TestSearchFromSubdirectory(t *testing.T) {
+	useTestIndex(t, true)
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	deep := filepath.Join(filepath.Dir(indexDir), "sub", "deep")
+	if err := os.MkdirAll(deep, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(deep)
+	oldTTY := stdoutIsTTY
+	stdoutIsTTY = func() bool { return false }
+	t.Cleanup(func() { stdoutIsTTY = oldTTY })
+	here := rootCmd.PersistentFlags().Lookup("here")
+	oldRoot := projectRoot
+	t.Cleanup(func() { here.Value.Set("false"); here.Changed = false; projectRoot = oldRoot })
+
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		t.Cleanup(func() { rootCmd.SetOut(nil) })
+		reachedRun = false
+		rootCmd.SetArgs(args)
+		t.Cleanup(func() { rootCmd.SetArgs(nil) })
+		err := Execute()
+		return out.String(), err
+	}
+
+	out, err := run("search", "wireguard")
+	if err != nil || !strings.Contains(out, "notes.md") {
+		t.Errorf("search from %s = %v:\n%s", deep, err, out)
+	}
+	// Hits elsewhere in the project are shown relative to the working
+	// directory; files outside it keep their absolute path.
+	root := filepath.Dir(indexDir)
+	if got := displayPath(deep, filepath.Join(root, "notes.md")); got != "../../notes.md" {
+		t.Errorf("displayPath in the project = %q, want ../../notes.md", got)
+	}
+	if outside := filepath.Join(filepath.Dir(root), "other.md"); displayPath(deep, outside) != filepath.ToSlash(outside) {
+		t.Errorf("displayPath outside the project = %q", displayPath(deep, outside))
+	}
+	if _, err = run("--here", "search", "wireguard"); !errors.Is(err, index.ErrNoIndex) {
+		t.Errorf("search --here = %v, want ErrNoIndex", err)
+	}
+	if _, err := os.Stat(filepath.Join(deep, ".sift")); !os.IsNotExist(err) {
+		t.Errorf("searching created an index in %s", deep)
+	}
+}
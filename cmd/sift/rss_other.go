@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package main
+
+// peakRSS is not implemented on this platform.
+func peakRSS() (int64, bool) {
+	return 0, false
+}
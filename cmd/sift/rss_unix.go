@@ -0,0 +1,20 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"runtime"
+	"syscall"
+)
+
+// peakRSS returns the process's peak resident set size in bytes.
+func peakRSS() (int64, bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, false
+	}
+	if runtime.GOOS == "darwin" {
+		return int64(ru.Maxrss), true // bytes
+	}
+	return int64(ru.Maxrss) * 1024, true // KiB on Linux
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// onRunSignals answers the signals an index run takes until ctx is done:
+// a status signal (SIGUSR1) prints where the run is to w, and a flush
+// signal (SIGUSR2) has idx save what it has indexed at the next file
+// boundary. Neither exists on Windows.
+func onRunSignals(ctx context.Context, idx *index.Index, w io.Writer) {
+	onSignal(ctx, statusSignals, func(os.Signal) { writeStatus(w, idx.Status(), time.Now()) })
+	onSignal(ctx, flushSignals, func(os.Signal) { idx.RequestFlush() })
+}
+
+// writeStatus prints s as one line:
+// "status: docs 1200/5000 files, 18m2s elapsed, ETA 57m0s, 48211 chunks, now api/auth.md".
+// The ETA assumes the rest of the root goes as fast as what is done.
+func writeStatus(w io.Writer, s index.RunStatus, now time.Time) {
+	var b strings.Builder
+	b.WriteString("status: ")
+	switch {
+	case s.Root == "":
+		b.WriteString("starting")
+	case s.Total == 0:
+		fmt.Fprintf(&b, "walking %s", s.Root)
+	default:
+		fmt.Fprintf(&b, "%s %d/%d files", s.Root, s.Done, s.Total)
+	}
+	elapsed := s.Run.Elapsed
+	if s.Run.Started.IsZero() && !s.RootStarted.IsZero() {
+		elapsed = now.Sub(s.RootStarted)
+	}
+	fmt.Fprintf(&b, ", %s elapsed", elapsed.Round(time.Second))
+	if s.Done > 0 && s.Done < s.Total {
+		perFile := now.Sub(s.RootStarted) / time.Duration(s.Done)
+		fmt.Fprintf(&b, ", ETA %s", (perFile * time.Duration(s.Total-s.Done)).Round(time.Second))
+	}
+	fmt.Fprintf(&b, ", %d chunks", s.Run.Chunks)
+	if s.Current != "" {
+		fmt.Fprintf(&b, ", now %s", shortPath(s.Current))
+	}
+	fmt.Fprintln(w, b.String())
+}
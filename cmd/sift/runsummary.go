@@ -0,0 +1,308 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/embed"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/lang"
+	"github.com/tejas242/sift/internal/protocol"
+	"github.com/tejas242/sift/internal/throttle"
+)
+
+var (
+	runJSON   bool
+	runRecord bool
+)
+
+// addRunFlags registers the end-of-run summary flags on cmd.
+func addRunFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&runJSON, "json", false, "write the end-of-run summary to stdout as JSON instead of the table on stderr")
+	cmd.Flags().BoolVar(&runRecord, "record", false, "append the run summary to "+index.RunsFile+" in the index directory")
+}
+
+// runReport is the end-of-run summary of index, rebuild and watch, and one
+// line of runs.jsonl.
+type runReport struct {
+	Command   string             `json:"command"`
+	Started   time.Time          `json:"started"`
+	ElapsedMS float64            `json:"elapsed_ms"`
+	PhasesMS  map[string]float64 `json:"phases_ms"`
+	// Priority is the mode the run indexed in, "normal" or "low"; see
+	// the low-priority setting.
+	Priority string   `json:"priority"`
+	Files    runFiles `json:"files"`
+	Chunks   int      `json:"chunks"`
+	Bytes    int64    `json:"bytes"`
+	// ChunksTruncated counts the Chunks too long for the model's input,
+	// which were embedded only up to embed.MaxSeqLen tokens.
+	ChunksTruncated int `json:"chunks_truncated"`
+	// Redactions counts the secrets replaced with [REDACTED] in the
+	// text stored for the Chunks.
+	Redactions int `json:"redactions"`
+	// EmbedFailures are the first few of Files.EmbedFailed.
+	EmbedFailures []embedFailure `json:"embed_failures,omitempty"`
+	// Slowest are the few embedded files that took longest, slowest
+	// first.
+	Slowest []fileCost `json:"slowest,omitempty"`
+	// Languages counts the Chunks by the language they were detected as
+	// (ISO 639-1 codes, "en" included).
+	Languages map[string]int `json:"languages,omitempty"`
+	// Error is why the run gave up, if it did; see abortRun.
+	Error string `json:"error,omitempty"`
+}
+
+type runFiles struct {
+	Embedded int `json:"embedded"`
+	Skipped  int `json:"skipped"`
+	Errored  int `json:"errored"`
+	// Moved counts the files found at a new path, which kept their
+	// embeddings instead of being embedded again.
+	Moved int `json:"moved"`
+	// EmbedFailed counts the Errored files the embedder failed on.
+	EmbedFailed int `json:"embed_failed"`
+	// Truncated counts the Embedded files with chunks truncated.
+	Truncated int `json:"truncated"`
+	// Redacted counts the Embedded files with secrets redacted.
+	Redacted int `json:"redacted"`
+}
+
+// embedFailure is a file that could not be embedded, in JSON output.
+type embedFailure = protocol.EmbedFailure
+
+// runSlowest is how many of a run's slowest files its summary lists.
+const runSlowest = 3
+
+// fileCost is what embedding one file took, in JSON output.
+type fileCost struct {
+	Path   string  `json:"path"`
+	MS     float64 `json:"ms"`
+	Chunks int     `json:"chunks"`
+}
+
+func newFileCosts(cs []index.FileCost) []fileCost {
+	if len(cs) == 0 {
+		return nil
+	}
+	out := make([]fileCost, len(cs))
+	for i, c := range cs {
+		out[i] = fileCost{c.Path, ms(c.Duration), c.Chunks}
+	}
+	return out
+}
+
+func newEmbedFailures(fs []index.EmbedFailure) []embedFailure {
+	if len(fs) == 0 {
+		return nil
+	}
+	out := make([]embedFailure, len(fs))
+	for i, f := range fs {
+		out[i] = embedFailure{Path: f.Path, Error: f.Err.Error()}
+	}
+	return out
+}
+
+func newRunReport(command string, s index.RunStats) runReport {
+	r := runReport{
+		Command:   command,
+		Started:   s.Started.UTC(),
+		ElapsedMS: ms(s.Elapsed),
+		PhasesMS:  make(map[string]float64, len(s.Phases)),
+		Priority:  string(pace.Limits().Mode),
+		Files: runFiles{Embedded: s.FilesEmbedded, Skipped: s.FilesSkipped, Errored: s.FilesErrored,
+			Moved: s.FilesMoved, EmbedFailed: s.EmbedFailed, Truncated: s.FilesTruncated, Redacted: s.FilesRedacted},
+		Chunks:          s.Chunks,
+		Bytes:           s.Bytes,
+		ChunksTruncated: s.ChunksTruncated,
+		Redactions:      s.Redactions,
+		EmbedFailures:   newEmbedFailures(s.EmbedFailures),
+		Slowest:         newFileCosts(s.Slowest[:min(len(s.Slowest), runSlowest)]),
+		Languages:       s.Languages,
+	}
+	for p, d := range s.Phases {
+		r.PhasesMS[p] = ms(d)
+	}
+	return r
+}
+
+// finishRun reports the run idx has just completed (and flushed): as JSON
+// on stdout with --json, else as a table on stderr unless --quiet, and
+// appended to runs.jsonl with --record.
+func finishRun(stdout io.Writer, command string, idx *index.Index) error {
+	return reportRun(stdout, newRunReport(command, idx.RunStats()))
+}
+
+// abortRun returns err, the error that ended command's run of idx. A run
+// that gave up on a failing embedder (an *index.EmbedAbortError) is first
+// reported as finishRun would, with the error, so the failures are seen
+// in the same place as on a completed run. Nothing is saved.
+func abortRun(stdout io.Writer, command string, idx *index.Index, err error) error {
+	var abort *index.EmbedAbortError
+	if !errors.As(err, &abort) {
+		return err
+	}
+	r := newRunReport(command, idx.RunStats())
+	r.Error = err.Error()
+	return errors.Join(err, reportRun(stdout, r))
+}
+
+func reportRun(stdout io.Writer, r runReport) error {
+	switch {
+	case runJSON:
+		if err := json.NewEncoder(stdout).Encode(r); err != nil {
+			return err
+		}
+	case !quiet:
+		writeRunSummary(os.Stderr, r)
+	}
+	if runRecord {
+		return appendRunRecord(indexDir, r)
+	}
+	return nil
+}
+
+// writeRunSummary prints r as a compact table: one row per phase that took
+// any time, then the file and chunk counts.
+func writeRunSummary(w io.Writer, r runReport) {
+	fmt.Fprintf(w, "\n%-10s  %10s  %6s\n", "phase", "time", "share")
+	fmt.Fprintln(w, strings.Repeat("─", 30))
+	for _, p := range index.RunPhases {
+		d := r.PhasesMS[p]
+		if d == 0 {
+			continue
+		}
+		name := p
+		if p == index.PhaseTokenize || p == index.PhaseInference {
+			name = "  " + p // part of embed
+		}
+		share := 0.0
+		if r.ElapsedMS > 0 {
+			share = 100 * d / r.ElapsedMS
+		}
+		fmt.Fprintf(w, "%-10s  %8.0fms  %5.1f%%\n", name, d, share)
+	}
+	fmt.Fprintf(w, "%-10s  %8.0fms\n\n", "total", r.ElapsedMS)
+	fmt.Fprintf(w, "files: %d embedded, %d skipped, %d failed; %d chunks from %s\n",
+		r.Files.Embedded, r.Files.Skipped, r.Files.Errored, r.Chunks, sizeText(r.Bytes))
+	if r.Priority == string(throttle.Low) {
+		fmt.Fprintf(w, "priority: %s\n", pace.Limits())
+	}
+	if r.Files.Moved > 0 {
+		fmt.Fprintf(w, "moved: %d files moved (reused embeddings)\n", r.Files.Moved)
+	}
+	writeSlowest(w, "slowest: ", r.Slowest)
+	writeTruncated(w, r.ChunksTruncated, r.Chunks, r.Files.Truncated)
+	writeRedacted(w, r.Redactions, r.Files.Redacted)
+	writeLanguages(w, r.Languages, r.Chunks)
+	writeEmbedFailures(w, r.Files.EmbedFailed, r.EmbedFailures)
+}
+
+// writeSlowest lists costs, one file per line, the first after label and
+// the rest lined up under it: "slowest: api/schema.json 14.2s / 212 chunks".
+func writeSlowest(w io.Writer, label string, costs []fileCost) {
+	for i, c := range costs {
+		if i > 0 {
+			label = strings.Repeat(" ", len(label))
+		}
+		fmt.Fprintf(w, "%s%s %s / %d chunks\n", label, c.Path,
+			durationText(time.Duration(c.MS*float64(time.Millisecond))), c.Chunks)
+	}
+}
+
+// writeTruncated warns that n of chunks, from files files, were too long
+// for the model's input, if any were.
+func writeTruncated(w io.Writer, n, chunks, files int) {
+	if n == 0 || chunks == 0 {
+		return
+	}
+	fmt.Fprintf(w, "warning: %.1f%% of chunks (%d of %d, in %d files) were longer than the model's %d-token input and truncated; search cannot find their ends by meaning\n",
+		100*float64(n)/float64(chunks), n, chunks, files, embed.MaxSeqLen)
+}
+
+// writeRedacted notes that n secrets, from files files, were redacted
+// from the stored chunk text, if any were.
+func writeRedacted(w io.Writer, n, files int) {
+	if n == 0 {
+		return
+	}
+	fmt.Fprintf(w, "redacted: %d secrets in %d files replaced with %s in the stored chunk text\n",
+		n, files, index.Redacted)
+}
+
+// nonEnglishWarn is the share of a run's chunks detected as not English
+// from which its summary suggests a multilingual model.
+const nonEnglishWarn = 0.1
+
+// writeLanguages warns that a notable share of chunks, broken down by
+// language in langs, is not English, which the default model embeds
+// poorly.
+func writeLanguages(w io.Writer, langs map[string]int, chunks int) {
+	other := chunks - langs[lang.English]
+	if chunks == 0 || len(langs) == 0 || float64(other) < nonEnglishWarn*float64(chunks) {
+		return
+	}
+	fmt.Fprintf(w, "warning: %.0f%% of chunks (%d of %d) were detected as non-English (%s); the default model is English-only, consider a multilingual one\n",
+		100*float64(other)/float64(chunks), other, chunks, languageShares(langs, chunks, false))
+}
+
+// languageShares lists each language's share of chunks, largest first,
+// leaving English out unless withEnglish: "de 15%, ja 8%".
+func languageShares(langs map[string]int, chunks int, withEnglish bool) string {
+	codes := slices.Collect(maps.Keys(langs))
+	slices.SortFunc(codes, func(a, b string) int {
+		return cmp.Or(cmp.Compare(langs[b], langs[a]), cmp.Compare(a, b))
+	})
+	var parts []string
+	for _, l := range codes {
+		if l == lang.English && !withEnglish {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %.0f%%", l, 100*float64(langs[l])/float64(chunks)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeEmbedFailures lists the sample of n embedding failures, if any.
+func writeEmbedFailures(w io.Writer, n int, sample []embedFailure) {
+	if n == 0 {
+		return
+	}
+	fmt.Fprintf(w, "embedding failed for %d files:\n", n)
+	for _, f := range sample {
+		fmt.Fprintf(w, "  %s: %s\n", f.Path, f.Error)
+	}
+	if more := n - len(sample); more > 0 {
+		fmt.Fprintf(w, "  … and %d more\n", more)
+	}
+}
+
+// appendRunRecord appends r as one JSON line to dir's runs.jsonl.
+func appendRunRecord(dir string, r runReport) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("record run: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, index.RunsFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("record run: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("record run: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/protocol"
+)
+
+func init() {
+	schemaCmd := &cobra.Command{
+		Use:   "schema [type...]",
+		Short: "Print the JSON Schema of sift's NDJSON messages",
+		Long: `Print the JSON Schema (draft 2020-12) of the messages sift writes for other
+programs: the --progress=json events of index, rebuild and watch, the serve
+socket's responses and the MCP tool results. Every message is an envelope
+{"v":1,"type":"...","data":{...}}; the schema covers the envelope and its
+payload.
+
+Without arguments, prints every type's schema in one object keyed by type;
+with one type, that schema alone, ready for a validator; with several, an
+object of those.`,
+		Example: `  sift schema
+  sift schema index.file > index.file.schema.json`,
+		ValidArgsFunction: func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			names := make([]string, len(protocol.Types))
+			for i, t := range protocol.Types {
+				names[i] = t.Name + "\t" + t.Doc
+			}
+			return names, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return writeSchemas(cmd.OutOrStdout(), args)
+		},
+	}
+	rootCmd.AddCommand(schemaCmd)
+}
+
+// writeSchemas writes the schemas of the named message types to w, or of
+// all of them when names is empty.
+func writeSchemas(w io.Writer, names []string) error {
+	if len(names) == 0 {
+		for _, t := range protocol.Types {
+			names = append(names, t.Name)
+		}
+	}
+	schemas := make(map[string]any, len(names))
+	for _, name := range names {
+		s, ok := protocol.Schema(name)
+		if !ok {
+			known := make([]string, len(protocol.Types))
+			for i, t := range protocol.Types {
+				known[i] = t.Name
+			}
+			return &usageError{fmt.Errorf("unknown message type %q (want one of %s)", name, strings.Join(known, ", "))}
+		}
+		schemas[name] = s
+	}
+	var out any = schemas
+	if len(names) == 1 {
+		out = schemas[names[0]]
+	}
+	j, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal json: %w", err)
+	}
+	fmt.Fprintln(w, string(j))
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/tejas242/sift/internal/protocol"
+)
+
+func TestSchemaCmd(t *testing.T) {
+	run := func(args ...string) (map[string]any, error) {
+		t.Helper()
+		cmd := findCmd(t, "schema")
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		defer cmd.SetOut(nil)
+		if err := cmd.RunE(cmd, args); err != nil {
+			return nil, err
+		}
+		var got map[string]any
+		if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, out.String())
+		}
+		return got, nil
+	}
+
+	all, err := run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(protocol.Types) {
+		t.Errorf("got %d schemas, want one per type (%d)", len(all), len(protocol.Types))
+	}
+	for _, typ := range protocol.Types {
+		s, ok := all[typ.Name].(map[string]any)
+		if !ok || s["title"] != typ.Name {
+			t.Errorf("schema of %s = %v", typ.Name, all[typ.Name])
+		}
+	}
+
+	// One type prints its schema alone, several an object of them.
+	one, err := run("index.file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if one["title"] != "index.file" || one["$schema"] != protocol.SchemaDialect {
+		t.Errorf("schema index.file = %v", one)
+	}
+	if two, err := run("stats", "error"); err != nil || len(two) != 2 || two["stats"] == nil {
+		t.Errorf("schema stats error = %v, %v", two, err)
+	}
+
+	if _, err := run("index.pause"); exitCode(err) != exitUsage {
+		t.Errorf("unknown type: err = %v (exit %d), want a usage error", err, exitCode(err))
+	}
+}
@@ -1,60 +1,467 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/config"
+	"github.com/tejas242/sift/internal/embed"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/server"
 )
 
 var (
 	jsonExport bool
 	topK       int
+	noDaemon   bool
+	fromStdin  bool
+	fullText   bool
+	ndjson     bool
+
+	searchFormat  string
+	searchColumns string
+
+	rerank    bool
+	rerankTop int
+
+	// searchIn is --in: the directories to search within, as given.
+	searchIn []string
+	// searchTypes is --type: the file categories to search, as given.
+	searchTypes []string
+	// perFile is --per-file; see index.Filter.MaxPerFile.
+	perFile int
+	// withAdjacent is --with-adjacent: how many chunks before and after
+	// each hit its JSON record carries.
+	withAdjacent int
+
+	// searchDirs is --dirs: rank directories rather than files.
+	searchDirs bool
+
+	// threshold is --threshold; it applies only when the flag is set.
+	threshold float64
+	// searchTimeout is --timeout: how long a search may take; 0 is no limit.
+	searchTimeout time.Duration
+	// reindexStale is --reindex-stale: re-index the files of stale results
+	// and search again.
+	reindexStale bool
 )
 
 func init() {
 	searchCmd := &cobra.Command{
-		Use:   "search <query>",
-		Short: "Non-interactive semantic search",
-		Args:  cobra.MinimumNArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			query := strings.Join(args, " ")
+		Use:     "search <query>",
+		Aliases: []string{"query"},
+		Short:   "Non-interactive semantic search",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if fromStdin {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.MinimumNArgs(1)(cmd, args)
+		},
+		RunE: runSearchCmd,
+	}
+	addSearchFlags(searchCmd)
+	rootCmd.AddCommand(searchCmd)
+}
+
+// runSearchCmd is search's RunE, shared with the root command.
+func runSearchCmd(cmd *cobra.Command, args []string) error {
+	var cols []hitColumn
+	asJSON, asNDJSON := jsonExport, ndjson
+	switch searchFormat {
+	case "", "text":
+		if cmd.Flags().Changed("columns") {
+			return &usageError{fmt.Errorf("--columns needs --format csv")}
+		}
+	case "json":
+		asJSON = true
+	case "ndjson":
+		asNDJSON = true
+	case "csv":
+		if fromStdin {
+			return &usageError{fmt.Errorf("--stdin always writes NDJSON; drop --format csv")}
+		}
+		var err error
+		if cols, err = selectHitColumns(searchColumns); err != nil {
+			return &usageError{fmt.Errorf("--columns: %w", err)}
+		}
+	default:
+		return &usageError{fmt.Errorf("--format: want text, json, ndjson or csv, got %q", searchFormat)}
+	}
+	if perFile < 1 {
+		return &usageError{fmt.Errorf("--per-file must be at least 1, got %d", perFile)}
+	}
+	switch {
+	case withAdjacent < 0:
+		return &usageError{fmt.Errorf("--with-adjacent must be at least 0, got %d", withAdjacent)}
+	case withAdjacent > 0 && fromStdin:
+		return &usageError{fmt.Errorf("--with-adjacent does not apply to --stdin")}
+	case withAdjacent > 0 && !fullText && !asNDJSON:
+		return &usageError{fmt.Errorf("--with-adjacent needs --full-text or --ndjson")}
+	}
+	if rerank {
+		switch {
+		case fromStdin:
+			return &usageError{fmt.Errorf("--rerank does not apply to --stdin")}
+		case rerankTop < topK:
+			return &usageError{fmt.Errorf("--rerank-top (%d) must be at least --top-k (%d)", rerankTop, topK)}
+		}
+	}
+	switch {
+	case searchTimeout < 0:
+		return &usageError{fmt.Errorf("--timeout must not be negative, got %s", searchTimeout)}
+	case searchTimeout > 0 && fromStdin:
+		return &usageError{fmt.Errorf("--timeout does not apply to --stdin")}
+	}
+	if reindexStale && (fromStdin || searchDirs) {
+		return &usageError{fmt.Errorf("--reindex-stale does not apply to --stdin or --dirs")}
+	}
+	if searchDirs {
+		switch {
+		case fromStdin, rerank, fullText, asNDJSON, cols != nil, withAdjacent > 0:
+			return &usageError{fmt.Errorf("--dirs writes text or JSON, and takes none of --stdin, --rerank, --full-text, --ndjson, --format csv or --with-adjacent")}
+		case cmd.Flags().Changed("threshold"), cmd.Flags().Changed("per-file"):
+			return &usageError{fmt.Errorf("--threshold and --per-file do not apply to --dirs")}
+		}
+	}
+	keep := func(results []index.SearchResult) []index.SearchResult { return results }
+	if cmd.Flags().Changed("threshold") {
+		keep = func(results []index.SearchResult) []index.SearchResult {
+			return aboveThreshold(results, float32(threshold))
+		}
+	}
+	if fromStdin {
+		return runStdinSearch(keep)
+	}
+	// Checked here so an empty query is not sent to a daemon first.
+	query, types := index.SplitTypes(index.NormalizeQuery(strings.Join(args, " ")))
+	if query == "" {
+		return index.ErrEmptyQuery
+	}
 
-			idx, err := openIndex(ortLib)
+	ctx := cmd.Context()
+	if searchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, searchTimeout)
+		defer cancel()
+	}
+	if searchDirs {
+		err := runDirSearch(ctx, cmd.OutOrStdout(), query, types, asJSON)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("search timed out after %s (--timeout)", searchTimeout)
+		}
+		return err
+	}
+	results, err := runSearch(ctx, query, types)
+	if err == nil {
+		if stale := index.MarkStale(results); len(stale) > 0 && reindexStale {
+			if err := reindexFiles(ctx, stale); err != nil {
+				return err
+			}
+			// In-process: a daemon may not have reloaded the index yet.
+			if results, err = searchWith(ctx, query, types, false); err == nil {
+				index.MarkStale(results)
+			}
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("search timed out after %s (--timeout)", searchTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	results = keep(results)
+	if cols != nil {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("getwd: %w", err)
+		}
+		if err := writeSearchCSV(cmd.OutOrStdout(), results, cwd, cols); err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			return errNoResults
+		}
+		return nil
+	}
+	if fullText || asNDJSON {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("getwd: %w", err)
+		}
+		var adjacent adjacentFunc
+		if withAdjacent > 0 && len(results) > 0 {
+			idx, err := index.OpenMeta(indexDir)
 			if err != nil {
 				return err
 			}
 			defer idx.Close()
+			adjacent = func(id string) (before, after []index.ChunkMeta) {
+				return idx.AdjacentChunks(id, withAdjacent)
+			}
+		}
+		if err := writeSearchHits(cmd.OutOrStdout(), results, cwd, fullText, asNDJSON, adjacent); err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			return errNoResults
+		}
+		return nil
+	}
+	if len(results) == 0 {
+		if asJSON {
+			fmt.Println("[]")
+		} else {
+			fmt.Println("no results")
+		}
+		return errNoResults
+	}
+	if asJSON {
+		j, err := json.MarshalIndent(jsonResults(results), "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal json: %w", err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(j))
+		return nil
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("getwd: %w", err)
+	}
+	writeResults(cmd.OutOrStdout(), query, results, cwd, searchIn)
+	return nil
+}
 
-			results, err := idx.Search(query, topK)
-			if err != nil {
-				return err
+// addSearchFlags registers search's flags on cmd, which is search itself or
+// the root command (`sift <query>`).
+func addSearchFlags(cmd *cobra.Command) {
+	f := cmd.Flags()
+	f.BoolVar(&jsonExport, "json", false, "output search results as JSON")
+	f.BoolVar(&fullText, "full-text", false, "JSON output including the complete chunk text, byte offsets, and score breakdown")
+	f.BoolVar(&ndjson, "ndjson", false, "stream results as NDJSON, one hit per line (add --full-text for chunk text)")
+	f.StringVar(&searchFormat, "format", "", "output format: text, json, ndjson or csv")
+	f.StringVar(&searchColumns, "columns", defaultHitColumns, "comma-separated fields for --format csv (also: abs_path, rel_path, chunk_index, id, start_byte, end_byte, vector, keyword, matched, matched_tokens, rerank, stale, calibrated, text)")
+	f.BoolVar(&plainOutput, "plain", false, "plain one-result-per-entry output even on a terminal")
+	f.IntVar(&topK, "top-k", 10, "number of results to return")
+	f.IntVar(&topK, "top", 10, "alias for --top-k")
+	f.IntVar(&perFile, "per-file", 1, "results to return per file; chunks overlapping a better one of the same file are left out")
+	f.IntVar(&withAdjacent, "with-adjacent", 0, "with --full-text or --ndjson, add this many chunks before and after each hit from its file")
+	f.StringArrayVar(&searchIn, "in", nil, "only search files under this directory, relative to the working directory (repeatable)")
+	f.StringArrayVar(&searchTypes, "type", nil, `only search files of these categories, comma-separated or repeated: "tests", "docs", "config", "source", "other" or one the categories setting names; "type:tests" in the query does the same`)
+	f.BoolVar(&searchDirs, "dirs", false, "rank the directories most relevant to the query instead of files, each with its best file")
+	f.BoolVar(&fromStdin, "stdin", false, "read one query per line from stdin and write NDJSON results")
+	cmd.MarkFlagsMutuallyExclusive("format", "json")
+	cmd.MarkFlagsMutuallyExclusive("format", "ndjson")
+	f.BoolVar(&rerank, "rerank", false, "rescore the top candidates with the cross-encoder in <model-dir>/reranker (`make download-reranker`)")
+	f.IntVar(&rerankTop, "rerank-top", 50, "number of vector search candidates --rerank rescores")
+	f.Float64Var(&threshold, "threshold", 0, "drop results scoring below this; compares the 0–1 calibrated score when the calibrate setting is on")
+	f.DurationVar(&searchTimeout, "timeout", 0, "give up on a search taking longer than this, e.g. 5s (default no limit)")
+	f.BoolVar(&reindexStale, "reindex-stale", false, "re-index the files of results modified since they were indexed, then search again")
+	f.BoolVar(&noDaemon, "no-daemon", false, "search in-process even when a daemon (`sift daemon start`) is running")
+	f.Bool("via-socket", false, "")
+	f.MarkDeprecated("via-socket", "a running daemon or `sift serve` is now used automatically")
+}
+
+// runSearch answers query, in files of types besides those of --type,
+// through the daemon (or `sift serve`) listening on the index's socket
+// when one is reachable, and in-process otherwise, including when the
+// daemon fails to answer. A deadline on ctx bounds the daemon's answer
+// too.
+func runSearch(ctx context.Context, query string, types []string) ([]index.SearchResult, error) {
+	return searchWith(ctx, query, types, !noDaemon)
+}
+
+// searchWith is runSearch, trying the daemon only when daemon is set.
+func searchWith(ctx context.Context, query string, types []string, daemon bool) ([]index.SearchResult, error) {
+	f, err := searchFilter(types)
+	if err != nil {
+		return nil, err
+	}
+	if rerank {
+		return runRerankSearch(ctx, query, f)
+	}
+	if daemon {
+		if c, err := server.Dial(server.DefaultSocketPath(indexDir)); err == nil {
+			if deadline, ok := ctx.Deadline(); ok {
+				c.SetTimeout(time.Until(deadline))
 			}
-			if len(results) == 0 {
-				if jsonExport {
-					fmt.Println("[]")
-				} else {
-					fmt.Println("no results")
-				}
-				return nil
-			}
-			if jsonExport {
-				j, err := json.MarshalIndent(results, "", "  ")
-				if err != nil {
-					return fmt.Errorf("marshal json: %w", err)
-				}
-				fmt.Println(string(j))
-				return nil
-			}
-			for i, r := range results {
-				fmt.Printf("%2d  %.3f  %s:%d\n    %s\n\n",
-					i+1, r.Score, r.Meta.Path, r.Meta.LineNum, r.Meta.Text)
-			}
-			return nil
-		},
+			results, err := c.Search(query, topK, f)
+			c.Close()
+			if err == nil {
+				return results, nil
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			logger.Debugf("daemon search failed, searching in-process: %v", err)
+		}
 	}
-	searchCmd.Flags().BoolVar(&jsonExport, "json", false, "output search results as JSON")
-	searchCmd.Flags().IntVar(&topK, "top-k", 10, "number of results to return")
-	rootCmd.AddCommand(searchCmd)
+
+	idx, err := openExistingIndex(ortLib)
+	if err != nil {
+		return nil, err
+	}
+	defer idx.Close()
+	return idx.SearchFiltered(ctx, query, topK, f)
+}
+
+// searchFilter returns the filter the search flags select, searching
+// files of types (from "type:" terms in the query) too. --in directories
+// are made absolute against the working directory, where project root
+// discovery starts too, so `--in .` in a subdirectory of the project
+// searches that subdirectory.
+func searchFilter(types []string) (index.Filter, error) {
+	f := index.Filter{MaxPerDir: maxPerDir, MaxPerFile: perFile}
+	for _, d := range searchIn {
+		abs, err := filepath.Abs(d)
+		if err != nil {
+			return f, &usageError{fmt.Errorf("--in %s: %w", d, err)}
+		}
+		f.Dirs = append(f.Dirs, abs)
+	}
+	for _, v := range searchTypes {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.ToLower(strings.TrimSpace(t)); t != "" && !slices.Contains(f.Types, t) {
+				f.Types = append(f.Types, t)
+			}
+		}
+	}
+	for _, t := range types {
+		if !slices.Contains(f.Types, t) {
+			f.Types = append(f.Types, t)
+		}
+	}
+	return f, nil
+}
+
+// runDirSearch answers query with the best topK directories (see
+// index.SearchDirs) among files of types, in-process as the daemon does
+// not rank directories, and writes them to w as text or JSON.
+func runDirSearch(ctx context.Context, w io.Writer, query string, types []string, asJSON bool) error {
+	f, err := searchFilter(types)
+	if err != nil {
+		return err
+	}
+	idx, err := openExistingIndex(ortLib)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	dirs, err := idx.SearchDirs(ctx, query, topK, f)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		if dirs == nil {
+			dirs = []index.DirResult{}
+		}
+		j, err := json.MarshalIndent(dirs, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal json: %w", err)
+		}
+		fmt.Fprintln(w, string(j))
+	} else {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("getwd: %w", err)
+		}
+		writeDirResults(w, dirs, cwd)
+	}
+	if len(dirs) == 0 {
+		return errNoResults
+	}
+	return nil
+}
+
+// writeDirResults prints ranked directories in the plain result format,
+// each with how many of its files matched and its best one.
+func writeDirResults(w io.Writer, dirs []index.DirResult, cwd string) {
+	if len(dirs) == 0 {
+		fmt.Fprintln(w, "no results")
+		return
+	}
+	for i, d := range dirs {
+		fmt.Fprintf(w, "%2d  %.3f  %s/  %d of %d files\n    best: %s:%d\n\n",
+			i+1, d.Score, strings.TrimSuffix(displayPath(cwd, d.Dir), "/"), d.Matched, d.Files,
+			displayPath(cwd, d.Top.Meta.Path), d.Top.Meta.LineNum)
+	}
+}
+
+// newRerankerFunc loads the reranker; tests replace it to avoid the model.
+var newRerankerFunc = func() (index.Reranker, error) {
+	return embed.NewReranker(filepath.Join(modelDir, embed.RerankerSubdir), config.ResolveOrtLib(ortLib), numThreads)
+}
+
+// runRerankSearch fetches rerankTop candidates matching f in-process (the
+// daemon has no reranker), rescores them, and keeps the best topK. How
+// long each stage took is logged, as the reranker is by far the slower one.
+func runRerankSearch(ctx context.Context, query string, f index.Filter) ([]index.SearchResult, error) {
+	rr, err := newRerankerFunc()
+	if err != nil {
+		return nil, err
+	}
+	idx, err := openExistingIndex(ortLib)
+	if err != nil {
+		rr.Close()
+		return nil, err
+	}
+	defer idx.Close()
+	idx.SetReranker(rr)
+
+	start := time.Now()
+	candidates, err := idx.SearchFiltered(ctx, query, rerankTop, f)
+	if err != nil {
+		return nil, err
+	}
+	searched := time.Now()
+	results, err := idx.Rerank(ctx, query, candidates)
+	if err != nil {
+		return nil, err
+	}
+	logger.Infof("search %v, rerank %v (%d candidates)", searched.Sub(start).Round(time.Millisecond),
+		time.Since(searched).Round(time.Millisecond), len(candidates))
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+// aboveThreshold returns the results whose ThresholdScore is at least floor.
+// Results are sorted best first, so it cuts at the first one below.
+func aboveThreshold(results []index.SearchResult, floor float32) []index.SearchResult {
+	for i, r := range results {
+		if r.ThresholdScore() < floor {
+			return results[:i]
+		}
+	}
+	return results
+}
+
+// runStdinSearch answers every line of stdin as a query with a single loaded
+// model, writing NDJSON in input order; keep filters each query's results.
+func runStdinSearch(keep func([]index.SearchResult) []index.SearchResult) error {
+	f, err := searchFilter(nil)
+	if err != nil {
+		return err
+	}
+	idx, err := openExistingIndex(ortLib)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	return writeBatchNDJSON(os.Stdin, os.Stdout, func(queries []string) []index.BatchResult {
+		out := idx.SearchBatchFiltered(queries, topK, f)
+		for i := range out {
+			out[i].Results = keep(out[i].Results)
+		}
+		return out
+	})
 }
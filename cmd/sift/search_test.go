@@ -0,0 +1,759 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tejas242/sift/internal/embed"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/throttle"
+)
+
+type mockEmbedder struct{}
+
+func (m *mockEmbedder) Embed(texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i := range texts {
+		v := make([]float32, 384)
+		v[0] = 1.0
+		vecs[i] = v
+	}
+	return vecs, nil
+}
+
+func (m *mockEmbedder) EmbedQuery(query string) ([]float32, error) {
+	v := make([]float32, 384)
+	v[0] = 1.0
+	return v, nil
+}
+
+func (m *mockEmbedder) Close() {}
+
+func TestWriteBatchNDJSON(t *testing.T) {
+	dir := t.TempDir()
+	idx := index.NewTestIndex(filepath.Join(dir, ".sift"), &mockEmbedder{})
+	for name, body := range map[string]string{
+		"vpn.md":    "wireguard config lives here",
+		"backup.md": "homelab backup schedule runs nightly",
+	} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	in, err := os.Open(filepath.Join("testdata", "queries.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	var out bytes.Buffer
+	calls := 0
+	err = writeBatchNDJSON(in, &out, func(queries []string) []index.BatchResult {
+		calls++
+		return idx.SearchBatch(queries, 1)
+	})
+	if err != nil {
+		t.Fatalf("writeBatchNDJSON: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("search called %d times, want 1 batch", calls)
+	}
+
+	want := []struct {
+		query string
+		isErr bool
+	}{
+		{"wireguard config", false},
+		{"", true},
+		{"", true},
+		{"homelab backup schedule", false},
+		{"wireguard config", false},
+	}
+	sc := bufio.NewScanner(&out)
+	n := 0
+	for sc.Scan() {
+		var line batchLine
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("line %d is not JSON: %v\n%s", n+1, err, sc.Text())
+		}
+		if n >= len(want) {
+			t.Fatalf("unexpected extra line: %s", sc.Text())
+		}
+		w := want[n]
+		if line.Query != w.query {
+			t.Errorf("line %d: query = %q, want %q", n+1, line.Query, w.query)
+		}
+		if w.isErr {
+			if line.Error == "" || len(line.Results) != 0 {
+				t.Errorf("line %d: want error and no results, got %+v", n+1, line)
+			}
+		} else {
+			if line.Error != "" {
+				t.Errorf("line %d: unexpected error %q", n+1, line.Error)
+			}
+			if len(line.Results) != 1 {
+				t.Errorf("line %d: got %d results, want 1 (--top)", n+1, len(line.Results))
+			}
+		}
+		n++
+	}
+	if n != len(want) {
+		t.Errorf("got %d lines, want %d", n, len(want))
+	}
+}
+
+// wordReranker scores a passage by the weight of the first of its words
+// it finds.
+type wordReranker struct {
+	weights map[string]float32
+	closed  bool
+}
+
+func (r *wordReranker) Rerank(query string, passages []string) ([]float32, error) {
+	scores := make([]float32, len(passages))
+	for i, p := range passages {
+		for w, s := range r.weights {
+			if strings.Contains(p, w) {
+				scores[i] = s
+			}
+		}
+	}
+	return scores, nil
+}
+
+func (r *wordReranker) Close() { r.closed = true }
+
+func TestRunRerankSearch(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	for name, body := range map[string]string{
+		"vpn.md":    "homelab wireguard config lives here",
+		"backup.md": "backup schedule runs nightly",
+		"notes.md":  "misc notes",
+	} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	openIndexFunc = func(index.Options) (*index.Index, error) { return idx, nil }
+	oldNew, oldRerank, oldTop, oldK, oldModel := newRerankerFunc, rerank, rerankTop, topK, modelDir
+	t.Cleanup(func() {
+		newRerankerFunc, rerank, rerankTop, topK, modelDir = oldNew, oldRerank, oldTop, oldK, oldModel
+	})
+	rr := &wordReranker{weights: map[string]float32{"backup": 5, "wireguard": 1}}
+	newRerankerFunc = func() (index.Reranker, error) { return rr, nil }
+	rerank, rerankTop, topK = true, 10, 2
+
+	// The keyword boost puts vpn.md first; the reranker prefers backup.md.
+	results, err := runSearch(context.Background(), "homelab wireguard", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || filepath.Base(results[0].Meta.Path) != "backup.md" || filepath.Base(results[1].Meta.Path) != "vpn.md" {
+		t.Fatalf("reranked results = %+v, want backup.md then vpn.md", results)
+	}
+	if r := results[0]; r.Rerank == nil || *r.Rerank != 5 || r.Score != 5 {
+		t.Errorf("backup.md: Rerank %v, Score %v; want 5 and 5", r.Rerank, r.Score)
+	}
+	if h := newSearchHit(1, results[1], dir, false); h.Scores.Rerank == nil || *h.Scores.Rerank != 1 || h.Scores.Keyword == 0 {
+		t.Errorf("JSON scores = %+v, want rerank 1 alongside the keyword boost", h.Scores)
+	}
+	if !rr.closed {
+		t.Error("reranker not closed with the index")
+	}
+
+	// Without the model, --rerank fails as a model error pointing at the download.
+	newRerankerFunc, modelDir = oldNew, t.TempDir()
+	_, err = runSearch(context.Background(), "homelab", nil)
+	if !errors.Is(err, embed.ErrRerankerMissing) || exitCode(err) != exitModel {
+		t.Fatalf("missing reranker: err = %v (exit %d), want ErrRerankerMissing, exit %d", err, exitCode(err), exitModel)
+	}
+	if code, hint := errorCode(err); code != codeModelMissing || !strings.Contains(hint, "download-reranker") {
+		t.Errorf("errorCode = %q, %q", code, hint)
+	}
+}
+
+func TestSearchIn(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	for _, name := range []string{"docs/vpn.md", "docs/old/vpn.md", "src/vpn.go"} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("wireguard"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	openIndexFunc = func(index.Options) (*index.Index, error) { return idx, nil }
+	oldIn, oldNoDaemon := searchIn, noDaemon
+	t.Cleanup(func() { searchIn, noDaemon = oldIn, oldNoDaemon })
+	noDaemon = true
+
+	// Relative directories resolve against the working directory.
+	t.Chdir(filepath.Join(dir, "docs"))
+	for _, tc := range []struct {
+		in   []string
+		want []string
+	}{
+		{[]string{"."}, []string{"docs/old/vpn.md", "docs/vpn.md"}},
+		{[]string{"old"}, []string{"docs/old/vpn.md"}},
+		{[]string{"old", "../src"}, []string{"docs/old/vpn.md", "src/vpn.go"}},
+		{[]string{filepath.Join(dir, "src")}, []string{"src/vpn.go"}},
+	} {
+		searchIn = tc.in
+		results, err := runSearch(context.Background(), "wireguard", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range results {
+			rel, _ := filepath.Rel(dir, r.Meta.Path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("--in %q: got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+
+	// A scope holding no indexed file finds nothing, with its exit code.
+	search := findCmd(t, "search")
+	searchIn = []string{"../notes"}
+	if err := search.RunE(search, []string{"wireguard"}); exitCode(err) != exitNoResults {
+		t.Errorf("--in ../notes: exit code %d (err %v), want %d", exitCode(err), err, exitNoResults)
+	}
+}
+
+func TestSearchPaths(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	for _, name := range []string{"docs/vpn.md", "src/vpn.go"} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("wireguard"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	openIndexFunc = func(index.Options) (*index.Index, error) { return idx, nil }
+	oldRoot, oldAbs, oldPlain, oldJSON, oldNDJSON, oldNoDaemon := projectRoot, absPaths, plainOutput, jsonExport, ndjson, noDaemon
+	t.Cleanup(func() {
+		projectRoot, absPaths, plainOutput, jsonExport, ndjson, noDaemon = oldRoot, oldAbs, oldPlain, oldJSON, oldNDJSON, oldNoDaemon
+	})
+	projectRoot, plainOutput, noDaemon = dir, true, true
+	t.Chdir(filepath.Join(dir, "docs"))
+
+	search := findCmd(t, "search")
+	search.SetContext(context.Background())
+	var out bytes.Buffer
+	search.SetOut(&out)
+	t.Cleanup(func() { search.SetOut(nil) })
+	run := func() string {
+		t.Helper()
+		out.Reset()
+		if err := search.RunE(search, []string{"wireguard"}); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	abs := func(name string) string { return filepath.ToSlash(filepath.Join(dir, filepath.FromSlash(name))) }
+
+	// Text names files relative to the working directory, or whole with
+	// --abs.
+	for _, tc := range []struct {
+		abs  bool
+		want []string
+	}{
+		{false, []string{"  vpn.md:1", "  ../src/vpn.go:1"}},
+		{true, []string{"  " + abs("docs/vpn.md") + ":1", "  " + abs("src/vpn.go") + ":1"}},
+	} {
+		absPaths = tc.abs
+		got := run()
+		for _, w := range tc.want {
+			if !strings.Contains(got, w) {
+				t.Errorf("--abs=%v: output lacks %q:\n%s", tc.abs, w, got)
+			}
+		}
+	}
+
+	// JSON carries the root-relative path and the absolute one either way.
+	jsonExport = true
+	var results []struct {
+		Meta    struct{ Path string }
+		AbsPath string `json:"abs_path"`
+	}
+	if err := json.Unmarshal([]byte(run()), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("--json: %d results, want 2", len(results))
+	}
+	for _, r := range results {
+		if abs(r.Meta.Path) != filepath.ToSlash(r.AbsPath) || !slices.Contains([]string{"docs/vpn.md", "src/vpn.go"}, r.Meta.Path) {
+			t.Errorf("--json: path %q, abs_path %q", r.Meta.Path, r.AbsPath)
+		}
+	}
+	jsonExport, ndjson = false, true
+	for line := range strings.Lines(run()) {
+		var h searchHit
+		if err := json.Unmarshal([]byte(line), &h); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{"docs/vpn.md": "vpn.md", "src/vpn.go": abs("src/vpn.go")}
+		if rel, ok := want[h.Path]; !ok || abs(h.Path) != filepath.ToSlash(h.AbsPath) || filepath.ToSlash(h.RelPath) != rel {
+			t.Errorf("--ndjson: path %q, abs_path %q, rel_path %q", h.Path, h.AbsPath, h.RelPath)
+		}
+	}
+}
+
+func TestSearchStale(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	vpn, tea := filepath.Join(dir, "vpn.md"), filepath.Join(dir, "tea.md")
+	for _, p := range []string{vpn, tea} {
+		if err := os.WriteFile(p, []byte("wireguard"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	openIndexFunc = func(index.Options) (*index.Index, error) {
+		idx.SetReadOnly(false) // as opened afresh, after searches set it
+		return idx, nil
+	}
+	oldPlain, oldJSON, oldNDJSON, oldNoDaemon := plainOutput, jsonExport, ndjson, noDaemon
+	t.Cleanup(func() {
+		plainOutput, jsonExport, ndjson, noDaemon, reindexStale = oldPlain, oldJSON, oldNDJSON, oldNoDaemon, false
+	})
+	plainOutput, noDaemon = true, true
+	t.Chdir(dir)
+
+	// vpn.md changes after it was indexed.
+	if err := os.WriteFile(vpn, []byte("wireguard tunnel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(vpn, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	search := findCmd(t, "search")
+	search.SetContext(context.Background())
+	var out bytes.Buffer
+	search.SetOut(&out)
+	t.Cleanup(func() { search.SetOut(nil) })
+	run := func() string {
+		t.Helper()
+		out.Reset()
+		if err := search.RunE(search, []string{"wireguard"}); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	got := run()
+	if !strings.Contains(got, "vpn.md:1  "+staleTag+"\n") || !strings.Contains(got, "tea.md:1\n") {
+		t.Errorf("text output does not tag vpn.md alone:\n%s", got)
+	}
+	ndjson = true
+	for line := range strings.Lines(run()) {
+		var h searchHit
+		if err := json.Unmarshal([]byte(line), &h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Stale != (h.Path == "vpn.md") {
+			t.Errorf("--ndjson: %s stale = %v", h.Path, h.Stale)
+		}
+	}
+	ndjson, jsonExport = false, true
+	var results []index.SearchResult
+	if err := json.Unmarshal([]byte(run()), &results); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Stale != (r.Meta.Path == "vpn.md") {
+			t.Errorf("--json: %s stale = %v", r.Meta.Path, r.Stale)
+		}
+	}
+
+	// --reindex-stale brings the file up to date before showing it.
+	jsonExport, reindexStale = false, true
+	if got := run(); strings.Contains(got, staleTag) || !strings.Contains(got, "wireguard tunnel") {
+		t.Errorf("--reindex-stale: output still stale:\n%s", got)
+	}
+}
+
+func TestSearchType(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	for _, name := range []string{"docs/vpn.md", "src/vpn.go", "src/vpn_test.go", "vpn.toml"} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("wireguard"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	openIndexFunc = func(index.Options) (*index.Index, error) { return idx, nil }
+	oldTypes, oldNoDaemon := searchTypes, noDaemon
+	t.Cleanup(func() { searchTypes, noDaemon = oldTypes, oldNoDaemon })
+	noDaemon = true
+
+	// --type and "type:" terms in the query add up.
+	for _, tc := range []struct {
+		flag, query []string
+		want        []string
+	}{
+		{[]string{"tests"}, nil, []string{"src/vpn_test.go"}},
+		{[]string{"Docs, config"}, nil, []string{"docs/vpn.md", "vpn.toml"}},
+		{[]string{"docs"}, []string{"tests"}, []string{"docs/vpn.md", "src/vpn_test.go"}},
+		{nil, []string{"source"}, []string{"src/vpn.go"}},
+	} {
+		searchTypes = tc.flag
+		results, err := runSearch(context.Background(), "wireguard", tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range results {
+			rel, _ := filepath.Rel(dir, r.Meta.Path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("--type %q, type: %q: got %q, want %q", tc.flag, tc.query, got, tc.want)
+		}
+	}
+
+	// A category holding no file finds nothing, with its exit code, and a
+	// query of "type:" terms alone is empty.
+	search := findCmd(t, "search")
+	search.SetContext(context.Background())
+	searchTypes = nil
+	if err := search.RunE(search, []string{"wireguard", "type:bench"}); exitCode(err) != exitNoResults {
+		t.Errorf("type:bench: exit code %d (err %v), want %d", exitCode(err), err, exitNoResults)
+	}
+	if err := search.RunE(search, []string{"type:tests"}); !errors.Is(err, index.ErrEmptyQuery) {
+		t.Errorf("type:tests alone: err = %v, want ErrEmptyQuery", err)
+	}
+}
+
+func TestSearchDirs(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	for _, name := range []string{"docs/vpn.md", "docs/tunnel.md", "src/vpn.go"} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("wireguard"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	openIndexFunc = func(index.Options) (*index.Index, error) { return idx, nil }
+	oldDirs, oldJSON := searchDirs, jsonExport
+	t.Cleanup(func() { searchDirs, jsonExport = oldDirs, oldJSON })
+	searchDirs = true
+	t.Chdir(dir)
+
+	search := findCmd(t, "search")
+	search.SetContext(context.Background())
+	var out bytes.Buffer
+	search.SetOut(&out)
+	t.Cleanup(func() { search.SetOut(nil) })
+	if err := search.RunE(search, []string{"wireguard"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out.String(), "\n")
+	if len(lines) < 5 || !strings.Contains(lines[0], "docs/  2 of 2 files") || !strings.Contains(lines[3], "src/  1 of 1 files") ||
+		!strings.Contains(lines[4], "best: src/vpn.go:1") {
+		t.Errorf("output:\n%s\nwant docs/ then src/, each with its best file", out.String())
+	}
+
+	out.Reset()
+	jsonExport = true
+	if err := search.RunE(search, []string{"wireguard"}); err != nil {
+		t.Fatal(err)
+	}
+	var dirs []index.DirResult
+	if err := json.Unmarshal(out.Bytes(), &dirs); err != nil || len(dirs) != 2 || dirs[0].Dir != filepath.Join(dir, "docs") {
+		t.Errorf("--json: %v, %+v; want docs/ then src/", err, dirs)
+	}
+
+	fromStdin = true
+	t.Cleanup(func() { fromStdin = false })
+	if err := search.RunE(search, nil); exitCode(err) != exitUsage {
+		t.Errorf("--dirs --stdin: %v, want a usage error", err)
+	}
+}
+
+func TestSearchEmptyQuery(t *testing.T) {
+	siftDir := filepath.Join(t.TempDir(), ".sift")
+	setGlobals(t, siftDir)
+	openIndexFunc = func(index.Options) (*index.Index, error) {
+		t.Fatal("an empty query opened the index")
+		return nil, nil
+	}
+	search := findCmd(t, "search")
+	for _, args := range [][]string{{""}, {"   "}, {" ", "\t"}} {
+		err := search.RunE(search, args)
+		if !errors.Is(err, index.ErrEmptyQuery) || exitCode(err) != exitUsage {
+			t.Errorf("search %q: %v, exit code %d; want ErrEmptyQuery, %d", args, err, exitCode(err), exitUsage)
+		}
+		if code, _ := errorCode(err); code != codeUsage {
+			t.Errorf("search %q: error code %s, want %s", args, code, codeUsage)
+		}
+	}
+}
+
+func TestSearchTimeout(t *testing.T) {
+	useTestIndex(t, true)
+	oldTimeout, oldNoDaemon := searchTimeout, noDaemon
+	t.Cleanup(func() { searchTimeout, noDaemon = oldTimeout, oldNoDaemon })
+	noDaemon = true
+	search := findCmd(t, "search")
+	search.SetContext(context.Background())
+
+	searchTimeout = time.Nanosecond
+	if err := search.RunE(search, []string{"wireguard"}); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("search with an expired --timeout: %v, want it timed out", err)
+	}
+	searchTimeout = -time.Second
+	if err := search.RunE(search, []string{"wireguard"}); exitCode(err) != exitUsage {
+		t.Errorf("negative --timeout: %v, want a usage error", err)
+	}
+	searchTimeout = time.Minute
+	if err := search.RunE(search, []string{"wireguard"}); err != nil {
+		t.Errorf("search within --timeout: %v", err)
+	}
+}
+
+func TestSearchLowPriority(t *testing.T) {
+	useTestIndex(t, true)
+	open := openIndexFunc
+	var opts index.Options
+	openIndexFunc = func(o index.Options) (*index.Index, error) { opts = o; return open(o) }
+	oldLow, oldNoDaemon := lowPriority, noDaemon
+	t.Cleanup(func() { lowPriority, noDaemon = oldLow, oldNoDaemon })
+	lowPriority, noDaemon = true, true
+	before := pace.Limits()
+
+	search := findCmd(t, "search")
+	if err := search.RunE(search, []string{"wireguard"}); err != nil {
+		t.Fatal(err)
+	}
+	full := throttle.Derive(false, numThreads, backgroundSessions)
+	if opts.Threads != full.Threads || opts.BackgroundSessions != full.BackgroundSessions {
+		t.Errorf("search opened with %d threads, %d background sessions; want %d, %d as without low-priority",
+			opts.Threads, opts.BackgroundSessions, full.Threads, full.BackgroundSessions)
+	}
+	if pace.Limits() != before || reniced != 0 {
+		t.Errorf("search applied low-priority: pace %v, nice %d", pace.Limits(), reniced)
+	}
+}
+
+func TestSearchReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	for _, name := range []string{"a.md", "b.md"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("wireguard"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+		if name == "a.md" {
+			if err := idx.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// b.md is indexed in memory only, so closing a writable index would
+	// save it.
+	before := siftDirState(t, siftDir)
+	if err := os.Chmod(siftDir, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(siftDir, 0o755) })
+	setGlobals(t, siftDir)
+	openIndexFunc = func(index.Options) (*index.Index, error) { return idx, nil }
+	oldNoDaemon := noDaemon
+	t.Cleanup(func() { noDaemon = oldNoDaemon })
+	noDaemon = true
+
+	search := findCmd(t, "search")
+	if err := search.RunE(search, []string{"wireguard"}); err != nil {
+		t.Errorf("search: %v", err)
+	}
+	stats := findCmd(t, "stats")
+	if err := stats.RunE(stats, nil); err != nil {
+		t.Errorf("stats: %v", err)
+	}
+	if after := siftDirState(t, siftDir); !maps.Equal(before, after) {
+		t.Errorf("read-only commands wrote to the index: before %v, after %v", before, after)
+	}
+}
+
+// siftDirState maps each file in dir to its size and mtime.
+func siftDirState(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := make(map[string]string, len(entries))
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		state[e.Name()] = fmt.Sprintf("%d %s", fi.Size(), fi.ModTime())
+	}
+	return state
+}
+
+func TestSearchWithAdjacent(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	p := filepath.Join(dir, "big.md")
+	if err := os.WriteFile(p, []byte(strings.Repeat("the tunnel runs under the garden wall.\n\n", 150)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	if _, err := idx.AddFile(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	openIndexFunc = func(index.Options) (*index.Index, error) { return idx, nil }
+	oldAdjacent, oldNDJSON, oldNoDaemon, oldPerFile := withAdjacent, ndjson, noDaemon, perFile
+	t.Cleanup(func() { withAdjacent, ndjson, noDaemon, perFile = oldAdjacent, oldNDJSON, oldNoDaemon, oldPerFile })
+	noDaemon, perFile = true, 10
+
+	search := findCmd(t, "search")
+	withAdjacent = 1
+	if err := search.RunE(search, []string{"tunnel"}); exitCode(err) != exitUsage {
+		t.Errorf("--with-adjacent without JSON hits: %v, want a usage error", err)
+	}
+
+	ndjson = true
+	var out bytes.Buffer
+	search.SetOut(&out)
+	t.Cleanup(func() { search.SetOut(nil) })
+	if err := search.RunE(search, []string{"tunnel"}); err != nil {
+		t.Fatal(err)
+	}
+	hits := 0
+	for line := range strings.Lines(out.String()) {
+		var h searchHit
+		if err := json.Unmarshal([]byte(line), &h); err != nil {
+			t.Fatal(err)
+		}
+		hits++
+		a := h.Adjacent
+		if a == nil {
+			t.Fatalf("hit %d has no adjacent chunks", h.ChunkIndex)
+		}
+		if h.ChunkIndex > 0 && (len(a.Before) != 1 || a.Before[0].ChunkIndex != h.ChunkIndex-1 || a.Before[0].Preview == "") {
+			t.Errorf("hit %d: before = %+v, want chunk %d", h.ChunkIndex, a.Before, h.ChunkIndex-1)
+		}
+		if h.ChunkIndex == 0 && len(a.Before) != 0 {
+			t.Errorf("first chunk has chunks before it: %+v", a.Before)
+		}
+	}
+	if hits < 2 {
+		t.Errorf("got %d hits, want several chunks of big.md", hits)
+	}
+}
+
+func TestAboveThreshold(t *testing.T) {
+	cal := func(v float32) *float32 { return &v }
+	results := []index.SearchResult{
+		{Score: 0.82, Calibrated: cal(1)},
+		{Score: 0.74, Calibrated: cal(0.6)},
+		{Score: 0.71, Calibrated: cal(0.45)},
+		{Score: 0.62, Calibrated: cal(0)},
+	}
+	if got := aboveThreshold(results, 0.5); len(got) != 2 {
+		t.Errorf("calibrated threshold 0.5 kept %d results, want 2", len(got))
+	}
+	for i := range results {
+		results[i].Calibrated = nil
+	}
+	if got := aboveThreshold(results, 0.7); len(got) != 3 {
+		t.Errorf("raw threshold 0.7 kept %d results, want 3", len(got))
+	}
+	if got := aboveThreshold(results, 0.9); len(got) != 0 {
+		t.Errorf("threshold above every score kept %d results", len(got))
+	}
+}
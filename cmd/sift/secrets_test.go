@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestScanSecrets(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	path := filepath.Join(dir, "deploy.md")
+	if err := os.WriteFile(path, []byte("deploy notes\nticket ACME-12345678"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	if _, err := idx.AddFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Close(); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	oldPattern := redactPattern
+	t.Cleanup(func() { redactPattern, secretsRewrite = oldPattern, false })
+	t.Chdir(dir)
+
+	cmd := findCmd(t, "scan-secrets")
+	cmd.SetContext(context.Background())
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	t.Cleanup(func() { cmd.SetOut(nil) })
+	run := func(want ...string) {
+		t.Helper()
+		out.Reset()
+		if err := cmd.RunE(cmd, nil); err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range want {
+			if !strings.Contains(out.String(), w) {
+				t.Errorf("output lacks %q:\n%s", w, out.String())
+			}
+		}
+	}
+
+	run("No secrets found")
+	redactPattern = regexp.MustCompile(`ACME-[0-9]{8}`)
+	run("deploy.md:2  custom\n", "Found 1 secrets in 1 files")
+	run("deploy.md:2  custom\n") // the audit left them
+	secretsRewrite = true
+	run("deploy.md:2  custom\n", "Redacted 1 secrets in 1 files")
+	if strings.Contains(out.String(), "ACME-12345678") {
+		t.Errorf("the report shows the secret:\n%s", out.String())
+	}
+	secretsRewrite = false
+	run("No secrets found") // in the index as saved
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/server"
+)
+
+var unixSocket string
+
+func init() {
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Keep the model loaded and answer queries over a Unix socket",
+		Long: `Keep the index and model loaded and answer queries over a Unix socket.
+search uses a server listening on the index's default socket automatically;
+` + "`sift daemon start`" + ` runs this command in the background.
+
+The index is reloaded from disk whenever another process (index, watch,
+reindex) has saved it, so answers stay current without a restart.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+			defer stop()
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			path := unixSocket
+			if path == "" {
+				path = server.DefaultSocketPath(indexDir)
+			}
+
+			idx, err := openInteractiveIndex(ortLib, true)
+			if err != nil {
+				return err
+			}
+			defer idx.Close()
+
+			ln, err := server.Listen(path)
+			if err != nil {
+				return err
+			}
+			if !quiet {
+				fmt.Fprintf(os.Stderr, "Listening on %s (Ctrl+C to stop)\n", path)
+			}
+			srv := server.New(refreshingIndex{idx})
+			srv.SetShutdownFunc(cancel)
+			reload := func() (*server.ReloadReply, error) { return reloadConfig(cmd, idx, nil) }
+			srv.SetReloadFunc(reload)
+			onReloadSignal(ctx, func() {
+				if _, err := reload(); err != nil {
+					logger.Errorf("%v", err)
+				}
+			})
+			return srv.Serve(ctx, ln)
+		},
+	}
+	serveCmd.Flags().StringVar(&unixSocket, "unix", "", "socket path (default <index-dir>/sift.sock)")
+	rootCmd.AddCommand(serveCmd)
+}
+
+// refreshingIndex is the server backend: it picks up index files saved by
+// other processes before answering.
+type refreshingIndex struct{ *index.Index }
+
+func (r refreshingIndex) SearchFiltered(ctx context.Context, query string, k int, f index.Filter) ([]index.SearchResult, error) {
+	r.refresh()
+	return r.Index.SearchFiltered(ctx, query, k, f)
+}
+
+func (r refreshingIndex) Stats() index.Stats {
+	r.refresh()
+	return r.Index.Stats()
+}
+
+func (r refreshingIndex) refresh() {
+	reloaded, err := r.Refresh()
+	switch {
+	case err != nil:
+		logger.Errorf("reload index: %v", err)
+	case reloaded:
+		logger.Infof("Reloaded the index (%d chunks)", r.Index.Stats().NumChunks)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// shutdownSignals stop long-running commands (index, watch, serve) cleanly.
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// reloadSignals make watch and serve re-read their configuration.
+var reloadSignals = []os.Signal{syscall.SIGHUP}
+
+// statusSignals make an index run print where it is, and flushSignals
+// save what it has indexed; see onRunSignals.
+var (
+	statusSignals = []os.Signal{syscall.SIGUSR1}
+	flushSignals  = []os.Signal{syscall.SIGUSR2}
+)
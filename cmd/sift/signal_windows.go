@@ -0,0 +1,19 @@
+package main
+
+import "os"
+
+// shutdownSignals stop long-running commands (index, watch, serve) cleanly.
+// Windows delivers only os.Interrupt (Ctrl-C / Ctrl-Break); SIGTERM is
+// never sent.
+var shutdownSignals = []os.Signal{os.Interrupt}
+
+// reloadSignals is empty: Windows has no SIGHUP. `sift serve` can still be
+// reloaded with a "reload" request.
+var reloadSignals []os.Signal
+
+// statusSignals and flushSignals are empty: Windows has no SIGUSR1 or
+// SIGUSR2, so index runs cannot be asked for their status or a checkpoint.
+var (
+	statusSignals []os.Signal
+	flushSignals  []os.Signal
+)
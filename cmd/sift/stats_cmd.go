@@ -1,30 +1,470 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/embed"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/lang"
+)
+
+var (
+	statsJSON    bool
+	statsWatch   bool
+	statsNoCheck bool
+	statsSlowest int
+	statsFull    bool
 )
 
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
+	statsCmd := &cobra.Command{
 		Use:   "stats",
 		Short: "Show index statistics",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			idx, err := openIndex(ortLib)
+			if statsWatch {
+				if statsJSON {
+					return &usageError{errors.New("--watch and --json cannot be combined")}
+				}
+				ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+				defer stop()
+				ticker := time.NewTicker(time.Second)
+				defer ticker.Stop()
+				return watchStats(ctx, cmd.OutOrStdout(), indexDir, ticker.C, stdoutIsTTY())
+			}
+			where := indexDir
+			if abs, err := filepath.Abs(indexDir); err == nil {
+				where = abs
+			}
+			scope := "project"
+			if globalIndex {
+				scope = "global"
+			}
+			// The plain report comes from the summary each flush writes,
+			// unless something only the full index holds is asked for or
+			// the summary predates the fields it needs.
+			if !statsFull && !statsJSON && statsSlowest == 0 {
+				if !index.Exists(indexDir) {
+					return noIndexError()
+				}
+				if s, err := index.ReadSummary(indexDir); err == nil && s.FormatVersion > 0 {
+					writeStatsSummary(cmd.OutOrStdout(), s, scope, where, time.Now())
+					return nil
+				}
+			}
+
+			// Stats only inspects files on disk; no need to load the model.
+			idx, err := index.OpenReadOnly(indexDir)
+			if errors.Is(err, index.ErrNoIndex) {
+				return noIndexError()
+			}
 			if err != nil {
 				return err
 			}
 			defer idx.Close()
+			idx.SetMaxFileKB(maxFileKB)
+			idx.SetOversizedPolicy(oversizedPolicy)
+			idx.SetCategoryRules(categoryRules)
 
 			s := idx.Stats()
+			if !statsNoCheck {
+				s = idx.StatsWithLiveness()
+			}
+
+			var slowest []fileCost
+			if statsSlowest > 0 {
+				slowest = append([]fileCost{}, newFileCosts(idx.SlowestFiles(statsSlowest))...)
+			}
+
+			if statsJSON {
+				r := newStatsReport(s, idx.Manifest(), where)
+				r.Slowest = slowest
+				j, err := json.MarshalIndent(r, "", "  ")
+				if err != nil {
+					return fmt.Errorf("marshal json: %w", err)
+				}
+				fmt.Println(string(j))
+				return nil
+			}
+
+			fmt.Printf("index:     %s\n", scope)
+			fmt.Printf("location:  %s\n", where)
+			for _, root := range idx.Roots() {
+				fmt.Printf("root:      %s\n", root)
+			}
 			fmt.Printf("chunks:    %d\n", s.NumChunks)
 			fmt.Printf("files:     %d\n", s.NumFiles)
-			fmt.Printf("size:      %d KB\n", s.IndexSizeKB)
+			fmt.Printf("size:      %s\n", sizeText(s.SizeBytes))
+			if stored, ok := s.Artifacts["meta.json"]; ok && s.MetaCompressed {
+				fmt.Printf("metadata:  %s gzipped (%s uncompressed)\n", sizeText(stored), sizeText(s.MetaBytes))
+			}
 			if !s.LastUpdated.IsZero() {
-				fmt.Printf("updated:   %s\n", s.LastUpdated.Format("2006-01-02 15:04:05"))
+				fmt.Printf("updated:   %s\n", whenText(time.Now(), s.LastUpdated))
+			}
+			if s.Repaired != "" {
+				fmt.Printf("repaired:  %s (saved by the next index run)\n", s.Repaired)
+			}
+			if n := len(s.MissingFiles); n > 0 {
+				fmt.Printf("missing:   %d indexed files missing on disk\n", n)
+			}
+			if s.Oversized > 0 {
+				fmt.Printf("too large: %d files over max-file-kb (%d KB)", s.Oversized, maxFileKB)
+				if s.OversizedIndexed > 0 {
+					fmt.Printf(", %d still indexed from a higher limit (`sift prune` drops them)", s.OversizedIndexed)
+				}
+				fmt.Println()
+			}
+			if s.HashedFiles < s.NumFiles {
+				fmt.Printf("hashed:    %d of %d files; the rest, indexed before content hashes, are re-embedded when their mtime changes\n", s.HashedFiles, s.NumFiles)
+			}
+			if s.SampledFiles > 0 {
+				fmt.Printf("sampled:   %d files over max-file-kb indexed in part (oversized = sample)\n", s.SampledFiles)
+			}
+			if s.TruncatedChunks > 0 {
+				fmt.Printf("truncated: %d chunks in %d files longer than the model's %d-token input (%.1f%% of chunks)\n",
+					s.TruncatedChunks, s.TruncatedFiles, embed.MaxSeqLen, 100*float64(s.TruncatedChunks)/float64(s.NumChunks))
+			}
+			writeStatsCategories(os.Stdout, s.Categories)
+			writeStatsLanguages(os.Stdout, s.Languages, s.NumChunks)
+			if statsSlowest > 0 {
+				writeSlowestHistory(os.Stdout, slowest, idx.Roots())
 			}
 			return nil
 		},
+	}
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "output statistics as JSON (stable schema)")
+	statsCmd.Flags().BoolVar(&statsWatch, "watch", false, "refresh every second with changes since the last refresh, until interrupted")
+	statsCmd.Flags().BoolVar(&statsFull, "full", false, "open the whole index for the full report, checking that indexed files still exist, instead of reading its summary")
+	statsCmd.Flags().BoolVar(&statsNoCheck, "no-check-files", false, "with --full, skip checking that indexed files still exist (faster on huge indexes)")
+	statsCmd.Flags().IntVar(&statsSlowest, "slowest", 0, "also list the `n` files that took longest to embed, over past index runs")
+	rootCmd.AddCommand(statsCmd)
+}
+
+// writeStatsSummary prints the report `sift stats` makes at now from the
+// index's summary alone, with where the full report is.
+func writeStatsSummary(w io.Writer, s *index.Summary, scope, where string, now time.Time) {
+	fmt.Fprintf(w, "index:     %s\n", scope)
+	fmt.Fprintf(w, "location:  %s\n", where)
+	for _, root := range s.Roots {
+		fmt.Fprintf(w, "root:      %s\n", root)
+	}
+	fmt.Fprintf(w, "chunks:    %d\n", s.Chunks)
+	fmt.Fprintf(w, "files:     %d\n", s.Files)
+	fmt.Fprintf(w, "size:      %s\n", sizeText(s.SizeBytes))
+	if !s.Updated.IsZero() {
+		fmt.Fprintf(w, "updated:   %s\n", whenText(now, s.Updated))
+	}
+	if s.Model != "" {
+		fmt.Fprintf(w, "model:     %s\n", s.Model)
+	}
+	fmt.Fprintln(w, "hint:      --full checks the indexed files and reports file types, languages, skipped and truncated files")
+}
+
+// writeStatsCategories breaks the index's files down by category, most
+// files first: "types:     source 120, docs 31, tests 12 files", named
+// as "type:" filters name them.
+func writeStatsCategories(w io.Writer, cats map[string]index.ExtStats) {
+	if len(cats) == 0 {
+		return
+	}
+	names := slices.Collect(maps.Keys(cats))
+	slices.SortFunc(names, func(a, b string) int {
+		return cmp.Or(cmp.Compare(cats[b].Files, cats[a].Files), strings.Compare(a, b))
 	})
+	parts := make([]string, len(names))
+	for i, c := range names {
+		parts[i] = fmt.Sprintf("%s %d", c, cats[c].Files)
+	}
+	fmt.Fprintf(w, "types:     %s files\n", strings.Join(parts, ", "))
+}
+
+// writeStatsLanguages breaks the index's chunks down by language, if any
+// are not English: "languages: en 77%, de 15%, ja 8% of chunks".
+func writeStatsLanguages(w io.Writer, langs map[string]index.LangStats, chunks int) {
+	byLang := make(map[string]int, len(langs))
+	for l, ls := range langs {
+		byLang[l] = ls.Chunks
+	}
+	if chunks == 0 || byLang[lang.English] == chunks {
+		return
+	}
+	fmt.Fprintf(w, "languages: %s of chunks\n", languageShares(byLang, chunks, true))
+}
+
+// writeSlowestHistory lists the files that took longest to embed, as
+// recorded by past runs, and how to leave the slowest out of the index.
+func writeSlowestHistory(w io.Writer, costs []fileCost, roots []string) {
+	if len(costs) == 0 {
+		fmt.Fprintln(w, "slowest:   none recorded yet (index runs record them)")
+		return
+	}
+	writeSlowest(w, "slowest:   ", costs)
+	fmt.Fprintf(w, "hint:      leave one out with --exclude '%s' or a line in .siftignore\n", rootRelative(roots, costs[0].Path))
+}
+
+// rootRelative returns path relative to the root of roots it lies under,
+// in the slash form ignore patterns use, or path itself under none.
+func rootRelative(roots []string, path string) string {
+	for _, root := range roots {
+		if rel, err := filepath.Rel(root, path); err == nil && filepath.IsLocal(rel) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// statsRetries and statsRetryWait bound how long watchStats retries a
+// summary caught mid-rewrite before skipping that refresh; tests replace
+// statsRetryWait.
+var (
+	statsRetries   = 3
+	statsRetryWait = func() { time.Sleep(50 * time.Millisecond) }
+)
+
+// watchStats prints a line for the index in dir now and on every tick
+// until ctx is done, each with the change since the previous line. With
+// redraw set the line is rewritten in place, as on a terminal. It reads
+// the small summary file each flush writes, never meta.json, except once
+// for an index flushed before summaries existed.
+func watchStats(ctx context.Context, w io.Writer, dir string, tick <-chan time.Time, redraw bool) error {
+	var prev *index.Summary
+	refresh := func(now time.Time) error {
+		s, err := readStatsSummary(dir)
+		if errors.Is(err, index.ErrNoIndex) {
+			s = &index.Summary{} // not created yet: show zeros until it is
+		} else if err != nil {
+			logger.Debugf("stats: %v", err)
+			return nil // keep the last line; try again next tick
+		}
+		line := statsLine(now, s, prev)
+		if redraw {
+			_, err = fmt.Fprint(w, "\r\x1b[K"+line)
+		} else {
+			_, err = fmt.Fprintln(w, line)
+		}
+		prev = s
+		return err
+	}
+
+	if err := refresh(time.Now()); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			if redraw {
+				fmt.Fprintln(w)
+			}
+			return nil
+		case now := <-tick:
+			if err := refresh(now); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readStatsSummary reads dir's summary, retrying a file that fails to
+// parse (it may be mid-rewrite). An index without one is measured by
+// loading its metadata.
+func readStatsSummary(dir string) (*index.Summary, error) {
+	var err error
+	for range statsRetries {
+		var s *index.Summary
+		if s, err = index.ReadSummary(dir); err == nil {
+			return s, nil
+		}
+		if os.IsNotExist(err) {
+			break
+		}
+		statsRetryWait()
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	idx, err := index.OpenReadOnly(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer idx.Close()
+	st := idx.Stats()
+	return &index.Summary{Chunks: st.NumChunks, Files: st.NumFiles, Updated: st.LastUpdated, SizeBytes: st.SizeBytes}, nil
+}
+
+// statsLine formats one refresh of `stats --watch`.
+func statsLine(now time.Time, s, prev *index.Summary) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s  %d chunks", now.Format("15:04:05"), s.Chunks)
+	if prev != nil {
+		fmt.Fprintf(&b, " (%+d)", s.Chunks-prev.Chunks)
+	}
+	fmt.Fprintf(&b, "  %d files", s.Files)
+	if prev != nil {
+		fmt.Fprintf(&b, " (%+d)", s.Files-prev.Files)
+	}
+	fmt.Fprintf(&b, "  %s", sizeText(s.SizeBytes))
+	if prev != nil {
+		fmt.Fprintf(&b, " (%s)", sizeDeltaText(s.SizeBytes-prev.SizeBytes))
+	}
+	if !s.Updated.IsZero() {
+		fmt.Fprintf(&b, "  updated %s", agoText(now, s.Updated))
+	}
+	return b.String()
+}
+
+// statsSchemaVersion is bumped on any incompatible change to statsReport.
+const statsSchemaVersion = 1
+
+// statsReport is the `sift stats --json` schema. Every field is always
+// present; values that are unknown are null rather than omitted.
+type statsReport struct {
+	SchemaVersion int                       `json:"schema_version"`
+	IndexRoot     string                    `json:"index_root"`
+	Chunks        int                       `json:"chunks"`
+	Files         int                       `json:"files"`
+	Extensions    map[string]statsExtension `json:"extensions"`
+	Artifacts     map[string]*int64         `json:"artifacts"`
+	SizeBytes     int64                     `json:"size_bytes"`
+	LastUpdated   *time.Time                `json:"last_updated"`
+	Model         statsModel                `json:"model"`
+	HNSW          statsHNSW                 `json:"hnsw"`
+	Meta          statsMeta                 `json:"meta"`
+	// Repaired describes a mismatch between hnsw.bin and meta.json fixed
+	// on load; the repair is saved by the next index run.
+	Repaired *string `json:"repaired"`
+	// MissingFiles counts indexed files no longer on disk; null when the
+	// check was skipped.
+	MissingFiles *int `json:"missing_files"`
+	// OversizedFiles counts the files index runs skip for exceeding
+	// max-file-kb, and OversizedIndexed those among them still indexed
+	// from a higher limit.
+	OversizedFiles   int `json:"oversized_files"`
+	OversizedIndexed int `json:"oversized_indexed"`
+	// TruncatedChunks counts the chunks longer than the model's input,
+	// embedded only up to its limit, and TruncatedFiles the files they
+	// are in.
+	TruncatedChunks int `json:"truncated_chunks"`
+	TruncatedFiles  int `json:"truncated_files"`
+	// SampledFiles counts the files over max-file-kb of which only a
+	// sample of chunks is indexed.
+	SampledFiles int `json:"sampled_files"`
+	// HashedFiles counts the files whose content hash meta.json holds,
+	// which a changed mtime alone does not re-embed.
+	HashedFiles int `json:"hashed_files"`
+	// Languages breaks files and chunks down by the language chunks were
+	// detected as (ISO 639-1 codes, "en" included).
+	Languages map[string]statsExtension `json:"languages"`
+	// Categories breaks files and chunks down by file category ("tests",
+	// "docs", "config", "source", "other", or the project's own).
+	Categories map[string]statsExtension `json:"categories"`
+	// Slowest lists the files that took longest to embed over past index
+	// runs, slowest first; null unless asked for with --slowest.
+	Slowest []fileCost `json:"slowest"`
+}
+
+// statsMeta compares meta.json's stored size with its JSON size.
+type statsMeta struct {
+	Compressed   bool   `json:"compressed"`
+	StoredBytes  *int64 `json:"stored_bytes"`
+	LogicalBytes int64  `json:"logical_bytes"`
+}
+
+// statsExtension counts the files and chunks of one extension, of one
+// language, or of one category.
+type statsExtension struct {
+	Files  int `json:"files"`
+	Chunks int `json:"chunks"`
+}
+
+type statsModel struct {
+	Name          *string `json:"name"`
+	Dim           *int    `json:"dim"`
+	Hash          *string `json:"hash"`
+	FormatVersion *int    `json:"format_version"`
+}
+
+type statsHNSW struct {
+	M              int `json:"m"`
+	EfConstruction int `json:"ef_construction"`
+	EfSearch       int `json:"ef_search"`
+}
+
+func newStatsReport(s index.Stats, m index.Manifest, root string) statsReport {
+	r := statsReport{
+		SchemaVersion: statsSchemaVersion,
+		IndexRoot:     root,
+		Chunks:        s.NumChunks,
+		Files:         s.NumFiles,
+		Extensions:    make(map[string]statsExtension, len(s.Extensions)),
+		Artifacts:     make(map[string]*int64, len(index.ArtifactFiles)),
+		HNSW: statsHNSW{
+			M:              s.HNSW.M,
+			EfConstruction: s.HNSW.EfConstruction,
+			EfSearch:       s.HNSW.EfSearch,
+		},
+		Meta:             statsMeta{Compressed: s.MetaCompressed, LogicalBytes: s.MetaBytes},
+		OversizedFiles:   s.Oversized,
+		OversizedIndexed: s.OversizedIndexed,
+		TruncatedChunks:  s.TruncatedChunks,
+		TruncatedFiles:   s.TruncatedFiles,
+		SampledFiles:     s.SampledFiles,
+		HashedFiles:      s.HashedFiles,
+	}
+	for ext, es := range s.Extensions {
+		r.Extensions[ext] = statsExtension{Files: es.Files, Chunks: es.Chunks}
+	}
+	r.Languages = make(map[string]statsExtension, len(s.Languages))
+	for l, ls := range s.Languages {
+		r.Languages[l] = statsExtension{Files: ls.Files, Chunks: ls.Chunks}
+	}
+	r.Categories = make(map[string]statsExtension, len(s.Categories))
+	for c, cs := range s.Categories {
+		r.Categories[c] = statsExtension{Files: cs.Files, Chunks: cs.Chunks}
+	}
+	for _, name := range index.ArtifactFiles {
+		r.Artifacts[name] = nil
+		if size, ok := s.Artifacts[name]; ok {
+			r.Artifacts[name] = &size
+			r.SizeBytes += size
+			if name == "meta.json" {
+				r.Meta.StoredBytes = &size
+			}
+		}
+	}
+	if !s.LastUpdated.IsZero() {
+		t := s.LastUpdated.UTC()
+		r.LastUpdated = &t
+	}
+	if s.Repaired != "" {
+		r.Repaired = &s.Repaired
+	}
+	if s.LivenessChecked {
+		n := len(s.MissingFiles)
+		r.MissingFiles = &n
+	}
+	if m.Model != "" {
+		r.Model.Name = &m.Model
+	}
+	if m.Dim != 0 {
+		r.Model.Dim = &m.Dim
+	}
+	if m.ModelHash != "" {
+		r.Model.Hash = &m.ModelHash
+	}
+	if m.FormatVersion != 0 {
+		r.Model.FormatVersion = &m.FormatVersion
+	}
+	return r
 }
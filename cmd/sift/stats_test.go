@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func TestStatsJSONGolden(t *testing.T) {
+	root := filepath.Join("testdata", "stats", "index")
+	idx, err := index.OpenReadOnly(root)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: %v", err)
+	}
+	defer idx.Close()
+
+	got, err := json.MarshalIndent(newStatsReport(idx.Stats(), idx.Manifest(), filepath.ToSlash(root)), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	golden := filepath.Join("testdata", "stats", "stats.golden.json")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("stats --json drifted from %s (run with -update if intended):\n%s", golden, got)
+	}
+}
+
+// TestStatsJSONNulls checks that missing data is reported as null, never omitted.
+func TestStatsJSONNulls(t *testing.T) {
+	b, err := json.Marshal(newStatsReport(index.Stats{}, index.Manifest{}, ".sift"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"schema_version", "index_root", "chunks", "files", "extensions",
+		"artifacts", "size_bytes", "last_updated", "model", "hnsw", "missing_files", "oversized_files"} {
+		if _, ok := m[key]; !ok {
+			t.Errorf("field %q missing", key)
+		}
+	}
+	if m["last_updated"] != nil {
+		t.Errorf("last_updated = %v, want null", m["last_updated"])
+	}
+	model := m["model"].(map[string]any)
+	for _, key := range []string{"name", "dim", "hash", "format_version"} {
+		if v, ok := model[key]; !ok || v != nil {
+			t.Errorf("model.%s = %v (present=%t), want null", key, v, ok)
+		}
+	}
+	artifacts := m["artifacts"].(map[string]any)
+	for _, name := range index.ArtifactFiles {
+		if v, ok := artifacts[name]; !ok || v != nil {
+			t.Errorf("artifacts[%s] = %v (present=%t), want null", name, v, ok)
+		}
+	}
+}
+
+func TestStatsSummary(t *testing.T) {
+	useTestIndex(t, true)
+	stats := findCmd(t, "stats")
+	var out bytes.Buffer
+	stats.SetOut(&out)
+	t.Cleanup(func() {
+		stats.SetOut(nil)
+		statsFull = false
+	})
+
+	// The plain report is read from summary.json.
+	if err := stats.RunE(stats, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"chunks:    1\n", "files:     1\n", "model:     ", "hint:      --full"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("stats:\n%s\nwant it to contain %q", out.String(), want)
+		}
+	}
+
+	// --full, or a missing summary, opens the index instead.
+	statsFull = true
+	out.Reset()
+	if err := stats.RunE(stats, nil); err != nil || out.Len() != 0 {
+		t.Errorf("stats --full: %v, summary report %q", err, out.String())
+	}
+	statsFull = false
+	if err := os.Remove(filepath.Join(indexDir, "summary.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := stats.RunE(stats, nil); err != nil || out.Len() != 0 {
+		t.Errorf("stats without a summary: %v, summary report %q", err, out.String())
+	}
+}
+
+func TestStatsSummary_Raw(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	s := &index.Summary{Chunks: 1200, Files: 30, SizeBytes: 88_290_918, Updated: now.Add(-2 * time.Hour)}
+	prev := &index.Summary{Chunks: 1000, Files: 30, SizeBytes: 88_291_942, Updated: now.Add(-3 * time.Hour)}
+	t.Cleanup(func() { rawOutput = false })
+	for _, tc := range []struct {
+		raw  bool
+		want []string
+	}{
+		{false, []string{"size:      84.2 MB\n", "updated:   2 hours ago (", "84.2 MB (-1.0 KB)  updated 2h ago"}},
+		{true, []string{"size:      88290918 bytes\n", "updated:   2026-01-02T10:00:00Z\n", "88290918 bytes (-1024 bytes)  updated 2026-01-02T10:00:00Z"}},
+	} {
+		rawOutput = tc.raw
+		var out bytes.Buffer
+		writeStatsSummary(&out, s, "project", "/p/.sift", now)
+		out.WriteString(statsLine(now, s, prev))
+		for _, want := range tc.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("raw=%t:\n%s\nwant it to contain %q", tc.raw, out.String(), want)
+			}
+		}
+	}
+}
+
+func TestSlowestListings(t *testing.T) {
+	root := filepath.FromSlash("/work/proj")
+	costs := []index.FileCost{
+		{Path: filepath.Join(root, "api", "schema.json"), Duration: 14230 * time.Millisecond, Chunks: 212},
+		{Path: filepath.Join(root, "docs", "big.md"), Duration: 3100 * time.Millisecond, Chunks: 40},
+		{Path: filepath.Join(root, "a.go"), Duration: 120 * time.Millisecond, Chunks: 2},
+		{Path: filepath.Join(root, "b.go"), Duration: 90 * time.Millisecond, Chunks: 1},
+	}
+
+	// A run summary names its few slowest files.
+	r := newRunReport("index", index.RunStats{Slowest: costs})
+	if len(r.Slowest) != runSlowest || r.Slowest[0].MS != 14230 || r.Slowest[0].Chunks != 212 {
+		t.Errorf("run report slowest = %+v, want the first %d", r.Slowest, runSlowest)
+	}
+	var b bytes.Buffer
+	writeRunSummary(&b, r)
+	want := "slowest: " + filepath.Join(root, "api", "schema.json") + " 14.2s / 212 chunks\n" +
+		"         " + filepath.Join(root, "docs", "big.md") + " 3.1s / 40 chunks\n"
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("run summary:\n%s\nwant it to contain:\n%s", b.String(), want)
+	}
+
+	// stats --slowest lists the history and how to exclude the worst.
+	b.Reset()
+	writeSlowestHistory(&b, newFileCosts(costs[:2]), []string{root})
+	if got := b.String(); !strings.Contains(got, "slowest:   "+filepath.Join(root, "api", "schema.json")+" 14.2s / 212 chunks") ||
+		!strings.Contains(got, "--exclude 'api/schema.json'") {
+		t.Errorf("stats --slowest:\n%s", got)
+	}
+	b.Reset()
+	writeSlowestHistory(&b, nil, nil)
+	if !strings.Contains(b.String(), "none recorded") {
+		t.Errorf("stats --slowest with no history: %q", b.String())
+	}
+}
+
+func TestWatchStats(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	addDoc := func(name string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("wireguard config lives here"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+		if err := idx.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addDoc("a.md")
+
+	pr, pw := io.Pipe()
+	lines := bufio.NewScanner(pr)
+	next := func() string {
+		t.Helper()
+		if !lines.Scan() {
+			t.Fatalf("watchStats stopped early: %v", lines.Err())
+		}
+		return lines.Text()
+	}
+	tick := make(chan time.Time)
+	clock := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- watchStats(ctx, pw, siftDir, tick, false) }()
+
+	if got := next(); !strings.Contains(got, "1 chunks  1 files") || strings.Contains(got, "(+") {
+		t.Errorf("first line = %q, want counts without deltas", got)
+	}
+
+	addDoc("b.md")
+	tick <- clock.Add(time.Second)
+	if got := next(); !strings.HasPrefix(got, "00:00:01  2 chunks (+1)  2 files (+1)") {
+		t.Errorf("after one more file: %q", got)
+	}
+
+	// Catch the summary mid-rewrite: the first read fails to parse and
+	// the retry sees the finished file.
+	addDoc("c.md")
+	summary := filepath.Join(siftDir, "summary.json")
+	full, err := os.ReadFile(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(summary, full[:len(full)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	retried := 0
+	oldWait := statsRetryWait
+	statsRetryWait = func() {
+		retried++
+		os.WriteFile(summary, full, 0o644)
+	}
+	t.Cleanup(func() { statsRetryWait = oldWait })
+	tick <- clock.Add(2 * time.Second)
+	if got := next(); !strings.HasPrefix(got, "00:00:02  3 chunks (+1)  3 files (+1)") {
+		t.Errorf("after a torn read: %q", got)
+	}
+	if retried != 1 {
+		t.Errorf("retried %d times, want 1", retried)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("watchStats = %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/index"
+)
+
+// topLineRunes is how much of a chunk's first line the digest shows.
+const topLineRunes = 80
+
+var (
+	topPerDir int
+	topJSON   bool
+)
+
+func init() {
+	topCmd := &cobra.Command{
+		Use:   "top",
+		Short: "Summarize what each top-level directory is about",
+		Long: `Print a digest of the index without a query: for each top-level directory
+(and the files directly in the project root), the chunks most similar on
+average to the rest of the directory, one per file, with their first line.
+They are what the directory is mostly about, which makes this a starting
+point in an unfamiliar project.
+
+Centrality is computed from the vectors already stored; no model is loaded.
+A directory of more than 2048 chunks is estimated from an evenly spaced
+sample of them, and its header says so.`,
+		Example: `  sift top
+  sift top --per-dir 5
+  sift top --json | jq -r '.[].top[0].path'`,
+		Args: cobra.NoArgs,
+		RunE: runTop,
+	}
+	f := topCmd.Flags()
+	f.IntVar(&topPerDir, "per-dir", 3, "chunks to show per directory")
+	f.BoolVar(&topJSON, "json", false, "output the digest as JSON")
+	rootCmd.AddCommand(topCmd)
+}
+
+// topDir is a directory of the `sift top --json` digest. Like searchHit,
+// it gives paths relative to the index root and absolute.
+type topDir struct {
+	Dir     string     `json:"dir"`
+	AbsDir  string     `json:"abs_dir"`
+	Files   int        `json:"files"`
+	Chunks  int        `json:"chunks"`
+	Sampled bool       `json:"sampled,omitempty"`
+	Top     []topChunk `json:"top"`
+}
+
+type topChunk struct {
+	ID         string  `json:"id"`
+	Path       string  `json:"path"`
+	AbsPath    string  `json:"abs_path"`
+	Line       int     `json:"line"`
+	Centrality float32 `json:"centrality"`
+	FirstLine  string  `json:"first_line"`
+}
+
+func runTop(cmd *cobra.Command, args []string) error {
+	if topPerDir < 1 {
+		return &usageError{fmt.Errorf("--per-dir must be at least 1, got %d", topPerDir)}
+	}
+	idx, err := index.OpenReadOnly(indexDir)
+	if errors.Is(err, index.ErrNoIndex) {
+		return noIndexError()
+	}
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+	digests, err := idx.TopChunks(cmd.Context(), topPerDir)
+	if err != nil {
+		return err
+	}
+
+	w := cmd.OutOrStdout()
+	if topJSON {
+		dirs := make([]topDir, len(digests))
+		for i, d := range digests {
+			dirs[i] = topDir{
+				Dir:     index.RootRelative(indexDir, d.Dir),
+				AbsDir:  d.Dir,
+				Files:   d.Files,
+				Chunks:  d.Chunks,
+				Sampled: d.Sampled,
+				Top:     make([]topChunk, len(d.Top)),
+			}
+			for j, c := range d.Top {
+				dirs[i].Top[j] = topChunk{
+					ID:         c.Meta.ID,
+					Path:       index.RootRelative(indexDir, c.Meta.Path),
+					AbsPath:    c.Meta.Path,
+					Line:       c.Meta.LineNum,
+					Centrality: c.Centrality,
+					FirstLine:  firstLine(c.Meta.Text),
+				}
+			}
+		}
+		j, err := json.MarshalIndent(dirs, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal json: %w", err)
+		}
+		fmt.Fprintln(w, string(j))
+	} else {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("getwd: %w", err)
+		}
+		writeTop(w, digests, cwd)
+	}
+	if len(digests) == 0 {
+		return errNoResults
+	}
+	return nil
+}
+
+// writeTop prints each directory with its size, then its central chunks
+// as centrality, path:line and first line.
+func writeTop(w io.Writer, digests []index.DirDigest, cwd string) {
+	if len(digests) == 0 {
+		fmt.Fprintln(w, "nothing indexed")
+		return
+	}
+	for i, d := range digests {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		sampled := ""
+		if d.Sampled {
+			sampled = ", sampled"
+		}
+		fmt.Fprintf(w, "%s/  %s chunks in %s files%s\n",
+			strings.TrimSuffix(displayPath(cwd, d.Dir), "/"), countText(d.Chunks), countText(d.Files), sampled)
+		for _, c := range d.Top {
+			fmt.Fprintf(w, "  %.3f  %s:%d  %s\n",
+				c.Centrality, displayPath(cwd, c.Meta.Path), c.Meta.LineNum, firstLine(c.Meta.Text))
+		}
+	}
+}
+
+// firstLine returns the first non-blank line of text, trimmed and cut to
+// topLineRunes.
+func firstLine(text string) string {
+	for line := range strings.Lines(text) {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if r := []rune(line); len(r) > topLineRunes {
+			return string(r[:topLineRunes-1]) + "…"
+		}
+		return line
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestTop(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := index.NewTestIndex(siftDir, &mockEmbedder{})
+	for name, body := range map[string]string{
+		"docs/vpn.md":    "\n# WireGuard\n\nPeers are listed under [Peer].",
+		"docs/tunnel.md": "# Tunnels",
+		"README.md":      "# sift",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Close(); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	t.Cleanup(func() { topPerDir, topJSON = 3, false })
+	t.Chdir(dir)
+
+	cmd := findCmd(t, "top")
+	cmd.SetContext(context.Background())
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	t.Cleanup(func() { cmd.SetOut(nil) })
+	run := func() string {
+		t.Helper()
+		out.Reset()
+		if err := cmd.RunE(cmd, nil); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	got := run()
+	for _, want := range []string{"./  1 chunks in 1 files\n", "README.md:1  # sift\n", "docs/  2 chunks in 2 files\n", "docs/vpn.md:2  # WireGuard\n", "docs/tunnel.md:1  # Tunnels\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output lacks %q:\n%s", want, got)
+		}
+	}
+
+	topPerDir, topJSON = 1, true
+	var dirs []topDir
+	if err := json.Unmarshal([]byte(run()), &dirs); err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 2 || dirs[1].Dir != "docs" || dirs[1].AbsDir != filepath.Join(dir, "docs") || len(dirs[1].Top) != 1 {
+		t.Fatalf("--json --per-dir 1 = %+v", dirs)
+	}
+	if c := dirs[1].Top[0]; !strings.HasPrefix(c.Path, "docs/") || c.AbsPath != filepath.Join(dir, filepath.FromSlash(c.Path)) || c.FirstLine == "" {
+		t.Errorf("--json chunk = %+v", c)
+	}
+
+	topPerDir = 0
+	if err := cmd.RunE(cmd, nil); exitCode(err) != exitUsage {
+		t.Errorf("--per-dir 0: %v, want a usage error", err)
+	}
+}
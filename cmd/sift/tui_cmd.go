@@ -1,8 +1,14 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"os"
+
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/termenv"
 	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/config"
 	"github.com/tejas242/sift/internal/tui"
 )
 
@@ -11,16 +17,59 @@ func init() {
 		Use:   "tui",
 		Short: "Launch interactive BubbleTea search interface",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			idx, err := openIndex(ortLib)
-			if err != nil {
-				return err
-			}
-			defer idx.Close()
-
-			m := tui.New(idx)
-			p := tea.NewProgram(m, tea.WithAltScreen())
-			_, err = p.Run()
-			return err
+			return runTUI()
 		},
 	})
 }
+
+// runTUI opens the index and runs the interactive search interface; bare
+// `sift` on a terminal does the same. The results pinned meanwhile are
+// exported once it exits; see exportPins.
+func runTUI() error {
+	idx, err := openInteractiveIndex(ortLib, false)
+	if err != nil {
+		return err
+	}
+	defer idx.Close()
+
+	m := tui.New(idx)
+	p := tea.NewProgram(m, tea.WithAltScreen())
+	final, err := p.Run()
+	if err != nil {
+		return err
+	}
+	if m, ok := final.(tui.Model); ok {
+		return exportPins(os.Stdout, m.Pins())
+	}
+	return nil
+}
+
+// copyToClipboard copies text to the terminal's clipboard; tests replace
+// it.
+var copyToClipboard = termenv.Copy
+
+// exportPins writes pins where the pin-export setting says, formatted as
+// pin-format says: to w for "stdout", the clipboard, or a file, which is
+// overwritten. Nothing is written when nothing is pinned.
+func exportPins(w io.Writer, pins []tui.Pin) error {
+	if len(pins) == 0 || pinExport == config.PinExportNone {
+		return nil
+	}
+	cwd, _ := os.Getwd()
+	text := tui.FormatPins(pins, pinFormat == config.PinFormatMarkdown, cwd)
+	switch pinExport {
+	case config.PinExportStdout:
+		_, err := io.WriteString(w, text)
+		return err
+	case config.PinExportClipboard:
+		copyToClipboard(text)
+		logger.Infof("copied %d pinned results to the clipboard", len(pins))
+		return nil
+	default:
+		if err := os.WriteFile(pinExport, []byte(text), 0o644); err != nil {
+			return fmt.Errorf("pin-export: %w", err)
+		}
+		logger.Infof("wrote %d pinned results to %s", len(pins), pinExport)
+		return nil
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tejas242/sift/internal/config"
+	"github.com/tejas242/sift/internal/tui"
+)
+
+func TestExportPins(t *testing.T) {
+	oldExport, oldFormat, oldCopy := pinExport, pinFormat, copyToClipboard
+	t.Cleanup(func() { pinExport, pinFormat, copyToClipboard = oldExport, oldFormat, oldCopy })
+	dir := t.TempDir()
+	t.Chdir(dir)
+	pins := []tui.Pin{{ID: "1", Path: filepath.Join(dir, "notes.md"), Line: 4, Text: "wireguard config"}}
+
+	pinExport, pinFormat = config.PinExportStdout, config.PinFormatLines
+	var out bytes.Buffer
+	if err := exportPins(&out, pins); err != nil || out.String() != "notes.md:4\n" {
+		t.Errorf("stdout export = %q, %v; want notes.md:4", out.String(), err)
+	}
+
+	pinFormat = config.PinFormatMarkdown
+	var copied string
+	copyToClipboard = func(s string) { copied = s }
+	pinExport = config.PinExportClipboard
+	out.Reset()
+	if err := exportPins(&out, pins); err != nil || copied != "- `notes.md:4` — wireguard config\n" || out.Len() != 0 {
+		t.Errorf("clipboard export copied %q, printed %q, %v", copied, out.String(), err)
+	}
+
+	pinExport = "pins.md"
+	if err := exportPins(&out, pins); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "pins.md")); err != nil || string(data) != copied {
+		t.Errorf("pins.md = %q, %v; want %q", data, err, copied)
+	}
+
+	pinExport = config.PinExportNone
+	if err := exportPins(&out, pins); err != nil || out.Len() != 0 {
+		t.Errorf("export none printed %q, %v", out.String(), err)
+	}
+	pinExport = config.PinExportStdout
+	if err := exportPins(&out, nil); err != nil || out.Len() != 0 {
+		t.Errorf("exporting no pins printed %q, %v", out.String(), err)
+	}
+}
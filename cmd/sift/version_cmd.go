@@ -1,23 +1,151 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"runtime"
 
 	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/config"
+	"github.com/tejas242/sift/internal/embed"
+	"github.com/tejas242/sift/internal/index"
 )
 
+// Set at build time via -ldflags; see the Makefile.
 var (
 	version = "dev"
 	commit  = "none"
 	date    = "unknown"
 )
 
+var (
+	versionJSON bool
+
+	// Probes behind `sift version`; tests replace them to avoid loading
+	// ONNX Runtime or hashing a real model.
+	probeRuntimeFunc = embed.ProbeRuntime
+	hashModelFunc    = embed.HashModel
+)
+
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
+	versionCmd := &cobra.Command{
 		Use:   "version",
-		Short: "Print the version number of sift",
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("sift version %s (%s) built on %s\n", version, commit, date)
+		Short: "Print version, runtime, model, and index provenance",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := collectVersionInfo()
+			if versionJSON {
+				j, err := json.MarshalIndent(info, "", "  ")
+				if err != nil {
+					return fmt.Errorf("marshal json: %w", err)
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(j))
+				return nil
+			}
+			writeVersionInfo(cmd.OutOrStdout(), info)
+			return nil
 		},
-	})
+	}
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "output as JSON")
+	rootCmd.AddCommand(versionCmd)
+
+	rootCmd.Version = version
+	rootCmd.SetVersionTemplate(fmt.Sprintf("sift version {{.Version}} (%s) built on %s\n", commit, date))
+}
+
+// versionInfo is the `sift version --json` schema. Values that could not be
+// determined are null; index is null when no index exists in scope.
+type versionInfo struct {
+	Version     string         `json:"version"`
+	Commit      string         `json:"commit"`
+	Date        string         `json:"date"`
+	Go          string         `json:"go"`
+	Platform    string         `json:"platform"`
+	ONNXRuntime versionRuntime `json:"onnxruntime"`
+	Model       versionModel   `json:"model"`
+	Index       *versionIndex  `json:"index"`
+}
+
+type versionRuntime struct {
+	Path    string  `json:"path"`
+	Version *string `json:"version"`
+	Error   string  `json:"error,omitempty"`
+}
+
+type versionModel struct {
+	Name string  `json:"name"`
+	Dir  string  `json:"dir"`
+	Hash *string `json:"hash"`
+}
+
+type versionIndex struct {
+	Dir           string `json:"dir"`
+	FormatVersion int    `json:"format_version"`
+	Model         string `json:"model"`
+	Dim           int    `json:"dim"`
+	ModelHash     string `json:"model_hash,omitempty"`
+}
+
+func collectVersionInfo() versionInfo {
+	info := versionInfo{
+		Version:  version,
+		Commit:   commit,
+		Date:     date,
+		Go:       runtime.Version(),
+		Platform: runtime.GOOS + "/" + runtime.GOARCH,
+		Model:    versionModel{Name: embed.ModelName, Dir: modelDir},
+	}
+
+	info.ONNXRuntime.Path = config.ResolveOrtLib(ortLib)
+	if v, err := probeRuntimeFunc(info.ONNXRuntime.Path); err != nil {
+		info.ONNXRuntime.Error = err.Error()
+	} else {
+		info.ONNXRuntime.Version = &v
+	}
+	if h, err := hashModelFunc(modelDir); err == nil {
+		info.Model.Hash = &h
+	}
+
+	if m, err := index.ReadManifest(indexDir); err == nil {
+		info.Index = &versionIndex{
+			Dir:           indexDir,
+			FormatVersion: m.FormatVersion,
+			Model:         m.Model,
+			Dim:           m.Dim,
+			ModelHash:     m.ModelHash,
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		logger.Warnf("read index manifest: %v", err)
+	}
+	return info
+}
+
+func writeVersionInfo(w io.Writer, info versionInfo) {
+	fmt.Fprintf(w, "sift version %s (%s) built on %s\n", info.Version, info.Commit, info.Date)
+	fmt.Fprintf(w, "go:          %s %s\n", info.Go, info.Platform)
+
+	lib := info.ONNXRuntime.Path
+	if lib == "" {
+		lib = "system default"
+	}
+	if info.ONNXRuntime.Version != nil {
+		fmt.Fprintf(w, "onnxruntime: %s (v%s)\n", lib, *info.ONNXRuntime.Version)
+	} else {
+		fmt.Fprintf(w, "onnxruntime: %s (unavailable: %s)\n", lib, info.ONNXRuntime.Error)
+	}
+
+	hash := "model.onnx not found"
+	if info.Model.Hash != nil {
+		hash = "sha256 " + *info.Model.Hash
+	}
+	fmt.Fprintf(w, "model:       %s in %s (%s)\n", info.Model.Name, info.Model.Dir, hash)
+
+	if info.Index == nil {
+		fmt.Fprintf(w, "index:       none in %s\n", indexDir)
+		return
+	}
+	fmt.Fprintf(w, "index:       %s (format v%d, built with %s, %d-dim)\n",
+		info.Index.Dir, info.Index.FormatVersion, info.Index.Model, info.Index.Dim)
 }
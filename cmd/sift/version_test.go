@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestVersionJSON(t *testing.T) {
+	siftDir := t.TempDir()
+	manifest := `{"format_version": 1, "model": "BGE-small-en-v1.5", "dim": 384, "model_hash": "abc123"}`
+	if err := os.WriteFile(filepath.Join(siftDir, "manifest.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	setGlobals(t, siftDir)
+	oldProbe, oldHash, oldJSON := probeRuntimeFunc, hashModelFunc, versionJSON
+	t.Cleanup(func() { probeRuntimeFunc, hashModelFunc, versionJSON = oldProbe, oldHash, oldJSON })
+	probeRuntimeFunc = func(string) (string, error) { return "1.24.1", nil }
+	hashModelFunc = func(string) (string, error) { return "deadbeef", nil }
+	versionJSON = true
+
+	decode := func() map[string]any {
+		t.Helper()
+		cmd := findCmd(t, "version")
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		defer cmd.SetOut(nil)
+		if err := cmd.RunE(cmd, nil); err != nil {
+			t.Fatal(err)
+		}
+		var got map[string]any
+		if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, out.String())
+		}
+		return got
+	}
+
+	got := decode()
+	var keys []string
+	for k := range got {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	want := []string{"commit", "date", "go", "index", "model", "onnxruntime", "platform", "version"}
+	if !slices.Equal(keys, want) {
+		t.Errorf("top-level keys = %v, want %v", keys, want)
+	}
+	if v := got["onnxruntime"].(map[string]any)["version"]; v != "1.24.1" {
+		t.Errorf("onnxruntime.version = %v", v)
+	}
+	if h := got["model"].(map[string]any)["hash"]; h != "deadbeef" {
+		t.Errorf("model.hash = %v", h)
+	}
+	idx, ok := got["index"].(map[string]any)
+	if !ok || idx["format_version"] != 1.0 || idx["model"] != "BGE-small-en-v1.5" || idx["model_hash"] != "abc123" {
+		t.Errorf("index = %v", got["index"])
+	}
+
+	// Missing pieces are null, not omitted.
+	probeRuntimeFunc = func(string) (string, error) { return "", errors.New("no library") }
+	hashModelFunc = func(string) (string, error) { return "", os.ErrNotExist }
+	indexDir = filepath.Join(t.TempDir(), "none")
+	got = decode()
+	if got["index"] != nil {
+		t.Errorf("index = %v, want null", got["index"])
+	}
+	rt := got["onnxruntime"].(map[string]any)
+	if v, ok := rt["version"]; !ok || v != nil || rt["error"] != "no library" {
+		t.Errorf("onnxruntime = %v, want null version with error", rt)
+	}
+	if h, ok := got["model"].(map[string]any)["hash"]; !ok || h != nil {
+		t.Errorf("model.hash = %v, want null", h)
+	}
+}
@@ -2,43 +2,84 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"os"
+	"errors"
 	"os/signal"
-	"syscall"
+	"slices"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/tejas242/sift/internal/throttle"
 	"github.com/tejas242/sift/internal/watcher"
 )
 
+// rescanInterval is how often watch rescans its roots; 0 disables it.
+var rescanInterval time.Duration
+
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "watch <dir> [dir...]",
-		Short: "Index a directory then watch it for changes",
-		Args:  cobra.MinimumNArgs(1),
+	watchCmd := &cobra.Command{
+		Use:               "watch <path> [path...]",
+		Short:             "Index directories or single files, then watch them for changes",
+		Args:              dirArgs,
+		ValidArgsFunction: completeRoots,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			if rescanInterval < 0 {
+				return &usageError{errors.New("--rescan-interval must not be negative")}
+			}
+			sink, err := newProgressSink(cmd.OutOrStdout(), "Scanning")
+			if err != nil {
+				return err
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
 			defer stop()
 
-			idx, err := openIndex(ortLib)
+			idx, err := openIndexWithPatterns()
 			if err != nil {
 				return err
 			}
 			defer idx.Close()
-
-			if err := indexDirs(ctx, idx, args); err != nil {
+			if args, err = indexRoots(idx, args); err != nil {
 				return err
 			}
+
+			// Arguments that could not be indexed are reported and left
+			// unwatched; the others are watched as usual.
+			if err := indexDirs(ctx, idx, args, false, sink); err != nil {
+				var bad argErrors
+				if !errors.As(err, &bad) {
+					return abortRun(cmd.OutOrStdout(), "watch", idx, err)
+				}
+				if args = slices.DeleteFunc(args, bad.has); len(args) == 0 {
+					return err
+				}
+				logger.Errorf("%v", err)
+			}
 			if err := idx.Flush(); err != nil {
 				return err
 			}
+			if err := finishRun(cmd.OutOrStdout(), "watch", idx); err != nil {
+				return err
+			}
 			s := idx.Stats()
-			fmt.Fprintf(os.Stderr, "Done. %d chunks indexed. Watching for changes… (Ctrl+C to stop)\n", s.NumChunks)
+			logger.Infof("Done. %d chunks indexed. Watching for changes… (Ctrl+C to stop)", s.NumChunks)
+			if l := pace.Limits(); l.Mode == throttle.Low {
+				logger.Infof("Priority: %s", l)
+			}
 
 			w, err := watcher.New(idx)
 			if err != nil {
 				return err
 			}
+			w.SetLogger(logger)
+			w.SetThrottle(pace)
+			if p, ok := sink.(*jsonProgress); ok {
+				// The change events follow the initial run's on stdout.
+				w.SetEvents(p.enc)
+			}
+			onReloadSignal(ctx, func() {
+				if _, err := reloadConfig(cmd, idx, w); err != nil {
+					logger.Errorf("%v", err)
+				}
+			})
 
 			done := make(chan struct{})
 			go func() {
@@ -49,12 +90,32 @@ func init() {
 			for _, dir := range args {
 				go func(d string) {
 					if err := w.Watch(d, done); err != nil {
-						fmt.Fprintf(os.Stderr, "watch error %s: %v\n", d, err)
+						logger.Errorf("watch error %s: %v", d, err)
 					}
 				}(dir)
 			}
+			defer startMaintenance(ctx, idx)()
+			if rescanInterval > 0 {
+				// fsnotify can drop events; catch up periodically.
+				ticker := time.NewTicker(rescanInterval)
+				defer ticker.Stop()
+				go w.RescanOn(ctx, args, ticker.C)
+			}
 			<-done
+			if st := w.Status(); st.Coalesced > 0 || st.Collapses > 0 || st.Suppressed > 0 {
+				logger.Infof("watch: %d events re-indexed by directory, %d left to %d rescans, %d messages suppressed",
+					st.Coalesced, st.Dropped, st.Collapses, st.Suppressed)
+			}
+			if st := w.Status(); st.Pauses > 0 {
+				logger.Infof("watch: %s priority, paused %d times (%s) for a busy system",
+					st.Priority, st.Pauses, durationText(st.Paused))
+			}
 			return nil
 		},
-	})
+	}
+	addPatternFlags(watchCmd)
+	addProgressFlag(watchCmd)
+	addMaintenanceFlag(watchCmd)
+	watchCmd.Flags().DurationVar(&rescanInterval, "rescan-interval", 0, "also rescan the watched directories this often, catching missed events (e.g. 30m; 0 disables)")
+	rootCmd.AddCommand(watchCmd)
 }
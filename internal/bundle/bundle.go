@@ -0,0 +1,266 @@
+// Package bundle assembles search results into a size-bounded context
+// bundle for LLM prompts: full chunk text, grouped and merged per file.
+package bundle
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// Options controls Build.
+type Options struct {
+	// Budget is the maximum size of the rendered Markdown, in Count units.
+	Budget int
+	// Count measures text, normally in tokens. Nil counts bytes.
+	Count func(string) int
+	// FileChunks returns every stored chunk of a file, ordered by
+	// ChunkIndex; it is used to expand hits to their neighbours.
+	FileChunks func(path string) []index.ChunkMeta
+	// Label maps a chunk path to the label shown in the bundle. Nil keeps it.
+	Label func(path string) string
+}
+
+// Section is one contiguous region of a file.
+type Section struct {
+	// Chunks lists the IDs of the chunks merged into the section.
+	Chunks    []string `json:"chunks"`
+	Path      string   `json:"path"`
+	StartLine int      `json:"start_line"`
+	EndLine   int      `json:"end_line"`
+	StartByte int64    `json:"start_byte"`
+	EndByte   int64    `json:"end_byte"`
+	Score     float32  `json:"score"`
+	Text      string   `json:"text"`
+}
+
+// Bundle is the assembled context.
+type Bundle struct {
+	Query    string    `json:"query"`
+	Budget   int       `json:"budget"`
+	Size     int       `json:"size"`
+	Sections []Section `json:"sections"`
+}
+
+// key identifies one stored chunk.
+type key struct {
+	path  string
+	chunk int
+}
+
+// builder tracks the chunks selected so far.
+type builder struct {
+	opts     Options
+	query    string
+	meta     map[key]index.ChunkMeta
+	score    map[key]float32 // hit score; neighbours have none
+	selected map[key]bool
+}
+
+// Build selects chunks from hits (best first) until the rendered bundle
+// would exceed opts.Budget. Hits are taken round-robin across files so one
+// long file cannot crowd out the rest; remaining budget then goes to the
+// chunks either side of each hit. Overlapping and adjacent chunks of a
+// file are merged into one section.
+func Build(query string, hits []index.SearchResult, opts Options) *Bundle {
+	if opts.Count == nil {
+		opts.Count = func(s string) int { return len(s) }
+	}
+	b := &builder{
+		opts:     opts,
+		query:    query,
+		meta:     make(map[key]index.ChunkMeta),
+		score:    make(map[key]float32),
+		selected: make(map[key]bool),
+	}
+	metas := make([]index.ChunkMeta, len(hits))
+	for i, h := range hits {
+		metas[i] = h.Meta
+	}
+	// Read previews back a file at a time: hits cluster in few files.
+	index.FullTexts(metas)
+	for i, h := range hits {
+		k := key{h.Meta.Path, h.Meta.ChunkIndex}
+		if _, seen := b.score[k]; !seen {
+			b.meta[k] = metas[i]
+			b.score[k] = h.Score
+		}
+	}
+
+	for _, h := range diversify(hits) {
+		b.try(key{h.Meta.Path, h.Meta.ChunkIndex})
+	}
+
+	if opts.FileChunks != nil {
+		for _, h := range hits {
+			k := key{h.Meta.Path, h.Meta.ChunkIndex}
+			if !b.selected[k] {
+				continue
+			}
+			for _, c := range opts.FileChunks(k.path) {
+				if c.ChunkIndex == k.chunk-1 || c.ChunkIndex == k.chunk+1 {
+					nk := key{c.Path, c.ChunkIndex}
+					if _, ok := b.meta[nk]; !ok {
+						b.meta[nk] = withFullText(c)
+					}
+					b.try(nk)
+				}
+			}
+		}
+	}
+
+	return b.bundle()
+}
+
+// withFullText returns c with its complete text when the index stored only
+// a preview, so sections merge whole chunks.
+func withFullText(c index.ChunkMeta) index.ChunkMeta {
+	if text, err := c.FullText(); err == nil {
+		c.Text, c.Truncated = text, false
+	}
+	return c
+}
+
+// try selects k if the bundle still fits the budget afterwards.
+func (b *builder) try(k key) {
+	if b.selected[k] {
+		return
+	}
+	b.selected[k] = true
+	if bd := b.bundle(); bd.Size > b.opts.Budget {
+		delete(b.selected, k)
+	}
+}
+
+// bundle renders the current selection.
+func (b *builder) bundle() *Bundle {
+	byFile := make(map[string][]index.ChunkMeta)
+	for k := range b.selected {
+		byFile[k.path] = append(byFile[k.path], b.meta[k])
+	}
+
+	var sections []Section
+	for path, chunks := range byFile {
+		slices.SortFunc(chunks, func(a, c index.ChunkMeta) int { return a.ChunkIndex - c.ChunkIndex })
+		for i := 0; i < len(chunks); {
+			j := i + 1
+			for j < len(chunks) && chunks[j].ChunkIndex == chunks[j-1].ChunkIndex+1 {
+				j++
+			}
+			sections = append(sections, b.section(path, chunks[i:j]))
+			i = j
+		}
+	}
+	slices.SortFunc(sections, func(a, c Section) int {
+		if r := cmp.Compare(c.Score, a.Score); r != 0 {
+			return r
+		}
+		if r := strings.Compare(a.Path, c.Path); r != 0 {
+			return r
+		}
+		return cmp.Compare(a.StartByte, c.StartByte)
+	})
+
+	bd := &Bundle{Query: b.query, Budget: b.opts.Budget, Sections: sections}
+	bd.Size = b.opts.Count(Markdown(bd))
+	return bd
+}
+
+// section merges a run of consecutive chunks of one file.
+func (b *builder) section(path string, run []index.ChunkMeta) Section {
+	s := Section{
+		Path:      path,
+		StartLine: run[0].LineNum,
+		StartByte: run[0].StartByte,
+		EndByte:   run[len(run)-1].EndByte,
+		Text:      run[0].Text,
+	}
+	for _, c := range run {
+		s.Chunks = append(s.Chunks, c.ID)
+	}
+	if b.opts.Label != nil {
+		s.Path = b.opts.Label(path)
+	}
+	for i, c := range run[1:] {
+		s.Text = mergeOverlap(s.Text, c.Text, run[i].EndByte-c.StartByte)
+	}
+	for _, c := range run {
+		if sc := b.score[key{c.Path, c.ChunkIndex}]; sc > s.Score {
+			s.Score = sc
+		}
+	}
+	s.EndLine = s.StartLine + strings.Count(s.Text, "\n")
+	return s
+}
+
+// mergeOverlap joins consecutive chunk texts whose source ranges share
+// overlap bytes, dropping the part the chunker repeated at the start of b
+// (the longest suffix of a, at most overlap bytes, that is a prefix of b).
+// Chunk text is trimmed, so chunks that don't overlap are joined with a
+// paragraph break, the chunker's preferred split.
+func mergeOverlap(a, b string, overlap int64) string {
+	for n := min(int64(len(a)), int64(len(b)), overlap); n > 0; n-- {
+		if strings.HasSuffix(a, b[:n]) {
+			return a + b[n:]
+		}
+	}
+	return a + "\n\n" + b
+}
+
+// diversify reorders hits round-robin across files: every file's best hit
+// first (in rank order), then every file's second, and so on.
+func diversify(hits []index.SearchResult) []index.SearchResult {
+	var files []string
+	perFile := make(map[string][]index.SearchResult)
+	for _, h := range hits {
+		if _, ok := perFile[h.Meta.Path]; !ok {
+			files = append(files, h.Meta.Path)
+		}
+		perFile[h.Meta.Path] = append(perFile[h.Meta.Path], h)
+	}
+	out := make([]index.SearchResult, 0, len(hits))
+	for round := 0; len(out) < len(hits); round++ {
+		for _, f := range files {
+			if round < len(perFile[f]) {
+				out = append(out, perFile[f][round])
+			}
+		}
+	}
+	return out
+}
+
+// Markdown renders bd as fenced, path-labelled sections.
+func Markdown(bd *Bundle) string {
+	var sb strings.Builder
+	for i, s := range bd.Sections {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fence := "```"
+		for strings.Contains(s.Text, fence) {
+			fence += "`"
+		}
+		fmt.Fprintf(&sb, "%s:%d-%d\n%s%s\n%s\n%s\n", s.Path, s.StartLine, s.EndLine, fence, lang(s.Path), s.Text, fence)
+	}
+	return sb.String()
+}
+
+// lang returns the fence info string for path's extension.
+func lang(path string) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	switch ext {
+	case "md":
+		return "markdown"
+	case "yml":
+		return "yaml"
+	case "sh", "bash", "zsh":
+		return "sh"
+	case "txt", "":
+		return ""
+	}
+	return ext
+}
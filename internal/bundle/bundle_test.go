@@ -0,0 +1,110 @@
+package bundle
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/chunker"
+	"github.com/tejas242/sift/internal/index"
+)
+
+// fixtureChunks chunks testdata/guide.md the way the indexer does.
+func fixtureChunks(t *testing.T) (string, []index.ChunkMeta) {
+	t.Helper()
+	path := filepath.Join("testdata", "guide.md")
+	chunks, err := chunker.ChunkFile(path, chunker.Options{MaxBytes: 600, OverlapBytes: 150})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 5 {
+		t.Fatalf("fixture produced only %d chunks", len(chunks))
+	}
+	metas := make([]index.ChunkMeta, len(chunks))
+	for i, c := range chunks {
+		metas[i] = index.ChunkMeta{Path: c.Path, LineNum: c.LineNum, StartByte: c.StartByte,
+			EndByte: c.EndByte, ChunkIndex: c.Index, Text: c.Text}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data)), metas
+}
+
+func hit(m index.ChunkMeta, score float32) index.SearchResult {
+	return index.SearchResult{Meta: m, Score: score}
+}
+
+func TestBuild_MergesOverlap(t *testing.T) {
+	text, chunks := fixtureChunks(t)
+	var hits []index.SearchResult
+	for i, c := range chunks {
+		hits = append(hits, hit(c, 1-float32(i)/100))
+	}
+	b := Build("q", hits, Options{Budget: 1 << 20})
+	if len(b.Sections) != 1 {
+		t.Fatalf("got %d sections, want 1 merged section", len(b.Sections))
+	}
+	s := b.Sections[0]
+	if s.Text != text {
+		t.Errorf("merged text does not reproduce the file:\n%q\nwant\n%q", s.Text, text)
+	}
+	if s.StartLine != 1 || s.EndLine != 1+strings.Count(text, "\n") {
+		t.Errorf("lines = %d-%d", s.StartLine, s.EndLine)
+	}
+}
+
+func TestBuild_Budget(t *testing.T) {
+	_, chunks := fixtureChunks(t)
+	var hits []index.SearchResult
+	for i, c := range chunks {
+		hits = append(hits, hit(c, 1-float32(i)/100))
+	}
+	words := func(s string) int { return len(strings.Fields(s)) }
+	for _, budget := range []int{0, 50, 150, 300, 1000} {
+		b := Build("q", hits, Options{Budget: budget, Count: words})
+		if b.Size > budget {
+			t.Errorf("budget %d: size %d over budget", budget, b.Size)
+		}
+		if got := words(Markdown(b)); got != b.Size {
+			t.Errorf("budget %d: Size = %d, rendered = %d", budget, b.Size, got)
+		}
+		if budget >= 300 && len(b.Sections) == 0 {
+			t.Errorf("budget %d: nothing selected", budget)
+		}
+	}
+}
+
+func TestBuild_DiversityAndNeighbours(t *testing.T) {
+	_, chunks := fixtureChunks(t)
+	other := index.ChunkMeta{Path: "other.go", LineNum: 3, ChunkIndex: 0, Text: "func other() {}"}
+
+	// Three strong hits in guide.md, one weaker in other.go; room for
+	// roughly two chunks.
+	hits := []index.SearchResult{hit(chunks[0], 0.9), hit(chunks[2], 0.85), hit(chunks[4], 0.8), hit(other, 0.5)}
+	b := Build("q", hits, Options{Budget: len(chunks[0].Text) + len(other.Text) + 200})
+	var paths []string
+	for _, s := range b.Sections {
+		paths = append(paths, s.Path)
+	}
+	if len(paths) != 2 || paths[0] != chunks[0].Path || paths[1] != "other.go" {
+		t.Errorf("sections = %v, want the best guide.md chunk and other.go", paths)
+	}
+
+	// With budget to spare, a hit grows to include its neighbours.
+	fileChunks := func(path string) []index.ChunkMeta {
+		if path == chunks[0].Path {
+			return chunks
+		}
+		return nil
+	}
+	b = Build("q", []index.SearchResult{hit(chunks[2], 0.9)}, Options{Budget: 1 << 20, FileChunks: fileChunks})
+	if len(b.Sections) != 1 {
+		t.Fatalf("got %d sections, want 1", len(b.Sections))
+	}
+	if s := b.Sections[0]; s.StartByte != chunks[1].StartByte || s.EndByte != chunks[3].EndByte {
+		t.Errorf("section spans bytes %d-%d, want chunks 1-3 (%d-%d)", s.StartByte, s.EndByte, chunks[1].StartByte, chunks[3].EndByte)
+	}
+}
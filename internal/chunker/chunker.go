@@ -100,6 +100,15 @@ func ChunkFile(path string, opts Options) ([]Chunk, error) {
 	return chunkBytes(data, path, opts)
 }
 
+// ChunkBytes is ChunkFile for data, the contents of the file at path
+// already read.
+func ChunkBytes(data []byte, path string, opts Options) ([]Chunk, error) {
+	if opts.MaxBytes <= 0 {
+		opts = DefaultOptions()
+	}
+	return chunkBytes(data, path, opts)
+}
+
 // chunkBytes performs semantic text splitting.
 func chunkBytes(data []byte, path string, opts Options) ([]Chunk, error) {
 	text := string(data)
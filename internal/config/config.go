@@ -2,9 +2,16 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/pelletier/go-toml/v2"
 )
@@ -15,68 +22,1009 @@ type Config struct {
 	OrtLib    string `toml:"ort-lib"`
 	Threads   int    `toml:"threads"`
 	MaxFileKB int    `toml:"max-file-kb"`
+	// EmbedSessions is how many ONNX sessions the model runs inference
+	// on: 1 serializes concurrent embedding, more run that many batches
+	// in parallel, each with Threads threads and its own memory.
+	EmbedSessions int `toml:"embed-sessions"`
+	// BackgroundSessions caps how many of those sessions the watcher's
+	// background indexing holds at once, leaving the others to searches
+	// and manual indexing; 0 lets it use all of them.
+	BackgroundSessions int `toml:"background-sessions"`
+	// LowPriority runs indexing in low-priority mode, out of the way of
+	// the rest of the machine: at a raised nice level, on one ONNX thread
+	// and session, pausing between files while the system is busy. It
+	// overrides Threads and BackgroundSessions; see throttle.Derive.
+	// Searches are not affected.
+	LowPriority bool `toml:"low-priority"`
+	// Oversized is what index runs do with files over MaxFileKB: skip
+	// them or index a sample of their chunks, for every extension or per
+	// extension; see ParseOversized.
+	Oversized string `toml:"oversized"`
+	// Preview is how much of each chunk's text the index stores: "full",
+	// or a number of characters; see ParsePreview.
+	Preview string `toml:"preview"`
+	// RedactPattern is a regular expression for secrets of the project's
+	// own to redact from stored chunk text, besides the built-in shapes;
+	// see index.SetRedactPattern.
+	RedactPattern string `toml:"redact-pattern"`
+	// Categories are the project's own rules for the category index runs
+	// tag each file with, tried before the built-in ones, as a
+	// comma-separated list of "category=glob"; see ParseCategories.
+	Categories string `toml:"categories"`
+	// CompressMeta is when meta.json is stored gzipped: CompressAuto,
+	// CompressAlways or CompressNever.
+	CompressMeta string `toml:"compress-meta"`
+	// PruneAfter is how old an index must be for opening it to drop files
+	// deleted from disk, as a duration ("168h"); "0" never does.
+	PruneAfter string `toml:"prune-after"`
+	// LowMemory makes search-only commands read the index's vectors from
+	// disk on demand instead of loading them all.
+	LowMemory bool `toml:"low-memory"`
+	// Preload is whether opening the index reads the files it left on
+	// disk through in the background, warming the page cache for the
+	// first search: "true", "false", or PreloadAuto; see ParsePreload.
+	Preload string `toml:"preload"`
+	// Calibrate rescales each query's scores onto [0, 1] across its
+	// candidates, so score thresholds mean the same for every query.
+	Calibrate bool `toml:"calibrate"`
+	// MaxPerDir caps how many search results may share a parent
+	// directory; 0 is unlimited.
+	MaxPerDir int `toml:"max-per-dir"`
+	// ResultCache is how many recent searches an open index keeps the
+	// results of, 0 disabling the cache, and ResultCacheTTL how long it
+	// reuses them, as a duration ("5m"); see ParseResultCacheTTL.
+	ResultCache    int    `toml:"result-cache"`
+	ResultCacheTTL string `toml:"result-cache-ttl"`
+	// Stopwords are the query words never given the keyword boost; see
+	// ParseStopwords. MinBoostWords is how many other words a query needs
+	// to be boosted at all.
+	Stopwords     string `toml:"stopwords"`
+	MinBoostWords int    `toml:"min-boost-words"`
+	// ExpandQueries is how many synonym variants of each query searches
+	// try besides it, up to MaxExpandQueries; 0 turns expansion off.
+	// Synonyms are the groups of words swapped; see ParseSynonyms.
+	ExpandQueries int    `toml:"expand-queries"`
+	Synonyms      string `toml:"synonyms"`
+	// MaxEmbedFailures is how many files failing to embed in a row make
+	// an indexing run give up, and MaxEmbedFailurePct the percentage of
+	// files that may fail; 0 disables either check.
+	MaxEmbedFailures   int `toml:"max-embed-failures"`
+	MaxEmbedFailurePct int `toml:"max-embed-failure-pct"`
+	// MaintenanceInterval and MaintenanceStalePct are how often watch and
+	// serve compact the index, as a duration ("24h"), and the percentage
+	// of indexed files gone from disk that makes them compact it sooner;
+	// MaintenanceIdle is how long the index must go unchanged first. "0"
+	// and 0 disable either trigger; see ParseMaintenanceDuration.
+	MaintenanceInterval string `toml:"maintenance-interval"`
+	MaintenanceStalePct int    `toml:"maintenance-stale-pct"`
+	MaintenanceIdle     string `toml:"maintenance-idle"`
+	// QueryPrefix and DocumentPrefix are put in front of queries and
+	// document chunks before they are embedded; with Symmetric set,
+	// queries get DocumentPrefix too, for models that embed both sides
+	// alike.
+	QueryPrefix    string `toml:"query-prefix"`
+	DocumentPrefix string `toml:"document-prefix"`
+	Symmetric      bool   `toml:"symmetric"`
+	// EditorCommand is how the TUI opens a result, as a command line
+	// with {path}, {line} and {col} placeholders ("hx {path}:{line}");
+	// "" picks one for $EDITOR. See ParseEditorCommand.
+	EditorCommand string `toml:"editor-command"`
+	// PinExport is where the TUI writes the results pinned in a session
+	// when it quits: PinExportStdout, PinExportClipboard, PinExportNone or
+	// a file path. PinFormat is PinFormatLines or PinFormatMarkdown.
+	PinExport string `toml:"pin-export"`
+	PinFormat string `toml:"pin-format"`
+	IndexDir  string `toml:"index-dir"`
+	// IndexLocation is LocationProject or LocationXDG.
+	IndexLocation string `toml:"index-location"`
+	// Global is set by the "global" flag; IndexDir is then GlobalIndexDir.
+	Global bool `toml:"-"`
+	// Profile is the [profile.<name>] table selected by the "profile" flag
+	// or $SIFT_PROFILE, or "".
+	Profile string `toml:"-"`
+	// Root is the project root: the nearest directory at or above the
+	// working directory holding the index, a .sift.toml, or a .git, or the
+	// working directory itself if there is none or the "here" flag is set.
+	Root string `toml:"-"`
+	// rootRelative reports whether a relative IndexDir is taken relative
+	// to Root (the default, or a value from the discovered project file)
+	// rather than the working directory; see IndexPath.
+	rootRelative bool
+
+	// UserFile is the user-level config file that was read, or "".
+	UserFile string `toml:"-"`
+	// File is the project config file that was read, or "" if none was found.
+	File string `toml:"-"`
+	// Sources records where each setting's effective value came from,
+	// keyed by setting name (e.g. "model-dir").
+	Sources map[string]Source `toml:"-"`
 }
 
 const (
 	// DefaultModelDir is the default directory where ONNX models are stored.
 	DefaultModelDir = "./models"
 	// DefaultSiftDir is the default directory where index data is persisted.
-	DefaultSiftDir  = ".sift"
-	// DefaultOrtLib is the default fallback path to onnxruntime.so.
-	DefaultOrtLib   = "./lib/onnxruntime.so"
+	DefaultSiftDir = ".sift"
 	// DefaultThreads is the default intra-op thread count for ONNX.
-	DefaultThreads  = 0
+	DefaultThreads = 0
+	// DefaultEmbedSessions is the default of embed-sessions.
+	DefaultEmbedSessions = 1
 	// DefaultMaxFile is the default file size skip limit in KB.
-	DefaultMaxFile  = 512
+	DefaultMaxFile = 512
+	// StopwordsDefault selects the built-in English stopword list; it is
+	// the default. StopwordsNone boosts every query word.
+	StopwordsDefault = "default"
+	StopwordsNone    = "none"
+	// DefaultMinBoostWords is the default of min-boost-words.
+	DefaultMinBoostWords = 1
+	// MaxExpandQueries is the largest expand-queries allowed.
+	MaxExpandQueries = 4
+	// SynonymsDefault selects the built-in programming synonyms; it is
+	// the default. SynonymsNone selects none.
+	SynonymsDefault = "default"
+	SynonymsNone    = "none"
+	// OversizedSkip leaves files over max-file-kb unindexed; it is the
+	// default. OversizedSample indexes a sample of their chunks.
+	OversizedSkip   = "skip"
+	OversizedSample = "sample"
+	// PreloadAuto preloads the index for the commands that keep it open
+	// for searches typed later (tui, serve); it is the default.
+	PreloadAuto = "auto"
+	// PreviewFull stores each chunk's complete text; it is the default.
+	PreviewFull = "full"
+	// CompressAuto gzips meta.json once it is large; it is the default.
+	CompressAuto = "auto"
+	// CompressAlways always gzips meta.json.
+	CompressAlways = "always"
+	// CompressNever stores meta.json as plain JSON.
+	CompressNever = "never"
+	// DefaultMaxEmbedFailures and DefaultMaxEmbedFailurePct are the
+	// defaults of max-embed-failures and max-embed-failure-pct.
+	DefaultMaxEmbedFailures   = 10
+	DefaultMaxEmbedFailurePct = 20
+	// DefaultMaintenanceInterval, DefaultMaintenanceStalePct and
+	// DefaultMaintenanceIdle are the defaults of maintenance-interval,
+	// maintenance-stale-pct and maintenance-idle.
+	DefaultMaintenanceInterval = "24h"
+	DefaultMaintenanceStalePct = 10
+	DefaultMaintenanceIdle     = "10m"
+	// PinExportStdout prints the pinned results after the TUI exits; it
+	// is the default. PinExportClipboard copies them to the clipboard
+	// (OSC 52) instead, and PinExportNone drops them.
+	PinExportStdout    = "stdout"
+	PinExportClipboard = "clipboard"
+	PinExportNone      = "none"
+	// PinFormatLines exports one path:line per pinned result; it is the
+	// default. PinFormatMarkdown exports a Markdown list with snippets.
+	PinFormatLines    = "lines"
+	PinFormatMarkdown = "markdown"
+	// DefaultQueryPrefix is the instruction BGE-small-en-v1.5 expects in
+	// front of queries (embed.BGEQueryPrefix).
+	DefaultQueryPrefix = "Represent this sentence for searching relevant passages: "
+	// DefaultPruneAfter is the default prune-after: a week.
+	DefaultPruneAfter = "168h"
+	// DefaultResultCache and DefaultResultCacheTTL are the defaults of
+	// result-cache and result-cache-ttl.
+	DefaultResultCache    = 64
+	DefaultResultCacheTTL = "5m"
+	// DefaultFile is the config file read from the project root.
+	DefaultFile = ".sift.toml"
+
+	// ConfigEnv names the environment variable that points at a config file.
+	ConfigEnv = "SIFT_CONFIG"
+	// ProfileEnv names the environment variable that selects a profile.
+	ProfileEnv = "SIFT_PROFILE"
+	// ProfileTable is the TOML table holding named profiles:
+	// [profile.docs], [profile.code], ...
+	ProfileTable = "profile"
 )
 
-// Load parses .sift.toml if it exists and returns a Config with merged defaults.
+// DefaultOrtLib is the default fallback path to the onnxruntime shared
+// library (onnxruntime.so, or onnxruntime.dll on Windows).
+var DefaultOrtLib = "./lib/" + OrtLibName(runtime.GOOS)
+
+// Source identifies which layer supplied a setting's value.
+// Precedence is flag > env > file > user file > default.
+type Source int
+
+const (
+	SourceDefault Source = iota
+	SourceUserFile
+	SourceFile
+	SourceEnv
+	SourceFlag
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceUserFile:
+		return "user file"
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "env"
+	case SourceFlag:
+		return "flag"
+	default:
+		return "default"
+	}
+}
+
+// Setting describes one global setting and how it is named in each layer.
+// The TOML key doubles as the flag name.
+type Setting struct {
+	Key string
+	Env string
+	get func(*Config) string
+	set func(*Config, string) error
+}
+
+// Settings lists every resolvable global setting in display order.
+var Settings = []Setting{
+	{"model-dir", "SIFT_MODEL_DIR",
+		func(c *Config) string { return c.ModelDir },
+		func(c *Config, v string) error { c.ModelDir = v; return nil }},
+	{"ort-lib", "SIFT_ORT_LIB",
+		func(c *Config) string { return c.OrtLib },
+		func(c *Config, v string) error { c.OrtLib = v; return nil }},
+	{"threads", "SIFT_THREADS",
+		func(c *Config) string { return strconv.Itoa(c.Threads) },
+		func(c *Config, v string) error { return setInt(&c.Threads, v) }},
+	{"embed-sessions", "SIFT_EMBED_SESSIONS",
+		func(c *Config) string { return strconv.Itoa(c.EmbedSessions) },
+		func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return fmt.Errorf("want a positive integer, got %q", v)
+			}
+			c.EmbedSessions = n
+			return nil
+		}},
+	{"background-sessions", "SIFT_BACKGROUND_SESSIONS",
+		func(c *Config) string { return strconv.Itoa(c.BackgroundSessions) },
+		func(c *Config, v string) error { return setInt(&c.BackgroundSessions, v) }},
+	{"low-priority", "SIFT_LOW_PRIORITY",
+		func(c *Config) string { return strconv.FormatBool(c.LowPriority) },
+		func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("want true or false, got %q", v)
+			}
+			c.LowPriority = b
+			return nil
+		}},
+	{"max-file-kb", "SIFT_MAX_FILE_KB",
+		func(c *Config) string { return strconv.Itoa(c.MaxFileKB) },
+		func(c *Config, v string) error { return setInt(&c.MaxFileKB, v) }},
+	{"oversized", "SIFT_OVERSIZED",
+		func(c *Config) string { return c.Oversized },
+		func(c *Config, v string) error {
+			if _, _, err := ParseOversized(v); err != nil {
+				return err
+			}
+			c.Oversized = v
+			return nil
+		}},
+	{"preview", "SIFT_PREVIEW",
+		func(c *Config) string { return c.Preview },
+		func(c *Config, v string) error {
+			if _, err := ParsePreview(v); err != nil {
+				return err
+			}
+			c.Preview = v
+			return nil
+		}},
+	{"redact-pattern", "SIFT_REDACT_PATTERN",
+		func(c *Config) string { return c.RedactPattern },
+		func(c *Config, v string) error {
+			if _, err := ParseRedactPattern(v); err != nil {
+				return err
+			}
+			c.RedactPattern = v
+			return nil
+		}},
+	{"categories", "SIFT_CATEGORIES",
+		func(c *Config) string { return c.Categories },
+		func(c *Config, v string) error {
+			if _, err := ParseCategories(v); err != nil {
+				return err
+			}
+			c.Categories = v
+			return nil
+		}},
+	{"compress-meta", "SIFT_COMPRESS_META",
+		func(c *Config) string { return c.CompressMeta },
+		func(c *Config, v string) error {
+			if v != CompressAuto && v != CompressAlways && v != CompressNever {
+				return fmt.Errorf("want %q, %q or %q, got %q", CompressAuto, CompressAlways, CompressNever, v)
+			}
+			c.CompressMeta = v
+			return nil
+		}},
+	{"prune-after", "SIFT_PRUNE_AFTER",
+		func(c *Config) string { return c.PruneAfter },
+		func(c *Config, v string) error {
+			if _, err := ParsePruneAfter(v); err != nil {
+				return err
+			}
+			c.PruneAfter = v
+			return nil
+		}},
+	{"low-memory", "SIFT_LOW_MEMORY",
+		func(c *Config) string { return strconv.FormatBool(c.LowMemory) },
+		func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("want true or false, got %q", v)
+			}
+			c.LowMemory = b
+			return nil
+		}},
+	{"preload", "SIFT_PRELOAD",
+		func(c *Config) string { return c.Preload },
+		func(c *Config, v string) error {
+			if _, _, err := ParsePreload(v); err != nil {
+				return err
+			}
+			c.Preload = v
+			return nil
+		}},
+	{"calibrate", "SIFT_CALIBRATE",
+		func(c *Config) string { return strconv.FormatBool(c.Calibrate) },
+		func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("want true or false, got %q", v)
+			}
+			c.Calibrate = b
+			return nil
+		}},
+	{"max-per-dir", "SIFT_MAX_PER_DIR",
+		func(c *Config) string { return strconv.Itoa(c.MaxPerDir) },
+		func(c *Config, v string) error { return setInt(&c.MaxPerDir, v) }},
+	{"result-cache", "SIFT_RESULT_CACHE",
+		func(c *Config) string { return strconv.Itoa(c.ResultCache) },
+		func(c *Config, v string) error { return setInt(&c.ResultCache, v) }},
+	{"result-cache-ttl", "SIFT_RESULT_CACHE_TTL",
+		func(c *Config) string { return c.ResultCacheTTL },
+		func(c *Config, v string) error {
+			if _, err := ParseResultCacheTTL(v); err != nil {
+				return err
+			}
+			c.ResultCacheTTL = v
+			return nil
+		}},
+	{"stopwords", "SIFT_STOPWORDS",
+		func(c *Config) string { return c.Stopwords },
+		func(c *Config, v string) error {
+			if _, _, err := ParseStopwords(v); err != nil {
+				return err
+			}
+			c.Stopwords = v
+			return nil
+		}},
+	{"min-boost-words", "SIFT_MIN_BOOST_WORDS",
+		func(c *Config) string { return strconv.Itoa(c.MinBoostWords) },
+		func(c *Config, v string) error { return setInt(&c.MinBoostWords, v) }},
+	{"expand-queries", "SIFT_EXPAND_QUERIES",
+		func(c *Config) string { return strconv.Itoa(c.ExpandQueries) },
+		func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > MaxExpandQueries {
+				return fmt.Errorf("want a number of variants from 0 to %d, got %q", MaxExpandQueries, v)
+			}
+			c.ExpandQueries = n
+			return nil
+		}},
+	{"synonyms", "SIFT_SYNONYMS",
+		func(c *Config) string { return c.Synonyms },
+		func(c *Config, v string) error {
+			if _, _, err := ParseSynonyms(v); err != nil {
+				return err
+			}
+			c.Synonyms = v
+			return nil
+		}},
+	{"max-embed-failures", "SIFT_MAX_EMBED_FAILURES",
+		func(c *Config) string { return strconv.Itoa(c.MaxEmbedFailures) },
+		func(c *Config, v string) error { return setInt(&c.MaxEmbedFailures, v) }},
+	{"max-embed-failure-pct", "SIFT_MAX_EMBED_FAILURE_PCT",
+		func(c *Config) string { return strconv.Itoa(c.MaxEmbedFailurePct) },
+		func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > 100 {
+				return fmt.Errorf("want a percentage from 0 to 100, got %q", v)
+			}
+			c.MaxEmbedFailurePct = n
+			return nil
+		}},
+	{"maintenance-interval", "SIFT_MAINTENANCE_INTERVAL",
+		func(c *Config) string { return c.MaintenanceInterval },
+		func(c *Config, v string) error {
+			if _, err := ParseMaintenanceDuration(v); err != nil {
+				return err
+			}
+			c.MaintenanceInterval = v
+			return nil
+		}},
+	{"maintenance-stale-pct", "SIFT_MAINTENANCE_STALE_PCT",
+		func(c *Config) string { return strconv.Itoa(c.MaintenanceStalePct) },
+		func(c *Config, v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > 100 {
+				return fmt.Errorf("want a percentage from 0 to 100, got %q", v)
+			}
+			c.MaintenanceStalePct = n
+			return nil
+		}},
+	{"maintenance-idle", "SIFT_MAINTENANCE_IDLE",
+		func(c *Config) string { return c.MaintenanceIdle },
+		func(c *Config, v string) error {
+			if _, err := ParseMaintenanceDuration(v); err != nil {
+				return err
+			}
+			c.MaintenanceIdle = v
+			return nil
+		}},
+	{"query-prefix", "SIFT_QUERY_PREFIX",
+		func(c *Config) string { return c.QueryPrefix },
+		func(c *Config, v string) error { c.QueryPrefix = v; return nil }},
+	{"document-prefix", "SIFT_DOCUMENT_PREFIX",
+		func(c *Config) string { return c.DocumentPrefix },
+		func(c *Config, v string) error { c.DocumentPrefix = v; return nil }},
+	{"symmetric", "SIFT_SYMMETRIC",
+		func(c *Config) string { return strconv.FormatBool(c.Symmetric) },
+		func(c *Config, v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("want true or false, got %q", v)
+			}
+			c.Symmetric = b
+			return nil
+		}},
+	{"editor-command", "SIFT_EDITOR_COMMAND",
+		func(c *Config) string { return c.EditorCommand },
+		func(c *Config, v string) error {
+			if _, err := ParseEditorCommand(v); err != nil {
+				return err
+			}
+			c.EditorCommand = v
+			return nil
+		}},
+	{"pin-export", "SIFT_PIN_EXPORT",
+		func(c *Config) string { return c.PinExport },
+		func(c *Config, v string) error {
+			if v == "" {
+				return fmt.Errorf("want %q, %q, %q or a file path", PinExportStdout, PinExportClipboard, PinExportNone)
+			}
+			c.PinExport = v
+			return nil
+		}},
+	{"pin-format", "SIFT_PIN_FORMAT",
+		func(c *Config) string { return c.PinFormat },
+		func(c *Config, v string) error {
+			if v != PinFormatLines && v != PinFormatMarkdown {
+				return fmt.Errorf("want %q or %q, got %q", PinFormatLines, PinFormatMarkdown, v)
+			}
+			c.PinFormat = v
+			return nil
+		}},
+	{"index-dir", "SIFT_INDEX_DIR",
+		func(c *Config) string { return c.IndexDir },
+		func(c *Config, v string) error { c.IndexDir = v; return nil }},
+	{"index-location", "SIFT_INDEX_LOCATION",
+		func(c *Config) string { return c.IndexLocation },
+		func(c *Config, v string) error {
+			if v != LocationProject && v != LocationXDG {
+				return fmt.Errorf("want %q or %q, got %q", LocationProject, LocationXDG, v)
+			}
+			c.IndexLocation = v
+			return nil
+		}},
+}
+
+func setInt(dst *int, v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return fmt.Errorf("want a non-negative integer, got %q", v)
+	}
+	*dst = n
+	return nil
+}
+
+// ParsePreview parses a preview setting into a character count, with 0
+// meaning PreviewFull.
+func ParsePreview(v string) (int, error) {
+	if v == PreviewFull {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("want %q or a positive number of characters, got %q", PreviewFull, v)
+	}
+	return n, nil
+}
+
+// ParseOversized parses an oversized setting: a comma-separated list of
+// entries, each OversizedSkip or OversizedSample alone, for every
+// extension, or "ext=policy" for one ("md=sample"). Later entries win. It
+// returns whether files are sampled by default and the per-extension
+// choices, keyed by lower-cased extension with its dot.
+func ParseOversized(v string) (sample bool, exts map[string]bool, err error) {
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		ext, policy, perExt := strings.Cut(entry, "=")
+		if !perExt {
+			policy = ext
+		}
+		policy = strings.TrimSpace(policy)
+		if policy != OversizedSkip && policy != OversizedSample {
+			return false, nil, fmt.Errorf("want %q or %q, optionally per extension as \"md=%s\", got %q",
+				OversizedSkip, OversizedSample, OversizedSample, entry)
+		}
+		if !perExt {
+			sample = policy == OversizedSample
+			continue
+		}
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext == "" || strings.ContainsAny(ext, "./\\") {
+			return false, nil, fmt.Errorf("bad extension in %q", entry)
+		}
+		if exts == nil {
+			exts = make(map[string]bool)
+		}
+		exts["."+ext] = policy == OversizedSample
+	}
+	return sample, exts, nil
+}
+
+// ParseStopwords parses a stopwords setting: StopwordsNone, or a
+// comma-separated list of words, where StopwordsDefault stands for the
+// built-in list ("default,foo" extends it). It returns the listed words,
+// lower-cased, and whether the built-in list is included.
+func ParseStopwords(v string) (words []string, builtin bool, err error) {
+	if v == StopwordsNone {
+		return nil, false, nil
+	}
+	for _, w := range strings.Split(v, ",") {
+		w = strings.ToLower(strings.TrimSpace(w))
+		switch {
+		case w == StopwordsDefault:
+			builtin = true
+		case w == "" || strings.ContainsFunc(w, unicode.IsSpace):
+			return nil, false, fmt.Errorf("want %q, %q or a comma-separated list of words, got %q", StopwordsDefault, StopwordsNone, v)
+		default:
+			words = append(words, w)
+		}
+	}
+	return words, builtin, nil
+}
+
+// ParseSynonyms parses a synonyms setting: SynonymsNone, or a
+// comma-separated list of groups of interchangeable words joined by "|"
+// ("login|signin|authenticate"), where SynonymsDefault stands for the
+// built-in groups ("default,ship|deploy" extends them). It returns the
+// listed groups, lower-cased, and whether the built-in ones are included.
+func ParseSynonyms(v string) (groups [][]string, builtin bool, err error) {
+	if v == SynonymsNone {
+		return nil, false, nil
+	}
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == SynonymsDefault {
+			builtin = true
+			continue
+		}
+		group := strings.Split(entry, "|")
+		for i, w := range group {
+			group[i] = strings.TrimSpace(w)
+			if group[i] == "" || strings.ContainsFunc(group[i], func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+			}) {
+				group = nil
+				break
+			}
+		}
+		if len(group) < 2 {
+			return nil, false, fmt.Errorf("want %q, %q or a comma-separated list of words joined by | such as \"login|signin\", got %q", SynonymsDefault, SynonymsNone, entry)
+		}
+		groups = append(groups, group)
+	}
+	return groups, builtin, nil
+}
+
+// ParsePruneAfter parses a prune-after setting, with 0 meaning never.
+func ParsePruneAfter(v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("want a duration such as \"168h\", or 0 to disable, got %q", v)
+	}
+	return d, nil
+}
+
+// ParseMaintenanceDuration parses a maintenance-interval or
+// maintenance-idle setting.
+func ParseMaintenanceDuration(v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("want a duration such as \"24h\", or 0, got %q", v)
+	}
+	return d, nil
+}
+
+// ParseResultCacheTTL parses a result-cache-ttl setting, with 0 meaning
+// cached results are reused until the index changes.
+func ParseResultCacheTTL(v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("want a duration such as \"5m\", or 0 for no limit, got %q", v)
+	}
+	return d, nil
+}
+
+// ParsePreload parses a preload setting: on is set for "true", and auto
+// for PreloadAuto.
+func ParsePreload(v string) (on, auto bool, err error) {
+	if v == PreloadAuto {
+		return false, true, nil
+	}
+	on, err = strconv.ParseBool(v)
+	if err != nil {
+		return false, false, fmt.Errorf("want true, false or %q, got %q", PreloadAuto, v)
+	}
+	return on, false, nil
+}
+
+// ParseRedactPattern compiles a redact-pattern setting; "" is nil, the
+// built-in patterns alone.
+func ParseRedactPattern(v string) (*regexp.Regexp, error) {
+	if v == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(v)
+	if err != nil {
+		return nil, fmt.Errorf("want a regular expression: %w", err)
+	}
+	return re, nil
+}
+
+// CategoryRule is one entry of a categories setting: files matching Glob
+// are in Category.
+type CategoryRule struct {
+	Category, Glob string
+}
+
+// categoryName is what a category may be called: it is typed in queries
+// as "type:<name>".
+var categoryName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// ParseCategories parses a categories setting: a comma-separated list of
+// "category=glob" entries ("tests=spec/**,docs=handbook/**"), in the
+// order they are tried. "" is none.
+func ParseCategories(v string) ([]CategoryRule, error) {
+	if strings.TrimSpace(v) == "" {
+		return nil, nil
+	}
+	var rules []CategoryRule
+	for _, entry := range strings.Split(v, ",") {
+		cat, glob, ok := strings.Cut(entry, "=")
+		cat, glob = strings.TrimSpace(cat), strings.TrimSpace(glob)
+		if !ok || glob == "" || !categoryName.MatchString(cat) {
+			return nil, fmt.Errorf("want a comma-separated list of category=glob, with lower-case category names such as \"tests=spec/**\", got %q", entry)
+		}
+		rules = append(rules, CategoryRule{Category: cat, Glob: glob})
+	}
+	return rules, nil
+}
+
+// ParseEditorCommand splits an editor-command setting into the program
+// and its arguments, as a shell would split words, without running one:
+// words are separated by spaces, and quoting part of a word in ' or "
+// keeps the spaces in it. Backslashes are not special, for Windows paths.
+// The placeholders {path}, {line} and {col} are left in the words, to be
+// filled in per result; {path} must be among them. "" returns nil.
+func ParseEditorCommand(v string) ([]string, error) {
+	var (
+		argv   []string
+		word   strings.Builder
+		inWord bool
+		quote  rune
+	)
+	for _, r := range v {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t':
+			if inWord {
+				argv = append(argv, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unclosed %c in %q", quote, v)
+	}
+	if inWord {
+		argv = append(argv, word.String())
+	}
+	if len(argv) == 0 {
+		return nil, nil
+	}
+	if !strings.Contains(strings.Join(argv[1:], " "), "{path}") {
+		return nil, fmt.Errorf("want a command line with {path} among its arguments, got %q", v)
+	}
+	return argv, nil
+}
+
+// Value returns the effective value of the named setting as a string.
+func (c *Config) Value(key string) string {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s.get(c)
+		}
+	}
+	return ""
+}
+
+// Defaults returns a Config holding only built-in defaults.
+func Defaults() *Config {
+	c := &Config{
+		ModelDir:            DefaultModelDir,
+		OrtLib:              DefaultOrtLib,
+		Threads:             DefaultThreads,
+		EmbedSessions:       DefaultEmbedSessions,
+		MaxFileKB:           DefaultMaxFile,
+		Oversized:           OversizedSkip,
+		Preview:             PreviewFull,
+		CompressMeta:        CompressAuto,
+		PruneAfter:          DefaultPruneAfter,
+		Preload:             PreloadAuto,
+		ResultCache:         DefaultResultCache,
+		ResultCacheTTL:      DefaultResultCacheTTL,
+		Stopwords:           StopwordsDefault,
+		MinBoostWords:       DefaultMinBoostWords,
+		Synonyms:            SynonymsDefault,
+		MaxEmbedFailures:    DefaultMaxEmbedFailures,
+		MaxEmbedFailurePct:  DefaultMaxEmbedFailurePct,
+		MaintenanceInterval: DefaultMaintenanceInterval,
+		MaintenanceStalePct: DefaultMaintenanceStalePct,
+		MaintenanceIdle:     DefaultMaintenanceIdle,
+		PinExport:           PinExportStdout,
+		PinFormat:           PinFormatLines,
+		QueryPrefix:         DefaultQueryPrefix,
+		IndexDir:            DefaultSiftDir,
+		IndexLocation:       LocationProject,
+		Sources:             make(map[string]Source, len(Settings)),
+	}
+	for _, s := range Settings {
+		c.Sources[s.Key] = SourceDefault
+	}
+	return c
+}
+
+// Load parses .sift.toml if it exists and returns a Config with merged defaults
+// and environment overrides applied.
 func Load() (*Config, error) {
-	cfg := &Config{
-		ModelDir:  DefaultModelDir,
-		OrtLib:    DefaultOrtLib,
-		Threads:   DefaultThreads,
-		MaxFileKB: DefaultMaxFile,
+	return Resolve(os.Getenv, nil)
+}
+
+// Resolve builds the effective configuration from every layer. getenv looks
+// up environment variables (os.Getenv in production); flags holds the values
+// of flags the user set explicitly, keyed by setting name, plus an optional
+// "config" entry naming the config file, a "global" entry selecting the
+// personal index, and a "profile" entry naming a profile.
+//
+// Layers, lowest first: defaults, the user config file
+// ($XDG_CONFIG_HOME/sift/config.toml), the project config file, SIFT_*
+// environment variables, flags. The project file is taken from the "config"
+// flag, then $SIFT_CONFIG, then .sift.toml in the working directory; only the
+// last may be missing.
+//
+// A profile ([profile.<name>] in either file, chosen by the "profile" flag
+// or $SIFT_PROFILE) overrides that file's top-level settings; keys it does
+// not set fall back to them. Unless some layer sets index-dir, each
+// profile gets its own index: .sift-<name>, or <project data dir>-<name>
+// with index-location = "xdg".
+func Resolve(getenv func(string) string, flags map[string]string) (*Config, error) {
+	c := Defaults()
+	c.Profile = getenv(ProfileEnv)
+	if p := flags["profile"]; p != "" {
+		c.Profile = p
+	}
+	if c.Profile != "" && !validProfile(c.Profile) {
+		return nil, fmt.Errorf("profile %q: want letters, digits, '-' or '_'", c.Profile)
+	}
+	profileFound := false
+
+	if p := UserConfigFile(getenv); p != "" {
+		found, err := c.loadFile(p, false, SourceUserFile)
+		if err != nil {
+			return nil, err
+		}
+		profileFound = profileFound || found
 	}
 
-	b, err := os.ReadFile(".sift.toml")
+	wd, err := os.Getwd()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return cfg, nil
+		return nil, fmt.Errorf("getwd: %w", err)
+	}
+	c.Root = wd
+	if flags["here"] != "true" {
+		indexName := DefaultSiftDir
+		if c.Profile != "" {
+			indexName += "-" + c.Profile
 		}
-		return nil, fmt.Errorf("read .sift.toml: %w", err)
+		c.Root, _ = FindProjectRoot(wd, indexName)
 	}
 
-	var fileCfg Config
-	if err := toml.Unmarshal(b, &fileCfg); err != nil {
-		return nil, fmt.Errorf("parse .sift.toml: %w", err)
+	path, explicit := filepath.Join(c.Root, DefaultFile), false
+	if p := getenv(ConfigEnv); p != "" {
+		path, explicit = p, true
+	}
+	if p := flags["config"]; p != "" {
+		path, explicit = p, true
+	}
+	found, err := c.loadFile(path, explicit, SourceFile)
+	if err != nil {
+		return nil, err
+	}
+	if c.Profile != "" && !profileFound && !found {
+		return nil, fmt.Errorf("profile %q: no [%s.%s] table in the config files", c.Profile, ProfileTable, c.Profile)
 	}
 
-	if fileCfg.ModelDir != "" {
-		cfg.ModelDir = fileCfg.ModelDir
+	for _, s := range Settings {
+		if v := getenv(s.Env); v != "" {
+			if err := s.set(c, v); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.Env, err)
+			}
+			c.Sources[s.Key] = SourceEnv
+		}
 	}
-	if fileCfg.OrtLib != "" {
-		cfg.OrtLib = fileCfg.OrtLib
+	for _, s := range Settings {
+		if v, ok := flags[s.Key]; ok {
+			if err := s.set(c, v); err != nil {
+				return nil, fmt.Errorf("--%s: %w", s.Key, err)
+			}
+			c.Sources[s.Key] = SourceFlag
+		}
 	}
-	if fileCfg.Threads > 0 {
-		cfg.Threads = fileCfg.Threads
+
+	// An explicit index-dir always wins; otherwise "xdg" moves the index
+	// out of the project into the user data directory.
+	if c.IndexLocation == LocationXDG && c.Sources["index-dir"] == SourceDefault {
+		home := dataHome(runtime.GOOS, getenv)
+		if home == "" {
+			return nil, errors.New("index-location = \"xdg\" but no data directory could be determined (set XDG_DATA_HOME)")
+		}
+		c.IndexDir = ProjectDataDir(home, c.Root)
+		if c.Profile != "" {
+			c.IndexDir += "-" + c.Profile
+		}
+		c.Sources["index-dir"] = c.Sources["index-location"]
+	}
+	if c.Profile != "" && c.Sources["index-dir"] == SourceDefault {
+		c.IndexDir = DefaultSiftDir + "-" + c.Profile
+	}
+	switch c.Sources["index-dir"] {
+	case SourceDefault:
+		c.rootRelative = true
+	case SourceFile:
+		c.rootRelative = !explicit
+	}
+
+	if flags["global"] == "true" {
+		dir := GlobalIndexDir(getenv)
+		if dir == "" {
+			return nil, errors.New("--global: no data directory could be determined (set XDG_DATA_HOME)")
+		}
+		c.Global = true
+		c.IndexDir = dir
+		c.Sources["index-dir"] = SourceFlag
+	}
+	return c, nil
+}
+
+// IndexPath returns the absolute path of the index directory. A relative
+// index-dir is taken relative to Root when it is the default or comes from
+// the project's own .sift.toml, so every subdirectory of a project finds
+// the same index, and relative to the working directory otherwise.
+func (c *Config) IndexPath() (string, error) {
+	if c.rootRelative && !filepath.IsAbs(c.IndexDir) {
+		return filepath.Join(c.Root, c.IndexDir), nil
+	}
+	return filepath.Abs(c.IndexDir)
+}
+
+// loadFile applies the settings present in the TOML file at path, then
+// those of c.Profile's table in it, reporting whether the file has one.
+func (c *Config) loadFile(path string, mustExist bool, src Source) (profileFound bool, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !mustExist {
+			return false, nil
+		}
+		return false, fmt.Errorf("read %s: %w", path, err)
 	}
-	if fileCfg.MaxFileKB > 0 {
-		cfg.MaxFileKB = fileCfg.MaxFileKB
+
+	var raw map[string]any
+	if err := toml.Unmarshal(b, &raw); err != nil {
+		return false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := c.apply(raw, src); err != nil {
+		return false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if c.Profile != "" {
+		if table, ok := profiles(raw)[c.Profile].(map[string]any); ok {
+			if err := c.apply(table, src); err != nil {
+				return false, fmt.Errorf("parse %s: %s.%s: %w", path, ProfileTable, c.Profile, err)
+			}
+			profileFound = true
+		}
+	}
+	if src == SourceUserFile {
+		c.UserFile = path
+	} else {
+		c.File = path
+	}
+	return profileFound, nil
+}
+
+// apply sets the settings present in one TOML table.
+func (c *Config) apply(table map[string]any, src Source) error {
+	for _, s := range Settings {
+		v, ok := table[s.Key]
+		if !ok {
+			continue
+		}
+		str := fmt.Sprint(v)
+		if str == "" {
+			continue
+		}
+		if err := s.set(c, str); err != nil {
+			return fmt.Errorf("%s: %w", s.Key, err)
+		}
+		c.Sources[s.Key] = src
+	}
+	return nil
+}
+
+// validProfile reports whether name can be used as a profile name, which
+// also ends up in the profile's index directory name.
+func validProfile(name string) bool {
+	for _, r := range name {
+		if !(r == '-' || r == '_' || '0' <= r && r <= '9' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z') {
+			return false
+		}
 	}
+	return name != ""
+}
 
-	return cfg, nil
+// profiles returns the [profile.*] tables of a parsed config file by name.
+func profiles(raw map[string]any) map[string]any {
+	p, _ := raw[ProfileTable].(map[string]any)
+	return p
 }
 
-// ResolveOrtLib resolves the absolute path of onnxruntime.so.
+// ResolveOrtLib resolves the absolute path of the onnxruntime library.
 func ResolveOrtLib(flagPath string) string {
 	if flagPath != "" {
 		return flagPath
 	}
 	if exe, err := os.Executable(); err == nil {
-		candidate := filepath.Join(filepath.Dir(exe), "lib", "onnxruntime.so")
-		if _, err := os.Stat(candidate); err == nil {
-			return candidate
+		for _, candidate := range ortLibCandidates(runtime.GOOS, filepath.Dir(exe)) {
+			if _, err := os.Stat(candidate); err == nil {
+				return candidate
+			}
 		}
 	}
 	if _, err := os.Stat(DefaultOrtLib); err == nil {
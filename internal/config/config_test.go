@@ -1,8 +1,12 @@
 package config
 
 import (
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -122,3 +126,713 @@ func TestResolveOrtLib(t *testing.T) {
 		}
 	}
 }
+
+func TestOrtLibCandidates(t *testing.T) {
+	exeDir := filepath.Join("opt", "sift")
+	cases := []struct {
+		goos string
+		want []string
+	}{
+		{"linux", []string{filepath.Join(exeDir, "lib", "onnxruntime.so")}},
+		{"darwin", []string{filepath.Join(exeDir, "lib", "onnxruntime.so")}},
+		{"windows", []string{
+			filepath.Join(exeDir, "onnxruntime.dll"),
+			filepath.Join(exeDir, "lib", "onnxruntime.dll"),
+		}},
+	}
+	for _, tc := range cases {
+		if got := ortLibCandidates(tc.goos, exeDir); !slices.Equal(got, tc.want) {
+			t.Errorf("ortLibCandidates(%q) = %q, want %q", tc.goos, got, tc.want)
+		}
+	}
+}
+
+// TestResolve_Precedence checks every combination of file, env, and flag for
+// each setting: the highest layer present must win (flag > env > file > default).
+func TestResolve_Precedence(t *testing.T) {
+	// Distinct per-layer values for every setting. A setting with only two
+	// legal values (a bool, or index-location and pin-format) gives the file
+	// and the flag the same one; the source check tells those layers apart.
+	values := map[string][3]string{ // file, env, flag
+		"model-dir":             {"file-models", "env-models", "flag-models"},
+		"ort-lib":               {"file.so", "env.so", "flag.so"},
+		"threads":               {"1", "2", "3"},
+		"embed-sessions":        {"1", "2", "3"},
+		"background-sessions":   {"0", "1", "2"},
+		"low-priority":          {"true", "false", "true"},
+		"max-file-kb":           {"100", "200", "300"},
+		"oversized":             {"sample", "skip,md=sample", "skip"},
+		"preview":               {"400", "800", "full"},
+		"redact-pattern":        {"ACME-[0-9]{8}", "tok_[a-z]+", "(?i)internal-[0-9a-f]{32}"},
+		"categories":            {"tests=spec/**", "docs=handbook/**", "bench=*_bench.go,tests=e2e/**"},
+		"compress-meta":         {"always", "never", "auto"},
+		"prune-after":           {"24h", "0", "720h"},
+		"low-memory":            {"true", "false", "true"},
+		"preload":               {"true", "false", "auto"},
+		"calibrate":             {"true", "false", "true"},
+		"max-per-dir":           {"2", "3", "4"},
+		"result-cache":          {"0", "16", "128"},
+		"result-cache-ttl":      {"1m", "0", "1h"},
+		"stopwords":             {"none", "default,foo", "foo,bar"},
+		"min-boost-words":       {"0", "2", "3"},
+		"expand-queries":        {"1", "2", "4"},
+		"synonyms":              {"none", "default,ship|deploy", "login|signin"},
+		"max-embed-failures":    {"5", "0", "20"},
+		"max-embed-failure-pct": {"50", "0", "100"},
+		"maintenance-interval":  {"1h", "0", "168h"},
+		"maintenance-stale-pct": {"5", "0", "50"},
+		"maintenance-idle":      {"0", "30m", "1h"},
+		"index-dir":             {"file-idx", "env-idx", "flag-idx"},
+		"index-location":        {"xdg", "project", "xdg"},
+		"editor-command":        {"hx {path}:{line}", "kak +{line} {path}", "code -g {path}:{line}"},
+		"pin-export":            {"clipboard", "none", "pins.md"},
+		"pin-format":            {"markdown", "lines", "markdown"},
+		"query-prefix":          {"file: ", "env: ", "flag: "},
+		"document-prefix":       {"file: ", "env: ", "flag: "},
+		"symmetric":             {"true", "false", "true"},
+	}
+
+	for _, s := range Settings {
+		for mask := 0; mask < 8; mask++ {
+			useFile, useEnv, useFlag := mask&1 != 0, mask&2 != 0, mask&4 != 0
+			name := fmt.Sprintf("%s/file=%t,env=%t,flag=%t", s.Key, useFile, useEnv, useFlag)
+			t.Run(name, func(t *testing.T) {
+				v := values[s.Key]
+				cfgPath := filepath.Join(t.TempDir(), "sift.toml")
+				content := ""
+				if useFile {
+					if s.Key == "threads" || s.Key == "embed-sessions" || s.Key == "background-sessions" || s.Key == "low-priority" || s.Key == "max-file-kb" || s.Key == "preview" || s.Key == "low-memory" || s.Key == "calibrate" || s.Key == "max-per-dir" || s.Key == "result-cache" ||
+						s.Key == "max-embed-failures" || s.Key == "max-embed-failure-pct" || s.Key == "maintenance-stale-pct" || s.Key == "symmetric" || s.Key == "min-boost-words" || s.Key == "expand-queries" {
+						content = fmt.Sprintf("%s = %s\n", s.Key, v[0])
+					} else {
+						content = fmt.Sprintf("%s = %q\n", s.Key, v[0])
+					}
+				}
+				if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				env := map[string]string{ConfigEnv: cfgPath, "XDG_DATA_HOME": t.TempDir()}
+				if useEnv {
+					env[s.Env] = v[1]
+				}
+				flags := map[string]string{}
+				if useFlag {
+					flags[s.Key] = v[2]
+				}
+
+				c, err := Resolve(func(k string) string { return env[k] }, flags)
+				if err != nil {
+					t.Fatalf("Resolve: %v", err)
+				}
+
+				want, wantSrc := Defaults().Value(s.Key), SourceDefault
+				switch {
+				case useFlag:
+					want, wantSrc = v[2], SourceFlag
+				case useEnv:
+					want, wantSrc = v[1], SourceEnv
+				case useFile:
+					want, wantSrc = v[0], SourceFile
+				}
+				if got := c.Value(s.Key); got != want {
+					t.Errorf("value = %q, want %q", got, want)
+				}
+				if got := c.Sources[s.Key]; got != wantSrc {
+					t.Errorf("source = %v, want %v", got, wantSrc)
+				}
+			})
+		}
+	}
+}
+
+func TestResolve_ConfigPath(t *testing.T) {
+	dir := t.TempDir()
+	envFile := filepath.Join(dir, "env.toml")
+	flagFile := filepath.Join(dir, "flag.toml")
+	if err := os.WriteFile(envFile, []byte(`model-dir = "from-env-file"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(flagFile, []byte(`model-dir = "from-flag-file"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	getenv := func(k string) string {
+		if k == ConfigEnv {
+			return envFile
+		}
+		return ""
+	}
+
+	c, err := Resolve(getenv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ModelDir != "from-env-file" || c.File != envFile {
+		t.Errorf("SIFT_CONFIG ignored: model-dir=%q file=%q", c.ModelDir, c.File)
+	}
+
+	c, err = Resolve(getenv, map[string]string{"config": flagFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ModelDir != "from-flag-file" {
+		t.Errorf("--config should beat SIFT_CONFIG, got %q", c.ModelDir)
+	}
+
+	// An explicitly named config file must exist.
+	if _, err := Resolve(getenv, map[string]string{"config": filepath.Join(dir, "missing.toml")}); err == nil {
+		t.Error("expected error for missing explicit config file")
+	}
+}
+
+func TestResolve_InvalidEnv(t *testing.T) {
+	getenv := func(k string) string {
+		if k == "SIFT_THREADS" {
+			return "many"
+		}
+		return ""
+	}
+	if _, err := Resolve(getenv, nil); err == nil {
+		t.Error("expected error for non-numeric SIFT_THREADS")
+	}
+	for _, v := range []string{"0", "-5", "short"} {
+		if _, err := Resolve(func(string) string { return "" }, map[string]string{"preview": v}); err == nil {
+			t.Errorf("expected error for --preview %s", v)
+		}
+	}
+}
+
+func TestResolve_UserAndProjectFiles(t *testing.T) {
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	project := t.TempDir()
+	if err := os.Chdir(project); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.Chdir(origDir)
+	}()
+
+	xdgConfig := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(xdgConfig, "sift"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	user := `
+model-dir = "/user/models"
+threads = 2
+`
+	if err := os.WriteFile(filepath.Join(xdgConfig, "sift", "config.toml"), []byte(user), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(DefaultFile, []byte(`threads = 6`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	getenv := func(k string) string {
+		if k == "XDG_CONFIG_HOME" {
+			return xdgConfig
+		}
+		return ""
+	}
+
+	c, err := Resolve(getenv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ModelDir != "/user/models" || c.Sources["model-dir"] != SourceUserFile {
+		t.Errorf("model-dir = %q (%v), want user file value", c.ModelDir, c.Sources["model-dir"])
+	}
+	if c.Threads != 6 || c.Sources["threads"] != SourceFile {
+		t.Errorf("threads = %d (%v), want project file to override user file", c.Threads, c.Sources["threads"])
+	}
+	if c.MaxFileKB != DefaultMaxFile || c.Sources["max-file-kb"] != SourceDefault {
+		t.Errorf("max-file-kb = %d (%v), want default", c.MaxFileKB, c.Sources["max-file-kb"])
+	}
+}
+
+func TestResolve_XDGIndexLocation(t *testing.T) {
+	dataHome := t.TempDir()
+	getenv := func(k string) string {
+		switch k {
+		case "XDG_DATA_HOME":
+			return dataHome
+		case "SIFT_INDEX_LOCATION":
+			return LocationXDG
+		}
+		return ""
+	}
+	// The location is keyed by the project root, not the subdirectory
+	// sift runs in.
+	project := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(project, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(project, "docs", "guides")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(sub)
+
+	c, err := Resolve(getenv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ProjectDataDir(dataHome, project); c.IndexDir != want {
+		t.Errorf("IndexDir = %q, want %q", c.IndexDir, want)
+	}
+	if !strings.HasPrefix(c.IndexDir, filepath.Join(dataHome, "sift")+string(filepath.Separator)) {
+		t.Errorf("IndexDir %q not under data home", c.IndexDir)
+	}
+
+	// An explicit index-dir beats the derived location.
+	c, err = Resolve(getenv, map[string]string{"index-dir": "custom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.IndexDir != "custom" {
+		t.Errorf("IndexDir = %q, want explicit value", c.IndexDir)
+	}
+}
+
+func TestResolve_Global(t *testing.T) {
+	dataHome := t.TempDir()
+	getenv := func(k string) string {
+		switch k {
+		case "XDG_DATA_HOME":
+			return dataHome
+		case "SIFT_INDEX_DIR":
+			return "env-idx"
+		case "SIFT_INDEX_LOCATION":
+			return LocationXDG
+		}
+		return ""
+	}
+
+	// --global beats index-dir and index-location from every other layer,
+	// and does not depend on the working directory.
+	c, err := Resolve(getenv, map[string]string{"global": "true"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(dataHome, "sift", "global")
+	if !c.Global || c.IndexDir != want || c.Sources["index-dir"] != SourceFlag {
+		t.Errorf("global = %v, IndexDir = %q (%v), want %q from flag", c.Global, c.IndexDir, c.Sources["index-dir"], want)
+	}
+	if GlobalIndexDir(getenv) != want {
+		t.Errorf("GlobalIndexDir = %q, want %q", GlobalIndexDir(getenv), want)
+	}
+
+	c, err = Resolve(getenv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Global || c.IndexDir != "env-idx" {
+		t.Errorf("without --global: global = %v, IndexDir = %q", c.Global, c.IndexDir)
+	}
+
+	if _, err := Resolve(func(string) string { return "" }, map[string]string{"global": "true"}); err == nil {
+		t.Error("expected an error when no data directory is known")
+	}
+}
+
+func TestProjectDataDir(t *testing.T) {
+	a := ProjectDataDir("/data", "/src/a")
+	if a != ProjectDataDir("/data", "/src/a/") {
+		t.Error("trailing slash should not change the derived path")
+	}
+	if a == ProjectDataDir("/data", "/src/b") {
+		t.Error("different projects must not share an index directory")
+	}
+	if filepath.Dir(a) != filepath.Join("/data", "sift") || len(filepath.Base(a)) != 16 {
+		t.Errorf("unexpected layout %q", a)
+	}
+}
+
+func TestPlatformDirs(t *testing.T) {
+	env := func(m map[string]string) func(string) string {
+		return func(k string) string { return m[k] }
+	}
+	cases := []struct {
+		goos       string
+		env        map[string]string
+		wantConfig string
+		wantData   string
+	}{
+		{"linux", map[string]string{"HOME": "/home/u"}, "/home/u/.config", "/home/u/.local/share"},
+		{"linux", map[string]string{"HOME": "/home/u", "XDG_CONFIG_HOME": "/c", "XDG_DATA_HOME": "/d"}, "/c", "/d"},
+		{"darwin", map[string]string{"HOME": "/Users/u"}, "/Users/u/Library/Application Support", "/Users/u/Library/Application Support"},
+		{"windows", map[string]string{"AppData": `C:\r`, "LocalAppData": `C:\l`}, `C:\r`, `C:\l`},
+		{"linux", map[string]string{}, "", ""},
+	}
+	for _, tc := range cases {
+		if got := configHome(tc.goos, env(tc.env)); got != filepath.FromSlash(tc.wantConfig) && got != tc.wantConfig {
+			t.Errorf("%s configHome(%v) = %q, want %q", tc.goos, tc.env, got, tc.wantConfig)
+		}
+		if got := dataHome(tc.goos, env(tc.env)); got != filepath.FromSlash(tc.wantData) && got != tc.wantData {
+			t.Errorf("%s dataHome(%v) = %q, want %q", tc.goos, tc.env, got, tc.wantData)
+		}
+	}
+}
+
+func TestConfig_Reload(t *testing.T) {
+	cur := Defaults()
+	next := Defaults()
+	next.MaxFileKB = 64
+	next.Sources["max-file-kb"] = SourceFile
+	next.ModelDir = "/elsewhere"
+	next.Sources["model-dir"] = SourceFile
+
+	if d := cur.Diff(Defaults()); len(d) != 0 {
+		t.Errorf("Diff of equal configs = %+v, want none", d)
+	}
+	applied, restart := cur.Reload(next)
+	if len(applied) != 1 || applied[0] != (Change{Key: "max-file-kb", Old: "512", New: "64", Live: true}) {
+		t.Errorf("applied = %+v, want max-file-kb 512 → 64", applied)
+	}
+	if len(restart) != 1 || restart[0].Key != "model-dir" || restart[0].Live {
+		t.Errorf("restart = %+v, want model-dir", restart)
+	}
+	if cur.MaxFileKB != 64 || cur.Sources["max-file-kb"] != SourceFile {
+		t.Errorf("max-file-kb = %d (%v), want 64 from file", cur.MaxFileKB, cur.Sources["max-file-kb"])
+	}
+	if cur.ModelDir != DefaultModelDir {
+		t.Errorf("model-dir = %q; a restart-only setting must keep its running value", cur.ModelDir)
+	}
+}
+
+func TestSetFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sift", "config.toml")
+	original := `# project settings
+model-dir = "./models"   # shared with the team
+threads = 2
+
+[extra]
+note = "kept"
+`
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kv := range [][2]string{
+		{"threads", "4"},
+		{"model-dir", "/opt/models"},
+		{"preview", "full"},
+		{"max-file-kb", "64"},
+	} {
+		if err := SetFile(path, kv[0], kv[1]); err != nil {
+			t.Fatalf("SetFile(%s, %s): %v", kv[0], kv[1], err)
+		}
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# project settings
+model-dir = "/opt/models"   # shared with the team
+threads = 4
+preview = "full"
+max-file-kb = 64
+
+[extra]
+note = "kept"
+`
+	if string(got) != want {
+		t.Errorf("file after SetFile:\n%s\nwant:\n%s", got, want)
+	}
+
+	c, err := Resolve(func(k string) string {
+		if k == ConfigEnv {
+			return path
+		}
+		return ""
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, v := range map[string]string{"threads": "4", "model-dir": "/opt/models", "preview": "full", "max-file-kb": "64"} {
+		if c.Value(key) != v || c.Sources[key] != SourceFile {
+			t.Errorf("%s = %q (%v), want %q from file", key, c.Value(key), c.Sources[key], v)
+		}
+	}
+
+	// A new file is created along with its directory.
+	fresh := filepath.Join(t.TempDir(), "new", ".sift.toml")
+	if err := SetFile(fresh, "index-location", LocationXDG); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(fresh); string(b) != "index-location = \"xdg\"\n" {
+		t.Errorf("new file = %q", b)
+	}
+}
+
+func TestSetFile_Validation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".sift.toml")
+	for _, kv := range [][2]string{
+		{"max-file-mb", "1"},       // unknown key
+		{"threads", "many"},        // not a number
+		{"preview", "0"},           // not positive
+		{"index-location", "home"}, // not an allowed value
+		{"oversized", "md=shrink"}, // not a policy
+		{"stopwords", "the,,and"},  // empty word
+		{"expand-queries", "5"},    // past the cap
+	} {
+		if err := SetFile(path, kv[0], kv[1]); err == nil {
+			t.Errorf("SetFile(%s, %s) succeeded, want an error", kv[0], kv[1])
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("rejected sets created %s", path)
+	}
+
+	if err := os.WriteFile(path, []byte("threads = 1\nmodeldir = \"x\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if unknown, err := UnknownKeys(path); err != nil || len(unknown) != 1 || unknown[0] != "modeldir" {
+		t.Errorf("UnknownKeys = %q, %v; want [modeldir]", unknown, err)
+	}
+}
+
+func TestParseOversized(t *testing.T) {
+	for _, tc := range []struct {
+		in     string
+		sample bool
+		exts   map[string]bool
+	}{
+		{"skip", false, nil},
+		{"sample", true, nil},
+		{"skip, md=sample,.RST=sample", false, map[string]bool{".md": true, ".rst": true}},
+		{"sample,go=skip,sample", true, map[string]bool{".go": false}},
+	} {
+		sample, exts, err := ParseOversized(tc.in)
+		if err != nil || sample != tc.sample || !maps.Equal(exts, tc.exts) {
+			t.Errorf("ParseOversized(%q) = %v, %v, %v; want %v, %v", tc.in, sample, exts, err, tc.sample, tc.exts)
+		}
+	}
+	for _, bad := range []string{"", "trim", "md=", "=sample", "a.b=sample", "skip,,sample"} {
+		if _, _, err := ParseOversized(bad); err == nil {
+			t.Errorf("ParseOversized(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestParseEditorCommand(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"hx {path}:{line}", []string{"hx", "{path}:{line}"}},
+		{"  emacsclient -n  +{line}:{col}\t{path} ", []string{"emacsclient", "-n", "+{line}:{col}", "{path}"}},
+		{`"C:\Program Files\Sublime Text\subl.exe" "{path}:{line}"`, []string{`C:\Program Files\Sublime Text\subl.exe`, "{path}:{line}"}},
+		{`my-editor --title='a "b"' {path}`, []string{"my-editor", `--title=a "b"`, "{path}"}},
+	} {
+		got, err := ParseEditorCommand(tc.in)
+		if err != nil || !slices.Equal(got, tc.want) {
+			t.Errorf("ParseEditorCommand(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+		}
+	}
+	for _, bad := range []string{"vim +{line}", "{path}", `vim "{path}`} {
+		if _, err := ParseEditorCommand(bad); err == nil {
+			t.Errorf("ParseEditorCommand(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestParseStopwords(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		words   []string
+		builtin bool
+	}{
+		{"default", nil, true},
+		{"none", nil, false},
+		{"Default, Foo,bar", []string{"foo", "bar"}, true},
+		{"foo", []string{"foo"}, false},
+	} {
+		words, builtin, err := ParseStopwords(tc.in)
+		if err != nil || builtin != tc.builtin || !slices.Equal(words, tc.words) {
+			t.Errorf("ParseStopwords(%q) = %q, %v, %v; want %q, %v", tc.in, words, builtin, err, tc.words, tc.builtin)
+		}
+	}
+	for _, bad := range []string{"", "foo,", "two words"} {
+		if _, _, err := ParseStopwords(bad); err == nil {
+			t.Errorf("ParseStopwords(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestParseSynonyms(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		groups  [][]string
+		builtin bool
+	}{
+		{"default", nil, true},
+		{"none", nil, false},
+		{"Default, Ship | Deploy", [][]string{{"ship", "deploy"}}, true},
+		{"login|signin|sign_in,rm|remove", [][]string{{"login", "signin", "sign_in"}, {"rm", "remove"}}, false},
+	} {
+		groups, builtin, err := ParseSynonyms(tc.in)
+		if err != nil || builtin != tc.builtin || !slices.EqualFunc(groups, tc.groups, slices.Equal) {
+			t.Errorf("ParseSynonyms(%q) = %q, %v, %v; want %q, %v", tc.in, groups, builtin, err, tc.groups, tc.builtin)
+		}
+	}
+	for _, bad := range []string{"", "login", "login|", "login|sign in", "login|sign-in", "default,"} {
+		if _, _, err := ParseSynonyms(bad); err == nil {
+			t.Errorf("ParseSynonyms(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestParseCategories(t *testing.T) {
+	got, err := ParseCategories(" tests = spec/** ,docs=handbook/*.adoc,e2e-tests=**/e2e/**")
+	want := []CategoryRule{{"tests", "spec/**"}, {"docs", "handbook/*.adoc"}, {"e2e-tests", "**/e2e/**"}}
+	if err != nil || !slices.Equal(got, want) {
+		t.Errorf("ParseCategories = %q, %v; want %q", got, err, want)
+	}
+	if got, err := ParseCategories(""); err != nil || got != nil {
+		t.Errorf("ParseCategories(\"\") = %q, %v; want none", got, err)
+	}
+	for _, bad := range []string{"tests", "tests=", "=spec/**", "Tests=spec/**", "my tests=spec/**", "tests=spec/**,"} {
+		if _, err := ParseCategories(bad); err == nil {
+			t.Errorf("ParseCategories(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestResolve_Profile(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	userFile := filepath.Join(dir, "xdg", "sift", "config.toml")
+	if err := os.MkdirAll(filepath.Dir(userFile), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(userFile, []byte("threads = 3\n\n[profile.code]\nthreads = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	project := `max-file-kb = 256
+preview = 300
+
+[profile.docs]
+max-file-kb = 2048
+preview = "full"
+
+[profile.code]
+index-dir = "idx/code"
+`
+	if err := os.WriteFile(DefaultFile, []byte(project), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{"XDG_CONFIG_HOME": filepath.Join(dir, "xdg")}
+	getenv := func(k string) string { return env[k] }
+
+	c, err := Resolve(getenv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Profile != "" || c.MaxFileKB != 256 || c.IndexDir != DefaultSiftDir {
+		t.Errorf("no profile: profile=%q max-file-kb=%d index-dir=%q", c.Profile, c.MaxFileKB, c.IndexDir)
+	}
+
+	// docs overrides two keys, inherits threads from the user file, and
+	// gets an index of its own.
+	c, err = Resolve(getenv, map[string]string{"profile": "docs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MaxFileKB != 2048 || c.Preview != PreviewFull || c.Threads != 3 {
+		t.Errorf("docs: max-file-kb=%d preview=%q threads=%d, want 2048 full 3", c.MaxFileKB, c.Preview, c.Threads)
+	}
+	if c.IndexDir != ".sift-docs" || c.Sources["index-dir"] != SourceDefault {
+		t.Errorf("docs: index-dir = %q (%v), want .sift-docs", c.IndexDir, c.Sources["index-dir"])
+	}
+
+	// code is split across both files; the project's explicit index-dir wins.
+	env[ProfileEnv] = "code"
+	c, err = Resolve(getenv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Threads != 1 || c.MaxFileKB != 256 || c.IndexDir != "idx/code" {
+		t.Errorf("code: threads=%d max-file-kb=%d index-dir=%q, want 1 256 idx/code", c.Threads, c.MaxFileKB, c.IndexDir)
+	}
+	// A flag still beats the profile.
+	c, err = Resolve(getenv, map[string]string{"max-file-kb": "8"})
+	if err != nil || c.MaxFileKB != 8 {
+		t.Errorf("flag over profile: max-file-kb=%d, %v", c.MaxFileKB, err)
+	}
+
+	for _, bad := range []string{"missing", "../escape"} {
+		if _, err := Resolve(getenv, map[string]string{"profile": bad}); err == nil {
+			t.Errorf("profile %q: want an error", bad)
+		}
+	}
+
+	if unknown, err := UnknownKeys(DefaultFile); err != nil || len(unknown) != 0 {
+		t.Errorf("UnknownKeys with profiles = %q, %v", unknown, err)
+	}
+}
+
+func TestResolve_ProjectRoot(t *testing.T) {
+	mkdir := func(parts ...string) string {
+		t.Helper()
+		p := filepath.Join(parts...)
+		if err := os.MkdirAll(p, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	project := t.TempDir()
+	mkdir(project, ".git")
+	deep := mkdir(project, "docs", "guides")
+	if err := os.WriteFile(filepath.Join(project, DefaultFile), []byte("max-file-kb = 77\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	noenv := func(string) string { return "" }
+	resolve := func(flags map[string]string) (*Config, string) {
+		t.Helper()
+		c, err := Resolve(noenv, flags)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := c.IndexPath()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c, p
+	}
+
+	// From a subdirectory: the root's config file and index.
+	t.Chdir(deep)
+	c, p := resolve(nil)
+	if c.Root != project || p != filepath.Join(project, DefaultSiftDir) || c.MaxFileKB != 77 {
+		t.Errorf("from %s: root %q, index %q, max-file-kb %d; want %q, %q, 77", deep, c.Root, p, c.MaxFileKB, project, filepath.Join(project, DefaultSiftDir))
+	}
+	// An explicit relative index-dir stays relative to the working directory.
+	if _, p = resolve(map[string]string{"index-dir": "idx"}); p != filepath.Join(deep, "idx") {
+		t.Errorf("--index-dir idx = %q, want it under %s", p, deep)
+	}
+	// --here makes the working directory the root.
+	if c, p = resolve(map[string]string{"here": "true"}); c.Root != deep || p != filepath.Join(deep, DefaultSiftDir) || c.MaxFileKB == 77 {
+		t.Errorf("--here: root %q, index %q, max-file-kb %d", c.Root, p, c.MaxFileKB)
+	}
+
+	// An existing index below the project root is nearer, so it wins.
+	mkdir(project, "docs", DefaultSiftDir)
+	if c, p = resolve(nil); p != filepath.Join(project, "docs", DefaultSiftDir) {
+		t.Errorf("with docs/.sift: root %q, index %q", c.Root, p)
+	}
+
+	// Outside any project the working directory is the root, as before.
+	alone := t.TempDir()
+	t.Chdir(alone)
+	if c, p = resolve(nil); c.Root != alone || p != filepath.Join(alone, DefaultSiftDir) {
+		t.Errorf("no project: root %q, index %q; want %s", c.Root, p, alone)
+	}
+	if _, ok := FindProjectRoot(alone, DefaultSiftDir); ok {
+		t.Errorf("FindProjectRoot(%s) found a project", alone)
+	}
+}
@@ -0,0 +1,197 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Lookup returns the setting named key.
+func Lookup(key string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Keys returns every setting name, in Settings order.
+func Keys() []string {
+	keys := make([]string, len(Settings))
+	for i, s := range Settings {
+		keys[i] = s.Key
+	}
+	return keys
+}
+
+// Validate reports whether value is acceptable for the setting key.
+func Validate(key, value string) error {
+	s, ok := Lookup(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %q (known: %s)", key, strings.Join(Keys(), ", "))
+	}
+	if err := s.set(Defaults(), value); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+// UnknownKeys returns the keys in the TOML file at path that are not
+// settings, sorted, with those inside a profile as "profile.<name>.<key>";
+// the loader ignores them, so they are usually typos. A missing file has
+// none.
+func UnknownKeys(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var raw map[string]any
+	if err := toml.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	var unknown []string
+	for k := range raw {
+		if _, ok := Lookup(k); !ok && k != ProfileTable {
+			unknown = append(unknown, k)
+		}
+	}
+	for name, p := range profiles(raw) {
+		table, ok := p.(map[string]any)
+		if !ok {
+			unknown = append(unknown, ProfileTable+"."+name)
+			continue
+		}
+		for k := range table {
+			if _, ok := Lookup(k); !ok {
+				unknown = append(unknown, ProfileTable+"."+name+"."+k)
+			}
+		}
+	}
+	slices.Sort(unknown)
+	return unknown, nil
+}
+
+// SetFile sets key to value in the TOML file at path, creating the file
+// (and its directory) if needed. The file is edited line by line so
+// comments and the order of other keys survive: an existing top-level
+// assignment has its value replaced, keeping any trailing comment;
+// otherwise the key is added after the last top-level line.
+func SetFile(path, key, value string) error {
+	if err := Validate(key, value); err != nil {
+		return err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	assignment := key + " = " + formatValue(value)
+	insertAt, replaced := len(lines), false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") {
+			// Settings are top-level; stop at the first table.
+			insertAt = i
+			for insertAt > 0 && strings.TrimSpace(lines[insertAt-1]) == "" {
+				insertAt--
+			}
+			break
+		}
+		k, rest, ok := strings.Cut(trimmed, "=")
+		if !ok || strings.Trim(strings.TrimSpace(k), `"'`) != key {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		lines[i] = indent + assignment + trailingComment(rest)
+		replaced = true
+		break
+	}
+	if !replaced {
+		lines = slices.Insert(lines, insertAt, assignment)
+	}
+
+	out := []byte(strings.Join(lines, "\n") + "\n")
+	var check map[string]any
+	if err := toml.Unmarshal(out, &check); err != nil {
+		return fmt.Errorf("edit %s: result is not valid TOML: %w", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, out, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// formatValue renders value as a TOML integer or boolean when it is one,
+// and as a basic string otherwise.
+func formatValue(value string) string {
+	if _, err := strconv.Atoi(value); err == nil || value == "true" || value == "false" {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// trailingComment returns the whitespace and "# comment" following the
+// value in rest (the text after "="), or "".
+func trailingComment(rest string) string {
+	v := strings.TrimLeft(rest, " \t")
+	end := len(v)
+	switch {
+	case strings.HasPrefix(v, `"`):
+		for i := 1; i < len(v); i++ {
+			if v[i] == '\\' {
+				i++
+			} else if v[i] == '"' {
+				end = i + 1
+				break
+			}
+		}
+	case strings.HasPrefix(v, "'"):
+		if i := strings.IndexByte(v[1:], '\''); i >= 0 {
+			end = i + 2
+		}
+	default:
+		if i := strings.IndexAny(v, " \t#"); i >= 0 {
+			end = i
+		}
+	}
+	tail := v[end:]
+	if i := strings.IndexByte(tail, '#'); i >= 0 {
+		// Keep the spacing before the comment as written.
+		ws := tail[:i]
+		if strings.TrimSpace(ws) != "" {
+			return ""
+		}
+		if ws == "" && end > 0 {
+			ws = " "
+		}
+		return ws + tail[i:]
+	}
+	return ""
+}
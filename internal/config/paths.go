@@ -0,0 +1,128 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Index locations accepted by the index-location setting.
+const (
+	// LocationProject keeps the index in index-dir (default ./.sift).
+	LocationProject = "project"
+	// LocationXDG keeps the index under the user data directory, keyed by
+	// a hash of the project path, so repositories stay clean.
+	LocationXDG = "xdg"
+)
+
+// FindProjectRoot walks up from dir to the nearest directory holding an
+// index named indexName, a .sift.toml, or a .git, and returns it. ok is
+// false, and root is dir, when no ancestor has any of them.
+func FindProjectRoot(dir, indexName string) (root string, ok bool) {
+	for d := filepath.Clean(dir); ; {
+		for _, marker := range []string{indexName, DefaultFile, ".git"} {
+			if _, err := os.Stat(filepath.Join(d, marker)); err == nil {
+				return d, true
+			}
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir, false
+		}
+		d = parent
+	}
+}
+
+// UserConfigFile returns the user-level config file path
+// ($XDG_CONFIG_HOME/sift/config.toml or the platform equivalent),
+// or "" if no home directory can be determined.
+func UserConfigFile(getenv func(string) string) string {
+	dir := configHome(runtime.GOOS, getenv)
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "sift", "config.toml")
+}
+
+// ProjectDataDir returns the per-project index directory under dataHome:
+// <dataHome>/sift/<first 16 hex chars of sha256(projectPath)>.
+func ProjectDataDir(dataHome, projectPath string) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(projectPath)))
+	return filepath.Join(dataHome, "sift", hex.EncodeToString(sum[:])[:16])
+}
+
+// GlobalIndexDir returns the directory of the personal index selected by
+// --global: <data home>/sift/global. It returns "" if no data directory
+// can be determined.
+func GlobalIndexDir(getenv func(string) string) string {
+	home := dataHome(runtime.GOOS, getenv)
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, "sift", "global")
+}
+
+// OrtLibName returns the file name of the onnxruntime shared library on goos.
+func OrtLibName(goos string) string {
+	if goos == "windows" {
+		return "onnxruntime.dll"
+	}
+	return "onnxruntime.so"
+}
+
+// ortLibCandidates lists where ResolveOrtLib looks for the library relative
+// to the executable's directory: lib/, and on Windows first the directory
+// itself, where release archives put the DLL.
+func ortLibCandidates(goos, exeDir string) []string {
+	name := OrtLibName(goos)
+	candidates := []string{filepath.Join(exeDir, "lib", name)}
+	if goos == "windows" {
+		candidates = append([]string{filepath.Join(exeDir, name)}, candidates...)
+	}
+	return candidates
+}
+
+// configHome mirrors os.UserConfigDir but reads the environment through
+// getenv so callers (and tests) control every input.
+func configHome(goos string, getenv func(string) string) string {
+	if d := getenv("XDG_CONFIG_HOME"); d != "" {
+		return d
+	}
+	switch goos {
+	case "windows":
+		return getenv("AppData")
+	case "darwin":
+		if h := getenv("HOME"); h != "" {
+			return filepath.Join(h, "Library", "Application Support")
+		}
+	default:
+		if h := getenv("HOME"); h != "" {
+			return filepath.Join(h, ".config")
+		}
+	}
+	return ""
+}
+
+// dataHome returns $XDG_DATA_HOME or the platform equivalent
+// (%LocalAppData% on Windows, ~/Library/Application Support on macOS,
+// ~/.local/share elsewhere).
+func dataHome(goos string, getenv func(string) string) string {
+	if d := getenv("XDG_DATA_HOME"); d != "" {
+		return d
+	}
+	switch goos {
+	case "windows":
+		return getenv("LocalAppData")
+	case "darwin":
+		if h := getenv("HOME"); h != "" {
+			return filepath.Join(h, "Library", "Application Support")
+		}
+	default:
+		if h := getenv("HOME"); h != "" {
+			return filepath.Join(h, ".local", "share")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package config
+
+// Change is one setting whose effective value differs between two
+// resolutions of the configuration.
+type Change struct {
+	Key string
+	Old string
+	New string
+	// Live is set when a running process can apply the change; see
+	// LiveSettings. Any other change needs a restart.
+	Live bool
+}
+
+// LiveSettings lists the settings long-running commands (watch, serve)
+// apply on a reload. The rest are baked into the loaded model or the open
+// index.
+var LiveSettings = map[string]bool{
+	"low-priority":          true,
+	"max-file-kb":           true,
+	"oversized":             true,
+	"preview":               true,
+	"redact-pattern":        true,
+	"categories":            true,
+	"compress-meta":         true,
+	"calibrate":             true,
+	"max-per-dir":           true,
+	"result-cache":          true,
+	"result-cache-ttl":      true,
+	"stopwords":             true,
+	"min-boost-words":       true,
+	"expand-queries":        true,
+	"synonyms":              true,
+	"max-embed-failures":    true,
+	"max-embed-failure-pct": true,
+}
+
+// Diff returns the settings whose value in next differs from c, in
+// Settings order.
+func (c *Config) Diff(next *Config) []Change {
+	var changes []Change
+	for _, s := range Settings {
+		if old, nv := s.get(c), s.get(next); old != nv {
+			changes = append(changes, Change{Key: s.Key, Old: old, New: nv, Live: LiveSettings[s.Key]})
+		}
+	}
+	return changes
+}
+
+// Reload diffs c against next and copies the live changes (value and
+// source) into c. It returns the changes it applied and those that need
+// a restart; c keeps the old value of the latter, since that is what is
+// still in effect.
+func (c *Config) Reload(next *Config) (applied, restart []Change) {
+	for _, ch := range c.Diff(next) {
+		if !ch.Live {
+			restart = append(restart, ch)
+			continue
+		}
+		for _, s := range Settings {
+			if s.Key == ch.Key {
+				// The value was validated when next was resolved.
+				_ = s.set(c, ch.New)
+				c.Sources[s.Key] = next.Sources[s.Key]
+			}
+		}
+		applied = append(applied, ch)
+	}
+	return applied, restart
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package doctor
+
+import "errors"
+
+// diskFree is not implemented on this platform; the check is skipped.
+func diskFree(string) (uint64, error) {
+	return 0, errors.New("free-space probe not supported on this platform")
+}
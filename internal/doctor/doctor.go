@@ -0,0 +1,410 @@
+// Package doctor implements the environment checks behind `sift doctor`.
+// Every check is a plain function returning a Result so tests can run it
+// against a deliberately broken Env.
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/tejas242/sift/internal/config"
+	"github.com/tejas242/sift/internal/embed"
+	"github.com/tejas242/sift/internal/index"
+)
+
+// Status is the outcome of a single check.
+type Status int
+
+const (
+	// Pass means the check succeeded.
+	Pass Status = iota
+	// Warn means sift will work but something deserves attention.
+	Warn
+	// Fail is a hard failure: sift will not work until it is fixed.
+	Fail
+	// Skip means the check did not apply or depended on a failed check.
+	Skip
+)
+
+// Result is the structured outcome of one check.
+type Result struct {
+	Name   string
+	Status Status
+	Detail string
+	Hint   string // remediation, shown only for Warn/Fail
+}
+
+// Embedder is the subset of the embedder used by the embedding check.
+type Embedder interface {
+	Embed(texts []string) ([][]float32, error)
+	Close()
+}
+
+// Env describes the environment under inspection.
+type Env struct {
+	ModelDir string
+	OrtLib   string // resolved library path; "" = system default
+	IndexDir string
+	Threads  int
+	// ConfigFiles are the config files in effect, checked for unknown keys.
+	ConfigFiles []string
+	// Prefixes are the embedding prefixes the settings give, compared
+	// with those the index was built with.
+	Prefixes embed.Prefixes
+
+	// InotifyPath is the procfs file holding the inotify watch limit.
+	InotifyPath string
+	// NewEmbedder loads the embedder for the test-embedding check.
+	NewEmbedder func(modelDir, ortLib string, threads int) (Embedder, error)
+	// DiskFree reports free bytes on the filesystem holding path.
+	DiskFree func(path string) (uint64, error)
+
+	modelHash string
+}
+
+// DefaultEnv returns an Env wired to the real embedder and OS probes.
+func DefaultEnv(modelDir, ortLib, indexDir string, threads int) *Env {
+	return &Env{
+		ModelDir:    modelDir,
+		OrtLib:      ortLib,
+		IndexDir:    indexDir,
+		Threads:     threads,
+		Prefixes:    embed.DefaultPrefixes,
+		InotifyPath: "/proc/sys/fs/inotify/max_user_watches",
+		NewEmbedder: func(modelDir, ortLib string, threads int) (Embedder, error) {
+			return embed.New(modelDir, ortLib, threads)
+		},
+		DiskFree: diskFree,
+	}
+}
+
+// modelFiles are the files that must exist in the model directory.
+var modelFiles = []string{"model.onnx", "tokenizer.json"}
+
+// knownModels maps the SHA-256 of each model.onnx sift is known to work
+// with to where it comes from.
+var knownModels = map[string]string{
+	// https://huggingface.co/BAAI/bge-small-en-v1.5, onnx/model.onnx, as
+	// `make download-model` fetches it.
+	"828e1496d7fabb79cfa4dcd84fa38625c0d3d21da474a00f08db0f559940cf35": embed.ModelName,
+}
+
+const (
+	// minInotifyWatches is the watch limit below which large trees run out.
+	minInotifyWatches = 65536
+	// Free-space thresholds for the index filesystem.
+	diskFailBytes = 50 << 20
+	diskWarnBytes = 500 << 20
+)
+
+// Run executes every check in order. Checks that depend on an earlier hard
+// failure are reported as skipped rather than failing a second time.
+func Run(env *Env) []Result {
+	var results []Result
+	model := CheckModelFiles(env)
+	tok := CheckTokenizer(env)
+	ortRes := CheckORT(env)
+	results = append(results, model, tok, ortRes)
+
+	if model.Status == Fail || tok.Status == Fail || ortRes.Status == Fail {
+		results = append(results, Result{
+			Name:   "test embedding",
+			Status: Skip,
+			Detail: "requires model, tokenizer and onnxruntime",
+		})
+	} else {
+		results = append(results, CheckEmbedding(env))
+	}
+
+	results = append(results, CheckConfigKeys(env), CheckManifest(env), CheckLastHeal(env), CheckDiskSpace(env))
+	if runtime.GOOS == "linux" {
+		results = append(results, CheckInotify(env))
+	}
+	return results
+}
+
+// Failed reports whether any result is a hard failure.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckModelFiles verifies the model directory holds the ONNX model and
+// tokenizer, and checks the model's SHA-256 against knownModels. Another
+// model may work, so a mismatch only warns, showing the hash.
+func CheckModelFiles(env *Env) Result {
+	r := Result{Name: "model files"}
+	for _, name := range modelFiles {
+		p := filepath.Join(env.ModelDir, name)
+		if _, err := os.Stat(p); err != nil {
+			r.Status = Fail
+			r.Detail = fmt.Sprintf("%s not found", p)
+			r.Hint = "run `make download-model` or point --model-dir at the model directory"
+			return r
+		}
+	}
+	hash, err := env.hash()
+	if err != nil {
+		r.Status = Fail
+		r.Detail = fmt.Sprintf("read model.onnx: %v", err)
+		r.Hint = "re-download the model with `make download-model`"
+		return r
+	}
+	name, ok := knownModels[hash]
+	if !ok {
+		r.Status = Warn
+		r.Detail = fmt.Sprintf("model.onnx in %s is not a known %s (sha256 %s)", env.ModelDir, embed.ModelName, hash)
+		r.Hint = "the file may be corrupt or another model; re-download it with `make download-model`"
+		return r
+	}
+	r.Detail = fmt.Sprintf("%s in %s (sha256 %s…)", name, env.ModelDir, hash[:12])
+	return r
+}
+
+// CheckTokenizer verifies tokenizer.json parses.
+func CheckTokenizer(env *Env) Result {
+	r := Result{Name: "tokenizer"}
+	if err := embed.CheckTokenizer(env.ModelDir); err != nil {
+		r.Status = Fail
+		r.Detail = err.Error()
+		r.Hint = "tokenizer.json is missing or corrupt — re-run `make download-model`"
+		return r
+	}
+	r.Detail = "tokenizer.json loads"
+	return r
+}
+
+// CheckORT verifies the ONNX Runtime shared library resolves and initializes.
+func CheckORT(env *Env) Result {
+	r := Result{Name: "onnxruntime"}
+	lib := env.OrtLib
+	if lib != "" {
+		if _, err := os.Stat(lib); err != nil {
+			r.Status = Fail
+			r.Detail = fmt.Sprintf("%s not found", lib)
+			r.Hint = "run `make download-ort` or pass --ort-lib with the library for your OS/arch"
+			return r
+		}
+	}
+	if err := embed.InitRuntime(lib); err != nil {
+		r.Status = Fail
+		r.Detail = err.Error()
+		r.Hint = "the library may be for a different OS/arch — re-run `make download-ort`"
+		return r
+	}
+	if lib == "" {
+		lib = "system default"
+	}
+	r.Detail = fmt.Sprintf("%s (v%s)", lib, embed.RuntimeVersion())
+	return r
+}
+
+// CheckEmbedding runs a real embedding and checks its dimension.
+func CheckEmbedding(env *Env) Result {
+	r := Result{Name: "test embedding"}
+	e, err := env.NewEmbedder(env.ModelDir, env.OrtLib, env.Threads)
+	if err != nil {
+		r.Status = Fail
+		r.Detail = err.Error()
+		r.Hint = "check the model and onnxruntime versions match (ORT 1.24.x)"
+		return r
+	}
+	defer e.Close()
+	vecs, err := e.Embed([]string{"sift doctor"})
+	if err != nil {
+		r.Status = Fail
+		r.Detail = err.Error()
+		r.Hint = "inference failed — try `sift --threads 1 doctor` or re-download the model"
+		return r
+	}
+	if len(vecs) != 1 || len(vecs[0]) != embed.EmbeddingDim {
+		got := 0
+		if len(vecs) > 0 {
+			got = len(vecs[0])
+		}
+		r.Status = Fail
+		r.Detail = fmt.Sprintf("got %d-dim vector, want %d", got, embed.EmbeddingDim)
+		r.Hint = fmt.Sprintf("the model in %s is not %s", env.ModelDir, embed.ModelName)
+		return r
+	}
+	r.Detail = fmt.Sprintf("%d-dim vector", embed.EmbeddingDim)
+	return r
+}
+
+// CheckConfigKeys warns about keys in the config files that are not
+// settings; the loader ignores them, so a typo silently has no effect.
+func CheckConfigKeys(env *Env) Result {
+	r := Result{Name: "config keys"}
+	if len(env.ConfigFiles) == 0 {
+		r.Status = Skip
+		r.Detail = "no config file"
+		return r
+	}
+	var problems []string
+	for _, f := range env.ConfigFiles {
+		unknown, err := config.UnknownKeys(f)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		if len(unknown) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: unknown %s", f, strings.Join(unknown, ", ")))
+		}
+	}
+	if len(problems) > 0 {
+		r.Status = Warn
+		r.Detail = strings.Join(problems, "; ")
+		r.Hint = "known keys: " + strings.Join(config.Keys(), ", ") + " (see `sift config list`)"
+		return r
+	}
+	r.Detail = strings.Join(env.ConfigFiles, ", ")
+	return r
+}
+
+// CheckManifest verifies an existing index was built with the current model.
+func CheckManifest(env *Env) Result {
+	r := Result{Name: "index manifest"}
+	m, err := index.ReadManifest(env.IndexDir)
+	if os.IsNotExist(err) {
+		r.Status = Skip
+		r.Detail = fmt.Sprintf("no manifest in %s", env.IndexDir)
+		return r
+	}
+	if err != nil {
+		r.Status = Fail
+		r.Detail = err.Error()
+		r.Hint = "run `sift rebuild <dir>` to recreate the index"
+		return r
+	}
+	if m.FormatVersion > index.FormatVersion {
+		r.Status = Fail
+		r.Detail = fmt.Sprintf("index format v%d is newer than this sift (v%d)", m.FormatVersion, index.FormatVersion)
+		r.Hint = "upgrade sift or rebuild the index"
+		return r
+	}
+	if m.Model != embed.ModelName || m.Dim != embed.EmbeddingDim {
+		r.Status = Fail
+		r.Detail = fmt.Sprintf("index built with %s (%d-dim), current model is %s (%d-dim)",
+			m.Model, m.Dim, embed.ModelName, embed.EmbeddingDim)
+		r.Hint = "run `sift rebuild <dir>` to re-embed with the current model"
+		return r
+	}
+	if m.ModelHash != "" {
+		if hash, err := env.hash(); err == nil && hash != m.ModelHash {
+			r.Status = Fail
+			r.Detail = "model.onnx differs from the one the index was built with"
+			r.Hint = "run `sift rebuild <dir>` to re-embed with the current model"
+			return r
+		}
+	}
+	if was := m.EmbedPrefixes(); was != env.Prefixes {
+		r.Status = Fail
+		r.Detail = fmt.Sprintf("index embedded with %s, but the settings give %s", was, env.Prefixes)
+		r.Hint = "run `sift rebuild <dir>` to re-embed with the current prefixes"
+		return r
+	}
+	r.Detail = fmt.Sprintf("%s, format v%d", m.Model, m.FormatVersion)
+	if m.SampledFiles > 0 {
+		r.Detail += fmt.Sprintf(", %d oversized files indexed in part (sampled)", m.SampledFiles)
+	}
+	return r
+}
+
+// CheckLastHeal reports the repairs recorded the last time the index was
+// opened in an inconsistent state (see index.Heal). A repair is history,
+// not a problem, so it never fails; the files it dropped chunks of are
+// re-embedded by the next index run.
+func CheckLastHeal(env *Env) Result {
+	r := Result{Name: "index self-heal"}
+	m, err := index.ReadManifest(env.IndexDir)
+	if err != nil {
+		// CheckManifest reports a missing or unreadable manifest.
+		r.Status = Skip
+		r.Detail = "no readable manifest"
+		return r
+	}
+	if m.LastHeal == nil {
+		r.Detail = "no repairs recorded"
+		return r
+	}
+	r.Detail = fmt.Sprintf("last repaired %s: %s", m.LastHeal.Time.Local().Format("2006-01-02 15:04"),
+		strings.Join(m.LastHeal.Actions, "; "))
+	return r
+}
+
+// CheckDiskSpace verifies the filesystem holding the index has room to grow.
+func CheckDiskSpace(env *Env) Result {
+	r := Result{Name: "disk space"}
+	// Probe the nearest existing ancestor: the index dir may not exist yet.
+	dir := env.IndexDir
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	free, err := env.DiskFree(dir)
+	if err != nil {
+		r.Status = Skip
+		r.Detail = err.Error()
+		return r
+	}
+	r.Detail = fmt.Sprintf("%d MB free for %s", free>>20, env.IndexDir)
+	switch {
+	case free < diskFailBytes:
+		r.Status = Fail
+		r.Hint = "free up disk space; indexing writes several temporary files"
+	case free < diskWarnBytes:
+		r.Status = Warn
+		r.Hint = "large indexes may not fit; free up disk space"
+	}
+	return r
+}
+
+// CheckInotify verifies the inotify watch limit is high enough for `sift watch`.
+func CheckInotify(env *Env) Result {
+	r := Result{Name: "inotify watches"}
+	data, err := os.ReadFile(env.InotifyPath)
+	if err != nil {
+		r.Status = Skip
+		r.Detail = err.Error()
+		return r
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		r.Status = Skip
+		r.Detail = fmt.Sprintf("unreadable limit %q", strings.TrimSpace(string(data)))
+		return r
+	}
+	r.Detail = fmt.Sprintf("max_user_watches = %d", n)
+	if n < minInotifyWatches {
+		r.Status = Warn
+		r.Hint = "`sift watch` on large trees may miss changes — run `sudo sysctl fs.inotify.max_user_watches=524288`"
+	}
+	return r
+}
+
+// hash returns the model hash, computing it at most once per Env.
+func (env *Env) hash() (string, error) {
+	if env.modelHash != "" {
+		return env.modelHash, nil
+	}
+	h, err := embed.HashModel(env.ModelDir)
+	if err != nil {
+		return "", err
+	}
+	env.modelHash = h
+	return h, nil
+}
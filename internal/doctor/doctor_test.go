@@ -0,0 +1,260 @@
+package doctor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/embed"
+)
+
+type fakeEmbedder struct {
+	dim int
+	err error
+}
+
+func (f *fakeEmbedder) Embed(texts []string) ([][]float32, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return [][]float32{make([]float32, f.dim)}, nil
+}
+
+func (f *fakeEmbedder) Close() {}
+
+// brokenEnv returns an Env pointing at an empty model dir and a temp index dir.
+func brokenEnv(t *testing.T) *Env {
+	t.Helper()
+	dir := t.TempDir()
+	env := DefaultEnv(filepath.Join(dir, "models"), "", filepath.Join(dir, ".sift"), 0)
+	env.DiskFree = func(string) (uint64, error) { return 10 << 30, nil }
+	return env
+}
+
+func writeModel(t *testing.T, env *Env) {
+	t.Helper()
+	if err := os.MkdirAll(env.ModelDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"model.onnx":     "not really onnx",
+		"tokenizer.json": "{",
+	} {
+		if err := os.WriteFile(filepath.Join(env.ModelDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func writeManifest(t *testing.T, env *Env, content string) {
+	t.Helper()
+	if err := os.MkdirAll(env.IndexDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(env.IndexDir, "manifest.json"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckModelFiles(t *testing.T) {
+	env := brokenEnv(t)
+	if r := CheckModelFiles(env); r.Status != Fail || r.Hint == "" {
+		t.Errorf("missing model: got status %v hint %q, want Fail with hint", r.Status, r.Hint)
+	}
+
+	writeModel(t, env)
+	hash, err := embed.HashModel(env.ModelDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := CheckModelFiles(env); r.Status != Warn || !strings.Contains(r.Detail, hash) || r.Hint == "" {
+		t.Errorf("unknown model: got %v (%s), want Warn showing sha256 %s", r.Status, r.Detail, hash)
+	}
+
+	knownModels[hash] = "test model"
+	t.Cleanup(func() { delete(knownModels, hash) })
+	if r := CheckModelFiles(env); r.Status != Pass || !strings.Contains(r.Detail, "test model") {
+		t.Errorf("known model: got %v (%s), want Pass naming it", r.Status, r.Detail)
+	}
+}
+
+func TestCheckTokenizerCorrupt(t *testing.T) {
+	env := brokenEnv(t)
+	writeModel(t, env) // tokenizer.json is truncated JSON
+	if r := CheckTokenizer(env); r.Status != Fail {
+		t.Errorf("corrupt tokenizer: got %v, want Fail", r.Status)
+	}
+}
+
+func TestCheckORTMissingLibrary(t *testing.T) {
+	env := brokenEnv(t)
+	env.OrtLib = filepath.Join(t.TempDir(), "onnxruntime.so")
+	r := CheckORT(env)
+	if r.Status != Fail {
+		t.Fatalf("missing ORT library: got %v, want Fail", r.Status)
+	}
+	if r.Hint == "" {
+		t.Error("expected a remediation hint")
+	}
+}
+
+func TestCheckEmbedding(t *testing.T) {
+	env := brokenEnv(t)
+
+	env.NewEmbedder = func(string, string, int) (Embedder, error) {
+		return &fakeEmbedder{dim: embed.EmbeddingDim}, nil
+	}
+	if r := CheckEmbedding(env); r.Status != Pass {
+		t.Errorf("good embedder: got %v (%s), want Pass", r.Status, r.Detail)
+	}
+
+	env.NewEmbedder = func(string, string, int) (Embedder, error) {
+		return &fakeEmbedder{dim: 768}, nil
+	}
+	if r := CheckEmbedding(env); r.Status != Fail {
+		t.Errorf("wrong dimension: got %v, want Fail", r.Status)
+	}
+
+	env.NewEmbedder = func(string, string, int) (Embedder, error) {
+		return &fakeEmbedder{err: errors.New("ort run failed")}, nil
+	}
+	if r := CheckEmbedding(env); r.Status != Fail {
+		t.Errorf("inference error: got %v, want Fail", r.Status)
+	}
+}
+
+func TestCheckConfigKeys(t *testing.T) {
+	env := brokenEnv(t)
+	if r := CheckConfigKeys(env); r.Status != Skip {
+		t.Errorf("no config: got %v, want Skip", r.Status)
+	}
+
+	f := filepath.Join(t.TempDir(), ".sift.toml")
+	env.ConfigFiles = []string{f}
+	if err := os.WriteFile(f, []byte("threads = 2\nmax-file-kb = 64\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if r := CheckConfigKeys(env); r.Status != Pass {
+		t.Errorf("known keys: got %v (%s), want Pass", r.Status, r.Detail)
+	}
+
+	if err := os.WriteFile(f, []byte("threads = 2\nmax-file-mb = 1\nmodeldir = \"x\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := CheckConfigKeys(env)
+	if r.Status != Warn || !strings.Contains(r.Detail, "max-file-mb, modeldir") {
+		t.Errorf("typo'd keys: got %v (%s), want Warn naming both", r.Status, r.Detail)
+	}
+}
+
+func TestCheckManifest(t *testing.T) {
+	env := brokenEnv(t)
+	if r := CheckManifest(env); r.Status != Skip {
+		t.Errorf("no index: got %v, want Skip", r.Status)
+	}
+
+	writeManifest(t, env, `{"format_version":1,"model":"BGE-small-en-v1.5","dim":384}`)
+	if r := CheckManifest(env); r.Status != Pass {
+		t.Errorf("matching manifest: got %v (%s), want Pass", r.Status, r.Detail)
+	}
+
+	writeManifest(t, env, `{"format_version":1,"model":"BGE-small-en-v1.5","dim":384,"sampled_files":2}`)
+	if r := CheckManifest(env); r.Status != Pass || !strings.Contains(r.Detail, "2 oversized files indexed in part") {
+		t.Errorf("sampled files: got %v (%s), want Pass naming them", r.Status, r.Detail)
+	}
+
+	writeManifest(t, env, `{"format_version":1,"model":"e5-base","dim":768}`)
+	if r := CheckManifest(env); r.Status != Fail {
+		t.Errorf("model mismatch: got %v, want Fail", r.Status)
+	}
+
+	writeManifest(t, env, `{"format_version":1,"model":"BGE-small-en-v1.5","dim":384,
+		"prefixes":{"query":"query: ","document":"passage: "}}`)
+	if r := CheckManifest(env); r.Status != Fail || !strings.Contains(r.Detail, `"passage: "`) {
+		t.Errorf("prefix mismatch: got %v (%s), want Fail", r.Status, r.Detail)
+	}
+
+	writeModel(t, env)
+	writeManifest(t, env, `{"format_version":1,"model":"BGE-small-en-v1.5","dim":384,"model_hash":"deadbeef"}`)
+	if r := CheckManifest(env); r.Status != Fail {
+		t.Errorf("model hash mismatch: got %v, want Fail", r.Status)
+	}
+
+	writeManifest(t, env, `{not json`)
+	if r := CheckManifest(env); r.Status != Fail {
+		t.Errorf("corrupt manifest: got %v, want Fail", r.Status)
+	}
+}
+
+func TestCheckLastHeal(t *testing.T) {
+	env := brokenEnv(t)
+	if r := CheckLastHeal(env); r.Status != Skip {
+		t.Errorf("no index: got %v, want Skip", r.Status)
+	}
+
+	writeManifest(t, env, `{"format_version":1,"model":"BGE-small-en-v1.5","dim":384}`)
+	if r := CheckLastHeal(env); r.Status != Pass || r.Detail != "no repairs recorded" {
+		t.Errorf("no repairs: got %v (%s)", r.Status, r.Detail)
+	}
+
+	writeManifest(t, env, `{"format_version":1,"model":"BGE-small-en-v1.5","dim":384,
+		"last_heal":{"time":"2025-01-02T03:04:05Z","actions":["summary.json was missing","kept the first 2"]}}`)
+	if r := CheckLastHeal(env); r.Status != Pass || !strings.Contains(r.Detail, "summary.json was missing; kept the first 2") {
+		t.Errorf("recorded repair: got %v (%s)", r.Status, r.Detail)
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	env := brokenEnv(t)
+	cases := []struct {
+		free uint64
+		want Status
+	}{
+		{10 << 30, Pass},
+		{100 << 20, Warn},
+		{1 << 20, Fail},
+	}
+	for _, tc := range cases {
+		env.DiskFree = func(string) (uint64, error) { return tc.free, nil }
+		if r := CheckDiskSpace(env); r.Status != tc.want {
+			t.Errorf("free=%d: got %v, want %v", tc.free, r.Status, tc.want)
+		}
+	}
+}
+
+func TestCheckInotify(t *testing.T) {
+	env := brokenEnv(t)
+	env.InotifyPath = filepath.Join(t.TempDir(), "max_user_watches")
+
+	if err := os.WriteFile(env.InotifyPath, []byte("8192\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if r := CheckInotify(env); r.Status != Warn {
+		t.Errorf("low limit: got %v, want Warn", r.Status)
+	}
+
+	if err := os.WriteFile(env.InotifyPath, []byte("524288\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if r := CheckInotify(env); r.Status != Pass {
+		t.Errorf("high limit: got %v, want Pass", r.Status)
+	}
+}
+
+func TestRunSkipsEmbeddingAfterHardFailure(t *testing.T) {
+	env := brokenEnv(t)
+	called := false
+	env.NewEmbedder = func(string, string, int) (Embedder, error) {
+		called = true
+		return &fakeEmbedder{dim: embed.EmbeddingDim}, nil
+	}
+	results := Run(env)
+	if called {
+		t.Error("embedding check should be skipped when the model is missing")
+	}
+	if !Failed(results) {
+		t.Error("expected Run to report a hard failure")
+	}
+}
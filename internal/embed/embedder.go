@@ -3,28 +3,45 @@
 package embed
 
 import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/daulet/tokenizers"
+	"github.com/tejas242/sift/internal/logging"
 	ort "github.com/yalue/onnxruntime_go"
 )
 
 const (
-	// maxSeqLen is the effective maximum token length per input.
+	// MaxSeqLen is the effective maximum token length per input; longer
+	// inputs are truncated, and only their beginning is embedded.
 	// BGE-small supports up to 512 tokens, but capping at 256 halves the
 	// attention matrix (O(seqLen²)) and is sufficient for 200-word chunks.
 	// Most English text at 200 words ≈ 250 tokens; some unicode-heavy text
 	// may get truncated but embedding quality is negligibly affected.
-	maxSeqLen = 256
+	MaxSeqLen = 256
+	// ModelName identifies the embedding model sift is built around.
+	ModelName = "BGE-small-en-v1.5"
 	// EmbeddingDim is the output dimension of BGE-small-en-v1.5.
 	EmbeddingDim = 384
-	// defaultBatchSize keeps memory + inference latency bounded on low-end CPUs.
-	defaultBatchSize = 4
+	// batchTokens bounds the padded size (texts × longest text, in tokens)
+	// of one inference batch, keeping memory and latency bounded on
+	// low-end CPUs: four full-length texts, or more shorter ones.
+	batchTokens = 4 * MaxSeqLen
+	// maxBatchTexts caps how many short texts share one batch.
+	maxBatchTexts = 32
 
 	// BGEQueryPrefix is prepended to queries (not documents) for asymmetric
 	// retrieval per the BGE-small-en-v1.5 paper recommendation.
@@ -32,47 +49,145 @@ const (
 	BGEQueryPrefix = "Represent this sentence for searching relevant passages: "
 )
 
-// Embedder wraps an ONNX session and a HuggingFace tokenizer.
+// Prefixes are the instructions a model expects in front of the text it
+// embeds. Asymmetric models mark queries and documents differently (BGE
+// prefixes queries only; e5 uses "query: " and "passage: "); symmetric
+// ones treat both sides alike, so queries get the document prefix.
+type Prefixes struct {
+	Query     string `json:"query,omitempty"`
+	Document  string `json:"document,omitempty"`
+	Symmetric bool   `json:"symmetric,omitempty"`
+}
+
+// DefaultPrefixes are those of BGE-small-en-v1.5.
+var DefaultPrefixes = Prefixes{Query: BGEQueryPrefix}
+
+// QueryText returns query as it is to be embedded.
+func (p Prefixes) QueryText(query string) string {
+	if p.Symmetric {
+		return p.Document + query
+	}
+	return p.Query + query
+}
+
+// DocumentText returns a document chunk as it is to be embedded.
+func (p Prefixes) DocumentText(text string) string {
+	return p.Document + text
+}
+
+// String describes p for messages, quoting the prefixes.
+func (p Prefixes) String() string {
+	if p.Symmetric {
+		return fmt.Sprintf("symmetric, prefix %q", p.Document)
+	}
+	return fmt.Sprintf("query prefix %q, document prefix %q", p.Query, p.Document)
+}
+
+// Embedder wraps ONNX sessions and a HuggingFace tokenizer. It is safe
+// for concurrent use (see sessions.go); SetLogger and SetPhaseFunc are
+// not, and are called before it is shared.
 type Embedder struct {
-	session   *ort.DynamicAdvancedSession
-	tokenizer *tokenizers.Tokenizer
-	batchSize int
+	sessions  *sessionPool
+	tokMu     sync.Mutex // serializes calls into tokenizer
+	tokenizer encoder
+	log       *logging.Logger
+	phase     PhaseFunc
+
+	threadsMu sync.Mutex // serializes SetThreads
+	threads   int        // intra-op threads of each session
+	// newSessions creates as many sessions as e has, with the given
+	// intra-op threads each; nil where they cannot be re-created.
+	newSessions func(threads int) ([]session, error)
 }
 
+// PhaseFunc receives the time one embedding phase took.
+type PhaseFunc func(phase string, d time.Duration)
+
+// Phases reported by an Embedder's PhaseFunc: tokenizing once per Embed
+// call, inference once per batch.
+const (
+	PhaseTokenize  = "tokenize"
+	PhaseInference = "inference" // tensor setup + session.Run, waiting for a session included
+)
+
+// SetPhaseFunc makes e report per-batch phase timings to f (nil stops it).
+func (e *Embedder) SetPhaseFunc(f PhaseFunc) {
+	e.phase = f
+}
+
+// ErrModelMissing is wrapped by New's error when model.onnx or
+// tokenizer.json is absent from the model directory.
+var ErrModelMissing = errors.New("model files missing")
+
+// ErrRuntime is wrapped by InitRuntime's error when the ONNX Runtime
+// library cannot be loaded or initialized.
+var ErrRuntime = errors.New("init ort")
+
 // New loads the ONNX model and tokenizer from modelDir.
 // ortLibPath is the path to onnxruntime.so; pass "" to use the system default.
 // numThreads controls intra-op parallelism; 0 = use min(4, NumCPU).
 // modelDir must contain: model.onnx, tokenizer.json
 func New(modelDir, ortLibPath string, numThreads int) (*Embedder, error) {
+	return NewSessions(modelDir, ortLibPath, numThreads, DefaultSessions)
+}
+
+// NewSessions is New with n inference sessions, so that up to n batches
+// run in parallel (see sessions.go). Each session has numThreads intra-op
+// threads and its own copy of the model in memory; n below 1 means 1.
+func NewSessions(modelDir, ortLibPath string, numThreads, n int) (*Embedder, error) {
 	modelPath := filepath.Join(modelDir, "model.onnx")
 	tokenPath := filepath.Join(modelDir, "tokenizer.json")
 
 	if _, err := os.Stat(modelPath); err != nil {
-		return nil, fmt.Errorf("model not found at %s — run `make download-model` first", modelPath)
+		return nil, fmt.Errorf("%w: %s not found — run `make download-model` first", ErrModelMissing, modelPath)
 	}
 	if _, err := os.Stat(tokenPath); err != nil {
-		return nil, fmt.Errorf("tokenizer not found at %s — run `make download-model` first", tokenPath)
+		return nil, fmt.Errorf("%w: %s not found — run `make download-model` first", ErrModelMissing, tokenPath)
 	}
 
-	// Point ORT at the bundled shared library if specified.
-	if ortLibPath != "" {
-		ort.SetSharedLibraryPath(ortLibPath)
+	if err := InitRuntime(ortLibPath); err != nil {
+		return nil, err
 	}
 
-	// Initialize ONNX Runtime (no-op if already initialized).
-	if err := ort.InitializeEnvironment(); err != nil {
-		return nil, fmt.Errorf("init ort: %w", err)
+	n = max(n, 1)
+	threads := threadCount(numThreads)
+	newSessions := func(threads int) ([]session, error) {
+		return newORTSessions(modelPath, threads, n)
+	}
+	sessions, err := newSessions(threads)
+	if err != nil {
+		return nil, err
 	}
 
-	// Determine thread count. More threads rarely help on ≤4-core machines
-	// and cause severe contention when both IntraOp and InterOp spawn threads.
-	if numThreads <= 0 {
-		numThreads = runtime.NumCPU()
-		if numThreads > 4 {
-			numThreads = 4
-		}
+	tk, err := tokenizers.FromFile(tokenPath)
+	if err != nil {
+		newSessionPool(sessions).destroy()
+		return nil, fmt.Errorf("load tokenizer: %w", err)
+	}
+
+	return &Embedder{
+		sessions:    newSessionPool(sessions),
+		tokenizer:   tk,
+		log:         logging.Default(),
+		threads:     threads,
+		newSessions: newSessions,
+	}, nil
+}
+
+// threadCount returns the intra-op thread count a session gets for the
+// threads setting n: n itself, or for 0 (or less) the CPU count capped
+// at 4. More threads rarely help on ≤4-core machines and cause severe
+// contention when both IntraOp and InterOp spawn threads.
+func threadCount(n int) int {
+	if n > 0 {
+		return n
 	}
+	return min(runtime.NumCPU(), 4)
+}
 
+// newORTSessions creates n ONNX Runtime sessions of the model at
+// modelPath, each with threads intra-op threads.
+func newORTSessions(modelPath string, threads, n int) ([]session, error) {
 	// Build session options (CPU only, conservatively threaded).
 	opts, err := ort.NewSessionOptions()
 	if err != nil {
@@ -81,7 +196,7 @@ func New(modelDir, ortLibPath string, numThreads int) (*Embedder, error) {
 	defer opts.Destroy()
 
 	// IntraOpNumThreads: parallelism WITHIN a single op (e.g. MatMul).
-	if err := opts.SetIntraOpNumThreads(numThreads); err != nil {
+	if err := opts.SetIntraOpNumThreads(threads); err != nil {
 		return nil, fmt.Errorf("set intra threads: %w", err)
 	}
 	// InterOpNumThreads: parallelism BETWEEN ops in the graph.
@@ -94,28 +209,167 @@ func New(modelDir, ortLibPath string, numThreads int) (*Embedder, error) {
 	inputNames := []string{"input_ids", "attention_mask", "token_type_ids"}
 	outputNames := []string{"last_hidden_state"}
 
-	session, err := ort.NewDynamicAdvancedSession(modelPath, inputNames, outputNames, opts)
+	sessions := make([]session, 0, n)
+	for range n {
+		s, err := ort.NewDynamicAdvancedSession(modelPath, inputNames, outputNames, opts)
+		if err != nil {
+			newSessionPool(sessions).destroy()
+			return nil, fmt.Errorf("create session: %w", err)
+		}
+		sessions = append(sessions, ortSession{s})
+	}
+	return sessions, nil
+}
+
+// Threads returns the intra-op thread count of each of e's sessions.
+func (e *Embedder) Threads() int {
+	e.threadsMu.Lock()
+	defer e.threadsMu.Unlock()
+	return e.threads
+}
+
+// SetThreads re-creates e's inference sessions with n intra-op threads
+// each (0 picks as New does), for a configuration reload: ONNX Runtime
+// fixes a session's threads when it is created. Batches running finish on
+// the old sessions, destroyed as they are released; the rest run on the
+// new ones. It does nothing when the count is unchanged.
+func (e *Embedder) SetThreads(n int) error {
+	e.threadsMu.Lock()
+	defer e.threadsMu.Unlock()
+	n = threadCount(n)
+	if n == e.threads || e.newSessions == nil {
+		return nil
+	}
+	sessions, err := e.newSessions(n)
 	if err != nil {
-		return nil, fmt.Errorf("create session: %w", err)
+		return fmt.Errorf("set threads: %w", err)
 	}
+	e.sessions.replace(sessions)
+	e.threads = n
+	e.log.Debugf("embedder: %d sessions re-created with %d threads each", len(sessions), n)
+	return nil
+}
 
-	tk, err := tokenizers.FromFile(tokenPath)
+// SetLogger routes debug timings to l; nil silences them.
+func (e *Embedder) SetLogger(l *logging.Logger) {
+	e.log = l
+}
+
+// InitRuntime points ONNX Runtime at ortLibPath (or the system default when
+// empty) and initializes the environment. It is a no-op if ORT is already
+// initialized in this process.
+func InitRuntime(ortLibPath string) error {
+	if ort.IsInitialized() {
+		return nil
+	}
+	if ortLibPath != "" {
+		ort.SetSharedLibraryPath(ortLibPath)
+	}
+	if err := ort.InitializeEnvironment(); err != nil {
+		return fmt.Errorf("%w: %w", ErrRuntime, err)
+	}
+	return nil
+}
+
+// RuntimeVersion returns the version string reported by the loaded ONNX
+// Runtime library. InitRuntime must have succeeded first.
+func RuntimeVersion() string {
+	if !ort.IsInitialized() {
+		return ""
+	}
+	return ort.GetVersion()
+}
+
+// ProbeRuntime initializes ONNX Runtime from ortLibPath (see InitRuntime)
+// and returns the version the library reports.
+func ProbeRuntime(ortLibPath string) (string, error) {
+	if err := InitRuntime(ortLibPath); err != nil {
+		return "", err
+	}
+	return RuntimeVersion(), nil
+}
+
+// CheckTokenizer loads tokenizer.json from modelDir and immediately releases it.
+func CheckTokenizer(modelDir string) error {
+	tk, err := tokenizers.FromFile(filepath.Join(modelDir, "tokenizer.json"))
+	if err != nil {
+		return fmt.Errorf("load tokenizer: %w", err)
+	}
+	tk.Close()
+	return nil
+}
+
+// TokenCounter counts tokens with the model's tokenizer, without loading
+// the ONNX model.
+type TokenCounter struct {
+	tk *tokenizers.Tokenizer
+}
+
+// countSegmentBytes bounds each Encode call: tokenizer.json truncates at
+// the model's 512-token limit, so longer text is counted piecewise.
+const countSegmentBytes = 400
+
+// NewTokenCounter loads tokenizer.json from modelDir.
+func NewTokenCounter(modelDir string) (*TokenCounter, error) {
+	tk, err := tokenizers.FromFile(filepath.Join(modelDir, "tokenizer.json"))
 	if err != nil {
-		session.Destroy()
 		return nil, fmt.Errorf("load tokenizer: %w", err)
 	}
+	return &TokenCounter{tk: tk}, nil
+}
 
-	return &Embedder{
-		session:   session,
-		tokenizer: tk,
-		batchSize: defaultBatchSize,
-	}, nil
+// Count returns the number of tokens in text, excluding special tokens.
+func (c *TokenCounter) Count(text string) int {
+	n := 0
+	for len(text) > 0 {
+		cut := len(text)
+		if cut > countSegmentBytes {
+			// Split after a newline or space so words stay whole.
+			cut = strings.LastIndexAny(text[:countSegmentBytes], "\n ") + 1
+			if cut <= 0 {
+				cut = countSegmentBytes
+				for cut > 0 && !utf8.RuneStart(text[cut]) {
+					cut--
+				}
+			}
+		}
+		ids, _ := c.tk.Encode(text[:cut], false)
+		n += len(ids)
+		text = text[cut:]
+	}
+	return n
+}
+
+// Close releases the tokenizer.
+func (c *TokenCounter) Close() {
+	c.tk.Close()
+}
+
+// HashModel returns the hex SHA-256 of model.onnx in modelDir. It reads the
+// whole file, so callers should avoid it on hot paths.
+func HashModel(modelDir string) (string, error) {
+	f, err := os.Open(filepath.Join(modelDir, "model.onnx"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash model: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-// Close releases the ONNX session and tokenizer.
+// Close releases the ONNX sessions and tokenizer. No call may be in
+// progress. How the sessions were shared is logged at debug level.
 func (e *Embedder) Close() {
-	if e.session != nil {
-		e.session.Destroy()
+	if e.sessions != nil {
+		if st := e.sessions.Stats(); st.Runs > 0 {
+			e.log.Debugf("embedder: %d inference batches on %d sessions; %d waited for a session, %v in all, at most %v (queries at most %v, %d queued at most)",
+				st.Runs, st.Sessions, st.Waited, st.Wait.Round(time.Millisecond), st.MaxWait.Round(time.Millisecond),
+				st.MaxInteractiveWait.Round(time.Millisecond), st.MaxQueued)
+		}
+		e.sessions.destroy()
 	}
 	if e.tokenizer != nil {
 		e.tokenizer.Close()
@@ -125,27 +379,41 @@ func (e *Embedder) Close() {
 // Embed embeds a batch of document texts (no instruction prefix).
 // Use this for indexing document chunks.
 func (e *Embedder) Embed(texts []string) ([][]float32, error) {
-	results := make([][]float32, 0, len(texts))
-	for i := 0; i < len(texts); i += e.batchSize {
-		end := i + e.batchSize
-		if end > len(texts) {
-			end = len(texts)
-		}
-		batch, err := e.embedBatch(texts[i:end])
-		if err != nil {
-			return nil, fmt.Errorf("batch [%d:%d]: %w", i, end, err)
-		}
-		results = append(results, batch...)
+	vecs, _, err := e.EmbedTruncating(texts)
+	return vecs, err
+}
+
+// EmbedTruncating is Embed that also reports, for each text, whether it
+// tokenized past MaxSeqLen and was truncated.
+//
+// Every text in an inference batch is padded to the longest, so texts
+// are tokenized first and batched with others of similar length (see
+// lengthBatches); the vectors come back in the order of texts.
+func (e *Embedder) EmbedTruncating(texts []string) ([][]float32, []bool, error) {
+	return e.EmbedAt(texts, PriorityManual)
+}
+
+// EmbedAt is EmbedTruncating with its inference batches waiting for a
+// session at priority p (see sessions.go).
+func (e *Embedder) EmbedAt(texts []string, p Priority) ([][]float32, []bool, error) {
+	encs, truncated := e.tokenize(texts)
+	lens := make([]int, len(encs))
+	for i, enc := range encs {
+		lens[i] = len(enc.ids)
 	}
-	return results, nil
+	infer := func(batch []encoded) ([][]float32, error) { return e.infer(batch, p) }
+	vecs, err := embedBatches(encs, lengthBatches(lens, batchTokens, maxBatchTexts), infer)
+	if err != nil {
+		return nil, nil, err
+	}
+	return vecs, truncated, nil
 }
 
-// EmbedQuery embeds a single query string with the BGE instruction prefix.
-// Always use this for search queries — never for document chunks.
-// The prefix "Represent this sentence for searching relevant passages: "
-// is recommended by the BGE authors for asymmetric retrieval tasks.
+// EmbedQuery embeds a single search query, ahead of any indexing waiting
+// for a session. Like Embed, it embeds the text as given: the caller adds
+// the model's query prefix (Prefixes.QueryText).
 func (e *Embedder) EmbedQuery(query string) ([]float32, error) {
-	vecs, err := e.Embed([]string{BGEQueryPrefix + query})
+	vecs, _, err := e.EmbedAt([]string{query}, PriorityInteractive)
 	if err != nil {
 		return nil, err
 	}
@@ -155,22 +423,27 @@ func (e *Embedder) EmbedQuery(query string) ([]float32, error) {
 	return vecs[0], nil
 }
 
+// EmbedQueries embeds several queries, as given, in shared inference
+// batches, as EmbedQuery does.
+func (e *Embedder) EmbedQueries(queries []string) ([][]float32, error) {
+	vecs, _, err := e.EmbedAt(queries, PriorityInteractive)
+	return vecs, err
+}
+
 // encoded holds tokenization results for a single text.
 type encoded struct {
 	ids  []int64
 	mask []int64
 }
 
-// embedBatch runs a single ONNX inference call for up to batchSize texts.
-// Set SIFT_DEBUG=1 to print per-phase timing to stderr.
-func (e *Embedder) embedBatch(texts []string) ([][]float32, error) {
-	debug := os.Getenv("SIFT_DEBUG") == "1"
-	batchSize := len(texts)
+// tokenize encodes texts for the model, cutting them at MaxSeqLen, and
+// reports which were cut. Timings are logged at debug level (e.g.
+// SIFT_DEBUG=1).
+func (e *Embedder) tokenize(texts []string) ([]encoded, []bool) {
 	t0 := time.Now()
-
-	// ── Phase 1: Tokenize ───────────────────────────────────────────────────
-	all := make([]encoded, batchSize)
-	maxLen := 0
+	all := make([]encoded, len(texts))
+	truncated := make([]bool, len(texts))
+	e.tokMu.Lock()
 	for i, text := range texts {
 		enc := e.tokenizer.EncodeWithOptions(
 			text,
@@ -178,8 +451,9 @@ func (e *Embedder) embedBatch(texts []string) ([][]float32, error) {
 			tokenizers.WithReturnAttentionMask(),
 		)
 		ids := enc.IDs
-		if len(ids) > maxSeqLen {
-			ids = ids[:maxSeqLen]
+		if len(ids) > MaxSeqLen {
+			ids = ids[:MaxSeqLen]
+			truncated[i] = true
 		}
 		ids64 := make([]int64, len(ids))
 		mask64 := make([]int64, len(ids))
@@ -193,75 +467,105 @@ func (e *Embedder) embedBatch(texts []string) ([][]float32, error) {
 			}
 		}
 		all[i] = encoded{ids: ids64, mask: mask64}
-		if len(ids64) > maxLen {
-			maxLen = len(ids64)
+	}
+	e.tokMu.Unlock()
+	if e.log.Enabled(logging.LevelDebug) {
+		e.log.Debugf("[debug] tokenize(%d texts):   %v", len(texts), time.Since(t0))
+	}
+	if e.phase != nil {
+		e.phase(PhaseTokenize, time.Since(t0))
+	}
+	return all, truncated
+}
+
+// lengthBatches groups the texts of the given token lengths into
+// inference batches: by ascending length, each batch as large as
+// maxTexts and a padded size of budget tokens allow, but at least one
+// text. It returns each batch's indexes into lens.
+func lengthBatches(lens []int, budget, maxTexts int) [][]int {
+	order := make([]int, len(lens))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(lens[a], lens[b]) })
+	var batches [][]int
+	var cur []int
+	for _, i := range order {
+		// Sorted, so lens[i] is the longest of cur plus i.
+		if len(cur) > 0 && (len(cur) == maxTexts || (len(cur)+1)*lens[i] > budget) {
+			batches = append(batches, cur)
+			cur = nil
 		}
+		cur = append(cur, i)
 	}
-	if debug {
-		fmt.Fprintf(os.Stderr, "[debug] tokenize(%d texts, maxLen=%d):   %v\n", batchSize, maxLen, time.Since(t0))
+	if len(cur) > 0 {
+		batches = append(batches, cur)
+	}
+	return batches
+}
+
+// embedBatches runs infer on each batch of encs and returns the vectors
+// in the order of encs.
+func embedBatches(encs []encoded, batches [][]int, infer func([]encoded) ([][]float32, error)) ([][]float32, error) {
+	vecs := make([][]float32, len(encs))
+	for _, b := range batches {
+		batch := make([]encoded, len(b))
+		for j, i := range b {
+			batch[j] = encs[i]
+		}
+		out, err := infer(batch)
+		if err != nil {
+			return nil, fmt.Errorf("batch of %d texts: %w", len(b), err)
+		}
+		for j, i := range b {
+			vecs[i] = out[j]
+		}
 	}
+	return vecs, nil
+}
 
+// infer runs a single ONNX inference call for a batch of encoded texts,
+// padding each to the longest, on a session taken at priority p, and
+// returns their embeddings.
+// Per-phase timings are logged at debug level (e.g. SIFT_DEBUG=1).
+func (e *Embedder) infer(all []encoded, p Priority) ([][]float32, error) {
+	debug := e.log.Enabled(logging.LevelDebug)
+	batchSize := len(all)
+	maxLen := 0
+	for _, enc := range all {
+		maxLen = max(maxLen, len(enc.ids))
+	}
 	if maxLen == 0 {
 		return nil, fmt.Errorf("all texts tokenized to zero length")
 	}
 
-	// ── Phase 2: Build tensors ──────────────────────────────────────────────
+	// ── Phase 2: Build inputs and run ONNX inference ─────────────────────────
 	t1 := time.Now()
-	flatIDs := make([]int64, batchSize*maxLen)
-	flatMask := make([]int64, batchSize*maxLen)
-	flatType := make([]int64, batchSize*maxLen) // all zeros (token_type_ids)
-	for i, enc := range all {
-		copy(flatIDs[i*maxLen:], enc.ids)
-		copy(flatMask[i*maxLen:], enc.mask)
+	in := batchInput{
+		ids:    make([]int64, batchSize*maxLen),
+		mask:   make([]int64, batchSize*maxLen),
+		types:  make([]int64, batchSize*maxLen), // all zeros (token_type_ids)
+		texts:  batchSize,
+		seqLen: maxLen,
 	}
-	shape := ort.NewShape(int64(batchSize), int64(maxLen))
-
-	inputIDs, err := ort.NewTensor(shape, flatIDs)
-	if err != nil {
-		return nil, fmt.Errorf("input_ids tensor: %w", err)
-	}
-	defer inputIDs.Destroy()
-
-	attnMask, err := ort.NewTensor(shape, flatMask)
-	if err != nil {
-		return nil, fmt.Errorf("attention_mask tensor: %w", err)
+	for i, enc := range all {
+		copy(in.ids[i*maxLen:], enc.ids)
+		copy(in.mask[i*maxLen:], enc.mask)
 	}
-	defer attnMask.Destroy()
-
-	typeIDs, err := ort.NewTensor(shape, flatType)
+	hidden, err := e.sessions.run(p, in)
 	if err != nil {
-		return nil, fmt.Errorf("token_type_ids tensor: %w", err)
+		return nil, err
 	}
-	defer typeIDs.Destroy()
 	if debug {
-		fmt.Fprintf(os.Stderr, "[debug] build tensors:                   %v\n", time.Since(t1))
-	}
-
-	// ── Phase 3: ONNX inference ─────────────────────────────────────────────
-	t2 := time.Now()
-	inputs := []ort.Value{inputIDs, attnMask, typeIDs}
-	outputs := []ort.Value{nil}
-	if err := e.session.Run(inputs, outputs); err != nil {
-		return nil, fmt.Errorf("ort run: %w", err)
+		e.log.Debugf("[debug] session.Run (batch=%d, seq=%d): %v", batchSize, maxLen, time.Since(t1))
 	}
-	defer func() {
-		if outputs[0] != nil {
-			outputs[0].Destroy()
-		}
-	}()
-	if debug {
-		fmt.Fprintf(os.Stderr, "[debug] session.Run (batch=%d, seq=%d): %v\n", batchSize, maxLen, time.Since(t2))
+	if e.phase != nil {
+		e.phase(PhaseInference, time.Since(t1))
 	}
 
-	// ── Phase 4: CLS pool + L2 normalize ────────────────────────────────────
+	// ── Phase 3: CLS pool + L2 normalize ────────────────────────────────────
 	t3 := time.Now()
-	hiddenTensor, ok := outputs[0].(*ort.Tensor[float32])
-	if !ok {
-		return nil, fmt.Errorf("unexpected output type (want *Tensor[float32])")
-	}
-	hidden := hiddenTensor.GetData()
-	seqLen := int(hiddenTensor.GetShape()[1])
-
+	seqLen := maxLen
 	embeddings := make([][]float32, batchSize)
 	for i := 0; i < batchSize; i++ {
 		vec := make([]float32, EmbeddingDim)
@@ -275,8 +579,8 @@ func (e *Embedder) embedBatch(texts []string) ([][]float32, error) {
 		embeddings[i] = vec
 	}
 	if debug {
-		fmt.Fprintf(os.Stderr, "[debug] CLS pool + normalize:            %v  (total: %v)\n",
-			time.Since(t3), time.Since(t0))
+		e.log.Debugf("[debug] CLS pool + normalize:            %v  (total: %v)",
+			time.Since(t3), time.Since(t1))
 	}
 
 	return embeddings, nil
@@ -286,50 +590,80 @@ func (e *Embedder) embedBatch(texts []string) ([][]float32, error) {
 // the sift bench command. Returns (tokenizeMs, inferenceMs, totalMs, error).
 func (e *Embedder) BenchmarkSingle(text string) (tokenize, inference, total time.Duration, err error) {
 	t0 := time.Now()
+	e.tokMu.Lock()
 	enc := e.tokenizer.EncodeWithOptions(text, true, tokenizers.WithReturnAttentionMask())
+	e.tokMu.Unlock()
 	ids := enc.IDs
-	if len(ids) > maxSeqLen {
-		ids = ids[:maxSeqLen]
+	if len(ids) > MaxSeqLen {
+		ids = ids[:MaxSeqLen]
 	}
 	tokenize = time.Since(t0)
 
-	ids64 := make([]int64, len(ids))
-	mask64 := make([]int64, len(ids))
-	flatType := make([]int64, len(ids))
+	in := batchInput{
+		ids:    make([]int64, len(ids)),
+		mask:   make([]int64, len(ids)),
+		types:  make([]int64, len(ids)),
+		texts:  1,
+		seqLen: len(ids),
+	}
 	for j, v := range ids {
-		ids64[j] = int64(v)
-		mask64[j] = 1
+		in.ids[j] = int64(v)
+		in.mask[j] = 1
 	}
-	shape := ort.NewShape(1, int64(len(ids)))
-	idsT, e2 := ort.NewTensor(shape, ids64)
-	if e2 != nil {
-		return 0, 0, 0, e2
+
+	t1 := time.Now()
+	if _, err := e.sessions.run(PriorityManual, in); err != nil {
+		return 0, 0, 0, err
+	}
+	inference = time.Since(t1)
+	total = time.Since(t0)
+	return tokenize, inference, total, nil
+}
+
+// ortSession runs the model on an ONNX Runtime session.
+type ortSession struct {
+	s *ort.DynamicAdvancedSession
+}
+
+func (o ortSession) Run(in batchInput) ([]float32, error) {
+	shape := ort.NewShape(int64(in.texts), int64(in.seqLen))
+	inputIDs, err := ort.NewTensor(shape, in.ids)
+	if err != nil {
+		return nil, fmt.Errorf("input_ids tensor: %w", err)
 	}
-	defer idsT.Destroy()
-	maskT, e2 := ort.NewTensor(shape, mask64)
-	if e2 != nil {
-		return 0, 0, 0, e2
+	defer inputIDs.Destroy()
+	attnMask, err := ort.NewTensor(shape, in.mask)
+	if err != nil {
+		return nil, fmt.Errorf("attention_mask tensor: %w", err)
 	}
-	defer maskT.Destroy()
-	typT, e2 := ort.NewTensor(shape, flatType)
-	if e2 != nil {
-		return 0, 0, 0, e2
+	defer attnMask.Destroy()
+	typeIDs, err := ort.NewTensor(shape, in.types)
+	if err != nil {
+		return nil, fmt.Errorf("token_type_ids tensor: %w", err)
 	}
-	defer typT.Destroy()
+	defer typeIDs.Destroy()
 
-	t1 := time.Now()
 	outputs := []ort.Value{nil}
-	if e2 := e.session.Run([]ort.Value{idsT, maskT, typT}, outputs); e2 != nil {
-		return 0, 0, 0, e2
+	if err := o.s.Run([]ort.Value{inputIDs, attnMask, typeIDs}, outputs); err != nil {
+		return nil, fmt.Errorf("ort run: %w", err)
+	}
+	defer func() {
+		if outputs[0] != nil {
+			outputs[0].Destroy()
+		}
+	}()
+	hidden, ok := outputs[0].(*ort.Tensor[float32])
+	if !ok {
+		return nil, fmt.Errorf("unexpected output type (want *Tensor[float32])")
 	}
-	if outputs[0] != nil {
-		outputs[0].Destroy()
+	if got := hidden.GetShape(); len(got) != 3 || got[1] != int64(in.seqLen) || got[2] != EmbeddingDim {
+		return nil, fmt.Errorf("unexpected output shape %v", got)
 	}
-	inference = time.Since(t1)
-	total = time.Since(t0)
-	return tokenize, inference, total, nil
+	return hidden.GetData(), nil
 }
 
+func (o ortSession) Destroy() error { return o.s.Destroy() }
+
 // l2Normalize normalizes v in-place to unit length.
 func l2Normalize(v []float32) {
 	var norm float64
@@ -1,6 +1,10 @@
 package embed
 
 import (
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -70,3 +74,165 @@ func dotProduct(a, b []float32) float32 {
 	}
 	return sum
 }
+
+// mixedLengths returns token lengths like a real index's chunks: mostly
+// short (headings, short paragraphs, code blocks), some full-length.
+func mixedLengths(n int) []int {
+	r := rand.New(rand.NewPCG(1, 2))
+	lens := make([]int, n)
+	for i := range lens {
+		if r.IntN(5) == 0 {
+			lens[i] = 200 + r.IntN(MaxSeqLen-200+1)
+		} else {
+			lens[i] = 20 + r.IntN(60)
+		}
+	}
+	return lens
+}
+
+// fixedBatches is the batching Embed used before lengthBatches: every
+// size texts in input order.
+func fixedBatches(n, size int) [][]int {
+	var batches [][]int
+	for i := 0; i < n; i += size {
+		var b []int
+		for j := i; j < min(i+size, n); j++ {
+			b = append(b, j)
+		}
+		batches = append(batches, b)
+	}
+	return batches
+}
+
+// paddedTokens is the number of tokens inference runs over for batches.
+func paddedTokens(lens []int, batches [][]int) int {
+	total := 0
+	for _, b := range batches {
+		longest := 0
+		for _, i := range b {
+			longest = max(longest, lens[i])
+		}
+		total += len(b) * longest
+	}
+	return total
+}
+
+func TestLengthBatches(t *testing.T) {
+	lens := mixedLengths(500)
+	batches := lengthBatches(lens, batchTokens, maxBatchTexts)
+	seen := make([]bool, len(lens))
+	for _, b := range batches {
+		if len(b) == 0 || len(b) > maxBatchTexts {
+			t.Fatalf("batch of %d texts, want 1 to %d", len(b), maxBatchTexts)
+		}
+		if p := paddedTokens(lens, [][]int{b}); p > batchTokens && len(b) > 1 {
+			t.Errorf("batch %v pads to %d tokens, over the budget of %d", b, p, batchTokens)
+		}
+		for _, i := range b {
+			if seen[i] {
+				t.Fatalf("text %d batched twice", i)
+			}
+			seen[i] = true
+		}
+	}
+	for i, ok := range seen {
+		if !ok {
+			t.Fatalf("text %d not batched", i)
+		}
+	}
+
+	// Fixed batches nearly double the work; length batches add little
+	// padding.
+	unpadded := 0
+	for _, n := range lens {
+		unpadded += n
+	}
+	fixed, bucketed := paddedTokens(lens, fixedBatches(len(lens), 4)), paddedTokens(lens, batches)
+	if bucketed*10 > unpadded*11 || bucketed >= fixed {
+		t.Errorf("%d tokens pad to %d with length batching, %d with fixed batches of 4; want within 10%%, and fewer",
+			unpadded, bucketed, fixed)
+	}
+
+	// A text longer than the budget still gets a batch of its own.
+	if got := lengthBatches([]int{10, 300, 20}, 256, 8); !slices.EqualFunc(got, [][]int{{0, 2}, {1}}, slices.Equal) {
+		t.Errorf("lengthBatches = %v, want [[0 2] [1]]", got)
+	}
+}
+
+func TestEmbedBatchesOrder(t *testing.T) {
+	lens := mixedLengths(50)
+	encs := make([]encoded, len(lens))
+	for i, n := range lens {
+		encs[i] = encoded{ids: make([]int64, n), mask: make([]int64, n)}
+		encs[i].ids[0] = int64(i) // stands in for [CLS], so the output names its input
+	}
+	// infer returns each text's marker as its vector.
+	infer := func(batch []encoded) ([][]float32, error) {
+		out := make([][]float32, len(batch))
+		for j, enc := range batch {
+			out[j] = []float32{float32(enc.ids[0])}
+		}
+		return out, nil
+	}
+	vecs, err := embedBatches(encs, lengthBatches(lens, batchTokens, maxBatchTexts), infer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range vecs {
+		if len(v) != 1 || v[0] != float32(i) {
+			t.Fatalf("vector %d = %v, want the one for text %d", i, v, i)
+		}
+	}
+
+	errInfer := errors.New("ort run: boom")
+	_, err = embedBatches(encs, [][]int{{0, 1}}, func([]encoded) ([][]float32, error) { return nil, errInfer })
+	if !errors.Is(err, errInfer) {
+		t.Errorf("embedBatches = %v, want the inference error", err)
+	}
+}
+
+// BenchmarkEmbedMixed compares fixed batches of four against length
+// batching over chunks of mixed length. It needs the model in ../../models.
+func BenchmarkEmbedMixed(b *testing.B) {
+	e, err := New("../../models", "../../lib/onnxruntime.so", 0)
+	if err != nil {
+		b.Skipf("skipping: model not found at ../../models: %v", err)
+	}
+	defer e.Close()
+
+	words := strings.Fields("the retry policy backs off exponentially while the tunnel " +
+		"reconnects and the garden kettle config lives in a wireguard file")
+	lens := mixedLengths(256)
+	texts := make([]string, len(lens))
+	for i, n := range lens {
+		var sb strings.Builder
+		for j := range n * 3 / 4 { // roughly 4 tokens per 3 words
+			sb.WriteString(words[(i+j)%len(words)])
+			sb.WriteByte(' ')
+		}
+		texts[i] = sb.String()
+	}
+	encs, _ := e.tokenize(texts)
+	tokLens := make([]int, len(encs))
+	for i, enc := range encs {
+		tokLens[i] = len(enc.ids)
+	}
+
+	for _, bc := range []struct {
+		name    string
+		batches [][]int
+	}{
+		{"fixed4", fixedBatches(len(encs), 4)},
+		{"length", lengthBatches(tokLens, batchTokens, maxBatchTexts)},
+	} {
+		infer := func(batch []encoded) ([][]float32, error) { return e.infer(batch, PriorityManual) }
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportMetric(float64(paddedTokens(tokLens, bc.batches)), "padded-tokens/op")
+			for b.Loop() {
+				if _, err := embedBatches(encs, bc.batches, infer); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package embed
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/daulet/tokenizers"
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// RerankerSubdir is where, under the model directory, NewReranker looks
+// for the cross-encoder: model.onnx and tokenizer.json, as `make
+// download-reranker` fetches them.
+const RerankerSubdir = "reranker"
+
+// rerankBatchSize is how many query–passage pairs one inference call
+// scores. Pairs are longer than chunks alone, so it stays small.
+const rerankBatchSize = 8
+
+// ErrRerankerMissing is wrapped by NewReranker's error when the reranker's
+// model.onnx or tokenizer.json is absent.
+var ErrRerankerMissing = errors.New("reranker model missing")
+
+// Reranker scores query–passage pairs with a BERT-style cross-encoder
+// (such as ms-marco-MiniLM-L-6-v2) that reads both texts at once: slower
+// than comparing embeddings, but markedly better at ordering a short
+// candidate list.
+type Reranker struct {
+	session   *ort.DynamicAdvancedSession
+	tokenizer *tokenizers.Tokenizer
+}
+
+// NewReranker loads the cross-encoder from dir (normally
+// <model-dir>/reranker). ortLibPath and numThreads are as for New.
+func NewReranker(dir, ortLibPath string, numThreads int) (*Reranker, error) {
+	modelPath := filepath.Join(dir, "model.onnx")
+	tokenPath := filepath.Join(dir, "tokenizer.json")
+	for _, p := range []string{modelPath, tokenPath} {
+		if _, err := os.Stat(p); err != nil {
+			return nil, fmt.Errorf("%w: %s not found — run `make download-reranker` first", ErrRerankerMissing, p)
+		}
+	}
+	if err := InitRuntime(ortLibPath); err != nil {
+		return nil, err
+	}
+	if numThreads <= 0 {
+		numThreads = min(runtime.NumCPU(), 4)
+	}
+	opts, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, fmt.Errorf("session options: %w", err)
+	}
+	defer opts.Destroy()
+	if err := opts.SetIntraOpNumThreads(numThreads); err != nil {
+		return nil, fmt.Errorf("set intra threads: %w", err)
+	}
+	if err := opts.SetInterOpNumThreads(1); err != nil {
+		return nil, fmt.Errorf("set inter threads: %w", err)
+	}
+
+	session, err := ort.NewDynamicAdvancedSession(modelPath,
+		[]string{"input_ids", "attention_mask", "token_type_ids"}, []string{"logits"}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("create reranker session: %w", err)
+	}
+	tk, err := tokenizers.FromFile(tokenPath)
+	if err != nil {
+		session.Destroy()
+		return nil, fmt.Errorf("load reranker tokenizer: %w", err)
+	}
+	return &Reranker{session: session, tokenizer: tk}, nil
+}
+
+// Close releases the ONNX session and tokenizer.
+func (r *Reranker) Close() {
+	if r.session != nil {
+		r.session.Destroy()
+	}
+	if r.tokenizer != nil {
+		r.tokenizer.Close()
+	}
+}
+
+// Rerank returns a relevance score for query against each passage; higher
+// is more relevant. Scores are the model's raw logits, comparable within
+// one call.
+func (r *Reranker) Rerank(query string, passages []string) ([]float32, error) {
+	scores := make([]float32, 0, len(passages))
+	for i := 0; i < len(passages); i += rerankBatchSize {
+		end := min(i+rerankBatchSize, len(passages))
+		batch, err := r.rerankBatch(query, passages[i:end])
+		if err != nil {
+			return nil, fmt.Errorf("rerank [%d:%d]: %w", i, end, err)
+		}
+		scores = append(scores, batch...)
+	}
+	return scores, nil
+}
+
+// rerankBatch scores one batch of pairs, each encoded as
+// [CLS] query [SEP] passage [SEP] with token types 0 then 1; the passage
+// is cut to fit MaxSeqLen.
+func (r *Reranker) rerankBatch(query string, passages []string) ([]float32, error) {
+	q := r.tokenizer.EncodeWithOptions(query, true).IDs // [CLS] query [SEP]
+	if len(q) > MaxSeqLen/2 {
+		q = append(q[:MaxSeqLen/2-1:MaxSeqLen/2-1], q[len(q)-1])
+	}
+	pairs := make([][]uint32, len(passages))
+	maxLen := 0
+	for i, p := range passages {
+		ids := r.tokenizer.EncodeWithOptions(p, true).IDs[1:] // passage [SEP]
+		if room := MaxSeqLen - len(q); len(ids) > room {
+			ids = append(ids[:room-1:room-1], ids[len(ids)-1])
+		}
+		pairs[i] = append(append([]uint32(nil), q...), ids...)
+		maxLen = max(maxLen, len(pairs[i]))
+	}
+
+	n := len(passages)
+	flatIDs := make([]int64, n*maxLen)
+	flatMask := make([]int64, n*maxLen)
+	flatType := make([]int64, n*maxLen)
+	for i, ids := range pairs {
+		row := i * maxLen
+		for j, id := range ids {
+			flatIDs[row+j] = int64(id)
+			flatMask[row+j] = 1
+			if j >= len(q) {
+				flatType[row+j] = 1
+			}
+		}
+	}
+	shape := ort.NewShape(int64(n), int64(maxLen))
+	inputs := make([]ort.Value, 0, 3)
+	for _, data := range [][]int64{flatIDs, flatMask, flatType} {
+		t, err := ort.NewTensor(shape, data)
+		if err != nil {
+			return nil, fmt.Errorf("input tensor: %w", err)
+		}
+		defer t.Destroy()
+		inputs = append(inputs, t)
+	}
+
+	outputs := []ort.Value{nil}
+	if err := r.session.Run(inputs, outputs); err != nil {
+		return nil, fmt.Errorf("ort run: %w", err)
+	}
+	defer outputs[0].Destroy()
+	logits, ok := outputs[0].(*ort.Tensor[float32])
+	if !ok {
+		return nil, fmt.Errorf("unexpected output type (want *Tensor[float32])")
+	}
+	// logits is [n, 1] for a relevance head; take the last column in case
+	// the head has more than one.
+	data := logits.GetData()
+	width := len(data) / n
+	scores := make([]float32, n)
+	for i := range scores {
+		scores[i] = data[i*width+width-1]
+	}
+	return scores, nil
+}
@@ -0,0 +1,276 @@
+package embed
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/daulet/tokenizers"
+)
+
+// An Embedder is safe for concurrent use: serve and the TUI embed queries
+// while a watcher embeds chunks. Inference runs on one of a fixed set of
+// ONNX sessions, checked out for a single batch at a time. With one
+// session (the default) batches are serialized; with several, as many
+// batches run in parallel, each session with its own intra-op threads and
+// its own copy of the model's working memory. The tokenizer is shared,
+// and calls into it are serialized (tokenizing is a small fraction of
+// inference).
+//
+// A caller that finds every session busy waits its turn, by priority:
+// a session freed goes to the longest-waiting batch of the most urgent
+// priority, interactive queries before manual indexing before background
+// indexing. A batch runs to its end, but work of many batches asks for a
+// session again before each, so a query waits for at most a batch per
+// session however much indexing is queued. Background batches hold at
+// most SetBackgroundSessions sessions at once, leaving the rest for
+// queries and manual indexing.
+
+// Priority orders the batches waiting for an inference session.
+type Priority int
+
+const (
+	// PriorityInteractive is that of search queries, someone waiting on
+	// them: EmbedQuery and EmbedQueries.
+	PriorityInteractive Priority = iota
+	// PriorityManual is that of indexing a command was run for, and of
+	// Embed and EmbedTruncating.
+	PriorityManual
+	// PriorityBackground is that of indexing no one is waiting on, as
+	// the watcher's.
+	PriorityBackground
+
+	numPriorities = iota
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityManual:
+		return "manual"
+	case PriorityBackground:
+		return "background"
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+// QueueDepth counts batches waiting for an inference session, by
+// priority.
+type QueueDepth struct {
+	Interactive, Manual, Background int
+}
+
+func (q *QueueDepth) at(p Priority) *int {
+	switch p {
+	case PriorityInteractive:
+		return &q.Interactive
+	case PriorityManual:
+		return &q.Manual
+	}
+	return &q.Background
+}
+
+// Total is the number of batches waiting, whatever their priority.
+func (q QueueDepth) Total() int {
+	return q.Interactive + q.Manual + q.Background
+}
+
+// DefaultSessions is how many inference sessions New creates.
+const DefaultSessions = 1
+
+// session runs the model; ortSession is the real one, and tests replace
+// it.
+type session interface {
+	// Run returns last_hidden_state, flattened, for in.
+	Run(in batchInput) ([]float32, error)
+	Destroy() error
+}
+
+// batchInput is one inference batch: the model's three inputs, each
+// texts × seqLen tokens, flattened.
+type batchInput struct {
+	ids, mask, types []int64
+	texts, seqLen    int
+}
+
+// encoder is the part of a tokenizer an Embedder uses; tests replace it.
+type encoder interface {
+	EncodeWithOptions(str string, addSpecialTokens bool, opts ...tokenizers.EncodeOption) tokenizers.Encoding
+	Close() error
+}
+
+// SessionStats reports how an Embedder's inference sessions were shared.
+type SessionStats struct {
+	// Sessions is how many inference sessions the Embedder has, and
+	// BackgroundSessions how many of them background batches may hold.
+	Sessions, BackgroundSessions int
+	// Runs counts inference batches, and Waited those that found every
+	// session busy.
+	Runs, Waited int64
+	// Wait is the time batches spent waiting for a session in all, and
+	// MaxWait the longest one batch waited. MaxInteractiveWait is the
+	// longest a query's batch waited.
+	Wait, MaxWait, MaxInteractiveWait time.Duration
+	// Waiting is how many batches are waiting for a session now; Queued
+	// breaks them down by priority, and MaxQueued is the most that were
+	// ever waiting at once.
+	Waiting   int
+	Queued    QueueDepth
+	MaxQueued int
+}
+
+// sessionPool hands out sessions by priority, and first come, first
+// served within one.
+type sessionPool struct {
+	all []session
+
+	mu         sync.Mutex
+	free       []session
+	waiters    [numPriorities][]chan session // FIFO per priority
+	background int                           // sessions held by background batches
+	stats      SessionStats
+}
+
+func newSessionPool(sessions []session) *sessionPool {
+	p := &sessionPool{all: sessions, free: slices.Clone(sessions)}
+	p.stats.Sessions = len(sessions)
+	p.stats.BackgroundSessions = len(sessions)
+	return p
+}
+
+// setBackground caps the sessions background batches hold at once at n,
+// between 1 and all of them.
+func (p *sessionPool) setBackground(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stats.BackgroundSessions = min(max(n, 1), len(p.all))
+	p.dispatchUnderLock()
+}
+
+// admitsUnderLock reports whether a batch of priority prio may take a
+// free session now. Must be called with p.mu held.
+func (p *sessionPool) admitsUnderLock(prio Priority) bool {
+	return len(p.free) > 0 && (prio != PriorityBackground || p.background < p.stats.BackgroundSessions)
+}
+
+// takeUnderLock checks out a free session for a batch of priority prio.
+// Must be called with p.mu held.
+func (p *sessionPool) takeUnderLock(prio Priority) session {
+	s := p.free[len(p.free)-1]
+	p.free = p.free[:len(p.free)-1]
+	if prio == PriorityBackground {
+		p.background++
+	}
+	return s
+}
+
+// acquire checks out a session for a batch of priority prio, waiting
+// for one if all are in use, or if too many are held by background
+// batches and prio is background. It must be returned with release.
+func (p *sessionPool) acquire(prio Priority) session {
+	p.mu.Lock()
+	p.stats.Runs++
+	if len(p.waiters[prio]) == 0 && p.admitsUnderLock(prio) {
+		s := p.takeUnderLock(prio)
+		p.mu.Unlock()
+		return s
+	}
+	ch := make(chan session, 1)
+	p.waiters[prio] = append(p.waiters[prio], ch)
+	p.stats.Waiting++
+	*p.stats.Queued.at(prio)++
+	p.stats.MaxQueued = max(p.stats.MaxQueued, p.stats.Waiting)
+	p.mu.Unlock()
+
+	t0 := time.Now()
+	s := <-ch
+	wait := time.Since(t0)
+	p.mu.Lock()
+	p.stats.Waited++
+	p.stats.Wait += wait
+	p.stats.MaxWait = max(p.stats.MaxWait, wait)
+	if prio == PriorityInteractive {
+		p.stats.MaxInteractiveWait = max(p.stats.MaxInteractiveWait, wait)
+	}
+	p.mu.Unlock()
+	return s
+}
+
+// release returns s, checked out for a batch of priority prio. A
+// session replaced meanwhile is destroyed instead.
+func (p *sessionPool) release(prio Priority, s session) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if prio == PriorityBackground {
+		p.background--
+	}
+	if slices.Contains(p.all, s) {
+		p.free = append(p.free, s)
+	} else {
+		s.Destroy()
+	}
+	p.dispatchUnderLock()
+}
+
+// replace swaps p's sessions for sessions, as many: the free ones are
+// destroyed now, those checked out when they are released.
+func (p *sessionPool) replace(sessions []session) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.free {
+		s.Destroy()
+	}
+	p.all = sessions
+	p.free = slices.Clone(sessions)
+	p.dispatchUnderLock()
+}
+
+// dispatchUnderLock hands free sessions to the waiting batches that may
+// take them, most urgent first. Must be called with p.mu held.
+func (p *sessionPool) dispatchUnderLock() {
+	for prio := range Priority(numPriorities) {
+		for len(p.waiters[prio]) > 0 && p.admitsUnderLock(prio) {
+			ch := p.waiters[prio][0]
+			p.waiters[prio] = p.waiters[prio][1:]
+			p.stats.Waiting--
+			*p.stats.Queued.at(prio)--
+			ch <- p.takeUnderLock(prio)
+		}
+	}
+}
+
+func (p *sessionPool) Stats() SessionStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// destroy destroys every session; none may be checked out.
+func (p *sessionPool) destroy() {
+	for _, s := range p.all {
+		s.Destroy()
+	}
+}
+
+// run runs inference on a session from the pool, for a batch of
+// priority prio.
+func (p *sessionPool) run(prio Priority, in batchInput) ([]float32, error) {
+	s := p.acquire(prio)
+	defer p.release(prio, s)
+	return s.Run(in)
+}
+
+// Stats reports how e's inference sessions have been shared so far.
+func (e *Embedder) Stats() SessionStats {
+	return e.sessions.Stats()
+}
+
+// SetBackgroundSessions caps how many of e's sessions background batches
+// (PriorityBackground) hold at once, between 1 and all of them, which is
+// the default. It may be called while e is in use: a lowered cap lets
+// the batches running finish, a raised one lets waiting ones start.
+func (e *Embedder) SetBackgroundSessions(n int) {
+	e.sessions.setBackground(n)
+}
@@ -0,0 +1,350 @@
+package embed
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daulet/tokenizers"
+	"github.com/tejas242/sift/internal/logging"
+)
+
+// fakeEncoder tokenizes a text as [CLS] and a token naming the text, and
+// fails the test if it is called concurrently.
+type fakeEncoder struct {
+	t    testing.TB
+	busy atomic.Bool
+}
+
+func (f *fakeEncoder) EncodeWithOptions(str string, _ bool, _ ...tokenizers.EncodeOption) tokenizers.Encoding {
+	if !f.busy.CompareAndSwap(false, true) {
+		f.t.Error("tokenizer called concurrently")
+	}
+	defer f.busy.Store(false)
+	return tokenizers.Encoding{IDs: []uint32{101, textToken(str)}, AttentionMask: []uint32{1, 1}}
+}
+
+func (f *fakeEncoder) Close() error { return nil }
+
+// textToken is the token fakeEncoder gives text, and the dimension
+// fakeSession sets in its vector.
+func textToken(text string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(text))
+	return h.Sum32() % EmbeddingDim
+}
+
+// fakeSession takes delay per batch. The [CLS] output of each text is the
+// unit vector along its second token, so results name their inputs. It
+// fails the test if it is run concurrently.
+type fakeSession struct {
+	t         testing.TB
+	delay     time.Duration
+	busy      atomic.Bool
+	destroyed atomic.Bool
+}
+
+func (f *fakeSession) Run(in batchInput) ([]float32, error) {
+	if !f.busy.CompareAndSwap(false, true) {
+		f.t.Error("session run concurrently")
+	}
+	defer f.busy.Store(false)
+	if f.destroyed.Load() {
+		f.t.Error("destroyed session run")
+	}
+	time.Sleep(f.delay)
+	hidden := make([]float32, in.texts*in.seqLen*EmbeddingDim)
+	for i := range in.texts {
+		hidden[i*in.seqLen*EmbeddingDim+int(in.ids[i*in.seqLen+1])] = 1
+	}
+	return hidden, nil
+}
+
+func (f *fakeSession) Destroy() error {
+	f.destroyed.Store(true)
+	return nil
+}
+
+// fakeEmbedder returns an Embedder with n fake sessions.
+func fakeEmbedder(t testing.TB, n int, delay time.Duration) *Embedder {
+	sessions := make([]session, n)
+	for i := range sessions {
+		sessions[i] = &fakeSession{t: t, delay: delay}
+	}
+	return &Embedder{sessions: newSessionPool(sessions), tokenizer: &fakeEncoder{t: t}, log: logging.Discard()}
+}
+
+func checkVec(t *testing.T, text string, vec []float32) {
+	t.Helper()
+	if len(vec) != EmbeddingDim || vec[textToken(text)] != 1 {
+		t.Errorf("vector for %q is not its own", text)
+	}
+}
+
+// TestEmbedder_Concurrent hammers Embed and EmbedQuery from several
+// goroutines; run it with -race.
+func TestEmbedder_Concurrent(t *testing.T) {
+	const workers, rounds = 8, 40
+	for _, n := range []int{1, 3} {
+		t.Run(fmt.Sprintf("sessions=%d", n), func(t *testing.T) {
+			e := fakeEmbedder(t, n, 50*time.Microsecond)
+			var wg sync.WaitGroup
+			for w := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for r := range rounds {
+						q := fmt.Sprintf("query %d/%d", w, r)
+						vec, err := e.EmbedQuery(q)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						checkVec(t, q, vec)
+
+						docs := []string{fmt.Sprintf("doc %d/%d a", w, r), fmt.Sprintf("doc %d/%d b", w, r)}
+						vecs, err := e.Embed(docs)
+						if err != nil {
+							t.Error(err)
+							return
+						}
+						for i, d := range docs {
+							checkVec(t, d, vecs[i])
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			st := e.Stats()
+			// Two texts of one length share a batch: one per Embed call.
+			if st.Sessions != n || st.Runs != 2*workers*rounds {
+				t.Errorf("stats %+v, want %d sessions and %d runs", st, n, 2*workers*rounds)
+			}
+			if st.Waited == 0 || st.Wait < st.MaxWait || st.MaxWait <= 0 {
+				t.Errorf("stats %+v, want some waits, none longer than their total", st)
+			}
+			e.Close()
+		})
+	}
+}
+
+// TestSessionPool_FIFO checks that callers waiting for a session get it
+// in the order they asked.
+func TestSessionPool_FIFO(t *testing.T) {
+	p := newSessionPool([]session{&fakeSession{t: t}})
+	held := p.acquire(PriorityManual)
+	var order []int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := p.acquire(PriorityManual)
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			p.release(PriorityManual, s)
+		}()
+		// Let i queue up before starting the next.
+		for p.Stats().Waiting != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	p.release(PriorityManual, held)
+	wg.Wait()
+	if len(order) != 5 {
+		t.Fatalf("%d of 5 callers got a session", len(order))
+	}
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("sessions went to %v, want 0 to 4 in order", order)
+		}
+	}
+}
+
+// TestSessionPool_Priority checks that a freed session goes to the most
+// urgent waiter, and to the first come among equals.
+func TestSessionPool_Priority(t *testing.T) {
+	p := newSessionPool([]session{&fakeSession{t: t}})
+	held := p.acquire(PriorityManual)
+	prios := []Priority{PriorityBackground, PriorityManual, PriorityInteractive, PriorityBackground, PriorityInteractive}
+	var order []int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i, prio := range prios {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := p.acquire(prio)
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			p.release(prio, s)
+		}()
+		for p.Stats().Waiting != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	if q := p.Stats().Queued; q != (QueueDepth{Interactive: 2, Manual: 1, Background: 2}) {
+		t.Errorf("queued %+v, want 2 interactive, 1 manual and 2 background", q)
+	}
+	p.release(PriorityManual, held)
+	wg.Wait()
+	if want := []int{2, 4, 1, 0, 3}; !slices.Equal(order, want) {
+		t.Errorf("sessions went to %v, want %v", order, want)
+	}
+	if st := p.Stats(); st.Waiting != 0 || st.Queued.Total() != 0 || st.MaxQueued != 5 {
+		t.Errorf("stats after %+v, want nothing queued and 5 at most", st)
+	}
+}
+
+// TestSessionPool_BackgroundCap checks that background batches leave the
+// sessions past their cap to the others.
+func TestSessionPool_BackgroundCap(t *testing.T) {
+	p := newSessionPool([]session{&fakeSession{t: t}, &fakeSession{t: t}})
+	p.setBackground(1)
+	bg := p.acquire(PriorityBackground)
+	got := make(chan session)
+	go func() { got <- p.acquire(PriorityBackground) }()
+	for p.Stats().Queued.Background != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	// The free session is not the second background batch's to take.
+	q := p.acquire(PriorityInteractive)
+	p.release(PriorityInteractive, q)
+	select {
+	case <-got:
+		t.Fatal("a second background batch took a session past the cap")
+	case <-time.After(10 * time.Millisecond):
+	}
+	p.release(PriorityBackground, bg)
+	p.release(PriorityBackground, <-got)
+	if st := p.Stats(); st.BackgroundSessions != 1 || st.Waited != 1 {
+		t.Errorf("stats %+v, want a cap of 1 and one wait", st)
+	}
+}
+
+// TestEmbedder_QueryAheadOfIndexing embeds background batches from several
+// goroutines on one slow session and checks that a query waits for the
+// batch running at most, not for those queued.
+func TestEmbedder_SetThreads(t *testing.T) {
+	e := fakeEmbedder(t, 2, 0)
+	e.threads = 4
+	var created [][]session
+	e.newSessions = func(threads int) ([]session, error) {
+		sessions := make([]session, 2)
+		for i := range sessions {
+			sessions[i] = &fakeSession{t: t}
+		}
+		created = append(created, sessions)
+		return sessions, nil
+	}
+	old := slices.Clone(e.sessions.all)
+
+	// A batch running through the change finishes on its session, which
+	// is destroyed when it is released; the idle one goes at once.
+	held := e.sessions.acquire(PriorityBackground)
+	if err := e.SetThreads(1); err != nil {
+		t.Fatal(err)
+	}
+	if e.Threads() != 1 || len(created) != 1 {
+		t.Fatalf("threads = %d after %d re-creations, want 1 after 1", e.Threads(), len(created))
+	}
+	idle := old[0]
+	if idle == held {
+		idle = old[1]
+	}
+	if !idle.(*fakeSession).destroyed.Load() || held.(*fakeSession).destroyed.Load() {
+		t.Error("want the idle session destroyed and the held one kept")
+	}
+	e.sessions.release(PriorityBackground, held)
+	if !held.(*fakeSession).destroyed.Load() {
+		t.Error("replaced session not destroyed on release")
+	}
+	if got := len(e.sessions.free); got != 2 {
+		t.Errorf("%d sessions free, want the 2 new ones", got)
+	}
+	if _, err := e.EmbedQuery("after"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same count changes nothing.
+	if err := e.SetThreads(1); err != nil || len(created) != 1 {
+		t.Errorf("SetThreads(1) again: err %v, %d re-creations", err, len(created))
+	}
+}
+
+func TestEmbedder_QueryAheadOfIndexing(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	e := fakeEmbedder(t, 1, delay)
+	defer e.Close()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, _, err := e.EmbedAt([]string{fmt.Sprintf("chunk %d/%d", w, i)}, PriorityBackground); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for e.Stats().Queued.Background < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	vec, err := e.EmbedQuery("tunnel")
+	took := time.Since(start)
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkVec(t, "tunnel", vec)
+	// The batch running, then the query's own; first come, first served
+	// it would have waited for three more.
+	if took >= 3*delay {
+		t.Errorf("query took %v behind queued indexing, want under %v", took, 3*delay)
+	}
+	if st := e.Stats(); st.MaxInteractiveWait >= 2*delay {
+		t.Errorf("query waited %v for a session, want under %v", st.MaxInteractiveWait, 2*delay)
+	}
+}
+
+// BenchmarkEmbedder_Sessions compares one session, serializing batches,
+// with pools of several, under parallel queries. A fake session stands in
+// for ONNX Runtime, taking a fixed time per batch on threads of its own.
+func BenchmarkEmbedder_Sessions(b *testing.B) {
+	for _, n := range []int{1, 2, 4} {
+		b.Run(fmt.Sprintf("sessions=%d", n), func(b *testing.B) {
+			e := fakeEmbedder(b, n, time.Millisecond)
+			defer e.Close()
+			b.SetParallelism(4)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := e.EmbedQuery("how does the tunnel reconnect"); err != nil {
+						b.Error(err)
+					}
+				}
+			})
+			st := e.Stats()
+			b.ReportMetric(float64(st.Wait.Microseconds())/float64(st.Runs), "wait-µs/op")
+		})
+	}
+}
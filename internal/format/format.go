@@ -0,0 +1,96 @@
+// Package format renders sizes, counts, durations and ages for people:
+// "84.2 MB", "120k", "14.2s", "2 hours ago". Output is the same whatever
+// the locale: a point for the decimal, English units, no digit grouping.
+// Machine-readable output (JSON, sift's --raw) does not go through it.
+package format
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// byteUnits are the units Bytes steps through, each 1024 of the last.
+var byteUnits = []string{"KB", "MB", "GB", "TB", "PB", "EB"}
+
+// Bytes formats n bytes in binary units with one decimal: "0 B",
+// "512 B", "1.5 KB", "84.2 MB", "3.0 GB". A negative n keeps its sign.
+func Bytes(n int64) string {
+	if n < 0 {
+		if n == -n { // the smallest int64 has no positive counterpart
+			n++
+		}
+		return "-" + Bytes(-n)
+	}
+	if n < 1024 {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	v, unit := float64(n)/1024, 0
+	// Step up while the value would round to 1024.0 or more.
+	for v >= 1023.95 && unit < len(byteUnits)-1 {
+		v /= 1024
+		unit++
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64) + " " + byteUnits[unit]
+}
+
+// Count abbreviates large counts: 950, 120k, 1.2M.
+func Count(n int) string {
+	switch {
+	case n < 1000:
+		return strconv.Itoa(n)
+	case n < 1_000_000:
+		return strconv.Itoa(n/1000) + "k"
+	default:
+		return strconv.FormatFloat(float64(n)/1e6, 'f', 1, 64) + "M"
+	}
+}
+
+// Duration rounds d to a millisecond below a second and to a tenth of a
+// second above: "120ms", "14.2s", "3m5.1s".
+func Duration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// ageUnits are the units ages are given in, coarsest first; an age is
+// given in the coarsest unit it has one whole of.
+var ageUnits = []struct {
+	d           time.Duration
+	long, short string
+}{
+	{365 * 24 * time.Hour, "year", "y"},
+	{30 * 24 * time.Hour, "month", "mo"},
+	{7 * 24 * time.Hour, "week", "w"},
+	{24 * time.Hour, "day", "d"},
+	{time.Hour, "hour", "h"},
+	{time.Minute, "minute", "m"},
+}
+
+// Ago describes an age in the coarsest whole unit, rounding down: "just
+// now" under a minute (or for a time in the future), "1 minute ago",
+// "59 minutes ago", "2 hours ago", "1 day ago" for a day and a half.
+func Ago(d time.Duration) string {
+	for _, u := range ageUnits {
+		if n := int64(d / u.d); n >= 1 {
+			if n == 1 {
+				return "1 " + u.long + " ago"
+			}
+			return fmt.Sprintf("%d %ss ago", n, u.long)
+		}
+	}
+	return "just now"
+}
+
+// AgoShort is Ago for tight spaces: "just now", "59m ago", "2h ago",
+// "3d ago".
+func AgoShort(d time.Duration) string {
+	for _, u := range ageUnits {
+		if n := int64(d / u.d); n >= 1 {
+			return strconv.FormatInt(n, 10) + u.short + " ago"
+		}
+	}
+	return "just now"
+}
@@ -0,0 +1,94 @@
+package format
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBytes(t *testing.T) {
+	for _, tc := range []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1, "1 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{1024*1024 - 1, "1.0 MB"}, // would round to 1024.0 KB
+		{88_290_918, "84.2 MB"},
+		{3 << 30, "3.0 GB"},
+		{12_345_678_901, "11.5 GB"},
+		{5 << 40, "5.0 TB"},
+		{-2048, "-2.0 KB"},
+		{-1 << 63, "-8.0 EB"},
+	} {
+		if got := Bytes(tc.n); got != tc.want {
+			t.Errorf("Bytes(%d) = %q, want %q", tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestCount(t *testing.T) {
+	for _, tc := range []struct {
+		n    int
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1k"},
+		{120_400, "120k"},
+		{999_999, "999k"},
+		{1_250_000, "1.2M"},
+	} {
+		if got := Count(tc.n); got != tc.want {
+			t.Errorf("Count(%d) = %q, want %q", tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	for _, tc := range []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{1234 * time.Microsecond, "1ms"},
+		{999 * time.Millisecond, "999ms"},
+		{14_240 * time.Millisecond, "14.2s"},
+		{185_130 * time.Millisecond, "3m5.1s"},
+	} {
+		if got := Duration(tc.d); got != tc.want {
+			t.Errorf("Duration(%v) = %q, want %q", tc.d, got, tc.want)
+		}
+	}
+}
+
+func TestAgo(t *testing.T) {
+	const day = 24 * time.Hour
+	for _, tc := range []struct {
+		d           time.Duration
+		long, short string
+	}{
+		{-time.Hour, "just now", "just now"}, // clock skew
+		{0, "just now", "just now"},
+		{59 * time.Second, "just now", "just now"},
+		{time.Minute, "1 minute ago", "1m ago"},
+		{59*time.Minute + 59*time.Second, "59 minutes ago", "59m ago"},
+		{time.Hour, "1 hour ago", "1h ago"},
+		{2 * time.Hour, "2 hours ago", "2h ago"},
+		{day - time.Second, "23 hours ago", "23h ago"},
+		{day + day/2, "1 day ago", "1d ago"},
+		{3 * day, "3 days ago", "3d ago"},
+		{13 * day, "1 week ago", "1w ago"},
+		{45 * day, "1 month ago", "1mo ago"},
+		{800 * day, "2 years ago", "2y ago"},
+	} {
+		if got := Ago(tc.d); got != tc.long {
+			t.Errorf("Ago(%v) = %q, want %q", tc.d, got, tc.long)
+		}
+		if got := AgoShort(tc.d); got != tc.short {
+			t.Errorf("AgoShort(%v) = %q, want %q", tc.d, got, tc.short)
+		}
+	}
+}
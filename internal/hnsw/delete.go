@@ -0,0 +1,139 @@
+package hnsw
+
+// Deleting a node only marks it: searches walk through it as before but
+// never return it, and new nodes do not link to it, so its IDs and those
+// of every other node stay put. Compact then drops the marked nodes for
+// good, renumbering the rest, without inserting anything again: each
+// node that linked to a dropped one takes that node's neighbours instead.
+
+// Delete marks node id deleted. IDs not in the graph are ignored.
+func (g *Graph) Delete(id uint32) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if int(id) >= len(g.nodes) || g.deletedLocked(id) {
+		return
+	}
+	if words := (len(g.nodes) + 63) / 64; len(g.deleted) < words {
+		g.deleted = append(g.deleted, make([]uint64, words-len(g.deleted))...)
+	}
+	g.deleted[id/64] |= 1 << (id % 64)
+	g.nDeleted++
+}
+
+// Deleted returns the number of nodes marked deleted and not yet
+// compacted away.
+func (g *Graph) Deleted() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.nDeleted
+}
+
+// deletedLocked reports whether node id is marked deleted. Must be called
+// with g.mu held.
+func (g *Graph) deletedLocked(id uint32) bool {
+	word := int(id / 64)
+	return word < len(g.deleted) && g.deleted[word]&(1<<(id%64)) != 0
+}
+
+// Compact drops the nodes marked deleted, renumbering the others in
+// order: node i becomes node i minus the deleted nodes before it. It
+// fails, changing nothing, only if a lazily loaded graph cannot read its
+// vectors.
+func (g *Graph) Compact() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.nDeleted == 0 {
+		return nil
+	}
+	if err := g.materializeLocked(); err != nil {
+		return err
+	}
+
+	newID := make([]uint32, len(g.nodes))
+	live := uint32(0)
+	for id := range g.nodes {
+		if !g.deletedLocked(uint32(id)) {
+			newID[id] = live
+			live++
+		}
+	}
+
+	// Relink before renumbering, while IDs still index g.nodes.
+	for id := range g.nodes {
+		if g.deletedLocked(uint32(id)) {
+			continue
+		}
+		for lc, nbs := range g.nodes[id].neighbors {
+			g.nodes[id].neighbors[lc] = g.relinkLocked(uint32(id), nbs, lc)
+		}
+	}
+
+	nodes := make([]node, 0, live)
+	entry, top := uint32(0), -1
+	for id, n := range g.nodes {
+		if g.deletedLocked(uint32(id)) {
+			continue
+		}
+		for _, nbs := range n.neighbors {
+			for i, nb := range nbs {
+				nbs[i] = newID[nb]
+			}
+		}
+		if len(n.neighbors)-1 > top {
+			entry, top = newID[id], len(n.neighbors)-1
+		}
+		nodes = append(nodes, n)
+	}
+	if !g.deletedLocked(g.entryPoint) {
+		entry, top = newID[g.entryPoint], g.maxLayer
+	}
+	g.nodes = nodes
+	g.entryPoint, g.maxLayer = entry, max(top, 0)
+	g.deleted, g.nDeleted = nil, 0
+	return nil
+}
+
+// relinkLocked returns the neighbours at layer lc of node id, nbs,
+// without the deleted ones: those are replaced by their own live
+// neighbours, keeping the closest if that makes too many. Must be called
+// with g.mu held for writing.
+func (g *Graph) relinkLocked(id uint32, nbs []uint32, lc int) []uint32 {
+	hasDeleted := false
+	for _, nb := range nbs {
+		if g.deletedLocked(nb) {
+			hasDeleted = true
+			break
+		}
+	}
+	if !hasDeleted {
+		return nbs
+	}
+	seen := map[uint32]bool{id: true}
+	out := make([]uint32, 0, len(nbs))
+	add := func(nb uint32) {
+		if !seen[nb] && !g.deletedLocked(nb) {
+			seen[nb] = true
+			out = append(out, nb)
+		}
+	}
+	for _, nb := range nbs {
+		if !g.deletedLocked(nb) {
+			add(nb)
+			continue
+		}
+		seen[nb] = true
+		if lc < len(g.nodes[nb].neighbors) {
+			for _, nn := range g.nodes[nb].neighbors[lc] {
+				add(nn)
+			}
+		}
+	}
+	maxConn := g.m
+	if lc == 0 {
+		maxConn = 2 * g.m
+	}
+	if len(out) > maxConn {
+		out = g.pruneNeighbours(id, out, maxConn)
+	}
+	return out
+}
@@ -11,8 +11,10 @@ package hnsw
 
 import (
 	"container/heap"
+	"context"
 	"math"
 	"math/rand"
+	"runtime"
 	"sort"
 	"sync"
 )
@@ -43,6 +45,7 @@ type node struct {
 type Graph struct {
 	mu             sync.RWMutex
 	nodes          []node
+	store          *vecStore // set by LoadLazy: vectors not in nodes are read from it
 	entryPoint     uint32
 	maxLayer       int
 	m              int // max connections per layer (Mmax0 = 2*m at layer 0)
@@ -50,6 +53,8 @@ type Graph struct {
 	efSearch       int
 	ml             float64 // level generation factor = 1/ln(m)
 	rng            *rand.Rand
+	deleted        []uint64 // bitset of the node IDs marked deleted; see delete.go
+	nDeleted       int
 }
 
 // New creates an empty HNSW graph with the given parameters.
@@ -72,7 +77,20 @@ func New(m, efConstruction, efSearch int) *Graph {
 	}
 }
 
-// Len returns the number of nodes in the graph.
+// Params holds the construction parameters of a graph.
+type Params struct {
+	M              int
+	EfConstruction int
+	EfSearch       int
+}
+
+// Params returns the parameters the graph was built with.
+func (g *Graph) Params() Params {
+	return Params{M: g.m, EfConstruction: g.efConstruction, EfSearch: g.efSearch}
+}
+
+// Len returns the number of nodes in the graph, counting those marked
+// deleted until Compact drops them.
 func (g *Graph) Len() int {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
@@ -86,7 +104,7 @@ func (g *Graph) GetNodeVec(id uint32) []float32 {
 	if int(id) >= len(g.nodes) {
 		return nil
 	}
-	return g.nodes[id].vec
+	return g.vec(id)
 }
 
 // randomLevel draws a random level for a new node using the HNSW exponential law.
@@ -124,8 +142,9 @@ func (g *Graph) Insert(vec []float32) {
 
 	g.nodes = append(g.nodes, node{neighbors: neighbors, vec: vec})
 
-	if id == 0 {
-		g.entryPoint = 0
+	if int(id) == g.nDeleted {
+		// The first node, or the first not marked deleted: nothing to link.
+		g.entryPoint = id
 		g.maxLayer = level
 		return
 	}
@@ -139,8 +158,9 @@ func (g *Graph) Insert(vec []float32) {
 	}
 
 	// Insert into layers [min(level,epLevel) down to 0].
+	var s scratch
 	for lc := min(level, epLevel); lc >= 0; lc-- {
-		candidates := g.searchLayer(vec, ep, g.efConstruction, lc)
+		candidates := g.searchLayer(vec, ep, g.efConstruction, lc, &s, nil)
 		selected := g.selectNeighbours(candidates, g.m)
 
 		// Connect new node to selected neighbours.
@@ -170,13 +190,91 @@ func (g *Graph) Insert(vec []float32) {
 	}
 }
 
-// Search returns the k nearest neighbours to query (must be L2-normalized).
+// Search returns the k nearest neighbours to query (must be L2-normalized),
+// leaving out nodes marked deleted.
 func (g *Graph) Search(query []float32, k int) []Result {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
+	res, _ := g.search(query, k, new(scratch), nil)
+	return res
+}
+
+// SearchContext is Search, abandoned once ctx is done: the beam search
+// checks ctx before expanding each candidate, and then returns ctx's
+// error.
+func (g *Graph) SearchContext(ctx context.Context, query []float32, k int) ([]Result, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	res, stopped := g.search(query, k, new(scratch), ctx.Done())
+	if stopped {
+		return nil, ctx.Err()
+	}
+	return res, nil
+}
+
+// SearchMany returns the k nearest neighbours of each query, in order, as
+// Search would. The queries are spread over parallelism goroutines
+// (GOMAXPROCS when parallelism <= 0), each reusing its search buffers
+// from one query to the next; the graph is read-locked for the whole
+// batch, so every query sees the same nodes.
+func (g *Graph) SearchMany(queries [][]float32, k int, parallelism int) [][]Result {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	out := make([][]Result, len(queries))
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	parallelism = min(parallelism, len(queries))
+	if parallelism <= 1 {
+		s := new(scratch)
+		for i, q := range queries {
+			out[i], _ = g.search(q, k, s, nil)
+		}
+		return out
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range parallelism {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := new(scratch)
+			for i := range next {
+				out[i], _ = g.search(queries[i], k, s, nil)
+			}
+		}()
+	}
+	for i := range queries {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return out
+}
+
+// Score returns the similarity of query (L2-normalized) to each of the
+// nodes ids, best first: an exact search of those nodes alone, with no
+// graph traversal. IDs not in the graph, or marked deleted, are left out.
+func (g *Graph) Score(query []float32, ids []uint32) []Result {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	out := make([]Result, 0, len(ids))
+	for _, id := range ids {
+		if int(id) < len(g.nodes) && !g.deletedLocked(id) {
+			out = append(out, Result{ID: id, Score: sim(query, g.vec(id))})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out
+}
 
+// search is Search with g.mu held for reading and s as its buffers. It
+// stops early, reporting so, once done is closed (never when it is nil).
+func (g *Graph) search(query []float32, k int, s *scratch, done <-chan struct{}) (res []Result, stopped bool) {
 	if len(g.nodes) == 0 {
-		return nil
+		return nil, false
 	}
 
 	ep := g.entryPoint
@@ -192,7 +290,10 @@ func (g *Graph) Search(query []float32, k int) []Result {
 	if k > ef {
 		ef = k
 	}
-	candidates := g.searchLayer(query, ep, ef, 0)
+	candidates := g.searchLayer(query, ep, ef, 0, s, done)
+	if candidates == nil {
+		return nil, true
+	}
 
 	// Take top-k.
 	if len(candidates) > k {
@@ -203,7 +304,36 @@ func (g *Graph) Search(query []float32, k int) []Result {
 	for i, c := range candidates {
 		results[i] = Result{ID: c.id, Score: c.dist}
 	}
-	return results
+	return results, false
+}
+
+// scratch holds the buffers of searchLayer, kept between calls so a
+// worker answering many queries does not allocate them for each.
+type scratch struct {
+	visited []uint64 // bitset of the node IDs seen
+	c       maxHeap
+	w       []candidate
+}
+
+// reset clears s for a search of a graph of n nodes.
+func (s *scratch) reset(n int) {
+	words := (n + 63) / 64
+	if cap(s.visited) < words {
+		s.visited = make([]uint64, words)
+	} else {
+		s.visited = s.visited[:words]
+		clear(s.visited)
+	}
+	s.c = s.c[:0]
+	s.w = s.w[:0]
+}
+
+// visit marks id seen and reports whether it already was.
+func (s *scratch) visit(id uint32) bool {
+	word, bit := id/64, uint64(1)<<(id%64)
+	seen := s.visited[word]&bit != 0
+	s.visited[word] |= bit
+	return seen
 }
 
 // candidate is a (id, similarity) pair used in priority queues.
@@ -245,14 +375,14 @@ func (h *minHeap) Pop() interface{} {
 // greedySearchLayer navigates layer lc from ep to find the single closest node.
 func (g *Graph) greedySearchLayer(query []float32, ep uint32, lc int) uint32 {
 	best := ep
-	bestSim := sim(query, g.nodes[ep].vec)
+	bestSim := sim(query, g.vec(ep))
 
 	changed := true
 	for changed {
 		changed = false
 		if lc < len(g.nodes[best].neighbors) {
 			for _, nb := range g.nodes[best].neighbors[lc] {
-				s := sim(query, g.nodes[nb].vec)
+				s := sim(query, g.vec(nb))
 				if s > bestSim {
 					bestSim = s
 					best = nb
@@ -264,28 +394,42 @@ func (g *Graph) greedySearchLayer(query []float32, ep uint32, lc int) uint32 {
 	return best
 }
 
+// expanding, when set by tests, is called before searchLayer expands each
+// candidate.
+var expanding func()
+
 // searchLayer performs the full ef-based beam search at layer lc.
-// Returns candidates sorted descending by similarity (index 0 = best).
+// Returns candidates sorted descending by similarity (index 0 = best),
+// in s's buffer: valid until s is used again; nil once done is closed.
+// Nodes marked deleted are explored but not returned.
 //
 // Algorithm: maintain C (candidates to explore, max-heap) and W (best results, max-heap).
 // Always expand the most promising candidate from C. Stop when the best
 // unexplored candidate is worse than the worst element in W and W is full.
-func (g *Graph) searchLayer(query []float32, ep uint32, ef, lc int) []candidate {
-	visited := make([]bool, len(g.nodes))
-	visited[ep] = true
+func (g *Graph) searchLayer(query []float32, ep uint32, ef, lc int, s *scratch, done <-chan struct{}) []candidate {
+	s.reset(len(g.nodes))
+	s.visit(ep)
 
-	epSim := sim(query, g.nodes[ep].vec)
+	epSim := sim(query, g.vec(ep))
 
 	// C = candidates to explore, max-heap (best unexplored first).
-	C := &maxHeap{{id: ep, dist: epSim}}
+	C := &s.c
+	*C = append(*C, candidate{id: ep, dist: epSim})
 	heap.Init(C)
 
 	// W = result set, max-heap bounded to ef elements.
 	// We track the worst (minimum) similarity in W separately for O(1) access.
-	W := []candidate{{id: ep, dist: epSim}}
-	worstSim := epSim
+	W := s.w
+	worstSim := float32(math.Inf(-1))
+	if !g.deletedLocked(ep) {
+		W = append(W, candidate{id: ep, dist: epSim})
+		worstSim = epSim
+	}
 
 	minSimInW := func() float32 {
+		if len(W) == 0 {
+			return float32(math.Inf(-1))
+		}
 		m := W[0].dist
 		for _, c := range W[1:] {
 			if c.dist < m {
@@ -304,18 +448,29 @@ func (g *Graph) searchLayer(query []float32, ep uint32, ef, lc int) []candidate
 		if len(W) >= ef && c.dist < worstSim {
 			break
 		}
+		select {
+		case <-done:
+			s.w = W
+			return nil
+		default:
+		}
+		if expanding != nil {
+			expanding()
+		}
 
 		if lc < len(g.nodes[c.id].neighbors) {
 			for _, nb := range g.nodes[c.id].neighbors[lc] {
-				if visited[nb] {
+				if s.visit(nb) {
 					continue
 				}
-				visited[nb] = true
-				s := sim(query, g.nodes[nb].vec)
+				d := sim(query, g.vec(nb))
 
-				if len(W) < ef || s > worstSim {
-					heap.Push(C, candidate{id: nb, dist: s})
-					W = append(W, candidate{id: nb, dist: s})
+				if len(W) < ef || d > worstSim {
+					heap.Push(C, candidate{id: nb, dist: d})
+					if g.deletedLocked(nb) {
+						continue
+					}
+					W = append(W, candidate{id: nb, dist: d})
 					if len(W) > ef {
 						// Remove the worst element from W (linear scan — ef ≤ 200).
 						minIdx := 0
@@ -335,6 +490,7 @@ func (g *Graph) searchLayer(query []float32, ep uint32, ef, lc int) []candidate
 
 	// Sort W descending by similarity.
 	sort.Slice(W, func(i, j int) bool { return W[i].dist > W[j].dist })
+	s.w = W
 	return W
 }
 
@@ -363,7 +519,7 @@ func (g *Graph) pruneNeighbours(id uint32, nbs []uint32, maxConn int) []uint32 {
 	}
 	scored := make([]nb, len(nbs))
 	for i, n := range nbs {
-		scored[i] = nb{id: n, dist: sim(g.nodes[id].vec, g.nodes[n].vec)}
+		scored[i] = nb{id: n, dist: sim(g.vec(id), g.vec(n))}
 	}
 	// Sort descending by similarity.
 	sort.Slice(scored, func(i, j int) bool { return scored[i].dist > scored[j].dist })
@@ -1,12 +1,18 @@
 package hnsw
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"sort"
 	"testing"
+	"time"
 )
 
 // randomVec generates a random unit vector of dimension d.
@@ -50,6 +56,125 @@ func TestInsertSearch(t *testing.T) {
 	}
 }
 
+func TestSearchMany(t *testing.T) {
+	const dim = 64
+	rng := rand.New(rand.NewSource(3))
+	g := New(16, 200, 50)
+	for range 500 {
+		g.Insert(randomVec(rng, dim))
+	}
+	queries := make([][]float32, 40)
+	for i := range queries {
+		queries[i] = randomVec(rng, dim)
+	}
+
+	for _, workers := range []int{0, 1, 3, 100} {
+		got := g.SearchMany(queries, 10, workers)
+		if len(got) != len(queries) {
+			t.Fatalf("%d workers: %d result lists for %d queries", workers, len(got), len(queries))
+		}
+		for i, q := range queries {
+			if want := g.Search(q, 10); !slices.Equal(got[i], want) {
+				t.Errorf("%d workers, query %d: %v, want %v as from Search", workers, i, got[i], want)
+			}
+		}
+	}
+	if got := New(16, 200, 50).SearchMany(queries, 10, 4); len(got) != len(queries) || got[0] != nil {
+		t.Errorf("empty graph: %v", got)
+	}
+}
+
+func TestScore(t *testing.T) {
+	const dim = 64
+	rng := rand.New(rand.NewSource(5))
+	g := New(16, 200, 50)
+	vecs := make([][]float32, 100)
+	for i := range vecs {
+		vecs[i] = randomVec(rng, dim)
+		g.Insert(vecs[i])
+	}
+
+	got := g.Score(vecs[7], []uint32{3, 7, 42, 500})
+	if len(got) != 3 || got[0].ID != 7 || got[0].Score < 0.99 {
+		t.Fatalf("Score = %v, want 3 results led by the query's own node", got)
+	}
+	for i, r := range got {
+		if want := sim(vecs[7], vecs[r.ID]); r.Score != want {
+			t.Errorf("node %d scored %v, want %v", r.ID, r.Score, want)
+		}
+		if i > 0 && r.Score > got[i-1].Score {
+			t.Errorf("results not best first: %v", got)
+		}
+	}
+}
+
+func TestSearchContext(t *testing.T) {
+	const dim = 64
+	rng := rand.New(rand.NewSource(7))
+	g := New(16, 200, 50)
+	for range 500 {
+		g.Insert(randomVec(rng, dim))
+	}
+	q := randomVec(rng, dim)
+	if got, err := g.SearchContext(context.Background(), q, 10); err != nil || !slices.Equal(got, g.Search(q, 10)) {
+		t.Errorf("SearchContext = %v, %v; want Search's results", got, err)
+	}
+
+	// Cancelled mid-traversal, the search expands no further candidate.
+	ctx, cancel := context.WithCancel(context.Background())
+	n := 0
+	expanding = func() {
+		if n++; n == 5 {
+			cancel()
+		}
+	}
+	t.Cleanup(func() { expanding = nil })
+	if got, err := g.SearchContext(ctx, q, 200); !errors.Is(err, context.Canceled) || got != nil {
+		t.Errorf("cancelled SearchContext = %v, %v; want context.Canceled", got, err)
+	}
+	if n != 5 {
+		t.Errorf("%d candidates expanded, want the search to stop after the 5th", n)
+	}
+}
+
+// TestSearchManyInsert runs batches against a graph being inserted into;
+// run with -race.
+func TestSearchManyInsert(t *testing.T) {
+	const dim = 32
+	rng := rand.New(rand.NewSource(5))
+	g := New(8, 50, 20)
+	for range 100 {
+		g.Insert(randomVec(rng, dim))
+	}
+	more := make([][]float32, 200)
+	queries := make([][]float32, 16)
+	for i := range more {
+		more[i] = randomVec(rng, dim)
+	}
+	for i := range queries {
+		queries[i] = randomVec(rng, dim)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, v := range more {
+			g.Insert(v)
+		}
+	}()
+	for range 20 {
+		for i, res := range g.SearchMany(queries, 5, 4) {
+			if len(res) != 5 {
+				t.Fatalf("query %d: %d results, want 5", i, len(res))
+			}
+		}
+	}
+	<-done
+	if g.Len() != 300 {
+		t.Errorf("Len = %d, want 300", g.Len())
+	}
+}
+
 func TestPersistRoundTrip(t *testing.T) {
 	const dim = 64
 	rng := rand.New(rand.NewSource(7))
@@ -88,6 +213,136 @@ func TestPersistRoundTrip(t *testing.T) {
 	}
 }
 
+func TestDeleteCompact(t *testing.T) {
+	const dim, n = 32, 400
+	rng := rand.New(rand.NewSource(5))
+	g := New(16, 200, 50)
+	vecs := make([][]float32, n)
+	for i := range vecs {
+		vecs[i] = randomVec(rng, dim)
+		g.Insert(vecs[i])
+	}
+	ep := int(g.entryPoint)
+	gone := func(id int) bool { return id%3 == 0 || id == ep }
+	for id := range n {
+		if gone(id) {
+			g.Delete(uint32(id))
+		}
+	}
+	g.Delete(uint32(n)) // not in the graph: ignored
+	deleted := g.Deleted()
+
+	// Deleted nodes keep their IDs but are never found.
+	if g.Len() != n {
+		t.Errorf("Len = %d after deleting, want %d", g.Len(), n)
+	}
+	for id := range n {
+		for _, r := range g.Search(vecs[id], 10) {
+			if gone(int(r.ID)) {
+				t.Fatalf("search for %d returned deleted node %d", id, r.ID)
+			}
+		}
+	}
+	if r := g.Score(vecs[0], []uint32{0, 1}); len(r) != 1 || r[0].ID != 1 {
+		t.Errorf("Score = %v, want node 1 alone", r)
+	}
+	path := filepath.Join(t.TempDir(), "test.hnsw")
+	if err := g.Save(path); err == nil {
+		t.Error("Save with deleted nodes succeeded")
+	}
+
+	// Compact renumbers the rest in order, and they are still found.
+	if err := g.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	var live [][]float32
+	for id, v := range vecs {
+		if !gone(id) {
+			live = append(live, v)
+		}
+	}
+	if g.Len() != n-deleted || len(live) != g.Len() || g.Deleted() != 0 {
+		t.Fatalf("after Compact: Len = %d, Deleted = %d; want %d, 0", g.Len(), g.Deleted(), len(live))
+	}
+	found := 0
+	for id, v := range live {
+		if !slices.Equal(g.GetNodeVec(uint32(id)), v) {
+			t.Fatalf("node %d has the wrong vector after Compact", id)
+		}
+		if r := g.Search(v, 1); len(r) == 1 && r[0].ID == uint32(id) {
+			found++
+		}
+	}
+	if found < len(live)*95/100 {
+		t.Errorf("after Compact, %d of %d nodes find themselves", found, len(live))
+	}
+	if err := g.Save(path); err != nil {
+		t.Errorf("Save after Compact: %v", err)
+	}
+
+	// With every node deleted, a new one is still found.
+	for id := range g.Len() {
+		g.Delete(uint32(id))
+	}
+	g.Insert(vecs[0])
+	if r := g.Search(vecs[0], 1); len(r) != 1 || r[0].ID != uint32(len(live)) {
+		t.Errorf("search after deleting all and inserting = %v, want node %d", r, len(live))
+	}
+}
+
+// TestLoadLazy checks that a lazily loaded graph, with a cache too small
+// to hold every vector, searches exactly like the fully loaded one, and
+// that inserting into it and saving it keeps every vector.
+func TestLoadLazy(t *testing.T) {
+	const dim, n = 32, 300
+	rng := rand.New(rand.NewSource(11))
+	g := New(16, 200, 50)
+	for i := 0; i < n; i++ {
+		g.Insert(randomVec(rng, dim))
+	}
+	path := filepath.Join(t.TempDir(), "test.hnsw")
+	if err := g.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	lazy, err := LoadLazy(path, 16)
+	if err != nil {
+		t.Fatalf("LoadLazy: %v", err)
+	}
+	defer lazy.Close()
+	if !lazy.Lazy() || lazy.Len() != n {
+		t.Fatalf("Lazy() = %v, Len() = %d; want true, %d", lazy.Lazy(), lazy.Len(), n)
+	}
+	for i := 0; i < 20; i++ {
+		q := randomVec(rng, dim)
+		if want, got := g.Search(q, 10), lazy.Search(q, 10); !slices.Equal(got, want) {
+			t.Fatalf("query %d: lazy results %v, want %v", i, got, want)
+		}
+	}
+	if got := len(lazy.store.cache); got > 16 {
+		t.Errorf("cache holds %d vectors, want at most 16", got)
+	}
+
+	extra := randomVec(rng, dim)
+	lazy.Insert(extra)
+	g.Insert(extra)
+	if err := lazy.Save(path); err != nil {
+		t.Fatalf("Save lazy graph: %v", err)
+	}
+	if lazy.Lazy() {
+		t.Error("graph still lazy after Save")
+	}
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for id := uint32(0); id <= n; id++ {
+		if !slices.Equal(reloaded.GetNodeVec(id), g.GetNodeVec(id)) {
+			t.Fatalf("vector %d differs after saving the lazy graph", id)
+		}
+	}
+}
+
 // BenchmarkRecall10 measures recall@10 of HNSW vs brute force on 1000 vectors.
 func BenchmarkRecall10(b *testing.B) {
 	const (
@@ -192,3 +447,92 @@ func BenchmarkHNSWSearch(b *testing.B) {
 		_ = g.Search(queries[i], 10)
 	}
 }
+
+// BenchmarkSearchMany answers 64 queries over a 100k-node graph with
+// 1 to 8 workers; ns/op should fall near-linearly up to 4 on as many
+// cores. Short vectors and a smaller build pool keep the graph quick to
+// build; neither changes how searches share the cores.
+func BenchmarkSearchMany(b *testing.B) {
+	const (
+		dim    = 128
+		nIndex = 100_000
+	)
+	rng := rand.New(rand.NewSource(400))
+	g := New(16, 100, 50)
+	for range nIndex {
+		g.Insert(randomVec(rng, dim))
+	}
+	queries := make([][]float32, 64)
+	for i := range queries {
+		queries[i] = randomVec(rng, dim)
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for range b.N {
+				g.SearchMany(queries, 10, workers)
+			}
+		})
+	}
+}
+
+// BenchmarkSearchMemory compares a fully loaded graph with a lazily loaded
+// one: heap held by the loaded graph and p50 search latency.
+func BenchmarkSearchMemory(b *testing.B) {
+	const (
+		dim    = 384
+		nIndex = 10000
+	)
+	rng := rand.New(rand.NewSource(300))
+	g := New(16, 200, 50)
+	for i := 0; i < nIndex; i++ {
+		g.Insert(randomVec(rng, dim))
+	}
+	path := filepath.Join(b.TempDir(), "bench.hnsw")
+	if err := g.Save(path); err != nil {
+		b.Fatal(err)
+	}
+	g = nil
+	queries := make([][]float32, 256)
+	for i := range queries {
+		queries[i] = randomVec(rng, dim)
+	}
+
+	for _, mode := range []struct {
+		name string
+		load func() (*Graph, error)
+	}{
+		{"full", func() (*Graph, error) { return Load(path) }},
+		{"lazy", func() (*Graph, error) { return LoadLazy(path, 0) }},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			before := heapInUse()
+			loaded, err := mode.load()
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer loaded.Close()
+
+			lat := make([]time.Duration, 0, b.N)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				start := time.Now()
+				loaded.Search(queries[i%len(queries)], 10)
+				lat = append(lat, time.Since(start))
+			}
+			b.StopTimer()
+			slices.Sort(lat)
+			b.ReportMetric(float64(lat[len(lat)/2].Microseconds()), "p50-µs")
+			b.ReportMetric(float64(heapInUse()-before)/(1<<20), "heap-MB")
+			runtime.KeepAlive(loaded)
+		})
+	}
+}
+
+// heapInUse returns the live heap after a collection.
+func heapInUse() int64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapInuse)
+}
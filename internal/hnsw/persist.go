@@ -14,7 +14,8 @@ var magic = [4]byte{'S', 'H', 'N', 'W'}
 
 const formatVersion = uint16(1)
 
-// Save serializes the graph to a binary file.
+// Save serializes the graph to a binary file. A graph with nodes marked
+// deleted must be compacted first (see Compact).
 // Format:
 //
 //	[4]byte  magic
@@ -33,8 +34,15 @@ const formatVersion = uint16(1)
 //	uint16   neighborCount
 //	uint32   neighbor[neighborCount]
 func (g *Graph) Save(path string) error {
-	g.mu.RLock()
-	defer g.mu.RUnlock()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.nDeleted > 0 {
+		return fmt.Errorf("hnsw: %d nodes marked deleted; compact before saving", g.nDeleted)
+	}
+	// A lazily loaded graph reads from the file about to be replaced.
+	if err := g.materializeLocked(); err != nil {
+		return err
+	}
 
 	tmpPath := path + ".tmp"
 	f, err := os.Create(tmpPath)
@@ -0,0 +1,234 @@
+package hnsw
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sync"
+)
+
+// DefaultVecCache is the number of vectors LoadLazy keeps in memory by
+// default: about 6 MB of 384-dimensional vectors.
+const DefaultVecCache = 4096
+
+// vecStore reads node vectors from a graph file on demand, keeping the
+// most recently used ones in a small LRU cache, so a graph loaded for
+// searching holds only its links in memory.
+type vecStore struct {
+	f       *os.File
+	offsets []int64  // file offset of each node's vector
+	lens    []uint16 // its length in float32s
+
+	mu    sync.Mutex
+	cap   int
+	lru   *list.List // of *cachedVec, most recent first
+	cache map[uint32]*list.Element
+}
+
+type cachedVec struct {
+	id  uint32
+	vec []float32
+}
+
+// get returns node id's vector, reading it from the file on a cache miss.
+// The file is read without holding the lock, so concurrent searches only
+// contend on the cache itself.
+func (s *vecStore) get(id uint32) []float32 {
+	s.mu.Lock()
+	if e, ok := s.cache[id]; ok {
+		s.lru.MoveToFront(e)
+		s.mu.Unlock()
+		return e.Value.(*cachedVec).vec
+	}
+	s.mu.Unlock()
+
+	buf := make([]byte, 4*int(s.lens[id]))
+	if _, err := s.f.ReadAt(buf, s.offsets[id]); err != nil {
+		// The file was checked when loaded; a failing read now means it
+		// is gone or truncated underneath us. A zero vector scores 0.
+		return make([]float32, s.lens[id])
+	}
+	vec := decodeVec(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.cache[id]; !ok {
+		s.cache[id] = s.lru.PushFront(&cachedVec{id: id, vec: vec})
+		if s.lru.Len() > s.cap {
+			last := s.lru.Remove(s.lru.Back()).(*cachedVec)
+			delete(s.cache, last.id)
+		}
+	}
+	return vec
+}
+
+// decodeVec decodes little-endian float32s.
+func decodeVec(buf []byte) []float32 {
+	vec := make([]float32, len(buf)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return vec
+}
+
+// LoadLazy is Load without reading the vectors: they are read from path
+// when a search or insert first compares against them, and up to
+// cacheSize of them (DefaultVecCache if <= 0) are kept in memory. The
+// file stays open until Close. It trades some search latency for a much
+// smaller heap on large graphs; inserting still works, keeping new
+// vectors in memory.
+func LoadLazy(path string, cacheSize int) (*Graph, error) {
+	if cacheSize <= 0 {
+		cacheSize = DefaultVecCache
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	g, err := loadLinks(f, path, cacheSize)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return g, nil
+}
+
+// loadLinks reads the graph in f, recording where each vector is instead
+// of reading it.
+func loadLinks(f *os.File, path string, cacheSize int) (*Graph, error) {
+	cr := &countingReader{r: bufio.NewReaderSize(f, 1<<16)}
+	r := &binaryReader{r: cr}
+
+	var gotMagic [4]byte
+	r.read(&gotMagic)
+	if gotMagic != magic {
+		return nil, fmt.Errorf("invalid magic bytes in %s — graph may be corrupted", path)
+	}
+	version := r.readU16()
+	if version != formatVersion {
+		return nil, fmt.Errorf("unsupported version %d (expected %d)", version, formatVersion)
+	}
+	nodeCount := r.readU32()
+	entryPoint := r.readU32()
+	maxLayer := int(r.readU8())
+	m := int(r.readU16())
+	efConstruction := int(r.readU16())
+	efSearch := int(r.readU16())
+	if r.err != nil {
+		return nil, fmt.Errorf("read header: %w", r.err)
+	}
+
+	store := &vecStore{
+		f:       f,
+		offsets: make([]int64, nodeCount),
+		lens:    make([]uint16, nodeCount),
+		cap:     cacheSize,
+		lru:     list.New(),
+		cache:   make(map[uint32]*list.Element, cacheSize),
+	}
+	nodes := make([]node, nodeCount)
+	for i := range nodes {
+		layerCount := int(r.readU8())
+		vecLen := r.readU16()
+		store.offsets[i], store.lens[i] = cr.n, vecLen
+		if r.err == nil {
+			_, r.err = io.CopyN(io.Discard, cr, 4*int64(vecLen))
+		}
+		neighbors := make([][]uint32, layerCount)
+		for l := range neighbors {
+			nbCount := int(r.readU16())
+			neighbors[l] = make([]uint32, nbCount)
+			for j := range neighbors[l] {
+				neighbors[l][j] = r.readU32()
+			}
+		}
+		nodes[i] = node{neighbors: neighbors}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("read nodes: %w", r.err)
+	}
+
+	g := &Graph{
+		nodes:          nodes,
+		store:          store,
+		entryPoint:     entryPoint,
+		maxLayer:       maxLayer,
+		m:              m,
+		efConstruction: efConstruction,
+		efSearch:       efSearch,
+		rng:            rand.New(rand.NewSource(42)),
+	}
+	recalculateML(g)
+	return g, nil
+}
+
+// countingReader tracks the offset reached in the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// vec returns node id's vector, from memory or, for a lazily loaded node,
+// from the store. Must be called with g.mu held.
+func (g *Graph) vec(id uint32) []float32 {
+	if v := g.nodes[id].vec; v != nil || g.store == nil || int(id) >= len(g.store.offsets) {
+		return v
+	}
+	return g.store.get(id)
+}
+
+// Lazy reports whether the graph reads vectors on demand (see LoadLazy).
+func (g *Graph) Lazy() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.store != nil
+}
+
+// materializeLocked reads every lazily loaded vector into memory and
+// closes the store. Must be called with g.mu held for writing.
+func (g *Graph) materializeLocked() error {
+	if g.store == nil {
+		return nil
+	}
+	for id := range g.store.offsets {
+		if g.nodes[id].vec != nil {
+			continue
+		}
+		buf := make([]byte, 4*int(g.store.lens[id]))
+		if _, err := g.store.f.ReadAt(buf, g.store.offsets[id]); err != nil {
+			return fmt.Errorf("read vector %d from %s: %w", id, g.store.f.Name(), err)
+		}
+		g.nodes[id].vec = decodeVec(buf)
+	}
+	err := g.store.f.Close()
+	g.store = nil
+	return err
+}
+
+// Close releases the file a lazily loaded graph reads vectors from. The
+// graph must not be used afterwards. It is a no-op for other graphs and
+// for a nil Graph.
+func (g *Graph) Close() error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.store == nil {
+		return nil
+	}
+	err := g.store.f.Close()
+	g.store = nil
+	return err
+}
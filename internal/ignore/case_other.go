@@ -0,0 +1,6 @@
+//go:build !windows
+
+package ignore
+
+// foldCase is false where filesystems are usually case-sensitive.
+const foldCase = false
@@ -0,0 +1,5 @@
+package ignore
+
+// foldCase makes matchers case-insensitive: NTFS and FAT treat Foo.go and
+// foo.go as the same file.
+const foldCase = true
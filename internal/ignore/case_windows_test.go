@@ -0,0 +1,13 @@
+package ignore
+
+import "testing"
+
+func TestNew_FoldsOnWindows(t *testing.T) {
+	m, err := New([]string{"*.sql"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.SkipFile(`DB/DUMP.SQL`) {
+		t.Error("matchers should be case-insensitive on Windows")
+	}
+}
@@ -0,0 +1,147 @@
+// Package ignore decides which paths are left out of the index, combining
+// --exclude / --include-only globs with patterns from a .siftignore file.
+package ignore
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the per-directory-tree ignore file, read from the root being
+// indexed. It holds one glob per line; blank lines and #comments are skipped.
+const FileName = ".siftignore"
+
+// Matcher applies exclude and include-only globs to paths relative to a root.
+//
+// Globs use path.Match syntax per segment plus "**", which matches any
+// number of segments. A glob without a slash matches the base name at any
+// depth ("*.sql"); a glob with one is anchored at the root ("testdata/**").
+//
+// On case-insensitive filesystems (Windows) matching ignores case, so
+// "*.SQL" excludes dump.sql as the filesystem would.
+type Matcher struct {
+	exclude     []string
+	includeOnly []string
+	fold        bool // match case-insensitively
+}
+
+// New validates the globs and returns a Matcher. An empty includeOnly
+// means every file is eligible.
+func New(exclude, includeOnly []string) (*Matcher, error) {
+	for _, p := range append(append([]string{}, exclude...), includeOnly...) {
+		if _, err := path.Match(strings.ReplaceAll(p, "**", "*"), ""); err != nil {
+			return nil, fmt.Errorf("bad glob %q: %w", p, err)
+		}
+	}
+	return &Matcher{exclude: exclude, includeOnly: includeOnly, fold: foldCase}, nil
+}
+
+// ReadFile returns the globs in the ignore file at p, or nil if it does
+// not exist.
+func ReadFile(p string) ([]string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var globs []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		globs = append(globs, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", p, err)
+	}
+	return globs, nil
+}
+
+// SkipDir reports whether the directory at rel (relative to the root) and
+// everything below it should be skipped. Include-only globs never prune
+// directories, since a file deeper down may still match.
+func (m *Matcher) SkipDir(rel string) bool {
+	if m == nil {
+		return false
+	}
+	return m.matchAny(m.exclude, rel)
+}
+
+// SkipFile reports whether the file at rel (relative to the root) should
+// be left out.
+func (m *Matcher) SkipFile(rel string) bool {
+	if m == nil {
+		return false
+	}
+	if m.matchAny(m.exclude, rel) {
+		return true
+	}
+	return len(m.includeOnly) > 0 && !m.matchAny(m.includeOnly, rel)
+}
+
+// Rel converts path to the slash-separated form the matcher expects,
+// relative to root. Paths outside root are returned as-is (slashed).
+func Rel(root, p string) string {
+	if r, err := filepath.Rel(root, p); err == nil && !strings.HasPrefix(r, "..") {
+		p = r
+	}
+	return filepath.ToSlash(p)
+}
+
+func (m *Matcher) matchAny(globs []string, rel string) bool {
+	if m.fold {
+		rel = strings.ToLower(rel)
+	}
+	for _, g := range globs {
+		if m.fold {
+			g = strings.ToLower(g)
+		}
+		if Match(g, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the slash-separated relative path rel matches glob.
+func Match(glob, rel string) bool {
+	glob = strings.TrimPrefix(strings.TrimSuffix(glob, "/"), "./")
+	rel = strings.TrimPrefix(rel, "./")
+	if !strings.Contains(glob, "/") {
+		ok, _ := path.Match(glob, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(glob, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(glob, rel []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			// Zero or more whole segments.
+			for i := 0; i <= len(rel); i++ {
+				if matchSegments(glob[1:], rel[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(rel) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], rel[0]); !ok {
+			return false
+		}
+		glob, rel = glob[1:], rel[1:]
+	}
+	return len(rel) == 0
+}
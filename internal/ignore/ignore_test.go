@@ -0,0 +1,87 @@
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		glob, rel string
+		want      bool
+	}{
+		{"*.sql", "dump.sql", true},
+		{"*.sql", "db/migrations/001.sql", true},
+		{"*.sql", "db/schema.go", false},
+		{"testdata/**", "testdata", true},
+		{"testdata/**", "testdata/a/b.go", true},
+		{"testdata/**", "pkg/testdata/b.go", false},
+		{"**/testdata/**", "pkg/testdata/b.go", true},
+		{"docs/*.md", "docs/a.md", true},
+		{"docs/*.md", "docs/sub/a.md", false},
+		{"docs/**/*.md", "docs/sub/deep/a.md", true},
+		{"./vendor/", "vendor", true},
+	}
+	for _, tc := range cases {
+		if got := Match(tc.glob, tc.rel); got != tc.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tc.glob, tc.rel, got, tc.want)
+		}
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	m, err := New([]string{"testdata/**"}, []string{"*.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.SkipDir("testdata") || m.SkipDir("pkg") {
+		t.Error("SkipDir should prune only excluded directories")
+	}
+	if m.SkipFile("pkg/a.go") || !m.SkipFile("README.md") || !m.SkipFile("testdata/x.go") {
+		t.Error("SkipFile disagrees with exclude/include-only")
+	}
+	var nilM *Matcher
+	if nilM.SkipFile("a") || nilM.SkipDir("a") {
+		t.Error("nil matcher should skip nothing")
+	}
+	if _, err := New([]string{"[bad"}, nil); err == nil {
+		t.Error("expected error for malformed glob")
+	}
+}
+
+func TestMatcher_FoldCase(t *testing.T) {
+	m, err := New([]string{"*.SQL", "Build/**"}, []string{"*.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.fold = true // as on Windows
+	if !m.SkipFile("db/dump.sql") || !m.SkipDir("build") || !m.SkipFile("BUILD/gen.go") {
+		t.Error("folding matcher should exclude regardless of case")
+	}
+	if m.SkipFile("pkg/Main.GO") {
+		t.Error("folding matcher should include Main.GO via *.go")
+	}
+
+	m.fold = false
+	if m.SkipDir("build") || !m.SkipFile("Build/gen.go") || !m.SkipFile("pkg/Main.GO") {
+		t.Error("case-sensitive matcher should not fold")
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), FileName)
+	if globs, err := ReadFile(p); err != nil || globs != nil {
+		t.Fatalf("missing file: got %v, %v", globs, err)
+	}
+	if err := os.WriteFile(p, []byte("# generated\n\n*.sql\n  build/**  \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	globs, err := ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(globs) != 2 || globs[0] != "*.sql" || globs[1] != "build/**" {
+		t.Errorf("globs = %q", globs)
+	}
+}
@@ -0,0 +1,87 @@
+package index
+
+import "slices"
+
+// The index keeps, per file, its chunks' IDs (positions in idx.chunks)
+// ordered by ChunkIndex, and the position of each chunk by its stable
+// ChunkMeta.ID, so a file's chunks, a chunk and its neighbours are found
+// without scanning every chunk. Node IDs shift whenever chunks are
+// dropped, so dropChunksUnderLock rebuilds both; AddFile adds to them
+// chunk by chunk, and RemoveFile takes a file out.
+
+// indexFilesUnderLock rebuilds idx.byFile and idx.byID, and with them
+// idx.byHash, from idx.chunks. Must be called with idx.mu held for
+// writing.
+func (idx *Index) indexFilesUnderLock() {
+	idx.byFile = make(map[string][]int)
+	idx.byID = make(map[string]int, len(idx.chunks)-idx.removed)
+	for id, c := range idx.chunks {
+		if c.removed {
+			continue
+		}
+		k := idx.pathKey(c.Path)
+		idx.byFile[k] = append(idx.byFile[k], id)
+		idx.byID[c.ID] = id
+	}
+	for _, ids := range idx.byFile {
+		idx.sortFileChunks(ids)
+	}
+	idx.indexHashesUnderLock()
+}
+
+// addFileChunkUnderLock records chunk id, just appended, in idx.byFile
+// and idx.byID, and its file in idx.byHash with its first chunk. Must be
+// called with idx.mu held for writing.
+func (idx *Index) addFileChunkUnderLock(id int) {
+	if idx.byFile == nil {
+		idx.byFile = make(map[string][]int)
+	}
+	if idx.byID == nil {
+		idx.byID = make(map[string]int)
+	}
+	idx.byID[idx.chunks[id].ID] = id
+	k := idx.pathKey(idx.chunks[id].Path)
+	ids := append(idx.byFile[k], id)
+	// Chunks are added in order, so this rarely moves anything.
+	for i := len(ids) - 1; i > 0 && idx.chunks[ids[i-1]].ChunkIndex > idx.chunks[ids[i]].ChunkIndex; i-- {
+		ids[i-1], ids[i] = ids[i], ids[i-1]
+	}
+	idx.byFile[k] = ids
+	if len(ids) == 1 {
+		idx.addHashUnderLock(k, idx.chunks[id].FileHash)
+	}
+}
+
+func (idx *Index) sortFileChunks(ids []int) {
+	slices.SortStableFunc(ids, func(a, b int) int {
+		return idx.chunks[a].ChunkIndex - idx.chunks[b].ChunkIndex
+	})
+}
+
+// AdjacentChunks returns up to n chunks before and after the chunk with
+// the given ID (see ChunkMeta.ID) in its file, each in ChunkIndex order:
+// the context around a search result. Both are nil if no chunk has that
+// ID.
+func (idx *Index) AdjacentChunks(id string, n int) (before, after []ChunkMeta) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if n <= 0 {
+		return nil, nil
+	}
+	node, ok := idx.byID[id]
+	if !ok {
+		return nil, nil
+	}
+	ids := idx.byFile[idx.pathKey(idx.chunks[node].Path)]
+	i := slices.Index(ids, node)
+	if i < 0 {
+		return nil, nil
+	}
+	for _, j := range ids[max(i-n, 0):i] {
+		before = append(before, idx.withText(idx.chunks[j]))
+	}
+	for _, j := range ids[i+1 : min(i+1+n, len(ids))] {
+		after = append(after, idx.withText(idx.chunks[j]))
+	}
+	return before, after
+}
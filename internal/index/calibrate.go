@@ -0,0 +1,42 @@
+package index
+
+// SetCalibrate makes searches fill in each result's Calibrated score (see
+// calibrate); it is off by default.
+func (idx *Index) SetCalibrate(on bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.calibrate = on
+	idx.changedUnderLock()
+}
+
+// ThresholdScore is the score thresholds and score displays compare
+// against: Calibrated when the search calibrated it, Score otherwise.
+func (r SearchResult) ThresholdScore() float32 {
+	if r.Calibrated != nil {
+		return *r.Calibrated
+	}
+	return r.Score
+}
+
+// calibrate sets Calibrated on a query's candidate pool, which must be
+// sorted best first, by min-max scaling Score onto [0, 1]: the best
+// candidate gets 1 and the worst 0. Raw scores crowd into a narrow band
+// whose position varies with the query and the keyword boost; scaled
+// ones put a fixed threshold at the same point of every pool. A pool of
+// equal scores is all 1. Scaling is monotonic, so the order is unchanged.
+//
+// The pool is every candidate the graph search returned, before per-file
+// dedup, so how a result scales depends on k.
+func calibrate(pool []SearchResult) {
+	if len(pool) == 0 {
+		return
+	}
+	hi, lo := pool[0].Score, pool[len(pool)-1].Score
+	for i := range pool {
+		c := float32(1)
+		if hi > lo {
+			c = (pool[i].Score - lo) / (hi - lo)
+		}
+		pool[i].Calibrated = &c
+	}
+}
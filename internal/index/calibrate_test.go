@@ -0,0 +1,116 @@
+package index
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestCalibrate(t *testing.T) {
+	pool := func(scores ...float32) []SearchResult {
+		out := make([]SearchResult, len(scores))
+		for i, s := range scores {
+			out[i] = SearchResult{Score: s, Meta: ChunkMeta{ChunkIndex: i}}
+		}
+		return out
+	}
+	for _, tc := range []struct {
+		name   string
+		scores []float32
+		want   []float32
+	}{
+		{"narrow band", []float32{0.85, 0.70, 0.55}, []float32{1, 0.5, 0}},
+		{"keyword boosted", []float32{0.95, 0.65, 0.62, 0.60, 0.50}, []float32{1, 1.0 / 3, 4.0 / 15, 2.0 / 9, 0}},
+		{"negative", []float32{0.1, -0.1, -0.3}, []float32{1, 0.5, 0}},
+		{"ties", []float32{0.8, 0.8, 0.6}, []float32{1, 1, 0}},
+		{"all equal", []float32{0.7, 0.7}, []float32{1, 1}},
+		{"single", []float32{0.42}, []float32{1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := pool(tc.scores...)
+			calibrate(p)
+			for i, r := range p {
+				if r.Calibrated == nil || math.Abs(float64(*r.Calibrated-tc.want[i])) > 1e-6 {
+					t.Errorf("candidate %d (score %.2f): calibrated %v, want %.4f", i, r.Score, r.Calibrated, tc.want[i])
+				}
+				if r.Score != tc.scores[i] || r.ThresholdScore() != *r.Calibrated {
+					t.Errorf("candidate %d: Score %.2f, ThresholdScore %.4f", i, r.Score, r.ThresholdScore())
+				}
+			}
+		})
+	}
+	calibrate(nil) // must not panic
+
+	// Random pools: calibration is monotonic, so it never reorders.
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 200; n++ {
+		scores := make([]float32, 1+rng.Intn(50))
+		base, spread := rng.Float32(), rng.Float32()*0.3
+		for i := range scores {
+			scores[i] = base + rng.Float32()*spread
+		}
+		sort.Slice(scores, func(i, j int) bool { return scores[i] > scores[j] })
+		p := pool(scores...)
+		calibrate(p)
+		if *p[0].Calibrated != 1 {
+			t.Fatalf("best candidate calibrated %.4f, want 1", *p[0].Calibrated)
+		}
+		for i := 1; i < len(p); i++ {
+			if *p[i].Calibrated > *p[i-1].Calibrated || *p[i].Calibrated < 0 {
+				t.Fatalf("pool %v: calibrated %.6f after %.6f", scores, *p[i].Calibrated, *p[i-1].Calibrated)
+			}
+		}
+	}
+}
+
+func TestIndex_SetCalibrate(t *testing.T) {
+	dir := t.TempDir()
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), &mockEmbedder{})
+	for name, body := range map[string]string{
+		"a.md": "wireguard tunnel config",
+		"b.md": "wireguard notes",
+		"c.md": "backup schedule",
+	} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raw, err := idx.Search(context.Background(), "wireguard tunnel", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range raw {
+		if r.Calibrated != nil || r.ThresholdScore() != r.Score {
+			t.Fatalf("uncalibrated search set Calibrated: %+v", r)
+		}
+	}
+
+	idx.SetCalibrate(true)
+	got, err := idx.Search(context.Background(), "wireguard tunnel", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(raw) {
+		t.Fatalf("got %d results, want %d", len(got), len(raw))
+	}
+	// Keyword boosts of 2, 1 and 0 words, scaled onto [0, 1].
+	hi, lo := raw[0].Score, raw[len(raw)-1].Score
+	for i, r := range got {
+		want := (raw[i].Score - lo) / (hi - lo)
+		if r.Meta.Path != raw[i].Meta.Path || r.Score != raw[i].Score {
+			t.Errorf("result %d = %s %.3f, want %s %.3f unchanged", i, r.Meta.Path, r.Score, raw[i].Meta.Path, raw[i].Score)
+		}
+		if r.Calibrated == nil || math.Abs(float64(*r.Calibrated-want)) > 1e-6 {
+			t.Errorf("result %d calibrated %v, want %.2f", i, r.Calibrated, want)
+		}
+	}
+}
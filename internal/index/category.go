@@ -0,0 +1,142 @@
+package index
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/tejas242/sift/internal/ignore"
+)
+
+// Index runs tag every chunk with the category of its file: tests, docs,
+// config, source, or whatever the project's own rules name. Searches
+// filter on it (Filter.Types, "type:tests" in a query) and stats break
+// the index down by it.
+
+// The built-in categories.
+const (
+	CategoryTests  = "tests"
+	CategoryDocs   = "docs"
+	CategoryConfig = "config"
+	CategorySource = "source"
+	// CategoryOther is the category of files no rule matches.
+	CategoryOther = "other"
+)
+
+// CategoryRule puts the files matching Glob in Category. Globs are
+// matched as exclude patterns are, against the path relative to the
+// index root: "*_test.go" matches the base name, "docs/**" a subtree.
+type CategoryRule struct {
+	Category string
+	Glob     string
+}
+
+// DefaultCategoryRules are tried after the project's own rules (see
+// SetCategoryRules); the first rule that matches decides.
+var DefaultCategoryRules = []CategoryRule{
+	{CategoryTests, "*_test.go"},
+	{CategoryTests, "test_*.py"},
+	{CategoryTests, "*_test.py"},
+	{CategoryTests, "*.test.js"},
+	{CategoryTests, "*.test.ts"},
+	{CategoryTests, "*.spec.js"},
+	{CategoryTests, "*.spec.ts"},
+	{CategoryTests, "**/test/**"},
+	{CategoryTests, "**/tests/**"},
+	{CategoryTests, "**/__tests__/**"},
+	{CategoryTests, "**/testdata/**"},
+	{CategoryDocs, "**/docs/**"},
+	{CategoryDocs, "**/doc/**"},
+	{CategoryDocs, "*.md"},
+	{CategoryDocs, "*.txt"},
+	{CategoryConfig, "*.json"},
+	{CategoryConfig, "*.yaml"},
+	{CategoryConfig, "*.yml"},
+	{CategoryConfig, "*.toml"},
+	{CategoryConfig, "*.kdl"},
+	{CategoryConfig, "*.conf"},
+	{CategorySource, "*.go"},
+	{CategorySource, "*.py"},
+	{CategorySource, "*.js"},
+	{CategorySource, "*.ts"},
+	{CategorySource, "*.rs"},
+	{CategorySource, "*.c"},
+	{CategorySource, "*.cpp"},
+	{CategorySource, "*.h"},
+}
+
+// SetCategoryRules sets the project's own category rules, tried in order
+// before DefaultCategoryRules. Files indexed from then on are tagged by
+// them; `sift reindex` re-tags the files already indexed.
+func (idx *Index) SetCategoryRules(rules []CategoryRule) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.categories = slices.Clone(rules)
+	idx.changedUnderLock()
+}
+
+// classify returns the category of the file at path under idx's rules.
+func (idx *Index) classify(path string) string {
+	rel := ignore.Rel(idx.root, path)
+	if idx.foldCase {
+		rel = strings.ToLower(rel)
+	}
+	for _, rules := range [][]CategoryRule{idx.categories, DefaultCategoryRules} {
+		for _, r := range rules {
+			glob := r.Glob
+			if idx.foldCase {
+				glob = strings.ToLower(glob)
+			}
+			if ignore.Match(glob, rel) {
+				return r.Category
+			}
+		}
+	}
+	return CategoryOther
+}
+
+// category returns c's category: the one it was indexed with, or for a
+// chunk indexed before categories were, that of its path now.
+func (idx *Index) category(c ChunkMeta) string {
+	if c.Category != "" {
+		return c.Category
+	}
+	return idx.classify(c.Path)
+}
+
+// countCategories breaks chunks down by category. Must be called with
+// idx.mu held (read).
+func (idx *Index) countCategories() map[string]ExtStats {
+	out := make(map[string]ExtStats)
+	seen := make(map[string]bool)
+	for _, c := range idx.liveChunks() {
+		cat := idx.category(c)
+		cs := out[cat]
+		cs.Chunks++
+		if !seen[c.Path] {
+			seen[c.Path] = true
+			cs.Files++
+		}
+		out[cat] = cs
+	}
+	return out
+}
+
+// SplitTypes takes "type:" terms out of a query, returning the rest of
+// the query and the categories named, lower-cased: "retry type:tests"
+// gives "retry" and [tests]. "type:docs,config" names two.
+func SplitTypes(query string) (string, []string) {
+	var rest, types []string
+	for _, w := range strings.Fields(query) {
+		name, ok := strings.CutPrefix(strings.ToLower(w), "type:")
+		if !ok || name == "" {
+			rest = append(rest, w)
+			continue
+		}
+		for _, t := range strings.Split(name, ",") {
+			if t != "" && !slices.Contains(types, t) {
+				types = append(types, t)
+			}
+		}
+	}
+	return strings.Join(rest, " "), types
+}
@@ -0,0 +1,140 @@
+package index
+
+import (
+	"context"
+	"maps"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestClassify_Defaults(t *testing.T) {
+	dir := t.TempDir()
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), wordEmbedder{})
+	for path, want := range map[string]string{
+		"internal/index/index.go":      CategorySource,
+		"internal/index/index_test.go": CategoryTests,
+		"tests/test_chunk.py":          CategoryTests,
+		"pkg/chunk_test.py":            CategoryTests,
+		"web/app.spec.ts":              CategoryTests,
+		"web/__tests__/app.js":         CategoryTests,
+		"testdata/sample.md":           CategoryTests, // a test's input, whatever its extension
+		"README.md":                    CategoryDocs,
+		"docs/design.html":             CategoryDocs,
+		"docs/api/index.go":            CategoryDocs,
+		"notes.txt":                    CategoryDocs,
+		".sift.toml":                   CategoryConfig,
+		"deploy/values.yaml":           CategoryConfig,
+		"package.json":                 CategoryConfig,
+		"src/lib.rs":                   CategorySource,
+		"Makefile":                     CategoryOther,
+		"assets/logo.svg":              CategoryOther,
+	} {
+		if got := idx.classify(filepath.Join(dir, filepath.FromSlash(path))); got != want {
+			t.Errorf("classify(%s) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestClassify_ProjectRules(t *testing.T) {
+	dir := t.TempDir()
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), wordEmbedder{})
+	idx.SetCategoryRules([]CategoryRule{
+		{CategoryTests, "spec/**"},
+		{"bench", "*_bench.go"},
+		{CategorySource, "docs/examples/**"}, // ahead of the built-in docs/**
+	})
+	for path, want := range map[string]string{
+		"spec/retry.rb":               CategoryTests,
+		"internal/hnsw/hnsw_bench.go": "bench",
+		"docs/examples/main.go":       CategorySource,
+		"docs/guide.md":               CategoryDocs, // built-in rules still apply
+		"internal/hnsw/hnsw.go":       CategorySource,
+	} {
+		if got := idx.classify(filepath.Join(dir, filepath.FromSlash(path))); got != want {
+			t.Errorf("classify(%s) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestSplitTypes(t *testing.T) {
+	for _, tc := range []struct {
+		in, rest string
+		types    []string
+	}{
+		{"retry backoff", "retry backoff", nil},
+		{"retry type:tests", "retry", []string{"tests"}},
+		{"Type:Docs,config retry type:docs", "retry", []string{"docs", "config"}},
+		{"type: retry", "type: retry", nil}, // no category named
+		{"type:tests", "", []string{"tests"}},
+	} {
+		rest, types := SplitTypes(tc.in)
+		if rest != tc.rest || !slices.Equal(types, tc.types) {
+			t.Errorf("SplitTypes(%q) = %q, %q; want %q, %q", tc.in, rest, types, tc.rest, tc.types)
+		}
+	}
+}
+
+func TestSearch_TypeFilter(t *testing.T) {
+	idx, dir := dirsFixture(t, map[string]string{
+		"vpn/peer.go":      "wireguard tunnel",
+		"vpn/peer_test.go": "wireguard",
+		"docs/vpn.md":      "wireguard",
+		"vpn.toml":         "wireguard",
+	})
+	paths := func(f Filter) []string {
+		t.Helper()
+		results, err := idx.SearchFiltered(context.Background(), "wireguard", 10, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range results {
+			rel, _ := filepath.Rel(dir, r.Meta.Path)
+			got = append(got, filepath.ToSlash(rel))
+		}
+		slices.Sort(got)
+		return got
+	}
+
+	for _, tc := range []struct {
+		types []string
+		want  []string
+	}{
+		{nil, []string{"docs/vpn.md", "vpn.toml", "vpn/peer.go", "vpn/peer_test.go"}},
+		{[]string{CategoryTests}, []string{"vpn/peer_test.go"}},
+		{[]string{CategoryDocs, CategoryConfig}, []string{"docs/vpn.md", "vpn.toml"}},
+		{[]string{"bench"}, nil},
+	} {
+		if got := paths(Filter{Types: tc.types}); !slices.Equal(got, tc.want) {
+			t.Errorf("types %q: got %q, want %q", tc.types, got, tc.want)
+		}
+	}
+
+	// The category is stored with each chunk, and counted by Stats.
+	for _, c := range idx.chunks {
+		if c.Category == "" {
+			t.Errorf("%s stored without a category", c.Path)
+		}
+	}
+	want := map[string]ExtStats{
+		CategorySource: {Files: 1, Chunks: 1},
+		CategoryTests:  {Files: 1, Chunks: 1},
+		CategoryDocs:   {Files: 1, Chunks: 1},
+		CategoryConfig: {Files: 1, Chunks: 1},
+	}
+	if got := idx.Stats().Categories; !maps.Equal(got, want) {
+		t.Errorf("Stats().Categories = %v, want %v", got, want)
+	}
+
+	// Chunks indexed before categories were are classified by path.
+	idx.mu.Lock()
+	for id := range idx.chunks {
+		idx.chunks[id].Category = ""
+	}
+	idx.changedUnderLock()
+	idx.mu.Unlock()
+	if got := paths(Filter{Types: []string{CategoryTests}}); !slices.Equal(got, []string{"vpn/peer_test.go"}) {
+		t.Errorf("untagged chunks, type tests: got %q", got)
+	}
+}
@@ -0,0 +1,45 @@
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// chunkIDLen is the length of a ChunkID in hex digits (64 bits).
+const chunkIDLen = 16
+
+// chunkID derives a chunk's stable identifier from its path, its position
+// in the file, and its full text, so it survives compaction and
+// re-indexing as long as the chunk itself is unchanged.
+func chunkID(path string, chunkIndex int, text string) string {
+	h := sha256.New()
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.Itoa(chunkIndex)))
+	h.Write([]byte{0})
+	h.Write([]byte(text))
+	return hex.EncodeToString(h.Sum(nil))[:chunkIDLen]
+}
+
+// fillChunkIDs gives an ID to chunks loaded from an index written before
+// IDs existed. Their full text may no longer be stored, so the ID is
+// derived from what is; re-indexing the file replaces it.
+func fillChunkIDs(chunks []ChunkMeta) {
+	for i := range chunks {
+		if chunks[i].ID == "" {
+			chunks[i].ID = chunkID(chunks[i].Path, chunks[i].ChunkIndex, chunks[i].Text)
+		}
+	}
+}
+
+// GetChunk returns the chunk with the given ID (see ChunkMeta.ID).
+func (idx *Index) GetChunk(id string) (ChunkMeta, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	node, ok := idx.byID[id]
+	if !ok {
+		return ChunkMeta{}, false
+	}
+	return idx.withText(idx.chunks[node]), true
+}
@@ -0,0 +1,226 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tejas242/sift/internal/hnsw"
+)
+
+// A long-lived watch process keeps files deleted from disk in the index
+// (fsnotify events get lost), with their skip-cache entries, and keeps
+// cached searches of generations long retired. Compact drops them. Unlike
+// PruneDeleted, which rebuilds the graph under the write lock, it builds
+// the smaller graph off to the side while searches and inserts carry on,
+// and swaps it in only if the index did not change meanwhile, in memory
+// or on disk.
+
+// ErrIndexChanged is returned by Compact when the index changed while
+// the compacted graph was being built, or another process saved it and
+// idx could not reload it; the work is then discarded, and a later
+// Compact will start over.
+var ErrIndexChanged = errors.New("index changed while compacting")
+
+// Compaction reports what Compact did.
+type Compaction struct {
+	// Pruned lists, sorted, the files found gone from disk whose chunks
+	// and skip-cache entries were dropped, and Chunks counts those chunks.
+	Pruned []string
+	Chunks int
+	// CachedDropped counts the cached searches dropped as retired or
+	// expired.
+	CachedDropped int
+	Took          time.Duration
+}
+
+// compactInserted, if set, is called after each vector Compact inserts
+// into the new graph; tests use it to stop a compaction midway.
+var compactInserted func(n int)
+
+// Compact drops files gone from disk and stale cached searches, then
+// records the time in the manifest (see LastMaintenance) and saves the
+// index. It first reloads the index if another process saved it (see
+// Refresh), so it never writes older data over newer. Searches keep
+// answering from the old graph until the new one is complete. If ctx is
+// done first, the new graph is discarded and the index is left as it was.
+func (idx *Index) Compact(ctx context.Context) (*Compaction, error) {
+	start := clock()
+	idx.mu.RLock()
+	readOnly := idx.readOnly || idx.metaOnly || idx.stagingFor != nil
+	idx.mu.RUnlock()
+	if readOnly {
+		return nil, ErrReadOnly
+	}
+	if _, err := idx.Refresh(); err != nil {
+		return nil, err
+	}
+
+	// The pass returns idx.missing, which the swap below edits.
+	missing := slices.Clone(idx.CheckLiveness())
+	gone := make(map[string]bool, len(missing))
+	for _, p := range missing {
+		gone[idx.pathKey(p)] = true
+	}
+	isGone := func(p string) bool { return gone[idx.pathKey(p)] }
+	c := &Compaction{}
+
+	var (
+		gen    uint64
+		chunks []ChunkMeta
+		graph  *hnsw.Graph
+	)
+	if len(gone) > 0 {
+		// Copy the surviving vectors under the read lock, then insert them
+		// without it.
+		idx.mu.RLock()
+		gen = idx.gen
+		chunks = make([]ChunkMeta, 0, len(idx.chunks))
+		var vecs [][]float32
+		for id, ch := range idx.chunks {
+			if ch.removed {
+				continue
+			}
+			if isGone(ch.Path) {
+				c.Chunks++
+				continue
+			}
+			if vec := idx.graph.GetNodeVec(uint32(id)); vec != nil {
+				chunks = append(chunks, ch)
+				vecs = append(vecs, slices.Clone(vec))
+			}
+		}
+		idx.mu.RUnlock()
+
+		graph = hnsw.New(hnsw.DefaultM, hnsw.DefaultEfConstruction, hnsw.DefaultEfSearch)
+		for i, vec := range vecs {
+			if err := ctx.Err(); err != nil {
+				graph.Close()
+				return nil, err
+			}
+			graph.Insert(vec)
+			if compactInserted != nil {
+				compactInserted(i + 1)
+			}
+		}
+	}
+
+	idx.mu.Lock()
+	if idx.savedElsewhereUnderLock() || graph != nil && idx.gen != gen {
+		idx.mu.Unlock()
+		if graph != nil {
+			graph.Close()
+		}
+		return nil, ErrIndexChanged
+	}
+	if graph != nil {
+		old := idx.graph
+		idx.graph, idx.chunks = graph, chunks
+		idx.removed = 0
+		idx.indexFilesUnderLock()
+		idx.dropTermsUnderLock()
+		for k := range idx.fileCache {
+			if isGone(k) {
+				delete(idx.fileCache, k)
+			}
+		}
+		idx.forgetOversizedUnderLock(isGone)
+		idx.missing = slices.DeleteFunc(idx.missing, isGone)
+		idx.changedUnderLock()
+		idx.dirty = true
+		idx.lastUpdated = time.Now()
+		old.Close()
+		c.Pruned = missing
+		for _, p := range missing {
+			idx.log.Debugf("pruned %s", p)
+		}
+	}
+	if idx.manifest == nil {
+		idx.manifest = newManifest("")
+	}
+	idx.manifest.LastMaintenance = time.Now()
+	dirty := idx.dirty
+	idx.mu.Unlock()
+
+	c.CachedDropped = idx.results.purge(idx.generation())
+	var err error
+	if dirty {
+		err = idx.Flush()
+	} else {
+		err = idx.writeManifestIfExists()
+	}
+	c.Took = clock().Sub(start)
+	return c, err
+}
+
+// generation returns the index's current generation.
+func (idx *Index) generation() uint64 {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.gen
+}
+
+// writeManifestIfExists saves just the manifest, if the index has been
+// saved before.
+func (idx *Index) writeManifestIfExists() error {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if _, err := os.Stat(idx.dir); os.IsNotExist(err) {
+		return nil
+	}
+	return writeManifest(idx.dir, idx.manifest)
+}
+
+// LastMaintenance returns when Compact last ran on this index, by this
+// process or another that saved it since, or the zero time if it never
+// did.
+func (idx *Index) LastMaintenance() time.Time {
+	idx.mu.RLock()
+	last := time.Time{}
+	if idx.manifest != nil {
+		last = idx.manifest.LastMaintenance
+	}
+	idx.mu.RUnlock()
+	if m, err := ReadManifest(idx.dir); err == nil && m.LastMaintenance.After(last) {
+		last = m.LastMaintenance
+	}
+	return last
+}
+
+// LastUpdated returns when the index's contents last changed.
+func (idx *Index) LastUpdated() time.Time {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.lastUpdated
+}
+
+// StaleFraction runs the liveness pass (see CheckLiveness) and returns
+// the share of indexed files that are gone from disk.
+func (idx *Index) StaleFraction() float64 {
+	missing := len(idx.CheckLiveness())
+	known := len(idx.knownPaths())
+	if known == 0 {
+		return 0
+	}
+	return float64(missing) / float64(known)
+}
+
+// purge drops the cached searches of generations other than gen, and
+// those that outlived the TTL, returning how many it dropped.
+func (c *resultCache) purge(gen uint64) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	prefix := fmt.Sprintf("%d\x00", gen)
+	n := 0
+	for k, e := range c.entries {
+		if !strings.HasPrefix(k, prefix) || c.policy.TTL > 0 && clock().Sub(e.added) >= c.policy.TTL {
+			delete(c.entries, k)
+			n++
+		}
+	}
+	return n
+}
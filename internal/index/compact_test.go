@@ -0,0 +1,185 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// compactFixture indexes three files into a saved index and deletes one
+// from disk, returning the index and the deleted file.
+func compactFixture(t *testing.T) (idx *Index, siftDir, gone string) {
+	t.Helper()
+	root := t.TempDir()
+	siftDir = filepath.Join(t.TempDir(), ".sift")
+	for name, text := range map[string]string{"a.md": "wireguard tunnel", "b.md": "garden kettle", "c.md": "kettle garden tunnel"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx = NewTestIndex(siftDir, wordEmbedder{})
+	idx.SetResultCache(ResultCache{Size: 8})
+	if err := idx.IndexDir(context.Background(), root); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	gone = filepath.Join(root, "a.md")
+	if err := os.Remove(gone); err != nil {
+		t.Fatal(err)
+	}
+	return idx, siftDir, gone
+}
+
+func TestCompact(t *testing.T) {
+	idx, siftDir, gone := compactFixture(t)
+	if f := idx.StaleFraction(); f < 0.3 || f > 0.34 {
+		t.Errorf("StaleFraction = %v, want 1/3", f)
+	}
+	// A search cached before the compaction is retired by it.
+	if _, err := idx.Search(context.Background(), "garden", 5); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := idx.Compact(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(c.Pruned, []string{gone}) || c.Chunks != 1 || c.CachedDropped != 1 {
+		t.Errorf("Compact = %+v, want a.md pruned (1 chunk) and 1 cached search dropped", c)
+	}
+	if got := idx.Files(); len(got) != 2 || slices.Contains(got, gone) {
+		t.Errorf("Files after Compact = %v, want b.md and c.md", got)
+	}
+	res, err := idx.Search(context.Background(), "tunnel", 5)
+	if err != nil || len(res) == 0 || filepath.Base(res[0].Meta.Path) != "c.md" {
+		t.Errorf("Search(tunnel) after Compact = %+v, %v; want c.md first", res, err)
+	}
+
+	// The result is saved, with the time it ran.
+	reloaded, err := load(siftDir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(reloaded.Files()); n != 2 {
+		t.Errorf("saved index holds %d files, want 2", n)
+	}
+	if last := reloaded.LastMaintenance(); last.IsZero() || !last.Equal(idx.LastMaintenance()) {
+		t.Errorf("saved LastMaintenance = %v, want %v", last, idx.LastMaintenance())
+	}
+
+	// With nothing to prune, only the time is recorded.
+	before := idx.LastMaintenance()
+	if c, err := idx.Compact(context.Background()); err != nil || len(c.Pruned) != 0 {
+		t.Errorf("second Compact = %+v, %v; want nothing pruned", c, err)
+	}
+	if m, err := ReadManifest(siftDir); err != nil || !m.LastMaintenance.After(before) {
+		t.Errorf("manifest LastMaintenance = %v, %v; want after %v", m.LastMaintenance, err, before)
+	}
+}
+
+func TestCompact_Cancelled(t *testing.T) {
+	idx, siftDir, gone := compactFixture(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	compactInserted = func(n int) { cancel() }
+	t.Cleanup(func() { compactInserted = nil })
+
+	if _, err := idx.Compact(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Compact = %v, want context.Canceled", err)
+	}
+	// The index is as it was, in memory and on disk, and still loads.
+	if !slices.Contains(idx.Files(), gone) {
+		t.Error("a cancelled Compact dropped a.md")
+	}
+	if !idx.LastMaintenance().IsZero() {
+		t.Error("a cancelled Compact recorded a maintenance time")
+	}
+	reloaded, err := load(siftDir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(reloaded.Files()); n != 3 {
+		t.Errorf("saved index holds %d files after a cancelled Compact, want 3", n)
+	}
+}
+
+func TestCompact_IndexChanged(t *testing.T) {
+	idx, _, gone := compactFixture(t)
+	extra := filepath.Join(filepath.Dir(gone), "d.md")
+	if err := os.WriteFile(extra, []byte("wireguard garden"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	compactInserted = func(n int) {
+		if n == 1 {
+			if _, err := idx.AddFile(extra); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+	t.Cleanup(func() { compactInserted = nil })
+
+	if _, err := idx.Compact(context.Background()); !errors.Is(err, ErrIndexChanged) {
+		t.Fatalf("Compact = %v, want ErrIndexChanged", err)
+	}
+	// The file added meanwhile is kept, and nothing was pruned.
+	if files := idx.Files(); !slices.Contains(files, extra) || !slices.Contains(files, gone) {
+		t.Errorf("Files = %v, want d.md added and a.md still there", files)
+	}
+}
+
+func TestCompact_SavedElsewhere(t *testing.T) {
+	idx, siftDir, gone := compactFixture(t)
+	root := filepath.Dir(gone)
+	other, err := OpenWith(Options{Dir: siftDir, Embedder: wordEmbedder{}, MaxFileKB: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	add := func(idx *Index, name string) {
+		t.Helper()
+		p := filepath.Join(root, name)
+		if err := os.WriteFile(p, []byte("garden "+name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Another process saves a file: Compact picks it up instead of
+	// writing its older copy of the index over it.
+	add(other, "d.md")
+	if err := other.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.Compact(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := load(siftDir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := reloaded.Files(); len(files) != 3 || !slices.Contains(files, filepath.Join(root, "d.md")) {
+		t.Errorf("saved files after Compact = %v, want b.md, c.md and d.md", files)
+	}
+
+	// With unsaved changes of its own, idx cannot reload, so it gives up.
+	add(idx, "e.md")
+	other, err = OpenWith(Options{Dir: siftDir, Embedder: wordEmbedder{}, MaxFileKB: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	add(other, "f.md")
+	if err := other.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.Compact(context.Background()); !errors.Is(err, ErrIndexChanged) {
+		t.Fatalf("Compact over an index saved elsewhere = %v, want ErrIndexChanged", err)
+	}
+	if m, err := ReadManifest(siftDir); err != nil || !m.LastMaintenance.Before(m.Updated) {
+		t.Errorf("manifest = %+v, %v; want f.md's save left in place", m, err)
+	}
+}
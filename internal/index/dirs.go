@@ -0,0 +1,128 @@
+package index
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"path/filepath"
+	"slices"
+)
+
+// dirTopFiles is how many of a directory's best files its score sums.
+const dirTopFiles = 3
+
+// dirPool is how many candidate chunks SearchDirs considers per directory
+// asked for: far more than a file search, as a directory's score needs
+// several of its files among them.
+const dirPool = 50
+
+// DirResult is a directory returned from SearchDirs.
+type DirResult struct {
+	// Dir is the directory, as the paths of the files in it are indexed.
+	Dir string
+	// Score is the sum of the scores of the directory's best dirTopFiles
+	// files, each scored by its best chunk, over the square root of how
+	// many files are indexed in it (or of dirTopFiles, if fewer): a
+	// directory the query runs through outranks one holding a single
+	// strong match, and a large directory needs more matching files.
+	Score float32
+	// Files is how many files indexed directly in Dir pass the filter,
+	// and Matched how many of them were among the candidates.
+	Files, Matched int
+	// Top is the best chunk of the directory's best file.
+	Top SearchResult
+}
+
+// SearchDirs ranks directories instead of files: it scores a candidate
+// pool dirPool times k chunks, as Search would, and aggregates the best
+// chunk of each file by the directory holding it (see DirResult.Score),
+// returning the best k directories. f applies as it does to Search,
+// except that its per-directory and per-file caps do not; the pool is
+// widened while fewer than k directories pass it. Results are not cached.
+func (idx *Index) SearchDirs(ctx context.Context, query string, k int, f Filter) ([]DirResult, error) {
+	query = NormalizeQuery(query)
+	if query == "" {
+		return nil, ErrEmptyQuery
+	}
+	queryVec, err := idx.embedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if _, n := idx.prepareSearch(k, f); n == 0 {
+		return nil, nil
+	}
+	fetchK := min(k*dirPool, len(idx.chunks))
+	for {
+		hits, err := idx.graph.SearchContext(ctx, queryVec, fetchK)
+		if err != nil {
+			return nil, err
+		}
+		scored, err := idx.scoreHits(ctx, query, hits, f)
+		if err != nil {
+			return nil, err
+		}
+		dirs := idx.rankDirs(scored, f)
+		if len(dirs) >= k || fetchK == len(idx.chunks) || f.IsZero() {
+			return dirs[:min(k, len(dirs))], nil
+		}
+		fetchK = min(fetchK*4, len(idx.chunks))
+	}
+}
+
+// rankDirs aggregates scored chunks, best first, by directory. Must be
+// called with idx.mu held (read).
+func (idx *Index) rankDirs(scored []SearchResult, f Filter) []DirResult {
+	byDir := make(map[string][]SearchResult) // the best chunk of each file
+	seen := make(map[string]bool)
+	for _, r := range scored {
+		key := idx.pathKey(r.Meta.Path)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		dir := filepath.Dir(key)
+		byDir[dir] = append(byDir[dir], r)
+	}
+	files := idx.dirFiles(f)
+	dirs := make([]DirResult, 0, len(byDir))
+	for key, best := range byDir {
+		// In score order already, as scored is.
+		var sum float32
+		for _, r := range best[:min(dirTopFiles, len(best))] {
+			sum += r.Score
+		}
+		n := max(files[key], len(best))
+		dirs = append(dirs, DirResult{
+			Dir:     filepath.Dir(best[0].Meta.Path),
+			Score:   sum / float32(math.Sqrt(float64(max(n, dirTopFiles)))),
+			Files:   n,
+			Matched: len(best),
+			Top:     best[0],
+		})
+	}
+	slices.SortFunc(dirs, func(a, b DirResult) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Dir, b.Dir)
+	})
+	return dirs
+}
+
+// dirFiles counts the files passing f in each directory, by the key of
+// its path. Must be called with idx.mu held (read).
+func (idx *Index) dirFiles(f Filter) map[string]int {
+	files := make(map[string]int)
+	for key, ids := range idx.byFile {
+		if len(ids) == 0 {
+			continue
+		}
+		if idx.passes(f, idx.chunks[ids[0]]) {
+			files[filepath.Dir(key)]++
+		}
+	}
+	return files
+}
@@ -0,0 +1,92 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// dirsFixture indexes files, by slash-separated path, into a new tree
+// embedded by wordEmbedder.
+func dirsFixture(t *testing.T, files map[string]string) (*Index, string) {
+	t.Helper()
+	dir := t.TempDir()
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), wordEmbedder{})
+	for name, body := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return idx, dir
+}
+
+func TestSearchDirs(t *testing.T) {
+	idx, dir := dirsFixture(t, map[string]string{
+		// Three files about the query: the dominant directory, though
+		// misc/ holds a match as strong as its best.
+		"vpn/peer.go":   "wireguard",
+		"vpn/config.go": "wireguard wireguard tunnel",
+		"vpn/route.go":  "wireguard tunnel",
+		"misc/vpn.md":   "wireguard",
+		"misc/tea.md":   "kettle",
+		// One match among many files.
+		"home/a.md": "wireguard", "home/b.md": "garden", "home/c.md": "garden",
+		"home/d.md": "garden", "home/e.md": "garden", "home/f.md": "garden",
+		"home/g.md": "kettle", "home/h.md": "kettle", "home/i.md": "kettle",
+		"home/j.md":    "kettle",
+		"garden/x.txt": "garden",
+	})
+	dirs, err := idx.SearchDirs(context.Background(), "wireguard", 3, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		dir, top     string
+		files, match int
+	}{
+		{"vpn", "peer.go", 3, 3},
+		{"misc", "vpn.md", 2, 2},
+		{"home", "a.md", 10, 10},
+	}
+	if len(dirs) != len(want) {
+		t.Fatalf("got %d directories, want %d: %+v", len(dirs), len(want), dirs)
+	}
+	for i, w := range want {
+		d := dirs[i]
+		if d.Dir != filepath.Join(dir, w.dir) || filepath.Base(d.Top.Meta.Path) != w.top || d.Files != w.files || d.Matched != w.match {
+			t.Errorf("#%d: %s (top %s, %d of %d files), want %s (top %s, %d of %d)", i+1,
+				d.Dir, filepath.Base(d.Top.Meta.Path), d.Matched, d.Files, w.dir, w.top, w.match, w.files)
+		}
+	}
+	if !(dirs[0].Score > dirs[1].Score && dirs[1].Score > dirs[2].Score) {
+		t.Errorf("scores %v %v %v, want decreasing", dirs[0].Score, dirs[1].Score, dirs[2].Score)
+	}
+
+	// Filters apply as they do to a file search.
+	dirs, err = idx.SearchDirs(context.Background(), "wireguard", 3, Filter{Exts: []string{"md"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 2 || dirs[0].Dir != filepath.Join(dir, "misc") || dirs[1].Dir != filepath.Join(dir, "home") {
+		t.Errorf("md files only: %+v, want misc then home", dirs)
+	}
+	dirs, err = idx.SearchDirs(context.Background(), "wireguard", 3, Filter{Dirs: []string{"garden"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 1 || dirs[0].Dir != filepath.Join(dir, "garden") || dirs[0].Files != 1 {
+		t.Errorf("in garden/: %+v, want garden alone", dirs)
+	}
+
+	if _, err := idx.SearchDirs(context.Background(), "  ", 3, Filter{}); err != ErrEmptyQuery {
+		t.Errorf("empty query: %v, want ErrEmptyQuery", err)
+	}
+}
@@ -0,0 +1,108 @@
+package index
+
+import (
+	"fmt"
+	"sync"
+)
+
+// EmbedFailure is a file an indexing run could not embed.
+type EmbedFailure struct {
+	Path string
+	Err  error
+}
+
+// EmbedFailureLimit is how much embedding failure an indexing run puts up
+// with before giving up with an *EmbedAbortError. Zero fields are no
+// limit.
+type EmbedFailureLimit struct {
+	// Consecutive is how many files failing in a row end the run.
+	Consecutive int
+	// Percent is the share of the files tried so far (embedded or
+	// failed) that may fail, checked once minRateFiles have been tried.
+	Percent int
+}
+
+// DefaultEmbedFailureLimit gives up after 10 failures in a row, or once
+// more than a fifth of the files have failed.
+var DefaultEmbedFailureLimit = EmbedFailureLimit{Consecutive: 10, Percent: 20}
+
+const (
+	// minRateFiles is how many files must have been tried before
+	// EmbedFailureLimit.Percent applies, so one early failure is not 100%.
+	minRateFiles = 10
+	// embedFailureSamples is how many failures RunStats keeps.
+	embedFailureSamples = 10
+)
+
+// EmbedAbortError is returned by AddFile, and so ends an IndexDir run,
+// once embedding has failed more often than the EmbedFailureLimit allows:
+// a broken model or ONNX Runtime would otherwise "index" a whole tree
+// while embedding nothing. It matches ErrEmbedder and the last failure's
+// error.
+type EmbedAbortError struct {
+	Failed, Tried int // files this run
+	InARow        int // failures since the last file embedded
+	Last          EmbedFailure
+}
+
+func (e *EmbedAbortError) Error() string {
+	return fmt.Sprintf("embedding failed for %d of %d files (%d in a row), giving up; last: %s: %v",
+		e.Failed, e.Tried, e.InARow, e.Last.Path, e.Last.Err)
+}
+
+func (e *EmbedAbortError) Unwrap() []error { return []error{ErrEmbedder, e.Last.Err} }
+
+// SetEmbedFailureLimit changes when indexing gives up on a failing
+// embedder; it is DefaultEmbedFailureLimit until set.
+func (idx *Index) SetEmbedFailureLimit(l EmbedFailureLimit) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.embedLimit = l
+}
+
+// EmbedFailing reports whether the last file whose embedding was
+// attempted failed.
+func (idx *Index) EmbedFailing() bool {
+	return idx.counter().inARow.Load() > 0
+}
+
+// failureLog keeps the first embedFailureSamples failures of a run.
+type failureLog struct {
+	mu      sync.Mutex
+	samples []EmbedFailure
+}
+
+// embedFailed counts path as a file that could not be embedded and
+// returns an *EmbedAbortError if that puts the run over the limit. Only
+// the sampled failures are logged as warnings, so a dead embedder does
+// not print a line per file.
+func (idx *Index) embedFailed(path string, err error) error {
+	r := idx.counter()
+	r.errored.Add(1)
+	failed := r.embedFailed.Add(1)
+	inARow := r.inARow.Add(1)
+	tried := failed + r.embedded.Load()
+
+	f := EmbedFailure{Path: path, Err: err}
+	r.failures.mu.Lock()
+	sampled := len(r.failures.samples) < embedFailureSamples
+	if sampled {
+		r.failures.samples = append(r.failures.samples, f)
+	}
+	r.failures.mu.Unlock()
+	if sampled {
+		idx.log.Warnf("skip %s: embed error: %v", path, err)
+	} else {
+		idx.log.Debugf("skip %s: embed error: %v", path, err)
+	}
+
+	idx.mu.RLock()
+	l := idx.embedLimit
+	idx.mu.RUnlock()
+	over := l.Consecutive > 0 && inARow >= int64(l.Consecutive) ||
+		l.Percent > 0 && tried >= minRateFiles && failed*100 > int64(l.Percent)*tried
+	if !over {
+		return nil
+	}
+	return &EmbedAbortError{Failed: int(failed), Tried: int(tried), InARow: int(inARow), Last: f}
+}
@@ -0,0 +1,127 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var errEmbedBroken = errors.New("onnxruntime: session closed")
+
+// failingEmbedder fails to embed any batch containing "bad".
+type failingEmbedder struct{ mockEmbedder }
+
+func (e *failingEmbedder) Embed(texts []string) ([][]float32, error) {
+	for _, t := range texts {
+		if strings.Contains(t, "bad") {
+			return nil, errEmbedBroken
+		}
+	}
+	return e.mockEmbedder.Embed(texts)
+}
+
+// failureTree writes one file per body into a new directory, named so they
+// are walked in order.
+func failureTree(t *testing.T, bodies ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for i, body := range bodies {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("f%02d.md", i)), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestIndex_EmbedFailureLimit(t *testing.T) {
+	repeat := func(n int, body string) []string {
+		out := make([]string, n)
+		for i := range out {
+			out[i] = body
+		}
+		return out
+	}
+	// Every fourth file fails: 2 of the first 10 (20%), 3 of 12 (25%).
+	quarter := repeat(12, "good notes")
+	for i := 3; i < len(quarter); i += 4 {
+		quarter[i] = "bad notes"
+	}
+
+	for _, tc := range []struct {
+		name      string
+		bodies    []string
+		limit     EmbedFailureLimit
+		wantAbort bool
+		failed    int // files failed when the run ends
+		embedded  int
+	}{
+		{"in a row", repeat(20, "bad notes"), EmbedFailureLimit{Consecutive: 3}, true, 3, 0},
+		{"default", repeat(20, "bad notes"), DefaultEmbedFailureLimit, true, 10, 0},
+		{"rate", quarter, EmbedFailureLimit{Percent: 20}, true, 3, 9},
+		{"rate within limit", quarter, EmbedFailureLimit{Percent: 25}, false, 3, 9},
+		{"no limit", repeat(15, "bad notes"), EmbedFailureLimit{}, false, 15, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			idx := NewTestIndex(filepath.Join(t.TempDir(), ".sift"), &failingEmbedder{})
+			idx.SetEmbedFailureLimit(tc.limit)
+			idx.BeginRun()
+			err := idx.IndexDir(context.Background(), failureTree(t, tc.bodies...))
+
+			var abort *EmbedAbortError
+			if got := errors.As(err, &abort); got != tc.wantAbort {
+				t.Fatalf("IndexDir = %v, want abort %v", err, tc.wantAbort)
+			}
+			if tc.wantAbort {
+				if !errors.Is(err, ErrEmbedder) || !errors.Is(err, errEmbedBroken) {
+					t.Errorf("%v does not match ErrEmbedder and the embedder's error", err)
+				}
+				if abort.Failed != tc.failed || abort.Tried != tc.failed+tc.embedded {
+					t.Errorf("abort after %d of %d files, want %d of %d", abort.Failed, abort.Tried, tc.failed, tc.failed+tc.embedded)
+				}
+				if !strings.Contains(err.Error(), abort.Last.Path) {
+					t.Errorf("error %q does not name the last failed file", err)
+				}
+			}
+
+			s := idx.RunStats()
+			if s.EmbedFailed != tc.failed || s.FilesErrored != tc.failed || s.FilesEmbedded != tc.embedded {
+				t.Errorf("RunStats: %d embed failures, %d errored, %d embedded; want %d, %d, %d",
+					s.EmbedFailed, s.FilesErrored, s.FilesEmbedded, tc.failed, tc.failed, tc.embedded)
+			}
+			if want := min(tc.failed, embedFailureSamples); len(s.EmbedFailures) != want {
+				t.Fatalf("got %d sample failures, want %d", len(s.EmbedFailures), want)
+			}
+			for _, f := range s.EmbedFailures {
+				if !errors.Is(f.Err, errEmbedBroken) || !strings.HasSuffix(f.Path, ".md") {
+					t.Errorf("sample failure = %+v", f)
+				}
+			}
+
+			idx.BeginRun()
+			if s := idx.RunStats(); s.EmbedFailed != 0 || s.EmbedFailures != nil || idx.EmbedFailing() {
+				t.Errorf("BeginRun left failures: %+v", s)
+			}
+		})
+	}
+}
+
+func TestIndex_EmbedFailing(t *testing.T) {
+	root := failureTree(t, "bad notes", "good notes")
+	idx := NewTestIndex(filepath.Join(t.TempDir(), ".sift"), &failingEmbedder{})
+	if _, err := idx.AddFile(filepath.Join(root, "f00.md")); err != nil {
+		t.Fatal(err)
+	}
+	if !idx.EmbedFailing() {
+		t.Error("EmbedFailing = false after a failure")
+	}
+	if _, err := idx.AddFile(filepath.Join(root, "f01.md")); err != nil {
+		t.Fatal(err)
+	}
+	if idx.EmbedFailing() {
+		t.Error("EmbedFailing = true after a file embedded")
+	}
+}
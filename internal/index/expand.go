@@ -0,0 +1,241 @@
+package index
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Expansion makes searches also try variants of the query that swap one
+// of its words for a synonym, so a chunk that says "authenticate" is
+// found for "login". Every variant is embedded in the same batch as the
+// query and searched like it; the result lists are merged, a variant's
+// scores weighted down by VariantDecay for each variant before it.
+type Expansion struct {
+	// Variants is how many variants a search tries besides the query, at
+	// most MaxVariants; 0 turns expansion off, as it is by default.
+	Variants int
+	// Synonyms are groups of interchangeable lower-case words.
+	Synonyms [][]string
+}
+
+// MaxVariants caps Expansion.Variants, so an expanded search embeds at
+// most this many texts more than a plain one, in one batch.
+const MaxVariants = 4
+
+// VariantDecay weights a variant's scores against the query's: the first
+// variant's are multiplied by it, the second's by its square, and so on,
+// so a chunk found only through a synonym ranks below an equally similar
+// one found through the query's own words.
+const VariantDecay = 0.9
+
+// DefaultSynonyms are groups of words programmers use for the same thing.
+var DefaultSynonyms = [][]string{
+	{"login", "signin", "authenticate"},
+	{"logout", "signout"},
+	{"remove", "delete", "drop"},
+	{"create", "add", "insert"},
+	{"update", "modify", "edit"},
+	{"fetch", "retrieve", "load"},
+	{"save", "store", "persist"},
+	{"start", "launch", "spawn"},
+	{"stop", "terminate", "kill"},
+	{"error", "failure", "exception"},
+	{"config", "configuration", "settings"},
+	{"password", "credential", "secret"},
+	{"find", "search", "lookup"},
+	{"parse", "decode", "unmarshal"},
+	{"serialize", "encode", "marshal"},
+	{"directory", "folder", "dir"},
+	{"function", "method", "func"},
+	{"init", "initialize", "setup"},
+	{"validate", "verify", "check"},
+	{"cache", "memoize"},
+}
+
+// SetExpansion changes how many synonym variants searches try (see
+// Expansion); it is off until set.
+func (idx *Index) SetExpansion(e Expansion) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	e.Variants = min(max(e.Variants, 0), MaxVariants)
+	idx.expansion = e
+	idx.synonyms = make(map[string][]string)
+	for _, group := range e.Synonyms {
+		for _, w := range group {
+			for _, alt := range group {
+				if alt != w && !slices.Contains(idx.synonyms[w], alt) {
+					idx.synonyms[w] = append(idx.synonyms[w], alt)
+				}
+			}
+		}
+	}
+	idx.changedUnderLock()
+}
+
+// expandQuery returns up to Expansion.Variants variants of query, each
+// with one word replaced by a synonym: the first synonym of every word
+// that has one, in query order, then the second, and so on. Must be
+// called with idx.mu held (read).
+func (idx *Index) expandQuery(query string) []string {
+	if idx.expansion.Variants == 0 {
+		return nil
+	}
+	type word struct {
+		start, end int
+		alts       []string
+	}
+	var words []word
+	start := -1
+	for i, r := range query + " " {
+		switch {
+		case isIdentRune(r) && start < 0:
+			start = i
+		case !isIdentRune(r) && start >= 0:
+			if alts := idx.synonyms[strings.ToLower(query[start:i])]; alts != nil {
+				words = append(words, word{start, i, alts})
+			}
+			start = -1
+		}
+	}
+
+	var variants []string
+	seen := map[string]bool{query: true}
+	for n := 0; ; n++ {
+		more := false
+		for _, w := range words {
+			if n >= len(w.alts) {
+				continue
+			}
+			more = true
+			v := query[:w.start] + w.alts[n] + query[w.end:]
+			if seen[v] {
+				continue
+			}
+			seen[v] = true
+			if variants = append(variants, v); len(variants) == idx.expansion.Variants {
+				return variants
+			}
+		}
+		if !more {
+			return variants
+		}
+	}
+}
+
+// embedQueries embeds normalized queries in one batch when the embedder
+// supports it, one by one otherwise, unless ctx is done before or after.
+func (idx *Index) embedQueries(ctx context.Context, queries []string) ([][]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	embedder, err := idx.getEmbedder()
+	if err != nil {
+		return nil, err
+	}
+	prefixed := make([]string, len(queries))
+	for i, q := range queries {
+		prefixed[i] = idx.queryText(q)
+	}
+	var vecs [][]float32
+	if be, ok := embedder.(QueryBatchEmbedder); ok {
+		vecs, err = be.EmbedQueries(prefixed)
+		if err == nil && len(vecs) != len(queries) {
+			err = fmt.Errorf("%d vectors for %d queries", len(vecs), len(queries))
+		}
+	} else {
+		for _, q := range prefixed {
+			var v []float32
+			if v, err = embedder.EmbedQuery(q); err != nil {
+				break
+			}
+			vecs = append(vecs, v)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEmbedQuery, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return vecs, nil
+}
+
+// expandedCandidates scores the candidates of the query, texts[0], and of
+// its variants, the rest, each against its own vector in vecs, and
+// merges them best first: a chunk keeps its best score, a variant's
+// weighted by VariantDecay, with Variant set when a variant gave it. Each
+// text's candidates are the chunks holding its words when it would be
+// planned lexically, its best pool graph hits otherwise; the plan
+// returned is the query's. Must be called with idx.mu held (read).
+func (idx *Index) expandedCandidates(ctx context.Context, texts []string, vecs [][]float32, k int, f Filter, pool int) ([]SearchResult, string, error) {
+	best := make(map[string]SearchResult)
+	plan := QueryPlanHNSW
+	weight := float32(1)
+	for j, text := range texts {
+		hits := idx.lexicalHits(text, vecs[j], k, f)
+		switch {
+		case hits != nil && j == 0:
+			plan = QueryPlanLexical
+		case hits == nil:
+			var err error
+			if hits, err = idx.graph.SearchContext(ctx, vecs[j], pool); err != nil {
+				return nil, plan, err
+			}
+		}
+		scored, err := idx.scoreHits(ctx, text, hits, f)
+		if err != nil {
+			return nil, plan, err
+		}
+		if len(texts) == 1 {
+			return scored, plan, nil // in scoreHits' order, as a search ranks them
+		}
+		for _, r := range scored {
+			if j > 0 {
+				r.Score *= weight
+				r.Vector *= weight
+				r.Keyword *= weight
+				if r.Calibrated != nil {
+					c := *r.Calibrated * weight
+					r.Calibrated = &c
+				}
+				r.Variant = text
+			}
+			if prev, ok := best[r.Meta.ID]; !ok || r.Score > prev.Score {
+				best[r.Meta.ID] = r
+			}
+		}
+		weight *= VariantDecay
+	}
+	merged := slices.Collect(maps.Values(best))
+	slices.SortStableFunc(merged, func(a, b SearchResult) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Meta.ID, b.Meta.ID)
+	})
+	return merged, plan, nil
+}
+
+// searchExpanded ranks the merged candidates of query and its variants
+// (see expandedCandidates) as searchVec ranks a query's. When that leaves
+// fewer than k results, as a filter can, the query is searched alone,
+// widening as searchVec does, so expansion never returns fewer results
+// than a plain search. Must be called with idx.mu held (read).
+func (idx *Index) searchExpanded(ctx context.Context, texts []string, vecs [][]float32, k int, f Filter) ([]SearchResult, string, error) {
+	f, fetchK := idx.prepareSearch(k, f)
+	if fetchK == 0 {
+		return nil, QueryPlanHNSW, nil
+	}
+	merged, plan, err := idx.expandedCandidates(ctx, texts, vecs, k, f, fetchK)
+	if err != nil {
+		return nil, plan, err
+	}
+	if results := idx.pickResults(merged, k, f); len(results) >= k {
+		return results, plan, nil
+	}
+	return idx.searchVec(ctx, texts[0], vecs[0], k, f)
+}
@@ -0,0 +1,106 @@
+package index
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// batchEmbedder is wordEmbedder embedding queries in batches, recording
+// each batch.
+type batchEmbedder struct {
+	wordEmbedder
+	batches *[][]string
+}
+
+func (e batchEmbedder) EmbedQueries(queries []string) ([][]float32, error) {
+	*e.batches = append(*e.batches, slices.Clone(queries))
+	return e.Embed(queries)
+}
+
+func TestExpandQuery(t *testing.T) {
+	idx := NewTestIndex(t.TempDir(), wordEmbedder{})
+	idx.SetExpansion(Expansion{Variants: 3, Synonyms: [][]string{{"login", "signin", "authenticate"}, {"user", "account"}}})
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Every word's first synonym, then the second, and so on.
+	want := []string{"how does signin pick the user?", "how does Login pick the account?", "how does authenticate pick the user?"}
+	if got := idx.expandQuery("how does Login pick the user?"); !slices.Equal(got, want) {
+		t.Errorf("expandQuery = %q, want %q", got, want)
+	}
+	if got := idx.expandQuery("oauth token"); got != nil {
+		t.Errorf("expandQuery without synonyms = %q, want none", got)
+	}
+	idx.expansion.Variants = 1
+	if got := idx.expandQuery("login_user login"); !slices.Equal(got, []string{"login_user signin"}) {
+		t.Errorf("expandQuery, capped at 1 = %q; identifiers are not split", got)
+	}
+}
+
+func TestSearch_Expansion(t *testing.T) {
+	var batches [][]string
+	idx, dir := dirsFixture(t, map[string]string{
+		"vpn.md":    "wireguard",
+		"tunnel.md": "tunnel tunnel garden",
+		"tea.md":    "kettle",
+	})
+	idx.embedder = batchEmbedder{batches: &batches}
+	ctx := context.Background()
+	search := func(query string) []SearchResult {
+		t.Helper()
+		res, err := idx.Search(ctx, query, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	plain := search("tunnel")[0] // what the variant alone finds
+
+	idx.SetExpansion(Expansion{Variants: 1, Synonyms: [][]string{{"wireguard", "tunnel", "garden"}}})
+	batches = nil
+	res := search("wireguard")
+	if len(res) != 2 || filepath.Base(res[0].Meta.Path) != "vpn.md" || res[0].Variant != "" {
+		t.Fatalf("expanded search = %+v, want vpn.md first, through the query", res)
+	}
+	// tunnel.md is found only through the variant, its scores weighted
+	// down by VariantDecay.
+	got := res[1]
+	if filepath.Base(got.Meta.Path) != "tunnel.md" || got.Variant != "tunnel" {
+		t.Fatalf("second result %s via %q, want tunnel.md via \"tunnel\"", got.Meta.Path, got.Variant)
+	}
+	for _, s := range [][2]float32{{got.Score, plain.Score}, {got.Vector, plain.Vector}, {got.Keyword, plain.Keyword}} {
+		if math.Abs(float64(s[0]-VariantDecay*s[1])) > 1e-6 {
+			t.Errorf("variant scores %+v, want %v × %+v", got, VariantDecay, plain)
+		}
+	}
+	// The cap: one variant, embedded in one batch with the query.
+	if want := [][]string{{idx.queryText("wireguard"), idx.queryText("tunnel")}}; !slices.EqualFunc(batches, want, slices.Equal) {
+		t.Errorf("embedded %q, want %q", batches, want)
+	}
+
+	// Explain names the variant that surfaced each chunk.
+	ex, err := idx.Explain("wireguard", filepath.Join(dir, "tunnel.md"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ex.Variants, []string{"tunnel"}) || ex.Rank != 2 || ex.Chunks[0].Variant != "tunnel" || ex.Ahead[0].Variant != "" {
+		t.Errorf("Explain = %+v", ex)
+	}
+
+	// Variants past MaxVariants are never searched, and 0 turns
+	// expansion off.
+	idx.SetExpansion(Expansion{Variants: 10, Synonyms: [][]string{{"wireguard", "a", "b", "c", "d", "e", "f"}}})
+	batches = nil
+	search("wireguard")
+	if len(batches) != 1 || len(batches[0]) != 1+MaxVariants {
+		t.Errorf("embedded %q, want the query and %d variants", batches, MaxVariants)
+	}
+	idx.SetExpansion(Expansion{Synonyms: DefaultSynonyms})
+	batches = nil
+	if res := search("wireguard"); len(batches) != 0 || res[1].Variant != "" {
+		t.Errorf("expansion off: embedded %q, results %+v", batches, res)
+	}
+}
@@ -0,0 +1,140 @@
+package index
+
+import "context"
+
+// Explanation breaks down how one file ranks for a query; see Explain.
+type Explanation struct {
+	Query string `json:"query"`
+	Path  string `json:"path"`
+	// BoostWords lists the query words that can earn the keyword boost,
+	// stopwords left out; empty when the query has too few of them to be
+	// boosted at all (see KeywordPolicy).
+	BoostWords []string `json:"boost_words"`
+	// Indexed reports whether the index holds any chunk of Path at all.
+	Indexed bool `json:"indexed"`
+	// Plan is the query plan a search would follow: QueryPlanLexical
+	// scores every chunk holding a query word, whatever the pool, and
+	// QueryPlanHNSW searches the graph.
+	Plan string `json:"plan"`
+	// Variants are the synonym variants of the query searched with it
+	// (see SetExpansion); empty when expansion is off.
+	Variants []string `json:"variants,omitempty"`
+	// Candidates is the number of chunks scored, and Files the number of
+	// distinct files among them.
+	Candidates int `json:"candidates"`
+	Files      int `json:"files"`
+	// Rank is Path's rank after per-file dedup (as search shows it), and
+	// ChunkRank that of its best chunk before dedup; both 1-based, 0 when
+	// Path is not among the candidates.
+	Rank      int `json:"rank"`
+	ChunkRank int `json:"chunk_rank"`
+	// Chunks holds Path's candidate chunks, best first.
+	Chunks []ChunkScore `json:"chunks"`
+	// Ahead holds the best chunk of every file ranked above Path (all
+	// candidate files when Path is not among them).
+	Ahead []ChunkScore `json:"ahead"`
+}
+
+// ChunkScore is one scored candidate chunk: Score = Vector + Keyword.
+type ChunkScore struct {
+	ID         string  `json:"id"`
+	Path       string  `json:"path"`
+	Line       int     `json:"line"`
+	ChunkIndex int     `json:"chunk_index"`
+	Rank       int     `json:"rank"` // among all candidates, before dedup
+	Score      float32 `json:"score"`
+	Vector     float32 `json:"vector"`
+	Keyword    float32 `json:"keyword"`
+	// Matched lists the query words that earned the keyword boost, and
+	// MatchedTokens the word of the chunk each matched (see terms.go).
+	Matched       []string `json:"matched"`
+	MatchedTokens []string `json:"matched_tokens"`
+	// Variant is the variant of the query that surfaced the chunk, "" for
+	// the query itself (see SearchResult.Variant).
+	Variant string `json:"variant,omitempty"`
+}
+
+// Explain runs query like Search but over a candidate pool of pool chunks
+// (every chunk when pool <= 0) and reports how path ranks: its chunks'
+// score breakdowns, its rank before and after per-file dedup, and the
+// files that beat it. A query a search would plan lexically is ranked
+// over the chunks holding its words instead of the pool. With expansion
+// on, the query's synonym variants are ranked with it, as a search ranks
+// them.
+func (idx *Index) Explain(query, path string, pool int) (*Explanation, error) {
+	query = NormalizeQuery(query)
+	if query == "" {
+		return nil, ErrEmptyQuery
+	}
+	idx.mu.RLock()
+	texts := append([]string{query}, idx.expandQuery(query)...)
+	idx.mu.RUnlock()
+	vecs, err := idx.embedQueries(context.Background(), texts)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	target := idx.pathKey(normPath(path))
+
+	ex := &Explanation{Query: query, Path: path, BoostWords: idx.boostWords(query), Plan: QueryPlanHNSW, Variants: texts[1:], Chunks: []ChunkScore{}, Ahead: []ChunkScore{}}
+	if ex.BoostWords == nil {
+		ex.BoostWords = []string{}
+	}
+	for _, c := range idx.liveChunks() {
+		if idx.pathKey(c.Path) == target {
+			ex.Indexed = true
+			break
+		}
+	}
+	if pool <= 0 || pool > len(idx.chunks) {
+		pool = len(idx.chunks)
+	}
+	if pool == 0 {
+		return ex, nil
+	}
+
+	scored, plan, err := idx.expandedCandidates(context.Background(), texts, vecs, explainK, Filter{}, pool)
+	if err != nil {
+		return nil, err
+	}
+	ex.Plan = plan
+	ex.Candidates = len(scored)
+	seen := make(map[string]bool)
+	for i, r := range scored {
+		cs := ChunkScore{
+			ID:            r.Meta.ID,
+			Path:          r.Meta.Path,
+			Line:          r.Meta.LineNum,
+			ChunkIndex:    r.Meta.ChunkIndex,
+			Rank:          i + 1,
+			Score:         r.Score,
+			Vector:        r.Vector,
+			Keyword:       r.Keyword,
+			Matched:       r.Matched,
+			MatchedTokens: r.MatchedTokens,
+			Variant:       r.Variant,
+		}
+		if cs.Matched == nil {
+			cs.Matched, cs.MatchedTokens = []string{}, []string{}
+		}
+		key := idx.pathKey(r.Meta.Path)
+		isTarget := key == target
+		if isTarget {
+			ex.Chunks = append(ex.Chunks, cs)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		switch {
+		case isTarget:
+			ex.Rank, ex.ChunkRank = len(seen), cs.Rank
+		case ex.Rank == 0:
+			ex.Ahead = append(ex.Ahead, cs)
+		}
+	}
+	ex.Files = len(seen)
+	return ex, nil
+}
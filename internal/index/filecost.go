@@ -0,0 +1,86 @@
+package index
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// FileCost is what embedding one file took: the time from chunking it to
+// inserting its last chunk, and how many chunks it made.
+type FileCost struct {
+	Path     string        `json:"path"`
+	Duration time.Duration `json:"duration_ns"`
+	Chunks   int           `json:"chunks"`
+}
+
+// slowestKept is how many of the slowest files a run's RunStats.Slowest
+// and the manifest's history keep.
+const slowestKept = 50
+
+// clock times files for FileCost and ages cached search results; tests
+// replace it.
+var clock = time.Now
+
+// costLog keeps a run's slowest files; see insertCost.
+type costLog struct {
+	mu      sync.Mutex
+	slowest []FileCost
+}
+
+// insertCost adds c to costs, kept slowest first and at most slowestKept
+// long, replacing any earlier cost of the same file, and returns the
+// result. Most files are faster than the slowest kept and cost one
+// comparison.
+func insertCost(costs []FileCost, c FileCost) []FileCost {
+	if len(costs) == slowestKept && c.Duration <= costs[len(costs)-1].Duration &&
+		!slices.ContainsFunc(costs, func(o FileCost) bool { return o.Path == c.Path }) {
+		return costs
+	}
+	costs = slices.DeleteFunc(costs, func(o FileCost) bool { return o.Path == c.Path })
+	i, _ := slices.BinarySearchFunc(costs, c.Duration, func(o FileCost, d time.Duration) int {
+		// Descending: ties go after the ones already there.
+		if o.Duration >= d {
+			return -1
+		}
+		return 1
+	})
+	costs = slices.Insert(costs, i, c)
+	if len(costs) > slowestKept {
+		costs = costs[:slowestKept]
+	}
+	return costs
+}
+
+// noteCostUnderLock records c, a file just embedded, in the run's slowest
+// files and the manifest's. Must be called with idx.mu held.
+func (idx *Index) noteCostUnderLock(c FileCost) {
+	r := idx.counter()
+	r.costs.mu.Lock()
+	r.costs.slowest = insertCost(r.costs.slowest, c)
+	r.costs.mu.Unlock()
+	if idx.manifest != nil {
+		idx.manifest.Slowest = insertCost(idx.manifest.Slowest, c)
+	}
+}
+
+// SlowestFiles returns the n slowest files to embed that are still
+// indexed, slowest first, as recorded over the index's runs (each file's
+// latest cost; at most 50 are kept). n <= 0 returns all of them.
+func (idx *Index) SlowestFiles(n int) []FileCost {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if idx.manifest == nil {
+		return nil
+	}
+	var out []FileCost
+	for _, c := range idx.manifest.Slowest {
+		if n > 0 && len(out) == n {
+			break
+		}
+		if _, ok := idx.byFile[idx.pathKey(c.Path)]; ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
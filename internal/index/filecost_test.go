@@ -0,0 +1,106 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clockedEmbedder embeds like mockEmbedder and advances *now by a
+// millisecond per byte embedded, so a file's cost follows its size.
+type clockedEmbedder struct {
+	mockEmbedder
+	now *time.Time
+}
+
+func (e *clockedEmbedder) Embed(texts []string) ([][]float32, error) {
+	for _, s := range texts {
+		*e.now = e.now.Add(time.Duration(len(s)) * time.Millisecond)
+	}
+	return e.mockEmbedder.Embed(texts)
+}
+
+func TestIndex_SlowestFiles(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock = func() time.Time { return now }
+	t.Cleanup(func() { clock = time.Now })
+
+	root := t.TempDir()
+	idx := NewTestIndex(filepath.Join(root, ".sift"), &clockedEmbedder{now: &now})
+	sizes := map[string]int{"small.md": 10, "big.md": 300, "medium.md": 100}
+	for name, n := range sizes {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(strings.Repeat("x", n)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx.BeginRun()
+	if err := idx.IndexDir(t.Context(), root); err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(costs []FileCost) []string {
+		var out []string
+		for _, c := range costs {
+			out = append(out, fmt.Sprintf("%s %v/%d", filepath.Base(c.Path), c.Duration, c.Chunks))
+		}
+		return out
+	}
+	want := []string{"big.md 300ms/1", "medium.md 100ms/1", "small.md 10ms/1"}
+	if got := names(idx.RunStats().Slowest); !slices.Equal(got, want) {
+		t.Errorf("RunStats().Slowest = %q, want %q", got, want)
+	}
+	if got := names(idx.SlowestFiles(2)); !slices.Equal(got, want[:2]) {
+		t.Errorf("SlowestFiles(2) = %q, want %q", got, want[:2])
+	}
+
+	// The history outlives the run and the process, listing each file's
+	// latest cost and only files still indexed.
+	big := filepath.Join(root, "big.md")
+	if err := os.WriteFile(big, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx.BeginRun()
+	if _, err := idx.AddFile(big); err != nil {
+		t.Fatal(err)
+	}
+	if got := names(idx.RunStats().Slowest); !slices.Equal(got, []string{"big.md 1ms/1"}) {
+		t.Errorf("second run's Slowest = %q, want only big.md", got)
+	}
+	idx.pruneFiles([]string{filepath.Join(root, "small.md")})
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := load(idx.dir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(again.SlowestFiles(0)), []string{"medium.md 100ms/1", "big.md 1ms/1"}; !slices.Equal(got, want) {
+		t.Errorf("SlowestFiles after reload = %q, want %q", got, want)
+	}
+}
+
+func TestInsertCost(t *testing.T) {
+	var costs []FileCost
+	for i := range slowestKept + 10 {
+		costs = insertCost(costs, FileCost{Path: fmt.Sprint(i), Duration: time.Duration(i%20) * time.Second})
+	}
+	if len(costs) != slowestKept {
+		t.Fatalf("kept %d costs, want %d", len(costs), slowestKept)
+	}
+	if !slices.IsSortedFunc(costs, func(a, b FileCost) int { return int(b.Duration - a.Duration) }) {
+		t.Errorf("costs not slowest first: %v", costs)
+	}
+	if costs[0].Duration != 19*time.Second || costs[len(costs)-1].Duration != 3*time.Second {
+		t.Errorf("kept %v to %v, want 19s down to 3s", costs[0].Duration, costs[len(costs)-1].Duration)
+	}
+
+	// A file already kept is replaced by its new cost, even a faster one.
+	costs = insertCost(costs, FileCost{Path: costs[0].Path, Duration: time.Millisecond})
+	if len(costs) != slowestKept || costs[len(costs)-1].Duration != time.Millisecond || costs[0].Duration != 19*time.Second {
+		t.Errorf("after re-costing the slowest file: %d kept, %v to %v", len(costs), costs[0].Duration, costs[len(costs)-1].Duration)
+	}
+}
@@ -0,0 +1,118 @@
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"time"
+)
+
+// The skip cache records the version of each file the index holds: its
+// mtime, size, change time, inode and a hash of its bytes when it was
+// indexed. If none of the first four changed, the file is skipped without
+// being read; otherwise its bytes are hashed, and only a different hash
+// embeds it again. A checkout that rewrites files as they were, a touch
+// or a restored backup thus costs hashing, not inference. A tool that
+// keeps the mtime (cp -p, rsync -t, tar) still changes the ctime, which
+// no one can set, and usually the inode. Where the platform has neither
+// (Windows), the mtime and size alone decide, so an edit that kept both
+// is missed. Files indexed before
+// hashes were recorded have neither size nor hash: the next run reads and
+// hashes them, keeping their embeddings if their mtime is as indexed (all
+// that such a version can tell) and embedding them again otherwise.
+
+// PhaseHash times reading and hashing files whose mtime or size changed.
+const PhaseHash = "hash"
+
+// fileVersion is the version of a file the index holds.
+type fileVersion struct {
+	mtime time.Time
+	size  int64
+	ctime int64  // see statChange; 0 if unknown
+	inode uint64 // see statChange; 0 if unknown
+	hash  string // fileHash of its bytes; "" if unknown
+}
+
+// versionOf returns the version of its file c was indexed from.
+func versionOf(c ChunkMeta) fileVersion {
+	return fileVersion{mtime: c.Mtime, size: c.FileSize, ctime: c.FileCtime, inode: c.FileInode, hash: c.FileHash}
+}
+
+// setVersion records v as the version of c's file.
+func (c *ChunkMeta) setVersion(v fileVersion) {
+	c.Mtime, c.FileSize, c.FileCtime, c.FileInode, c.FileHash = v.mtime, v.size, v.ctime, v.inode, v.hash
+}
+
+// sameStat reports whether info shows the file as it was at v, so it
+// need not be read. A version without a hash never does, so that the file
+// gets one, and neither does one recorded without the ctime and inode the
+// platform has.
+func (v fileVersion) sameStat(info os.FileInfo) bool {
+	ctime, inode := statChange(info)
+	return v.hash != "" && v.mtime.Equal(info.ModTime()) && v.size == info.Size() &&
+		v.ctime == ctime && v.inode == inode
+}
+
+// holds reports whether the file, found at version now, still holds what
+// was indexed at v: the same bytes or, for a version without a hash, the
+// same mtime.
+func (v fileVersion) holds(now fileVersion) bool {
+	if v.hash == "" {
+		return v.mtime.Equal(now.mtime)
+	}
+	return v.hash == now.hash
+}
+
+// fileHash identifies a file by its bytes: the first 128 bits of their
+// SHA-256, in hex.
+func fileHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// sameContent reports whether the file at path, with info, still holds
+// what was indexed at v (see holds), reading it only when v has a hash.
+func sameContent(path string, info os.FileInfo, v fileVersion) bool {
+	if v.hash == "" {
+		return v.holds(fileVersion{mtime: info.ModTime()})
+	}
+	data, err := os.ReadFile(path)
+	return err == nil && fileHash(data) == v.hash
+}
+
+// retouch records path, which still holds what was indexed, as at version
+// v: its stat changed (by a checkout, say), or it had no hash yet, but its
+// chunks and embeddings stand. It reports false, changing nothing, if
+// path was indexed anew meanwhile.
+func (idx *Index) retouch(path string, v fileVersion) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	key := idx.pathKey(path)
+	cached, ok := idx.fileCache[key]
+	if !ok || !cached.holds(v) {
+		return false
+	}
+	if ids := idx.byFile[key]; len(ids) > 0 && idx.chunks[ids[0]].FileHash != v.hash {
+		idx.dropHashUnderLock(key, idx.chunks[ids[0]].FileHash)
+		idx.addHashUnderLock(key, v.hash)
+	}
+	for _, id := range idx.byFile[key] {
+		idx.chunks[id].setVersion(v)
+	}
+	idx.fileCache[key] = v
+	idx.changedUnderLock()
+	idx.dirty = true
+	idx.log.Debugf("unchanged %s (same content, new version recorded)", path)
+	return true
+}
+
+// countHashed counts the files among chunks whose version has a hash.
+func countHashed(chunks []ChunkMeta) int {
+	seen := make(map[string]bool)
+	for _, c := range chunks {
+		if c.FileHash != "" && !seen[c.Path] {
+			seen[c.Path] = true
+		}
+	}
+	return len(seen)
+}
@@ -0,0 +1,164 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestIndex_ContentHashSkip(t *testing.T) {
+	root := failureTree(t, "wireguard tunnel notes", "systemd unit notes")
+	a, b := filepath.Join(root, "f00.md"), filepath.Join(root, "f01.md")
+	dir := filepath.Join(root, ".sift")
+	e := &countingEmbedder{}
+	idx := NewTestIndex(dir, e)
+	idx.SetLogger(nil)
+	if err := idx.IndexDir(context.Background(), root); err != nil {
+		t.Fatal(err)
+	}
+	embedded := e.n
+
+	// A checkout or touch moves the mtime but not the content: hashed,
+	// not embedded, and recorded at the new mtime.
+	later := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(a, later, later); err != nil {
+		t.Fatal(err)
+	}
+	idx.BeginRun()
+	if skipped, err := idx.AddFile(a); err != nil || !skipped {
+		t.Fatalf("AddFile(touched) = %v, %v; want skipped", skipped, err)
+	}
+	if e.n != embedded {
+		t.Errorf("touched file embedded again (%d texts)", e.n-embedded)
+	}
+	if r := idx.RunStats(); r.Phases[PhaseHash] == 0 {
+		t.Error("hashing not timed")
+	}
+	if got := idx.chunks[idx.byFile[idx.pathKey(a)][0]].Mtime; !got.Equal(later) {
+		t.Errorf("chunk mtime = %v, want the new %v", got, later)
+	}
+	if results, err := idx.Search(context.Background(), "wireguard", 1); err != nil || len(MarkStale(results)) != 0 {
+		t.Errorf("touched file's results marked stale: %v", err)
+	}
+
+	// An edit that kept the mtime still changes the size.
+	info, err := os.Stat(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("systemd unit notes, revised"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(b, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if skipped, err := idx.AddFile(b); err != nil || skipped || e.n == embedded {
+		t.Errorf("AddFile(edited, same mtime) = %v, %v after %d texts; want it embedded", skipped, err, e.n-embedded)
+	}
+
+	// The hashes are saved in meta.json, and count in Stats.
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := OpenWith(Options{Dir: dir, Embedder: e, MaxFileKB: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer got.Close()
+	if s := got.Stats(); s.HashedFiles != 2 || s.NumFiles != 2 {
+		t.Errorf("HashedFiles = %d of %d files, want 2 of 2", s.HashedFiles, s.NumFiles)
+	}
+	embedded = e.n
+	if err := os.Chtimes(b, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if skipped, err := got.AddFile(b); err != nil || !skipped || e.n != embedded {
+		t.Errorf("reopened AddFile(touched) = %v, %v; want skipped without embedding", skipped, err)
+	}
+
+	// Forgetting the skip cache forgets the hashes: all is embedded anew.
+	got.ResetSkipCache()
+	if skipped, err := got.AddFile(b); err != nil || skipped {
+		t.Errorf("AddFile after ResetSkipCache = %v, %v; want it embedded", skipped, err)
+	}
+}
+
+func TestIndex_ContentHashKeptMtime(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("no ctime or inode on " + runtime.GOOS)
+	}
+	root := failureTree(t, "wireguard tunnel notes")
+	path := filepath.Join(root, "f00.md")
+	e := &countingEmbedder{}
+	idx := NewTestIndex(filepath.Join(root, ".sift"), e)
+	idx.SetLogger(nil)
+	if _, err := idx.AddFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaced with as many bytes and the old mtime, as cp -p or rsync -t
+	// leave a file: the ctime and inode still tell.
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte("systemd service notes!"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(tmp, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	n := e.n
+	if skipped, err := idx.AddFile(path); err != nil || skipped || e.n == n {
+		t.Errorf("AddFile(replaced, same mtime and size) = %v, %v; want it embedded", skipped, err)
+	}
+	n = e.n
+	if skipped, err := idx.AddFile(path); err != nil || !skipped || e.n != n {
+		t.Errorf("AddFile(unchanged) = %v, %v; want skipped", skipped, err)
+	}
+}
+
+func TestIndex_ContentHashLegacy(t *testing.T) {
+	root := failureTree(t, "wireguard tunnel notes")
+	path := filepath.Join(root, "f00.md")
+	e := &countingEmbedder{}
+	idx := NewTestIndex(filepath.Join(root, ".sift"), e)
+	idx.SetLogger(nil)
+	if _, err := idx.AddFile(path); err != nil {
+		t.Fatal(err)
+	}
+	// As indexed before hashes were recorded: the mtime alone decides,
+	// and the file is hashed from then on.
+	key := idx.pathKey(path)
+	legacy := func() {
+		v := idx.fileCache[key]
+		v.size, v.hash = 0, ""
+		idx.fileCache[key] = v
+	}
+	legacy()
+	n := e.n
+	if skipped, err := idx.AddFile(path); err != nil || !skipped || e.n != n {
+		t.Errorf("AddFile(unchanged legacy) = %v, %v; want skipped without embedding", skipped, err)
+	}
+	if idx.fileCache[key].hash == "" || idx.chunks[idx.byFile[key][0]].FileHash == "" {
+		t.Error("no hash recorded for the unchanged legacy file")
+	}
+	legacy()
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if skipped, err := idx.AddFile(path); err != nil || skipped || e.n == n {
+		t.Errorf("AddFile(touched legacy) = %v, %v; want it embedded, and hashed from then on", skipped, err)
+	}
+	if idx.fileCache[key].hash == "" {
+		t.Error("no hash recorded on re-embedding")
+	}
+}
@@ -0,0 +1,18 @@
+//go:build darwin
+
+package index
+
+import (
+	"os"
+	"syscall"
+)
+
+// statChange returns info's change time, in nanoseconds, and inode, or
+// zeros if info does not have them.
+func statChange(info os.FileInfo) (ctime int64, ino uint64) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+	return st.Ctimespec.Nano(), st.Ino
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package index
+
+import "os"
+
+// statChange is not implemented on this platform: only the mtime and size
+// tell whether a file changed.
+func statChange(os.FileInfo) (ctime int64, ino uint64) {
+	return 0, 0
+}
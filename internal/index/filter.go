@@ -0,0 +1,116 @@
+package index
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Filter restricts search results by file. The zero value matches everything,
+// subject to the index's per-directory cap.
+type Filter struct {
+	// Exts keeps only files with one of these extensions ("go" or ".go").
+	Exts []string
+	// PathPrefix keeps only files whose path starts with this prefix:
+	// their absolute path if it is absolute, else their path relative to
+	// the index root.
+	PathPrefix string
+	// Dirs keeps only files under one of these directories: absolute, or
+	// relative to the index root. Unlike PathPrefix, "docs" does not
+	// match docs-old/, and case is ignored where the filesystem ignores it.
+	Dirs []string
+	// Types keeps only files of one of these categories ("tests",
+	// "docs"); see ChunkMeta.Category.
+	Types []string
+	// MaxPerDir caps how many results may share a parent directory; 0
+	// uses the index's default (see SetMaxPerDir).
+	MaxPerDir int
+	// MaxPerFile is how many chunks of one file may be returned; 0 means
+	// one. A chunk whose byte range overlaps a better chunk of the same
+	// file is left out, as it shows the same lines.
+	MaxPerFile int
+}
+
+// IsZero reports whether f matches every path and caps nothing.
+func (f Filter) IsZero() bool {
+	return len(f.Exts) == 0 && f.PathPrefix == "" && len(f.Dirs) == 0 && len(f.Types) == 0 && f.MaxPerDir == 0 && f.MaxPerFile <= 1
+}
+
+// Match reports whether path passes the filter.
+func (f Filter) Match(path string) bool {
+	if f.PathPrefix != "" {
+		prefix := strings.TrimPrefix(filepath.Clean(f.PathPrefix), "./")
+		if !strings.HasPrefix(strings.TrimPrefix(path, "./"), prefix) {
+			return false
+		}
+	}
+	if len(f.Exts) == 0 {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range f.Exts {
+		e = strings.ToLower(e)
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// passes reports whether chunk c passes f: its path, its directory and
+// its category. Must be called with idx.mu held (read).
+func (idx *Index) passes(f Filter, c ChunkMeta) bool {
+	if !f.Match(idx.filterPath(f, c.Path)) || !idx.inDirs(f, c.Path) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	cat := idx.category(c)
+	return slices.ContainsFunc(f.Types, func(t string) bool { return strings.EqualFold(t, cat) })
+}
+
+// filterPath returns the form of the indexed path that f's PathPrefix is
+// matched against.
+func (idx *Index) filterPath(f Filter, path string) string {
+	if f.PathPrefix == "" || filepath.IsAbs(f.PathPrefix) {
+		return path
+	}
+	return idx.relPath(path)
+}
+
+// inDirs reports whether the indexed path lies under one of f.Dirs, or
+// f has none.
+func (idx *Index) inDirs(f Filter, path string) bool {
+	if len(f.Dirs) == 0 {
+		return true
+	}
+	key := idx.pathKey(path)
+	for _, d := range f.Dirs {
+		if !filepath.IsAbs(d) {
+			d = filepath.Join(idx.root, d)
+		}
+		d = idx.pathKey(filepath.Clean(d))
+		if !strings.HasSuffix(d, string(filepath.Separator)) {
+			d += string(filepath.Separator)
+		}
+		if strings.HasPrefix(key, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMaxPerDir sets how many results a search may return from one parent
+// directory when its Filter does not say (0, the default, is unlimited).
+// Results over the cap give way to the next-best from other directories,
+// which are marked Promoted.
+func (idx *Index) SetMaxPerDir(n int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.maxPerDir = n
+	idx.changedUnderLock()
+}
@@ -0,0 +1,74 @@
+package index
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// chunkFile is the part of *os.File used to read chunk text back.
+type chunkFile interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// openChunkFile opens a chunk's source file for FullText and FullTexts;
+// tests replace it to count opens or simulate a slow filesystem.
+var openChunkFile = func(path string) (chunkFile, error) { return os.Open(path) }
+
+// FullText returns the chunk's complete text: Text itself unless it was
+// truncated at index time, in which case the chunk's byte range is read
+// back from the file. On error it returns Text along with the error.
+//
+// Search itself never reads files; only callers that show whole chunks do.
+func (c ChunkMeta) FullText() (string, error) {
+	if !c.Truncated {
+		return c.Text, nil
+	}
+	f, err := openChunkFile(c.Path)
+	if err != nil {
+		return c.Text, err
+	}
+	defer f.Close()
+	return c.readFrom(f)
+}
+
+// FullTexts reads back the complete text of every truncated chunk in
+// chunks, in place, clearing Truncated; each file is opened once however
+// many of its chunks need reading. Chunks that cannot be read keep their
+// stored preview and stay Truncated.
+func FullTexts(chunks []ChunkMeta) {
+	byPath := make(map[string][]int)
+	var paths []string
+	for i, c := range chunks {
+		if !c.Truncated {
+			continue
+		}
+		if _, ok := byPath[c.Path]; !ok {
+			paths = append(paths, c.Path)
+		}
+		byPath[c.Path] = append(byPath[c.Path], i)
+	}
+	for _, p := range paths {
+		f, err := openChunkFile(p)
+		if err != nil {
+			continue
+		}
+		for _, i := range byPath[p] {
+			if text, err := chunks[i].readFrom(f); err == nil {
+				chunks[i].Text, chunks[i].Truncated = text, false
+			}
+		}
+		f.Close()
+	}
+}
+
+// readFrom reads the chunk's byte range from its open source file.
+func (c ChunkMeta) readFrom(f io.ReaderAt) (string, error) {
+	buf := make([]byte, c.EndByte-c.StartByte)
+	if _, err := f.ReadAt(buf, c.StartByte); err != nil {
+		return c.Text, fmt.Errorf("read %s: %w", c.Path, err)
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
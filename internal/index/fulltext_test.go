@@ -0,0 +1,111 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// truncatedChunks writes files files of perFile 100-byte chunks under dir
+// and returns their metadata as stored with a 10-character preview.
+func truncatedChunks(t testing.TB, dir string, files, perFile int) []ChunkMeta {
+	var chunks []ChunkMeta
+	for f := range files {
+		path := filepath.Join(dir, fmt.Sprintf("doc%d.md", f))
+		var body strings.Builder
+		for c := range perFile {
+			text := fmt.Sprintf("file %d chunk %d ", f, c)
+			body.WriteString(text + strings.Repeat("x", 99-len(text)) + "\n")
+			chunks = append(chunks, ChunkMeta{
+				Path: path, ChunkIndex: c, StartByte: int64(c * 100), EndByte: int64(c*100 + 99),
+				Text: text[:10], Truncated: true,
+			})
+		}
+		if err := os.WriteFile(path, []byte(body.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chunks
+}
+
+// countOpens wraps openChunkFile for the test, counting opens and
+// sleeping delay in each, like a network filesystem.
+func countOpens(t testing.TB, delay time.Duration) *int {
+	opens := new(int)
+	orig := openChunkFile
+	openChunkFile = func(path string) (chunkFile, error) {
+		*opens++
+		time.Sleep(delay)
+		return orig(path)
+	}
+	t.Cleanup(func() { openChunkFile = orig })
+	return opens
+}
+
+func TestFullTexts(t *testing.T) {
+	chunks := truncatedChunks(t, t.TempDir(), 3, 4)
+	chunks = append(chunks, ChunkMeta{Path: "/nonexistent.md", Text: "kept", Truncated: true})
+	opens := countOpens(t, 0)
+
+	FullTexts(chunks)
+	if *opens != 4 {
+		t.Errorf("opened files %d times for 4 distinct files", *opens)
+	}
+	for _, c := range chunks[:12] {
+		want := fmt.Sprintf("file %s chunk %d", strings.TrimSuffix(strings.TrimPrefix(filepath.Base(c.Path), "doc"), ".md"), c.ChunkIndex)
+		if c.Truncated || !strings.HasPrefix(c.Text, want) || len(c.Text) != 99 {
+			t.Errorf("chunk %s#%d = %q (truncated %v)", c.Path, c.ChunkIndex, c.Text, c.Truncated)
+		}
+	}
+	if last := chunks[12]; last.Text != "kept" || !last.Truncated {
+		t.Errorf("unreadable chunk = %+v, want its preview kept", last)
+	}
+}
+
+func TestSearchReadsNoFiles(t *testing.T) {
+	dir := t.TempDir()
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), &mockEmbedder{})
+	idx.SetPreview(10)
+	for i := range 5 {
+		p := filepath.Join(dir, fmt.Sprintf("doc%d.md", i))
+		if err := os.WriteFile(p, []byte("wireguard config lives here, and more besides"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opens := countOpens(t, 0)
+	if results, err := idx.Search(context.Background(), "wireguard config", 5); err != nil || len(results) != 5 {
+		t.Fatalf("Search = %d results, %v", len(results), err)
+	}
+	if *opens != 0 {
+		t.Errorf("Search opened %d files; ranking must use the stored text", *opens)
+	}
+}
+
+// BenchmarkReadBack reads back 50 truncated chunks from 5 files on a
+// filesystem that takes 1ms per open: one open per chunk versus one per
+// file.
+func BenchmarkReadBack(b *testing.B) {
+	chunks := truncatedChunks(b, b.TempDir(), 5, 10)
+	countOpens(b, time.Millisecond)
+	b.Run("per-chunk", func(b *testing.B) {
+		for range b.N {
+			for _, c := range chunks {
+				c.FullText()
+			}
+		}
+	})
+	b.Run("grouped", func(b *testing.B) {
+		buf := make([]ChunkMeta, len(chunks))
+		for range b.N {
+			copy(buf, chunks)
+			FullTexts(buf)
+		}
+	})
+}
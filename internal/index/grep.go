@@ -0,0 +1,132 @@
+package index
+
+import (
+	"cmp"
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// GrepMatch is a chunk whose text matches a Grep pattern.
+type GrepMatch struct {
+	Meta ChunkMeta
+	// Score is the cosine similarity between the chunk and the query Grep
+	// ranks by; unlike a search score, it has no keyword boost.
+	Score float32
+	// Lines holds the chunk's matching lines that no better chunk of the
+	// same file holds too, in order.
+	Lines []GrepLine
+}
+
+// GrepLine is one line matching a Grep pattern.
+type GrepLine struct {
+	Num  int
+	Text string
+	// Spans holds the byte ranges of Text that matched, as [start, end)
+	// pairs.
+	Spans [][2]int `json:",omitempty"`
+}
+
+// Grep finds the chunks passing f whose text matches re, then ranks them
+// by their similarity to about, embedded as a query: the pattern finds
+// the occurrences, the query says which matter. It returns the best k
+// (every match when k <= 0), best first. A line held by two overlapping
+// chunks is reported for the better one only.
+//
+// Unlike Search, Grep reads every chunk: the text of those stored
+// truncated (see SetPreview) is read back from their files.
+func (idx *Index) Grep(ctx context.Context, re *regexp.Regexp, about string, k int, f Filter) ([]GrepMatch, error) {
+	about = NormalizeQuery(about)
+	if about == "" {
+		return nil, ErrEmptyQuery
+	}
+	queryVec, err := idx.embedQuery(ctx, about)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var chunks []ChunkMeta
+	var ids []uint32
+	for id, c := range idx.chunks {
+		if !c.removed && idx.passes(f, c) {
+			chunks = append(chunks, idx.withText(c))
+			ids = append(ids, uint32(id))
+		}
+	}
+	FullTexts(chunks)
+
+	var matches []GrepMatch
+	for i, c := range chunks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		lines := grepLines(re, c)
+		if len(lines) == 0 {
+			continue
+		}
+		vec := idx.graph.GetNodeVec(ids[i])
+		if vec == nil {
+			continue
+		}
+		var score float32
+		for j := range vec {
+			score += vec[j] * queryVec[j]
+		}
+		matches = append(matches, GrepMatch{Meta: c, Score: score, Lines: lines})
+	}
+	slices.SortStableFunc(matches, func(a, b GrepMatch) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Or(cmp.Compare(a.Meta.Path, b.Meta.Path), cmp.Compare(a.Meta.LineNum, b.Meta.LineNum))
+	})
+
+	// Overlapping chunks share lines; keep each with the better chunk.
+	seen := make(map[string]map[int]bool)
+	out := matches[:0]
+	for _, m := range matches {
+		key := idx.pathKey(m.Meta.Path)
+		if seen[key] == nil {
+			seen[key] = make(map[int]bool)
+		}
+		m.Lines = slices.DeleteFunc(m.Lines, func(l GrepLine) bool { return seen[key][l.Num] })
+		if len(m.Lines) == 0 {
+			continue
+		}
+		for _, l := range m.Lines {
+			seen[key][l.Num] = true
+		}
+		out = append(out, m)
+		if len(out) == k {
+			break
+		}
+	}
+	return out, nil
+}
+
+// grepLines returns the lines of c's text matching re, numbered from the
+// chunk's first line.
+func grepLines(re *regexp.Regexp, c ChunkMeta) []GrepLine {
+	if !re.MatchString(c.Text) {
+		return nil
+	}
+	var lines []GrepLine
+	for i, line := range strings.Split(c.Text, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		locs := re.FindAllStringIndex(line, -1)
+		if len(locs) == 0 {
+			continue
+		}
+		l := GrepLine{Num: c.LineNum + i, Text: line}
+		for _, loc := range locs {
+			if loc[1] > loc[0] {
+				l.Spans = append(l.Spans, [2]int{loc[0], loc[1]})
+			}
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
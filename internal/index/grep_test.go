@@ -0,0 +1,85 @@
+package index
+
+import (
+	"context"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	idx, dir := dirsFixture(t, map[string]string{
+		"net/conn.go":   "wireguard tunnel\nconn.Close() // connection reset by peer\nreturn err",
+		"garden/log.md": "garden kettle\nthe kettle said: connection reset by peer, connection reset by peer",
+		"notes.md":      "garden\nnothing to see",
+	})
+	grep := func(pattern, about string, f Filter) []GrepMatch {
+		t.Helper()
+		m, err := idx.Grep(context.Background(), regexp.MustCompile(pattern), about, 0, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+	paths := func(ms []GrepMatch) []string {
+		var out []string
+		for _, m := range ms {
+			rel, _ := filepath.Rel(dir, m.Meta.Path)
+			out = append(out, filepath.ToSlash(rel))
+		}
+		return out
+	}
+	literal := regexp.QuoteMeta("connection reset by peer")
+
+	// The query decides the order of the same matches.
+	if got := paths(grep(literal, "garden", Filter{})); len(got) != 2 || got[0] != "garden/log.md" || got[1] != "net/conn.go" {
+		t.Errorf("about garden: %q, want log.md then conn.go", got)
+	}
+	ms := grep(literal, "wireguard tunnel", Filter{})
+	if got := paths(ms); len(got) != 2 || got[0] != "net/conn.go" {
+		t.Fatalf("about wireguard: %q, want conn.go first", got)
+	}
+	if ms[0].Score <= ms[1].Score {
+		t.Errorf("scores %v, %v; want decreasing", ms[0].Score, ms[1].Score)
+	}
+	if l := ms[0].Lines; len(l) != 1 || l[0].Num != 2 || l[0].Text != "conn.Close() // connection reset by peer" || len(l[0].Spans) != 1 || l[0].Spans[0] != [2]int{16, 40} {
+		t.Errorf("conn.go lines %+v, want line 2 with the match at 16–40", l)
+	}
+	if l := ms[1].Lines; len(l) != 1 || len(l[0].Spans) != 2 {
+		t.Errorf("log.md lines %+v, want one line matching twice", l)
+	}
+
+	// A regular expression, and filters.
+	if got := paths(grep(`conn\w*\.Close\(\)`, "garden", Filter{})); len(got) != 1 || got[0] != "net/conn.go" {
+		t.Errorf("regexp: %q, want conn.go alone", got)
+	}
+	if got := paths(grep(literal, "wireguard", Filter{Dirs: []string{"garden"}})); len(got) != 1 || got[0] != "garden/log.md" {
+		t.Errorf("in garden/: %q, want log.md alone", got)
+	}
+	if got := grep("no such text", "garden", Filter{}); len(got) != 0 {
+		t.Errorf("unmatched pattern: %+v, want nothing", got)
+	}
+	if m, err := idx.Grep(context.Background(), regexp.MustCompile("garden"), "garden", 1, Filter{}); err != nil || len(m) != 1 {
+		t.Errorf("k=1: %d matches (%v), want 1", len(m), err)
+	}
+}
+
+func TestGrep_OverlappingChunks(t *testing.T) {
+	idx, _ := dirsFixture(t, nil)
+	idx.mu.Lock()
+	// Two chunks of a file sharing line 3, as overlapping chunks do.
+	for i, text := range []string{"garden\nline two\nshared match", "shared match\nline four"} {
+		idx.chunks = append(idx.chunks, ChunkMeta{ID: string(rune('a' + i)), Path: "/tree/f.md", LineNum: 1 + 2*i, ChunkIndex: i, Text: text})
+		vec := make([]float32, 384)
+		vec[2-i] = 1 // the first is about the garden
+		idx.graph.Insert(vec)
+	}
+	idx.mu.Unlock()
+	ms, err := idx.Grep(context.Background(), regexp.MustCompile("shared"), "garden", 0, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 || ms[0].Meta.ChunkIndex != 0 || len(ms[0].Lines) != 1 || ms[0].Lines[0].Num != 3 {
+		t.Errorf("matches %+v, want line 3 once, from the better chunk", ms)
+	}
+}
@@ -0,0 +1,115 @@
+package index
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// CorruptError is returned when an index artifact cannot be loaded or
+// fails the health check on open, and cannot be repaired in place. It
+// matches ErrCorrupt.
+type CorruptError struct {
+	// Artifact is the file within the index directory, such as "hnsw.bin".
+	Artifact string
+	Err      error
+}
+
+func (e *CorruptError) Error() string {
+	return fmt.Sprintf("%v: %s — run `sift index` to rebuild: %v", ErrCorrupt, e.Artifact, e.Err)
+}
+
+func (e *CorruptError) Unwrap() []error { return []error{ErrCorrupt, e.Err} }
+
+// Heal records the repairs made when an index was opened in an
+// inconsistent state. The next Flush saves it in the manifest, where
+// `sift doctor` reports it.
+type Heal struct {
+	Time    time.Time `json:"time"`
+	Actions []string  `json:"actions"`
+}
+
+// spotChecks is how many evenly spaced graph nodes checkHealth reads.
+const spotChecks = 32
+
+// noteRepair records a repair made while loading: it is logged, marks the
+// index dirty so the next Flush saves it, and shows in Stats.Repaired and,
+// once saved, in the manifest's LastHeal.
+func (idx *Index) noteRepair(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if idx.heal == nil {
+		idx.heal = &Heal{Time: time.Now()}
+	}
+	idx.heal.Actions = append(idx.heal.Actions, msg)
+	idx.dirty = true
+	idx.log.Warnf("%s: %s", idx.dir, msg)
+}
+
+// checkHealth runs the fast consistency checks on a freshly loaded index,
+// after reconcile has brought graph and metadata to the same length:
+// every chunk names its file, a sample of vectors has the manifest's
+// dimension and finite, non-zero values, and summary.json is readable. A
+// bad summary is repaired (the next Flush rewrites it); anything else is
+// returned as a *CorruptError naming the artifact.
+func (idx *Index) checkHealth() error {
+	for i, c := range idx.chunks {
+		switch {
+		case c.Path == "":
+			return &CorruptError{metaFile, fmt.Errorf("chunk %d has no path", i)}
+		case c.EndByte < c.StartByte || c.ChunkIndex < 0:
+			return &CorruptError{metaFile, fmt.Errorf("chunk %d of %s has bytes %d–%d, index %d",
+				i, c.Path, c.StartByte, c.EndByte, c.ChunkIndex)}
+		}
+	}
+
+	if n := idx.graph.Len(); n > 0 {
+		step := max(1, n/spotChecks)
+		for id := 0; id < n; id += step {
+			if err := idx.checkVector(id); err != nil {
+				return err
+			}
+		}
+		if err := idx.checkVector(n - 1); err != nil {
+			return err
+		}
+	}
+
+	if len(idx.chunks) > 0 {
+		switch _, err := ReadSummary(idx.dir); {
+		case os.IsNotExist(err):
+			idx.noteRepair("%s was missing; it is rewritten on the next save", summaryFile)
+		case err != nil:
+			idx.noteRepair("%s was unreadable; it is rewritten on the next save", summaryFile)
+		}
+	}
+	return nil
+}
+
+// checkVector checks node id's vector against the manifest.
+func (idx *Index) checkVector(id int) error {
+	vec := idx.graph.GetNodeVec(uint32(id))
+	if dim := idx.manifest.Dim; dim > 0 && len(vec) != dim {
+		return &CorruptError{hnswFile, fmt.Errorf("node %d has %d dimensions, the manifest says %d", id, len(vec), dim)}
+	}
+	var sum float64
+	for _, x := range vec {
+		sum += float64(x) * float64(x)
+	}
+	switch {
+	case math.IsNaN(sum) || math.IsInf(sum, 0):
+		return &CorruptError{hnswFile, fmt.Errorf("node %d holds a non-finite value", id)}
+	case sum == 0:
+		return &CorruptError{hnswFile, fmt.Errorf("node %d is a zero vector", id)}
+	}
+	return nil
+}
+
+// repairedString joins the repairs made on load for Stats.Repaired.
+func (idx *Index) repairedString() string {
+	if idx.heal == nil {
+		return ""
+	}
+	return strings.Join(idx.heal.Actions, "; ")
+}
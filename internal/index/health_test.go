@@ -0,0 +1,142 @@
+package index
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/hnsw"
+)
+
+// healthFixture indexes two files into dir/.sift, saves it, and returns
+// the index directory.
+func healthFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := NewTestIndex(siftDir, wordEmbedder{})
+	for name, body := range map[string]string{"a.md": "wireguard", "b.md": "tunnel"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return siftDir
+}
+
+func TestLoad_SelfHeal(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		damage func(t *testing.T, dir string)
+		action string // in the recorded repair
+	}{
+		{"summary missing", func(t *testing.T, dir string) {
+			os.Remove(filepath.Join(dir, summaryFile))
+		}, "summary.json was missing"},
+		{"summary unreadable", func(t *testing.T, dir string) {
+			os.WriteFile(filepath.Join(dir, summaryFile), []byte("{"), 0o644)
+		}, "summary.json was unreadable"},
+		{"manifest missing", func(t *testing.T, dir string) {
+			os.Remove(filepath.Join(dir, manifestFile))
+		}, "manifest.json was missing"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := healthFixture(t)
+			tc.damage(t, dir)
+
+			idx, err := load(dir, "", fullGraph)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := idx.Stats(); !strings.Contains(s.Repaired, tc.action) || !idx.dirty {
+				t.Fatalf("Repaired = %q (dirty %v), want it to mention %q", s.Repaired, idx.dirty, tc.action)
+			}
+			if len(idx.chunks) != 2 || idx.graph.Len() != 2 {
+				t.Errorf("repair lost data: %d chunks, %d nodes", len(idx.chunks), idx.graph.Len())
+			}
+			if err := idx.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			m, err := ReadManifest(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.LastHeal == nil || !slices.ContainsFunc(m.LastHeal.Actions, func(a string) bool {
+				return strings.Contains(a, tc.action)
+			}) {
+				t.Errorf("manifest LastHeal = %+v, want %q recorded", m.LastHeal, tc.action)
+			}
+			again, err := load(dir, "", fullGraph)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.heal != nil {
+				t.Errorf("repair was not saved: reopening repaired %q", again.repairedString())
+			}
+			if again.Manifest().LastHeal == nil {
+				t.Error("LastHeal was lost on reopening")
+			}
+		})
+	}
+}
+
+func TestLoad_Unrepairable(t *testing.T) {
+	saveGraph := func(t *testing.T, dir string, vecs ...[]float32) {
+		t.Helper()
+		g := hnsw.New(hnsw.DefaultM, hnsw.DefaultEfConstruction, hnsw.DefaultEfSearch)
+		for _, v := range vecs {
+			g.Insert(v)
+		}
+		if err := g.Save(filepath.Join(dir, hnswFile)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unit := func(dim, axis int) []float32 {
+		v := make([]float32, dim)
+		v[axis] = 1
+		return v
+	}
+	for _, tc := range []struct {
+		name     string
+		damage   func(t *testing.T, dir string)
+		artifact string
+		detail   string
+	}{
+		{"wrong dimension", func(t *testing.T, dir string) {
+			saveGraph(t, dir, unit(8, 0), unit(8, 1))
+		}, hnswFile, "node 0 has 8 dimensions, the manifest says 384"},
+		{"zero vector", func(t *testing.T, dir string) {
+			saveGraph(t, dir, unit(384, 0), make([]float32, 384))
+		}, hnswFile, "node 1 is a zero vector"},
+		{"chunk without path", func(t *testing.T, dir string) {
+			p := filepath.Join(dir, metaFile)
+			data, _ := os.ReadFile(p)
+			data = []byte(strings.Replace(string(data), `"path": "`, `"path": "", "was": "`, 1))
+			os.WriteFile(p, data, 0o644)
+		}, metaFile, "chunk 0 has no path"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := healthFixture(t)
+			tc.damage(t, dir)
+
+			_, err := OpenReadOnly(dir)
+			var ce *CorruptError
+			if !errors.Is(err, ErrCorrupt) || !errors.As(err, &ce) {
+				t.Fatalf("OpenReadOnly = %v, want a *CorruptError", err)
+			}
+			if ce.Artifact != tc.artifact || !strings.Contains(err.Error(), tc.detail) {
+				t.Errorf("error = %v (artifact %q), want %s: %s", err, ce.Artifact, tc.artifact, tc.detail)
+			}
+		})
+	}
+}
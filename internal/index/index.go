@@ -5,18 +5,23 @@ package index
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tejas242/sift/internal/chunker"
 	"github.com/tejas242/sift/internal/embed"
 	"github.com/tejas242/sift/internal/hnsw"
+	"github.com/tejas242/sift/internal/ignore"
+	"github.com/tejas242/sift/internal/logging"
 )
 
 const (
@@ -25,123 +30,986 @@ const (
 	metaFile    = "meta.json"
 )
 
+// ErrNoIndex is returned when a command needs an existing index but dir has none.
+var ErrNoIndex = errors.New("no index found — run `sift index <dir>` first")
+
+// ErrIndexInRoot is returned when asked to index a directory that contains
+// the index directory in a non-hidden location; the walk would index the
+// index's own files.
+var ErrIndexInRoot = errors.New("index directory is inside the indexed root")
+
+// ErrCorrupt wraps failures to load an existing index's files.
+var ErrCorrupt = errors.New("index corrupt")
+
+// ErrUnsupported is returned by ReindexFile, and by IndexDir for a file
+// root, for a file type sift does not index.
+var ErrUnsupported = errors.New("unsupported file type")
+
+// ErrNotIndexed is returned by RemoveFile for a path the index holds
+// nothing of.
+var ErrNotIndexed = errors.New("not in the index")
+
+// ErrEmbedder wraps failures to load the embedding model or ONNX Runtime.
+var ErrEmbedder = errors.New("embedder")
+
+// ErrEmbedQuery wraps failures to embed a search query with a loaded model.
+var ErrEmbedQuery = errors.New("embed query")
+
+// ErrEmptyQuery is returned for a query with nothing but whitespace in it.
+var ErrEmptyQuery = errors.New("empty query")
+
+// NormalizeQuery returns query as the index searches for it: trimmed, with
+// each run of whitespace collapsed to one space. A query it makes empty
+// fails with ErrEmptyQuery.
+func NormalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// Exists reports whether dir holds a previously flushed index.
+func Exists(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, metaFile))
+	return err == nil
+}
+
 // ChunkMeta stores provenance for each indexed chunk.
 type ChunkMeta struct {
+	// ID identifies the chunk across compaction and re-indexing, unlike
+	// its position in the index; an unchanged chunk keeps its ID. Look it
+	// up with GetChunk.
+	ID string `json:"id,omitempty"`
+	// Path is the file's absolute path; meta.json stores it relative to
+	// the index root when inside it (see paths.go).
 	Path       string    `json:"path"`
 	LineNum    int       `json:"line_num"`
 	StartByte  int64     `json:"start_byte"`
 	EndByte    int64     `json:"end_byte"`
 	ChunkIndex int       `json:"chunk_index"`
-	Text       string    `json:"text"` // preview (first 200 chars)
+	Text       string    `json:"text"` // chunk text, or its preview if Truncated
 	Mtime      time.Time `json:"mtime"`
+	// Truncated is set when the index stored only the first part of the
+	// chunk's text (see SetPreview); FullText reads the rest back.
+	Truncated bool `json:"truncated,omitempty"`
+	// EmbedTruncated is set when the chunk was longer than the embedder's
+	// maximum input, so only its beginning was embedded (see
+	// TruncatingEmbedder): text past that cannot be found by meaning.
+	EmbedTruncated bool `json:"embed_truncated,omitempty"`
+	// Sampled is set when the chunk's file was over the size limit and
+	// only a sample of its chunks was indexed (see OversizedPolicy): the
+	// rest of the file cannot be found.
+	Sampled bool `json:"sampled,omitempty"`
+	// Lang is the language the chunk's text was detected as, as an ISO
+	// 639-1 code ("de", "ja"), or "" for English and text too short to
+	// tell; see lang.Detect.
+	Lang string `json:"lang,omitempty"`
+	// Category is the kind of file the chunk is from, as its path
+	// classified when it was indexed: tests, docs, config, source,
+	// other, or a category of the project's own; see CategoryRule.
+	Category string `json:"category,omitempty"`
+	// FileHash identifies the bytes of the chunk's file as indexed, and
+	// FileSize is their length; with Mtime, FileCtime (the file's change
+	// time in nanoseconds) and FileInode they are the version of the file
+	// the skip cache holds (see filehash.go). The hash also lets a file
+	// moved take over its chunks at its new path without being embedded
+	// again; see reuseMoved. They are empty in indexes made before they
+	// were recorded, until the next index run hashes the file, and ctime
+	// and inode are on platforms without them.
+	FileHash  string `json:"file_hash,omitempty"`
+	FileSize  int64  `json:"file_size,omitempty"`
+	FileCtime int64  `json:"file_ctime,omitempty"`
+	FileInode uint64 `json:"file_inode,omitempty"`
+
+	// textAt and textLen locate Text in the index's text.bin when it was
+	// left there (see textstore.go); textLen is 0 when Text holds it.
+	textAt  int64
+	textLen int32
+	// removed is set by RemoveFile: the chunk keeps its ID, hidden, until
+	// Flush drops it (see compactRemovedUnderLock).
+	removed bool
 }
 
 // Stats holds summary information about the current index.
 type Stats struct {
-	NumChunks   int
-	NumFiles    int
+	NumChunks int
+	NumFiles  int
+	// SizeBytes is the size of the index's files on disk, and IndexSizeKB
+	// the same in whole KB.
+	SizeBytes   int64
 	IndexSizeKB int64
 	LastUpdated time.Time
+
+	// Dir is the directory the index is stored in.
+	Dir string
+	// Extensions breaks files and chunks down by lower-cased file extension.
+	Extensions map[string]ExtStats
+	// Artifacts maps each on-disk index file that exists to its size in bytes.
+	Artifacts map[string]int64
+	// HashedFiles counts the files whose content hash the index holds,
+	// as meta.json stores it; the others were indexed before hashes were
+	// recorded. See filehash.go.
+	HashedFiles int
+	// HNSW holds the graph's construction parameters.
+	HNSW hnsw.Params
+	// MetaBytes is the size of meta.json's JSON, before any compression;
+	// MetaCompressed reports whether it is stored gzipped.
+	MetaBytes      int64
+	MetaCompressed bool
+	// Repaired describes the inconsistencies fixed when the index was
+	// loaded (see Heal), or is "" if there were none.
+	Repaired string
+	// MissingFiles lists the indexed files no longer on disk, and
+	// LivenessChecked whether it was filled in; see CheckLiveness.
+	MissingFiles    []string
+	LivenessChecked bool
+	// Oversized counts the files index runs skip for exceeding the size
+	// limit (as of when each was last seen), and OversizedIndexed those
+	// among them with chunks from a higher limit; see PruneOversized.
+	Oversized        int
+	OversizedIndexed int
+	// TruncatedChunks counts the chunks longer than the embedder's
+	// maximum input, and TruncatedFiles the files they are in; see
+	// ChunkMeta.EmbedTruncated.
+	TruncatedChunks int
+	TruncatedFiles  int
+	// SampledFiles counts the files over the size limit of which only a
+	// sample of chunks is indexed; see ChunkMeta.Sampled.
+	SampledFiles int
+	// Languages breaks chunks down by the language they were detected as;
+	// see ChunkMeta.Lang.
+	Languages map[string]LangStats
+	// Categories breaks files and chunks down by category; see
+	// ChunkMeta.Category.
+	Categories map[string]ExtStats
+}
+
+// ExtStats counts the files and chunks sharing one extension.
+type ExtStats struct {
+	Files  int
+	Chunks int
 }
 
+// ArtifactFiles lists the files an index directory may contain.
+var ArtifactFiles = []string{hnswFile, metaFile, textFile, termsFile, vectorsFile, manifestFile, summaryFile, journalFile, RunsFile}
+
+// DataFiles lists the artifacts that hold indexed data. The manifest
+// (model, roots, patterns) is not among them: removing these empties the
+// index but keeps its configuration.
+var DataFiles = []string{hnswFile, metaFile, textFile, termsFile, vectorsFile, summaryFile, journalFile}
+
 // SearchResult is a single result returned from Search.
 type SearchResult struct {
 	Meta  ChunkMeta
 	Score float32
+	// Score breakdown: Score = Vector + Keyword, unless the result was
+	// reranked, when Score = *Rerank.
+	Vector  float32 // cosine similarity between query and chunk
+	Keyword float32 // KeywordBoost per query word found in the chunk, weighted by rarity
+	// Matched lists the query words that earned the keyword boost (see
+	// KeywordPolicy), and MatchedTokens the word of the chunk each was
+	// found in, as written there (OAuthToken for oauth); see terms.go.
+	Matched       []string `json:",omitempty"`
+	MatchedTokens []string `json:",omitempty"`
+	// Rerank is the reranker's score, set by Rerank; nil otherwise.
+	Rerank *float32 `json:",omitempty"`
+	// Calibrated is Score rescaled across the query's candidates when
+	// calibration is on (see SetCalibrate); nil otherwise.
+	Calibrated *float32 `json:",omitempty"`
+	// Promoted is set when the result only made the top k because
+	// better-scoring results from a directory at its MaxPerDir cap were
+	// left out.
+	Promoted bool `json:",omitempty"`
+	// Stale is set by MarkStale when the result's file was modified after
+	// it was indexed.
+	Stale bool `json:",omitempty"`
+	// Variant is the synonym variant of the query the result was found
+	// through, its scores weighted down by VariantDecay; "" when the query
+	// itself found it (see SetExpansion).
+	Variant string `json:",omitempty"`
 }
 
+// KeywordBoost is added to a hit's score for each query word longer than
+// two letters that is a term of the chunk text, stopwords aside (see
+// KeywordPolicy), scaled from 0.5 for a term in every chunk to 2 for one
+// in a single chunk (see terms.go).
+const KeywordBoost = 0.05
+
 // Embedder defines the interface required by the index for generating text embeddings.
+// Both methods embed text as given: the index adds the prefixes the model
+// expects (see SetPrefixes).
 type Embedder interface {
 	Embed(texts []string) ([][]float32, error)
 	EmbedQuery(query string) ([]float32, error)
 	Close()
 }
 
+// QueryBatchEmbedder is implemented by embedders that can embed several
+// queries in one inference call. SearchBatch falls back to EmbedQuery per
+// query when the embedder does not implement it.
+type QueryBatchEmbedder interface {
+	EmbedQueries(queries []string) ([][]float32, error)
+}
+
+// TruncatingEmbedder is implemented by embedders that cut texts longer
+// than their maximum input. EmbedTruncating is Embed that also reports
+// which texts were cut; indexing counts them in RunStats and Stats.
+type TruncatingEmbedder interface {
+	EmbedTruncating(texts []string) (vecs [][]float32, truncated []bool, err error)
+}
+
+// PriorityEmbedder is implemented by embedders that schedule concurrent
+// work (see embed.Priority). EmbedAt is EmbedTruncating with texts
+// embedded at priority p: indexing embeds at that of its context (see
+// WithEmbedPriority), and searches embed their queries with EmbedQuery,
+// ahead of both.
+type PriorityEmbedder interface {
+	EmbedAt(texts []string, p embed.Priority) (vecs [][]float32, truncated []bool, err error)
+}
+
+type embedPriorityKey struct{}
+
+// WithEmbedPriority returns a context under which indexing embeds chunks
+// at priority p, for a PriorityEmbedder; indexing is
+// embed.PriorityManual otherwise. The watcher indexes at
+// embed.PriorityBackground, so a search in the same process does not
+// wait behind its backlog.
+func WithEmbedPriority(ctx context.Context, p embed.Priority) context.Context {
+	return context.WithValue(ctx, embedPriorityKey{}, p)
+}
+
+// embedPriority returns the priority indexing under ctx embeds at.
+func embedPriority(ctx context.Context) embed.Priority {
+	if p, ok := ctx.Value(embedPriorityKey{}).(embed.Priority); ok {
+		return p
+	}
+	return embed.PriorityManual
+}
+
+// embedChunks embeds texts with e at the priority of ctx, reporting which
+// were truncated when e is a TruncatingEmbedder (truncated is nil
+// otherwise).
+func embedChunks(ctx context.Context, e Embedder, texts []string) (vecs [][]float32, truncated []bool, err error) {
+	if pe, ok := e.(PriorityEmbedder); ok {
+		return pe.EmbedAt(texts, embedPriority(ctx))
+	}
+	if te, ok := e.(TruncatingEmbedder); ok {
+		return te.EmbedTruncating(texts)
+	}
+	vecs, err = e.Embed(texts)
+	return vecs, nil, err
+}
+
 // Index is the main index state.
 type Index struct {
 	mu               sync.RWMutex
 	dir              string
+	root             string // absolute parent of dir; see paths.go
+	foldCase         bool   // the filesystem ignores case; see pathKey
 	graph            *hnsw.Graph
-	chunks           []ChunkMeta          // indexed by chunk ID (== HNSW node ID)
-	fileCache        map[string]time.Time // path → mtime of last indexed version
+	chunks           []ChunkMeta            // indexed by chunk ID (== HNSW node ID)
+	fileCache        map[string]fileVersion // pathKey → last indexed version; see filehash.go
+	byFile           map[string][]int       // pathKey → chunk IDs by ChunkIndex; see adjacent.go
+	byID             map[string]int         // ChunkMeta.ID → chunk ID, for live chunks; see adjacent.go
+	byHash           map[string][]string    // FileHash → pathKeys; see moved.go
+	removed          int                    // chunks marked removed; see RemoveFile
 	embedder         Embedder
 	maxFileSizeBytes int64
+	oversizedPolicy  OversizedPolicy
 	dirty            bool
 	lastUpdated      time.Time
+	savedUpdated     time.Time // lastUpdated of the index on disk when idx last loaded or saved it; see summary.go
+	manifest         *Manifest
+	log              *logging.Logger
+	lazy             *lazyEmbedder     // set by Open; nil when embedder is given up front
+	phase            PhaseFunc         // optional per-phase timing hook
+	chunkProgress    ChunkProgressFunc // see SetChunkProgress
+	metaOnly         bool              // opened by OpenMeta: the graph was not loaded
+	readOnly         bool              // see SetReadOnly
+	exclude          []string          // --exclude globs
+	includeOnly      []string          // --include-only globs
+	patternsSet      bool              // SetPatterns was called; see recordSettingsUnderLock
+	preview          int               // runes of chunk text stored; 0 = all
+	redact           *regexp.Regexp    // see SetRedactPattern
+	categories       []CategoryRule    // see SetCategoryRules
+	metaCompression  MetaCompression
+	metaBytes        int64 // size of meta.json's JSON as last read or written
+	metaCompressed   bool  // whether meta.json is stored gzipped
+	heal             *Heal // repairs made on load, or nil
+	graphMode        graphMode
+	texts            *os.File // text.bin, when chunk text was left there; see textstore.go
+	missing          []string // files CheckLiveness found gone
+	livenessChecked  bool
+	run              runCounter
+	reranker         Reranker
+	calibrate        bool // see SetCalibrate
+	maxPerDir        int  // see SetMaxPerDir
+	embedLimit       EmbedFailureLimit
+	throttle         Throttler           // see SetThrottle
+	keywords         KeywordPolicy       // see SetKeywordPolicy
+	expansion        Expansion           // see SetExpansion
+	synonyms         map[string][]string // expansion.Synonyms by word
+	prefixes         embed.Prefixes      // see SetPrefixes
+	stagingFor       *Index              // the index a rebuild stages for; see rebuild.go
+	gen              uint64              // advanced by changedUnderLock; see resultcache.go
+	results          resultCache
+	terms            atomic.Pointer[termIndex] // nil until built; see terms.go
+	termsMu          sync.Mutex                // held while building terms
+	warm             *warmup                   // see Options.Preload
+	firstMu          sync.Mutex                // guards first and searched
+	first            FirstSearch
+	searched         bool
 }
 
-// Open loads (or creates) an index stored in dir.
+// Open loads (or prepares to create) an index stored in dir.
 // modelDir is the path to the BGE-small model directory.
 // ortLibPath is the path to onnxruntime.so; pass "" to use the system default.
 // numThreads controls ONNX intra-op parallelism; 0 = auto (min(NumCPU, 4)).
 // maxFileKB skips files larger than this limit.
 func Open(dir, modelDir, ortLibPath string, numThreads, maxFileKB int) (*Index, error) {
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return nil, fmt.Errorf("mkdir %s: %w", dir, err)
+	return OpenWith(Options{Dir: dir, ModelDir: modelDir, OrtLib: ortLibPath, Threads: numThreads, MaxFileKB: maxFileKB})
+}
+
+// OpenLowMemory is Open for commands that mostly search (search, the TUI,
+// serve): the graph's vectors stay in hnsw.bin and are read as searches
+// reach them, with the most recent few thousand cached, trading a little
+// latency for a much smaller resident set on large indexes. Inserting
+// still works, but index and watch should use Open.
+func OpenLowMemory(dir, modelDir, ortLibPath string, numThreads, maxFileKB int) (*Index, error) {
+	return OpenWith(Options{Dir: dir, ModelDir: modelDir, OrtLib: ortLibPath, Threads: numThreads, MaxFileKB: maxFileKB, LowMemory: true})
+}
+
+// Options says how OpenWith opens an index. The CLI and the public
+// package (pkg/sift) both open indexes through it.
+type Options struct {
+	// Dir is the directory the index is stored in.
+	Dir string
+	// ModelDir, OrtLib and Threads locate and configure the ONNX model,
+	// as for Open; they are unused when Embedder is set.
+	ModelDir string
+	OrtLib   string
+	Threads  int
+	// Sessions is how many inference sessions the model gets, so that
+	// concurrent searches and indexing embed in parallel; 0 means
+	// embed.DefaultSessions. See embed.NewSessions.
+	Sessions int
+	// BackgroundSessions caps how many of them background indexing holds
+	// at once; 0 means all. See WithEmbedPriority.
+	BackgroundSessions int
+	// Embedder, if set, embeds instead of the ONNX model. Close closes it.
+	Embedder Embedder
+	// MaxFileKB skips files larger than this many KB.
+	MaxFileKB int
+	// LowMemory leaves vectors on disk until searches reach them; see
+	// OpenLowMemory.
+	LowMemory bool
+	// Preload reads the files left on disk through once in the
+	// background, so the first search does not fault them in page by
+	// page; see Index.Warmup.
+	Preload bool
+}
+
+// OpenWith loads (or prepares to create) the index o describes.
+func OpenWith(o Options) (*Index, error) {
+	// dir itself is created by the first Flush, so opening never writes,
+	// but to finish a save a crash interrupted (see journal.go).
+
+	// Load existing files before the model so a corrupt index is reported
+	// without paying for ONNX startup.
+	mode := fullGraph
+	if o.LowMemory {
+		mode = lazyGraph
+	}
+	idx, err := load(o.Dir, o.ModelDir, mode)
+	if err != nil {
+		return nil, err
 	}
+	idx.maxFileSizeBytes = int64(o.MaxFileKB) * 1024
+	if o.Preload {
+		idx.startWarmup()
+	}
+	if o.Embedder != nil {
+		idx.embedder = o.Embedder
+		return idx, nil
+	}
+
+	// The model is loaded on first use (or by LoadEmbedder), so commands
+	// that never embed — e.g. `sift index --dry-run` — skip ONNX entirely.
+	idx.lazy = &lazyEmbedder{modelDir: o.ModelDir, ortLibPath: o.OrtLib, numThreads: o.Threads, sessions: o.Sessions, background: o.BackgroundSessions}
+	return idx, nil
+}
+
+// lazyEmbedder holds what is needed to load the model on first use.
+type lazyEmbedder struct {
+	modelDir   string
+	ortLibPath string
+	sessions   int
+	once       sync.Once
+	err        error
+
+	mu         sync.Mutex // guards the rest; see SetEmbedLimits
+	numThreads int
+	background int
+	loaded     Embedder
+}
+
+// LoadEmbedder loads the embedding model now if it has not been loaded
+// yet. Errors wrap ErrEmbedder. Callers that want model failures reported
+// up front (and not on first search) call this right after Open.
+func (idx *Index) LoadEmbedder() error {
+	_, err := idx.getEmbedder()
+	return err
+}
+
+// getEmbedder returns the embedder, loading it on first call.
+func (idx *Index) getEmbedder() (Embedder, error) {
+	if p := idx.stagingFor; p != nil {
+		return p.getEmbedder()
+	}
+	if l := idx.lazy; l != nil {
+		l.once.Do(func() {
+			l.mu.Lock()
+			threads := l.numThreads
+			l.mu.Unlock()
+			e, err := embed.NewSessions(l.modelDir, l.ortLibPath, threads, l.sessions)
+			if err != nil {
+				l.err = fmt.Errorf("%w: %w", ErrEmbedder, err)
+				return
+			}
+			e.SetLogger(idx.log)
+			e.SetPhaseFunc(idx.addPhase)
+			// The limits may have changed while the model loaded.
+			l.mu.Lock()
+			if l.background > 0 {
+				e.SetBackgroundSessions(l.background)
+			}
+			if l.numThreads != threads {
+				if err := e.SetThreads(l.numThreads); err != nil {
+					idx.log.Warnf("embedder: %v", err)
+				}
+			}
+			l.loaded = e
+			l.mu.Unlock()
+			idx.embedder = e
+		})
+		if l.err != nil {
+			return nil, l.err
+		}
+	}
+	if idx.embedder == nil {
+		return nil, ErrNoModel
+	}
+	return idx.embedder, nil
+}
 
-	e, err := embed.New(modelDir, ortLibPath, numThreads)
+// ErrNoModel is returned by operations that need the embedding model on
+// an index opened without it (OpenReadOnly, OpenWithoutModel).
+var ErrNoModel = errors.New("index opened without the embedding model")
+
+// ErrReadOnly is returned by Flush and AddFile on a read-only index; see
+// SetReadOnly.
+var ErrReadOnly = errors.New("index opened read-only")
+
+// OpenReadOnly loads an existing index without loading the embedding model,
+// for commands such as `sift stats` that only inspect it. The index is
+// read-only (see SetReadOnly), and searching fails with ErrNoModel. It
+// returns ErrNoIndex if dir holds no index.
+func OpenReadOnly(dir string) (*Index, error) {
+	idx, err := OpenWithoutModel(dir)
+	if err != nil {
+		return nil, err
+	}
+	idx.readOnly = true
+	return idx, nil
+}
+
+// OpenWithoutModel is OpenReadOnly for commands that edit an index's
+// metadata without embedding anything, such as `sift prune`: Flush
+// writes their changes.
+func OpenWithoutModel(dir string) (*Index, error) {
+	if !Exists(dir) {
+		return nil, ErrNoIndex
+	}
+	idx, err := load(dir, "", fullGraph)
 	if err != nil {
-		return nil, fmt.Errorf("embedder: %w", err)
+		return nil, err
 	}
+	idx.embedder = noModelEmbedder{}
+	return idx, nil
+}
+
+// OpenMeta is a lighter OpenReadOnly for metadata queries (Roots, Stats,
+// FileChunks) that must be fast, such as shell completion: it reads meta.json
+// and the manifest but skips the HNSW graph, so searching finds nothing.
+// It returns ErrNoIndex if dir holds no index.
+func OpenMeta(dir string) (*Index, error) {
+	if !Exists(dir) {
+		return nil, ErrNoIndex
+	}
+	idx, err := load(dir, "", noGraph)
+	if err != nil {
+		return nil, err
+	}
+	idx.embedder = noModelEmbedder{}
+	idx.metaOnly = true
+	idx.readOnly = true
+	return idx, nil
+}
 
+// SetReadOnly makes the index read-only, for commands that only search
+// it: they then work on read-only checkouts and mounts, and never race
+// a writer's files. Flush fails with ErrReadOnly if anything changed in
+// memory, such as a repair made on load, and Close drops those changes
+// without writing; adding files fails with ErrReadOnly. Opening never
+// creates the index directory, so a read-only index is one that exists.
+func (idx *Index) SetReadOnly(readOnly bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.readOnly = readOnly
+}
+
+// graphMode selects how load reads hnsw.bin.
+type graphMode int
+
+const (
+	noGraph   graphMode = iota // skip it (OpenMeta)
+	fullGraph                  // read every vector into memory
+	lazyGraph                  // read vectors on demand (OpenLowMemory)
+)
+
+// load reads the on-disk files of the index in dir. modelDir is hashed into
+// a fresh manifest when the index has none yet. The graph is read only if
+// mode is not noGraph, and then its vectors are read up front or, with
+// lazyGraph, on demand.
+func load(dir, modelDir string, mode graphMode) (*Index, error) {
 	idx := &Index{
-		dir:              dir,
-		embedder:         e,
-		maxFileSizeBytes: int64(maxFileKB) * 1024,
-		graph:            hnsw.New(hnsw.DefaultM, hnsw.DefaultEfConstruction, hnsw.DefaultEfSearch),
+		dir:        dir,
+		root:       indexRoot(dir),
+		foldCase:   caseInsensitive(dir),
+		graph:      hnsw.New(hnsw.DefaultM, hnsw.DefaultEfConstruction, hnsw.DefaultEfSearch),
+		log:        logging.Default(),
+		embedLimit: DefaultEmbedFailureLimit,
+		keywords:   DefaultKeywordPolicy,
+		prefixes:   embed.DefaultPrefixes,
+		results:    resultCache{policy: DefaultResultCache},
+	}
+
+	finished, err := finishSave(dir)
+	if err != nil {
+		return nil, err
 	}
 
-	// Load existing index if present.
 	metaPath := filepath.Join(dir, metaFile)
-	if data, err := os.ReadFile(metaPath); err == nil {
+	data, compressed, err := readMetaFile(metaPath)
+	switch {
+	case err == nil:
 		if err := json.Unmarshal(data, &idx.chunks); err != nil {
-			return nil, fmt.Errorf("corrupt meta.json — run `sift index` to rebuild: %w", err)
+			return nil, &CorruptError{metaFile, err}
 		}
+		fillChunkIDs(idx.chunks)
+		idx.metaBytes, idx.metaCompressed = int64(len(data)), compressed
+	case compressed:
+		return nil, &CorruptError{metaFile, err}
 	}
 
 	hnswPath := filepath.Join(dir, hnswFile)
-	if _, err := os.Stat(hnswPath); err == nil {
-		g, err := hnsw.Load(hnswPath)
+	if _, err := os.Stat(hnswPath); err == nil && mode != noGraph {
+		var g *hnsw.Graph
+		if mode == lazyGraph {
+			g, err = hnsw.LoadLazy(hnswPath, 0)
+		} else {
+			g, err = hnsw.Load(hnswPath)
+		}
 		if err != nil {
-			return nil, fmt.Errorf("corrupt hnsw.bin — run `sift index` to rebuild: %w", err)
+			return nil, &CorruptError{hnswFile, err}
 		}
 		idx.graph = g
 	}
 
-	// Build mtime skip-cache from loaded chunks.
-	idx.fileCache = make(map[string]time.Time, len(idx.chunks))
-	for _, c := range idx.chunks {
-		if existing, ok := idx.fileCache[c.Path]; !ok || c.Mtime.After(existing) {
-			idx.fileCache[c.Path] = c.Mtime
+	m, err := ReadManifest(dir)
+	legacy := true       // paths stored before relPathsVersion
+	textsStored := false // text in text.bin, from textFileVersion
+	switch {
+	case err == nil:
+		idx.manifest = m
+		idx.lastUpdated, idx.savedUpdated = m.Updated, m.Updated
+		legacy = m.FormatVersion < relPathsVersion
+		textsStored = m.FormatVersion >= textFileVersion
+	case os.IsNotExist(err):
+		// New (or pre-manifest) index: record the model it is being built with.
+		var hash string
+		if modelDir != "" {
+			hash, _ = embed.HashModel(modelDir)
+		}
+		idx.manifest = newManifest(hash)
+		if len(idx.chunks) > 0 {
+			idx.noteRepair("%s was missing; recreated it for %s", manifestFile, idx.manifest.Model)
 		}
+	default:
+		return nil, fmt.Errorf("%w — run `sift rebuild` to recreate it", err)
+	}
+	idx.resolvePaths(legacy)
+	if err := idx.loadTexts(mode == fullGraph, textsStored); err != nil {
+		idx.graph.Close()
+		return nil, err
 	}
 
+	// Build the skip-cache from loaded chunks.
+	idx.fileCache = make(map[string]fileVersion, len(idx.chunks))
+	for _, c := range idx.chunks {
+		k := idx.pathKey(c.Path)
+		if existing, ok := idx.fileCache[k]; !ok || c.Mtime.After(existing.mtime) {
+			idx.fileCache[k] = versionOf(c)
+		}
+	}
+	if len(finished) > 0 {
+		idx.noteRepair("finished saving %s, which a crash interrupted", strings.Join(finished, ", "))
+	}
+	idx.graphMode = mode
+	if mode != noGraph {
+		idx.reconcile()
+		idx.dedupChunks()
+		if err := idx.checkHealth(); err != nil {
+			idx.graph.Close()
+			idx.closeTexts()
+			return nil, err
+		}
+		if ti, err := readTerms(dir, idx.chunks); err == nil {
+			idx.terms.Store(ti)
+		}
+	}
+	idx.indexFilesUnderLock()
 	return idx, nil
 }
 
+// reconcile repairs an index whose graph and metadata disagree on the
+// number of chunks, as a crash between writing hnsw.bin and meta.json
+// leaves them. Node i is chunk i and both grow by appending, so the
+// shorter one is trusted and the longer one cut to match: surplus
+// metadata is dropped, and the graph is rebuilt from its first nodes. The
+// files that lost chunks are re-embedded by the next index run. The
+// repair is recorded with noteRepair.
+func (idx *Index) reconcile() {
+	nodes, metas := idx.graph.Len(), len(idx.chunks)
+	if nodes == metas {
+		return
+	}
+	n := min(nodes, metas)
+	if metas > n {
+		for _, c := range idx.chunks[n:] {
+			// A zero mtime makes the next index run re-embed the file.
+			idx.fileCache[idx.pathKey(c.Path)] = fileVersion{}
+		}
+		idx.chunks = idx.chunks[:n]
+		idx.dropTermsUnderLock()
+	} else {
+		p := idx.graph.Params()
+		g := hnsw.New(p.M, p.EfConstruction, p.EfSearch)
+		for id := range n {
+			g.Insert(idx.graph.GetNodeVec(uint32(id)))
+		}
+		idx.graph.Close()
+		idx.graph = g
+	}
+	idx.noteRepair("meta.json had %d chunks but hnsw.bin %d nodes; kept the first %d", metas, nodes, n)
+}
+
+// noModelEmbedder backs indexes opened without the embedding model.
+type noModelEmbedder struct{}
+
+func (noModelEmbedder) Embed([]string) ([][]float32, error)  { return nil, ErrNoModel }
+func (noModelEmbedder) EmbedQuery(string) ([]float32, error) { return nil, ErrNoModel }
+func (noModelEmbedder) Close()                               {}
+
 // NewTestIndex creates an Index for testing purposes with a custom mock embedder.
 func NewTestIndex(dir string, embedder Embedder) *Index {
 	return &Index{
 		dir:              dir,
+		root:             indexRoot(dir),
 		embedder:         embedder,
 		maxFileSizeBytes: 512 * 1024,
 		graph:            hnsw.New(hnsw.DefaultM, hnsw.DefaultEfConstruction, hnsw.DefaultEfSearch),
-		fileCache:        make(map[string]time.Time),
+		graphMode:        fullGraph,
+		fileCache:        make(map[string]fileVersion),
+		manifest:         newManifest(""),
+		log:              logging.Default(),
+		embedLimit:       DefaultEmbedFailureLimit,
+		keywords:         DefaultKeywordPolicy,
+		prefixes:         embed.DefaultPrefixes,
+		results:          resultCache{policy: DefaultResultCache},
 	}
 }
 
-// Close flushes dirty state and releases the embedder.
-func (idx *Index) Close() error {
-	if err := idx.Flush(); err != nil {
+// SetPatterns sets the exclude and include-only globs applied (together with
+// each root's .siftignore) when walking directories, and records them in
+// the manifest, to be saved with the index's next change: applying
+// settings alone never makes an index write itself over a newer one.
+func (idx *Index) SetPatterns(exclude, includeOnly []string) error {
+	if _, err := ignore.New(exclude, includeOnly); err != nil {
 		return err
 	}
-	idx.embedder.Close()
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.exclude, idx.includeOnly, idx.patternsSet = exclude, includeOnly, true
+	if idx.manifest == nil {
+		idx.manifest = newManifest("")
+	}
+	idx.manifest.Exclude, idx.manifest.IncludeOnly = exclude, includeOnly
 	return nil
 }
 
+// ResetSkipCache forgets the version recorded for every indexed file, so the
+// next IndexDir re-embeds everything (after changing chunker options, say)
+// while the current graph keeps answering searches until then. It returns
+// the number of files affected; call Flush to persist the reset.
+func (idx *Index) ResetSkipCache() int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	n := len(idx.fileCache)
+	for i := range idx.chunks {
+		idx.chunks[i].setVersion(fileVersion{})
+	}
+	clear(idx.fileCache)
+	clear(idx.byHash)
+	if n > 0 {
+		idx.changedUnderLock()
+		idx.dirty = true
+	}
+	return n
+}
+
+// SetPreview limits the chunk text stored for files indexed from now on to
+// its first n characters (0 stores it all), and records the limit in the
+// manifest, like SetPatterns. Embeddings always see the whole chunk;
+// keyword boosts and snippets see only the stored text.
+func (idx *Index) SetPreview(n int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.preview = n
+	if idx.manifest == nil {
+		idx.manifest = newManifest("")
+	}
+	idx.manifest.Preview = n
+}
+
+// SetMaxFileKB changes the size above which files are skipped, for a
+// configuration reload while watching.
+func (idx *Index) SetMaxFileKB(kb int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.maxFileSizeBytes = int64(kb) * 1024
+}
+
+// Matcher returns the path filter for rootDir: the globs from SetPatterns
+// merged with rootDir/.siftignore.
+func (idx *Index) Matcher(rootDir string) (*ignore.Matcher, error) {
+	fromFile, err := ignore.ReadFile(filepath.Join(rootDir, ignore.FileName))
+	if err != nil {
+		return nil, err
+	}
+	idx.mu.RLock()
+	exclude := append(append([]string{}, idx.exclude...), fromFile...)
+	includeOnly := idx.includeOnly
+	idx.mu.RUnlock()
+	return ignore.New(exclude, includeOnly)
+}
+
+// PhaseFunc receives the time one indexing phase took.
+type PhaseFunc = embed.PhaseFunc
+
+// Indexing phases reported to a PhaseFunc. PhaseEmbed covers the whole
+// embedding call; embedders that support it (the ONNX one does) also
+// report PhaseTokenize and PhaseInference within it.
+const (
+	PhaseChunk     = "chunk"
+	PhaseEmbed     = "embed"
+	PhaseTokenize  = embed.PhaseTokenize
+	PhaseInference = embed.PhaseInference
+	PhaseInsert    = "insert" // HNSW insertion, including replacing stale chunks
+	PhaseFlush     = "flush"
+)
+
+// phaseSetter is implemented by embedders that report phase timings.
+type phaseSetter interface {
+	SetPhaseFunc(embed.PhaseFunc)
+}
+
+// SetPhaseFunc makes idx report the time spent in each indexing phase to f,
+// for benchmarks. Set it before indexing; nil stops reporting. f may be
+// called with the index locked and must not call back into idx.
+func (idx *Index) SetPhaseFunc(f PhaseFunc) {
+	idx.phase = f
+	if idx.lazy != nil && idx.embedder == nil {
+		return // applied when the model is loaded
+	}
+	if ps, ok := idx.embedder.(phaseSetter); ok {
+		ps.SetPhaseFunc(idx.addPhase)
+	}
+}
+
+// ChunkProgressFunc is told, between the embedding batches of a file too
+// large for one, how many of its total chunks are embedded so far.
+type ChunkProgressFunc func(path string, done, total int)
+
+// SetChunkProgress makes idx report progress within large files to f;
+// nil, the default, prints it on the log's writer instead when logging at
+// info level. Set it before indexing.
+func (idx *Index) SetChunkProgress(f ChunkProgressFunc) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.chunkProgress = f
+}
+
+// timePhase counts the time since start towards phase in RunStats and
+// reports it to the phase hook, if any.
+func (idx *Index) timePhase(phase string, start time.Time) {
+	idx.addPhase(phase, time.Since(start))
+}
+
+// loggerSetter is implemented by embedders that accept an injected logger.
+type loggerSetter interface {
+	SetLogger(*logging.Logger)
+}
+
+// SetLogger routes the index's (and its embedder's) diagnostics to l.
+// A nil logger silences them.
+func (idx *Index) SetLogger(l *logging.Logger) {
+	idx.log = l
+	if idx.lazy != nil && idx.embedder == nil {
+		return // applied when the model is loaded
+	}
+	if ls, ok := idx.embedder.(loggerSetter); ok {
+		ls.SetLogger(l)
+	}
+}
+
+// Logger returns the logger the index writes diagnostics to.
+func (idx *Index) Logger() *logging.Logger {
+	return idx.log
+}
+
+// FileChunks returns the chunks stored for path, ordered by ChunkIndex.
+func (idx *Index) FileChunks(path string) []ChunkMeta {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var out []ChunkMeta
+	for _, id := range idx.byFile[idx.pathKey(normPath(path))] {
+		out = append(out, idx.withText(idx.chunks[id]))
+	}
+	return out
+}
+
+// EachVector calls fn with every chunk and its embedding, in chunk id
+// order, stopping at fn's first error. It streams: nothing is copied, so
+// fn must not modify or retain vec, and must not call back into idx.
+func (idx *Index) EachVector(fn func(id int, meta ChunkMeta, vec []float32) error) error {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	for id, c := range idx.chunks {
+		if c.removed {
+			continue
+		}
+		vec := idx.graph.GetNodeVec(uint32(id))
+		if vec == nil {
+			continue
+		}
+		if err := fn(id, idx.withText(c), vec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Files returns the path of every indexed file, sorted.
+func (idx *Index) Files() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var out []string
+	for _, c := range idx.liveChunks() {
+		out = append(out, c.Path)
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+// Roots returns the directories indexed into idx so far, sorted.
+func (idx *Index) Roots() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if idx.manifest == nil {
+		return nil
+	}
+	return slices.Clone(idx.manifest.Roots)
+}
+
+// addRoot records rootDir in the manifest's Roots.
+func (idx *Index) addRoot(rootDir string) error {
+	abs, err := filepath.Abs(rootDir)
+	if err != nil {
+		return err
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.manifest == nil {
+		idx.manifest = newManifest("")
+	}
+	if i, found := slices.BinarySearch(idx.manifest.Roots, abs); !found {
+		idx.manifest.Roots = slices.Insert(idx.manifest.Roots, i, abs)
+		idx.dirty = true
+	}
+	return nil
+}
+
+// newManifest returns a manifest describing an index built now with the
+// bundled embedding model.
+func newManifest(modelHash string) *Manifest {
+	prefixes := embed.DefaultPrefixes
+	return &Manifest{
+		FormatVersion: FormatVersion,
+		Model:         embed.ModelName,
+		Dim:           embed.EmbeddingDim,
+		ModelHash:     modelHash,
+		Created:       time.Now(),
+		Prefixes:      &prefixes,
+	}
+}
+
+// Manifest returns a copy of the index manifest.
+func (idx *Index) Manifest() Manifest {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if idx.manifest == nil {
+		return *newManifest("")
+	}
+	return *idx.manifest
+}
+
+// Close flushes dirty state, unless the index is read-only, and releases
+// the embedder.
+func (idx *Index) Close() error {
+	idx.stopWarmup()
+	idx.mu.RLock()
+	readOnly := idx.readOnly
+	idx.mu.RUnlock()
+	if !readOnly {
+		if err := idx.Flush(); err != nil {
+			return err
+		}
+	}
+	if idx.embedder != nil {
+		idx.embedder.Close()
+	}
+	if idx.reranker != nil {
+		idx.reranker.Close()
+	}
+	idx.mu.Lock()
+	idx.closeTexts()
+	idx.mu.Unlock()
+	return idx.graph.Close()
+}
+
 // AddFile chunks, embeds, and indexes all chunks from a single file.
 // If the file's mtime matches the cached value it is skipped (already up to date).
+// A file that cannot be read, chunked or embedded is counted in RunStats
+// and skipped, until embedding failures pass the EmbedFailureLimit: then
+// the error is an *EmbedAbortError.
 // ctx is checked between embedding batches: cancelling it stops mid-file.
 func (idx *Index) AddFile(path string) (skipped bool, err error) {
 	return idx.AddFileCtx(context.Background(), path)
@@ -149,50 +1017,139 @@ func (idx *Index) AddFile(path string) (skipped bool, err error) {
 
 // AddFileCtx is like AddFile but respects ctx cancellation between embed batches.
 func (idx *Index) AddFileCtx(ctx context.Context, path string) (skipped bool, err error) {
+	return idx.addFile(ctx, path, true)
+}
+
+// addFile is AddFileCtx. With reuse set, a file new to the index that
+// is an indexed file moved takes over its chunks instead of being
+// embedded (see reuseMoved).
+func (idx *Index) addFile(ctx context.Context, path string, reuse bool) (skipped bool, err error) {
+	idx.mu.RLock()
+	readOnly := idx.readOnly
+	idx.mu.RUnlock()
+	if readOnly {
+		return false, ErrReadOnly
+	}
 	if !chunker.IsSupportedFile(path) {
 		return false, nil
 	}
+	path = normPath(path)
+	key := idx.pathKey(path)
 
 	info, statErr := os.Stat(path)
 	if statErr != nil {
-		fmt.Fprintf(os.Stderr, "skip %s: %v\n", path, statErr)
+		idx.log.Warnf("skip %s: %v", path, statErr)
+		idx.countFile(fileErrored, 0, 0)
 		return false, nil
 	}
 
 	// Skip very large files — they're almost certainly generated data, not
-	// source code or documentation worth indexing chunk by chunk.
-	if info.Size() > idx.maxFileSizeBytes {
-		fmt.Fprintf(os.Stderr, "skip %s: file too large (%d KB > %d KB limit)\n",
-			path, info.Size()/1024, idx.maxFileSizeBytes/1024)
+	// source code or documentation worth indexing chunk by chunk — unless
+	// the oversized policy samples them.
+	idx.mu.RLock()
+	limit := idx.maxFileSizeBytes
+	admitted, sample := idx.admitsUnderLock(path, info.Size())
+	idx.mu.RUnlock()
+	if !admitted {
+		idx.log.Warnf("skip %s: file too large (%d KB > %d KB limit)",
+			path, info.Size()/1024, limit/1024)
+		idx.noteOversized(path, info.Size(), limit)
+		idx.countFile(fileSkipped, 0, 0)
 		return false, nil
 	}
 
 	mtime := info.ModTime()
 
-	// Skip-cache: file at this mtime is already indexed.
+	// Skip-cache: the file is already indexed at this mtime and size, or
+	// failing that, with these contents (see filehash.go).
 	idx.mu.RLock()
-	cachedMtime, inCache := idx.fileCache[path]
+	cached, inCache := idx.fileCache[key]
 	idx.mu.RUnlock()
-	if inCache && cachedMtime.Equal(mtime) {
+	if inCache && cached.sameStat(info) {
+		idx.countFile(fileSkipped, 0, 0)
 		return true, nil
 	}
 
-	chunks, err := chunker.ChunkFile(path, chunker.DefaultOptions())
+	costStart := clock()
+	hashStart := time.Now()
+	data, err := os.ReadFile(path)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "skip %s: chunk error: %v\n", path, err)
+		idx.timePhase(PhaseHash, hashStart)
+		idx.log.Warnf("skip %s: %v", path, err)
+		idx.countFile(fileErrored, 0, 0)
+		return false, nil
+	}
+	version := fileVersion{mtime: mtime, size: int64(len(data)), hash: fileHash(data)}
+	version.ctime, version.inode = statChange(info)
+	idx.timePhase(PhaseHash, hashStart)
+	if inCache && cached.holds(version) && idx.retouch(path, version) {
+		idx.countFile(fileSkipped, 0, 0)
+		return true, nil
+	}
+
+	chunkStart := time.Now()
+	chunks, err := chunker.ChunkBytes(data, path, chunker.DefaultOptions())
+	idx.timePhase(PhaseChunk, chunkStart)
+	if err != nil {
+		idx.log.Warnf("skip %s: chunk error: %v", path, err)
+		idx.countFile(fileErrored, 0, 0)
 		return false, nil
 	}
 	if len(chunks) == 0 {
+		idx.countFile(fileSkipped, 0, 0)
 		return false, nil
 	}
+	sampled := false
+	if sample {
+		keep := sampleChunks(len(chunks), sampleHeadChunks, sampleBudget)
+		if sampled = len(keep) < len(chunks); sampled {
+			idx.log.Warnf("sample %s: file too large (%d KB > %d KB limit), indexing %d of %d chunks",
+				path, info.Size()/1024, limit/1024, len(keep), len(chunks))
+			picked := make([]chunker.Chunk, len(keep))
+			for i, k := range keep {
+				picked[i] = chunks[k]
+			}
+			chunks = picked
+		}
+	}
 
-	base := filepath.Base(path)
-	nChunks := len(chunks)
-	verbose := nChunks > 4 // show chunk progress for files with many chunks
+	if reuse && !inCache {
+		if old := idx.movedFrom(path, version.hash); old != "" && idx.reuseMoved(old, path, chunks, version) {
+			idx.countFile(fileMoved, 0, 0)
+			return false, nil
+		}
+	}
+
+	langs := make([]string, len(chunks))
+	for i, c := range chunks {
+		langs[i] = chunkLang(c.Text)
+	}
+
+	embedder, err := idx.getEmbedder()
+	if err != nil {
+		return false, err
+	}
+	idx.mu.RLock()
+	prefixes := idx.prefixes
+	chunkProgress := idx.chunkProgress
+	custom := idx.redact
+	idx.mu.RUnlock()
 
 	// Embed batch-by-batch so we can: (a) show live progress and (b) check ctx.
-	const batchSize = 4
+	// Batches are large enough for the embedder to group chunks of similar
+	// length into its inference batches.
+	const batchSize = 32
+	nChunks := len(chunks)
+	logged := chunkProgress == nil && nChunks > batchSize && idx.log.Enabled(logging.LevelInfo)
+	progress := idx.log.Writer()
+	if logged {
+		base := filepath.Base(path)
+		chunkProgress = func(_ string, done, total int) {
+			fmt.Fprintf(progress, "\r    embedded chunk %d / %d  %s ", done, total, base)
+		}
+	}
 	vecs := make([][]float32, 0, nChunks)
+	cut := make([]bool, 0, nChunks)
 	for start := 0; start < nChunks; start += batchSize {
 		if ctxErr := ctx.Err(); ctxErr != nil {
 			return false, ctxErr
@@ -203,58 +1160,224 @@ func (idx *Index) AddFileCtx(ctx context.Context, path string) (skipped bool, er
 		}
 		batch := make([]string, end-start)
 		for i, c := range chunks[start:end] {
-			batch[i] = c.Text
-		}
-		if verbose {
-			fmt.Fprintf(os.Stderr, "\r    embedding chunk %d–%d / %d  %s ",
-				start+1, end, nChunks, base)
+			batch[i] = prefixes.DocumentText(c.Text)
 		}
-		batchVecs, embedErr := idx.embedder.Embed(batch)
+		embedStart := time.Now()
+		batchVecs, batchCut, embedErr := embedChunks(ctx, embedder, batch)
+		idx.timePhase(PhaseEmbed, embedStart)
 		if embedErr != nil {
-			if verbose {
-				fmt.Fprintln(os.Stderr, "")
+			if logged && start > 0 {
+				fmt.Fprintln(progress, "")
 			}
-			fmt.Fprintf(os.Stderr, "skip %s: embed error: %v\n", path, embedErr)
-			return false, nil
+			return false, idx.embedFailed(path, embedErr)
+		}
+		if chunkProgress != nil && end < nChunks {
+			chunkProgress(path, end, nChunks)
+		}
+		if batchCut == nil {
+			batchCut = make([]bool, len(batchVecs))
 		}
 		vecs = append(vecs, batchVecs...)
+		cut = append(cut, batchCut...)
 	}
-	if verbose {
-		fmt.Fprintf(os.Stderr, "\r    %-60s\r", "") // clear the chunk line
+	if logged {
+		fmt.Fprintf(progress, "\r    %-60s\r", "") // clear the chunk line
 	}
 
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
+	defer idx.timePhase(PhaseInsert, time.Now())
 
 	// Remove stale/old chunks for this file path before adding new ones
 	idx.removeFileChunksUnderLock(path)
+	if len(idx.chunks) == idx.removed {
+		idx.recordPrefixesUnderLock(prefixes)
+		// An embedder other than the bundled model may not give
+		// EmbeddingDim vectors: record what it gives.
+		if len(vecs) > 0 && idx.manifest.Dim != len(vecs[0]) {
+			idx.manifest.Dim = len(vecs[0])
+			idx.dirty = true
+		}
+	}
 
+	nCut, nRedacted := 0, 0
+	category := idx.classify(path)
 	for i, vec := range vecs {
+		text, n := redactText(chunks[i].Text, custom)
+		nRedacted += n
+		text, truncated := truncateRunes(text, idx.preview)
+		if cut[i] {
+			nCut++
+		}
 		idx.chunks = append(idx.chunks, ChunkMeta{
-			Path:       path,
-			LineNum:    chunks[i].LineNum,
-			StartByte:  chunks[i].StartByte,
-			EndByte:    chunks[i].EndByte,
-			ChunkIndex: chunks[i].Index,
-			Text:       chunks[i].Text,
-			Mtime:      mtime,
+			ID:             chunkID(idx.storedPath(path), chunks[i].Index, chunks[i].Text),
+			Path:           path,
+			LineNum:        chunks[i].LineNum,
+			StartByte:      chunks[i].StartByte,
+			EndByte:        chunks[i].EndByte,
+			ChunkIndex:     chunks[i].Index,
+			Text:           text,
+			Mtime:          mtime,
+			Truncated:      truncated,
+			EmbedTruncated: cut[i],
+			Sampled:        sampled,
+			Lang:           langs[i],
+			Category:       category,
+			FileHash:       version.hash,
+			FileSize:       version.size,
+			FileCtime:      version.ctime,
+			FileInode:      version.inode,
 		})
 		idx.graph.Insert(vec)
+		idx.addFileChunkUnderLock(len(idx.chunks) - 1)
+		idx.addTermsUnderLock(text)
 	}
 
-	idx.fileCache[path] = mtime
+	idx.fileCache[key] = version
+	delete(idx.oversizedRecords(), path)
+	idx.countFile(fileEmbedded, nChunks, info.Size())
+	idx.countTruncated(nCut)
+	idx.countRedacted(nRedacted)
+	idx.countLangs(langs)
+	idx.noteCostUnderLock(FileCost{Path: path, Duration: clock().Sub(costStart), Chunks: nChunks})
+	idx.changedUnderLock()
 	idx.dirty = true
+	idx.log.Debugf("indexed %s (%d chunks)", path, nChunks)
+	if nCut > 0 {
+		idx.log.Debugf("%s: %d of %d chunks truncated by the embedder", path, nCut, nChunks)
+	}
 	idx.lastUpdated = time.Now()
 	return false, nil
 }
 
+// ReindexFile drops path's chunks and skip-cache entry and indexes it
+// again, even if its mtime is unchanged, returning its new chunk count.
+// Unlike AddFile, which quietly skips what it cannot index, it returns an
+// error for a missing, unsupported, or oversized file, or one that could
+// not be chunked or embedded, and ErrReadOnly, changing nothing, on a
+// read-only index; call Flush to persist the result.
+func (idx *Index) ReindexFile(ctx context.Context, path string) (int, error) {
+	idx.mu.RLock()
+	readOnly := idx.readOnly || idx.metaOnly
+	idx.mu.RUnlock()
+	if readOnly {
+		return 0, ErrReadOnly
+	}
+	path = normPath(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		return 0, fmt.Errorf("%s: is a directory", path)
+	}
+	if !chunker.IsSupportedFile(path) {
+		return 0, fmt.Errorf("%s: %w", path, ErrUnsupported)
+	}
+	idx.mu.Lock()
+	limit := idx.maxFileSizeBytes
+	admitted, _ := idx.admitsUnderLock(path, info.Size())
+	if admitted {
+		idx.removeFileChunksUnderLock(path)
+		delete(idx.fileCache, idx.pathKey(path))
+		idx.dirty = true
+	}
+	idx.mu.Unlock()
+	if !admitted {
+		return 0, fmt.Errorf("%s: file too large (%d KB > %d KB limit)", path, info.Size()/1024, limit/1024)
+	}
+
+	// Embedded afresh even when another indexed file, gone from disk,
+	// has the same content.
+	if _, err := idx.addFile(ctx, path, false); err != nil {
+		return 0, err
+	}
+	idx.mu.RLock()
+	_, ok := idx.fileCache[idx.pathKey(path)]
+	idx.mu.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("%s: no chunks indexed (empty, or chunking or embedding failed)", path)
+	}
+	return len(idx.FileChunks(path)), nil
+}
+
+// RemoveFile drops path's chunks, skip-cache entry and any oversized
+// record, so the index forgets it until it is indexed again, and returns
+// how many chunks it had. The file need not exist on disk. A path the
+// index knows nothing of fails with ErrNotIndexed. The chunks and their
+// graph nodes are only marked removed, so removing a file costs the
+// same whatever the size of the index; searches skip them, and Flush,
+// which persists the result, drops them for good.
+func (idx *Index) RemoveFile(path string) (int, error) {
+	path = normPath(path)
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	key := idx.pathKey(path)
+	n := len(idx.byFile[key])
+	if _, cached := idx.fileCache[key]; !cached && n == 0 {
+		return 0, fmt.Errorf("%s: %w", path, ErrNotIndexed)
+	}
+	isPath := func(p string) bool { return idx.pathKey(p) == key }
+	if n > 0 {
+		ids := idx.byFile[key]
+		for _, id := range ids {
+			idx.chunks[id].removed = true
+			idx.graph.Delete(uint32(id))
+			delete(idx.byID, idx.chunks[id].ID)
+		}
+		idx.removed += n
+		idx.dropHashUnderLock(key, idx.chunks[ids[0]].FileHash)
+		delete(idx.byFile, key)
+		idx.dropTermsUnderLock()
+		idx.changedUnderLock()
+	}
+	idx.forgetOversizedUnderLock(isPath)
+	delete(idx.fileCache, key)
+	idx.missing = slices.DeleteFunc(idx.missing, isPath)
+	idx.dirty = true
+	idx.lastUpdated = time.Now()
+	idx.log.Debugf("removed %s (%d chunks)", path, n)
+	return n, nil
+}
+
+// truncateRunes returns the first n runes of s (all of it when n is 0) and
+// whether anything was cut.
+func truncateRunes(s string, n int) (string, bool) {
+	if n <= 0 {
+		return s, false
+	}
+	i := 0
+	for j := range s {
+		if i == n {
+			return s[:j], true
+		}
+		i++
+	}
+	return s, false
+}
+
 // removeFileChunksUnderLock removes all chunks belonging to path from idx.chunks
 // and rebuilds the HNSW graph from the remaining chunks.
 // Must be called with idx.mu held.
 func (idx *Index) removeFileChunksUnderLock(path string) {
+	key := idx.pathKey(path)
+	idx.removeChunksUnderLock(func(p string) bool { return idx.pathKey(p) == key })
+}
+
+// removeChunksUnderLock removes the chunks of every file for which drop
+// returns true, rebuilding the HNSW graph from the rest only once.
+// Must be called with idx.mu held.
+func (idx *Index) removeChunksUnderLock(drop func(path string) bool) {
+	idx.dropChunksUnderLock(func(_ int, c ChunkMeta) bool { return drop(c.Path) })
+}
+
+// dropChunksUnderLock removes the chunks for which drop, given a chunk's
+// ID and metadata, returns true, rebuilding the HNSW graph from the rest;
+// chunks marked removed go with them. Must be called with idx.mu held.
+func (idx *Index) dropChunksUnderLock(drop func(id int, c ChunkMeta) bool) {
 	hasOldChunks := false
-	for _, c := range idx.chunks {
-		if c.Path == path {
+	for id, c := range idx.chunks {
+		if !c.removed && drop(id, c) {
 			hasOldChunks = true
 			break
 		}
@@ -268,7 +1391,7 @@ func (idx *Index) removeFileChunksUnderLock(path string) {
 	newGraph := hnsw.New(hnsw.DefaultM, hnsw.DefaultEfConstruction, hnsw.DefaultEfSearch)
 
 	for oldID, c := range idx.chunks {
-		if c.Path == path {
+		if c.removed || drop(oldID, c) {
 			continue
 		}
 		vec := idx.graph.GetNodeVec(uint32(oldID))
@@ -279,87 +1402,390 @@ func (idx *Index) removeFileChunksUnderLock(path string) {
 	}
 
 	idx.chunks = newChunks
+	idx.removed = 0
+	idx.graph.Close()
 	idx.graph = newGraph
+	idx.indexFilesUnderLock()
+	idx.dropTermsUnderLock()
+	idx.changedUnderLock()
+}
+
+// liveChunks returns idx.chunks without the chunks marked removed, which
+// are in it until the next Flush. Must be called with idx.mu held.
+func (idx *Index) liveChunks() []ChunkMeta {
+	if idx.removed == 0 {
+		return idx.chunks
+	}
+	return slices.DeleteFunc(slices.Clone(idx.chunks), func(c ChunkMeta) bool { return c.removed })
 }
 
-// Search embeds query with the BGE instruction prefix and returns the top-k most similar chunks.
+// compactRemovedUnderLock drops the chunks marked removed, and their graph
+// nodes, renumbering the rest: the graph is relinked around the gaps, not
+// rebuilt. Must be called with idx.mu held for writing.
+func (idx *Index) compactRemovedUnderLock() error {
+	if idx.removed == 0 {
+		return nil
+	}
+	if err := idx.graph.Compact(); err != nil {
+		return err
+	}
+	idx.chunks = slices.DeleteFunc(idx.chunks, func(c ChunkMeta) bool { return c.removed })
+	idx.removed = 0
+	idx.indexFilesUnderLock()
+	idx.dropTermsUnderLock()
+	idx.changedUnderLock()
+	return nil
+}
+
+// Search embeds query with the query prefix and returns the top-k most similar chunks.
 // It performs cross-chunk deduplication: it will not return two chunks from the same file.
-func (idx *Index) Search(query string, k int) ([]SearchResult, error) {
-	queryVec, err := idx.embedder.EmbedQuery(query)
+// The query is normalized first (see NormalizeQuery); an empty one fails
+// with ErrEmptyQuery before anything is embedded. Cancelling ctx abandons
+// the search, which then returns ctx's error.
+func (idx *Index) Search(ctx context.Context, query string, k int) ([]SearchResult, error) {
+	return idx.SearchFiltered(ctx, query, k, Filter{})
+}
+
+// SearchFiltered is like Search but only returns chunks matching f. The
+// candidate pool is widened until k matching files are found or the whole
+// graph has been considered. A search repeated before the index changes
+// is answered from the result cache (see ResultCache); an abandoned one
+// is not cached.
+func (idx *Index) SearchFiltered(ctx context.Context, query string, k int, f Filter) ([]SearchResult, error) {
+	query = NormalizeQuery(query)
+	if query == "" {
+		return nil, ErrEmptyQuery
+	}
+	idx.mu.RLock()
+	key := idx.resultKey(query, k, f)
+	idx.mu.RUnlock()
+	if res, ok := idx.results.get(key); ok {
+		return res, nil
+	}
+	start, warm := time.Now(), idx.Warmup().Done
+	idx.mu.RLock()
+	variants := idx.expandQuery(query)
+	idx.mu.RUnlock()
+	texts := append([]string{query}, variants...)
+	var vecs [][]float32
+	var err error
+	if len(variants) == 0 {
+		var queryVec []float32
+		queryVec, err = idx.embedQuery(ctx, query)
+		vecs = [][]float32{queryVec}
+	} else {
+		vecs, err = idx.embedQueries(ctx, texts)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("embed query: %w", err)
+		return nil, err
 	}
 
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
+	var res []SearchResult
+	var plan string
+	if len(variants) == 0 {
+		res, plan, err = idx.searchVec(ctx, query, vecs[0], k, f)
+	} else {
+		res, plan, err = idx.searchExpanded(ctx, texts, vecs, k, f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Cached under the generation searched, which may be newer than the
+	// one looked up.
+	idx.results.put(idx.resultKey(query, k, f), res)
+	idx.log.Debugf("search %q: %s plan, %d variants, %v", query, plan, len(variants), time.Since(start).Round(time.Microsecond))
+	idx.noteSearch(start, warm)
+	return res, nil
+}
 
-	// Fetch more hits to allow filtering out duplicates from the same file.
-	fetchK := k * 5
-	if fetchK > len(idx.chunks) {
-		fetchK = len(idx.chunks)
+// embedQuery embeds a normalized query, unless ctx is done before or
+// after: embedding does not watch ctx, so a search cancelled meanwhile
+// stops before the graph.
+func (idx *Index) embedQuery(ctx context.Context, query string) ([]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	if fetchK == 0 {
-		return nil, nil
+	embedder, err := idx.getEmbedder()
+	if err != nil {
+		return nil, err
+	}
+	queryVec, err := embedder.EmbedQuery(idx.queryText(query))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrEmbedQuery, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	return queryVec, nil
+}
 
-	hits := idx.graph.Search(queryVec, fetchK)
+// BatchResult is the outcome of one query in a SearchBatch call.
+type BatchResult struct {
+	Results []SearchResult
+	Err     error
+}
 
-	queryWords := strings.Fields(strings.ToLower(query))
+// SearchBatch runs several queries, embedding them together when the
+// embedder supports it. Results are returned in input order; a failing
+// query (e.g. ErrEmptyQuery) sets its Err without affecting the rest.
+func (idx *Index) SearchBatch(queries []string, k int) []BatchResult {
+	return idx.SearchBatchFiltered(queries, k, Filter{})
+}
 
-	type scoredHit struct {
-		meta  ChunkMeta
-		score float32
-		text  string
+// SearchBatchFiltered is like SearchBatch but only returns chunks matching f.
+func (idx *Index) SearchBatchFiltered(queries []string, k int, f Filter) []BatchResult {
+	out := make([]BatchResult, len(queries))
+	var texts []string
+	var pos []int
+	for i, q := range queries {
+		q = NormalizeQuery(q)
+		if q == "" {
+			out[i].Err = ErrEmptyQuery
+			continue
+		}
+		texts = append(texts, q)
+		pos = append(pos, i)
+	}
+	if len(texts) == 0 {
+		return out
 	}
-	var reranked []scoredHit
 
-	for _, h := range hits {
-		if int(h.ID) >= len(idx.chunks) {
+	embedder, err := idx.getEmbedder()
+	if err != nil {
+		for _, p := range pos {
+			out[p].Err = err
+		}
+		return out
+	}
+
+	// Synonym variants (see SetExpansion) are embedded in the same batch,
+	// after the queries.
+	idx.mu.RLock()
+	variants := make([][]string, len(texts))
+	all := slices.Clone(texts)
+	for j, q := range texts {
+		variants[j] = idx.expandQuery(q)
+		all = append(all, variants[j]...)
+	}
+	idx.mu.RUnlock()
+	prefixed := make([]string, len(all))
+	for j, q := range all {
+		prefixed[j] = idx.queryText(q)
+	}
+	vecs := make([][]float32, len(all))
+	batched := false
+	if be, ok := embedder.(QueryBatchEmbedder); ok {
+		if v, err := be.EmbedQueries(prefixed); err == nil && len(v) == len(all) {
+			vecs = v
+			batched = true
+		}
+	}
+	if !batched {
+		// Embed one by one so a single bad query cannot sink the batch.
+		for j, q := range prefixed {
+			v, err := embedder.EmbedQuery(q)
+			if err != nil {
+				if j < len(texts) {
+					out[pos[j]].Err = fmt.Errorf("%w: %w", ErrEmbedQuery, err)
+				}
+				continue
+			}
+			vecs[j] = v
+		}
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	f, fetchK := idx.prepareSearch(k, f)
+	if fetchK == 0 {
+		return out
+	}
+	// Search the graph for every query not planned lexically at once,
+	// over all cores; only queries that need a wider pool search it again.
+	var live []int
+	var liveVecs [][]float32
+	next := len(texts) // where the variants of texts[j] start in vecs
+	for j, v := range vecs[:len(texts)] {
+		n := len(variants[j])
+		next += n
+		if v == nil {
 			continue
 		}
-		meta := idx.chunks[h.ID]
-		score := h.Score
-
-		chunkText := meta.Text
-		lowerText := strings.ToLower(chunkText)
-		var matches int
-		for _, w := range queryWords {
-			if len(w) > 2 && strings.Contains(lowerText, w) {
-				matches++
+		if n > 0 {
+			// A variant that failed to embed is left out.
+			qs, qvecs := []string{texts[j]}, [][]float32{v}
+			for i, vv := range vecs[next-n : next] {
+				if vv != nil {
+					qs, qvecs = append(qs, variants[j][i]), append(qvecs, vv)
+				}
+			}
+			out[pos[j]].Results, _, _ = idx.searchExpanded(context.Background(), qs, qvecs, k, f)
+			continue
+		}
+		if hits := idx.lexicalHits(texts[j], v, k, f); hits != nil {
+			if res, _ := idx.rankHits(context.Background(), texts[j], hits, k, f); len(res) >= k {
+				out[pos[j]].Results = res
+				continue
 			}
 		}
-		score += float32(matches) * 0.05
+		live = append(live, j)
+		liveVecs = append(liveVecs, v)
+	}
+	hits := idx.graph.SearchMany(liveVecs, fetchK, 0)
+	for n, j := range live {
+		out[pos[j]].Results, _ = idx.rankWidening(context.Background(), texts[j], vecs[j], k, f, fetchK, hits[n])
+	}
+	return out
+}
 
-		reranked = append(reranked, scoredHit{meta: meta, score: score, text: chunkText})
+// searchVec ranks chunks against an already-embedded query, until ctx is
+// done, and returns the query plan it followed (see queryplan.go). Must
+// be called with idx.mu held (read).
+func (idx *Index) searchVec(ctx context.Context, query string, queryVec []float32, k int, f Filter) ([]SearchResult, string, error) {
+	f, fetchK := idx.prepareSearch(k, f)
+	if fetchK == 0 {
+		return nil, QueryPlanHNSW, nil
+	}
+	if hits := idx.lexicalHits(query, queryVec, k, f); hits != nil {
+		results, err := idx.rankHits(ctx, query, hits, k, f)
+		if err != nil || len(results) >= k {
+			return results, QueryPlanLexical, err
+		}
+	}
+	hits, err := idx.graph.SearchContext(ctx, queryVec, fetchK)
+	if err != nil {
+		return nil, QueryPlanHNSW, err
 	}
+	results, err := idx.rankWidening(ctx, query, queryVec, k, f, fetchK, hits)
+	return results, QueryPlanHNSW, err
+}
 
-	// Sort by hybrid bi-encoder + keyword score
-	sort.Slice(reranked, func(i, j int) bool {
-		return reranked[i].score > reranked[j].score
-	})
+// prepareSearch fills in f's defaults and returns the size of the first
+// candidate pool searched for k results, or 0 if no chunk can match.
+// Must be called with idx.mu held (read).
+func (idx *Index) prepareSearch(k int, f Filter) (Filter, int) {
+	if f.MaxPerDir <= 0 {
+		f.MaxPerDir = idx.maxPerDir
+	}
+	if (len(f.Dirs) > 0 || len(f.Types) > 0) && !slices.ContainsFunc(idx.chunks, func(c ChunkMeta) bool { return idx.passes(f, c) }) {
+		return f, 0 // rather than widening to the whole graph for nothing
+	}
+	// Fetch more hits to allow filtering out duplicates from the same file.
+	return f, min(k*5, len(idx.chunks))
+}
 
+// rankWidening ranks hits, the best fetchK graph hits for queryVec, and
+// while fewer than k pass f, searches a pool four times larger, up to
+// the whole graph, until ctx is done. Must be called with idx.mu held
+// (read).
+func (idx *Index) rankWidening(ctx context.Context, query string, queryVec []float32, k int, f Filter, fetchK int, hits []hnsw.Result) ([]SearchResult, error) {
+	for {
+		results, err := idx.rankHits(ctx, query, hits, k, f)
+		if err != nil || len(results) >= k || fetchK == len(idx.chunks) || f.IsZero() {
+			return results, err
+		}
+		fetchK = min(fetchK*4, len(idx.chunks))
+		if hits, err = idx.graph.SearchContext(ctx, queryVec, fetchK); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rankHits applies the keyword boost, filter, per-file dedup and the
+// filter's per-directory cap to raw graph hits and returns at most k
+// results.
+func (idx *Index) rankHits(ctx context.Context, query string, hits []hnsw.Result, k int, f Filter) ([]SearchResult, error) {
+	scored, err := idx.scoreHits(ctx, query, hits, f)
+	if err != nil {
+		return nil, err
+	}
+	return idx.pickResults(scored, k, f), nil
+}
+
+// pickResults applies per-file dedup and the filter's per-directory cap
+// to scored results, best first, and returns at most k of them.
+func (idx *Index) pickResults(scored []SearchResult, k int, f Filter) []SearchResult {
 	results := make([]SearchResult, 0, k)
-	seen := make(map[string]bool)
+	perFile := make(map[string][]ChunkMeta) // the chunks kept of each file
+	perDir := make(map[string]int)
+	capped := false // a better result was left out for its directory
 
-	for _, h := range reranked {
+	for _, h := range scored {
 		if len(results) >= k {
 			break
 		}
-		if seen[h.meta.Path] {
+		key := idx.pathKey(h.Meta.Path)
+		kept := perFile[key]
+		if len(kept) >= max(f.MaxPerFile, 1) || slices.ContainsFunc(kept, func(c ChunkMeta) bool { return overlaps(c, h.Meta) }) {
 			continue
 		}
-		seen[h.meta.Path] = true
+		perFile[key] = append(kept, h.Meta)
+		if f.MaxPerDir > 0 {
+			dir := filepath.Dir(key)
+			if perDir[dir] >= f.MaxPerDir {
+				capped = true
+				continue
+			}
+			perDir[dir]++
+		}
+		h.Promoted = capped
+		results = append(results, h)
+	}
+	return results
+}
 
-		results = append(results, SearchResult{
-			Meta:  h.meta,
-			Score: h.score,
+// overlaps reports whether two chunks of a file share source bytes, as
+// neighbours do by up to the chunker's overlap.
+func overlaps(a, b ChunkMeta) bool {
+	return a.StartByte < b.EndByte && b.StartByte < a.EndByte
+}
+
+// scoreHits applies the keyword boost and filter to raw graph hits and
+// returns them best first, before per-file dedup. It stops with ctx's
+// error once ctx is done, checked before each hit's text is read.
+func (idx *Index) scoreHits(ctx context.Context, query string, hits []hnsw.Result, f Filter) ([]SearchResult, error) {
+	queryWords := idx.boostWords(query)
+
+	var reranked []SearchResult
+	for _, h := range hits {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if int(h.ID) >= len(idx.chunks) {
+			continue
+		}
+		meta := idx.chunks[h.ID]
+		if !idx.passes(f, meta) {
+			continue
+		}
+		meta = idx.withText(meta)
+		matched, tokens, weight := idx.matchTerms(queryWords, meta.Text)
+		keyword := weight * KeywordBoost
+		reranked = append(reranked, SearchResult{
+			Meta:          meta,
+			Score:         h.Score + keyword,
+			Vector:        h.Score,
+			Keyword:       keyword,
+			Matched:       matched,
+			MatchedTokens: tokens,
 		})
 	}
-	return results, nil
+
+	// Sort by hybrid bi-encoder + keyword score
+	sort.SliceStable(reranked, func(i, j int) bool {
+		return reranked[i].Score > reranked[j].Score
+	})
+	if idx.calibrate {
+		calibrate(reranked)
+	}
+	return reranked, nil
 }
 
-// Flush writes the HNSW graph and metadata to disk if dirty.
-// Writes use the atomic pattern: write to .tmp, sync, rename.
+// Flush writes the HNSW graph and metadata to disk if dirty. Each file is
+// written in full before any replaces the old one, and the journal makes
+// the renames that follow complete even after a crash; see journal.go.
 func (idx *Index) Flush() error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
@@ -367,28 +1793,85 @@ func (idx *Index) Flush() error {
 	if !idx.dirty {
 		return nil
 	}
+	if idx.readOnly || idx.metaOnly {
+		// With metaOnly, writing would replace hnsw.bin with an empty graph.
+		return ErrReadOnly
+	}
+	if idx.stagingFor != nil {
+		return errStaging
+	}
+	defer idx.timePhase(PhaseFlush, time.Now())
+	if err := os.MkdirAll(idx.dir, 0o755); err != nil {
+		return fmt.Errorf("mkdir %s: %w", idx.dir, err)
+	}
+	if err := idx.compactRemovedUnderLock(); err != nil {
+		return fmt.Errorf("compact hnsw: %w", err)
+	}
 
-	// Save HNSW graph (uses atomic writes internally).
-	hnswPath := filepath.Join(idx.dir, hnswFile)
-	if err := idx.graph.Save(hnswPath); err != nil {
+	// Stage every file (each one an atomic write: tmp → sync → rename),
+	// commit with the journal, then move them into place.
+	staged := func(name string) string { return stagedPath(idx.dir, name) }
+	if err := idx.graph.Save(staged(hnswFile)); err != nil {
 		return fmt.Errorf("save hnsw: %w", err)
 	}
-
-	// Save chunk metadata (atomic write: tmp → sync → rename).
-	metaPath := filepath.Join(idx.dir, metaFile)
-	tmpMeta := metaPath + ".tmp"
-	data, err := json.MarshalIndent(idx.chunks, "", "  ")
+	if err := idx.writeTextsUnderLock(staged(textFile)); err != nil {
+		return err
+	}
+	if err := idx.writeTermsUnderLock(staged(termsFile)); err != nil {
+		return err
+	}
+	tmpMeta := staged(metaFile) + ".tmp"
+	metas := slices.Clone(idx.chunks)
+	for i := range metas {
+		metas[i].Path = idx.storedPath(metas[i].Path)
+		metas[i].Text = "" // in text.bin
+	}
+	truncChunks, truncFiles := countTruncated(idx.chunks)
+	data, err := json.MarshalIndent(metas, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshal meta: %w", err)
 	}
-	if err := os.WriteFile(tmpMeta, data, 0o644); err != nil {
+	stored, compressed, err := encodeMeta(data, idx.metaCompression)
+	if err != nil {
+		return fmt.Errorf("compress meta: %w", err)
+	}
+	idx.metaBytes, idx.metaCompressed = int64(len(data)), compressed
+	if err := os.WriteFile(tmpMeta, stored, 0o644); err != nil {
 		return fmt.Errorf("write meta tmp: %w", err)
 	}
-	if err := os.Rename(tmpMeta, metaPath); err != nil {
+	if err := os.Rename(tmpMeta, staged(metaFile)); err != nil {
 		os.Remove(tmpMeta)
 		return fmt.Errorf("rename meta: %w", err)
 	}
 
+	if idx.manifest == nil {
+		idx.manifest = newManifest("")
+	}
+	idx.manifest.FormatVersion = FormatVersion // as meta.json was just written
+	idx.manifest.Updated = idx.lastUpdated
+	idx.manifest.TruncatedChunks, idx.manifest.TruncatedFiles = truncChunks, truncFiles
+	idx.manifest.SampledFiles = countSampled(idx.chunks)
+	idx.manifest.Languages = countLanguages(idx.chunks)
+	if idx.heal != nil {
+		idx.manifest.LastHeal = idx.heal
+	}
+	if err := writeManifestTo(staged(manifestFile), idx.manifest); err != nil {
+		return err
+	}
+	if err := idx.writeSummaryUnderLock(staged(summaryFile)); err != nil {
+		return err
+	}
+
+	// The summary goes last: Refresh takes it to mean the save is done.
+	names := []string{hnswFile, textFile, termsFile, metaFile, manifestFile, summaryFile}
+	if err := writeJournal(idx.dir, names); err != nil {
+		return err
+	}
+	if err := commitSave(idx.dir, names); err != nil {
+		return err
+	}
+	idx.savedUpdated = idx.lastUpdated
+
 	idx.dirty = false
 	return nil
 }
@@ -398,43 +1881,138 @@ func (idx *Index) Stats() Stats {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
+	live := idx.liveChunks()
 	fileSet := make(map[string]struct{})
-	for _, c := range idx.chunks {
-		fileSet[c.Path] = struct{}{}
+	exts := make(map[string]ExtStats)
+	for _, c := range live {
+		ext := strings.ToLower(filepath.Ext(c.Path))
+		es := exts[ext]
+		es.Chunks++
+		if _, seen := fileSet[c.Path]; !seen {
+			fileSet[c.Path] = struct{}{}
+			es.Files++
+		}
+		exts[ext] = es
+	}
+
+	truncChunks, truncFiles := countTruncated(live)
+	oversized := idx.oversizedUnderLock()
+	oversizedIndexed := 0
+	for _, p := range oversized {
+		if _, ok := fileSet[p]; ok {
+			oversizedIndexed++
+		}
 	}
 
 	// Measure disk usage.
 	var sizeBytes int64
-	for _, fname := range []string{hnswFile, metaFile} {
+	artifacts := make(map[string]int64)
+	for _, fname := range ArtifactFiles {
 		if fi, err := os.Stat(filepath.Join(idx.dir, fname)); err == nil {
+			artifacts[fname] = fi.Size()
 			sizeBytes += fi.Size()
 		}
 	}
 
 	return Stats{
-		NumChunks:   len(idx.chunks),
-		NumFiles:    len(fileSet),
-		IndexSizeKB: sizeBytes / 1024,
-		LastUpdated: idx.lastUpdated,
+		NumChunks:        len(live),
+		NumFiles:         len(fileSet),
+		SizeBytes:        sizeBytes,
+		IndexSizeKB:      sizeBytes / 1024,
+		LastUpdated:      idx.lastUpdated,
+		Dir:              idx.dir,
+		Extensions:       exts,
+		Artifacts:        artifacts,
+		HashedFiles:      countHashed(live),
+		HNSW:             idx.graph.Params(),
+		MetaBytes:        idx.metaBytes,
+		MetaCompressed:   idx.metaCompressed,
+		Repaired:         idx.repairedString(),
+		MissingFiles:     slices.Clone(idx.missing),
+		LivenessChecked:  idx.livenessChecked,
+		Oversized:        len(oversized),
+		OversizedIndexed: oversizedIndexed,
+		TruncatedChunks:  truncChunks,
+		TruncatedFiles:   truncFiles,
+		SampledFiles:     countSampled(idx.chunks),
+		Languages:        countLanguages(idx.chunks),
+		Categories:       idx.countCategories(),
+	}
+}
+
+// countTruncated returns how many of chunks were truncated by the
+// embedder, and in how many files.
+func countTruncated(chunks []ChunkMeta) (nChunks, nFiles int) {
+	files := make(map[string]bool)
+	for _, c := range chunks {
+		if c.EmbedTruncated {
+			nChunks++
+			files[c.Path] = true
+		}
+	}
+	return nChunks, len(files)
+}
+
+// countSampled returns how many files in chunks are indexed only in
+// part; see ChunkMeta.Sampled.
+func countSampled(chunks []ChunkMeta) int {
+	files := make(map[string]bool)
+	for _, c := range chunks {
+		if c.Sampled {
+			files[c.Path] = true
+		}
 	}
+	return len(files)
 }
 
 // RebuildFromDir reindexes everything in rootDir from scratch.
 func (idx *Index) RebuildFromDir(ctx context.Context, rootDir string) error {
-	idx.mu.Lock()
-	idx.chunks = idx.chunks[:0]
-	idx.graph = hnsw.New(hnsw.DefaultM, hnsw.DefaultEfConstruction, hnsw.DefaultEfSearch)
-	idx.fileCache = make(map[string]time.Time) // clear skip-cache
-	idx.mu.Unlock()
+	return idx.RebuildFromDirWithProgress(ctx, rootDir, nil)
+}
 
-	return idx.IndexDirWithProgress(ctx, rootDir, nil)
+// RebuildFromDirWithProgress is RebuildFromDir, calling progress after each
+// file (may be nil).
+func (idx *Index) RebuildFromDirWithProgress(ctx context.Context, rootDir string, progress ProgressFunc) error {
+	if err := idx.checkRoot(rootDir); err != nil {
+		return err
+	}
+	return idx.Rebuild(func(stage *Index) error {
+		return stage.IndexDirWithProgress(ctx, rootDir, progress)
+	})
+}
+
+// checkRoot returns ErrIndexInRoot if the index directory lies inside
+// rootDir and no path element between them is hidden (walkDir skips
+// hidden directories, so .sift and the like are safe).
+func (idx *Index) checkRoot(rootDir string) error {
+	root, err := filepath.Abs(rootDir)
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.Abs(idx.dir)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil // outside root
+	}
+	if rel != "." {
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			if strings.HasPrefix(part, ".") {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: %s is under %s; use a hidden directory or one outside the root", ErrIndexInRoot, dir, root)
 }
 
 // ProgressFunc is called after each file is processed during indexing.
 // done and total are file counts; skipped=true means mtime cache hit (no re-embed).
 type ProgressFunc func(done, total int, path string, skipped bool)
 
-// IndexDir walks rootDir and indexes all supported files.
+// IndexDir walks rootDir and indexes all supported files; rootDir may
+// also be a single file (see IndexDirWithProgress).
 // ctx is checked between files; cancel it to interrupt indexing gracefully.
 func (idx *Index) IndexDir(ctx context.Context, rootDir string) error {
 	return idx.IndexDirWithProgress(ctx, rootDir, nil)
@@ -443,42 +2021,132 @@ func (idx *Index) IndexDir(ctx context.Context, rootDir string) error {
 // IndexDirWithProgress walks rootDir, indexes all supported files, and calls
 // progress after each file (may be nil). ctx is checked between each file;
 // cancel it to stop indexing after the current file finishes embedding.
+// A rootDir that is a file is indexed alone, as one of a single file.
+// Meanwhile Status reports how far it is, and RequestFlush saves the
+// index between two files.
 func (idx *Index) IndexDirWithProgress(ctx context.Context, rootDir string, progress ProgressFunc) error {
+	info, err := os.Stat(rootDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return idx.indexFileRoot(ctx, rootDir, progress)
+	}
+	if err := idx.checkRoot(rootDir); err != nil {
+		return err
+	}
+	m, err := idx.Matcher(rootDir)
+	if err != nil {
+		return err
+	}
+	idx.startRoot(rootDir, 0)
+
 	// First pass: collect all eligible file paths so we know the total.
 	var paths []string
-	err := walkDir(rootDir, func(path string) error {
+	walkStart := time.Now()
+	err = walkDir(rootDir, m, func(path string) error {
 		if chunker.IsSupportedFile(path) {
 			paths = append(paths, path)
 		}
 		return nil
 	})
+	idx.timePhase(PhaseWalk, walkStart)
 	if err != nil {
 		return err
 	}
+	if err := idx.addRoot(rootDir); err != nil {
+		return err
+	}
 
 	total := len(paths)
+	idx.startRoot(rootDir, total)
 	for i, path := range paths {
 		// Check for cancellation before each file (embedding can be slow).
 		if err := ctx.Err(); err != nil {
 			return err
 		}
+		idx.noteFile(i, path)
 		skipped, err := idx.AddFileCtx(ctx, path)
 		if err != nil {
 			return err
 		}
+		idx.noteFile(i+1, "")
 		if progress != nil {
 			progress(i+1, total, path, skipped)
 		}
+		idx.checkpoint()
+		if !skipped && i+1 < total {
+			idx.pause(ctx)
+		}
 	}
 	return nil
 }
 
+// indexFileRoot indexes path, a file given as a root. Ignore rules do not
+// apply to it, since naming the file asks for it, but its type must be
+// one sift indexes. It is remembered as a root like a directory, so runs
+// with --global and watch rescans revisit it.
+func (idx *Index) indexFileRoot(ctx context.Context, path string, progress ProgressFunc) error {
+	if !chunker.IsSupportedFile(path) {
+		return fmt.Errorf("%s: %w", path, ErrUnsupported)
+	}
+	if err := idx.addRoot(path); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	idx.startRoot(path, 1)
+	idx.noteFile(0, path)
+	skipped, err := idx.AddFileCtx(ctx, path)
+	if err != nil {
+		return err
+	}
+	idx.noteFile(1, "")
+	if progress != nil {
+		progress(1, 1, path, skipped)
+	}
+	idx.checkpoint()
+	return nil
+}
+
+// PruneMissing removes the chunks of indexed files under rootDir (or of
+// rootDir itself, for a file root) that no longer exist, catching deletions nobody reported (sift watch rescans).
+// It returns the number of files removed.
+func (idx *Index) PruneMissing(rootDir string) (int, error) {
+	root, err := filepath.Abs(rootDir)
+	if err != nil {
+		return 0, err
+	}
+
+	// Stat outside the lock so searches are not held up by the disk.
+	var under []string
+	for _, p := range idx.knownPaths() {
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, abs); err == nil && filepath.IsLocal(rel) {
+			under = append(under, p)
+		}
+	}
+	missing := missingFiles(under)
+	idx.pruneFiles(missing)
+	return len(missing), nil
+}
+
 // walkDir walks rootDir recursively, calling fn for each file.
-// Skips hidden directories.
-func walkDir(rootDir string, fn func(string) error) error {
-	entries, err := os.ReadDir(rootDir)
+// Skips hidden entries and anything m excludes.
+func walkDir(rootDir string, m *ignore.Matcher, fn func(string) error) error {
+	return walkDirFrom(rootDir, rootDir, m, fn, nil)
+}
+
+// walkDirFrom walks dir (below rootDir). onSkip, if non-nil, is told about
+// every file or directory the matcher excludes.
+func walkDirFrom(rootDir, dir string, m *ignore.Matcher, fn func(string) error, onSkip func(path string, isDir bool)) error {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return fmt.Errorf("readdir %s: %w", rootDir, err)
+		return fmt.Errorf("readdir %s: %w", dir, err)
 	}
 	for _, entry := range entries {
 		name := entry.Name()
@@ -486,12 +2154,25 @@ func walkDir(rootDir string, fn func(string) error) error {
 		if strings.HasPrefix(name, ".") {
 			continue
 		}
-		full := filepath.Join(rootDir, name)
+		full := filepath.Join(dir, name)
+		rel := ignore.Rel(rootDir, full)
 		if entry.IsDir() {
-			if err := walkDir(full, fn); err != nil {
+			if m.SkipDir(rel) {
+				if onSkip != nil {
+					onSkip(full, true)
+				}
+				continue
+			}
+			if err := walkDirFrom(rootDir, full, m, fn, onSkip); err != nil {
 				return err
 			}
 		} else {
+			if m.SkipFile(rel) {
+				if onSkip != nil {
+					onSkip(full, false)
+				}
+				continue
+			}
 			if err := fn(full); err != nil {
 				return err
 			}
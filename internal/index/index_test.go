@@ -2,16 +2,25 @@
 package index
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
+	"maps"
 	"math"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/tejas242/sift/internal/chunker"
+	"github.com/tejas242/sift/internal/embed"
 	"github.com/tejas242/sift/internal/hnsw"
+	"github.com/tejas242/sift/internal/logging"
 )
 
 // TestHNSWRecallSmokeTest exercises the HNSW implementation used by the index.
@@ -60,7 +69,7 @@ func TestIndexDirSkipsHidden(t *testing.T) {
 
 	// We verify through the chunker that the hidden file is excluded.
 	var seen []string
-	walkDir(dir, func(path string) error {
+	walkDir(dir, nil, func(path string) error {
 		seen = append(seen, path)
 		return nil
 	})
@@ -117,9 +126,74 @@ func TestIndexDirContextCancel(t *testing.T) {
 	}
 }
 
+func TestIndex_ChunkProgress(t *testing.T) {
+	dir := t.TempDir()
+	big := filepath.Join(dir, "big.md")
+	var text strings.Builder
+	for i := range 1200 {
+		fmt.Fprintf(&text, "line %d of a file long enough to take several embedding batches\n", i)
+	}
+	if err := os.WriteFile(big, []byte(text.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	small := filepath.Join(dir, "small.md")
+	if err := os.WriteFile(small, []byte("one chunk"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := chunker.ChunkFile(big, chunker.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(chunks)
+	if n <= 64 {
+		t.Fatalf("fixture has %d chunks, want more than two batches", n)
+	}
+
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), &mockEmbedder{})
+	var events []string
+	idx.SetChunkProgress(func(path string, done, total int) {
+		events = append(events, fmt.Sprintf("%s %d/%d", filepath.Base(path), done, total))
+	})
+	for _, p := range []string{small, big} {
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Between batches only: not for a file of one batch, nor after the last.
+	var want []string
+	for done := 32; done < n; done += 32 {
+		want = append(want, fmt.Sprintf("big.md %d/%d", done, n))
+	}
+	if !slices.Equal(events, want) {
+		t.Errorf("chunk progress = %q, want %q", events, want)
+	}
+
+	// Cancelling from the callback stops the file at the next batch.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events = nil
+	idx.SetChunkProgress(func(path string, done, total int) {
+		events = append(events, fmt.Sprintf("%d/%d", done, total))
+		cancel()
+	})
+	edited := strings.Replace(text.String(), "line 0 ", "Line 0 ", 1)
+	if err := os.WriteFile(big, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(big, time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.AddFileCtx(ctx, big); !errors.Is(err, context.Canceled) {
+		t.Errorf("AddFileCtx = %v, want context.Canceled", err)
+	}
+	if want := []string{fmt.Sprintf("32/%d", n)}; !slices.Equal(events, want) {
+		t.Errorf("chunk progress after cancelling = %q, want %q", events, want)
+	}
+}
+
 // walkDirWithCtx is a helper wrapping walkDir with ctx cancellation for tests.
 func walkDirWithCtx(ctx context.Context, rootDir string, fn func(string) error) error {
-	return walkDir(rootDir, func(path string) error {
+	return walkDir(rootDir, nil, func(path string) error {
 		if err := ctx.Err(); err != nil {
 			return err
 		}
@@ -169,7 +243,7 @@ func TestIndex_AddFile_Deduplicate_Search(t *testing.T) {
 		embedder:         &mockEmbedder{},
 		maxFileSizeBytes: 512 * 1024,
 		graph:            hnsw.New(16, 200, 50),
-		fileCache:        make(map[string]time.Time),
+		fileCache:        make(map[string]fileVersion),
 	}
 
 	// Create a dummy file to index.
@@ -228,7 +302,7 @@ func TestIndex_AddFile_Deduplicate_Search(t *testing.T) {
 	}
 
 	// 4. Search.
-	results, err := idx.Search("updated document", 10)
+	results, err := idx.Search(context.Background(), "updated document", 10)
 	if err != nil {
 		t.Fatalf("Search failed: %v", err)
 	}
@@ -247,7 +321,7 @@ func TestIndex_Flush_SavesFiles(t *testing.T) {
 		embedder:         &mockEmbedder{},
 		maxFileSizeBytes: 512 * 1024,
 		graph:            hnsw.New(16, 200, 50),
-		fileCache:        make(map[string]time.Time),
+		fileCache:        make(map[string]fileVersion),
 	}
 
 	filePath := filepath.Join(dir, "doc.md")
@@ -284,16 +358,146 @@ func TestIndex_Flush_SavesFiles(t *testing.T) {
 	if stats.NumFiles != 1 {
 		t.Errorf("expected 1 file, got %d", stats.NumFiles)
 	}
+
+	sum, err := ReadSummary(dir)
+	if err != nil {
+		t.Fatalf("ReadSummary: %v", err)
+	}
+	if sum.Chunks != 1 || sum.Files != 1 || sum.SizeBytes == 0 {
+		t.Errorf("summary = %+v, want 1 chunk, 1 file and a size", sum)
+	}
+}
+
+func TestSummary_InSync(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, ".sift")
+	idx := NewTestIndex(dir, &mockEmbedder{})
+	check := func(step string) {
+		t.Helper()
+		if err := idx.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		sum, err := ReadSummary(dir)
+		if err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		st, m := idx.Stats(), idx.Manifest()
+		if sum.Chunks != st.NumChunks || sum.Files != st.NumFiles || !sum.Updated.Equal(st.LastUpdated) ||
+			sum.FormatVersion != FormatVersion || sum.Model != m.Model || !slices.Equal(sum.Roots, m.Roots) {
+			t.Errorf("%s: summary %+v, want %d chunks, %d files, updated %v, roots %q",
+				step, sum, st.NumChunks, st.NumFiles, st.LastUpdated, m.Roots)
+		}
+	}
+
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("notes on "+name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.IndexDir(context.Background(), root); err != nil {
+		t.Fatal(err)
+	}
+	check("indexed")
+	if sum, _ := ReadSummary(dir); sum.Files != 3 || len(sum.Roots) != 1 {
+		t.Errorf("summary %+v, want 3 files under one root", sum)
+	}
+
+	p := filepath.Join(root, "d.md")
+	if err := os.WriteFile(p, []byte("one more"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.AddFile(p); err != nil {
+		t.Fatal(err)
+	}
+	check("added")
+
+	if err := os.Remove(filepath.Join(root, "a.md")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := idx.PruneMissing(root); err != nil || n != 1 {
+		t.Fatalf("PruneMissing = %d, %v; want 1 file removed", n, err)
+	}
+	check("removed")
+	if sum, _ := ReadSummary(dir); sum.Files != 3 {
+		t.Errorf("summary %+v after adding one file and removing one, want 3 files", sum)
+	}
+}
+
+func TestOpenMeta(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, ".sift")
+	if _, err := OpenMeta(dir); !errors.Is(err, ErrNoIndex) {
+		t.Fatalf("OpenMeta on missing index: err = %v, want ErrNoIndex", err)
+	}
+
+	for _, name := range []string{"b.go", "a.md"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("package notes"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := NewTestIndex(dir, &mockEmbedder{})
+	if err := idx.IndexDir(context.Background(), root); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := OpenMeta(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := m.Stats(); s.NumFiles != 2 || len(s.Extensions) != 2 {
+		t.Errorf("Stats() = %d files, extensions %v; want 2 files, .go and .md", s.NumFiles, s.Extensions)
+	}
+	if got := m.Roots(); len(got) != 1 {
+		t.Errorf("Roots() = %q, want the indexed root", got)
+	}
+	if m.graph.Len() != 0 {
+		t.Error("OpenMeta should not load the graph")
+	}
+	m.dirty = true
+	if err := m.Flush(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Flush on a metadata-only index: err = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestIndex_ResetSkipCache(t *testing.T) {
+	dir := t.TempDir()
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), &mockEmbedder{})
+	doc := filepath.Join(dir, "doc.md")
+	if err := os.WriteFile(doc, []byte("unchanged content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.AddFile(doc); err != nil {
+		t.Fatal(err)
+	}
+	if skipped, _ := idx.AddFile(doc); !skipped {
+		t.Fatal("unchanged file should be skipped")
+	}
+
+	if n := idx.ResetSkipCache(); n != 1 {
+		t.Errorf("ResetSkipCache() = %d, want 1", n)
+	}
+	if s := idx.Stats(); s.NumChunks != 1 {
+		t.Errorf("ResetSkipCache dropped chunks: %d left", s.NumChunks)
+	}
+	if skipped, err := idx.AddFile(doc); err != nil || skipped {
+		t.Errorf("AddFile after reset: skipped=%v err=%v, want re-embedded", skipped, err)
+	}
+	if s := idx.Stats(); s.NumChunks != 1 {
+		t.Errorf("re-embedding duplicated chunks: %d", s.NumChunks)
+	}
 }
 
 func TestIndex_RebuildFromDir(t *testing.T) {
 	dir := t.TempDir()
 	idx := &Index{
-		dir:              dir,
+		dir:              filepath.Join(dir, ".sift"),
 		embedder:         &mockEmbedder{},
 		maxFileSizeBytes: 512 * 1024,
 		graph:            hnsw.New(16, 200, 50),
-		fileCache:        make(map[string]time.Time),
+		fileCache:        make(map[string]fileVersion),
 	}
 
 	// Create some files to index
@@ -324,3 +528,1736 @@ func TestIndex_RebuildFromDir(t *testing.T) {
 		t.Errorf("expected 1 chunk after rebuild, got %d", stats2.NumChunks)
 	}
 }
+
+func TestIndex_SearchBatch(t *testing.T) {
+	dir := t.TempDir()
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), &mockEmbedder{})
+	p := filepath.Join(dir, "a.md")
+	if err := os.WriteFile(p, []byte("alpha beta"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.AddFile(p); err != nil {
+		t.Fatal(err)
+	}
+
+	got := idx.SearchBatch([]string{"alpha", "  ", "beta"}, 5)
+	if len(got) != 3 {
+		t.Fatalf("got %d results, want 3", len(got))
+	}
+	if got[0].Err != nil || len(got[0].Results) != 1 {
+		t.Errorf("query 0: %+v", got[0])
+	}
+	if got[1].Err == nil {
+		t.Error("blank query should carry an error")
+	}
+	if got[2].Err != nil || len(got[2].Results) != 1 {
+		t.Errorf("query 2: %+v", got[2])
+	}
+}
+
+// cancellingEmbedder cancels a search while embedding its query.
+type cancellingEmbedder struct {
+	mockEmbedder
+	cancel context.CancelFunc
+}
+
+func (e *cancellingEmbedder) EmbedQuery(q string) ([]float32, error) {
+	e.cancel()
+	return e.mockEmbedder.EmbedQuery(q)
+}
+
+func TestIndex_SearchCancelled(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	emb := &cancellingEmbedder{cancel: cancel}
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), emb)
+	idx.SetResultCache(ResultCache{Size: 8})
+	p := filepath.Join(dir, "a.md")
+	if err := os.WriteFile(p, []byte("alpha beta"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.AddFile(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if res, err := idx.Search(ctx, "alpha", 5); !errors.Is(err, context.Canceled) || res != nil {
+		t.Fatalf("search cancelled while embedding = %v, %v; want context.Canceled", res, err)
+	}
+	// Nothing was cached for the abandoned search.
+	emb.cancel = func() {}
+	if res, err := idx.Search(context.Background(), "alpha", 5); err != nil || len(res) != 1 {
+		t.Errorf("search after a cancelled one = %v, %v; want a.md", res, err)
+	}
+}
+
+func TestIndex_MaxPerDir(t *testing.T) {
+	root := t.TempDir()
+	idx := NewTestIndex(filepath.Join(root, ".sift"), &mockEmbedder{})
+	// Every vector is the same, so keyword matches decide the order: the
+	// generated API docs match all three words and would fill the top 4.
+	files := map[string]string{
+		"gen/api1.md":    "retry backoff policy",
+		"gen/api2.md":    "retry backoff policy",
+		"gen/api3.md":    "retry backoff policy",
+		"gen/api4.md":    "retry backoff policy",
+		"gen/api5.md":    "retry backoff policy",
+		"docs/guide.md":  "retry backoff",
+		"docs/ops.md":    "retry notes",
+		"notes/misc.txt": "unrelated",
+	}
+	for name, body := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dirs := func(results []SearchResult) (out []string) {
+		for _, r := range results {
+			d := filepath.Base(filepath.Dir(r.Meta.Path))
+			if r.Promoted {
+				d += "*"
+			}
+			out = append(out, d)
+		}
+		return out
+	}
+
+	got, err := idx.Search(context.Background(), "retry backoff policy", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gen", "gen", "gen", "gen"}; !slices.Equal(dirs(got), want) {
+		t.Fatalf("uncapped: dirs %q, want %q", dirs(got), want)
+	}
+
+	got, err = idx.SearchFiltered(context.Background(), "retry backoff policy", 4, Filter{MaxPerDir: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gen", "gen", "docs*", "docs*"}; !slices.Equal(dirs(got), want) {
+		t.Errorf("MaxPerDir 2: dirs %q, want %q (* = promoted)", dirs(got), want)
+	}
+	if got[2].Meta.Path != filepath.Join(root, "docs", "guide.md") {
+		t.Errorf("first promoted = %s, want the best-scoring other directory's file", got[2].Meta.Path)
+	}
+
+	// The index default applies when the filter sets no cap, and a cap of
+	// one leaves room for every directory.
+	idx.SetMaxPerDir(1)
+	got, err = idx.Search(context.Background(), "retry backoff policy", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"gen", "docs*", "notes*"}; !slices.Equal(dirs(got), want) {
+		t.Errorf("SetMaxPerDir(1): dirs %q, want %q", dirs(got), want)
+	}
+	if got, _ := idx.SearchFiltered(context.Background(), "retry backoff policy", 4, Filter{MaxPerDir: 3}); len(got) != 4 || !got[3].Promoted {
+		t.Errorf("filter cap 3 over default 1: dirs %q, want 3 gen and a promoted docs", dirs(got))
+	}
+}
+
+func TestRankHits_OverlappingChunks(t *testing.T) {
+	idx := NewTestIndex(t.TempDir(), &mockEmbedder{})
+	// Chunks of a.md overlap their neighbours by 250 bytes, as the chunker
+	// makes them; b.md's single chunk overlaps nothing.
+	idx.chunks = []ChunkMeta{
+		{Path: "/p/a.md", ChunkIndex: 0, StartByte: 0, EndByte: 1000},
+		{Path: "/p/a.md", ChunkIndex: 1, StartByte: 750, EndByte: 1750},
+		{Path: "/p/a.md", ChunkIndex: 2, StartByte: 1500, EndByte: 2500},
+		{Path: "/p/a.md", ChunkIndex: 3, StartByte: 2500, EndByte: 3000}, // touches 2, no overlap
+		{Path: "/p/b.md", ChunkIndex: 0, StartByte: 0, EndByte: 800},
+	}
+	hits := []hnsw.Result{{ID: 1, Score: 0.9}, {ID: 0, Score: 0.85}, {ID: 2, Score: 0.8}, {ID: 4, Score: 0.7}, {ID: 3, Score: 0.6}}
+	chunks := func(results []SearchResult) (out []string) {
+		for _, r := range results {
+			out = append(out, fmt.Sprintf("%s#%d", filepath.Base(r.Meta.Path), r.Meta.ChunkIndex))
+		}
+		return out
+	}
+
+	for _, tc := range []struct {
+		perFile int
+		want    []string
+	}{
+		{0, []string{"a.md#1", "b.md#0"}},
+		{1, []string{"a.md#1", "b.md#0"}},
+		// Chunks 0 and 2 both overlap the better chunk 1, leaving 3.
+		{2, []string{"a.md#1", "b.md#0", "a.md#3"}},
+		{5, []string{"a.md#1", "b.md#0", "a.md#3"}},
+	} {
+		got, _ := idx.rankHits(context.Background(), "", hits, 10, Filter{MaxPerFile: tc.perFile})
+		if !slices.Equal(chunks(got), tc.want) {
+			t.Errorf("MaxPerFile %d: got %q, want %q", tc.perFile, chunks(got), tc.want)
+		}
+	}
+
+	// Without chunk 1, chunks 0 and 2 no longer overlap anything kept.
+	got, _ := idx.rankHits(context.Background(), "", hits[1:], 10, Filter{MaxPerFile: 3})
+	if want := []string{"a.md#0", "a.md#2", "b.md#0", "a.md#3"}; !slices.Equal(chunks(got), want) {
+		t.Errorf("without chunk 1: got %q, want %q", chunks(got), want)
+	}
+}
+
+func TestIndex_SearchInDirs(t *testing.T) {
+	root := t.TempDir()
+	idx := NewTestIndex(filepath.Join(root, ".sift"), wordEmbedder{})
+	files := map[string]string{
+		"a/x.md":     "wireguard tunnel",
+		"a/b/y.md":   "wireguard",
+		"a-old/z.md": "wireguard",
+		"c/w.md":     "wireguard",
+	}
+	// Plenty of out-of-scope files scoring above a/x.md, so scoped results
+	// lie deep in the graph. Their vectors differ, as HNSW expects.
+	for i := range 30 {
+		files[fmt.Sprintf("gen/%02d.md", i)] = strings.Repeat("wireguard ", i+2) + "garden"
+	}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		body := files[name]
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rel := func(results []SearchResult) []string {
+		var out []string
+		for _, r := range results {
+			out = append(out, filepath.ToSlash(idx.relPath(r.Meta.Path)))
+		}
+		slices.Sort(out)
+		return out
+	}
+
+	for _, tc := range []struct {
+		dirs []string
+		want []string
+	}{
+		{[]string{"a"}, []string{"a/b/y.md", "a/x.md"}}, // not a-old
+		{[]string{filepath.Join(root, "a", "b")}, []string{"a/b/y.md"}},
+		{[]string{"a/b", "c/"}, []string{"a/b/y.md", "c/w.md"}},
+		{[]string{"a", "a/b"}, []string{"a/b/y.md", "a/x.md"}},
+		{[]string{"nowhere"}, nil},
+		{[]string{"a/x.md"}, nil}, // a file is not a directory
+	} {
+		got, err := idx.SearchFiltered(context.Background(), "wireguard", 5, Filter{Dirs: tc.dirs})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(rel(got), tc.want) {
+			t.Errorf("Dirs %q: got %q, want %q", tc.dirs, rel(got), tc.want)
+		}
+	}
+
+	if got, _ := idx.SearchFiltered(context.Background(), "wireguard", 5, Filter{Dirs: []string{"."}}); len(got) != 5 {
+		t.Errorf("Dirs [.] returned %d results, want 5", len(got))
+	}
+	batch := idx.SearchBatchFiltered([]string{"wireguard", "tunnel"}, 5, Filter{Dirs: []string{"c"}})
+	if got := rel(batch[0].Results); !slices.Equal(got, []string{"c/w.md"}) || len(batch[1].Results) != 1 {
+		t.Errorf("SearchBatchFiltered = %+v", batch)
+	}
+}
+
+func TestIndexDir_LogLevels(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("alpha beta"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Over the size limit: produces a warn-level skip message.
+	big := make([]byte, 600*1024)
+	for i := range big {
+		big[i] = 'x'
+	}
+	if err := os.WriteFile(filepath.Join(dir, "big.md"), big, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(level logging.Level) string {
+		var buf bytes.Buffer
+		idx := NewTestIndex(filepath.Join(t.TempDir(), ".sift"), &mockEmbedder{})
+		idx.SetLogger(logging.New(&buf, level))
+		if err := idx.IndexDir(context.Background(), dir); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	if out := run(logging.LevelError); out != "" {
+		t.Errorf("quiet run produced output: %q", out)
+	}
+	out := run(logging.LevelDebug)
+	if !strings.Contains(out, "indexed "+filepath.Join(dir, "a.md")) {
+		t.Errorf("verbose run missing per-file line: %q", out)
+	}
+	if !strings.Contains(out, "file too large") {
+		t.Errorf("verbose run missing skip message: %q", out)
+	}
+}
+
+func TestIndexDir_ExcludeIncludeOnly(t *testing.T) {
+	root := t.TempDir()
+	files := []string{
+		"main.go",
+		"README.md",
+		"db/seed.json",
+		"testdata/fixture.go",
+		"pkg/util.go",
+		"pkg/notes.md",
+		"build/out.go",
+	}
+	for _, f := range files {
+		p := filepath.Join(root, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("content of "+f), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, ".siftignore"), []byte("# generated\nbuild/**\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name        string
+		exclude     []string
+		includeOnly []string
+		want        []string
+	}{
+		{"siftignore only", nil, nil,
+			[]string{"README.md", "db/seed.json", "main.go", "pkg/notes.md", "pkg/util.go", "testdata/fixture.go"}},
+		{"exclude", []string{"testdata/**", "*.json"}, nil,
+			[]string{"README.md", "main.go", "pkg/notes.md", "pkg/util.go"}},
+		{"include-only", nil, []string{"*.md"},
+			[]string{"README.md", "pkg/notes.md"}},
+		{"both", []string{"pkg/**"}, []string{"*.go"},
+			[]string{"main.go", "testdata/fixture.go"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			idx := NewTestIndex(filepath.Join(t.TempDir(), ".sift"), &mockEmbedder{})
+			if err := idx.SetPatterns(tc.exclude, tc.includeOnly); err != nil {
+				t.Fatal(err)
+			}
+			if err := idx.IndexDir(context.Background(), root); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for p := range idx.fileCache {
+				rel, _ := filepath.Rel(root, p)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("indexed %v, want %v", got, tc.want)
+			}
+			m := idx.Manifest()
+			if len(m.Exclude) != len(tc.exclude) || len(m.IncludeOnly) != len(tc.includeOnly) {
+				t.Errorf("manifest patterns = %v / %v", m.Exclude, m.IncludeOnly)
+			}
+		})
+	}
+
+	idx := NewTestIndex(t.TempDir(), &mockEmbedder{})
+	if err := idx.SetPatterns([]string{"[oops"}, nil); err == nil {
+		t.Error("expected malformed glob to be rejected")
+	}
+}
+
+func TestPlanDir(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel string, size int) string {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(strings.Repeat("x", size)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	cached := write("cached.go", 10)
+	fresh := write("fresh.md", 2000)
+	write("big.txt", 2048)
+	write("image.png", 10)
+	write("vendor/lib.go", 10)
+
+	idx := NewTestIndex(filepath.Join(t.TempDir(), ".sift"), &mockEmbedder{})
+	idx.maxFileSizeBytes = 2047
+	if _, err := idx.AddFile(cached); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.SetPatterns([]string{"vendor/**"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := idx.PlanDir(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.ToEmbed) != 1 || p.ToEmbed[0] != fresh {
+		t.Errorf("ToEmbed = %v, want [%s]", p.ToEmbed, fresh)
+	}
+	if len(p.Cached) != 1 || p.Cached[0] != cached {
+		t.Errorf("Cached = %v, want [%s]", p.Cached, cached)
+	}
+	if p.EstBytes != 2000 || p.EstChunks != 3 {
+		t.Errorf("estimate = %d bytes, %d chunks; want 2000, 3", p.EstBytes, p.EstChunks)
+	}
+	reasons := make(map[string]string)
+	for _, s := range p.Skipped {
+		rel, _ := filepath.Rel(root, s.Path)
+		reasons[filepath.ToSlash(rel)] = s.Reason
+	}
+	want := map[string]string{"big.txt": SkipTooLarge, "image.png": SkipUnsupported, "vendor": SkipIgnored}
+	if !maps.Equal(reasons, want) {
+		t.Errorf("skipped = %v, want %v", reasons, want)
+	}
+
+	// fresh ignores the skip cache, as a rebuild does.
+	p, err = idx.PlanDir(root, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.ToEmbed) != 2 || len(p.Cached) != 0 {
+		t.Errorf("fresh plan: ToEmbed = %v, Cached = %v", p.ToEmbed, p.Cached)
+	}
+}
+
+func TestIndexDir_FileRoot(t *testing.T) {
+	root := t.TempDir()
+	notes := filepath.Join(root, "notes.md")
+	for name, body := range map[string]string{"notes.md": "wireguard notes", "other.md": "garden", "logo.png": "png"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Naming a file indexes it even when ignore rules would skip it.
+	if err := os.WriteFile(filepath.Join(root, ".siftignore"), []byte("notes.md\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx := NewTestIndex(filepath.Join(root, ".sift"), &mockEmbedder{})
+
+	p, err := idx.PlanDir(notes, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(p.ToEmbed, []string{notes}) || len(p.Skipped) != 0 {
+		t.Errorf("PlanDir(file) = %+v, want just the file to embed", p)
+	}
+
+	var calls []string
+	err = idx.IndexDirWithProgress(context.Background(), notes, func(done, total int, path string, skipped bool) {
+		calls = append(calls, fmt.Sprintf("%d/%d %s %v", done, total, filepath.Base(path), skipped))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1/1 notes.md false"}; !slices.Equal(calls, want) {
+		t.Errorf("progress = %q, want %q", calls, want)
+	}
+	if s := idx.Stats(); s.NumFiles != 1 {
+		t.Errorf("indexed %d files, want only the named one", s.NumFiles)
+	}
+	if got := idx.Roots(); !slices.Equal(got, []string{notes}) {
+		t.Errorf("Roots() = %q, want the file", got)
+	}
+
+	if err := idx.IndexDir(context.Background(), filepath.Join(root, "logo.png")); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("unsupported file: err = %v, want ErrUnsupported", err)
+	}
+	if err := idx.IndexDir(context.Background(), filepath.Join(root, "missing.md")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file: err = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestCheckRoot(t *testing.T) {
+	root := t.TempDir()
+	cases := []struct {
+		dir  string
+		want bool // want ErrIndexInRoot
+	}{
+		{filepath.Join(root, ".sift"), false},
+		{filepath.Join(root, ".cache", "sift"), false},
+		{filepath.Join(t.TempDir(), "index"), false},
+		{filepath.Join(root, "..", "sibling"), false},
+		{root, true},
+		{filepath.Join(root, "index"), true},
+		{filepath.Join(root, "build", "index"), true},
+	}
+	for _, tc := range cases {
+		idx := NewTestIndex(tc.dir, &mockEmbedder{})
+		err := idx.IndexDir(context.Background(), root)
+		if got := errors.Is(err, ErrIndexInRoot); got != tc.want {
+			t.Errorf("index dir %s: err = %v, want ErrIndexInRoot = %v", tc.dir, err, tc.want)
+		}
+	}
+}
+
+// wordEmbedder counts a few known words into fixed axes, so cosine scores
+// are predictable: "wireguard tunnel" against "wireguard wireguard tunnel"
+// is 3/√10.
+type wordEmbedder struct{}
+
+var wordAxes = map[string]int{"wireguard": 0, "tunnel": 1, "garden": 2, "kettle": 3}
+
+func (wordEmbedder) Embed(texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i, s := range texts {
+		v := make([]float32, 384)
+		for _, w := range strings.Fields(strings.ToLower(s)) {
+			if a, ok := wordAxes[w]; ok {
+				v[a]++
+			}
+		}
+		l2Normalize(v)
+		vecs[i] = v
+	}
+	return vecs, nil
+}
+
+func (e wordEmbedder) EmbedQuery(q string) ([]float32, error) {
+	v, err := e.Embed([]string{q})
+	return v[0], err
+}
+
+func (wordEmbedder) Close() {}
+
+func TestIndex_Explain(t *testing.T) {
+	dir := t.TempDir()
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), wordEmbedder{})
+	files := map[string]string{
+		"a.md": "wireguard tunnel",
+		"b.md": "wireguard wireguard tunnel",
+		"c.md": "tunnel",
+		"d.md": "garden",
+	}
+	for name, body := range files {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	near := func(got, want float32) bool { return math.Abs(float64(got-want)) < 1e-4 }
+	// The boost a word earns in 4 chunks when df of them hold it.
+	boost := func(df float64) float32 {
+		return float32(minTermWeight+(maxTermWeight-minTermWeight)*math.Log(5/(df+1))/math.Log(2.5)) * KeywordBoost
+	}
+
+	ex, err := idx.Explain("wireguard tunnel", filepath.Join(dir, "c.md"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ex.Indexed || ex.Rank != 3 || ex.ChunkRank != 3 || ex.Candidates != 4 || ex.Files != 4 {
+		t.Errorf("Indexed=%v Rank=%d ChunkRank=%d Candidates=%d Files=%d, want true 3 3 4 4",
+			ex.Indexed, ex.Rank, ex.ChunkRank, ex.Candidates, ex.Files)
+	}
+	if len(ex.Chunks) != 1 {
+		t.Fatalf("got %d chunks of c.md, want 1", len(ex.Chunks))
+	}
+	c := ex.Chunks[0]
+	if !near(c.Vector, float32(1/math.Sqrt2)) || !near(c.Keyword, boost(3)) || !near(c.Score, c.Vector+c.Keyword) {
+		t.Errorf("c.md scored %+v, want vector 1/√2 and the boost for tunnel", c)
+	}
+	if len(c.Matched) != 1 || c.Matched[0] != "tunnel" {
+		t.Errorf("c.md matched %q, want [tunnel]", c.Matched)
+	}
+	if len(ex.Ahead) != 2 || filepath.Base(ex.Ahead[0].Path) != "a.md" || filepath.Base(ex.Ahead[1].Path) != "b.md" {
+		t.Fatalf("Ahead = %+v, want a.md then b.md", ex.Ahead)
+	}
+	if b := ex.Ahead[1]; !near(b.Vector, float32(3/math.Sqrt(10))) || !near(b.Keyword, boost(2)+boost(3)) {
+		t.Errorf("b.md scored %+v, want vector 3/√10 and the boosts for wireguard and tunnel", b)
+	}
+
+	// A pool too small to reach the file still lists what was found.
+	ex, err = idx.Explain("wireguard tunnel", filepath.Join(dir, "c.md"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ex.Indexed || ex.Rank != 0 || len(ex.Chunks) != 0 || len(ex.Ahead) != 1 {
+		t.Errorf("pool 1: Indexed=%v Rank=%d chunks=%d ahead=%d, want true 0 0 1",
+			ex.Indexed, ex.Rank, len(ex.Chunks), len(ex.Ahead))
+	}
+
+	ex, err = idx.Explain("wireguard tunnel", filepath.Join(dir, "missing.md"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Indexed || ex.Rank != 0 {
+		t.Errorf("missing.md: Indexed=%v Rank=%d, want false 0", ex.Indexed, ex.Rank)
+	}
+}
+
+// countingEmbedder is mockEmbedder counting the texts it embeds.
+type countingEmbedder struct {
+	mockEmbedder
+	n int
+}
+
+func (e *countingEmbedder) Embed(texts []string) ([][]float32, error) {
+	e.n += len(texts)
+	return e.mockEmbedder.Embed(texts)
+}
+
+func TestIndex_ReindexFile(t *testing.T) {
+	dir := t.TempDir()
+	emb := &countingEmbedder{}
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), emb)
+	doc := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(doc, []byte("wireguard config lives here"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.AddFile(doc); err != nil {
+		t.Fatal(err)
+	}
+	if skipped, _ := idx.AddFile(doc); !skipped || emb.n != 1 {
+		t.Fatalf("unchanged file: skipped=%v after %d embeds, want skipped after 1", skipped, emb.n)
+	}
+
+	// Same mtime, new content: only a forced re-index picks it up.
+	info, _ := os.Stat(doc)
+	if err := os.WriteFile(doc, []byte("tailscale replaced it"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(doc, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	n, err := idx.ReindexFile(context.Background(), doc)
+	if err != nil || n != 1 {
+		t.Fatalf("ReindexFile = %d, %v; want 1 chunk", n, err)
+	}
+	if emb.n != 2 {
+		t.Errorf("embedded %d texts, want the file embedded again", emb.n)
+	}
+	if got := idx.FileChunks(doc); len(got) != 1 || !strings.Contains(got[0].Text, "tailscale") {
+		t.Errorf("chunks after ReindexFile = %+v", got)
+	}
+	if files := idx.Files(); len(files) != 1 || files[0] != doc {
+		t.Errorf("Files() = %q, want [%s]", files, doc)
+	}
+
+	// A read-only index keeps the file it cannot re-index.
+	idx.SetReadOnly(true)
+	if _, err := idx.ReindexFile(context.Background(), doc); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("read-only: err = %v, want ErrReadOnly", err)
+	}
+	_, cached := idx.fileCache[idx.pathKey(doc)]
+	if got := idx.FileChunks(doc); len(got) != 1 || !cached {
+		t.Errorf("read-only ReindexFile left %d chunks, cached %v; want the file untouched", len(got), cached)
+	}
+	idx.SetReadOnly(false)
+
+	if _, err := idx.ReindexFile(context.Background(), filepath.Join(dir, "gone.md")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: err = %v, want ErrNotExist", err)
+	}
+	bin := filepath.Join(dir, "blob.bin")
+	if err := os.WriteFile(bin, []byte{0, 1, 2}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.ReindexFile(context.Background(), bin); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("unsupported file: err = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestIndex_RemoveFile(t *testing.T) {
+	dir := t.TempDir()
+	emb := &countingEmbedder{}
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), emb)
+	var docs []string
+	for _, name := range []string{"keep.md", "gone.md"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("wireguard config lives here"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, p)
+	}
+	keep, gone := docs[0], docs[1]
+	// Deleted from disk: the index still knows it, until removed.
+	if err := os.Remove(gone); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := idx.RemoveFile(gone); err != nil || n != 1 {
+		t.Fatalf("RemoveFile = %d, %v; want 1 chunk", n, err)
+	}
+	if files := idx.Files(); len(files) != 1 || files[0] != keep {
+		t.Errorf("Files() = %q, want [%s]", files, keep)
+	}
+	if res, _ := idx.Search(context.Background(), "wireguard", 5); len(res) != 1 || res[0].Meta.Path != keep {
+		t.Errorf("search after RemoveFile = %+v, want only %s", res, keep)
+	}
+	if _, err := idx.RemoveFile(gone); !errors.Is(err, ErrNotIndexed) {
+		t.Errorf("removing it again: err = %v, want ErrNotIndexed", err)
+	}
+
+	// The graph was not rebuilt: the chunk is only marked removed until
+	// Flush drops it.
+	if n, del := idx.graph.Len(), idx.graph.Deleted(); n != 2 || del != 1 || idx.Stats().NumChunks != 1 {
+		t.Errorf("before Flush: %d nodes, %d deleted, %d chunks; want 2, 1, 1", n, del, idx.Stats().NumChunks)
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n, del := idx.graph.Len(), idx.graph.Deleted(); n != 1 || del != 0 || len(idx.chunks) != 1 {
+		t.Errorf("after Flush: %d nodes, %d deleted, %d chunks; want 1, 0, 1", n, del, len(idx.chunks))
+	}
+	reopened, err := OpenReadOnly(filepath.Join(dir, ".sift"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := reopened.Files(); len(files) != 1 || files[0] != keep {
+		t.Errorf("reopened: Files() = %q, want [%s]", files, keep)
+	}
+	reopened.Close()
+
+	// Its skip-cache entry went with it: indexed again, it is embedded.
+	if _, err := idx.RemoveFile(keep); err != nil {
+		t.Fatal(err)
+	}
+	if skipped, err := idx.AddFile(keep); skipped || err != nil || emb.n != 3 {
+		t.Errorf("AddFile after RemoveFile: skipped=%v, %v after %d embeds; want embedded again", skipped, err, emb.n)
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if c := idx.FileChunks(keep); idx.graph.Len() != 1 || len(c) != 1 || c[0].Text != "wireguard config lives here" {
+		t.Errorf("after re-adding and Flush: %d nodes, chunks %+v", idx.graph.Len(), c)
+	}
+	if res, _ := idx.Search(context.Background(), "wireguard", 5); len(res) != 1 || res[0].Meta.Path != keep {
+		t.Errorf("search after re-adding = %+v, want %s", res, keep)
+	}
+}
+
+func TestIndex_RemoveFileReaddedChunkIDs(t *testing.T) {
+	dir := t.TempDir()
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), wordEmbedder{})
+	p := filepath.Join(dir, "big.md")
+	if err := os.WriteFile(p, []byte(strings.Repeat("the tunnel runs under the garden wall.\n\n", 150)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.AddFile(p); err != nil {
+		t.Fatal(err)
+	}
+	chunks := idx.FileChunks(p)
+	if len(chunks) < 3 {
+		t.Fatalf("big.md has %d chunks, want at least 3", len(chunks))
+	}
+	id := chunks[1].ID
+
+	// Removed and added again before a Flush, the file has the same chunk
+	// IDs as the chunks still marked removed; lookups find the live ones.
+	if _, err := idx.RemoveFile(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := idx.GetChunk(id); ok {
+		t.Error("GetChunk found a removed chunk")
+	}
+	if _, err := idx.AddFile(p); err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := idx.GetChunk(id); !ok || c.removed || c.ChunkIndex != 1 {
+		t.Errorf("GetChunk after re-adding = %+v, %v; want the live chunk 1", c, ok)
+	}
+	if before, after := idx.AdjacentChunks(id, 1); len(before) != 1 || len(after) != 1 ||
+		before[0].ChunkIndex != 0 || after[0].ChunkIndex != 2 {
+		t.Errorf("AdjacentChunks after re-adding = %+v, %+v; want chunks 0 and 2", before, after)
+	}
+}
+
+func TestIndex_ChunkIDs(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, ".sift")
+	idx := NewTestIndex(dir, &mockEmbedder{})
+	files := map[string]string{
+		"a.md": "alpha notes about wireguard",
+		"b.md": "bravo notes about backups",
+		"c.md": "charlie notes about cron",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.IndexDir(context.Background(), root); err != nil {
+		t.Fatal(err)
+	}
+	ids := func(name string) []string {
+		var out []string
+		for _, c := range idx.FileChunks(filepath.Join(root, name)) {
+			out = append(out, c.ID)
+		}
+		return out
+	}
+	bIDs, cIDs := ids("b.md"), ids("c.md")
+	if len(bIDs) != 1 || len(bIDs[0]) != chunkIDLen || bIDs[0] == cIDs[0] {
+		t.Fatalf("IDs b=%q c=%q, want one distinct %d-digit ID each", bIDs, cIDs, chunkIDLen)
+	}
+
+	// Removing a.md compacts the index, shifting b's and c's positions.
+	if err := os.Remove(filepath.Join(root, "a.md")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := idx.PruneMissing(root); err != nil || n != 1 {
+		t.Fatalf("PruneMissing = %d, %v; want 1", n, err)
+	}
+	if got := ids("b.md"); !slices.Equal(got, bIDs) {
+		t.Errorf("b.md IDs after compaction = %q, want %q", got, bIDs)
+	}
+	c, ok := idx.GetChunk(bIDs[0])
+	if !ok || c.Path != filepath.Join(root, "b.md") || c.Text != files["b.md"] {
+		t.Errorf("GetChunk(%q) = %+v, %v", bIDs[0], c, ok)
+	}
+
+	// Re-indexing keeps an unchanged chunk's ID and replaces a changed one's.
+	if _, err := idx.ReindexFile(context.Background(), filepath.Join(root, "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids("b.md"); !slices.Equal(got, bIDs) {
+		t.Errorf("b.md IDs after re-indexing = %q, want %q", got, bIDs)
+	}
+	if err := os.WriteFile(filepath.Join(root, "c.md"), []byte("charlie moved to systemd timers"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.ReindexFile(context.Background(), filepath.Join(root, "c.md")); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids("c.md"); slices.Equal(got, cIDs) {
+		t.Errorf("c.md kept ID %q after its text changed", got)
+	}
+	if _, ok := idx.GetChunk(cIDs[0]); ok {
+		t.Errorf("GetChunk found the stale ID %q", cIDs[0])
+	}
+
+	// IDs are saved, and backfilled for indexes written without them.
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := OpenMeta(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := m.GetChunk(bIDs[0]); !ok || c.Path != filepath.Join(root, "b.md") {
+		t.Errorf("GetChunk after reopening = %+v, %v", c, ok)
+	}
+	legacy := m.FileChunks(filepath.Join(root, "b.md"))
+	legacy[0].ID, legacy[0].Path = "", m.storedPath(legacy[0].Path)
+	fillChunkIDs(legacy)
+	if legacy[0].ID != bIDs[0] {
+		t.Errorf("backfilled ID = %q, want %q", legacy[0].ID, bIDs[0])
+	}
+}
+
+func TestIndex_SetPreview(t *testing.T) {
+	dir := t.TempDir()
+	body := "wireguard config lives here — ünïcode included"
+	doc := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(doc, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		preview   int
+		text      string
+		truncated bool
+	}{
+		{10, "wireguard ", true},
+		{32, "wireguard config lives here — ün", true},
+		{1000, body, false},
+		{0, body, false}, // full
+	} {
+		siftDir := filepath.Join(dir, fmt.Sprintf(".sift-%d", tc.preview))
+		idx := NewTestIndex(siftDir, &mockEmbedder{})
+		idx.SetPreview(tc.preview)
+		if _, err := idx.AddFile(doc); err != nil {
+			t.Fatal(err)
+		}
+		if err := idx.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Reload from disk: the stored text and the manifest round-trip.
+		ro, err := OpenReadOnly(siftDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunks := ro.FileChunks(doc)
+		if len(chunks) != 1 {
+			t.Fatalf("preview %d: %d chunks, want 1", tc.preview, len(chunks))
+		}
+		c := chunks[0]
+		if c.Text != tc.text || c.Truncated != tc.truncated {
+			t.Errorf("preview %d: Text=%q Truncated=%v, want %q %v", tc.preview, c.Text, c.Truncated, tc.text, tc.truncated)
+		}
+		if full, err := c.FullText(); err != nil || full != body {
+			t.Errorf("preview %d: FullText = %q, %v; want the whole chunk", tc.preview, full, err)
+		}
+		if m := ro.Manifest(); m.Preview != tc.preview {
+			t.Errorf("preview %d: manifest records %d", tc.preview, m.Preview)
+		}
+		ro.Close()
+	}
+}
+
+func TestIndex_Refresh(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	writer := NewTestIndex(siftDir, &mockEmbedder{})
+	add := func(name string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("content of "+name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("a.md")
+
+	reader, err := OpenReadOnly(siftDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := reader.Refresh(); ok || err != nil {
+		t.Errorf("Refresh of an unchanged index = %v, %v; want false", ok, err)
+	}
+
+	add("b.md")
+	if ok, err := reader.Refresh(); !ok || err != nil {
+		t.Fatalf("Refresh after another process flushed = %v, %v; want true", ok, err)
+	}
+	if s := reader.Stats(); s.NumFiles != 2 || s.NumChunks != 2 {
+		t.Errorf("after Refresh: %d files, %d chunks; want 2, 2", s.NumFiles, s.NumChunks)
+	}
+	if ok, _ := reader.Refresh(); ok {
+		t.Error("second Refresh reloaded again")
+	}
+
+	// Unflushed changes of its own are never thrown away.
+	add("c.md")
+	p := filepath.Join(dir, "d.md")
+	if err := os.WriteFile(p, []byte("local only"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	local := NewTestIndex(siftDir, &mockEmbedder{})
+	if _, err := local.AddFile(p); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := local.Refresh(); ok {
+		t.Error("Refresh replaced an index with unflushed changes")
+	}
+}
+
+func TestIndex_RefreshAfterSettings(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	add := func(idx *Index, name string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("content of "+name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+		if err := idx.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writer := NewTestIndex(siftDir, &mockEmbedder{})
+	add(writer, "a.md")
+
+	// A long-lived process applies its settings, then another saves.
+	server, err := OpenReadOnly(siftDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.SetPreview(40)
+	if err := server.SetPatterns([]string{"*.log"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	add(writer, "b.md")
+
+	if ok, err := server.Refresh(); !ok || err != nil {
+		t.Fatalf("Refresh after applying settings = %v, %v; want a reload", ok, err)
+	}
+	if m := server.Manifest(); m.Preview != 40 || !slices.Equal(m.Exclude, []string{"*.log"}) {
+		t.Errorf("reloaded manifest has preview %d, exclude %v; want the settings applied before", m.Preview, m.Exclude)
+	}
+	if err := server.Close(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := OpenReadOnly(siftDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := saved.Stats().NumFiles; n != 2 {
+		t.Errorf("saved index holds %d files after the server closed, want 2", n)
+	}
+}
+
+func TestIndex_MetaCompression(t *testing.T) {
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(doc, []byte("wireguard config lives here"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		mode MetaCompression
+		gzip bool
+	}{
+		{"auto", CompressAuto, false}, // far below MetaCompressThreshold
+		{"always", CompressAlways, true},
+		{"never", CompressNever, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			siftDir := filepath.Join(dir, ".sift-"+tc.name)
+			idx := NewTestIndex(siftDir, &mockEmbedder{})
+			idx.SetMetaCompression(tc.mode)
+			if _, err := idx.AddFile(doc); err != nil {
+				t.Fatal(err)
+			}
+			if err := idx.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			raw, err := os.ReadFile(filepath.Join(siftDir, metaFile))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := bytes.HasPrefix(raw, gzipMagic); got != tc.gzip {
+				t.Fatalf("meta.json gzipped = %v, want %v", got, tc.gzip)
+			}
+
+			loaded, err := OpenReadOnly(siftDir)
+			if err != nil {
+				t.Fatalf("reopen: %v", err)
+			}
+			got := loaded.FileChunks(doc)
+			if len(got) != 1 || got[0].Text != "wireguard config lives here" {
+				t.Errorf("chunks after reopen = %+v", got)
+			}
+			s := loaded.Stats()
+			if s.MetaCompressed != tc.gzip || s.MetaBytes == 0 {
+				t.Errorf("Stats: compressed=%v, %d bytes", s.MetaCompressed, s.MetaBytes)
+			}
+			if tc.gzip && s.Artifacts[metaFile] >= s.MetaBytes {
+				t.Errorf("stored %d bytes for %d bytes of JSON", s.Artifacts[metaFile], s.MetaBytes)
+			}
+		})
+	}
+
+	t.Run("corrupt gzip", func(t *testing.T) {
+		siftDir := filepath.Join(dir, ".sift-corrupt")
+		if err := os.MkdirAll(siftDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		bad := append(append([]byte{}, gzipMagic...), "not really gzip"...)
+		if err := os.WriteFile(filepath.Join(siftDir, metaFile), bad, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OpenReadOnly(siftDir); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("OpenReadOnly = %v, want ErrCorrupt", err)
+		}
+	})
+}
+
+func TestLoad_LowMemory(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	write := func(name, body string) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	idx := NewTestIndex(siftDir, wordEmbedder{})
+	want := map[string]string{}
+	for _, w := range []string{"wireguard", "tunnel", "garden"} {
+		p := write(w+".md", w)
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+		want[w] = p
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lazy, err := load(siftDir, "", lazyGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !lazy.graph.Lazy() {
+		t.Fatal("graph not loaded lazily")
+	}
+	lazy.embedder = wordEmbedder{}
+	lazy.maxFileSizeBytes = idx.maxFileSizeBytes
+	if _, err := lazy.AddFile(write("kettle.md", "kettle")); err != nil {
+		t.Fatal(err)
+	}
+	want["kettle"] = filepath.Join(dir, "kettle.md")
+	for query, p := range want {
+		res, err := lazy.Search(context.Background(), query, 1)
+		if err != nil || len(res) != 1 || res[0].Meta.Path != p {
+			t.Errorf("lazy Search(%q) = %+v, %v; want %s", query, res, err, p)
+		}
+	}
+	if err := lazy.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	full, err := load(siftDir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full.embedder = wordEmbedder{}
+	for query, p := range want {
+		if res, err := full.Search(context.Background(), query, 1); err != nil || len(res) != 1 || res[0].Meta.Path != p {
+			t.Errorf("Search(%q) after saving the lazy index = %+v, %v; want %s", query, res, err, p)
+		}
+	}
+}
+
+func TestLoad_ReconcilesGraphAndMeta(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	idx := NewTestIndex(siftDir, wordEmbedder{})
+	add := func(name, body string) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+		if err := idx.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	read := func(name string) []byte {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(siftDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	a := add("a.md", "wireguard")
+	b := add("b.md", "tunnel")
+	oldMeta, oldGraph := read(metaFile), read(hnswFile)
+	c := add("c.md", "garden")
+	newMeta, newGraph := read(metaFile), read(hnswFile)
+
+	for _, tc := range []struct {
+		name        string
+		meta, graph []byte
+	}{
+		{"graph ahead", oldMeta, newGraph}, // crashed after writing hnsw.bin
+		{"meta ahead", newMeta, oldGraph},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Beside the original, so the stored paths name the same files.
+			d := filepath.Join(dir, ".sift-"+strings.ReplaceAll(tc.name, " ", "-"))
+			if err := os.MkdirAll(d, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			for name, data := range map[string][]byte{metaFile: tc.meta, hnswFile: tc.graph} {
+				if err := os.WriteFile(filepath.Join(d, name), data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got, err := load(d, "", fullGraph)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.chunks) != 2 || got.graph.Len() != 2 {
+				t.Fatalf("after load: %d chunks, %d nodes; want 2 and 2", len(got.chunks), got.graph.Len())
+			}
+			if s := got.Stats(); s.Repaired == "" || !got.dirty {
+				t.Errorf("repair not reported: Repaired=%q dirty=%v", s.Repaired, got.dirty)
+			}
+			if v, ok := got.fileCache[c]; ok && !v.mtime.IsZero() {
+				t.Errorf("c.md keeps its mtime, so the next index run would skip it")
+			}
+
+			got.embedder = wordEmbedder{}
+			for query, want := range map[string]string{"wireguard": a, "tunnel": b} {
+				res, err := got.Search(context.Background(), query, 1)
+				if err != nil || len(res) != 1 || res[0].Meta.Path != want || res[0].Vector < 0.99 {
+					t.Errorf("Search(%q) = %+v, %v; want %s with a perfect match", query, res, err, want)
+				}
+			}
+
+			if err := got.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			again, err := load(d, "", fullGraph)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.heal != nil || len(again.chunks) != again.graph.Len() {
+				t.Errorf("repair was not saved: %q, %d chunks, %d nodes", again.repairedString(), len(again.chunks), again.graph.Len())
+			}
+			if h := again.Manifest().LastHeal; h == nil || !strings.Contains(strings.Join(h.Actions, "; "), "kept the first 2") {
+				t.Errorf("manifest LastHeal = %+v, want the repair recorded", h)
+			}
+		})
+	}
+}
+
+func TestLoad_FinishesInterruptedSave(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	write := func(name, body string) string {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	a := write("a.md", "wireguard")
+	big := write("big.md", strings.Repeat("the tunnel runs under the garden wall.\n\n", 150))
+	names := []string{hnswFile, textFile, termsFile, metaFile, manifestFile, summaryFile}
+
+	// Save a.md, then big.md too, keeping the files of each save.
+	idx := NewTestIndex(siftDir, wordEmbedder{})
+	saved := func() map[string][]byte {
+		t.Helper()
+		if err := idx.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		files := make(map[string][]byte)
+		for _, name := range names {
+			data, err := os.ReadFile(filepath.Join(siftDir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[name] = data
+		}
+		return files
+	}
+	if _, err := idx.AddFile(a); err != nil {
+		t.Fatal(err)
+	}
+	before := saved()
+	if _, err := idx.AddFile(big); err != nil {
+		t.Fatal(err)
+	}
+	after := saved()
+	bigChunks := len(idx.FileChunks(big))
+
+	// crash lays out the files as a crash during the second save leaves
+	// them: the old files, the new ones staged beside them, the journal if
+	// it was saved, and the first renamed of the new files in place.
+	crash := func(journaled bool, renamed int) {
+		t.Helper()
+		for _, name := range names {
+			if err := os.WriteFile(filepath.Join(siftDir, name), before[name], 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(stagedPath(siftDir, name), after[name], 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if journaled {
+			if err := writeJournal(siftDir, names); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, name := range names[:renamed] {
+			if err := os.Rename(stagedPath(siftDir, name), filepath.Join(siftDir, name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		name      string
+		journaled bool
+		renamed   int
+		wantBig   int
+	}{
+		{"before the journal", false, 0, 0},
+		{"after the journal", true, 0, bigChunks},
+		{"midway through the renames", true, 2, bigChunks},
+		{"after the last rename", true, len(names), bigChunks},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			crash(tc.journaled, tc.renamed)
+			got, err := load(siftDir, "", fullGraph)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := len(got.FileChunks(big)); n != tc.wantBig {
+				t.Errorf("big.md has %d chunks after load, want %d", n, tc.wantBig)
+			}
+			if len(got.FileChunks(a)) != 1 || got.graph.Len() != len(got.chunks) {
+				t.Errorf("after load: a.md has %d chunks; %d chunks, %d nodes", len(got.FileChunks(a)), len(got.chunks), got.graph.Len())
+			}
+			if tc.journaled && tc.renamed < len(names) && got.Stats().Repaired == "" {
+				t.Error("finishing the save was not reported as a repair")
+			}
+			if _, err := os.Stat(filepath.Join(siftDir, journalFile)); !os.IsNotExist(err) {
+				t.Errorf("journal left behind after load: %v", err)
+			}
+		})
+	}
+}
+
+func TestLiveness(t *testing.T) {
+	dir := t.TempDir()
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), wordEmbedder{})
+	var paths []string
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("wireguard tunnel"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	if s := idx.Stats(); s.LivenessChecked || s.MissingFiles != nil {
+		t.Errorf("Stats checked liveness unasked: %+v", s.MissingFiles)
+	}
+	if s := idx.StatsWithLiveness(); !s.LivenessChecked || len(s.MissingFiles) != 0 {
+		t.Errorf("nothing deleted, but MissingFiles = %q", s.MissingFiles)
+	}
+
+	for _, p := range paths[1:] {
+		if err := os.Remove(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := idx.StatsWithLiveness()
+	if !slices.Equal(s.MissingFiles, paths[1:]) || s.NumFiles != 3 {
+		t.Errorf("MissingFiles = %q with %d files, want %q with 3 (not pruned yet)", s.MissingFiles, s.NumFiles, paths[1:])
+	}
+
+	// A fresh index is not pruned; one left alone longer than the age is.
+	if n := idx.PruneIfStale(time.Hour); n != 0 {
+		t.Errorf("PruneIfStale on a fresh index removed %d files", n)
+	}
+	if n := idx.PruneIfStale(0); n != 0 {
+		t.Errorf("PruneIfStale(0) removed %d files, want the pass skipped", n)
+	}
+	idx.lastUpdated = time.Now().Add(-2 * time.Hour)
+	if n := idx.PruneIfStale(time.Hour); n != 2 {
+		t.Errorf("PruneIfStale removed %d files, want 2", n)
+	}
+	s = idx.Stats()
+	if s.NumFiles != 1 || len(s.MissingFiles) != 0 || len(idx.fileCache) != 1 {
+		t.Errorf("after pruning: %d files, missing %q, %d cached; want 1, none, 1", s.NumFiles, s.MissingFiles, len(idx.fileCache))
+	}
+	if !idx.dirty {
+		t.Error("pruning did not mark the index dirty")
+	}
+}
+
+// poisonEmbedder is mockEmbedder failing on any text containing "poison".
+type poisonEmbedder struct{ mockEmbedder }
+
+func (e *poisonEmbedder) Embed(texts []string) ([][]float32, error) {
+	for _, t := range texts {
+		if strings.Contains(t, "poison") {
+			return nil, errors.New("poisoned")
+		}
+	}
+	return e.mockEmbedder.Embed(texts)
+}
+
+func TestRunStats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.md":      "wireguard config lives here",
+		"b.go":      "package b\n\nfunc B() {}",
+		"c.md":      strings.Repeat("the tunnel runs under the garden wall.\n\n", 100),
+		"bad.md":    "this chunk is poison",
+		"empty.txt": "",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), &poisonEmbedder{})
+	idx.SetLogger(nil)
+
+	idx.BeginRun()
+	if err := idx.IndexDir(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	r := idx.RunStats()
+	if r.FilesEmbedded != 3 || r.FilesErrored != 1 || r.FilesSkipped != 1 {
+		t.Errorf("files: %d embedded, %d skipped, %d errored; want 3, 1, 1", r.FilesEmbedded, r.FilesSkipped, r.FilesErrored)
+	}
+	if s := idx.Stats(); r.Chunks != s.NumChunks || r.Chunks <= 3 {
+		t.Errorf("run counted %d chunks, index holds %d", r.Chunks, s.NumChunks)
+	}
+	var bytes int64
+	for _, name := range []string{"a.md", "b.go", "c.md"} {
+		bytes += int64(len(files[name]))
+	}
+	if r.Bytes != bytes {
+		t.Errorf("run counted %d bytes, want %d", r.Bytes, bytes)
+	}
+	var phases time.Duration
+	for _, p := range RunPhases {
+		if p != PhaseTokenize && p != PhaseInference { // part of embed
+			phases += r.Phases[p]
+		}
+	}
+	if r.Started.IsZero() || phases > r.Elapsed {
+		t.Errorf("phases add up to %v of a %v run started %v", phases, r.Elapsed, r.Started)
+	}
+	if r.Phases[PhaseWalk] == 0 || r.Phases[PhaseFlush] == 0 {
+		t.Errorf("walk %v, flush %v; want both timed", r.Phases[PhaseWalk], r.Phases[PhaseFlush])
+	}
+
+	// A second run over unchanged files skips them all and starts afresh.
+	idx.BeginRun()
+	if err := idx.IndexDir(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	r = idx.RunStats()
+	if r.FilesEmbedded != 0 || r.FilesSkipped != 4 || r.FilesErrored != 1 || r.Chunks != 0 || r.Bytes != 0 {
+		t.Errorf("second run: %+v; want 4 skipped, the poisoned file failing again, nothing embedded", r)
+	}
+}
+
+// truncatingEmbedder reports texts over maxBytes as truncated, as a
+// tokenizer cutting at its maximum sequence length would.
+type truncatingEmbedder struct {
+	mockEmbedder
+	maxBytes int
+}
+
+func (e *truncatingEmbedder) EmbedTruncating(texts []string) ([][]float32, []bool, error) {
+	vecs, err := e.Embed(texts)
+	cut := make([]bool, len(texts))
+	for i, t := range texts {
+		cut[i] = len(t) > e.maxBytes
+	}
+	return vecs, cut, err
+}
+
+func TestIndex_EmbedTruncation(t *testing.T) {
+	dir := t.TempDir()
+	// Default chunks are up to 1200 bytes: long.md has several near that,
+	// short.md one well under the 600-byte "token" limit.
+	files := map[string]string{
+		"long.md":  strings.Repeat("the tunnel runs under the garden wall.\n\n", 100),
+		"short.md": "wireguard config lives here",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), &truncatingEmbedder{maxBytes: 600})
+	idx.BeginRun()
+	if err := idx.IndexDir(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+
+	want := 0
+	for _, c := range idx.FileChunks(filepath.Join(dir, "long.md")) {
+		if c.EmbedTruncated != (c.EndByte-c.StartByte > 600) {
+			t.Errorf("chunk %d of %d bytes: EmbedTruncated = %v", c.ChunkIndex, c.EndByte-c.StartByte, c.EmbedTruncated)
+		}
+		if c.EmbedTruncated {
+			want++
+		}
+	}
+	if want == 0 {
+		t.Fatal("no chunk of long.md is over the limit")
+	}
+	if c := idx.FileChunks(filepath.Join(dir, "short.md")); len(c) != 1 || c[0].EmbedTruncated {
+		t.Errorf("short.md chunks = %+v, want one untruncated", c)
+	}
+	if r := idx.RunStats(); r.ChunksTruncated != want || r.FilesTruncated != 1 {
+		t.Errorf("RunStats: %d chunks truncated in %d files, want %d in 1", r.ChunksTruncated, r.FilesTruncated, want)
+	}
+	if s := idx.Stats(); s.TruncatedChunks != want || s.TruncatedFiles != 1 {
+		t.Errorf("Stats: %d chunks truncated in %d files, want %d in 1", s.TruncatedChunks, s.TruncatedFiles, want)
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := ReadManifest(filepath.Join(dir, ".sift")); err != nil || m.TruncatedChunks != want || m.TruncatedFiles != 1 {
+		t.Errorf("manifest = %+v, %v; want %d chunks truncated in 1 file", m, err, want)
+	}
+
+	// The flags are saved, and an unchanged file is not counted again.
+	again, err := load(filepath.Join(dir, ".sift"), "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.embedder = &truncatingEmbedder{maxBytes: 600}
+	if s := again.Stats(); s.TruncatedChunks != want {
+		t.Errorf("Stats after reopening: %d chunks truncated, want %d", s.TruncatedChunks, want)
+	}
+	again.BeginRun()
+	if err := again.IndexDir(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	if r := again.RunStats(); r.ChunksTruncated != 0 {
+		t.Errorf("a run over unchanged files counted %d truncated chunks", r.ChunksTruncated)
+	}
+}
+
+func TestIndex_EmptyQuery(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a.md")
+	if err := os.WriteFile(path, []byte("wireguard tunnel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	e := &recordingEmbedder{}
+	idx := NewTestIndex(filepath.Join(root, ".sift"), e)
+	idx.SetPrefixes(embed.Prefixes{})
+	if _, err := idx.AddFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, q := range []string{"", "   ", "\t\n "} {
+		if _, err := idx.Search(context.Background(), q, 5); !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("Search(%q) = %v, want ErrEmptyQuery", q, err)
+		}
+		if _, err := idx.Explain(q, path, 0); !errors.Is(err, ErrEmptyQuery) {
+			t.Errorf("Explain(%q) = %v, want ErrEmptyQuery", q, err)
+		}
+	}
+	if len(e.queries) != 0 {
+		t.Errorf("empty queries were embedded: %q", e.queries)
+	}
+
+	// Whitespace is trimmed and collapsed before embedding.
+	if res, err := idx.Search(context.Background(), "  wireguard \t\n tunnel ", 5); err != nil || len(res) != 1 {
+		t.Fatalf("Search = %+v, %v; want one result", res, err)
+	}
+	out := idx.SearchBatch([]string{" ", "wireguard   tunnel"}, 5)
+	if !errors.Is(out[0].Err, ErrEmptyQuery) || out[1].Err != nil || len(out[1].Results) != 1 {
+		t.Errorf("SearchBatch = %+v", out)
+	}
+	if want := []string{"wireguard tunnel", "wireguard tunnel"}; !slices.Equal(e.queries, want) {
+		t.Errorf("queries embedded as %q, want %q", e.queries, want)
+	}
+}
+
+// dirState maps each file in dir to its size and mtime.
+func dirState(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := make(map[string]string, len(entries))
+	for _, e := range entries {
+		fi, err := e.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		state[e.Name()] = fmt.Sprintf("%d %s", fi.Size(), fi.ModTime())
+	}
+	return state
+}
+
+func TestIndex_ReadOnly(t *testing.T) {
+	root := t.TempDir()
+	siftDir := filepath.Join(root, ".sift")
+	doc := filepath.Join(root, "a.md")
+	if err := os.WriteFile(doc, []byte("wireguard"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writer := NewTestIndex(siftDir, &mockEmbedder{})
+	if _, err := writer.AddFile(doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// A missing manifest is recreated on load: a change to save.
+	if err := os.Remove(filepath.Join(siftDir, manifestFile)); err != nil {
+		t.Fatal(err)
+	}
+	before := dirState(t, siftDir)
+	if err := os.Chmod(siftDir, 0o555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(siftDir, 0o755) })
+
+	idx, err := load(siftDir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.embedder = &mockEmbedder{}
+	idx.SetReadOnly(true)
+	if res, err := idx.Search(context.Background(), "wireguard", 5); err != nil || len(res) != 1 {
+		t.Errorf("Search = %+v, %v; want one result", res, err)
+	}
+	if _, err := idx.AddFile(doc); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("AddFile = %v, want ErrReadOnly", err)
+	}
+	if err := idx.Flush(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Flush of a repaired index = %v, want ErrReadOnly", err)
+	}
+	if err := idx.Close(); err != nil {
+		t.Errorf("Close = %v, want it to drop the repair without writing", err)
+	}
+
+	ro, err := OpenReadOnly(siftDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ro.Search(context.Background(), "wireguard", 5); !errors.Is(err, ErrNoModel) {
+		t.Errorf("Search without the model = %v, want ErrNoModel", err)
+	}
+	if err := ro.Close(); err != nil {
+		t.Errorf("Close = %v", err)
+	}
+
+	// Permissions do not stop root, so compare the files too.
+	if after := dirState(t, siftDir); !maps.Equal(before, after) {
+		t.Errorf("read-only index wrote to disk: before %v, after %v", before, after)
+	}
+}
+
+func TestIndex_AdjacentChunks(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, body string) string {
+		t.Helper()
+		p := filepath.Join(root, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	a := write("a.md", "wireguard")
+	big := write("big.md", strings.Repeat("the tunnel runs under the garden wall.\n\n", 150))
+	idx := NewTestIndex(filepath.Join(root, ".sift"), &mockEmbedder{})
+	for _, p := range []string{a, big} {
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chunks := idx.FileChunks(big)
+	if len(chunks) < 5 {
+		t.Fatalf("big.md has %d chunks, want at least 5", len(chunks))
+	}
+	indexes := func(cs []ChunkMeta) []int {
+		var out []int
+		for _, c := range cs {
+			out = append(out, c.ChunkIndex)
+		}
+		return out
+	}
+	check := func(c ChunkMeta, n int, wantBefore, wantAfter []int) {
+		t.Helper()
+		before, after := idx.AdjacentChunks(c.ID, n)
+		if got := indexes(before); !slices.Equal(got, wantBefore) {
+			t.Errorf("chunk %d, n=%d: before = %v, want %v", c.ChunkIndex, n, got, wantBefore)
+		}
+		if got := indexes(after); !slices.Equal(got, wantAfter) {
+			t.Errorf("chunk %d, n=%d: after = %v, want %v", c.ChunkIndex, n, got, wantAfter)
+		}
+		for _, adj := range append(before, after...) {
+			if adj.Path != big {
+				t.Errorf("adjacent chunk from %s, want %s", adj.Path, big)
+			}
+		}
+	}
+	last := len(chunks) - 1
+	check(chunks[2], 1, []int{1}, []int{3})
+	check(chunks[2], 2, []int{0, 1}, []int{3, 4})
+	check(chunks[0], 3, nil, []int{1, 2, 3})
+	check(chunks[last], 1, []int{last - 1}, nil)
+	check(idx.FileChunks(a)[0], 2, nil, nil)
+	if before, after := idx.AdjacentChunks("nope", 1); before != nil || after != nil {
+		t.Errorf("unknown ID: %v, %v; want nothing", before, after)
+	}
+
+	// Re-indexing a.md after a change moves big.md's chunks to other IDs;
+	// re-indexing big.md shortened replaces its chunks.
+	later := time.Now().Add(time.Minute)
+	for _, p := range []string{a, write("big.md", strings.Repeat("the tunnel runs under the garden wall.\n\n", 60))} {
+		if err := os.Chtimes(p, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := idx.AddFile(a); err != nil {
+		t.Fatal(err)
+	}
+	check(chunks[2], 1, []int{1}, []int{3})
+	if _, err := idx.AddFile(big); err != nil {
+		t.Fatal(err)
+	}
+	chunks = idx.FileChunks(big)
+	if got := indexes(chunks); len(got) < 2 || len(got) >= last+1 || !slices.IsSorted(got) {
+		t.Fatalf("big.md chunks after re-indexing = %v", got)
+	}
+	last = len(chunks) - 1
+	check(chunks[last], 2, []int{last - 2, last - 1}, nil)
+}
+
+// priorityEmbedder records the priority of each EmbedAt call.
+type priorityEmbedder struct {
+	wordEmbedder
+	prios []embed.Priority
+}
+
+func (e *priorityEmbedder) EmbedAt(texts []string, p embed.Priority) ([][]float32, []bool, error) {
+	e.prios = append(e.prios, p)
+	vecs, err := e.Embed(texts)
+	return vecs, nil, err
+}
+
+func TestIndex_EmbedPriority(t *testing.T) {
+	dir := t.TempDir()
+	emb := &priorityEmbedder{}
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), emb)
+	for _, name := range []string{"a.md", "b.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("wireguard "+name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := idx.AddFile(filepath.Join(dir, "a.md")); err != nil {
+		t.Fatal(err)
+	}
+	bg := WithEmbedPriority(context.Background(), embed.PriorityBackground)
+	if _, err := idx.AddFileCtx(bg, filepath.Join(dir, "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	if want := []embed.Priority{embed.PriorityManual, embed.PriorityBackground}; !slices.Equal(emb.prios, want) {
+		t.Errorf("embedded at %v, want %v", emb.prios, want)
+	}
+}
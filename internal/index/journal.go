@@ -0,0 +1,104 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+const journalFile = "journal.json"
+
+// Flush replaces several files, and a crash between two of them would
+// leave, say, a new hnsw.bin beside the old meta.json: files indexed
+// since the last save half there, and graph nodes no longer matching
+// chunks. So Flush first writes every file in full beside the one it
+// replaces (see stagedPath), then saves the journal listing them, which
+// commits the save, and only then renames them into place and removes
+// the journal. A load that finds the journal finishes the renames (see
+// finishSave), so the index on disk is always as one Flush or the next
+// left it, never a mix of the two.
+
+// stagedPath returns where Flush writes the new version of the index file
+// name before renaming it into place.
+func stagedPath(dir, name string) string {
+	return filepath.Join(dir, name+".new")
+}
+
+// writeJournal saves names, the staged files of a save, as the journal
+// (tmp → sync → rename).
+func writeJournal(dir string, names []string) error {
+	data, err := json.Marshal(names)
+	if err != nil {
+		return fmt.Errorf("marshal journal: %w", err)
+	}
+	path := filepath.Join(dir, journalFile)
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("write journal tmp: %w", err)
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write journal: %w", err)
+	}
+	return nil
+}
+
+// commitSave renames the staged files names into place, in order, and
+// then removes the journal. A staged file already gone was renamed
+// before a crash, or by another process finishing the same save.
+func commitSave(dir string, names []string) error {
+	for _, name := range names {
+		err := os.Rename(stagedPath(dir, name), filepath.Join(dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("save %s: %w", name, err)
+		}
+	}
+	if err := os.Remove(filepath.Join(dir, journalFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove journal: %w", err)
+	}
+	return nil
+}
+
+// finishSave completes the save whose journal dir holds, if any, and
+// returns the files it renamed into place. It is the one write opening
+// an index makes: until it is done, the index is neither the old one nor
+// the new one.
+func finishSave(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, journalFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, &CorruptError{journalFile, err}
+	}
+	var pending []string
+	for _, name := range names {
+		if !slices.Contains(ArtifactFiles, name) {
+			return nil, &CorruptError{journalFile, fmt.Errorf("%q is not an index file", name)}
+		}
+		if _, err := os.Stat(stagedPath(dir, name)); err == nil {
+			pending = append(pending, name)
+		}
+	}
+	if err := commitSave(dir, names); err != nil {
+		return nil, fmt.Errorf("finish the save a crash interrupted: %w", err)
+	}
+	return pending, nil
+}
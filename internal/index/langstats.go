@@ -0,0 +1,83 @@
+package index
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/tejas242/sift/internal/lang"
+)
+
+// English-only embedding models (BGE-small-en, the default) give poor
+// vectors for text in other languages, so index runs note the language
+// of every chunk they embed (see lang.Detect), and the run summary and
+// stats report how much of the index is not English.
+
+// LangStats counts the chunks detected as one language, and the files
+// they are in.
+type LangStats struct {
+	Files  int `json:"files"`
+	Chunks int `json:"chunks"`
+}
+
+// chunkLang returns what ChunkMeta.Lang records for text: its language,
+// or "" for English.
+func chunkLang(text string) string {
+	if l := lang.Detect(text); l != lang.English {
+		return l
+	}
+	return ""
+}
+
+// countLanguages breaks chunks down by language, keyed by ISO 639-1
+// code, English included.
+func countLanguages(chunks []ChunkMeta) map[string]LangStats {
+	out := make(map[string]LangStats)
+	files := make(map[string]map[string]bool) // language → paths
+	for _, c := range chunks {
+		l := c.Lang
+		if l == "" {
+			l = lang.English
+		}
+		ls := out[l]
+		ls.Chunks++
+		if files[l] == nil {
+			files[l] = make(map[string]bool)
+		}
+		if !files[l][c.Path] {
+			files[l][c.Path] = true
+			ls.Files++
+		}
+		out[l] = ls
+	}
+	return out
+}
+
+// langLog counts a run's chunks by language.
+type langLog struct {
+	mu     sync.Mutex
+	chunks map[string]int
+}
+
+// countLangs records the languages of the chunks of a file just
+// embedded, as ChunkMeta.Lang holds them.
+func (idx *Index) countLangs(langs []string) {
+	r := idx.counter()
+	r.langs.mu.Lock()
+	defer r.langs.mu.Unlock()
+	if r.langs.chunks == nil {
+		r.langs.chunks = make(map[string]int)
+	}
+	for _, l := range langs {
+		if l == "" {
+			l = lang.English
+		}
+		r.langs.chunks[l]++
+	}
+}
+
+// runLanguages returns the run's chunks by language.
+func (r *runCounter) runLanguages() map[string]int {
+	r.langs.mu.Lock()
+	defer r.langs.mu.Unlock()
+	return maps.Clone(r.langs.chunks)
+}
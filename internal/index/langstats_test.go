@@ -0,0 +1,62 @@
+package index
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndex_Languages(t *testing.T) {
+	root := t.TempDir()
+	siftDir := filepath.Join(root, ".sift")
+	idx := NewTestIndex(siftDir, &mockEmbedder{})
+	files := map[string]string{
+		"readme.md": "The index is stored next to the project, so that every run only has to embed the files that changed since the last one.",
+		"main.go":   "package main\n\n// main starts the server.\nfunc main() {\n\tserve(os.Args[1:])\n}\n",
+		"liesmich.md": "Die Konfiguration wird beim Start aus der Datei gelesen und kann über Umgebungsvariablen " +
+			"überschrieben werden, die für jeden Dienst einzeln gesetzt sind.",
+		"setup.md": "設定ファイルは起動時に読み込まれ、各値は環境変数で上書きできます。サービスを再起動する必要はありません。",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx.BeginRun()
+	if err := idx.IndexDir(t.Context(), root); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := idx.RunStats().Languages, map[string]int{"en": 2, "de": 1, "ja": 1}; !maps.Equal(got, want) {
+		t.Errorf("RunStats().Languages = %v, want %v", got, want)
+	}
+	want := map[string]LangStats{"en": {2, 2}, "de": {1, 1}, "ja": {1, 1}}
+	if got := idx.Stats().Languages; !maps.Equal(got, want) {
+		t.Errorf("Stats().Languages = %v, want %v", got, want)
+	}
+	for _, c := range idx.chunks {
+		if filepath.Base(c.Path) == "readme.md" && c.Lang != "" {
+			t.Errorf("readme.md: Lang = %q, want \"\" for English", c.Lang)
+		}
+	}
+
+	// The breakdown is saved in the manifest and survives reloading.
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	m, err := ReadManifest(siftDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(m.Languages, want) {
+		t.Errorf("manifest Languages = %v, want %v", m.Languages, want)
+	}
+	again, err := load(siftDir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := again.Stats().Languages; !maps.Equal(got, want) {
+		t.Errorf("Stats().Languages after reload = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,146 @@
+package index
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// livenessWorkers bounds how many files the liveness pass stats at once.
+const livenessWorkers = 8
+
+// missingFiles returns the paths that no longer exist on disk. Other stat
+// errors (permissions, an unmounted drive) do not count as missing.
+func missingFiles(paths []string) []string {
+	var missing []string
+	statFiles(paths, func(p string, _ fs.FileInfo, err error) {
+		if errors.Is(err, fs.ErrNotExist) {
+			missing = append(missing, p)
+		}
+	})
+	slices.Sort(missing)
+	return missing
+}
+
+// statFiles stats paths, up to livenessWorkers of them concurrently, and
+// calls fn with each result; calls to fn do not overlap.
+func statFiles(paths []string, fn func(path string, fi fs.FileInfo, err error)) {
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	next := make(chan string)
+	for range min(livenessWorkers, len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range next {
+				fi, err := os.Stat(p)
+				mu.Lock()
+				fn(p, fi, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, p := range paths {
+		next <- p
+	}
+	close(next)
+	wg.Wait()
+}
+
+// knownPaths returns every path the index holds chunks or a skip-cache
+// entry for. A file with both is named as its chunks name it; the
+// skip-cache key may be folded to lower case, which on the
+// case-insensitive filesystem that folding is for names the same file.
+func (idx *Index) knownPaths() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	seen := make(map[string]bool, len(idx.fileCache))
+	var paths []string
+	add := func(p string) {
+		if k := idx.pathKey(p); !seen[k] {
+			seen[k] = true
+			paths = append(paths, p)
+		}
+	}
+	for _, c := range idx.liveChunks() {
+		add(c.Path)
+	}
+	for p := range idx.fileCache {
+		add(p)
+	}
+	return paths
+}
+
+// CheckLiveness stats every indexed file and returns, sorted, those that
+// no longer exist. The result is remembered and reported by Stats as
+// MissingFiles until the next check or prune. The disk is read without
+// holding the lock, so searches carry on meanwhile.
+func (idx *Index) CheckLiveness() []string {
+	missing := missingFiles(idx.knownPaths())
+	idx.mu.Lock()
+	idx.missing = missing
+	idx.livenessChecked = true
+	idx.mu.Unlock()
+	return missing
+}
+
+// StatsWithLiveness is Stats after a CheckLiveness pass, so MissingFiles
+// is filled in. It stats every indexed file; use Stats on huge indexes
+// where that is too slow.
+func (idx *Index) StatsWithLiveness() Stats {
+	idx.CheckLiveness()
+	return idx.Stats()
+}
+
+// PruneIfStale runs the liveness pass when the index was last updated
+// more than age ago, and removes the chunks and skip-cache entries of the
+// files that are gone, so an index left alone for months does not keep
+// counting (and matching) deleted files. It returns the number of files
+// removed; age <= 0 skips the pass. Call Flush to persist the result.
+func (idx *Index) PruneIfStale(age time.Duration) int {
+	idx.mu.RLock()
+	updated := idx.lastUpdated
+	idx.mu.RUnlock()
+	if age <= 0 || updated.IsZero() || time.Since(updated) < age {
+		return 0
+	}
+	return len(idx.PruneDeleted())
+}
+
+// PruneDeleted runs the liveness pass and removes the chunks and
+// skip-cache entries of the files that are gone, returning them sorted.
+// Call Flush to persist the result.
+func (idx *Index) PruneDeleted() []string {
+	// pruneFiles edits idx.missing, which CheckLiveness returned.
+	missing := slices.Clone(idx.CheckLiveness())
+	idx.pruneFiles(missing)
+	return missing
+}
+
+// pruneFiles removes the chunks and skip-cache entries of paths.
+func (idx *Index) pruneFiles(paths []string) {
+	if len(paths) == 0 {
+		return
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	gone := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		gone[idx.pathKey(p)] = true
+		idx.log.Debugf("pruned %s", p)
+	}
+	isGone := func(p string) bool { return gone[idx.pathKey(p)] }
+	idx.removeChunksUnderLock(isGone)
+	idx.forgetOversizedUnderLock(isGone)
+	for k := range gone {
+		delete(idx.fileCache, k)
+	}
+	idx.missing = slices.DeleteFunc(idx.missing, isGone)
+	idx.dirty = true
+	idx.lastUpdated = time.Now()
+}
@@ -0,0 +1,112 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tejas242/sift/internal/embed"
+)
+
+const manifestFile = "manifest.json"
+
+// FormatVersion is the on-disk layout version written to the manifest.
+// Version 2 stores chunk paths relative to the index root; version 3
+// stores chunk text in text.bin.
+const FormatVersion = 3
+
+// Manifest records how an index was built so later runs (and `sift doctor`)
+// can detect a model or format mismatch.
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	Model         string    `json:"model"`
+	Dim           int       `json:"dim"`
+	ModelHash     string    `json:"model_hash,omitempty"`
+	Created       time.Time `json:"created"`
+	Updated       time.Time `json:"updated"`
+	// Exclude and IncludeOnly record the command-line globs the index was
+	// last built with (.siftignore patterns live in the tree itself).
+	Exclude     []string `json:"exclude,omitempty"`
+	IncludeOnly []string `json:"include_only,omitempty"`
+	// Preview is how many characters of each chunk's text are stored;
+	// 0 means the full text.
+	Preview int `json:"preview,omitempty"`
+	// Roots lists the directories indexed so far, as absolute paths, so
+	// they can be re-indexed or watched without naming them again.
+	Roots []string `json:"roots,omitempty"`
+	// LastHeal records the repairs made the last time the index was
+	// opened in an inconsistent state and then saved.
+	LastHeal *Heal `json:"last_heal,omitempty"`
+	// Oversized maps the files last skipped for exceeding the size limit
+	// to their size and that limit, so a changed limit can be applied to
+	// them; see RecheckOversized and PruneOversized.
+	Oversized map[string]Oversized `json:"oversized,omitempty"`
+	// TruncatedChunks and TruncatedFiles count, as of the last save, the
+	// chunks too long for the embedder's input and the files they are in;
+	// see ChunkMeta.EmbedTruncated.
+	TruncatedChunks int `json:"truncated_chunks,omitempty"`
+	TruncatedFiles  int `json:"truncated_files,omitempty"`
+	// SampledFiles counts, as of the last save, the files over the size
+	// limit indexed only in part; see ChunkMeta.Sampled.
+	SampledFiles int `json:"sampled_files,omitempty"`
+	// Languages breaks chunks down, as of the last save, by the language
+	// they were detected as; see ChunkMeta.Lang.
+	Languages map[string]LangStats `json:"languages,omitempty"`
+	// Slowest records the files that took longest to embed, slowest
+	// first, each with its latest cost; see SlowestFiles.
+	Slowest []FileCost `json:"slowest,omitempty"`
+	// Prefixes records the prefixes the vectors were embedded with; nil,
+	// in indexes from before it was recorded, means the defaults. See
+	// EmbedPrefixes.
+	Prefixes *embed.Prefixes `json:"prefixes,omitempty"`
+	// LastMaintenance is when Compact last ran, so processes sharing the
+	// index do not each run it; see LastMaintenance.
+	LastMaintenance time.Time `json:"last_maintenance,omitzero"`
+}
+
+// EmbedPrefixes returns the prefixes the index's vectors were embedded
+// with.
+func (m *Manifest) EmbedPrefixes() embed.Prefixes {
+	if m.Prefixes == nil {
+		return embed.DefaultPrefixes
+	}
+	return *m.Prefixes
+}
+
+// ReadManifest loads the manifest stored in dir. It returns an error
+// satisfying os.IsNotExist when the index has no manifest yet.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrCorrupt, manifestFile, err)
+	}
+	return &m, nil
+}
+
+// writeManifest atomically writes m to dir (tmp → rename).
+func writeManifest(dir string, m *Manifest) error {
+	return writeManifestTo(filepath.Join(dir, manifestFile), m)
+}
+
+// writeManifestTo atomically writes m to path.
+func writeManifestTo(path string, m *Manifest) error {
+	tmp := path + ".tmp"
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write manifest tmp: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename manifest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package index
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// MetaCompression selects when Flush gzips meta.json. Reading detects
+// the format from the file's first bytes, so any mode reads any index.
+type MetaCompression int
+
+const (
+	// CompressAuto gzips meta.json once its JSON reaches
+	// MetaCompressThreshold bytes; it is the default.
+	CompressAuto MetaCompression = iota
+	// CompressAlways gzips meta.json whatever its size.
+	CompressAlways
+	// CompressNever writes plain JSON.
+	CompressNever
+)
+
+// MetaCompressThreshold is the JSON size from which CompressAuto gzips
+// meta.json.
+const MetaCompressThreshold = 1 << 20
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// SetMetaCompression sets when Flush gzips meta.json. An unchanged index
+// keeps its current format until something else makes it flush.
+func (idx *Index) SetMetaCompression(c MetaCompression) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.metaCompression = c
+}
+
+// readMetaFile returns the JSON stored at path, decompressing it when it is
+// gzipped, and whether it was.
+func readMetaFile(path string) (data []byte, compressed bool, err error) {
+	raw, err := os.ReadFile(path)
+	if err != nil || !bytes.HasPrefix(raw, gzipMagic) {
+		return raw, false, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, true, fmt.Errorf("gunzip: %w", err)
+	}
+	defer zr.Close()
+	if data, err = io.ReadAll(zr); err != nil {
+		return nil, true, fmt.Errorf("gunzip: %w", err)
+	}
+	return data, true, nil
+}
+
+// encodeMeta returns the bytes to store for the JSON in data under mode c,
+// and whether they are gzipped.
+func encodeMeta(data []byte, c MetaCompression) ([]byte, bool, error) {
+	if c == CompressNever || (c == CompressAuto && len(data) < MetaCompressThreshold) {
+		return data, false, nil
+	}
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, false, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, false, err
+	}
+	return buf.Bytes(), true, nil
+}
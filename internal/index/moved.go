@@ -0,0 +1,130 @@
+package index
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/tejas242/sift/internal/chunker"
+)
+
+// A file moved or renamed keeps its content but not its path, so the skip
+// cache misses it. Rather than embed it again, an index run that finds a
+// file new to the index with the same FileHash as an indexed file gone
+// from disk takes that file's chunks over: their vectors stay in the
+// graph and only their metadata is rewritten to the new path. idx.byHash
+// finds the files with a hash without looking at the others.
+
+// movedFrom returns the indexed file, gone from disk, whose bytes hash to
+// hash, or "" if there is none. path itself is not a candidate.
+func (idx *Index) movedFrom(path, hash string) string {
+	key := idx.pathKey(path)
+	idx.mu.RLock()
+	var candidates []string
+	for _, k := range idx.byHash[hash] {
+		if ids := idx.byFile[k]; k != key && len(ids) > 0 {
+			candidates = append(candidates, idx.chunks[ids[0]].Path)
+		}
+	}
+	idx.mu.RUnlock()
+
+	// Stat outside the lock so searches are not held up by the disk.
+	for _, p := range candidates {
+		if _, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) {
+			return p
+		}
+	}
+	return ""
+}
+
+// reuseMoved moves the chunks of the indexed file old, gone from disk, to
+// path, which has the same bytes at version v and chunked into chunks, and
+// records path as indexed at v. It reports false, changing nothing, if old
+// no longer matches, was chunked otherwise (as a different file type can
+// be) or path has been indexed meanwhile.
+func (idx *Index) reuseMoved(old, path string, chunks []chunker.Chunk, v fileVersion) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	oldKey, key := idx.pathKey(old), idx.pathKey(path)
+	ids := idx.byFile[oldKey]
+	if len(ids) == 0 || idx.chunks[ids[0]].FileHash != v.hash || len(idx.byFile[key]) > 0 {
+		return false
+	}
+	if _, ok := idx.fileCache[key]; ok {
+		return false
+	}
+
+	// Same bytes cut at the same places make the same chunks.
+	full := make(map[int]chunker.Chunk, len(chunks))
+	for _, c := range chunks {
+		full[c.Index] = c
+	}
+	if len(ids) != len(chunks) {
+		return false
+	}
+	for _, id := range ids {
+		c := idx.chunks[id]
+		if n, ok := full[c.ChunkIndex]; !ok || n.StartByte != c.StartByte || n.EndByte != c.EndByte {
+			return false
+		}
+	}
+	category := idx.classify(path)
+	for _, id := range ids {
+		c := &idx.chunks[id]
+		delete(idx.byID, c.ID)
+		c.ID = chunkID(idx.storedPath(path), c.ChunkIndex, full[c.ChunkIndex].Text)
+		idx.byID[c.ID] = id
+		c.Path = path
+		c.setVersion(v)
+		c.Category = category
+	}
+	delete(idx.byFile, oldKey)
+	idx.byFile[key] = ids
+	idx.dropHashUnderLock(oldKey, v.hash)
+	idx.addHashUnderLock(key, v.hash)
+	delete(idx.fileCache, oldKey)
+	idx.fileCache[key] = v
+	isOld := func(p string) bool { return idx.pathKey(p) == oldKey }
+	idx.forgetOversizedUnderLock(isOld)
+	idx.missing = slices.DeleteFunc(idx.missing, isOld)
+
+	idx.log.Debugf("moved %s to %s (reused %d embeddings)", old, path, len(ids))
+	idx.changedUnderLock()
+	idx.dirty = true
+	idx.lastUpdated = time.Now()
+	return true
+}
+
+// indexHashesUnderLock rebuilds idx.byHash from idx.byFile. Must be called
+// with idx.mu held for writing.
+func (idx *Index) indexHashesUnderLock() {
+	idx.byHash = make(map[string][]string)
+	for k, ids := range idx.byFile {
+		idx.addHashUnderLock(k, idx.chunks[ids[0]].FileHash)
+	}
+}
+
+// addHashUnderLock records that the file key hashes to hash, if it has one.
+// Must be called with idx.mu held for writing.
+func (idx *Index) addHashUnderLock(key, hash string) {
+	if hash == "" {
+		return
+	}
+	if idx.byHash == nil {
+		idx.byHash = make(map[string][]string)
+	}
+	idx.byHash[hash] = append(idx.byHash[hash], key)
+}
+
+// dropHashUnderLock forgets that the file key hashes to hash. Must be
+// called with idx.mu held for writing.
+func (idx *Index) dropHashUnderLock(key, hash string) {
+	keys := slices.DeleteFunc(idx.byHash[hash], func(k string) bool { return k == key })
+	if len(keys) == 0 {
+		delete(idx.byHash, hash)
+	} else {
+		idx.byHash[hash] = keys
+	}
+}
@@ -0,0 +1,119 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIndex_MovedFiles(t *testing.T) {
+	dir := t.TempDir()
+	siftDir := filepath.Join(dir, ".sift")
+	for name, body := range map[string]string{
+		"old/vpn.md":    "wireguard tunnel",
+		"old/tea.md":    "kettle",
+		"old/garden.md": "garden",
+		"notes.md":      "wireguard",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	emb := &recordingEmbedder{}
+	idx := NewTestIndex(siftDir, emb)
+	ctx := context.Background()
+	if err := idx.IndexDir(ctx, dir); err != nil {
+		t.Fatal(err)
+	}
+	nodes := idx.graph.Len()
+
+	// Move the directory, editing one of its files on the way, and copy a
+	// file that stays where it was.
+	if err := os.Rename(filepath.Join(dir, "old"), filepath.Join(dir, "new")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new", "garden.md"), []byte("garden kettle"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "copy.md"), []byte("wireguard"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	emb.docs = nil
+	idx.BeginRun()
+	if err := idx.IndexDir(ctx, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the edited file and the copy were embedded.
+	slices.Sort(emb.docs)
+	if want := []string{"garden kettle", "wireguard"}; !slices.Equal(emb.docs, want) {
+		t.Errorf("embedded %q, want %q", emb.docs, want)
+	}
+	if s := idx.RunStats(); s.FilesMoved != 2 || s.FilesEmbedded != 2 {
+		t.Errorf("RunStats: %d moved, %d embedded; want 2 and 2", s.FilesMoved, s.FilesEmbedded)
+	}
+	// The hash index follows the move: it names the new path, not the old.
+	vpnKey := idx.pathKey(filepath.Join(dir, "new", "vpn.md"))
+	hash := idx.chunks[idx.byFile[vpnKey][0]].FileHash
+	if keys := idx.byHash[hash]; !slices.Equal(keys, []string{vpnKey}) {
+		t.Errorf("byHash[%s] = %q, want new/vpn.md alone", hash, keys)
+	}
+	// The moved files kept their vectors: only the new chunks were added.
+	if got := idx.graph.Len(); got != nodes+2 {
+		t.Errorf("graph has %d nodes, want %d", got, nodes+2)
+	}
+
+	results, err := idx.Search(ctx, "wireguard", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range results {
+		rel, _ := filepath.Rel(dir, r.Meta.Path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	if !slices.Contains(got, "new/vpn.md") || slices.Contains(got, "old/vpn.md") {
+		t.Errorf("search found %q, want new/vpn.md and not old/vpn.md", got)
+	}
+	vpn := idx.FileChunks(filepath.Join(dir, "new", "vpn.md"))
+	if len(vpn) != 1 || vpn[0].ID != chunkID("new/vpn.md", 0, "wireguard tunnel") {
+		t.Errorf("new/vpn.md chunks = %+v, want one with an ID for its new path", vpn)
+	}
+	if c, ok := idx.GetChunk(vpn[0].ID); !ok || c.Path != vpn[0].Path {
+		t.Errorf("GetChunk(%s) = %+v, %v", vpn[0].ID, c, ok)
+	}
+
+	// The move survives a save, leaving nothing to prune.
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := idx.PruneMissing(dir); err != nil || n != 1 {
+		t.Errorf("PruneMissing = %d, %v; want old/garden.md alone", n, err)
+	}
+	reopened, err := OpenReadOnly(siftDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if c := reopened.FileChunks(filepath.Join(dir, "new", "tea.md")); len(c) != 1 || c[0].FileHash == "" {
+		t.Errorf("reopened: new/tea.md chunks = %+v", c)
+	}
+
+	// Reindexing a file embeds it again, whatever has its content.
+	if err := os.Remove(filepath.Join(dir, "notes.md")); err != nil {
+		t.Fatal(err)
+	}
+	emb.docs = nil
+	if _, err := idx.ReindexFile(ctx, filepath.Join(dir, "copy.md")); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(emb.docs, []string{"wireguard"}) {
+		t.Errorf("ReindexFile embedded %q, want the file's text", emb.docs)
+	}
+}
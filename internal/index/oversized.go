@@ -0,0 +1,259 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Oversized is a file skipped for exceeding the size limit, as recorded
+// in the manifest: its size then and the limit in effect, in bytes.
+type Oversized struct {
+	Size  int64 `json:"size"`
+	Limit int64 `json:"limit"`
+}
+
+// OversizedPolicy is what an index run does with a file over the size
+// limit: skip it, the zero value, or index a sample of its chunks (see
+// sampleChunks), marking them ChunkMeta.Sampled.
+type OversizedPolicy struct {
+	// Sample samples the files of every extension not in Exts.
+	Sample bool
+	// Exts maps lower-cased extensions, with their dot, to whether their
+	// files are sampled.
+	Exts map[string]bool
+}
+
+// samples reports whether p samples the file at path.
+func (p OversizedPolicy) samples(path string) bool {
+	if s, ok := p.Exts[strings.ToLower(filepath.Ext(path))]; ok {
+		return s
+	}
+	return p.Sample
+}
+
+const (
+	// sampleHeadChunks is how many leading chunks of a sampled file are
+	// indexed, and sampleBudget how many of its chunks in all.
+	sampleHeadChunks = 16
+	sampleBudget     = 64
+	// maxSampleFactor bounds sampling: a file more than this many times
+	// the size limit is skipped all the same, since it is read and chunked
+	// whole.
+	maxSampleFactor = 64
+)
+
+// SetOversizedPolicy changes what is done with files over the size
+// limit, for the files indexed from now on.
+func (idx *Index) SetOversizedPolicy(p OversizedPolicy) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.oversizedPolicy = p
+}
+
+// admitsUnderLock reports whether a file of size bytes at path is
+// indexed, and whether only a sample of its chunks is: one over the size
+// limit is skipped unless the oversized policy samples it and it is
+// within maxSampleFactor of the limit. Must be called with idx.mu held.
+func (idx *Index) admitsUnderLock(path string, size int64) (ok, sample bool) {
+	limit := idx.maxFileSizeBytes
+	if size <= limit {
+		return true, false
+	}
+	if idx.oversizedPolicy.samples(path) && size/maxSampleFactor <= limit {
+		return true, true
+	}
+	return false, false
+}
+
+// sampleChunks returns, in order, the positions of the chunks indexed of
+// a file with n chunks: all of them when n is within budget, and
+// otherwise the first head and then evenly spaced ones through the rest,
+// ending with the last, budget in all.
+func sampleChunks(n, head, budget int) []int {
+	keep := make([]int, 0, min(n, budget))
+	if n <= budget {
+		for i := range n {
+			keep = append(keep, i)
+		}
+		return keep
+	}
+	head = min(head, budget)
+	for i := range head {
+		keep = append(keep, i)
+	}
+	switch rest := budget - head; rest {
+	case 0:
+	case 1:
+		keep = append(keep, n-1)
+	default:
+		// rest <= n-head-1, so the positions are distinct.
+		for i := range rest {
+			keep = append(keep, head+i*(n-1-head)/(rest-1))
+		}
+	}
+	return keep
+}
+
+// noteOversized records that path, of size bytes, was skipped for
+// exceeding limit. Its chunks, if it was indexed under a higher limit,
+// stay until PruneOversized.
+func (idx *Index) noteOversized(path string, size, limit int64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.noteOversizedUnderLock(path, size, limit)
+}
+
+func (idx *Index) noteOversizedUnderLock(path string, size, limit int64) {
+	if idx.manifest == nil {
+		idx.manifest = newManifest("")
+	}
+	o := Oversized{Size: size, Limit: limit}
+	if idx.manifest.Oversized[path] == o {
+		return
+	}
+	if idx.manifest.Oversized == nil {
+		idx.manifest.Oversized = make(map[string]Oversized)
+	}
+	idx.manifest.Oversized[path] = o
+	idx.dirty = true
+}
+
+// forgetOversizedUnderLock drops the records of the paths for which
+// drop returns true. Must be called with idx.mu held.
+func (idx *Index) forgetOversizedUnderLock(drop func(path string) bool) {
+	if idx.manifest == nil {
+		return
+	}
+	n := len(idx.manifest.Oversized)
+	maps.DeleteFunc(idx.manifest.Oversized, func(p string, _ Oversized) bool { return drop(p) })
+	if len(idx.manifest.Oversized) != n {
+		idx.dirty = true
+	}
+}
+
+// OversizedFiles returns, sorted, the recorded files that are over the
+// current size limit and not sampled: those an index run skips.
+func (idx *Index) OversizedFiles() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.oversizedUnderLock()
+}
+
+func (idx *Index) oversizedUnderLock() []string {
+	var out []string
+	for p, o := range idx.oversizedRecords() {
+		if ok, _ := idx.admitsUnderLock(p, o.Size); !ok {
+			out = append(out, p)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// RecheckOversized indexes the recorded oversized files that the current
+// size limit or oversized policy admits, after either changed, and
+// forgets those deleted since.
+// Index runs re-check the files under their roots anyway; this catches
+// the rest, as when watch reloads max-file-kb. It returns the number of
+// files indexed; call Flush to persist them.
+func (idx *Index) RecheckOversized(ctx context.Context) (int, error) {
+	idx.mu.RLock()
+	var fits []string
+	for p, o := range idx.oversizedRecords() {
+		if ok, _ := idx.admitsUnderLock(p, o.Size); ok {
+			fits = append(fits, p)
+		}
+	}
+	idx.mu.RUnlock()
+	slices.Sort(fits)
+
+	n := 0
+	for _, p := range fits {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		if _, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) {
+			idx.mu.Lock()
+			idx.forgetOversizedUnderLock(func(q string) bool { return q == p })
+			idx.mu.Unlock()
+			continue
+		}
+		skipped, err := idx.AddFileCtx(ctx, p)
+		if err != nil {
+			return n, err
+		}
+		if !skipped && len(idx.FileChunks(p)) > 0 {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// PruneOversized removes the chunks and skip-cache entries of indexed
+// files now larger than the size limit, after it was lowered, recording
+// them as oversized, and forgets oversized files deleted since. Files the
+// oversized policy samples are left as they are. It stats
+// every indexed file and returns, sorted, those removed; call Flush to
+// persist the result.
+func (idx *Index) PruneOversized() []string {
+	indexed := idx.knownPaths()
+	idx.mu.RLock()
+	paths := append(slices.Collect(maps.Keys(idx.oversizedRecords())), indexed...)
+	idx.mu.RUnlock()
+
+	sizes := make(map[string]int64)
+	gone := make(map[string]bool)
+	statFiles(paths, func(p string, fi fs.FileInfo, err error) {
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			gone[p] = true
+		case err == nil:
+			sizes[p] = fi.Size()
+		}
+	})
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	limit := idx.maxFileSizeBytes
+	over := make(map[string]int64)
+	for p, size := range sizes {
+		if ok, _ := idx.admitsUnderLock(p, size); !ok {
+			over[p] = size
+		}
+	}
+	idx.forgetOversizedUnderLock(func(p string) bool { return gone[p] })
+	var removed []string
+	for _, p := range indexed {
+		size, ok := over[p]
+		if !ok {
+			continue
+		}
+		removed = append(removed, p)
+		delete(idx.fileCache, idx.pathKey(p))
+		idx.noteOversizedUnderLock(p, size, limit)
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	idx.removeChunksUnderLock(func(p string) bool { _, ok := over[p]; return ok })
+	idx.dirty = true
+	idx.lastUpdated = time.Now()
+	slices.Sort(removed)
+	return removed
+}
+
+// oversizedRecords returns the manifest's oversized files, which may be
+// nil. Must be called with idx.mu held.
+func (idx *Index) oversizedRecords() map[string]Oversized {
+	if idx.manifest == nil {
+		return nil
+	}
+	return idx.manifest.Oversized
+}
@@ -0,0 +1,209 @@
+package index
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/chunker"
+)
+
+func TestIndex_SizeLimitChanges(t *testing.T) {
+	root := t.TempDir()
+	siftDir := filepath.Join(t.TempDir(), ".sift")
+	big := filepath.Join(root, "big.md")
+	if err := os.WriteFile(big, []byte(strings.Repeat("wireguard tunnel ", 200)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "small.md"), []byte("backup schedule"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	idx := NewTestIndex(siftDir, &mockEmbedder{})
+	idx.SetMaxFileKB(1)
+	if err := idx.IndexDir(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.FileChunks(big)) != 0 {
+		t.Fatal("a 3 KB file was indexed under a 1 KB limit")
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The skip is recorded in the manifest, with the limit.
+	idx, err := load(siftDir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.embedder = &mockEmbedder{}
+	idx.SetMaxFileKB(1)
+	if o, ok := idx.Manifest().Oversized[big]; !ok || o.Limit != 1024 || o.Size != 3400 {
+		t.Errorf("manifest Oversized = %+v, want big.md at 3400 bytes over 1024", idx.Manifest().Oversized)
+	}
+	if s := idx.Stats(); s.Oversized != 1 || s.OversizedIndexed != 0 {
+		t.Errorf("Stats: %d oversized, %d indexed; want 1, 0", s.Oversized, s.OversizedIndexed)
+	}
+
+	// Raising the limit picks the file up on the next run.
+	idx.SetMaxFileKB(8)
+	if s := idx.Stats(); s.Oversized != 0 {
+		t.Errorf("Stats.Oversized = %d under a limit it fits", s.Oversized)
+	}
+	if err := idx.IndexDir(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.FileChunks(big)) == 0 {
+		t.Fatal("big.md not indexed after raising the limit")
+	}
+	if _, ok := idx.Manifest().Oversized[big]; ok {
+		t.Error("big.md still recorded as oversized once indexed")
+	}
+
+	// Lowering it again leaves the chunks until PruneOversized.
+	idx.SetMaxFileKB(1)
+	if err := idx.IndexDir(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	if s := idx.Stats(); len(idx.FileChunks(big)) == 0 || s.Oversized != 1 || s.OversizedIndexed != 1 {
+		t.Errorf("after lowering: %d chunks of big.md, Stats %d oversized, %d indexed; want chunks kept, 1, 1",
+			len(idx.FileChunks(big)), s.Oversized, s.OversizedIndexed)
+	}
+	if got := idx.PruneOversized(); !slices.Equal(got, []string{big}) {
+		t.Errorf("PruneOversized = %v, want [%s]", got, big)
+	}
+	if s := idx.Stats(); len(idx.FileChunks(big)) != 0 || s.NumFiles != 1 || s.OversizedIndexed != 0 {
+		t.Errorf("after prune: %d chunks of big.md, %d files, %d oversized indexed", len(idx.FileChunks(big)), s.NumFiles, s.OversizedIndexed)
+	}
+	if got := idx.PruneOversized(); got != nil {
+		t.Errorf("second PruneOversized = %v, want nothing", got)
+	}
+
+	// RecheckOversized applies a raised limit without walking the root.
+	idx.SetMaxFileKB(8)
+	if n, err := idx.RecheckOversized(ctx); err != nil || n != 1 || len(idx.FileChunks(big)) == 0 {
+		t.Errorf("RecheckOversized = %d, %v (%d chunks), want big.md indexed", n, err, len(idx.FileChunks(big)))
+	}
+
+	// Deleted files are forgotten.
+	idx.SetMaxFileKB(1)
+	idx.PruneOversized()
+	if err := os.Remove(big); err != nil {
+		t.Fatal(err)
+	}
+	idx.SetMaxFileKB(8)
+	if n, err := idx.RecheckOversized(ctx); err != nil || n != 0 {
+		t.Errorf("RecheckOversized of a deleted file = %d, %v", n, err)
+	}
+	if len(idx.Manifest().Oversized) != 0 {
+		t.Errorf("deleted file still recorded: %+v", idx.Manifest().Oversized)
+	}
+}
+
+func TestSampleChunks(t *testing.T) {
+	for _, tc := range []struct {
+		n, head, budget int
+		want            []int
+	}{
+		{3, 2, 5, []int{0, 1, 2}},
+		{5, 2, 5, []int{0, 1, 2, 3, 4}},
+		{20, 2, 5, []int{0, 1, 2, 10, 19}},
+		{100, 4, 8, []int{0, 1, 2, 3, 4, 35, 67, 99}},
+		{10, 3, 4, []int{0, 1, 2, 9}},
+		{10, 6, 4, []int{0, 1, 2, 3}},
+	} {
+		if got := sampleChunks(tc.n, tc.head, tc.budget); !slices.Equal(got, tc.want) {
+			t.Errorf("sampleChunks(%d, %d, %d) = %v, want %v", tc.n, tc.head, tc.budget, got, tc.want)
+		}
+	}
+	// The default budget, over a large file, is strictly increasing.
+	got := sampleChunks(1000, sampleHeadChunks, sampleBudget)
+	if len(got) != sampleBudget || got[len(got)-1] != 999 || !slices.IsSorted(got) || len(slices.Compact(slices.Clone(got))) != len(got) {
+		t.Errorf("sampleChunks(1000) = %v, want %d distinct positions ending at 999", got, sampleBudget)
+	}
+}
+
+func TestIndex_SampleOversized(t *testing.T) {
+	root := t.TempDir()
+	siftDir := filepath.Join(t.TempDir(), ".sift")
+	// Over a hundred chunks each, over a 16 KB limit.
+	var b strings.Builder
+	for i := range 4000 {
+		fmt.Fprintf(&b, "section %d of the api reference\n", i)
+	}
+	body := []byte(b.String())
+	ref, data := filepath.Join(root, "ref.md"), filepath.Join(root, "data.txt")
+	for _, p := range []string{ref, data} {
+		if err := os.WriteFile(p, body, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+
+	idx := NewTestIndex(siftDir, &mockEmbedder{})
+	idx.SetMaxFileKB(16)
+	idx.SetOversizedPolicy(OversizedPolicy{Exts: map[string]bool{".md": true}})
+	if err := idx.IndexDir(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(idx.FileChunks(data)); n != 0 {
+		t.Errorf("data.txt has %d chunks, want it skipped as too large", n)
+	}
+	chunks, err := chunker.ChunkFile(ref, chunker.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sampleChunks(len(chunks), sampleHeadChunks, sampleBudget)
+	got := idx.FileChunks(ref)
+	if len(got) != sampleBudget || len(chunks) <= sampleBudget {
+		t.Fatalf("ref.md has %d of %d chunks indexed, want %d", len(got), len(chunks), sampleBudget)
+	}
+	for i, c := range got {
+		if c.ChunkIndex != want[i] || !c.Sampled || c.StartByte != chunks[want[i]].StartByte {
+			t.Errorf("chunk %d = index %d at byte %d (sampled %v), want index %d at byte %d, sampled",
+				i, c.ChunkIndex, c.StartByte, c.Sampled, want[i], chunks[want[i]].StartByte)
+		}
+	}
+	if s := idx.Stats(); s.SampledFiles != 1 || s.Oversized != 1 {
+		t.Errorf("Stats: %d sampled, %d oversized; want 1, 1", s.SampledFiles, s.Oversized)
+	}
+
+	// The flag and the count survive a save; PruneOversized keeps the sample.
+	if got := idx.PruneOversized(); got != nil {
+		t.Errorf("PruneOversized = %v, want the sampled file kept", got)
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	again, err := load(siftDir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := again.Manifest(); m.SampledFiles != 1 {
+		t.Errorf("manifest SampledFiles = %d, want 1", m.SampledFiles)
+	}
+	if s := again.Stats(); s.SampledFiles != 1 {
+		t.Errorf("Stats after reopening: %d sampled files, want 1", s.SampledFiles)
+	}
+
+	// Under a limit it fits, the file is indexed whole.
+	idx.SetMaxFileKB(1024)
+	if n, err := idx.ReindexFile(ctx, ref); err != nil || n != len(chunks) {
+		t.Errorf("ReindexFile = %d, %v; want all %d chunks", n, err, len(chunks))
+	}
+	if s := idx.Stats(); s.SampledFiles != 0 {
+		t.Errorf("Stats.SampledFiles = %d after indexing whole", s.SampledFiles)
+	}
+
+	// Sampling everything picks up the skipped file too.
+	idx.SetMaxFileKB(16)
+	idx.SetOversizedPolicy(OversizedPolicy{Sample: true})
+	if n, err := idx.RecheckOversized(ctx); err != nil || n != 1 || len(idx.FileChunks(data)) != sampleBudget {
+		t.Errorf("RecheckOversized = %d, %v (%d chunks of data.txt), want it sampled", n, err, len(idx.FileChunks(data)))
+	}
+}
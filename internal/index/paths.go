@@ -0,0 +1,215 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// In memory a chunk's Path is absolute and cleaned, whatever form it was
+// indexed under, so "./a/b.md", "a/b.md" and "/proj/a/b.md" are one file.
+// meta.json stores it slash-separated and, inside the index root (the
+// directory holding the index directory), relative to that root, so an
+// index built on one machine still finds its files where the tree is
+// mounted on another. Case is kept as given, but on a case-insensitive
+// filesystem paths are compared by pathKey, which folds it.
+
+// relPathsVersion is the FormatVersion from which meta.json stores paths
+// relative to the index root; format 1 stored them as given to AddFile.
+const relPathsVersion = 2
+
+// indexRoot returns the absolute directory holding the index directory
+// dir, or "" if it cannot be resolved.
+func indexRoot(dir string) string {
+	root, err := filepath.Abs(filepath.Dir(dir))
+	if err != nil {
+		return ""
+	}
+	return root
+}
+
+// normPath returns path in the form the index holds it: absolute and
+// cleaned.
+func normPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// pathKey returns the key the normalized path is compared by: path
+// itself, or path lower-cased when the index's filesystem ignores case.
+func (idx *Index) pathKey(path string) string {
+	if idx.foldCase {
+		return strings.ToLower(path)
+	}
+	return path
+}
+
+// relPath returns path relative to the index root when it lies inside
+// it, and path unchanged otherwise.
+func (idx *Index) relPath(path string) string {
+	if idx.root == "" {
+		return path
+	}
+	if rel, err := filepath.Rel(idx.root, path); err == nil && filepath.IsLocal(rel) {
+		return rel
+	}
+	return path
+}
+
+// storedPath returns path as meta.json records it.
+func (idx *Index) storedPath(path string) string {
+	return filepath.ToSlash(idx.relPath(path))
+}
+
+// RootRelative returns path, absolute, as the index in dir stores it:
+// slash-separated and relative to the index root when inside it. Output
+// that names files by their place in the project uses it.
+func RootRelative(dir, path string) string {
+	if root := indexRoot(dir); root != "" {
+		if rel, err := filepath.Rel(root, path); err == nil && filepath.IsLocal(rel) {
+			path = rel
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// loadedPath returns the in-memory form of a path read from meta.json.
+func (idx *Index) loadedPath(p string) string {
+	p = filepath.FromSlash(p)
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(idx.root, p)
+}
+
+// legacyPath returns the in-memory form of a path read from a format 1
+// meta.json, where a relative path was relative to the directory sift ran
+// in: usually the index root, so a file found there is taken to be it.
+func (idx *Index) legacyPath(p string) string {
+	if !filepath.IsAbs(p) && idx.root != "" {
+		if q := filepath.Join(idx.root, p); fileExists(q) {
+			return q
+		}
+	}
+	return normPath(p)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// resolvePaths turns the chunk paths read from meta.json into their
+// in-memory form; legacy is whether the index predates relPathsVersion.
+// A legacy index's oversized files are normalized too; the next Flush
+// saves it in the current format.
+func (idx *Index) resolvePaths(legacy bool) {
+	resolve := idx.loadedPath
+	if legacy {
+		resolve = idx.legacyPath
+	}
+	resolved := make(map[string]string)
+	for i := range idx.chunks {
+		p := idx.chunks[i].Path
+		if p == "" {
+			continue // reported by checkHealth
+		}
+		q, ok := resolved[p]
+		if !ok {
+			q = resolve(p)
+			resolved[p] = q
+		}
+		idx.chunks[i].Path = q
+	}
+	if !legacy || len(idx.chunks) == 0 {
+		return
+	}
+	if old := idx.manifest.Oversized; len(old) > 0 {
+		idx.manifest.Oversized = make(map[string]Oversized, len(old))
+		for p, o := range old {
+			idx.manifest.Oversized[idx.legacyPath(p)] = o
+		}
+	}
+	idx.log.Debugf("%s: read paths of index format v%d", idx.dir, relPathsVersion-1)
+}
+
+// dedupChunks drops the chunks of files indexed more than once under
+// different names: "./a.md" and "a.md" by a format 1 index, or "A.md" and
+// "a.md" on a case-insensitive filesystem. Of each file only the newest
+// version's chunks are kept, one per chunk index; the repair is recorded
+// with noteRepair. Must be called with the graph loaded.
+func (idx *Index) dedupChunks() {
+	newest := make(map[string]time.Time)
+	for _, c := range idx.chunks {
+		k := idx.pathKey(c.Path)
+		if t, ok := newest[k]; !ok || c.Mtime.After(t) {
+			newest[k] = c.Mtime
+		}
+	}
+	type slot struct {
+		key   string
+		chunk int
+	}
+	seen := make(map[slot]bool, len(idx.chunks))
+	drop := make(map[int]bool)
+	var files []string
+	for i, c := range idx.chunks {
+		k := idx.pathKey(c.Path)
+		s := slot{k, c.ChunkIndex}
+		if c.Mtime.Equal(newest[k]) && !seen[s] {
+			seen[s] = true
+			continue
+		}
+		drop[i] = true
+		if !slices.Contains(files, c.Path) {
+			files = append(files, c.Path)
+		}
+	}
+	if len(drop) == 0 {
+		return
+	}
+	idx.dropChunksUnderLock(func(id int, _ ChunkMeta) bool { return drop[id] })
+	slices.Sort(files)
+	idx.noteRepair("dropped %d duplicate chunks of %s, indexed under more than one name",
+		len(drop), strings.Join(files, ", "))
+}
+
+// caseInsensitive reports whether the filesystem holding dir ignores case
+// in names, as macOS and Windows do by default: whether the nearest
+// existing directory at or above dir whose name has letters is found
+// under that name in the opposite case too.
+func caseInsensitive(dir string) bool {
+	d, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	for {
+		name := filepath.Base(d)
+		if fi, err := os.Stat(d); err == nil {
+			if swapped := swapCase(name); swapped != name {
+				fj, err := os.Stat(filepath.Join(filepath.Dir(d), swapped))
+				return err == nil && os.SameFile(fi, fj)
+			}
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return false
+		}
+		d = parent
+	}
+}
+
+// swapCase returns s with upper- and lower-case letters exchanged.
+func swapCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
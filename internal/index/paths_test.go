@@ -0,0 +1,193 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tejas242/sift/internal/hnsw"
+)
+
+func TestIndex_NormalizesPaths(t *testing.T) {
+	root := t.TempDir()
+	t.Chdir(root)
+	if err := os.Mkdir("a", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join("a", "b.md"), []byte("wireguard"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(root, "a", "b.md")
+
+	idx := NewTestIndex(filepath.Join(root, ".sift"), wordEmbedder{})
+	if _, err := idx.AddFile("./a/b.md"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"a/b.md", "a/../a/b.md", want} {
+		if skipped, err := idx.AddFile(p); err != nil || !skipped {
+			t.Errorf("AddFile(%q) = %v, %v; want it skipped as already indexed", p, skipped, err)
+		}
+		if n := len(idx.FileChunks(p)); n != 1 {
+			t.Errorf("FileChunks(%q) has %d chunks, want 1", p, n)
+		}
+	}
+	if got := idx.Files(); !slices.Equal(got, []string{want}) {
+		t.Errorf("Files = %q, want [%q]", got, want)
+	}
+	if res, err := idx.Search(context.Background(), "wireguard", 5); err != nil || len(res) != 1 {
+		t.Errorf("Search = %+v, %v; want one result", res, err)
+	}
+
+	// meta.json stores the path relative to the index root.
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := readMetaFile(filepath.Join(root, ".sift", metaFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored []ChunkMeta
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) != 1 || stored[0].Path != "a/b.md" {
+		t.Fatalf("meta.json paths = %+v, want a/b.md", stored)
+	}
+	again, err := load(filepath.Join(root, ".sift"), "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := again.Files(); !slices.Equal(got, []string{want}) {
+		t.Errorf("Files after reopening = %q, want [%q]", got, want)
+	}
+}
+
+func TestLoad_MigratesLegacyPaths(t *testing.T) {
+	root := t.TempDir()
+	siftDir := filepath.Join(root, ".sift")
+	if err := os.MkdirAll(filepath.Join(root, "a"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a", "b.md"), []byte("wireguard"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A format 1 index that saw the file as both ./a/b.md and a/b.md,
+	// the second time after it changed.
+	older := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	chunks := []ChunkMeta{
+		{ID: "1", Path: "./a/b.md", Text: "wireguard", Mtime: older},
+		{ID: "2", Path: "a/b.md", Text: "wireguard", Mtime: newer},
+	}
+	data, err := json.Marshal(chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(siftDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(siftDir, metaFile), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g := hnsw.New(hnsw.DefaultM, hnsw.DefaultEfConstruction, hnsw.DefaultEfSearch)
+	for range chunks {
+		v, _ := wordEmbedder{}.EmbedQuery("wireguard")
+		g.Insert(v)
+	}
+	if err := g.Save(filepath.Join(siftDir, hnswFile)); err != nil {
+		t.Fatal(err)
+	}
+	m := newManifest("")
+	m.FormatVersion = 1
+	if err := writeManifest(siftDir, m); err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := load(siftDir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(root, "a", "b.md")
+	if len(idx.chunks) != 1 || idx.chunks[0].ID != "2" || idx.chunks[0].Path != want || idx.graph.Len() != 1 {
+		t.Fatalf("after load: chunks %+v, %d nodes; want only chunk 2, at %s", idx.chunks, idx.graph.Len(), want)
+	}
+	if s := idx.Stats(); !strings.Contains(s.Repaired, "duplicate chunks") {
+		t.Errorf("Repaired = %q, want the dropped duplicate recorded", s.Repaired)
+	}
+	if v := idx.fileCache[want]; !v.mtime.Equal(newer) {
+		t.Errorf("skip-cache mtime = %v, want %v", v.mtime, newer)
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := ReadManifest(siftDir); err != nil || m.FormatVersion != FormatVersion {
+		t.Errorf("manifest after saving = %+v, %v; want format v%d", m, err, FormatVersion)
+	}
+}
+
+func TestIndex_FoldCase(t *testing.T) {
+	if runtime.GOOS == "linux" && caseInsensitive(t.TempDir()) {
+		t.Error("caseInsensitive = true for a Linux temporary directory")
+	}
+
+	// Two files differing only in case stand in for one file seen under
+	// two names on a case-insensitive filesystem.
+	root := t.TempDir()
+	upper, lower := filepath.Join(root, "Notes.md"), filepath.Join(root, "notes.md")
+	for i, p := range []string{upper, lower} {
+		if err := os.WriteFile(p, []byte("wireguard "+strconv.Itoa(i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mt := time.Now().Add(time.Duration(i-2) * time.Minute)
+		if err := os.Chtimes(p, mt, mt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index := func(foldCase bool) *Index {
+		idx := NewTestIndex(filepath.Join(root, ".sift"), wordEmbedder{})
+		idx.foldCase = foldCase
+		for _, p := range []string{upper, lower} {
+			if _, err := idx.AddFile(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return idx
+	}
+
+	if got := index(false).Files(); len(got) != 2 {
+		t.Errorf("case-sensitive Files = %q, want both", got)
+	}
+
+	idx := index(true)
+	if got := idx.Files(); !slices.Equal(got, []string{lower}) {
+		t.Errorf("Files = %q, want the later name only, %q", got, lower)
+	}
+	if n := len(idx.FileChunks(strings.ToUpper(lower))); n != 1 {
+		t.Errorf("FileChunks under another case has %d chunks, want 1", n)
+	}
+	if skipped, err := idx.AddFile(upper); err != nil || skipped {
+		t.Errorf("AddFile(%q) = %v, %v; want it re-indexed for its different content", upper, skipped, err)
+	}
+	if got := idx.Files(); !slices.Equal(got, []string{upper}) {
+		t.Errorf("Files after re-indexing = %q, want [%q]", got, upper)
+	}
+
+	// Search dedups by the folded path too.
+	both := index(false)
+	if res, _ := both.Search(context.Background(), "wireguard", 5); len(res) != 2 {
+		t.Fatalf("case-sensitive Search returned %d results, want 2", len(res))
+	}
+	both.foldCase = true
+	both.changedUnderLock() // a real index never changes its case rule; retire the cached search
+	if res, _ := both.Search(context.Background(), "wireguard", 5); len(res) != 1 {
+		t.Errorf("case-folding Search returned %d results, want 1", len(res))
+	}
+}
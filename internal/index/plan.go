@@ -0,0 +1,113 @@
+package index
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tejas242/sift/internal/chunker"
+)
+
+// Skip reasons reported in a Plan.
+const (
+	SkipIgnored     = "ignored"
+	SkipTooLarge    = "too large"
+	SkipUnsupported = "unsupported"
+	SkipUnreadable  = "unreadable"
+)
+
+// PlanSkip is a path a dry run would leave out, and why.
+type PlanSkip struct {
+	Path   string
+	Reason string
+	IsDir  bool
+}
+
+// Plan describes what indexing a directory would do, without embedding.
+type Plan struct {
+	ToEmbed []string   // new or modified files
+	Cached  []string   // unchanged since the last run (skip cache hit)
+	Skipped []PlanSkip // excluded by ignore rules, size limit, or type
+	// EstChunks estimates the chunks ToEmbed will produce from file sizes.
+	EstChunks int
+	// EstBytes is the total size of ToEmbed.
+	EstBytes int64
+}
+
+// PlanDir performs the walk IndexDir would — applying ignore rules, the
+// size limit, and the skip cache — and reports the outcome without
+// chunking any file or loading the embedding model. With fresh set the
+// skip cache is ignored, as RebuildFromDir does. A rootDir that is a file
+// is planned alone, as IndexDir indexes it.
+func (idx *Index) PlanDir(rootDir string, fresh bool) (*Plan, error) {
+	info, err := os.Stat(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	fileRoot := !info.IsDir()
+	if fileRoot && !chunker.IsSupportedFile(rootDir) {
+		return nil, fmt.Errorf("%s: %w", rootDir, ErrUnsupported)
+	}
+	if err := idx.checkRoot(rootDir); err != nil {
+		return nil, err
+	}
+
+	opts := chunker.DefaultOptions()
+	stride := int64(opts.MaxBytes - opts.OverlapBytes)
+	p := &Plan{}
+
+	onSkip := func(path string, isDir bool) {
+		p.Skipped = append(p.Skipped, PlanSkip{Path: path, Reason: SkipIgnored, IsDir: isDir})
+	}
+	plan := func(path string) error {
+		if !chunker.IsSupportedFile(path) {
+			p.Skipped = append(p.Skipped, PlanSkip{Path: path, Reason: SkipUnsupported})
+			return nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			p.Skipped = append(p.Skipped, PlanSkip{Path: path, Reason: SkipUnreadable})
+			return nil
+		}
+		idx.mu.RLock()
+		admitted, sample := idx.admitsUnderLock(normPath(path), info.Size())
+		idx.mu.RUnlock()
+		if !admitted {
+			p.Skipped = append(p.Skipped, PlanSkip{Path: path, Reason: SkipTooLarge})
+			return nil
+		}
+
+		idx.mu.RLock()
+		cached, ok := idx.fileCache[idx.pathKey(normPath(path))]
+		idx.mu.RUnlock()
+		if ok && !fresh && (cached.sameStat(info) || sameContent(path, info, cached)) {
+			p.Cached = append(p.Cached, path)
+			return nil
+		}
+
+		p.ToEmbed = append(p.ToEmbed, path)
+		p.EstBytes += info.Size()
+		// Each chunk advances by MaxBytes-OverlapBytes; every non-empty
+		// file yields at least one. A sampled file yields at most
+		// sampleBudget.
+		if info.Size() > 0 {
+			n := int((info.Size() + stride - 1) / stride)
+			if sample {
+				n = min(n, sampleBudget)
+			}
+			p.EstChunks += n
+		}
+		return nil
+	}
+	if fileRoot {
+		plan(rootDir)
+		return p, nil
+	}
+	m, err := idx.Matcher(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := walkDirFrom(rootDir, rootDir, m, plan, onSkip); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
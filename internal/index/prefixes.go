@@ -0,0 +1,59 @@
+package index
+
+import "github.com/tejas242/sift/internal/embed"
+
+// SetPrefixes sets the instructions put in front of document chunks and
+// queries before they are embedded; embed.DefaultPrefixes by default.
+// The manifest records the prefixes an index's vectors were embedded
+// with. If they differ from p, existing vectors no longer match new
+// queries, so a warning asks for a rebuild (see PrefixesChanged).
+func (idx *Index) SetPrefixes(p embed.Prefixes) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.prefixes = p
+	idx.changedUnderLock()
+	if len(idx.chunks) == idx.removed {
+		idx.recordPrefixesUnderLock(p)
+		return
+	}
+	if was := idx.indexedPrefixes(); was != p {
+		idx.log.Warnf("%s was embedded with %s, but the settings now give %s; run `sift rebuild` to re-embed it",
+			idx.dir, was, p)
+	}
+}
+
+// PrefixesChanged reports whether the index holds vectors embedded with
+// other prefixes than those now set, and returns the ones it was.
+func (idx *Index) PrefixesChanged() (embed.Prefixes, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	was := idx.indexedPrefixes()
+	return was, len(idx.chunks) > idx.removed && was != idx.prefixes
+}
+
+// queryText returns query with the query prefix in front.
+func (idx *Index) queryText(query string) string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.prefixes.QueryText(query)
+}
+
+// indexedPrefixes returns the prefixes the manifest records. Must be
+// called with idx.mu held.
+func (idx *Index) indexedPrefixes() embed.Prefixes {
+	if idx.manifest == nil {
+		return embed.DefaultPrefixes
+	}
+	return idx.manifest.EmbedPrefixes()
+}
+
+// recordPrefixesUnderLock records p in the manifest as the prefixes of
+// the index's vectors, to be saved with the vectors themselves; call it
+// while the index holds none embedded otherwise. Must be called with
+// idx.mu held for writing.
+func (idx *Index) recordPrefixesUnderLock(p embed.Prefixes) {
+	if idx.manifest == nil {
+		idx.manifest = newManifest("")
+	}
+	idx.manifest.Prefixes = &p
+}
@@ -0,0 +1,109 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/tejas242/sift/internal/embed"
+)
+
+// recordingEmbedder records the texts it is asked to embed.
+type recordingEmbedder struct {
+	wordEmbedder
+	docs, queries []string
+}
+
+func (e *recordingEmbedder) Embed(texts []string) ([][]float32, error) {
+	e.docs = append(e.docs, texts...)
+	return e.wordEmbedder.Embed(texts)
+}
+
+func (e *recordingEmbedder) EmbedQuery(q string) ([]float32, error) {
+	e.queries = append(e.queries, q)
+	return e.wordEmbedder.EmbedQuery(q)
+}
+
+func (e *recordingEmbedder) EmbedQueries(qs []string) ([][]float32, error) {
+	e.queries = append(e.queries, qs...)
+	return e.wordEmbedder.Embed(qs)
+}
+
+func TestIndex_Prefixes(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		prefixes       embed.Prefixes
+		wantDoc, wantQ string
+	}{
+		{"default", embed.DefaultPrefixes, "wireguard", embed.BGEQueryPrefix + "wireguard"},
+		{"e5", embed.Prefixes{Query: "query: ", Document: "passage: "}, "passage: wireguard", "query: wireguard"},
+		{"symmetric", embed.Prefixes{Query: "query: ", Document: "passage: ", Symmetric: true}, "passage: wireguard", "passage: wireguard"},
+		{"none", embed.Prefixes{}, "wireguard", "wireguard"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, "a.md")
+			if err := os.WriteFile(path, []byte("wireguard"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			e := &recordingEmbedder{}
+			idx := NewTestIndex(filepath.Join(root, ".sift"), e)
+			idx.SetPrefixes(tc.prefixes)
+			if _, err := idx.AddFile(path); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := idx.Search(context.Background(), "wireguard", 5); err != nil {
+				t.Fatal(err)
+			}
+			idx.SearchBatch([]string{"wireguard"}, 5)
+			if want := []string{tc.wantDoc}; !slices.Equal(e.docs, want) {
+				t.Errorf("documents embedded as %q, want %q", e.docs, want)
+			}
+			if want := []string{tc.wantQ, tc.wantQ}; !slices.Equal(e.queries, want) {
+				t.Errorf("queries embedded as %q, want %q", e.queries, want)
+			}
+			if got := idx.manifest.EmbedPrefixes(); got != tc.prefixes {
+				t.Errorf("manifest records %v, want %v", got, tc.prefixes)
+			}
+			if _, changed := idx.PrefixesChanged(); changed {
+				t.Error("PrefixesChanged = true with the prefixes unchanged")
+			}
+		})
+	}
+}
+
+func TestIndex_PrefixesChanged(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a.md")
+	if err := os.WriteFile(path, []byte("wireguard"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx := NewTestIndex(filepath.Join(root, ".sift"), &recordingEmbedder{})
+	if _, err := idx.AddFile(path); err != nil {
+		t.Fatal(err)
+	}
+	e5 := embed.Prefixes{Query: "query: ", Document: "passage: "}
+	idx.SetPrefixes(e5)
+	if was, changed := idx.PrefixesChanged(); !changed || was != embed.DefaultPrefixes {
+		t.Errorf("PrefixesChanged = %v, %t; want the default prefixes, changed", was, changed)
+	}
+
+	// Re-indexing the only file replaces every vector, so the new
+	// prefixes are recorded.
+	if err := os.WriteFile(path, []byte("wireguard tunnel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.AddFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if was, changed := idx.PrefixesChanged(); changed || was != e5 {
+		t.Errorf("after re-indexing PrefixesChanged = %v, %t; want %v, unchanged", was, changed, e5)
+	}
+}
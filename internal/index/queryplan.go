@@ -0,0 +1,69 @@
+package index
+
+import (
+	"slices"
+
+	"github.com/tejas242/sift/internal/hnsw"
+)
+
+// One- and two-word queries ("mutex", "dockerfile") are where the
+// embedding is weakest and the keyword boost strongest. A search for at
+// most maxLexicalWords content words (see contentWords) whose terms each
+// have postings in the term index is planned lexically: the chunks
+// holding any of those terms are scored exactly against the query vector
+// from their stored vectors, without searching the graph. When they make
+// up fewer than k results, the search falls back to the graph, which
+// also finds chunks holding none of the words.
+
+// The query plans, as Explanation.Plan and the debug log name them.
+const (
+	QueryPlanHNSW    = "hnsw"
+	QueryPlanLexical = "lexical"
+)
+
+// maxLexicalWords is the most content words a query planned lexically
+// may have.
+const maxLexicalWords = 2
+
+// maxPostings is the most chunks a term's postings list holds. A term in
+// more keeps none, and a query for it searches the graph: scoring that
+// many chunks one by one would cost more than the graph search saves.
+const maxPostings = 1024
+
+// explainK is the number of results Explain assumes a search asked for
+// when choosing its plan: sift search's default.
+const explainK = 10
+
+// lexicalHits returns the chunks holding a term of query, scored against
+// queryVec best first, if query is short enough to be planned lexically
+// and they span at least k files passing f; otherwise nil. Must be called
+// with idx.mu held (read).
+func (idx *Index) lexicalHits(query string, queryVec []float32, k int, f Filter) []hnsw.Result {
+	words := idx.contentWords(query)
+	if len(words) == 0 || len(words) > maxLexicalWords {
+		return nil
+	}
+	ti := idx.termIndex()
+	var ids []uint32
+	for _, w := range words {
+		t := termOf(w)
+		posts, ok := ti.postings[t]
+		if !ok && ti.df[t] > 0 {
+			return nil // in too many chunks to have postings
+		}
+		ids = append(ids, posts...)
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	files := make(map[string]bool)
+	for _, id := range ids {
+		if int(id) < len(idx.chunks) && idx.passes(f, idx.chunks[id]) {
+			files[idx.pathKey(idx.chunks[id].Path)] = true
+		}
+	}
+	if len(files) < k {
+		return nil
+	}
+	return idx.graph.Score(queryVec, ids)
+}
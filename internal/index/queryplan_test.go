@@ -0,0 +1,128 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// aliasEmbedder is wordEmbedder reading "wg" as "wireguard": a chunk can
+// then be about the query without holding its word.
+type aliasEmbedder struct{ wordEmbedder }
+
+func (e aliasEmbedder) Embed(texts []string) ([][]float32, error) {
+	out := make([]string, len(texts))
+	for i, s := range texts {
+		words := strings.Fields(s)
+		for j, w := range words {
+			if w == "wg" {
+				words[j] = "wireguard"
+			}
+		}
+		out[i] = strings.Join(words, " ")
+	}
+	return e.wordEmbedder.Embed(out)
+}
+
+func (e aliasEmbedder) EmbedQuery(q string) ([]float32, error) {
+	v, err := e.Embed([]string{q})
+	return v[0], err
+}
+
+func TestSearch_QueryPlan(t *testing.T) {
+	dir := t.TempDir()
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), aliasEmbedder{})
+	for name, body := range map[string]string{
+		"a.md":   "wireguard tunnel",
+		"b.md":   "wireguard",
+		"c.md":   "wireguard garden garden",
+		"vpn.md": "wg", // as close to the query as b.md, without its word
+		"tea.md": "kettle",
+	} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	search := func(query string, k int) (string, []string) {
+		t.Helper()
+		vec, err := idx.embedQuery(ctx, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		idx.mu.RLock()
+		results, plan, err := idx.searchVec(ctx, query, vec, k, Filter{})
+		idx.mu.RUnlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range results {
+			got = append(got, filepath.Base(r.Meta.Path))
+		}
+		return plan, got
+	}
+
+	for _, tc := range []struct {
+		query string
+		k     int
+		plan  string
+		want  []string
+	}{
+		// Three files hold the word: enough for three results, scored
+		// exactly, and the file that only means it is left out.
+		{"wireguard", 3, QueryPlanLexical, []string{"b.md", "a.md", "c.md"}},
+		{"the wireguard", 3, QueryPlanLexical, []string{"b.md", "a.md", "c.md"}}, // stopwords do not count
+		// Too few for four: the graph finds it.
+		{"wireguard", 4, QueryPlanHNSW, []string{"b.md", "vpn.md", "a.md", "c.md"}},
+		// Three content words are past planning lexically.
+		{"wireguard tunnel garden", 4, QueryPlanHNSW, []string{"a.md", "c.md", "b.md", "vpn.md"}},
+		// A word no chunk holds leaves nothing to score.
+		{"dockerfile", 1, QueryPlanHNSW, nil},
+	} {
+		plan, got := search(tc.query, tc.k)
+		if plan != tc.plan || !slices.Equal(got[:min(len(got), len(tc.want))], tc.want) {
+			t.Errorf("%q top %d: %s plan, %q; want %s, %q", tc.query, tc.k, plan, got, tc.plan, tc.want)
+		}
+	}
+
+	// Search follows the same plan, and Explain reports it.
+	results, err := idx.Search(ctx, "wireguard", 3)
+	if err != nil || len(results) != 3 || filepath.Base(results[0].Meta.Path) != "b.md" {
+		t.Errorf("Search = %+v, %v", results, err)
+	}
+	ex, err := idx.Explain("wireguard", filepath.Join(dir, "vpn.md"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Plan != QueryPlanHNSW {
+		t.Errorf("Explain plan %s, want hnsw: 3 files are fewer than a default search shows", ex.Plan)
+	}
+}
+
+func TestTermIndex_Postings(t *testing.T) {
+	ti := newTermIndex()
+	ti.add("sync.Mutex guards the map")
+	ti.add("a plain map")
+	if got := ti.postings["map"]; !slices.Equal(got, []uint32{0, 1}) {
+		t.Errorf("postings of map = %v, want [0 1]", got)
+	}
+	if got := ti.postings["mutex"]; !slices.Equal(got, []uint32{0}) {
+		t.Errorf("postings of mutex = %v, want [0]", got)
+	}
+
+	// A term in more than maxPostings chunks keeps only its count.
+	for range maxPostings {
+		ti.add("map")
+	}
+	if _, ok := ti.postings["map"]; ok || ti.df["map"] != maxPostings+2 {
+		t.Errorf("map in %d chunks: postings kept (%d), want only the count", ti.df["map"], len(ti.postings["map"]))
+	}
+}
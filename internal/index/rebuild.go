@@ -0,0 +1,99 @@
+package index
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/tejas242/sift/internal/hnsw"
+)
+
+// A rebuild indexes into a staging index, built off to the side while the
+// index keeps serving searches from its old state, and swaps the result
+// in only once complete. The staging index borrows the live one's
+// embedder, run counters and settings; its own graph, chunks, skip-cache
+// and manifest are what the swap installs.
+
+// errStaging is returned by Flush on a rebuild's staging index, which is
+// never saved itself.
+var errStaging = errors.New("index: a rebuild's staging index is not saved")
+
+// Rebuild reindexes from scratch: build indexes every root into stage, an
+// empty index sharing idx's directory, settings and embedder, and when it
+// returns nil stage's contents replace idx's and are saved. Until then
+// searches of idx see its old contents; if build fails (or its context is
+// cancelled), idx is left untouched and stage discarded. stage must not be
+// used once build returns.
+func (idx *Index) Rebuild(build func(stage *Index) error) error {
+	idx.mu.RLock()
+	readOnly := idx.readOnly || idx.metaOnly
+	idx.mu.RUnlock()
+	if readOnly {
+		return ErrReadOnly
+	}
+
+	stage := idx.newStaging()
+	if err := build(stage); err != nil {
+		stage.graph.Close()
+		return err
+	}
+
+	idx.mu.Lock()
+	old := idx.graph
+	idx.graph = stage.graph
+	idx.chunks = stage.chunks
+	idx.byFile = stage.byFile
+	idx.byID = stage.byID
+	idx.byHash = stage.byHash
+	idx.removed = stage.removed
+	idx.terms.Store(stage.terms.Load())
+	idx.fileCache = stage.fileCache
+	idx.manifest = stage.manifest
+	idx.missing, idx.livenessChecked = nil, false
+	idx.lastUpdated = time.Now()
+	idx.changedUnderLock()
+	idx.dirty = true
+	idx.mu.Unlock()
+	old.Close()
+
+	return idx.Flush()
+}
+
+// newStaging returns an empty index to rebuild idx into; see Rebuild.
+func (idx *Index) newStaging() *Index {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	m := newManifest("")
+	if idx.manifest != nil {
+		c := *idx.manifest
+		c.Roots = slices.Clone(c.Roots)
+		c.Exclude = slices.Clone(c.Exclude)
+		c.IncludeOnly = slices.Clone(c.IncludeOnly)
+		c.Oversized = nil
+		c.Slowest = slices.Clone(c.Slowest)
+		m = &c
+	}
+	return &Index{
+		dir:              idx.dir,
+		root:             idx.root,
+		foldCase:         idx.foldCase,
+		graph:            hnsw.New(hnsw.DefaultM, hnsw.DefaultEfConstruction, hnsw.DefaultEfSearch),
+		fileCache:        make(map[string]fileVersion),
+		maxFileSizeBytes: idx.maxFileSizeBytes,
+		oversizedPolicy:  idx.oversizedPolicy,
+		manifest:         m,
+		log:              idx.log,
+		exclude:          idx.exclude,
+		includeOnly:      idx.includeOnly,
+		preview:          idx.preview,
+		redact:           idx.redact,
+		categories:       idx.categories,
+		metaCompression:  idx.metaCompression,
+		graphMode:        fullGraph,
+		embedLimit:       idx.embedLimit,
+		prefixes:         idx.prefixes,
+		chunkProgress:    idx.chunkProgress,
+		throttle:         idx.throttle,
+		stagingFor:       idx,
+	}
+}
@@ -0,0 +1,174 @@
+package index
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pacedEmbedder embeds like wordEmbedder, but each Embed call first waits
+// for a step, so a test can search between the files of a rebuild.
+type pacedEmbedder struct {
+	wordEmbedder
+	step chan struct{}
+}
+
+func (e *pacedEmbedder) Embed(texts []string) ([][]float32, error) {
+	<-e.step
+	return e.wordEmbedder.Embed(texts)
+}
+
+// rebuildFixture indexes n files about wireguard under a new root, then
+// rewrites them to be about gardens, so a rebuild's old and new contents
+// tell apart. It returns the index, now pacing its embeds, and the root.
+func rebuildFixture(t *testing.T, n int) (*Index, *pacedEmbedder, string) {
+	t.Helper()
+	root := t.TempDir()
+	for i := range n {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("f%02d.md", i)), []byte("wireguard tunnel"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	emb := &pacedEmbedder{step: make(chan struct{})}
+	idx := NewTestIndex(filepath.Join(root, ".sift"), wordEmbedder{})
+	if err := idx.IndexDir(context.Background(), root); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for i := range n {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("f%02d.md", i)), []byte("garden kettle"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx.embedder = emb
+	return idx, emb, root
+}
+
+// searchState returns which complete state a search of idx sees: "old"
+// or "new", failing the test on anything in between.
+func searchState(t *testing.T, idx *Index, n int) string {
+	res, err := idx.Search(context.Background(), "wireguard garden", 2*n)
+	if err != nil {
+		t.Error(err)
+		return ""
+	}
+	var old, fresh int
+	for _, r := range res {
+		switch r.Meta.Text {
+		case "wireguard tunnel":
+			old++
+		case "garden kettle":
+			fresh++
+		}
+	}
+	switch {
+	case old == n && fresh == 0 && len(res) == n:
+		return "old"
+	case fresh == n && old == 0 && len(res) == n:
+		return "new"
+	}
+	t.Errorf("search saw %d old and %d new of %d results, want all %d of one state", old, fresh, len(res), n)
+	return ""
+}
+
+func TestIndex_RebuildServesOldState(t *testing.T) {
+	const n = 6
+	idx, emb, root := rebuildFixture(t, n)
+
+	done := make(chan error, 1)
+	go func() { done <- idx.RebuildFromDir(context.Background(), root) }()
+
+	// A searcher racing the rebuild never sees a partial state.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				searchState(t, idx, n)
+			}
+		}
+	}()
+
+	// Between the files of the rebuild, searches see the old index.
+	for i := range n {
+		if got := searchState(t, idx, n); got != "old" {
+			t.Fatalf("after %d of %d files rebuilt, search saw %q, want old", i, n, got)
+		}
+		emb.step <- struct{}{}
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	close(stop)
+	wg.Wait()
+
+	if got := searchState(t, idx, n); got != "new" {
+		t.Errorf("after the rebuild, search saw %q, want new", got)
+	}
+	if s := idx.Stats(); s.NumChunks != n || s.NumFiles != n {
+		t.Errorf("Stats after the rebuild: %d chunks in %d files, want %d, %d", s.NumChunks, s.NumFiles, n, n)
+	}
+
+	// The rebuild was saved.
+	again, err := load(idx.dir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.embedder = wordEmbedder{}
+	if got := searchState(t, again, n); got != "new" {
+		t.Errorf("reopened index is %q, want new", got)
+	}
+}
+
+func TestIndex_RebuildCancelled(t *testing.T) {
+	const n = 6
+	idx, emb, root := rebuildFixture(t, n)
+	meta := filepath.Join(idx.dir, metaFile)
+	before, err := os.ReadFile(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- idx.RebuildFromDir(ctx, root) }()
+	for range n / 2 {
+		emb.step <- struct{}{}
+	}
+	cancel()
+	// Release the file being embedded when the context was cancelled.
+	select {
+	case emb.step <- struct{}{}:
+	case <-time.After(time.Second):
+	}
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("RebuildFromDir = %v, want context.Canceled", err)
+	}
+
+	if got := searchState(t, idx, n); got != "old" {
+		t.Errorf("after a cancelled rebuild, search saw %q, want old", got)
+	}
+	if idx.dirty {
+		t.Error("a cancelled rebuild left the index dirty")
+	}
+	if after, err := os.ReadFile(meta); err != nil || !bytes.Equal(after, before) {
+		t.Errorf("a cancelled rebuild changed %s (%v)", metaFile, err)
+	}
+	idx.embedder = wordEmbedder{}
+	if skipped, err := idx.AddFile(filepath.Join(root, "f00.md")); err != nil || skipped {
+		t.Errorf("AddFile after a cancelled rebuild = %v, %v; want the changed file re-embedded", skipped, err)
+	}
+}
@@ -0,0 +1,198 @@
+package index
+
+import (
+	"cmp"
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Chunk text is stored in the index (text.bin), where it outlives the
+// file it came from and is handed to whoever searches it, so text shaped
+// like a secret is replaced with Redacted before it is stored: the
+// built-in secretPatterns, and the pattern set with SetRedactPattern.
+// Embeddings still see the original text, which never leaves the
+// machine, and a truncated chunk's full text is still read from its file.
+
+// Redacted replaces each secret in stored chunk text.
+const Redacted = "[REDACTED]"
+
+// RedactCustom names the pattern set with SetRedactPattern in a
+// SecretMatch.
+const RedactCustom = "custom"
+
+// secretPattern is a regexp matching one shape of secret. When it has a
+// group, only the text the first group matched is redacted, so
+// "Authorization: Bearer abc…" keeps what the token is.
+type secretPattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// secretPatterns are the secrets always redacted.
+var secretPatterns = []secretPattern{
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)\baws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+]{40})`)},
+	{"bearer-token", regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]{20,}=*)`)},
+	{"github-token", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	// An unterminated block is a key cut off by the end of its chunk.
+	{"private-key", regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----(?s:.*?)(?:-----END [A-Z0-9 ]*PRIVATE KEY-----|\z)`)},
+}
+
+// SecretPatterns returns the names of the built-in secret patterns.
+func SecretPatterns() []string {
+	names := make([]string, len(secretPatterns))
+	for i, p := range secretPatterns {
+		names[i] = p.name
+	}
+	return names
+}
+
+// SetRedactPattern adds re to the built-in patterns redacted from the text
+// of chunks indexed from now on; nil leaves the built-ins alone. As with
+// them, only the first group is redacted if re has one. Use ScanSecrets
+// to redact chunks already stored.
+func (idx *Index) SetRedactPattern(re *regexp.Regexp) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.redact = re
+}
+
+// secretMatch is one secret found in a text: the byte range to redact
+// and the pattern that matched it.
+type secretMatch struct {
+	start, end int
+	pattern    string
+}
+
+// findSecrets returns the secrets in text, in order and not overlapping:
+// where two patterns match overlapping text, the one starting first (or,
+// from the same place, listed first) wins.
+func findSecrets(text string, custom *regexp.Regexp) []secretMatch {
+	var found []secretMatch
+	find := func(name string, re *regexp.Regexp) {
+		for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[0], m[1]
+			if len(m) > 2 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			if end > start {
+				found = append(found, secretMatch{start, end, name})
+			}
+		}
+	}
+	for _, p := range secretPatterns {
+		find(p.name, p.re)
+	}
+	if custom != nil {
+		find(RedactCustom, custom)
+	}
+	if len(found) < 2 {
+		return found
+	}
+	// Stable, so a tie keeps pattern order.
+	slices.SortStableFunc(found, func(a, b secretMatch) int { return cmp.Compare(a.start, b.start) })
+	out := found[:1]
+	for _, m := range found[1:] {
+		if last := &out[len(out)-1]; m.start < last.end {
+			last.end = max(last.end, m.end)
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// redactText replaces the secrets in text with Redacted, followed by as
+// many newlines as each spanned, so the lines after it keep their
+// numbers, and returns how many it replaced.
+func redactText(text string, custom *regexp.Regexp) (string, int) {
+	found := findSecrets(text, custom)
+	if len(found) == 0 {
+		return text, 0
+	}
+	var b strings.Builder
+	at := 0
+	for _, m := range found {
+		b.WriteString(text[at:m.start])
+		b.WriteString(Redacted)
+		b.WriteString(strings.Repeat("\n", strings.Count(text[m.start:m.end], "\n")))
+		at = m.end
+	}
+	b.WriteString(text[at:])
+	return b.String(), len(found)
+}
+
+// SecretMatch is a secret ScanSecrets found in stored chunk text.
+type SecretMatch struct {
+	Path string
+	Line int
+	// ChunkID is the ID of the chunk holding it; see ChunkMeta.ID.
+	ChunkID string
+	// Pattern names the pattern it matched: one of SecretPatterns, or
+	// RedactCustom.
+	Pattern string
+}
+
+// ScanSecrets audits the chunk text already stored for secrets, as
+// indexing now redacts them: stored before redaction existed, or before
+// the pattern set with SetRedactPattern was. It returns them in index
+// order, a line held by two overlapping chunks once. With rewrite set, it
+// also redacts them, as indexing would have; call Flush to persist that.
+func (idx *Index) ScanSecrets(ctx context.Context, rewrite bool) ([]SecretMatch, error) {
+	if rewrite {
+		idx.mu.Lock()
+		defer idx.mu.Unlock()
+		if idx.readOnly {
+			return nil, ErrReadOnly
+		}
+	} else {
+		idx.mu.RLock()
+		defer idx.mu.RUnlock()
+	}
+	type seenKey struct {
+		path    string
+		line    int
+		pattern string
+	}
+	seen := make(map[seenKey]bool)
+	var out []SecretMatch
+	changed := false
+	for i := range idx.chunks {
+		if i%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		c := &idx.chunks[i]
+		if c.removed {
+			continue
+		}
+		text := idx.textOf(*c)
+		found := findSecrets(text, idx.redact)
+		if len(found) == 0 {
+			continue
+		}
+		for _, m := range found {
+			line := c.LineNum + strings.Count(text[:m.start], "\n")
+			k := seenKey{idx.pathKey(c.Path), line, m.pattern}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			out = append(out, SecretMatch{Path: c.Path, Line: line, ChunkID: c.ID, Pattern: m.pattern})
+		}
+		if rewrite {
+			c.Text, _ = redactText(text, idx.redact)
+			c.textAt, c.textLen = 0, 0
+			changed = true
+		}
+	}
+	if changed {
+		idx.dropTermsUnderLock()
+		idx.changedUnderLock()
+		idx.dirty = true
+	}
+	return out, nil
+}
@@ -0,0 +1,78 @@
+package index
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Reranker rescores query–passage pairs, typically with a cross-encoder
+// that reads both texts together; higher scores are more relevant.
+// *embed.Reranker implements it.
+type Reranker interface {
+	Rerank(query string, passages []string) ([]float32, error)
+	Close()
+}
+
+// ErrNoReranker is returned by Rerank when SetReranker was never called.
+var ErrNoReranker = errors.New("no reranker loaded")
+
+// SetReranker makes Rerank use r. The index closes it on Close.
+func (idx *Index) SetReranker(r Reranker) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.reranker = r
+}
+
+// Rerank rescores results against query with the reranker and returns
+// them best first. Each result's Rerank holds its new score, which also
+// replaces Score; Vector and Keyword keep the first-stage breakdown, and
+// with calibration on Calibrated is recomputed across results.
+// Passages are the chunks' full text where it can still be read. The
+// reranker does not watch ctx; a rerank cancelled meanwhile returns ctx's
+// error, before and after scoring.
+func (idx *Index) Rerank(ctx context.Context, query string, results []SearchResult) ([]SearchResult, error) {
+	idx.mu.RLock()
+	r, cal := idx.reranker, idx.calibrate
+	idx.mu.RUnlock()
+	if r == nil {
+		return nil, ErrNoReranker
+	}
+	if len(results) == 0 {
+		return results, nil
+	}
+	passages := make([]string, len(results))
+	for i, res := range results {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		text, err := res.Meta.FullText()
+		if err != nil {
+			text = res.Meta.Text
+		}
+		passages[i] = text
+	}
+	scores, err := r.Rerank(query, passages)
+	if err != nil {
+		return nil, fmt.Errorf("rerank: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(scores) != len(results) {
+		return nil, fmt.Errorf("rerank: got %d scores for %d passages", len(scores), len(results))
+	}
+	out := make([]SearchResult, len(results))
+	for i, res := range results {
+		s := scores[i]
+		res.Rerank = &s
+		res.Score = s
+		out[i] = res
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	if cal {
+		calibrate(out)
+	}
+	return out, nil
+}
@@ -0,0 +1,103 @@
+package index
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ResultCache sizes the cache of recent search results, which answers a
+// repeated search (the TUI re-running a query as filters are toggled,
+// serve fielding the same request twice) without embedding the query
+// again. Entries are keyed by the normalized query, k, the Filter and the
+// index's generation, which every change to the chunks or to a ranking
+// setting advances, so a cached result is never one the index would no
+// longer give.
+type ResultCache struct {
+	// Size is how many searches are kept, the least recently used
+	// dropped first; 0 disables the cache.
+	Size int
+	// TTL is how long a cached search is reused; 0 keeps it until it is
+	// dropped or the index changes.
+	TTL time.Duration
+}
+
+// DefaultResultCache is the cache until SetResultCache is called.
+var DefaultResultCache = ResultCache{Size: 64, TTL: 5 * time.Minute}
+
+// SetResultCache resizes the result cache, emptying it.
+func (idx *Index) SetResultCache(c ResultCache) {
+	idx.results.mu.Lock()
+	defer idx.results.mu.Unlock()
+	idx.results.policy = c
+	idx.results.entries = nil
+}
+
+// resultCache holds searches' results by resultKey; see ResultCache.
+type resultCache struct {
+	mu      sync.Mutex
+	policy  ResultCache
+	entries map[string]*cachedResults
+	tick    uint64 // advanced by every get and put, to order entries by use
+}
+
+type cachedResults struct {
+	results []SearchResult
+	added   time.Time
+	used    uint64
+}
+
+// resultKey is the cache key of a search. Must be called with idx.mu held
+// (read), the generation being the one the results are, or will be, from.
+func (idx *Index) resultKey(query string, k int, f Filter) string {
+	return fmt.Sprintf("%d\x00%d\x00%#v\x00%s", idx.gen, k, f, query)
+}
+
+// changedUnderLock advances the generation, retiring every cached search.
+// Must be called with idx.mu held for writing.
+func (idx *Index) changedUnderLock() {
+	idx.gen++
+}
+
+// get returns a copy of the results cached under key, if any are and have
+// not outlived the TTL.
+func (c *resultCache) get(key string) ([]SearchResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.policy.TTL > 0 && clock().Sub(e.added) >= c.policy.TTL {
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.tick++
+	e.used = c.tick
+	return slices.Clone(e.results), true
+}
+
+// put caches a copy of results under key, dropping the least recently
+// used entry if the cache is full.
+func (c *resultCache) put(key string, results []SearchResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.policy.Size <= 0 {
+		return
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]*cachedResults)
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.policy.Size {
+		oldest := ""
+		for k, e := range c.entries {
+			if oldest == "" || e.used < c.entries[oldest].used {
+				oldest = k
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.tick++
+	c.entries[key] = &cachedResults{results: slices.Clone(results), added: clock(), used: c.tick}
+}
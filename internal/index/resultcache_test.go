@@ -0,0 +1,97 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIndex_ResultCache(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock = func() time.Time { return now }
+	t.Cleanup(func() { clock = time.Now })
+
+	dir := t.TempDir()
+	emb := &recordingEmbedder{}
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), emb)
+	idx.SetResultCache(ResultCache{Size: 2, TTL: time.Minute})
+	add := func(name, body string) {
+		t.Helper()
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("vpn.md", "wireguard tunnel setup")
+	add("go.go", "wireguard client in go")
+
+	// search runs q, reporting whether it was embedded (a cache miss).
+	search := func(q string, f Filter) ([]SearchResult, bool) {
+		t.Helper()
+		before := len(emb.queries)
+		res, err := idx.SearchFiltered(context.Background(), q, 5, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, len(emb.queries) > before
+	}
+
+	first, missed := search("wireguard", Filter{})
+	if !missed || len(first) != 2 {
+		t.Fatalf("first search: missed=%v, %d results; want a miss with 2", missed, len(first))
+	}
+	// Normalization makes these the same query.
+	if again, missed := search("  wireguard ", Filter{}); missed || len(again) != 2 {
+		t.Errorf("repeated search: missed=%v, %d results; want a hit with 2", missed, len(again))
+	}
+	first[0].Score = -1 // callers own what they are given
+	if again, _ := search("wireguard", Filter{}); again[0].Score == -1 {
+		t.Error("a cached result shares its slice with an earlier caller")
+	}
+
+	// The filter is part of the key.
+	if res, missed := search("wireguard", Filter{Exts: []string{"go"}}); !missed || len(res) != 1 {
+		t.Errorf("filtered search: missed=%v, %d results; want a miss with 1", missed, len(res))
+	}
+	if _, missed := search("wireguard", Filter{Exts: []string{"go"}}); missed {
+		t.Error("repeated filtered search missed the cache")
+	}
+
+	// Any change to the index retires what was cached.
+	add("notes.md", "wireguard peers")
+	if res, missed := search("wireguard", Filter{}); !missed || len(res) != 3 {
+		t.Errorf("after AddFile: missed=%v, %d results; want a miss with 3", missed, len(res))
+	}
+	idx.SetMaxPerDir(1)
+	if res, missed := search("wireguard", Filter{}); !missed || len(res) != 1 {
+		t.Errorf("after SetMaxPerDir: missed=%v, %d results; want a miss with 1", missed, len(res))
+	}
+
+	// Cached searches expire after the TTL.
+	now = now.Add(time.Minute)
+	if _, missed := search("wireguard", Filter{}); !missed {
+		t.Error("search past the TTL hit the cache")
+	}
+
+	// The least recently used search is dropped to make room.
+	search("tunnel", Filter{})
+	search("wireguard", Filter{})
+	search("peers", Filter{})
+	if _, missed := search("wireguard", Filter{}); missed {
+		t.Error("recently used search was evicted")
+	}
+	if _, missed := search("tunnel", Filter{}); !missed {
+		t.Error("least recently used search was kept past the cache size")
+	}
+
+	idx.SetResultCache(ResultCache{})
+	search("wireguard", Filter{})
+	if _, missed := search("wireguard", Filter{}); !missed {
+		t.Error("a disabled cache answered a search")
+	}
+}
@@ -0,0 +1,115 @@
+package index
+
+import (
+	"cmp"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tejas242/sift/internal/chunker"
+	"github.com/tejas242/sift/internal/ignore"
+)
+
+// MergedRoot is a root left out by CoverRoots: Root, as given, is Into,
+// or lies inside it.
+type MergedRoot struct {
+	Root, Into string
+	// Same is set when Root is the same directory or file as Into, spelt
+	// differently or through a symlink.
+	Same bool
+}
+
+// CoverRoots returns the smallest set of roots that indexes everything
+// roots does, in their order and spelling, and the roots it left out.
+// Roots are compared once symlinks are resolved. A root inside another
+// is left out only when walking the outer one reaches it: it is not
+// hidden or ignored there, and, being a directory, has no .siftignore of
+// its own, which the outer walk would not apply. A root that cannot be
+// resolved is kept, for indexing to report.
+func (idx *Index) CoverRoots(roots []string) (cover []string, merged []MergedRoot) {
+	type root struct {
+		arg, key, resolved string
+		dir                bool
+	}
+	var rs []root
+	for _, arg := range roots {
+		r := root{arg: arg}
+		abs, err := filepath.Abs(arg)
+		if err == nil {
+			r.resolved, err = filepath.EvalSymlinks(abs)
+		}
+		if err != nil {
+			r.resolved = filepath.Clean(arg)
+		} else if info, err := os.Stat(r.resolved); err == nil {
+			r.dir = info.IsDir()
+		}
+		r.key = idx.pathKey(r.resolved)
+		if i := slices.IndexFunc(rs, func(o root) bool { return o.key == r.key }); i >= 0 {
+			merged = append(merged, MergedRoot{Root: arg, Into: rs[i].arg, Same: true})
+			continue
+		}
+		rs = append(rs, r)
+	}
+
+	// Outer roots first, so a root inside another that is itself left out
+	// is checked against what will be walked.
+	byDepth := slices.Clone(rs)
+	slices.SortStableFunc(byDepth, func(a, b root) int {
+		return cmp.Compare(strings.Count(a.key, string(filepath.Separator)), strings.Count(b.key, string(filepath.Separator)))
+	})
+	var walked []root
+	into := make(map[string]string) // key → the root covering it
+	for _, r := range byDepth {
+		i := slices.IndexFunc(walked, func(o root) bool { return o.dir && idx.reaches(o.resolved, o.key, r.resolved, r.key, r.dir) })
+		if i < 0 {
+			walked = append(walked, r)
+			continue
+		}
+		into[r.key] = walked[i].arg
+	}
+	for _, r := range rs {
+		if outer, ok := into[r.key]; ok {
+			merged = append(merged, MergedRoot{Root: r.arg, Into: outer})
+			continue
+		}
+		cover = append(cover, r.arg)
+	}
+	return cover, merged
+}
+
+// reaches reports whether walking the directory dir would index path,
+// a directory or a file inside it; dirKey and pathKey are their
+// pathKeys. See CoverRoots.
+func (idx *Index) reaches(dir, dirKey, path, pathKey string, isDir bool) bool {
+	rel, err := filepath.Rel(dirKey, pathKey)
+	if err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return false
+	}
+	if isDir {
+		if _, err := os.Stat(filepath.Join(path, ignore.FileName)); !os.IsNotExist(err) {
+			return false
+		}
+	} else if !chunker.IsSupportedFile(path) {
+		return false
+	}
+	m, err := idx.Matcher(dir)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	for i, part := range parts {
+		if strings.HasPrefix(part, ".") {
+			return false
+		}
+		sub := filepath.ToSlash(filepath.Join(parts[:i+1]...))
+		if i < len(parts)-1 || isDir {
+			if m.SkipDir(sub) {
+				return false
+			}
+		} else if m.SkipFile(sub) {
+			return false
+		}
+	}
+	return true
+}
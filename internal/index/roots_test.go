@@ -0,0 +1,72 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tejas242/sift/internal/ignore"
+)
+
+func TestIndex_CoverRoots(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"src/api", "src/.cache/gen", "src/vendor/lib", "src/web/app", "docs"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, body := range map[string]string{
+		"src/" + ignore.FileName:     "vendor/\n",
+		"src/web/" + ignore.FileName: "*.min.js\n",
+		"src/api/auth.go":            "package api",
+		"src/api/auth.bin":           "\x00",
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "src"), filepath.Join(root, "code")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	t.Chdir(root)
+	idx := NewTestIndex(filepath.Join(root, ".sift"), &mockEmbedder{})
+
+	for _, tc := range []struct {
+		roots, cover []string
+		merged       []string
+	}{
+		{[]string{"src", "docs"}, []string{"src", "docs"}, nil},
+		// Exact duplicates, however spelt.
+		{[]string{".", "./", root}, []string{"."}, []string{"./ = .", root + " = ."}},
+		{[]string{"src", "code"}, []string{"src"}, []string{"code = src"}},
+		// Nested roots, in either order and through a symlink.
+		{[]string{"src/api", "src"}, []string{"src"}, []string{"src/api in src"}},
+		{[]string{"code/api", "docs", "src/api/auth.go", "src"}, []string{"docs", "src"},
+			[]string{"code/api in src", "src/api/auth.go in src"}},
+		// Unless the outer walk would not index them: hidden, ignored,
+		// with their own .siftignore, or a file of a type sift skips.
+		{[]string{"src", "src/.cache/gen", "src/vendor/lib", "src/web", "src/api/auth.bin"},
+			[]string{"src", "src/.cache/gen", "src/vendor/lib", "src/web", "src/api/auth.bin"}, nil},
+		// The outermost root that reaches a nested one covers it.
+		{[]string{"src/web/app", "src/web", "."}, []string{"src/web", "."},
+			[]string{"src/web/app in ."}},
+		{[]string{"src/web/app", "src", "docs/..", "src/web"}, []string{"src", "docs/..", "src/web"},
+			[]string{"src/web/app in docs/.."}},
+		{[]string{"missing", "missing/sub", "src"}, []string{"missing", "missing/sub", "src"}, nil},
+	} {
+		cover, merged := idx.CoverRoots(tc.roots)
+		var got []string
+		for _, m := range merged {
+			rel := "in"
+			if m.Same {
+				rel = "="
+			}
+			got = append(got, fmt.Sprintf("%s %s %s", m.Root, rel, m.Into))
+		}
+		if !slices.Equal(cover, tc.cover) || !slices.Equal(got, tc.merged) {
+			t.Errorf("CoverRoots(%q) = %q, %q; want %q, %q", tc.roots, cover, got, tc.cover, tc.merged)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package index
+
+import (
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// PhaseWalk times walking a root for files to index.
+const PhaseWalk = "walk"
+
+// RunPhases lists the phases RunStats times, in pipeline order.
+// PhaseTokenize and PhaseInference are part of PhaseEmbed.
+var RunPhases = runPhases[:]
+
+var runPhases = [...]string{PhaseWalk, PhaseHash, PhaseChunk, PhaseTokenize, PhaseInference, PhaseEmbed, PhaseInsert, PhaseThrottle, PhaseFlush}
+
+// RunsFile is the log sift appends one record per indexing run to, when
+// asked, for comparing runs over time.
+const RunsFile = "runs.jsonl"
+
+// RunStats aggregates one indexing run: where the time went and what was
+// done to how many files. See BeginRun.
+type RunStats struct {
+	Started time.Time
+	Elapsed time.Duration
+	// Phases maps each of RunPhases to the time spent in it.
+	Phases map[string]time.Duration
+	// FilesEmbedded were chunked and embedded; FilesSkipped were unchanged
+	// since the last run, too large, or empty; FilesErrored could not be
+	// read, chunked or embedded.
+	FilesEmbedded int
+	FilesSkipped  int
+	FilesErrored  int
+	// FilesMoved were indexed files found at a new path, which took over
+	// the chunks, and embeddings, of the old one; see reuseMoved.
+	FilesMoved int
+	// Chunks and Bytes count what was embedded.
+	Chunks int
+	Bytes  int64
+	// EmbedFailed counts the FilesErrored the embedder failed on, and
+	// EmbedFailures holds the first few of them.
+	EmbedFailed   int
+	EmbedFailures []EmbedFailure
+	// ChunksTruncated counts the Chunks longer than the embedder's
+	// maximum input, of which only the beginning was embedded, and
+	// FilesTruncated the files they came from.
+	ChunksTruncated int
+	FilesTruncated  int
+	// Redactions counts the secrets replaced with Redacted in the text
+	// stored for the Chunks, and FilesRedacted the files they came from.
+	Redactions    int
+	FilesRedacted int
+	// Slowest are the files embedded that took longest, slowest first
+	// (at most 50).
+	Slowest []FileCost
+	// Languages counts the Chunks by the language they were detected as,
+	// keyed by ISO 639-1 code ("en" included); see ChunkMeta.Lang.
+	Languages map[string]int
+}
+
+// runCounter accumulates a RunStats with atomic adds, so the hooks are
+// cheap enough to leave on for every run.
+type runCounter struct {
+	started                    atomic.Int64 // UnixNano
+	phases                     [len(runPhases)]atomic.Int64
+	embedded, skipped, errored atomic.Int64
+	moved                      atomic.Int64
+	chunks, bytes              atomic.Int64
+	embedFailed                atomic.Int64
+	truncated, truncatedFiles  atomic.Int64
+	redacted, redactedFiles    atomic.Int64
+	inARow                     atomic.Int64 // embed failures since the last file embedded
+	failures                   failureLog
+	costs                      costLog
+	langs                      langLog
+	progress                   runProgress
+}
+
+// BeginRun starts a new RunStats: counters are zeroed and the clock
+// started. Indexing is always counted; BeginRun only marks where a run
+// begins.
+func (idx *Index) BeginRun() {
+	r := idx.counter()
+	for i := range r.phases {
+		r.phases[i].Store(0)
+	}
+	for _, c := range []*atomic.Int64{&r.embedded, &r.skipped, &r.errored, &r.moved, &r.chunks, &r.bytes, &r.embedFailed, &r.truncated, &r.truncatedFiles, &r.redacted, &r.redactedFiles, &r.inARow} {
+		c.Store(0)
+	}
+	r.failures.mu.Lock()
+	r.failures.samples = nil
+	r.failures.mu.Unlock()
+	r.costs.mu.Lock()
+	r.costs.slowest = nil
+	r.costs.mu.Unlock()
+	r.langs.mu.Lock()
+	r.langs.chunks = nil
+	r.langs.mu.Unlock()
+	r.progress.mu.Lock()
+	r.progress.root = ""
+	r.progress.mu.Unlock()
+	r.started.Store(time.Now().UnixNano())
+}
+
+// RunStats returns what has been counted since BeginRun.
+func (idx *Index) RunStats() RunStats {
+	r := idx.counter()
+	s := RunStats{
+		Phases:          make(map[string]time.Duration, len(RunPhases)),
+		FilesEmbedded:   int(r.embedded.Load()),
+		FilesSkipped:    int(r.skipped.Load()),
+		FilesErrored:    int(r.errored.Load()),
+		FilesMoved:      int(r.moved.Load()),
+		Chunks:          int(r.chunks.Load()),
+		Bytes:           r.bytes.Load(),
+		EmbedFailed:     int(r.embedFailed.Load()),
+		ChunksTruncated: int(r.truncated.Load()),
+		FilesTruncated:  int(r.truncatedFiles.Load()),
+		Redactions:      int(r.redacted.Load()),
+		FilesRedacted:   int(r.redactedFiles.Load()),
+	}
+	r.failures.mu.Lock()
+	s.EmbedFailures = slices.Clone(r.failures.samples)
+	r.failures.mu.Unlock()
+	r.costs.mu.Lock()
+	s.Slowest = slices.Clone(r.costs.slowest)
+	r.costs.mu.Unlock()
+	s.Languages = r.runLanguages()
+	if ns := r.started.Load(); ns != 0 {
+		s.Started = time.Unix(0, ns)
+		s.Elapsed = time.Since(s.Started)
+	}
+	for i, p := range RunPhases {
+		s.Phases[p] = time.Duration(r.phases[i].Load())
+	}
+	return s
+}
+
+// addPhase counts d towards phase and passes it on to the SetPhaseFunc
+// hook, if any. Embedders report tokenize and inference time through it.
+func (idx *Index) addPhase(phase string, d time.Duration) {
+	if p := idx.stagingFor; p != nil {
+		p.addPhase(phase, d)
+		return
+	}
+	if i := slices.Index(runPhases[:], phase); i >= 0 {
+		idx.run.phases[i].Add(int64(d))
+	}
+	if idx.phase != nil {
+		idx.phase(phase, d)
+	}
+}
+
+// countFile records the outcome of one AddFile call; chunks and size
+// count only for fileEmbedded. Embedding failures go through embedFailed.
+func (idx *Index) countFile(outcome fileOutcome, chunks int, size int64) {
+	r := idx.counter()
+	switch outcome {
+	case fileEmbedded:
+		r.embedded.Add(1)
+		r.inARow.Store(0)
+		r.chunks.Add(int64(chunks))
+		r.bytes.Add(size)
+	case fileSkipped:
+		r.skipped.Add(1)
+	case fileErrored:
+		r.errored.Add(1)
+	case fileMoved:
+		r.moved.Add(1)
+	}
+}
+
+// countTruncated records that n chunks of a file just embedded were
+// truncated by the embedder.
+func (idx *Index) countTruncated(n int) {
+	if n > 0 {
+		r := idx.counter()
+		r.truncated.Add(int64(n))
+		r.truncatedFiles.Add(1)
+	}
+}
+
+// countRedacted records that n secrets were redacted from the chunks of a
+// file just embedded.
+func (idx *Index) countRedacted(n int) {
+	if n > 0 {
+		r := idx.counter()
+		r.redacted.Add(int64(n))
+		r.redactedFiles.Add(1)
+	}
+}
+
+// counter returns the counter idx's indexing is counted in: its own, or
+// for a rebuild's staging index that of the index it stages for.
+func (idx *Index) counter() *runCounter {
+	if p := idx.stagingFor; p != nil {
+		return &p.run
+	}
+	return &idx.run
+}
+
+type fileOutcome int
+
+const (
+	fileEmbedded fileOutcome = iota
+	fileSkipped
+	fileErrored
+	fileMoved
+)
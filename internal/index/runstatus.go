@@ -0,0 +1,90 @@
+package index
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RunStatus is a snapshot of an index run in progress, for reporting it
+// while it runs (sift index on SIGUSR1). See Status.
+type RunStatus struct {
+	// Root is the directory or file being indexed, or "" before the first
+	// one starts.
+	Root string
+	// Done of Total files of Root are indexed; Total is 0 while Root is
+	// still being walked. Current is the file being indexed, if any.
+	Done, Total int
+	Current     string
+	// RootStarted is when indexing Root began.
+	RootStarted time.Time
+	// Run holds the run's counters so far.
+	Run RunStats
+}
+
+// runProgress is where IndexDirWithProgress is, for Status.
+type runProgress struct {
+	mu          sync.Mutex
+	root        string
+	done, total int
+	current     string
+	started     time.Time
+
+	// flush is set by RequestFlush and taken at the next file boundary.
+	flush atomic.Bool
+}
+
+// Status returns where the index run in progress is.
+func (idx *Index) Status() RunStatus {
+	p := &idx.counter().progress
+	p.mu.Lock()
+	s := RunStatus{Root: p.root, Done: p.done, Total: p.total, Current: p.current, RootStarted: p.started}
+	p.mu.Unlock()
+	s.Run = idx.RunStats()
+	return s
+}
+
+// startRoot records that root, with total files (0 until walked), is
+// being indexed; its start time is kept when it is already.
+func (idx *Index) startRoot(root string, total int) {
+	p := &idx.counter().progress
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.root != root {
+		p.root, p.started = root, time.Now()
+	}
+	p.done, p.total, p.current = 0, total, ""
+}
+
+// noteFile records that the root's file path is being indexed, with done
+// files before it; path is "" once the last is done.
+func (idx *Index) noteFile(done int, path string) {
+	p := &idx.counter().progress
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done, p.current = done, path
+}
+
+// RequestFlush asks the index run in progress to save what it has indexed
+// at the next file boundary, as a checkpoint a crash cannot take back. A
+// rebuild's staging index is only saved once complete, so it declines.
+func (idx *Index) RequestFlush() {
+	idx.counter().progress.flush.Store(true)
+}
+
+// checkpoint saves the index if RequestFlush was called, logging the
+// outcome; a failure is left for the run's final Flush to report.
+func (idx *Index) checkpoint() {
+	if !idx.counter().progress.flush.CompareAndSwap(true, false) {
+		return
+	}
+	if idx.stagingFor != nil {
+		idx.log.Infof("checkpoint: a rebuild is saved only once complete")
+		return
+	}
+	if err := idx.Flush(); err != nil {
+		idx.log.Warnf("checkpoint: %v", err)
+		return
+	}
+	idx.log.Infof("checkpoint: saved %d chunks", idx.Stats().NumChunks)
+}
@@ -0,0 +1,64 @@
+package index
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndex_StatusAndCheckpoint(t *testing.T) {
+	root := t.TempDir()
+	siftDir := filepath.Join(root, ".sift")
+	idx := NewTestIndex(siftDir, &mockEmbedder{})
+	for i := range 4 {
+		p := filepath.Join(root, fmt.Sprintf("f%d.md", i))
+		if err := os.WriteFile(p, []byte(fmt.Sprintf("file %d", i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx.BeginRun()
+	if s := idx.Status(); s.Root != "" {
+		t.Errorf("Status before the run = %+v, want no root", s)
+	}
+	var saved []int // chunks on disk after each file
+	err := idx.IndexDirWithProgress(t.Context(), root, func(done, total int, path string, _ bool) {
+		s := idx.Status()
+		if s.Root != root || s.Done != done || s.Total != 4 || s.Current != "" || s.Run.Chunks != done {
+			t.Errorf("Status after file %d = %+v, want %d/4 of %s and %d chunks", done, s, done, root, done)
+		}
+		if done == 2 {
+			idx.RequestFlush()
+		}
+		n := 0
+		if on, err := load(siftDir, "", fullGraph); err == nil {
+			n = len(on.chunks)
+		}
+		saved = append(saved, n)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The flush requested after the second file happens once it is reported.
+	if want := []int{0, 0, 2, 2}; fmt.Sprint(saved) != fmt.Sprint(want) {
+		t.Errorf("chunks saved after each file = %v, want %v", saved, want)
+	}
+
+	// A rebuild's staging index declines: it is saved only once complete.
+	err = idx.Rebuild(func(stage *Index) error {
+		stage.RequestFlush()
+		return stage.IndexDirWithProgress(t.Context(), root, func(done, _ int, _ string, _ bool) {
+			on, err := load(siftDir, "", fullGraph)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(on.chunks) != 2 {
+				t.Errorf("after staging file %d: %d chunks on disk, want the checkpoint's 2", done, len(on.chunks))
+			}
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,39 @@
+package index
+
+import (
+	"io/fs"
+	"time"
+)
+
+// MarkStale sets Stale on the results whose file was modified after it
+// was indexed: its mtime on disk is no longer the one recorded with its
+// chunks, so the text and line shown may be out of date. Each file is
+// stat'ed once. A file gone from disk is not marked; pruning drops it.
+// It returns the stale files, in the order of their first result.
+func MarkStale(results []SearchResult) []string {
+	indexed := make(map[string]time.Time)
+	var paths []string
+	for _, r := range results {
+		if _, ok := indexed[r.Meta.Path]; !ok {
+			indexed[r.Meta.Path] = r.Meta.Mtime
+			paths = append(paths, r.Meta.Path)
+		}
+	}
+	modified := make(map[string]bool)
+	statFiles(paths, func(p string, fi fs.FileInfo, err error) {
+		if err == nil && !fi.ModTime().Equal(indexed[p]) {
+			modified[p] = true
+		}
+	})
+
+	for i := range results {
+		results[i].Stale = modified[results[i].Meta.Path]
+	}
+	var stale []string
+	for _, p := range paths {
+		if modified[p] {
+			stale = append(stale, p)
+		}
+	}
+	return stale
+}
@@ -0,0 +1,44 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMarkStale(t *testing.T) {
+	dir := t.TempDir()
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), wordEmbedder{})
+	var results []SearchResult
+	for _, name := range []string{"a.md", "b.md", "gone.md"} {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("wireguard"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range idx.FileChunks(p) {
+			results = append(results, SearchResult{Meta: c}, SearchResult{Meta: c})
+		}
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "b.md"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "gone.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	stale := MarkStale(results)
+	if want := []string{filepath.Join(dir, "b.md")}; !slices.Equal(stale, want) {
+		t.Errorf("MarkStale = %q, want %q", stale, want)
+	}
+	for _, r := range results {
+		if want := filepath.Base(r.Meta.Path) == "b.md"; r.Stale != want {
+			t.Errorf("%s: Stale = %v, want %v", filepath.Base(r.Meta.Path), r.Stale, want)
+		}
+	}
+}
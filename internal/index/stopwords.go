@@ -0,0 +1,74 @@
+package index
+
+import (
+	"slices"
+	"strings"
+)
+
+// KeywordPolicy selects the query words that earn KeywordBoost: those
+// longer than two letters and not in Stopwords, or none at all when
+// fewer than MinWords remain, leaving the ranking to the embedding. A
+// natural-language query ("how does the token get refreshed") is then
+// boosted for its content words only.
+type KeywordPolicy struct {
+	// Stopwords are lower-case words never boosted.
+	Stopwords []string
+	// MinWords is how many content words a query needs to be boosted at
+	// all; 0 or 1 boosts any query with one.
+	MinWords int
+}
+
+// DefaultKeywordPolicy is the policy until SetKeywordPolicy is called.
+var DefaultKeywordPolicy = KeywordPolicy{Stopwords: DefaultStopwords, MinWords: 1}
+
+// DefaultStopwords lists common English words that say little about what
+// a query is after: function words, question words and verbs found in
+// most text. Words of two letters or fewer are never boosted anyway.
+var DefaultStopwords = []string{
+	"about", "after", "again", "all", "also", "and", "any", "are", "been",
+	"before", "being", "between", "both", "but", "can", "could", "did",
+	"does", "doing", "done", "each", "else", "every", "for", "from", "get",
+	"gets", "getting", "got", "had", "has", "have", "having", "her", "here",
+	"him", "his", "how", "into", "its", "just", "make", "makes", "more",
+	"most", "much", "must", "not", "off", "once", "only", "other", "our",
+	"out", "over", "own", "same", "she", "should", "some", "such", "than",
+	"that", "the", "their", "them", "then", "there", "these", "they",
+	"this", "those", "through", "too", "under", "until", "use", "used",
+	"uses", "using", "very", "was", "way", "were", "what", "when", "where",
+	"which", "while", "who", "whom", "whose", "why", "will", "with",
+	"within", "without", "would", "you", "your",
+}
+
+// SetKeywordPolicy changes which query words earn the keyword boost; it
+// is DefaultKeywordPolicy until set.
+func (idx *Index) SetKeywordPolicy(p KeywordPolicy) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.keywords = p
+	idx.changedUnderLock()
+}
+
+// boostWords returns the words of query that earn the keyword boost, in
+// query order and lower-cased, split at anything but letters, digits and
+// underscores; see KeywordPolicy and matchTerms. Must be called with
+// idx.mu held (read).
+func (idx *Index) boostWords(query string) []string {
+	words := idx.contentWords(query)
+	if len(words) < idx.keywords.MinWords {
+		return nil
+	}
+	return words
+}
+
+// contentWords returns the words of query longer than two letters and
+// not stopwords, as boostWords does but however few there are. Must be
+// called with idx.mu held (read).
+func (idx *Index) contentWords(query string) []string {
+	var words []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool { return !isIdentRune(r) }) {
+		if len(w) > 2 && !slices.Contains(idx.keywords.Stopwords, w) {
+			words = append(words, w)
+		}
+	}
+	return words
+}
@@ -0,0 +1,118 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// vocabEmbedder embeds a text as its counts of each of its words, one
+// axis per word, ignoring the rest.
+type vocabEmbedder []string
+
+func (e vocabEmbedder) Embed(texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i, s := range texts {
+		v := make([]float32, 384)
+		for _, w := range strings.Fields(strings.ToLower(s)) {
+			if a := slices.Index(e, w); a >= 0 {
+				v[a]++
+			}
+		}
+		l2Normalize(v)
+		vecs[i] = v
+	}
+	return vecs, nil
+}
+
+func (e vocabEmbedder) EmbedQuery(q string) ([]float32, error) {
+	v, err := e.Embed([]string{q})
+	return v[0], err
+}
+
+func (vocabEmbedder) Close() {}
+
+func TestIndex_KeywordPolicy(t *testing.T) {
+	dir := t.TempDir()
+	// Both files embed alike, so the keyword boost alone ranks them: one
+	// is about the query's subject, the other merely full of its
+	// stopwords.
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), vocabEmbedder{"token", "refreshed"})
+	files := map[string]string{
+		"auth.md": "auth token refreshed",
+		"faq.md":  "how does the build get the token refreshed when the cache does not get cleared",
+	}
+	for name, body := range files {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const query = "how does the auth token get refreshed"
+	ranking := func() (names []string, matched [][]string) {
+		t.Helper()
+		res, err := idx.Search(context.Background(), query, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range res {
+			names = append(names, filepath.Base(r.Meta.Path))
+			matched = append(matched, r.Matched)
+		}
+		return names, matched
+	}
+
+	// Without stopwords, the file full of them wins.
+	idx.SetKeywordPolicy(KeywordPolicy{})
+	if got, matched := ranking(); !slices.Equal(got, []string{"faq.md", "auth.md"}) {
+		t.Errorf("without stopwords: ranking %v (matched %q), want faq.md first", got, matched)
+	}
+
+	// With them, only content words are boosted.
+	idx.SetKeywordPolicy(DefaultKeywordPolicy)
+	got, matched := ranking()
+	if !slices.Equal(got, []string{"auth.md", "faq.md"}) {
+		t.Errorf("with stopwords: ranking %v, want auth.md first", got)
+	}
+	if want := [][]string{{"auth", "token", "refreshed"}, {"token", "refreshed"}}; !slices.EqualFunc(matched, want, slices.Equal) {
+		t.Errorf("matched words %q, want %q", matched, want)
+	}
+
+	ex, err := idx.Explain(query, filepath.Join(dir, "faq.md"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ex.BoostWords, []string{"auth", "token", "refreshed"}) || ex.Rank != 2 {
+		t.Errorf("Explain: boost words %q, rank %d; want the three content words, rank 2", ex.BoostWords, ex.Rank)
+	}
+	if len(ex.Chunks) != 1 || !slices.Equal(ex.Chunks[0].Matched, []string{"token", "refreshed"}) {
+		t.Errorf("Explain chunks = %+v, want faq.md matching token and refreshed", ex.Chunks)
+	}
+
+	// Too few content words: no boost at all.
+	for _, tc := range []struct {
+		policy KeywordPolicy
+		query  string
+	}{
+		{KeywordPolicy{Stopwords: DefaultStopwords, MinWords: 4}, query},
+		{DefaultKeywordPolicy, "how does it get there"},
+	} {
+		idx.SetKeywordPolicy(tc.policy)
+		res, err := idx.Search(context.Background(), tc.query, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range res {
+			if r.Keyword != 0 || r.Matched != nil {
+				t.Errorf("%q with min %d: %s boosted %v for %q, want no boost",
+					tc.query, tc.policy.MinWords, filepath.Base(r.Meta.Path), r.Keyword, r.Matched)
+			}
+		}
+	}
+}
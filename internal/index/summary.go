@@ -0,0 +1,164 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const summaryFile = "summary.json"
+
+// Summary is the small stats file written on every Flush, so a dashboard
+// (`sift stats --watch`), a shell prompt or an editor's status line can
+// read an index's size and age without parsing meta.json or loading the
+// model.
+type Summary struct {
+	Chunks  int       `json:"chunks"`
+	Files   int       `json:"files"`
+	Updated time.Time `json:"updated"`
+	// FormatVersion, Model and Roots are copied from the manifest; a
+	// summary written before they were added has FormatVersion 0.
+	FormatVersion int      `json:"format_version,omitempty"`
+	Model         string   `json:"model,omitempty"`
+	Roots         []string `json:"roots,omitempty"`
+	// SizeBytes is the total size of the index's files, measured by
+	// ReadSummary rather than stored.
+	SizeBytes int64 `json:"-"`
+}
+
+// ReadSummary loads the summary stored in dir and measures the index's
+// size on disk, without opening the index. It returns an error satisfying os.IsNotExist when the
+// index has not been flushed since summaries were introduced.
+func ReadSummary(dir string) (*Summary, error) {
+	data, err := os.ReadFile(filepath.Join(dir, summaryFile))
+	if err != nil {
+		return nil, err
+	}
+	var s Summary
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrCorrupt, summaryFile, err)
+	}
+	for _, name := range ArtifactFiles {
+		if fi, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			s.SizeBytes += fi.Size()
+		}
+	}
+	return &s, nil
+}
+
+// writeSummaryUnderLock atomically writes idx's summary to path (tmp →
+// rename). Must be called with idx.mu held.
+func (idx *Index) writeSummaryUnderLock(path string) error {
+	files := make(map[string]struct{})
+	live := idx.liveChunks()
+	for _, c := range live {
+		files[c.Path] = struct{}{}
+	}
+	s := Summary{Chunks: len(live), Files: len(files), Updated: idx.lastUpdated}
+	if m := idx.manifest; m != nil {
+		s.FormatVersion, s.Model, s.Roots = m.FormatVersion, m.Model, m.Roots
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshal summary: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write summary tmp: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename summary: %w", err)
+	}
+	return nil
+}
+
+// recordSettingsUnderLock records in a reloaded manifest the settings
+// SetPatterns, SetPreview and SetPrefixes keep there. Must be called with
+// idx.mu held for writing.
+func (idx *Index) recordSettingsUnderLock() {
+	if idx.manifest == nil {
+		idx.manifest = newManifest("")
+	}
+	if idx.patternsSet {
+		idx.manifest.Exclude, idx.manifest.IncludeOnly = idx.exclude, idx.includeOnly
+	}
+	idx.manifest.Preview = idx.preview
+	if len(idx.chunks) == idx.removed {
+		idx.recordPrefixesUnderLock(idx.prefixes)
+	}
+}
+
+// savedElsewhereUnderLock reports whether another process has saved the
+// index since idx last loaded or saved it, going by the manifest, which
+// every Flush writes. Must be called with idx.mu held.
+func (idx *Index) savedElsewhereUnderLock() bool {
+	var on time.Time
+	if m, err := ReadManifest(idx.dir); err == nil {
+		on = m.Updated
+	}
+	return !on.Equal(idx.savedUpdated)
+}
+
+// Refresh reloads the index from disk if another process has flushed it
+// since idx was loaded, so a long-running reader (`sift serve`) answers
+// from the current data. It reports whether anything was reloaded. The
+// settings applied to idx are kept and recorded again in the reloaded
+// manifest. An index with unflushed changes of its own is never replaced
+// (a read-only one's are dropped), and a reload caught mid-flush (the
+// files disagree with the summary) is skipped until the next call.
+func (idx *Index) Refresh() (bool, error) {
+	s, err := ReadSummary(idx.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	idx.mu.RLock()
+	// Unsaved changes are kept, unless the index is read-only and cannot
+	// save them anyway.
+	current := (idx.dirty && !idx.readOnly) || s.Updated.Equal(idx.savedUpdated)
+	idx.mu.RUnlock()
+	if current {
+		return false, nil
+	}
+
+	fresh, err := load(idx.dir, "", idx.graphMode)
+	if err != nil {
+		return false, err
+	}
+	if len(fresh.chunks) != s.Chunks || !fresh.lastUpdated.Equal(s.Updated) {
+		fresh.graph.Close()
+		fresh.closeTexts()
+		return false, nil
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.dirty && !idx.readOnly {
+		fresh.graph.Close()
+		fresh.closeTexts()
+		return false, nil
+	}
+	idx.chunks = fresh.chunks
+	idx.byFile = fresh.byFile
+	idx.byID = fresh.byID
+	idx.byHash = fresh.byHash
+	idx.removed = fresh.removed
+	idx.terms.Store(fresh.terms.Load())
+	idx.graph.Close()
+	idx.graph = fresh.graph
+	idx.closeTexts()
+	idx.texts = fresh.texts
+	idx.fileCache = fresh.fileCache
+	idx.manifest = fresh.manifest
+	idx.recordSettingsUnderLock()
+	idx.lastUpdated, idx.savedUpdated = fresh.lastUpdated, fresh.savedUpdated
+	idx.changedUnderLock()
+	idx.dirty = false
+	idx.metaBytes, idx.metaCompressed = fresh.metaBytes, fresh.metaCompressed
+	return true, nil
+}
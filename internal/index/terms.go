@@ -0,0 +1,347 @@
+package index
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The keyword boost matches query words against the terms of a chunk
+// rather than its raw text. A term is a word of the text, lower-cased, or
+// a run of the subtokens an identifier splits into at underscores and
+// case changes, joined: o_auth_token and OAuthToken both hold the terms
+// "oauth", "auth", "authtoken", "token" and "oauthtoken", so a search for
+// OAuth (or oauth, or o_auth) matches either, while "oauthless" holds
+// only itself and matches none of them.
+//
+// Matches are weighted by how rare the term is across the index, from its
+// document frequency in the term index: the number of chunks holding
+// each term. For the terms held by at most maxPostings chunks it also
+// lists those chunks, for the lexical query plan (see queryplan.go). The term
+// index is kept in memory, extended as chunks are
+// added, and saved as terms.bin with the chunks it was built from; any
+// other change to the chunks drops it, and it is rebuilt on the next
+// search or save.
+
+const termsFile = "terms.bin"
+
+var termsMagic = [8]byte{'S', 'I', 'F', 'T', 'T', 'R', 'M', '2'}
+
+// maxRun is the most subtokens of one identifier joined into a term.
+const maxRun = 4
+
+// minTermLen is the shortest term, in bytes, that is indexed or boosted.
+const minTermLen = 3
+
+// minTermWeight and maxTermWeight bound the factor KeywordBoost is scaled
+// by: a term in every chunk gets the first, one in a single chunk the
+// second.
+const (
+	minTermWeight = 0.5
+	maxTermWeight = 2
+)
+
+// isIdentRune reports whether r belongs to a word: identifiers are words
+// too, underscores and all.
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// subtokens splits an identifier at underscores and case changes
+// (getHTTPServer → get, HTTP, Server), keeping digits with what precedes
+// them.
+func subtokens(word string) []string {
+	var out []string
+	for _, part := range strings.Split(word, "_") {
+		start := 0
+		prev := rune(0)
+		for i, r := range part {
+			if i > start {
+				next, _ := utf8.DecodeRuneInString(part[i+utf8.RuneLen(r):])
+				lowerToUpper := unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev))
+				acronymEnd := unicode.IsUpper(r) && unicode.IsUpper(prev) && unicode.IsLower(next)
+				if lowerToUpper || acronymEnd {
+					out = append(out, part[start:i])
+					start = i
+				}
+			}
+			prev = r
+		}
+		if start < len(part) {
+			out = append(out, part[start:])
+		}
+	}
+	return out
+}
+
+// termOf returns the term a query word is matched as: its subtokens,
+// lower-cased and joined.
+func termOf(word string) string {
+	return strings.ToLower(strings.ReplaceAll(word, "_", ""))
+}
+
+// eachTerm calls fn with every term of text (see the comment at the top
+// of this file) and the word it is in, as written; a term may repeat.
+func eachTerm(text string, fn func(term, word string)) {
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool { return !isIdentRune(r) }) {
+		subs := subtokens(word)
+		for i := range subs {
+			for j := i + 1; j <= min(i+maxRun, len(subs)); j++ {
+				if t := strings.ToLower(strings.Join(subs[i:j], "")); len(t) >= minTermLen {
+					fn(t, word)
+				}
+			}
+		}
+		if len(subs) > maxRun {
+			if t := termOf(word); len(t) >= minTermLen {
+				fn(t, word)
+			}
+		}
+	}
+}
+
+// chunkTerms returns the distinct terms of text, each with the first word
+// it was found in.
+func chunkTerms(text string) map[string]string {
+	terms := make(map[string]string)
+	eachTerm(text, func(t, w string) {
+		if _, ok := terms[t]; !ok {
+			terms[t] = w
+		}
+	})
+	return terms
+}
+
+// termIndex holds the document frequency of every term: how many chunks
+// hold it.
+type termIndex struct {
+	df map[string]int32
+	// postings lists the IDs of the chunks holding each term, in order,
+	// for the terms held by at most maxPostings chunks.
+	postings map[string][]uint32
+	chunks   int
+}
+
+func newTermIndex() *termIndex {
+	return &termIndex{df: make(map[string]int32), postings: make(map[string][]uint32)}
+}
+
+// add counts the terms of one more chunk, of the given text, which is
+// the chunk with ID ti.chunks.
+func (ti *termIndex) add(text string) {
+	id := uint32(ti.chunks)
+	for t := range chunkTerms(text) {
+		ti.df[t]++
+		switch df := ti.df[t]; {
+		case df <= maxPostings:
+			ti.postings[t] = append(ti.postings[t], id)
+		case df == maxPostings+1:
+			delete(ti.postings, t)
+		}
+	}
+	ti.chunks++
+}
+
+// weight is the factor KeywordBoost is scaled by for a match on term: from
+// minTermWeight for a term in every chunk to maxTermWeight for one in a
+// single chunk, by inverse document frequency.
+func (ti *termIndex) weight(term string) float32 {
+	n := ti.chunks
+	df := max(int(ti.df[term]), 1)
+	if n < 2 {
+		return 1
+	}
+	idf := math.Log(float64(n+1) / float64(df+1))
+	top := math.Log(float64(n+1) / 2)
+	return float32(minTermWeight + (maxTermWeight-minTermWeight)*min(idf/top, 1))
+}
+
+// buildTerms counts the terms of every chunk. Must be called with idx.mu
+// held (read).
+func (idx *Index) buildTerms() *termIndex {
+	ti := newTermIndex()
+	for _, c := range idx.chunks {
+		if c.removed {
+			ti.add("") // keeps the IDs of the chunks after it
+			continue
+		}
+		ti.add(idx.textOf(c))
+	}
+	return ti
+}
+
+// termIndex returns the term index, building it if a change to the
+// chunks dropped it. Must be called with idx.mu held (read).
+func (idx *Index) termIndex() *termIndex {
+	if ti := idx.terms.Load(); ti != nil {
+		return ti
+	}
+	idx.termsMu.Lock()
+	defer idx.termsMu.Unlock()
+	if ti := idx.terms.Load(); ti != nil {
+		return ti
+	}
+	ti := idx.buildTerms()
+	idx.terms.Store(ti)
+	return ti
+}
+
+// addTermsUnderLock counts the terms of a chunk just appended, if the term
+// index is built. Must be called with idx.mu held for writing.
+func (idx *Index) addTermsUnderLock(text string) {
+	if ti := idx.terms.Load(); ti != nil {
+		ti.add(text)
+	}
+}
+
+// dropTermsUnderLock discards the term index after a change to the chunks
+// other than appending one. Must be called with idx.mu held for writing.
+func (idx *Index) dropTermsUnderLock() {
+	idx.terms.Store(nil)
+}
+
+// matchTerms returns the boost words (see boostWords) among the terms of
+// text, with the word of text each was found in and the sum of their
+// weights.
+func (idx *Index) matchTerms(queryWords []string, text string) (matched, tokens []string, weight float32) {
+	if len(queryWords) == 0 {
+		return nil, nil, 0
+	}
+	terms := chunkTerms(text)
+	ti := idx.termIndex()
+	for _, w := range queryWords {
+		t := termOf(w)
+		if word, ok := terms[t]; ok {
+			matched = append(matched, w)
+			tokens = append(tokens, word)
+			weight += ti.weight(t)
+		}
+	}
+	return matched, tokens, weight
+}
+
+// writeTermsUnderLock saves the term index to path, a terms.bin (atomic
+// write: tmp → sync → rename), with the checksum of the chunks it counts (see
+// textsSum): magic, checksum, chunk count, term count, then each term's
+// length, bytes, document frequency and postings count as uvarints,
+// followed by its postings as uvarint deltas. Must be called with idx.mu
+// held for writing.
+func (idx *Index) writeTermsUnderLock(path string) error {
+	ti := idx.terms.Load()
+	if ti == nil {
+		ti = idx.buildTerms()
+		idx.terms.Store(ti)
+	}
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("write terms: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	w.Write(termsMagic[:])
+	var buf [binary.MaxVarintLen64]byte
+	binary.Write(w, binary.LittleEndian, textsSum(idx.chunks))
+	binary.Write(w, binary.LittleEndian, uint64(ti.chunks))
+	binary.Write(w, binary.LittleEndian, uint64(len(ti.df)))
+	for _, t := range slices.Sorted(maps.Keys(ti.df)) {
+		w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(t)))])
+		w.WriteString(t)
+		w.Write(buf[:binary.PutUvarint(buf[:], uint64(ti.df[t]))])
+		ids := ti.postings[t]
+		w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(ids)))])
+		prev := uint32(0)
+		for _, id := range ids {
+			w.Write(buf[:binary.PutUvarint(buf[:], uint64(id-prev))])
+			prev = id
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write terms: %w", err)
+	}
+	return nil
+}
+
+// errTermsStale is returned by readTerms for a terms.bin counting other
+// chunks than meta.json holds.
+var errTermsStale = errors.New("terms.bin does not match meta.json")
+
+// readTerms loads the term index saved in dir for chunks. It returns an
+// error for a missing, damaged or stale terms.bin; the index is then
+// rebuilt when needed, so none of these is a corruption.
+func readTerms(dir string, chunks []ChunkMeta) (*termIndex, error) {
+	f, err := os.Open(filepath.Join(dir, termsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := bufio.NewReader(f)
+	var header struct {
+		Magic              [8]byte
+		Sum, Chunks, Terms uint64
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != termsMagic || header.Sum != textsSum(chunks) || header.Chunks != uint64(len(chunks)) {
+		return nil, errTermsStale
+	}
+	ti := newTermIndex()
+	ti.chunks = len(chunks)
+	for range header.Terms {
+		n, err := binary.ReadUvarint(r)
+		if err != nil || n > 1<<16 {
+			return nil, errTermsStale
+		}
+		t := make([]byte, n)
+		if _, err := io.ReadFull(r, t); err != nil {
+			return nil, err
+		}
+		df, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		n, err = binary.ReadUvarint(r)
+		if err != nil || n > df || n > maxPostings {
+			return nil, errTermsStale
+		}
+		if n > 0 {
+			ids := make([]uint32, n)
+			prev := uint64(0)
+			for i := range ids {
+				d, err := binary.ReadUvarint(r)
+				if err != nil {
+					return nil, err
+				}
+				prev += d
+				if prev >= header.Chunks {
+					return nil, errTermsStale
+				}
+				ids[i] = uint32(prev)
+			}
+			ti.postings[string(t)] = ids
+		}
+		ti.df[string(t)] = int32(df)
+	}
+	return ti, nil
+}
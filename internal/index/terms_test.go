@@ -0,0 +1,147 @@
+package index
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSubtokens(t *testing.T) {
+	for word, want := range map[string][]string{
+		"getHTTPServer": {"get", "HTTP", "Server"},
+		"OAuthToken":    {"O", "Auth", "Token"},
+		"o_auth_token":  {"o", "auth", "token"},
+		"MAX_RETRIES":   {"MAX", "RETRIES"},
+		"utf8Decode":    {"utf8", "Decode"},
+		"sha256Sum":     {"sha256", "Sum"},
+		"_private":      {"private"},
+		"wireguard":     {"wireguard"},
+	} {
+		if got := subtokens(word); !slices.Equal(got, want) {
+			t.Errorf("subtokens(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestChunkTerms(t *testing.T) {
+	terms := chunkTerms("func refreshOAuthToken(o_auth_token string)")
+	for _, term := range []string{"refresh", "oauth", "auth", "token", "oauthtoken", "refreshoauthtoken", "func", "string"} {
+		if _, ok := terms[term]; !ok {
+			t.Errorf("terms lack %q: %v", term, terms)
+		}
+	}
+	if w := terms["oauth"]; w != "refreshOAuthToken" {
+		t.Errorf("oauth found in %q, want the first word holding it", w)
+	}
+	for _, term := range []string{"o", "oauthtokens", "tokenstring"} {
+		if _, ok := terms[term]; ok {
+			t.Errorf("terms hold %q", term)
+		}
+	}
+}
+
+// termsFixture indexes files whose embeddings all match the query alike,
+// so the keyword boost alone ranks them.
+func termsFixture(t *testing.T, files map[string]string) *Index {
+	t.Helper()
+	dir := t.TempDir()
+	idx := NewTestIndex(filepath.Join(dir, ".sift"), &mockEmbedder{})
+	idx.SetKeywordPolicy(KeywordPolicy{MinWords: 1})
+	for name, body := range files {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return idx
+}
+
+func TestSearch_IdentifierMatch(t *testing.T) {
+	idx := termsFixture(t, map[string]string{
+		"camel.go": "func refreshOAuthToken() error",
+		"snake.py": "def refresh(o_auth_token): pass",
+		"prose.md": "an oauthless login page",
+	})
+	res, err := idx.Search(context.Background(), "oauth", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]SearchResult)
+	for _, r := range res {
+		got[filepath.Base(r.Meta.Path)] = r
+	}
+	for name, token := range map[string]string{"camel.go": "refreshOAuthToken", "snake.py": "o_auth_token"} {
+		r := got[name]
+		if r.Keyword <= 0 || !slices.Equal(r.Matched, []string{"oauth"}) || !slices.Equal(r.MatchedTokens, []string{token}) {
+			t.Errorf("%s: keyword %v, matched %q in %q; want oauth boosted in %s", name, r.Keyword, r.Matched, r.MatchedTokens, token)
+		}
+	}
+	if r := got["prose.md"]; r.Keyword != 0 || len(r.Matched) != 0 {
+		t.Errorf("prose.md: keyword %v, matched %q; want no boost for oauthless", r.Keyword, r.Matched)
+	}
+}
+
+func TestSearch_TermWeight(t *testing.T) {
+	// "retry" is in every chunk and "backoff" in one: a chunk matching
+	// only the rare word outranks one matching only the common one, and
+	// each is weighted within the bounds.
+	idx := termsFixture(t, map[string]string{
+		"a.go": "retry loop",
+		"b.go": "retry with jitter",
+		"c.go": "retry until done",
+		"d.go": "exponential backoff",
+	})
+	res, err := idx.Search(context.Background(), "retry backoff", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base := filepath.Base(res[0].Meta.Path); base != "d.go" {
+		t.Fatalf("first result %s, want d.go (the rare word)", base)
+	}
+	rare, common := res[0].Keyword, res[1].Keyword
+	if math.Abs(float64(rare-maxTermWeight*KeywordBoost)) > 1e-6 || common <= minTermWeight*KeywordBoost || common >= KeywordBoost {
+		t.Errorf("boosts %v (backoff) and %v (retry), want %v and between %v and %v",
+			rare, common, maxTermWeight*KeywordBoost, minTermWeight*KeywordBoost, KeywordBoost)
+	}
+}
+
+func TestTerms_SavedAndAdded(t *testing.T) {
+	idx := termsFixture(t, map[string]string{"a.go": "retry loop", "b.go": "exponential backoff"})
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := load(idx.dir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ti := reloaded.terms.Load()
+	if ti == nil || ti.chunks != 2 || ti.df["backoff"] != 1 || ti.df["retry"] != 1 {
+		t.Fatalf("loaded terms %+v, want both chunks counted", ti)
+	}
+	if want := idx.terms.Load().postings["retry"]; len(want) != 1 || !slices.Equal(ti.postings["retry"], want) {
+		t.Errorf("loaded postings of retry %v, want %v", ti.postings["retry"], want)
+	}
+	reloaded.embedder = &mockEmbedder{}
+	reloaded.SetMaxFileKB(512)
+
+	// An appended chunk is counted in place; a stale terms.bin is ignored.
+	p := filepath.Join(filepath.Dir(idx.dir), "c.go")
+	if err := os.WriteFile(p, []byte("retry again"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloaded.AddFile(p); err != nil {
+		t.Fatal(err)
+	}
+	if ti := reloaded.terms.Load(); ti.chunks != 3 || ti.df["retry"] != 2 || len(ti.postings["retry"]) != 2 || ti.postings["retry"][1] != 2 {
+		t.Errorf("terms after AddFile %+v, want retry in 2 of 3 chunks", ti)
+	}
+	if _, err := readTerms(idx.dir, reloaded.chunks); err == nil {
+		t.Error("readTerms accepted a terms.bin for other chunks")
+	}
+}
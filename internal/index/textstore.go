@@ -0,0 +1,247 @@
+package index
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Chunk text is stored apart from the rest of the chunk metadata, in
+// text.bin, so an index opened to search (OpenLowMemory, OpenMeta) can
+// leave it on disk: each chunk then keeps only where its text is, and the
+// text is read back for the candidates a search scores and the chunks it
+// returns. On a large index, text is most of what meta.json used to hold.
+//
+// text.bin holds textMagic, a checksum of the IDs of the chunks it was
+// written for (see textsSum), their count, count+1 offsets into the file
+// bounding each chunk's text, then the texts.
+
+const textFile = "text.bin"
+
+// textFileVersion is the FormatVersion from which chunk text is stored in
+// text.bin rather than meta.json.
+const textFileVersion = 3
+
+var textMagic = [8]byte{'S', 'I', 'F', 'T', 'T', 'X', 'T', '1'}
+
+// textHeaderLen is the size of text.bin's magic, checksum and count.
+const textHeaderLen = 24
+
+// errTextMismatch is returned by readTexts for a text.bin written for
+// other chunks than meta.json holds, as a crash between saving the two
+// can leave it.
+var errTextMismatch = errors.New("text.bin does not match meta.json")
+
+// textsSum is the checksum text.bin records of the chunks it holds the
+// text of: their IDs, which are derived from that text, in order.
+func textsSum(chunks []ChunkMeta) uint64 {
+	h := fnv.New64a()
+	for _, c := range chunks {
+		io.WriteString(h, c.ID)
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+// readTexts attaches the text stored in dir's text.bin to chunks: into
+// Text when resident, else as each chunk's place in the file, which is
+// returned open to read them back from. The file is nil if resident, or
+// if text.bin does not exist.
+func readTexts(dir string, chunks []ChunkMeta, resident bool) (*os.File, error) {
+	f, err := os.Open(filepath.Join(dir, textFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := attachTexts(f, chunks, resident); err != nil || resident {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func attachTexts(f *os.File, chunks []ChunkMeta, resident bool) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(io.NewSectionReader(f, 0, info.Size()))
+	var header [textHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return &CorruptError{textFile, err}
+	}
+	if [8]byte(header[:8]) != textMagic {
+		return &CorruptError{textFile, errors.New("not a sift text file")}
+	}
+	sum, n := binary.LittleEndian.Uint64(header[8:]), binary.LittleEndian.Uint64(header[16:])
+	if n != uint64(len(chunks)) || sum != textsSum(chunks) {
+		return errTextMismatch
+	}
+	offsets := make([]int64, len(chunks)+1)
+	if err := binary.Read(r, binary.LittleEndian, offsets); err != nil {
+		return &CorruptError{textFile, err}
+	}
+	start := int64(textHeaderLen + 8*len(offsets))
+	for i := range chunks {
+		if offsets[i] < start || offsets[i+1] < offsets[i] {
+			return &CorruptError{textFile, fmt.Errorf("bad offset for chunk %d", i)}
+		}
+	}
+	if offsets[len(chunks)] != info.Size() {
+		return &CorruptError{textFile, fmt.Errorf("%d bytes, want %d", info.Size(), offsets[len(chunks)])}
+	}
+	for i := range chunks {
+		n := offsets[i+1] - offsets[i]
+		if !resident {
+			chunks[i].Text = ""
+			chunks[i].textAt, chunks[i].textLen = offsets[i], int32(n)
+			continue
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return &CorruptError{textFile, err}
+		}
+		chunks[i].Text = string(buf)
+	}
+	return nil
+}
+
+// loadTexts attaches the chunk text of a freshly loaded index (see
+// readTexts). stored is whether its manifest says text.bin holds it; if
+// not, meta.json did. A text.bin that is missing or does not match loses
+// the text: chunks read it back from their files (see FullText) until the
+// next index run re-embeds them.
+func (idx *Index) loadTexts(resident, stored bool) error {
+	f, err := readTexts(idx.dir, idx.chunks, resident)
+	switch {
+	case errors.Is(err, errTextMismatch):
+		idx.loseTexts("%s did not match %s", textFile, metaFile)
+	case err != nil:
+		return err
+	case f == nil && stored && len(idx.chunks) > 0:
+		if _, statErr := os.Stat(filepath.Join(idx.dir, textFile)); os.IsNotExist(statErr) {
+			idx.loseTexts("%s was missing", textFile)
+		}
+	}
+	idx.texts = f
+	return nil
+}
+
+// loseTexts empties every chunk's text, marking it Truncated so FullText
+// reads it from the file, and its version, so the next index run
+// re-embeds the file, and notes the repair.
+func (idx *Index) loseTexts(format string, args ...any) {
+	for i := range idx.chunks {
+		c := &idx.chunks[i]
+		c.Text, c.Truncated = "", true
+		c.setVersion(fileVersion{})
+	}
+	idx.noteRepair(format+"; chunk text is read from the files until they are re-indexed", args...)
+}
+
+// textOf returns c's text, reading it back from text.bin if it was left
+// there. Must be called with idx.mu held (read).
+func (idx *Index) textOf(c ChunkMeta) string {
+	if c.textLen == 0 {
+		return c.Text
+	}
+	buf := make([]byte, c.textLen)
+	if _, err := idx.texts.ReadAt(buf, c.textAt); err != nil {
+		idx.log.Warnf("%s: read chunk text: %v", idx.dir, err)
+		return ""
+	}
+	return string(buf)
+}
+
+// withText returns c with its text in Text, as every chunk handed out
+// has it. Must be called with idx.mu held (read).
+func (idx *Index) withText(c ChunkMeta) ChunkMeta {
+	c.Text = idx.textOf(c)
+	c.textAt, c.textLen = 0, 0
+	return c
+}
+
+// writeTextsUnderLock saves every chunk's text to path, a text.bin
+// (atomic write: tmp → sync → rename). An index that leaves text on disk
+// then reads it from the new file, dropping the text it held. Must be
+// called with idx.mu held for writing.
+func (idx *Index) writeTextsUnderLock(path string) error {
+	tmp := path + ".tmp"
+	offsets := make([]int64, len(idx.chunks)+1)
+	offsets[0] = int64(textHeaderLen + 8*len(offsets))
+	for i, c := range idx.chunks {
+		n := int64(c.textLen)
+		if n == 0 {
+			n = int64(len(c.Text))
+		}
+		offsets[i+1] = offsets[i] + n
+	}
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("write text tmp: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	var header [textHeaderLen]byte
+	copy(header[:], textMagic[:])
+	binary.LittleEndian.PutUint64(header[8:], textsSum(idx.chunks))
+	binary.LittleEndian.PutUint64(header[16:], uint64(len(idx.chunks)))
+	w.Write(header[:])
+	binary.Write(w, binary.LittleEndian, offsets)
+	for _, c := range idx.chunks {
+		if c.textLen == 0 {
+			w.WriteString(c.Text)
+			continue
+		}
+		if _, err := io.Copy(w, io.NewSectionReader(idx.texts, c.textAt, int64(c.textLen))); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return fmt.Errorf("copy chunk text: %w", err)
+		}
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write text: %w", err)
+	}
+
+	if idx.graphMode != lazyGraph {
+		return nil
+	}
+	nf, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	for i := range idx.chunks {
+		c := &idx.chunks[i]
+		c.Text = ""
+		c.textAt, c.textLen = offsets[i], int32(offsets[i+1]-offsets[i])
+	}
+	idx.closeTexts()
+	idx.texts = nf
+	return nil
+}
+
+// closeTexts closes text.bin if the index reads text from it.
+func (idx *Index) closeTexts() {
+	if idx.texts != nil {
+		idx.texts.Close()
+		idx.texts = nil
+	}
+}
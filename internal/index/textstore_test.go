@@ -0,0 +1,137 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIndex_TextLeftOnDisk(t *testing.T) {
+	root := t.TempDir()
+	siftDir := filepath.Join(root, ".sift")
+	idx := NewTestIndex(siftDir, wordEmbedder{})
+	bodies := map[string]string{
+		"vpn.md":    "wireguard tunnel setup",
+		"garden.md": "garden kettle notes",
+	}
+	for name, body := range bodies {
+		p := filepath.Join(root, name)
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	lazy, err := load(siftDir, "", lazyGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lazy.closeTexts() })
+	lazy.embedder, lazy.maxFileSizeBytes = wordEmbedder{}, 512*1024
+	for _, c := range lazy.chunks {
+		if c.Text != "" || c.textLen == 0 {
+			t.Fatalf("%s: holds %q (%d bytes on disk), want its text left in %s", c.Path, c.Text, c.textLen, textFile)
+		}
+	}
+
+	// Everything handed out carries its text.
+	res, err := lazy.Search(context.Background(), "wireguard tunnel", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Meta.Text != bodies["vpn.md"] || !slices.Equal(res[0].Matched, []string{"wireguard", "tunnel"}) {
+		t.Fatalf("Search = %+v, want vpn.md with its text, keyword-boosted", res)
+	}
+	if c, ok := lazy.GetChunk(res[0].Meta.ID); !ok || c.Text != bodies["vpn.md"] {
+		t.Errorf("GetChunk = %q, %v; want vpn.md's text", c.Text, ok)
+	}
+	if cs := lazy.FileChunks(filepath.Join(root, "garden.md")); len(cs) != 1 || cs[0].Text != bodies["garden.md"] {
+		t.Errorf("FileChunks = %+v, want garden.md's text", cs)
+	}
+
+	// A file indexed by the lazy index is saved alongside the rest, which
+	// is then read from the new text.bin.
+	p := filepath.Join(root, "tunnel.md")
+	if err := os.WriteFile(p, []byte("tunnel tunnel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lazy.AddFile(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := lazy.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if cs := lazy.FileChunks(p); len(cs) != 1 || cs[0].Text != "tunnel tunnel" || lazy.chunks[len(lazy.chunks)-1].Text != "" {
+		t.Errorf("after Flush: FileChunks = %+v, want the text read back from %s", cs, textFile)
+	}
+	full, err := load(siftDir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, c := range full.chunks {
+		texts = append(texts, c.Text)
+	}
+	slices.Sort(texts)
+	if want := []string{"garden kettle notes", "tunnel tunnel", "wireguard tunnel setup"}; !slices.Equal(texts, want) {
+		t.Errorf("reloaded texts = %q, want %q", texts, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(siftDir, metaFile)); strings.Contains(string(data), "wireguard") {
+		t.Errorf("%s holds chunk text: %s", metaFile, data)
+	}
+}
+
+func TestLoad_TextMismatch(t *testing.T) {
+	root := t.TempDir()
+	siftDir := filepath.Join(root, ".sift")
+	idx := NewTestIndex(siftDir, wordEmbedder{})
+	p := filepath.Join(root, "vpn.md")
+	for _, body := range []string{"wireguard tunnel", "wireguard tunnel, edited"} {
+		if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.ReindexFile(t.Context(), p); err != nil {
+			t.Fatal(err)
+		}
+		if body == "wireguard tunnel" {
+			if err := idx.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// A crash after saving text.bin, before meta.json: the old text.bin
+	// is left beside the new metadata.
+	stale, err := os.ReadFile(filepath.Join(siftDir, textFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(siftDir, textFile), stale, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := load(siftDir, "", fullGraph)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := again.Stats(); !strings.Contains(s.Repaired, "text.bin did not match") {
+		t.Errorf("Repaired = %q, want the mismatch recorded", s.Repaired)
+	}
+	c := again.chunks[0]
+	if c.Text != "" || !c.Truncated || !c.Mtime.IsZero() {
+		t.Fatalf("chunk after repair = %+v, want no text, Truncated, zero mtime", c)
+	}
+	if text, err := c.FullText(); err != nil || text != "wireguard tunnel, edited" {
+		t.Errorf("FullText = %q, %v; want the file read back", text, err)
+	}
+}
@@ -0,0 +1,92 @@
+package index
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// PhaseThrottle times the pauses a Throttler made between files.
+const PhaseThrottle = "throttle"
+
+// A Throttler paces indexing, as *throttle.Throttle does in low-priority
+// mode: IndexDir calls Wait between two files, and it returns how long it
+// paused, at most until ctx is done.
+type Throttler interface {
+	Wait(ctx context.Context) time.Duration
+}
+
+// SetThrottle makes IndexDir call t between the files it embeds; nil, the
+// default, never pauses. t may change its pace while idx indexes.
+func (idx *Index) SetThrottle(t Throttler) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.throttle = t
+}
+
+// pause lets idx's Throttler pause after a file, counting the time
+// towards PhaseThrottle.
+func (idx *Index) pause(ctx context.Context) {
+	idx.mu.RLock()
+	t := idx.throttle
+	idx.mu.RUnlock()
+	if t == nil {
+		return
+	}
+	if d := t.Wait(ctx); d > 0 {
+		idx.addPhase(PhaseThrottle, d)
+	}
+}
+
+// threadSetter and backgroundSetter are implemented by embedders whose
+// resource limits can change once loaded, as the ONNX one's can.
+type (
+	threadSetter interface {
+		SetThreads(n int) error
+	}
+	backgroundSetter interface {
+		SetBackgroundSessions(n int)
+	}
+)
+
+// SetEmbedLimits changes the ONNX intra-op threads of each inference
+// session (0 picks from the CPU count) and how many sessions background
+// indexing holds at once (0 means all), as Options' Threads and
+// BackgroundSessions, for a configuration reload. A model not loaded yet
+// loads with them; a loaded one re-creates its sessions when the thread
+// count changes. Embedders without such limits ignore them.
+func (idx *Index) SetEmbedLimits(threads, background int) error {
+	if p := idx.stagingFor; p != nil {
+		return p.SetEmbedLimits(threads, background)
+	}
+	var e Embedder
+	if l := idx.lazy; l != nil {
+		l.mu.Lock()
+		l.numThreads, l.background = threads, background
+		e = l.loaded
+		l.mu.Unlock()
+		if e == nil {
+			return nil // applied when the model is loaded
+		}
+	} else {
+		idx.mu.RLock()
+		e = idx.embedder
+		idx.mu.RUnlock()
+	}
+	return applyEmbedLimits(e, threads, background)
+}
+
+// applyEmbedLimits applies SetEmbedLimits' limits to e, where it has them.
+// A background of 0 lifts the cap: every session.
+func applyEmbedLimits(e Embedder, threads, background int) error {
+	if bs, ok := e.(backgroundSetter); ok {
+		if background <= 0 {
+			background = math.MaxInt
+		}
+		bs.SetBackgroundSessions(background)
+	}
+	if ts, ok := e.(threadSetter); ok {
+		return ts.SetThreads(threads)
+	}
+	return nil
+}
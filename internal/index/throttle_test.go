@@ -0,0 +1,90 @@
+package index
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingThrottler logs its calls to events and pauses for pause.
+type recordingThrottler struct {
+	events *[]string
+	pause  time.Duration
+}
+
+func (r recordingThrottler) Wait(context.Context) time.Duration {
+	*r.events = append(*r.events, "pause")
+	return r.pause
+}
+
+func TestIndex_Throttle(t *testing.T) {
+	root := failureTree(t, "one", "two", "three")
+	idx := NewTestIndex(t.TempDir(), &mockEmbedder{})
+	idx.SetLogger(nil)
+	var events []string
+	idx.SetThrottle(recordingThrottler{&events, time.Millisecond})
+	progress := func(_, _ int, path string, skipped bool) {
+		events = append(events, "file")
+	}
+
+	idx.BeginRun()
+	if err := idx.IndexDirWithProgress(context.Background(), root, progress); err != nil {
+		t.Fatal(err)
+	}
+	// Between files, not after the last.
+	want := []string{"file", "pause", "file", "pause", "file"}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %s, want %s", strings.Join(events, " "), strings.Join(want, " "))
+	}
+	if got := idx.RunStats().Phases[PhaseThrottle]; got != 2*time.Millisecond {
+		t.Errorf("throttle phase = %v, want the 2 pauses", got)
+	}
+
+	// Files skipped as unchanged cost nothing to pause for.
+	events = nil
+	if err := idx.IndexDirWithProgress(context.Background(), root, progress); err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(events, "pause") {
+		t.Errorf("paused between skipped files: %s", strings.Join(events, " "))
+	}
+}
+
+// limitedEmbedder records the resource limits set on it.
+type limitedEmbedder struct {
+	mockEmbedder
+	threads, background int
+}
+
+func (e *limitedEmbedder) SetThreads(n int) error      { e.threads = n; return nil }
+func (e *limitedEmbedder) SetBackgroundSessions(n int) { e.background = n }
+
+func TestIndex_SetEmbedLimits(t *testing.T) {
+	e := &limitedEmbedder{}
+	idx := NewTestIndex(t.TempDir(), e)
+	if err := idx.SetEmbedLimits(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if e.threads != 1 || e.background != 1 {
+		t.Errorf("limits = %d threads, %d background; want 1, 1", e.threads, e.background)
+	}
+	// Back to normal: no cap on background sessions.
+	if err := idx.SetEmbedLimits(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if e.threads != 0 || e.background < 1<<30 {
+		t.Errorf("limits = %d threads, %d background; want 0, uncapped", e.threads, e.background)
+	}
+
+	// Before the model is loaded, they are kept for when it is.
+	lazy := NewTestIndex(t.TempDir(), nil)
+	lazy.lazy = &lazyEmbedder{numThreads: 4}
+	if err := lazy.SetEmbedLimits(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if lazy.lazy.numThreads != 1 || lazy.lazy.background != 1 {
+		t.Errorf("lazy limits = %+v", lazy.lazy)
+	}
+}
@@ -0,0 +1,169 @@
+package index
+
+import (
+	"cmp"
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// topSample is how many chunks of a directory TopChunks looks at: all of
+// them up to that, and an evenly spaced sample past it, so a digest of a
+// large index reads a bounded number of vectors per directory.
+const topSample = 2048
+
+// DirDigest is a top-level directory and the chunks that best represent
+// it, as TopChunks returns it.
+type DirDigest struct {
+	// Dir is a directory directly inside the index root, or the root
+	// itself for the files indexed there. A file outside the root is
+	// counted in its own directory.
+	Dir string
+	// Files and Chunks count what is indexed anywhere under Dir.
+	Files, Chunks int
+	// Sampled is set when Dir held more than topSample chunks, so Top is
+	// the most central of a sample of them, compared with that sample.
+	Sampled bool
+	// Top holds Dir's most central chunks, most central first and at
+	// most one per file.
+	Top []CentralChunk
+}
+
+// CentralChunk is a chunk and how central it is to its directory.
+type CentralChunk struct {
+	Meta ChunkMeta
+	// Centrality is the chunk's mean cosine similarity to the other
+	// chunks of its directory (or of the sample); 0 for a directory of
+	// one chunk.
+	Centrality float32
+}
+
+// TopChunks picks, for each top-level directory of the index, the perDir
+// chunks most similar on average to the rest of the directory: what the
+// directory is about, without a query. Stored vectors are compared, so
+// no model is needed. A chunk's mean similarity to the others is its
+// similarity to their sum, which makes one pass over the directory's
+// vectors enough; past topSample chunks only a sample is read (see
+// DirDigest.Sampled). Directories are returned in path order.
+func (idx *Index) TopChunks(ctx context.Context, perDir int) ([]DirDigest, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	byDir := make(map[string][]uint32)
+	var dirs []string
+	for id, c := range idx.chunks {
+		if c.removed {
+			continue
+		}
+		dir := idx.topDir(c.Path)
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], uint32(id))
+	}
+	slices.Sort(dirs)
+
+	out := make([]DirDigest, 0, len(dirs))
+	for _, dir := range dirs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		ids := byDir[dir]
+		files := make(map[string]bool)
+		for _, id := range ids {
+			files[idx.pathKey(idx.chunks[id].Path)] = true
+		}
+		out = append(out, DirDigest{
+			Dir:     dir,
+			Files:   len(files),
+			Chunks:  len(ids),
+			Sampled: len(ids) > topSample,
+			Top:     idx.centralUnderLock(sampleIDs(ids, topSample), perDir),
+		})
+	}
+	return out, nil
+}
+
+// topDir returns the directory path is digested under: the first
+// directory below the index root on its way, the root for a file directly
+// in it, or the file's own directory outside it.
+func (idx *Index) topDir(path string) string {
+	if idx.root != "" {
+		if rel, err := filepath.Rel(idx.root, path); err == nil && filepath.IsLocal(rel) {
+			first, _, nested := strings.Cut(rel, string(filepath.Separator))
+			if !nested {
+				return idx.root
+			}
+			return filepath.Join(idx.root, first)
+		}
+	}
+	return filepath.Dir(path)
+}
+
+// sampleIDs returns ids, or n of them evenly spaced if there are more.
+func sampleIDs(ids []uint32, n int) []uint32 {
+	if len(ids) <= n {
+		return ids
+	}
+	out := make([]uint32, n)
+	for i := range out {
+		out[i] = ids[i*len(ids)/n]
+	}
+	return out
+}
+
+// centralUnderLock ranks the chunks ids by their mean similarity to each
+// other and returns the best k, one per file. Must be called with idx.mu
+// held (read).
+func (idx *Index) centralUnderLock(ids []uint32, k int) []CentralChunk {
+	vecs := make([][]float32, 0, len(ids))
+	kept := make([]uint32, 0, len(ids))
+	var sum []float32
+	for _, id := range ids {
+		vec := idx.graph.GetNodeVec(id)
+		if vec == nil {
+			continue
+		}
+		if sum == nil {
+			sum = make([]float32, len(vec))
+		}
+		for i, x := range vec {
+			sum[i] += x
+		}
+		vecs = append(vecs, vec)
+		kept = append(kept, id)
+	}
+
+	ranked := make([]CentralChunk, len(vecs))
+	for i, vec := range vecs {
+		var self, all float32
+		for j, x := range vec {
+			self += x * x
+			all += x * sum[j]
+		}
+		ranked[i] = CentralChunk{Meta: idx.chunks[kept[i]]}
+		if len(vecs) > 1 {
+			ranked[i].Centrality = (all - self) / float32(len(vecs)-1)
+		}
+	}
+	slices.SortStableFunc(ranked, func(a, b CentralChunk) int {
+		return cmp.Compare(b.Centrality, a.Centrality)
+	})
+
+	var top []CentralChunk
+	seen := make(map[string]bool)
+	for _, c := range ranked {
+		if len(top) == k {
+			break
+		}
+		key := idx.pathKey(c.Meta.Path)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		c.Meta = idx.withText(c.Meta)
+		top = append(top, c)
+	}
+	return top
+}
@@ -0,0 +1,78 @@
+package index
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestTopChunks(t *testing.T) {
+	idx, dir := dirsFixture(t, map[string]string{
+		// Every other file of vpn/ is about wireguard: peer.go, about
+		// nothing else, is the most representative; tea.md the least.
+		"vpn/peer.go":       "wireguard",
+		"vpn/route.go":      "wireguard tunnel",
+		"vpn/wall/route.go": "wireguard garden garden", // nested: still vpn/
+		"vpn/tea.md":        "kettle",
+		"home/garden.md":    "garden",
+		"README.md":         "kettle",
+	})
+	digests, err := idx.TopChunks(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel := func(p string) string {
+		r, _ := filepath.Rel(dir, p)
+		return filepath.ToSlash(r)
+	}
+	type digest struct {
+		dir          string
+		files, total int
+		top          []string
+	}
+	var got []digest
+	for _, d := range digests {
+		g := digest{dir: rel(d.Dir), files: d.Files, total: d.Chunks}
+		for _, c := range d.Top {
+			g.top = append(g.top, rel(c.Meta.Path))
+		}
+		got = append(got, g)
+		if d.Sampled {
+			t.Errorf("%s: sampled, with %d chunks", g.dir, d.Chunks)
+		}
+	}
+	want := []digest{
+		{".", 1, 1, []string{"README.md"}},
+		{"home", 1, 1, []string{"home/garden.md"}},
+		{"vpn", 4, 4, []string{"vpn/peer.go", "vpn/route.go"}},
+	}
+	if !slices.EqualFunc(got, want, func(a, b digest) bool {
+		return a.dir == b.dir && a.files == b.files && a.total == b.total && slices.Equal(a.top, b.top)
+	}) {
+		t.Errorf("TopChunks = %+v\nwant %+v", got, want)
+	}
+
+	// Centrality is the mean similarity to the directory's other chunks:
+	// for peer.go, (0.707 + 0.447 + 0) / 3.
+	vpn := digests[2].Top
+	if c := vpn[0].Centrality; c < 0.384 || c > 0.386 {
+		t.Errorf("peer.go centrality %.3f, want 0.385", c)
+	}
+	if vpn[0].Meta.Text != "wireguard" {
+		t.Errorf("top chunk text %q, want the stored text", vpn[0].Meta.Text)
+	}
+	if digests[0].Top[0].Centrality != 0 {
+		t.Errorf("a lone chunk has centrality %.3f, want 0", digests[0].Top[0].Centrality)
+	}
+}
+
+func TestSampleIDs(t *testing.T) {
+	ids := []uint32{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if got := sampleIDs(ids, 20); !slices.Equal(got, ids) {
+		t.Errorf("sampleIDs(10 of 20) = %v, want all", got)
+	}
+	if got := sampleIDs(ids, 4); !slices.Equal(got, []uint32{0, 2, 5, 7}) {
+		t.Errorf("sampleIDs(4 of 10) = %v, want evenly spaced", got)
+	}
+}
@@ -0,0 +1,161 @@
+package index
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// An index opened with LowMemory reads vectors from hnsw.bin, and chunk
+// text from text.bin, as searches reach them: after a reboot the first
+// search faults those files in page by page, in the graph's random order,
+// and is slow however fast the model is. Warm-up (Options.Preload) reads
+// them through once, sequentially, in the background, so the page cache
+// is hot by the time the first query is typed. Open does not wait for it,
+// and Close stops it.
+
+// warmBlock is how much warm-up reads at a time, checking between reads
+// whether Close has stopped it.
+const warmBlock = 1 << 20
+
+// openWarmFile opens a file for warm-up to read; tests replace it to
+// pace or count the reads.
+var openWarmFile = func(path string) (io.ReadCloser, error) { return os.Open(path) }
+
+// Warmup reports on an index's warm-up; see Options.Preload.
+type Warmup struct {
+	// Started is set when Open started a warm-up, and Done once it has
+	// read every file (or given up on one it could not read).
+	Started, Done bool
+	// Bytes counts what it has read, and Elapsed how long it took, once
+	// Done.
+	Bytes   int64
+	Elapsed time.Duration
+}
+
+// FirstSearch is how the first search after Open went.
+type FirstSearch struct {
+	Elapsed time.Duration
+	// Warm is set when a warm-up had finished before the search began;
+	// see Options.Preload.
+	Warm bool
+}
+
+// warmup is the state of a running or finished warm-up.
+type warmup struct {
+	stop chan struct{}
+	done chan struct{}
+
+	mu      sync.Mutex
+	status  Warmup
+	started time.Time
+}
+
+// warmFiles returns the index files read on demand, which warm-up reads
+// ahead: hnsw.bin when its vectors were left on disk, and text.bin when
+// chunk text was.
+func (idx *Index) warmFiles() []string {
+	var files []string
+	if idx.graphMode == lazyGraph {
+		files = append(files, filepath.Join(idx.dir, hnswFile))
+	}
+	if idx.texts != nil {
+		files = append(files, filepath.Join(idx.dir, textFile))
+	}
+	return files
+}
+
+// startWarmup reads the files idx reads on demand on a new goroutine; see
+// Warmup.
+func (idx *Index) startWarmup() {
+	w := &warmup{stop: make(chan struct{}), done: make(chan struct{}), started: time.Now()}
+	w.status.Started = true
+	idx.warm = w
+	go w.run(idx.warmFiles(), idx.log.Debugf)
+}
+
+func (w *warmup) run(files []string, logf func(string, ...any)) {
+	defer close(w.done)
+	buf := make([]byte, warmBlock)
+	for _, path := range files {
+		f, err := openWarmFile(path)
+		if err != nil {
+			continue
+		}
+		for {
+			select {
+			case <-w.stop:
+				f.Close()
+				return
+			default:
+			}
+			n, err := f.Read(buf)
+			w.mu.Lock()
+			w.status.Bytes += int64(n)
+			w.mu.Unlock()
+			if err != nil {
+				break
+			}
+		}
+		f.Close()
+	}
+	w.mu.Lock()
+	w.status.Done = true
+	w.status.Elapsed = time.Since(w.started)
+	bytes, elapsed := w.status.Bytes, w.status.Elapsed
+	w.mu.Unlock()
+	logf("warm-up read %d KB in %v", bytes/1024, elapsed.Round(time.Millisecond))
+}
+
+// Warmup reports on the warm-up Open started, if it did.
+func (idx *Index) Warmup() Warmup {
+	w := idx.warm
+	if w == nil {
+		return Warmup{}
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// stopWarmup stops a running warm-up and waits for it to return.
+func (idx *Index) stopWarmup() {
+	if w := idx.warm; w != nil {
+		select {
+		case <-w.stop:
+		default:
+			close(w.stop)
+		}
+		<-w.done
+	}
+}
+
+// FirstSearch reports how the first search (Search or SearchFiltered)
+// after Open went, once there has been one.
+func (idx *Index) FirstSearch() (FirstSearch, bool) {
+	idx.firstMu.Lock()
+	defer idx.firstMu.Unlock()
+	return idx.first, idx.searched
+}
+
+// noteSearch records the search begun at start as the first search, if
+// it is, and logs how long it took.
+func (idx *Index) noteSearch(start time.Time, warm bool) {
+	idx.firstMu.Lock()
+	defer idx.firstMu.Unlock()
+	if idx.searched {
+		return
+	}
+	idx.searched = true
+	idx.first = FirstSearch{Elapsed: time.Since(start), Warm: warm}
+	state := "no warm-up"
+	switch w := idx.Warmup(); {
+	case warm:
+		state = "index warm"
+	case w.Started:
+		state = "warm-up still running"
+	}
+	idx.log.Debugf("first search took %v (%s)", idx.first.Elapsed.Round(time.Millisecond), state)
+}
@@ -0,0 +1,173 @@
+package index
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedFile is a file whose reads wait for gate to be closed.
+type gatedFile struct {
+	io.ReadCloser
+	gate <-chan struct{}
+}
+
+func (f gatedFile) Read(p []byte) (int, error) {
+	<-f.gate
+	return f.ReadCloser.Read(p)
+}
+
+// gateWarmFiles makes warm-up reads wait for the returned gate to be
+// closed, and records the files it opens.
+func gateWarmFiles(t *testing.T) (gate chan struct{}, opened func() []string) {
+	t.Helper()
+	gate = make(chan struct{})
+	var mu sync.Mutex
+	var paths []string
+	old := openWarmFile
+	t.Cleanup(func() { openWarmFile = old })
+	openWarmFile = func(path string) (io.ReadCloser, error) {
+		mu.Lock()
+		paths = append(paths, filepath.Base(path))
+		mu.Unlock()
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		return gatedFile{f, gate}, nil
+	}
+	return gate, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(paths)
+	}
+}
+
+// warmupFixture saves an index of a few files and returns its directory.
+func warmupFixture(t *testing.T) string {
+	t.Helper()
+	idx, dir := dirsFixture(t, map[string]string{
+		"vpn/peer.go": "wireguard tunnel",
+		"home/tea.md": "kettle garden",
+	})
+	if err := idx.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, ".sift")
+}
+
+func waitWarm(t *testing.T, idx *Index) Warmup {
+	t.Helper()
+	select {
+	case <-idx.warm.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("warm-up did not finish")
+	}
+	return idx.Warmup()
+}
+
+func TestWarmup(t *testing.T) {
+	siftDir := warmupFixture(t)
+	gate, opened := gateWarmFiles(t)
+	opts := Options{Dir: siftDir, Embedder: wordEmbedder{}, LowMemory: true, Preload: true}
+
+	// Open returns while the warm-up is held up, and a search meanwhile
+	// is not warm.
+	idx, err := OpenWith(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := idx.Warmup(); !w.Started || w.Done {
+		t.Errorf("after Open: %+v, want started and not done", w)
+	}
+	if _, err := idx.Search(context.Background(), "wireguard", 3); err != nil {
+		t.Fatal(err)
+	}
+	if first, ok := idx.FirstSearch(); !ok || first.Warm {
+		t.Errorf("first search during warm-up: %+v, %t; want recorded, not warm", first, ok)
+	}
+	close(gate)
+	w := waitWarm(t, idx)
+	var size int64
+	for _, name := range []string{hnswFile, textFile} {
+		info, err := os.Stat(filepath.Join(siftDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += info.Size()
+	}
+	if !w.Done || w.Bytes != size {
+		t.Errorf("warm-up %+v, want done reading %d bytes", w, size)
+	}
+	if got := opened(); !slices.Equal(got, []string{hnswFile, textFile}) {
+		t.Errorf("warm-up read %v, want %s and %s", got, hnswFile, textFile)
+	}
+	// Only the first search is recorded.
+	if _, err := idx.Search(context.Background(), "kettle", 3); err != nil {
+		t.Fatal(err)
+	}
+	if first, _ := idx.FirstSearch(); first.Warm {
+		t.Errorf("a later search replaced the first: %+v", first)
+	}
+	idx.Close()
+
+	// A search after the warm-up is warm.
+	idx, err = OpenWith(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitWarm(t, idx)
+	if _, err := idx.Search(context.Background(), "wireguard", 3); err != nil {
+		t.Fatal(err)
+	}
+	if first, ok := idx.FirstSearch(); !ok || !first.Warm {
+		t.Errorf("first search after warm-up: %+v, %t; want warm", first, ok)
+	}
+	idx.Close()
+
+	// Without Preload nothing is read.
+	opts.Preload = false
+	n := len(opened())
+	idx, err = OpenWith(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	if w := idx.Warmup(); w.Started || len(opened()) != n {
+		t.Errorf("without Preload: %+v, %d files opened", w, len(opened())-n)
+	}
+}
+
+func TestWarmup_Close(t *testing.T) {
+	siftDir := warmupFixture(t)
+	gate, _ := gateWarmFiles(t)
+	idx, err := OpenWith(Options{Dir: siftDir, Embedder: wordEmbedder{}, LowMemory: true, Preload: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := make(chan error)
+	go func() { closed <- idx.Close() }()
+	// Close waits for the read in progress, then stops the warm-up.
+	select {
+	case <-idx.warm.stop:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the warm-up")
+	}
+	close(gate)
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the warm-up")
+	}
+	if w := idx.Warmup(); w.Done {
+		t.Errorf("warm-up %+v finished after Close", w)
+	}
+}
@@ -0,0 +1,186 @@
+// Package lang guesses the natural language of a chunk of text, cheaply
+// enough to run on every chunk an index run embeds. It tells English
+// apart from the languages an English-only embedding model handles
+// poorly; it is not a general-purpose language identifier.
+//
+// Text in a script other than Latin is assigned the language that script
+// is mostly written in (Cyrillic is reported as Russian, Han without kana
+// as Chinese). Latin-script text is scored against short profiles of each
+// language's most frequent character trigrams, and is English unless
+// another language clearly wins, so identifiers, code and short snippets
+// stay English.
+package lang
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// English is what Detect returns for English text, and for text with too
+// few letters, or too mixed, to tell.
+const English = "en"
+
+// minLetters is how many letters text needs for Detect to look past
+// English.
+const minLetters = 24
+
+// minScriptShare is the share of a text's letters a non-Latin script
+// needs to decide its language: a Japanese comment in a Go file counts,
+// an "ü" in a name does not.
+const minScriptShare = 0.2
+
+// scripts are the non-Latin scripts Detect recognises, with the language
+// each is reported as.
+var scripts = []struct {
+	table *unicode.RangeTable
+	lang  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+	{unicode.Hangul, "ko"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+}
+
+// Detect returns the ISO 639-1 code of the language text is most likely
+// written in, or English.
+func Detect(text string) string {
+	var latin, total int
+	counts := make([]int, len(scripts))
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		total++
+		if r < 0x250 { // Basic Latin through Latin Extended-B
+			latin++
+			continue
+		}
+		for i, s := range scripts {
+			if unicode.Is(s.table, r) {
+				counts[i]++
+				break
+			}
+		}
+	}
+	if total < minLetters {
+		return English
+	}
+
+	// Japanese mixes kana with Han; any notable share of kana makes Han
+	// text Japanese rather than Chinese.
+	byLang := make(map[string]int)
+	for i, s := range scripts {
+		byLang[s.lang] += counts[i]
+	}
+	if byLang["ja"] > 0 && float64(byLang["ja"]) >= 0.1*float64(byLang["ja"]+byLang["zh"]) {
+		byLang["ja"] += byLang["zh"]
+		delete(byLang, "zh")
+	}
+	best, bestN := "", 0
+	for _, s := range scripts {
+		if n := byLang[s.lang]; n > bestN {
+			best, bestN = s.lang, n
+		}
+	}
+	if float64(bestN) >= minScriptShare*float64(total) {
+		return best
+	}
+	if float64(latin) < minScriptShare*float64(total) {
+		return English
+	}
+	return detectLatin(text)
+}
+
+// minTrigrams is how many trigrams Latin-script text needs for
+// detectLatin to look past English.
+const minTrigrams = 30
+
+// detectLatin scores text's trigrams against each profile: the share of
+// them among the language's most frequent, weighted towards the top of
+// the list. Another language wins over English only with a clear margin
+// and a share that looks like prose rather than code.
+func detectLatin(text string) string {
+	trigrams := 0
+	scores := make(map[string]float64, len(profiles))
+	eachTrigram(text, func(t string) {
+		trigrams++
+		for l, p := range profiles {
+			scores[l] += p[t]
+		}
+	})
+	if trigrams < minTrigrams {
+		return English
+	}
+	best, bestScore := English, scores[English]
+	for _, l := range slices.Sorted(maps.Keys(scores)) {
+		if scores[l] > bestScore {
+			best, bestScore = l, scores[l]
+		}
+	}
+	if best == English || bestScore < 1.5*scores[English] || bestScore/float64(trigrams) < 0.15 {
+		return English
+	}
+	return best
+}
+
+// eachTrigram calls fn with every trigram of text's lower-cased words,
+// each padded with a space on either side (" th", "the", "he ").
+func eachTrigram(text string, fn func(string)) {
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		rs := []rune(" " + w + " ")
+		for i := 0; i+3 <= len(rs); i++ {
+			fn(string(rs[i : i+3]))
+		}
+	}
+}
+
+// profiles maps each Latin-script language to the weight of its most
+// frequent trigrams: 1 for the first on its list, falling towards 0.5 for
+// the last.
+var profiles = map[string]map[string]float64{
+	English: profile(" th", "the", "he ", " an", "nd ", "and", " of", "of ", " to", "ing", "ng ", " in",
+		"to ", "ed ", "is ", "in ", "er ", "ion", " a ", "on ", "at ", "re ", "tio", "es ", " is",
+		"hat", "tha", "ent", " wh", " be", "for", " fo", "or ", " it", "it ", "ly ", "al ", "as ",
+		" co", "his", "ith", "wit", " wi", "are", "you", " yo", "ou ", "thi", "ter", "nt "),
+	"de": profile("en ", "er ", " de", "der", "ie ", "ich", "sch", "die", " di", "ein", "che", "nd ",
+		"und", " un", "ch ", " ei", "den", "in ", "cht", "ine", "te ", "gen", "ung", " ge", "ten",
+		"es ", "das", " da", "ht ", "ist", " is", "st ", "auf", " au", " zu", "zu ", "nde", "ter",
+		"ber", " ve", "ver", "ne ", "eit", "mit", " mi", "sie", " si", "nic", "ach", "ür "),
+	"fr": profile("es ", " de", "de ", "le ", " le", "ent", "nt ", " la", "la ", "les", " et", "et ",
+		"re ", "ion", " pa", "on ", "des", "tio", " qu", "que", "ue ", "ne ", " co", " un", "une",
+		"men", " po", "our", "ait", " en", "en ", "ons", "eur", "ais", "est", " es", "par", " da",
+		"dan", "ans", "pou", "ur ", "ée ", "té ", " ce", "ui ", "qui"),
+	"es": profile("de ", " de", "os ", "la ", " la", "el ", "es ", " el", "ión", "ón ", " en", "en ",
+		"ent", " co", "ado", "ue ", "que", " qu", "as ", "do ", " lo", "nte", "los", " pa", "con",
+		"ar ", "ra ", "cia", "aci", "ien", " se", "par", "sta", " es", "est", "las", " po", "por",
+		"ara", "una", " un", " y ", "er ", "ida", "dad", "ció", "ero", "no ", "ndo"),
+	"pt": profile("de ", " de", "os ", "ão ", "do ", "da ", " qu", "que", "ue ", " co", "ent", "ção",
+		" e ", "ra ", " da", "com", "as ", "nte", " do", "men", "ado", " pa", "par", "ões", " se",
+		"em ", " em", "não", " nã", "est", "ica", "ara", "uma", " um", "um ", "ar ", "es ", "açã",
+		" pr", "ida", "dad", "ade", "ém ", "is ", "ess", "mos", "ort", "iss", "sso"),
+	"it": profile("di ", " di", "la ", " la", "che", " ch", "re ", "to ", "ell", "ion", "lla", "del",
+		" de", "zio", "ne ", "ent", "one", " co", "ato", " il", "il ", "per", " pe", "are", "nte",
+		"no ", "con", "ta ", "le ", " in", "ti ", "gli", " gl", "ra ", "ere", "tto", "non", " no",
+		"ala", "lle", " un", "una", "sse", "ess", "ame", "ia ", "eri"),
+	"nl": profile("en ", "de ", " de", "an ", "van", " va", "het", " he", "et ", "een", " ee", "er ",
+		"ing", "ijk", "aar", "cht", "ver", "sch", " ve", " in", "in ", "ien", "gen", "te ",
+		"den", "oor", " zi", "nde", "ij ", "ter", " ge", "zij", "eer", "ie ", "nd ", "ere", " op",
+		"op ", " me", "met", " ni", "nie", "iet", "ijn", "ede", "lij", "wor"),
+}
+
+// profile weights trigrams, most frequent first; see profiles.
+func profile(trigrams ...string) map[string]float64 {
+	p := make(map[string]float64, len(trigrams))
+	for i, t := range trigrams {
+		p[t] = 1 - 0.5*float64(i)/float64(len(trigrams))
+	}
+	return p
+}
@@ -0,0 +1,38 @@
+package lang
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		name, text, want string
+	}{
+		{"english prose", "The index is stored next to the project, so that every run only has to embed the files that changed since the last one.", "en"},
+		{"go code", `// Flush writes the index to disk.
+func (idx *Index) Flush() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.writeMetaUnderLock(filepath.Join(idx.dir, metaFile))
+}`, "en"},
+		{"short", "Hallo Welt", "en"},
+		{"german", "Die Konfiguration wird beim Start aus der Datei gelesen und kann über Umgebungsvariablen überschrieben werden, die für jeden Dienst einzeln gesetzt sind.", "de"},
+		{"french", "Le fichier de configuration est lu au démarrage, et chaque valeur peut être remplacée par une variable d'environnement pour le service concerné.", "fr"},
+		{"spanish", "El archivo de configuración se lee al iniciar, y cada valor puede ser reemplazado por una variable de entorno para los servicios de la aplicación.", "es"},
+		{"portuguese", "O arquivo de configuração é lido na inicialização, e cada valor pode ser substituído por uma variável de ambiente para o serviço em questão, que não precisa ser reiniciado.", "pt"},
+		{"italian", "Il file di configurazione viene letto all'avvio, e ogni valore può essere sostituito da una variabile d'ambiente per il servizio che non deve essere riavviato.", "it"},
+		{"dutch", "Het configuratiebestand wordt bij het opstarten gelezen, en elke waarde kan worden vervangen door een omgevingsvariabele voor de dienst die niet opnieuw gestart hoeft te worden.", "nl"},
+		{"japanese", "設定ファイルは起動時に読み込まれ、各値は環境変数で上書きできます。サービスを再起動する必要はありません。", "ja"},
+		{"chinese", "配置文件在启动时读取，每个值都可以通过环境变量覆盖，服务不需要重新启动。这样部署更加简单。", "zh"},
+		{"russian", "Файл конфигурации читается при запуске, и каждое значение можно переопределить переменной окружения.", "ru"},
+		{"korean", "설정 파일은 시작할 때 읽히며 각 값은 환경 변수로 덮어쓸 수 있습니다. 서비스를 다시 시작할 필요가 없습니다.", "ko"},
+		{"go with japanese comments", `// 設定ファイルを読み込み、環境変数で上書きする。
+func Load(path string) (*Config, error) {
+	// ファイルがなければデフォルトを返す。
+	return readFile(path)
+}`, "ja"},
+		{"english with a german name", "Thanks to Jürgen Müller for reviewing the patch and for the benchmark numbers in the pull request.", "en"},
+	} {
+		if got := Detect(tc.text); got != tc.want {
+			t.Errorf("%s: Detect = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
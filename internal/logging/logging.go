@@ -0,0 +1,88 @@
+// Package logging is a minimal leveled logger shared by index, embed, and
+// watcher so the CLI (or a library consumer) decides where diagnostics go.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Level orders messages by severity; a Logger prints messages at or below
+// its level.
+type Level int
+
+const (
+	LevelError Level = iota
+	LevelWarn
+	LevelInfo
+	LevelDebug
+)
+
+// Logger writes leveled, newline-terminated messages to a sink.
+// A nil *Logger is valid and discards everything.
+type Logger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+}
+
+// New returns a Logger writing messages at or below level to w.
+func New(w io.Writer, level Level) *Logger {
+	return &Logger{w: w, level: level}
+}
+
+// Default logs info and above to stderr — the behaviour packages had before
+// a logger was injected — or everything when SIFT_DEBUG=1.
+func Default() *Logger {
+	if os.Getenv("SIFT_DEBUG") == "1" {
+		return New(os.Stderr, LevelDebug)
+	}
+	return New(os.Stderr, LevelInfo)
+}
+
+// Discard returns a Logger that drops all output.
+func Discard() *Logger {
+	return New(io.Discard, LevelError)
+}
+
+// Enabled reports whether messages at lv would be written.
+func (l *Logger) Enabled(lv Level) bool {
+	return l != nil && lv <= l.level
+}
+
+// Writer returns the underlying sink for callers that draw their own
+// output (e.g. carriage-return progress lines). Guard with Enabled.
+func (l *Logger) Writer() io.Writer {
+	if l == nil {
+		return io.Discard
+	}
+	return l.w
+}
+
+// Errorf logs at LevelError.
+func (l *Logger) Errorf(format string, args ...any) { l.logf(LevelError, format, args...) }
+
+// Warnf logs at LevelWarn.
+func (l *Logger) Warnf(format string, args ...any) { l.logf(LevelWarn, format, args...) }
+
+// Infof logs at LevelInfo.
+func (l *Logger) Infof(format string, args ...any) { l.logf(LevelInfo, format, args...) }
+
+// Debugf logs at LevelDebug.
+func (l *Logger) Debugf(format string, args ...any) { l.logf(LevelDebug, format, args...) }
+
+func (l *Logger) logf(lv Level, format string, args ...any) {
+	if !l.Enabled(lv) {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.w, msg)
+}
@@ -0,0 +1,32 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLevels(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, LevelWarn)
+	l.Errorf("e %d", 1)
+	l.Warnf("w")
+	l.Infof("i")
+	l.Debugf("d")
+	if got, want := buf.String(), "e 1\nw\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if l.Enabled(LevelInfo) || !l.Enabled(LevelWarn) {
+		t.Error("Enabled disagrees with level")
+	}
+}
+
+func TestNilLogger(t *testing.T) {
+	var l *Logger
+	l.Errorf("dropped")
+	if l.Enabled(LevelError) {
+		t.Error("nil logger should be disabled")
+	}
+	if l.Writer() == nil {
+		t.Error("nil logger should expose a discard writer")
+	}
+}
@@ -0,0 +1,139 @@
+// Package maintain schedules index maintenance (index.Compact) inside the
+// long-lived watch process, which otherwise never prunes files deleted
+// from disk or retired cached searches: nobody runs `sift prune` against
+// an index a watcher keeps open for weeks.
+//
+// Maintenance runs only once the index has gone unchanged for a while, so
+// it does not compete with a burst of edits, and then either because it
+// is due (every Interval) or because enough of the indexed files are gone
+// (StalePct). The time it last ran is kept in the index's manifest, so
+// two watches sharing an index do not both run it.
+package maintain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tejas242/sift/internal/format"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/logging"
+)
+
+// Policy says when maintenance runs.
+type Policy struct {
+	// Interval runs maintenance at least this often; 0 disables it.
+	Interval time.Duration
+	// StalePct runs maintenance once this percentage of indexed files are
+	// gone from disk; 0 disables it.
+	StalePct int
+	// Idle is how long the index must go unchanged, and how long since
+	// any process last ran maintenance, before it runs.
+	Idle time.Duration
+}
+
+// Enabled reports whether p ever runs maintenance.
+func (p Policy) Enabled() bool {
+	return p.Interval > 0 || p.StalePct > 0
+}
+
+// Index is what the scheduler maintains; *index.Index implements it.
+type Index interface {
+	LastUpdated() time.Time
+	LastMaintenance() time.Time
+	StaleFraction() float64
+	Compact(ctx context.Context) (*index.Compaction, error)
+}
+
+// Scheduler runs maintenance on one index as Policy allows.
+type Scheduler struct {
+	idx     Index
+	policy  Policy
+	log     *logging.Logger
+	started time.Time
+}
+
+// New returns a scheduler for idx, started at now: an index that never
+// had maintenance is first due an Interval after that.
+func New(idx Index, p Policy, log *logging.Logger, now time.Time) *Scheduler {
+	if log == nil {
+		log = logging.Discard()
+	}
+	return &Scheduler{idx: idx, policy: p, log: log, started: now}
+}
+
+// Due reports whether maintenance should run at now, and why.
+func (s *Scheduler) Due(now time.Time) (reason string, due bool) {
+	p := s.policy
+	if !p.Enabled() || now.Sub(s.idx.LastUpdated()) < p.Idle {
+		return "", false
+	}
+	last := s.idx.LastMaintenance()
+	if now.Sub(last) < p.Idle {
+		return "", false
+	}
+	if p.Interval > 0 {
+		since := last
+		if since.Before(s.started) {
+			since = s.started
+		}
+		if now.Sub(since) >= p.Interval {
+			return lastRun(now, last), true
+		}
+	}
+	if p.StalePct > 0 {
+		if f := s.idx.StaleFraction(); f > 0 && f*100 >= float64(p.StalePct) {
+			return fmt.Sprintf("%.0f%% of indexed files are gone", f*100), true
+		}
+	}
+	return "", false
+}
+
+// lastRun describes how long before now last was: "last run 2 days
+// ago", or "never run".
+func lastRun(now, last time.Time) string {
+	if last.IsZero() {
+		return "never run"
+	}
+	return "last run " + format.Ago(now.Sub(last))
+}
+
+// RunOnce runs maintenance if it is due at now, logging a summary, and
+// reports whether it ran. Cancelling ctx stops it, leaving the index as
+// it was.
+func (s *Scheduler) RunOnce(ctx context.Context, now time.Time) bool {
+	reason, ok := s.Due(now)
+	if !ok {
+		return false
+	}
+	s.log.Debugf("[maintenance] starting: %s", reason)
+	c, err := s.idx.Compact(ctx)
+	switch {
+	case errors.Is(err, index.ErrIndexChanged):
+		s.log.Debugf("[maintenance] index changed meanwhile; will retry")
+		return false
+	case err != nil && ctx.Err() != nil:
+		return false
+	case err != nil:
+		s.log.Errorf("[maintenance] %v", err)
+		return false
+	}
+	s.log.Infof("[maintenance] %s: pruned %d deleted files (%d chunks), dropped %d cached searches in %s",
+		reason, len(c.Pruned), c.Chunks, c.CachedDropped, c.Took.Round(time.Millisecond))
+	return true
+}
+
+// Run calls RunOnce on every tick (normally a time.Ticker's channel),
+// with the tick's time, until ctx is done. It blocks; call it in a
+// goroutine.
+func (s *Scheduler) Run(ctx context.Context, tick <-chan time.Time) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tick:
+			s.RunOnce(ctx, now)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package maintain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// fakeIndex is an index whose state the tests set directly. Compact, like
+// the real one, records the time it ran (the scheduler's clock) unless
+// its context is done first.
+type fakeIndex struct {
+	updated, maintained time.Time
+	stale               float64
+	compactions         int
+	now                 time.Time
+	compact             func(ctx context.Context) error // optional; runs before recording
+}
+
+func (f *fakeIndex) LastUpdated() time.Time     { return f.updated }
+func (f *fakeIndex) LastMaintenance() time.Time { return f.maintained }
+func (f *fakeIndex) StaleFraction() float64     { return f.stale }
+
+func (f *fakeIndex) Compact(ctx context.Context) (*index.Compaction, error) {
+	if f.compact != nil {
+		if err := f.compact(ctx); err != nil {
+			return nil, err
+		}
+	}
+	f.compactions++
+	f.maintained, f.stale = f.now, 0
+	return &index.Compaction{}, nil
+}
+
+func TestDue(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	p := Policy{Interval: 24 * time.Hour, StalePct: 10, Idle: 10 * time.Minute}
+	for _, tc := range []struct {
+		name       string
+		policy     Policy
+		at         time.Duration // after start
+		updated    time.Duration // after start
+		maintained time.Duration // after start; 0 = never
+		stale      float64
+		want       bool
+	}{
+		{"fresh process, nothing stale", p, time.Hour, 0, 0, 0, false},
+		{"interval since start", p, 25 * time.Hour, 0, 0, 0, true},
+		{"interval, but busy", p, 25 * time.Hour, 25*time.Hour - time.Minute, 0, 0, false},
+		{"interval since the last run", p, 30 * time.Hour, 0, 10 * time.Hour, 0, false},
+		{"interval since the last run passed", p, 35 * time.Hour, 0, 10 * time.Hour, 0, true},
+		{"stale", p, time.Hour, 0, 0, 0.2, true},
+		{"stale, under the threshold", p, time.Hour, 0, 0, 0.05, false},
+		{"stale, but busy", p, time.Hour, time.Hour - time.Minute, 0, 0.2, false},
+		{"stale, but another process just ran it", p, time.Hour, 0, time.Hour - time.Minute, 0.2, false},
+		{"stale trigger disabled", Policy{Interval: 24 * time.Hour, Idle: 10 * time.Minute}, time.Hour, 0, 0, 0.9, false},
+		{"disabled", Policy{Idle: 10 * time.Minute}, 100 * time.Hour, 0, 0, 0.9, false},
+	} {
+		f := &fakeIndex{updated: start.Add(tc.updated), stale: tc.stale}
+		if tc.maintained > 0 {
+			f.maintained = start.Add(tc.maintained)
+		}
+		s := New(f, tc.policy, nil, start)
+		if reason, got := s.Due(start.Add(tc.at)); got != tc.want {
+			t.Errorf("%s: Due = %t (%q), want %t", tc.name, got, reason, tc.want)
+		}
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	f := &fakeIndex{updated: start}
+	s := New(f, Policy{Interval: time.Hour, StalePct: 10, Idle: 10 * time.Minute}, nil, start)
+	for _, step := range []struct {
+		at    time.Duration
+		stale float64
+		want  bool
+	}{
+		{30 * time.Minute, 0, false},
+		{61 * time.Minute, 0, true},  // an hour since start
+		{90 * time.Minute, 0, false}, // not an hour since
+		{95 * time.Minute, 0.5, true},
+		{100 * time.Minute, 0.5, false}, // ran less than Idle ago
+		{3 * time.Hour, 0, true},        // an hour since the last run
+	} {
+		f.now, f.stale = start.Add(step.at), step.stale
+		if got := s.RunOnce(context.Background(), f.now); got != step.want {
+			t.Errorf("at %s: RunOnce = %t, want %t", step.at, got, step.want)
+		}
+	}
+	if want := start.Add(3 * time.Hour); f.compactions != 3 || !f.maintained.Equal(want) {
+		t.Errorf("%d compactions, the last at %v; want 3, at %v", f.compactions, f.maintained, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	f := &fakeIndex{updated: start, stale: 1}
+	s := New(f, Policy{StalePct: 10}, nil, start)
+	ctx, cancel := context.WithCancel(context.Background())
+	tick := make(chan time.Time)
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx, tick)
+		close(done)
+	}()
+	tick <- start.Add(time.Minute)
+	tick <- start.Add(2 * time.Minute) // received once the first has run
+	cancel()
+	<-done
+	if f.compactions != 1 {
+		t.Errorf("compactions = %d, want 1", f.compactions)
+	}
+}
+
+func TestRunOnce_Shutdown(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	ctx, cancel := context.WithCancel(context.Background())
+	// Shutting down mid-maintenance: Compact gives up with ctx's error and
+	// records nothing.
+	f := &fakeIndex{updated: start, stale: 1, compact: func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	}}
+	s := New(f, Policy{StalePct: 10}, nil, start)
+	if s.RunOnce(ctx, start.Add(time.Minute)) {
+		t.Error("RunOnce reported a cancelled maintenance as run")
+	}
+	if f.compactions != 0 || !f.maintained.IsZero() {
+		t.Errorf("a cancelled maintenance was recorded: %d runs, last %v", f.compactions, f.maintained)
+	}
+
+	// An index that changed meanwhile is retried on the next tick.
+	f.compact = func(context.Context) error { return index.ErrIndexChanged }
+	if New(f, Policy{StalePct: 10}, nil, start).RunOnce(context.Background(), start.Add(time.Minute)) {
+		t.Error("RunOnce reported maintenance run when the index changed meanwhile")
+	}
+}
@@ -0,0 +1,167 @@
+// Package mcp exposes a sift index to coding agents over the Model Context
+// Protocol: newline-delimited JSON-RPC 2.0 on stdin/stdout.
+//
+// Supported methods: initialize, notifications/initialized, ping, tools/list
+// and tools/call with the sift_search and sift_stats tools.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// ProtocolVersion is the MCP revision this server implements.
+const ProtocolVersion = "2024-11-05"
+
+// JSON-RPC 2.0 error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeServerError    = -32000
+)
+
+// Backend is the subset of *index.Index the tools need.
+type Backend interface {
+	SearchFiltered(ctx context.Context, query string, k int, f index.Filter) ([]index.SearchResult, error)
+	Stats() index.Stats
+}
+
+// Server answers MCP requests against a lazily opened Backend, so clients
+// can complete the handshake even when the index or model is missing.
+type Server struct {
+	version string
+	open    func() (Backend, error)
+
+	mu      sync.Mutex
+	backend Backend
+}
+
+// NewServer returns a server reporting version in its handshake. open is
+// called on the first tool invocation; its error is returned to the client.
+func NewServer(version string, open func() (Backend, error)) *Server {
+	return &Server{version: version, open: open}
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// Serve reads requests from r and writes responses to w until r reaches EOF.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	enc := json.NewEncoder(w)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if resp := s.handle(line); resp != nil {
+				if encErr := enc.Encode(resp); encErr != nil {
+					return fmt.Errorf("write response: %w", encErr)
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read request: %w", err)
+		}
+	}
+}
+
+// handle processes one frame. It returns nil for notifications and blank lines.
+func (s *Server) handle(line []byte) *response {
+	if len(bytes.TrimSpace(line)) == 0 {
+		return nil
+	}
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return errorResponse(json.RawMessage("null"), codeParseError, "parse error: "+err.Error())
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		id := req.ID
+		if id == nil {
+			id = json.RawMessage("null")
+		}
+		return errorResponse(id, codeInvalidRequest, "invalid request")
+	}
+	notification := req.ID == nil
+
+	result, err := s.dispatch(req.Method, req.Params)
+	if notification {
+		return nil
+	}
+	if err != nil {
+		var re *rpcError
+		if errors.As(err, &re) {
+			return errorResponse(req.ID, re.Code, re.Message)
+		}
+		return errorResponse(req.ID, codeServerError, err.Error())
+	}
+	return &response{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+func (s *Server) dispatch(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		return map[string]interface{}{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      map[string]string{"name": "sift", "version": s.version},
+		}, nil
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+	case "ping":
+		return map[string]interface{}{}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": toolDefs}, nil
+	case "tools/call":
+		return s.callTool(params)
+	default:
+		return nil, &rpcError{Code: codeMethodNotFound, Message: "method not found: " + method}
+	}
+}
+
+func (s *Server) backendOrErr() (Backend, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backend != nil {
+		return s.backend, nil
+	}
+	b, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	s.backend = b
+	return b, nil
+}
+
+func errorResponse(id json.RawMessage, code int, msg string) *response {
+	return &response{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: msg}}
+}
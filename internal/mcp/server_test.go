@@ -0,0 +1,212 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/protocol"
+)
+
+type mockEmbedder struct{}
+
+func (m *mockEmbedder) Embed(texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i := range texts {
+		v := make([]float32, 384)
+		v[0] = 1.0
+		vecs[i] = v
+	}
+	return vecs, nil
+}
+
+func (m *mockEmbedder) EmbedQuery(query string) ([]float32, error) {
+	v := make([]float32, 384)
+	v[0] = 1.0
+	return v, nil
+}
+
+func (m *mockEmbedder) Close() {}
+
+// fixtureIndex indexes a .go and a .md file with the mock embedder.
+func fixtureIndex(t *testing.T) *index.Index {
+	t.Helper()
+	dir := t.TempDir()
+	idx := index.NewTestIndex(filepath.Join(dir, ".sift"), &mockEmbedder{})
+	for name, content := range map[string]string{
+		"src/auth.go":  "package auth\n\nfunc Login() {}\n",
+		"docs/auth.md": "# Authentication\n\nHow login works.\n",
+	} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return idx
+}
+
+// exchange feeds frames to a fresh server and decodes every response.
+func exchange(t *testing.T, open func() (Backend, error), frames ...string) []response {
+	t.Helper()
+	var out bytes.Buffer
+	srv := NewServer("test", open)
+	if err := srv.Serve(strings.NewReader(strings.Join(frames, "\n")+"\n"), &out); err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	var resps []response
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var r response
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		resps = append(resps, r)
+	}
+	return resps
+}
+
+func resultMap(t *testing.T, r response) map[string]interface{} {
+	t.Helper()
+	if r.Error != nil {
+		t.Fatalf("unexpected error frame: %+v", r.Error)
+	}
+	data, _ := json.Marshal(r.Result)
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// toolText extracts the protocol message in the text content of a
+// tools/call result, checks its type and decodes its payload.
+func toolText(t *testing.T, r response, typ string, v interface{}) {
+	t.Helper()
+	m := resultMap(t, r)
+	content := m["content"].([]interface{})
+	text := content[0].(map[string]interface{})["text"].(string)
+	env, err := protocol.Unmarshal([]byte(text))
+	if err != nil {
+		t.Fatalf("decode tool text %q: %v", text, err)
+	}
+	if env.Type != typ {
+		t.Fatalf("tool text type = %q, want %q", env.Type, typ)
+	}
+	if err := env.Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandshakeAndListTools(t *testing.T) {
+	opened := false
+	resps := exchange(t, func() (Backend, error) { opened = true; return nil, index.ErrNoIndex },
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"test","version":"0"}}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"ping"}`,
+	)
+	if opened {
+		t.Error("handshake should not open the index")
+	}
+	if len(resps) != 3 {
+		t.Fatalf("expected 3 responses (notification is silent), got %d", len(resps))
+	}
+
+	init := resultMap(t, resps[0])
+	if init["protocolVersion"] != ProtocolVersion {
+		t.Errorf("protocolVersion = %v", init["protocolVersion"])
+	}
+	if info := init["serverInfo"].(map[string]interface{}); info["name"] != "sift" {
+		t.Errorf("serverInfo = %v", info)
+	}
+
+	tools := resultMap(t, resps[1])["tools"].([]interface{})
+	names := map[string]bool{}
+	for _, raw := range tools {
+		tl := raw.(map[string]interface{})
+		names[tl["name"].(string)] = true
+		schema := tl["inputSchema"].(map[string]interface{})
+		if schema["type"] != "object" {
+			t.Errorf("%s: schema type = %v, want object", tl["name"], schema["type"])
+		}
+		if _, ok := schema["properties"].(map[string]interface{}); !ok {
+			t.Errorf("%s: schema has no properties object", tl["name"])
+		}
+	}
+	if !names["sift_search"] || !names["sift_stats"] {
+		t.Errorf("tools = %v, want sift_search and sift_stats", names)
+	}
+}
+
+func TestCallSearchWithFilters(t *testing.T) {
+	idx := fixtureIndex(t)
+	resps := exchange(t, func() (Backend, error) { return idx, nil },
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"sift_search","arguments":{"query":"login","k":5}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"sift_search","arguments":{"query":"login","ext":["md"]}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"sift_stats","arguments":{}}}`,
+	)
+	if len(resps) != 3 {
+		t.Fatalf("expected 3 responses, got %d", len(resps))
+	}
+
+	var res protocol.SearchResults
+	toolText(t, resps[0], protocol.TypeSearchResult, &res)
+	all := res.Results
+	if len(all) != 2 {
+		t.Fatalf("unfiltered search: got %d hits, want 2", len(all))
+	}
+	for _, h := range all {
+		if h.Line < 1 || h.Text == "" {
+			t.Errorf("hit missing line/text: %+v", h)
+		}
+	}
+
+	res = protocol.SearchResults{}
+	toolText(t, resps[1], protocol.TypeSearchResult, &res)
+	if md := res.Results; len(md) != 1 || filepath.Ext(md[0].Path) != ".md" {
+		t.Errorf("ext filter: got %+v, want only the .md file", md)
+	}
+
+	var st protocol.Stats
+	toolText(t, resps[2], protocol.TypeStats, &st)
+	if st.Chunks != 2 || st.Files != 2 {
+		t.Errorf("stats = %+v, want 2 chunks / 2 files", st)
+	}
+}
+
+func TestErrorFrames(t *testing.T) {
+	resps := exchange(t, func() (Backend, error) { return nil, index.ErrNoIndex },
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"sift_search","arguments":{"query":"x"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"sift_search","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"sift_search","arguments":{"query":" \t "}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"nope"}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"resources/list"}`,
+		`{not json`,
+	)
+	want := []int{codeServerError, codeInvalidParams, codeInvalidParams, codeInvalidParams, codeMethodNotFound, codeParseError}
+	if len(resps) != len(want) {
+		t.Fatalf("expected %d responses, got %d", len(want), len(resps))
+	}
+	for i, code := range want {
+		if resps[i].Error == nil {
+			t.Errorf("frame %d: expected error %d, got result", i, code)
+			continue
+		}
+		if resps[i].Error.Code != code {
+			t.Errorf("frame %d: error code = %d, want %d (%s)", i, resps[i].Error.Code, code, resps[i].Error.Message)
+		}
+	}
+	if !strings.Contains(resps[0].Error.Message, "no index") {
+		t.Errorf("missing-index message = %q", resps[0].Error.Message)
+	}
+}
@@ -0,0 +1,141 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/protocol"
+)
+
+// defaultK is the result count when sift_search is called without k.
+const defaultK = 10
+
+// maxK bounds k so a client cannot request the whole index.
+const maxK = 100
+
+type tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+// toolDefs are advertised by tools/list. The input schemas are JSON Schema
+// (draft 2020-12 subset) so clients can render parameters.
+var toolDefs = []tool{
+	{
+		Name:        "sift_search",
+		Description: "Semantic search over the local sift index. Returns the most relevant chunks (one per file) with path, line, score and text.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "Natural-language description of what to find.",
+					"minLength":   1,
+				},
+				"k": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of results.",
+					"minimum":     1,
+					"maximum":     maxK,
+					"default":     defaultK,
+				},
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Only return files whose path starts with this prefix.",
+				},
+				"ext": map[string]interface{}{
+					"type":        "array",
+					"description": "Only return files with these extensions, e.g. [\"go\", \"md\"].",
+					"items":       map[string]interface{}{"type": "string"},
+				},
+			},
+			"required":             []string{"query"},
+			"additionalProperties": false,
+		},
+	},
+	{
+		Name:        "sift_stats",
+		Description: "Summary statistics for the local sift index.",
+		InputSchema: map[string]interface{}{
+			"type":                 "object",
+			"properties":           map[string]interface{}{},
+			"additionalProperties": false,
+		},
+	},
+}
+
+type callParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type searchArgs struct {
+	Query string   `json:"query"`
+	K     int      `json:"k"`
+	Path  string   `json:"path"`
+	Ext   []string `json:"ext"`
+}
+
+func (s *Server) callTool(raw json.RawMessage) (interface{}, error) {
+	var p callParams
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return nil, &rpcError{Code: codeInvalidParams, Message: "invalid tools/call params: " + err.Error()}
+	}
+	switch p.Name {
+	case "sift_search":
+		var a searchArgs
+		if len(p.Arguments) > 0 {
+			if err := json.Unmarshal(p.Arguments, &a); err != nil {
+				return nil, &rpcError{Code: codeInvalidParams, Message: "invalid arguments: " + err.Error()}
+			}
+		}
+		if index.NormalizeQuery(a.Query) == "" {
+			return nil, &rpcError{Code: codeInvalidParams, Message: "query is required"}
+		}
+		if a.K <= 0 {
+			a.K = defaultK
+		}
+		if a.K > maxK {
+			a.K = maxK
+		}
+		b, err := s.backendOrErr()
+		if err != nil {
+			return nil, err
+		}
+		results, err := b.SearchFiltered(context.Background(), a.Query, a.K, index.Filter{Exts: a.Ext, PathPrefix: a.Path})
+		if err != nil {
+			return nil, err
+		}
+		hits := make([]protocol.Hit, len(results))
+		for i, r := range results {
+			hits[i] = protocol.NewHit(r)
+		}
+		return textResult(protocol.TypeSearchResult, protocol.SearchResults{Results: hits})
+
+	case "sift_stats":
+		b, err := s.backendOrErr()
+		if err != nil {
+			return nil, err
+		}
+		st := b.Stats()
+		return textResult(protocol.TypeStats, protocol.Stats{Chunks: st.NumChunks, Files: st.NumFiles, SizeKB: st.IndexSizeKB, LastUpdated: st.LastUpdated})
+
+	default:
+		return nil, &rpcError{Code: codeInvalidParams, Message: "unknown tool: " + p.Name}
+	}
+}
+
+// textResult wraps a protocol message of type typ carrying v as the JSON
+// text content of a tools/call result.
+func textResult(typ string, v interface{}) (interface{}, error) {
+	data, err := protocol.Marshal(typ, v)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": string(data)}},
+		"isError": false,
+	}, nil
+}
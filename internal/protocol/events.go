@@ -0,0 +1,209 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// The message types, by the stream that carries them.
+const (
+	// --progress=json, one root of an index, rebuild or watch run at a
+	// time.
+	TypeIndexStart  = "index.start"
+	TypeIndexChunk  = "index.chunk"
+	TypeIndexFile   = "index.file"
+	TypeIndexFinish = "index.finish"
+	TypeIndexError  = "index.error"
+	// watch --progress=json, after the initial run.
+	TypeWatchFile   = "watch.file"
+	TypeWatchRescan = "watch.rescan"
+	// Socket server responses and MCP tool results.
+	TypeSearchResult = "search.result"
+	TypeStats        = "stats"
+	TypeReload       = "reload"
+	TypeOK           = "ok"
+	TypeError        = "error"
+)
+
+// EventType describes a message type: its name, what it reports, and its
+// payload, as a zero value (nil for none).
+type EventType struct {
+	Name string
+	Doc  string
+	Data any
+}
+
+// Types lists every message type sift writes.
+var Types = []EventType{
+	{TypeIndexStart, "Indexing of a root started.", IndexStart{}},
+	{TypeIndexChunk, "Embedding batches of a large file done so far, between batches.", IndexChunk{}},
+	{TypeIndexFile, "A file of the root was indexed, or skipped as unchanged.", IndexFile{}},
+	{TypeIndexFinish, "Indexing of a root finished.", IndexFinish{}},
+	{TypeIndexError, "Indexing of a root failed; the run stops.", IndexError{}},
+	{TypeWatchFile, "A watched file changed and was re-indexed, or failed to be.", WatchFile{}},
+	{TypeWatchRescan, "A rescan of the watched roots finished.", WatchRescan{}},
+	{TypeSearchResult, "The results of a search, best first, at most one chunk per file unless asked for more.", SearchResults{}},
+	{TypeStats, "Summary statistics of the index.", Stats{}},
+	{TypeReload, "The settings a configuration reload applied, and those that need a restart.", Reload{}},
+	{TypeOK, "A request without a reply (ping, shutdown) succeeded.", nil},
+	{TypeError, "A request failed.", Error{}},
+}
+
+// IndexStart is the payload of TypeIndexStart.
+type IndexStart struct {
+	Root string `json:"root"`
+}
+
+// IndexChunk is the payload of TypeIndexChunk: Done of the Total chunks
+// of Path are embedded.
+type IndexChunk struct {
+	Path  string `json:"path"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+}
+
+// IndexFile is the payload of TypeIndexFile: Path is file Done of the
+// root's Total.
+type IndexFile struct {
+	Done    int    `json:"done"`
+	Total   int    `json:"total"`
+	Path    string `json:"path"`
+	Skipped bool   `json:"skipped"`
+}
+
+// IndexFinish is the payload of TypeIndexFinish.
+type IndexFinish struct {
+	Root       string `json:"root"`
+	Chunks     int    `json:"chunks"`
+	DurationMS int64  `json:"duration_ms"`
+	// EmbedFailed counts the root's files that could not be embedded,
+	// and EmbedFailures lists the first few.
+	EmbedFailed   int            `json:"embed_failed,omitempty"`
+	EmbedFailures []EmbedFailure `json:"embed_failures,omitempty"`
+}
+
+// IndexError is the payload of TypeIndexError.
+type IndexError struct {
+	Root          string         `json:"root"`
+	Error         string         `json:"error"`
+	DurationMS    int64          `json:"duration_ms"`
+	EmbedFailed   int            `json:"embed_failed,omitempty"`
+	EmbedFailures []EmbedFailure `json:"embed_failures,omitempty"`
+}
+
+// EmbedFailure is a file that could not be embedded.
+type EmbedFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// WatchFile is the payload of TypeWatchFile. Skipped is set when the
+// file was unchanged after all, and Error when it could not be indexed.
+type WatchFile struct {
+	Path    string `json:"path"`
+	Skipped bool   `json:"skipped"`
+	Error   string `json:"error,omitempty"`
+}
+
+// WatchRescan is the payload of TypeWatchRescan: of the Files checked,
+// Updated were re-indexed and Removed, gone from disk, dropped.
+type WatchRescan struct {
+	Files      int   `json:"files"`
+	Updated    int   `json:"updated"`
+	Removed    int   `json:"removed"`
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// SearchResults is the payload of TypeSearchResult.
+type SearchResults struct {
+	Results []Hit `json:"results"`
+}
+
+// Hit is one search result.
+type Hit struct {
+	ID      string  `json:"id,omitempty"`
+	Path    string  `json:"path"`
+	Line    int     `json:"line"`
+	Score   float32 `json:"score"`
+	Vector  float32 `json:"vector"`
+	Keyword float32 `json:"keyword"`
+	// Matched lists the query words that earned the keyword boost, and
+	// MatchedTokens the word of the chunk each matched.
+	Matched       []string `json:"matched,omitempty"`
+	MatchedTokens []string `json:"matched_tokens,omitempty"`
+	// Calibrated is set when the index calibrates scores.
+	Calibrated *float32 `json:"calibrated,omitempty"`
+	// Promoted is set when the per-directory cap moved the hit up.
+	Promoted   bool      `json:"promoted,omitempty"`
+	Text       string    `json:"text"`
+	StartByte  int64     `json:"start_byte"`
+	EndByte    int64     `json:"end_byte"`
+	ChunkIndex int       `json:"chunk_index"`
+	Mtime      time.Time `json:"mtime"`
+}
+
+// NewHit converts a search result, reading its full text back if the
+// index stores only a preview.
+func NewHit(r index.SearchResult) Hit {
+	text, _ := r.Meta.FullText()
+	return Hit{
+		ID:            r.Meta.ID,
+		Path:          r.Meta.Path,
+		Line:          r.Meta.LineNum,
+		Score:         r.Score,
+		Vector:        r.Vector,
+		Keyword:       r.Keyword,
+		Matched:       r.Matched,
+		MatchedTokens: r.MatchedTokens,
+		Calibrated:    r.Calibrated,
+		Promoted:      r.Promoted,
+		Text:          text,
+		StartByte:     r.Meta.StartByte,
+		EndByte:       r.Meta.EndByte,
+		ChunkIndex:    r.Meta.ChunkIndex,
+		Mtime:         r.Meta.Mtime,
+	}
+}
+
+// Result converts h back into a search result, as a client renders it.
+func (h Hit) Result() index.SearchResult {
+	return index.SearchResult{
+		Meta: index.ChunkMeta{
+			ID:         h.ID,
+			Path:       h.Path,
+			LineNum:    h.Line,
+			Text:       h.Text,
+			StartByte:  h.StartByte,
+			EndByte:    h.EndByte,
+			ChunkIndex: h.ChunkIndex,
+			Mtime:      h.Mtime,
+		},
+		Score:         h.Score,
+		Vector:        h.Vector,
+		Keyword:       h.Keyword,
+		Matched:       h.Matched,
+		MatchedTokens: h.MatchedTokens,
+		Calibrated:    h.Calibrated,
+		Promoted:      h.Promoted,
+	}
+}
+
+// Stats is the payload of TypeStats.
+type Stats struct {
+	Chunks      int       `json:"chunks"`
+	Files       int       `json:"files"`
+	SizeKB      int64     `json:"size_kb"`
+	LastUpdated time.Time `json:"last_updated,omitzero"`
+}
+
+// Reload is the payload of TypeReload.
+type Reload struct {
+	Applied []string `json:"applied"`
+	Restart []string `json:"restart"`
+}
+
+// Error is the payload of TypeError.
+type Error struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,111 @@
+// Package protocol defines the JSON sift writes for other programs to
+// read: the --progress=json stream of index, rebuild and watch, the watch
+// event stream, the socket server's responses and the MCP tool results.
+// Every message is an Envelope naming its version and type, with the
+// type's payload under "data", one per line:
+//
+//	{"v":1,"type":"index.file","data":{"done":12,"total":240,"path":"docs/a.md","skipped":false}}
+//
+// so a client parses any of them with one decoder, switching on Type.
+// Payloads only grow within a version: a client should ignore fields and
+// types it does not know. See Types for every type and Schema for its
+// JSON Schema.
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Version is the protocol version sift writes. It changes only when a
+// payload changes incompatibly.
+const Version = 1
+
+// ErrVersion is returned by Unmarshal for a message of a version newer
+// than Version, or of none.
+var ErrVersion = errors.New("unsupported protocol version")
+
+// Envelope is one message.
+type Envelope struct {
+	V    int    `json:"v"`
+	Type string `json:"type"`
+	// ID is the socket request the message answers, if any.
+	ID   int             `json:"id,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// New returns the envelope of a message of type typ carrying data, which
+// is nil for a type without a payload.
+func New(typ string, data any) (Envelope, error) {
+	env := Envelope{V: Version, Type: typ}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return Envelope{}, fmt.Errorf("marshal %s: %w", typ, err)
+		}
+		env.Data = raw
+	}
+	return env, nil
+}
+
+// Marshal encodes a message of type typ carrying data, without the
+// trailing newline.
+func Marshal(typ string, data any) ([]byte, error) {
+	env, err := New(typ, data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(env)
+}
+
+// Unmarshal decodes one message, leaving its payload for Decode. Unknown
+// fields are ignored; a version other than 1 to Version fails with
+// ErrVersion.
+func Unmarshal(line []byte) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(line, &env); err != nil {
+		return Envelope{}, err
+	}
+	if env.V < 1 || env.V > Version {
+		return Envelope{}, fmt.Errorf("%w: %d", ErrVersion, env.V)
+	}
+	return env, nil
+}
+
+// Decode decodes the payload of e into v, ignoring unknown fields. A
+// message without a payload leaves v as it is.
+func (e Envelope) Decode(v any) error {
+	if len(e.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return fmt.Errorf("decode %s: %w", e.Type, err)
+	}
+	return nil
+}
+
+// Encoder writes messages as NDJSON. It is safe for concurrent use, so
+// several sources (an indexing run and a watcher) can share a stream.
+type Encoder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{enc: json.NewEncoder(w)}
+}
+
+// Encode writes a message of type typ carrying data, and a newline.
+func (e *Encoder) Encode(typ string, data any) error {
+	env, err := New(typ, data)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(env)
+}
@@ -0,0 +1,159 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+var calibrated float32 = 0.9
+
+// samples has a payload with every field set for each type with one.
+var samples = map[string]any{
+	TypeIndexStart:  IndexStart{Root: "docs"},
+	TypeIndexChunk:  IndexChunk{Path: "docs/big.md", Done: 64, Total: 300},
+	TypeIndexFile:   IndexFile{Done: 12, Total: 240, Path: "docs/a.md", Skipped: true},
+	TypeIndexFinish: IndexFinish{Root: "docs", Chunks: 1180, DurationMS: 5321, EmbedFailed: 3, EmbedFailures: []EmbedFailure{{Path: "b.md", Error: "boom"}}},
+	TypeIndexError:  IndexError{Root: "docs", Error: "giving up", DurationMS: 12, EmbedFailed: 10, EmbedFailures: []EmbedFailure{{Path: "c.md", Error: "boom"}}},
+	TypeWatchFile:   WatchFile{Path: "docs/a.md", Skipped: true, Error: "permission denied"},
+	TypeWatchRescan: WatchRescan{Files: 240, Updated: 2, Removed: 1, DurationMS: 900},
+	TypeSearchResult: SearchResults{Results: []Hit{{
+		ID: "3f9a1c0e5b7d2468", Path: "docs/vpn.md", Line: 12, Score: 0.8, Vector: 0.7, Keyword: 0.1,
+		Matched: []string{"wireguard"}, MatchedTokens: []string{"WireGuard"}, Calibrated: &calibrated,
+		Promoted: true, Text: "WireGuard config", StartByte: 100, EndByte: 116, ChunkIndex: 1,
+		Mtime: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}}},
+	TypeStats:  Stats{Chunks: 1180, Files: 240, SizeKB: 4096, LastUpdated: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+	TypeReload: Reload{Applied: []string{"max-file-kb=64"}, Restart: []string{"model-dir"}},
+	TypeError:  Error{Message: "query is required"},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, typ := range Types {
+		t.Run(typ.Name, func(t *testing.T) {
+			data := samples[typ.Name]
+			if (data == nil) != (typ.Data == nil) {
+				t.Fatalf("sample %v does not match the payload %T", data, typ.Data)
+			}
+			if data != nil && reflect.TypeOf(data) != reflect.TypeOf(typ.Data) {
+				t.Fatalf("sample is a %T, want %T", data, typ.Data)
+			}
+			var buf bytes.Buffer
+			if err := NewEncoder(&buf).Encode(typ.Name, data); err != nil {
+				t.Fatal(err)
+			}
+			line := buf.Bytes()
+			if bytes.Count(line, []byte("\n")) != 1 || line[len(line)-1] != '\n' {
+				t.Fatalf("Encode wrote %q, want one line", line)
+			}
+
+			env, err := Unmarshal(line)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if env.V != Version || env.Type != typ.Name {
+				t.Fatalf("envelope = v%d %q", env.V, env.Type)
+			}
+			if data == nil {
+				if env.Data != nil {
+					t.Errorf("data = %s, want none", env.Data)
+				}
+				return
+			}
+			got := reflect.New(reflect.TypeOf(data))
+			if err := env.Decode(got.Interface()); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Elem().Interface(), data) {
+				t.Errorf("round trip = %+v, want %+v", got.Elem().Interface(), data)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_UnknownFields(t *testing.T) {
+	// A later sift may add fields to the envelope and the payload.
+	line := `{"v":1,"type":"index.file","trace":"x","data":{"done":1,"total":2,"path":"a.md","skipped":false,"bytes":512}}`
+	env, err := Unmarshal([]byte(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f IndexFile
+	if err := env.Decode(&f); err != nil {
+		t.Fatal(err)
+	}
+	if f != (IndexFile{Done: 1, Total: 2, Path: "a.md"}) {
+		t.Errorf("decoded %+v", f)
+	}
+	// And types: a client skips what it does not know.
+	if env, err := Unmarshal([]byte(`{"v":1,"type":"index.pause","data":{}}`)); err != nil || env.Type != "index.pause" {
+		t.Errorf("unknown type: %+v, %v", env, err)
+	}
+}
+
+func TestUnmarshal_Version(t *testing.T) {
+	for _, line := range []string{`{"v":2,"type":"ok"}`, `{"type":"ok"}`, `{"results":[]}`} {
+		if _, err := Unmarshal([]byte(line)); !errors.Is(err, ErrVersion) {
+			t.Errorf("Unmarshal(%s) = %v, want ErrVersion", line, err)
+		}
+	}
+	if _, err := Unmarshal([]byte(`{"v":1,`)); err == nil || errors.Is(err, ErrVersion) {
+		t.Errorf("Unmarshal of truncated JSON = %v, want a syntax error", err)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	for _, typ := range Types {
+		s, ok := Schema(typ.Name)
+		if !ok {
+			t.Fatalf("no schema for %s", typ.Name)
+		}
+		if s["$schema"] != SchemaDialect || s["title"] != typ.Name || s["description"] != typ.Doc {
+			t.Errorf("%s: schema header = %v", typ.Name, s)
+		}
+		props := s["properties"].(map[string]any)
+		if props["type"].(map[string]any)["const"] != typ.Name {
+			t.Errorf("%s: type property = %v", typ.Name, props["type"])
+		}
+		if typ.Data == nil {
+			if _, ok := props["data"]; ok {
+				t.Errorf("%s: schema has data, the type has no payload", typ.Name)
+			}
+			continue
+		}
+		// Every field of the sample's encoding is described.
+		data := props["data"].(map[string]any)
+		raw, _ := json.Marshal(samples[typ.Name])
+		var fields map[string]any
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			t.Fatal(err)
+		}
+		dataProps := data["properties"].(map[string]any)
+		for name := range fields {
+			if _, ok := dataProps[name]; !ok {
+				t.Errorf("%s: field %q missing from the schema", typ.Name, name)
+			}
+		}
+		if _, ok := data["additionalProperties"]; ok {
+			t.Errorf("%s: schema forbids unknown fields", typ.Name)
+		}
+	}
+
+	s, _ := Schema(TypeIndexFinish)
+	data := s["properties"].(map[string]any)["data"].(map[string]any)
+	if req := data["required"].([]string); !slices.Equal(req, []string{"root", "chunks", "duration_ms"}) {
+		t.Errorf("index.finish requires %q, want the fields without omitempty", req)
+	}
+	s, _ = Schema(TypeStats)
+	updated := s["properties"].(map[string]any)["data"].(map[string]any)["properties"].(map[string]any)["last_updated"]
+	if !reflect.DeepEqual(updated, map[string]any{"type": "string", "format": "date-time"}) {
+		t.Errorf("stats last_updated = %v", updated)
+	}
+	if _, ok := Schema("index.pause"); ok {
+		t.Error("Schema of an unknown type succeeded")
+	}
+}
@@ -0,0 +1,97 @@
+package protocol
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SchemaDialect is the JSON Schema draft of Schema's output.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Lookup returns the message type named name.
+func Lookup(name string) (EventType, bool) {
+	for _, t := range Types {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return EventType{}, false
+}
+
+// Schema returns the JSON Schema of a whole message of type name: the
+// envelope, with its payload's schema under "data". Fields not listed are
+// allowed, as payloads grow within a version.
+func Schema(name string) (map[string]any, bool) {
+	t, ok := Lookup(name)
+	if !ok {
+		return nil, false
+	}
+	props := map[string]any{
+		"v":    map[string]any{"const": Version},
+		"type": map[string]any{"const": t.Name},
+		"id":   map[string]any{"type": "integer", "description": "The socket request answered, if any."},
+	}
+	required := []string{"v", "type"}
+	if t.Data != nil {
+		props["data"] = typeSchema(reflect.TypeOf(t.Data))
+		required = append(required, "data")
+	}
+	return map[string]any{
+		"$schema":     SchemaDialect,
+		"title":       t.Name,
+		"description": t.Doc,
+		"type":        "object",
+		"properties":  props,
+		"required":    required,
+	}, true
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// typeSchema returns the JSON Schema of the encoding of values of type t.
+func typeSchema(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		required := []string{}
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = typeSchema(f.Type)
+			if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": props, "required": required}
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,113 @@
+// Package resultview holds presentation helpers shared by the CLI and TUI:
+// grouping search hits by file and locating query terms for highlighting.
+package resultview
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// FileGroup is the hits from one file, best first.
+type FileGroup struct {
+	Path string
+	Best float32
+	Hits []index.SearchResult
+}
+
+// Group buckets results by file. Groups are ordered by their best score
+// and keep the input order of hits within a file.
+func Group(results []index.SearchResult) []FileGroup {
+	var groups []FileGroup
+	pos := make(map[string]int)
+	for _, r := range results {
+		i, ok := pos[r.Meta.Path]
+		if !ok {
+			i = len(groups)
+			pos[r.Meta.Path] = i
+			groups = append(groups, FileGroup{Path: r.Meta.Path, Best: r.Score})
+		}
+		g := &groups[i]
+		g.Hits = append(g.Hits, r)
+		g.Best = max(g.Best, r.Score)
+	}
+	slices.SortStableFunc(groups, func(a, b FileGroup) int {
+		switch {
+		case a.Best > b.Best:
+			return -1
+		case a.Best < b.Best:
+			return 1
+		}
+		return 0
+	})
+	return groups
+}
+
+// Terms splits a query into the lower-cased words worth highlighting:
+// letters and digits only, at least two characters, without duplicates.
+func Terms(query string) []string {
+	var terms []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) >= 2 && !slices.Contains(terms, w) {
+			terms = append(terms, w)
+		}
+	}
+	return terms
+}
+
+// Span is a half-open byte range [Start, End) of a matched term.
+type Span struct{ Start, End int }
+
+// Find returns the non-overlapping, sorted spans in line where any term
+// occurs, matching case-insensitively.
+func Find(line string, terms []string) []Span {
+	if len(terms) == 0 {
+		return nil
+	}
+	lower := strings.ToLower(line)
+	if len(lower) != len(line) {
+		return nil // case folding changed byte offsets; don't guess
+	}
+	var spans []Span
+	for _, t := range terms {
+		for off := 0; ; {
+			i := strings.Index(lower[off:], t)
+			if i < 0 {
+				break
+			}
+			spans = append(spans, Span{off + i, off + i + len(t)})
+			off += i + len(t)
+		}
+	}
+	slices.SortFunc(spans, func(a, b Span) int { return a.Start - b.Start })
+	var merged []Span
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.Start <= merged[n-1].End {
+			merged[n-1].End = max(merged[n-1].End, s.End)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// Highlight returns line with every term occurrence passed through mark.
+func Highlight(line string, terms []string, mark func(string) string) string {
+	spans := Find(line, terms)
+	if len(spans) == 0 {
+		return line
+	}
+	var sb strings.Builder
+	last := 0
+	for _, s := range spans {
+		sb.WriteString(line[last:s.Start])
+		sb.WriteString(mark(line[s.Start:s.End]))
+		last = s.End
+	}
+	sb.WriteString(line[last:])
+	return sb.String()
+}
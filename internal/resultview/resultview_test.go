@@ -0,0 +1,51 @@
+package resultview
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+func TestTerms(t *testing.T) {
+	got := Terms("How does WireGuard's config-file work? a config")
+	want := []string{"how", "does", "wireguard", "config", "file", "work"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Terms = %v, want %v", got, want)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	mark := func(s string) string { return "[" + s + "]" }
+	cases := []struct{ line, want string }{
+		{"The WireGuard config", "The [WireGuard] [config]"},
+		{"configconfig", "[configconfig]"},
+		{"nothing here", "nothing here"},
+	}
+	for _, tc := range cases {
+		if got := Highlight(tc.line, []string{"wireguard", "config"}, mark); got != tc.want {
+			t.Errorf("Highlight(%q) = %q, want %q", tc.line, got, tc.want)
+		}
+	}
+	// Overlapping terms merge into one span.
+	if got := Find("wireguard", []string{"wire", "reguard"}); !slices.Equal(got, []Span{{0, 9}}) {
+		t.Errorf("overlapping spans = %v", got)
+	}
+}
+
+func TestGroup(t *testing.T) {
+	r := func(path string, score float32) index.SearchResult {
+		return index.SearchResult{Meta: index.ChunkMeta{Path: path}, Score: score}
+	}
+	groups := Group([]index.SearchResult{r("a", 0.5), r("b", 0.9), r("a", 0.4), r("c", 0.5)})
+	var paths []string
+	for _, g := range groups {
+		paths = append(paths, g.Path)
+	}
+	if !slices.Equal(paths, []string{"b", "a", "c"}) {
+		t.Errorf("group order = %v", paths)
+	}
+	if len(groups[1].Hits) != 2 || groups[1].Best != 0.5 {
+		t.Errorf("group a = %+v", groups[1])
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/protocol"
+)
+
+// Client talks to a running Server over its Unix socket.
+type Client struct {
+	conn    net.Conn
+	br      *bufio.Reader
+	nextID  int
+	timeout time.Duration
+}
+
+// DefaultTimeout bounds each request made by a Client from Dial; see
+// SetTimeout.
+const DefaultTimeout = 30 * time.Second
+
+// Dial connects to the server listening at path. It fails fast when no
+// server is running so callers can fall back to in-process search.
+func Dial(path string) (*Client, error) {
+	conn, err := net.DialTimeout("unix", path, 200*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, br: bufio.NewReader(conn), timeout: DefaultTimeout}, nil
+}
+
+// SetTimeout bounds how long each request may take, from sending it to
+// receiving its response; 0 waits indefinitely.
+func (c *Client) SetTimeout(d time.Duration) {
+	c.timeout = d
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Do sends req and waits for its response.
+func (c *Client) Do(req Request) (Response, error) {
+	c.nextID++
+	req.ID = c.nextID
+	deadline := time.Time{}
+	if c.timeout > 0 {
+		deadline = time.Now().Add(c.timeout)
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return Response{}, err
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, err
+	}
+	if _, err := c.conn.Write(append(data, '\n')); err != nil {
+		return Response{}, fmt.Errorf("send: %w", err)
+	}
+	line, err := c.br.ReadBytes('\n')
+	if err != nil {
+		return Response{}, fmt.Errorf("receive: %w", err)
+	}
+	env, err := protocol.Unmarshal(line)
+	if err != nil {
+		return Response{}, fmt.Errorf("decode response: %w", err)
+	}
+	resp, err := responseFrom(env)
+	if err != nil {
+		return Response{}, fmt.Errorf("decode response: %w", err)
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+// Search runs a search on the server and converts the hits back into
+// index results so callers can render them like local ones.
+func (c *Client) Search(query string, k int, f index.Filter) ([]index.SearchResult, error) {
+	resp, err := c.Do(Request{Op: "search", Query: query, K: k, Path: f.PathPrefix, Ext: f.Exts, In: f.Dirs, Type: f.Types, MaxPerDir: f.MaxPerDir, PerFile: f.MaxPerFile})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]index.SearchResult, len(resp.Results))
+	for i, h := range resp.Results {
+		results[i] = h.Result()
+	}
+	return results, nil
+}
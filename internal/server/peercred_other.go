@@ -0,0 +1,11 @@
+//go:build !linux
+
+package server
+
+import "net"
+
+// checkPeer relies on the socket file's 0600 mode on platforms without
+// SO_PEERCRED.
+func checkPeer(net.Conn) error {
+	return nil
+}
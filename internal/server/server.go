@@ -0,0 +1,302 @@
+// Package server serves a resident sift index to editor plugins over a Unix
+// domain socket, avoiding the model load cost of spawning the CLI per query.
+//
+// The wire protocol is newline-delimited JSON: each Request line is answered
+// by exactly one line on the same connection, a protocol envelope of type
+// "search.result", "stats", "reload", "ok" or "error" carrying the
+// request's id.
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/protocol"
+)
+
+// SocketName is the socket file created inside the index directory when no
+// explicit path is given.
+const SocketName = "sift.sock"
+
+// DefaultSocketPath returns the socket path derived from an index directory.
+func DefaultSocketPath(indexDir string) string {
+	return filepath.Join(indexDir, SocketName)
+}
+
+// Backend is the subset of *index.Index the server needs.
+type Backend interface {
+	SearchFiltered(ctx context.Context, query string, k int, f index.Filter) ([]index.SearchResult, error)
+	Stats() index.Stats
+}
+
+// Request is one client request.
+type Request struct {
+	ID    int      `json:"id,omitempty"`
+	Op    string   `json:"op"` // "search", "stats", "reload", "shutdown" or "ping"
+	Query string   `json:"query,omitempty"`
+	K     int      `json:"k,omitempty"`
+	Path  string   `json:"path,omitempty"`
+	Ext   []string `json:"ext,omitempty"`
+	// In keeps only files under these absolute directories.
+	In []string `json:"in,omitempty"`
+	// Type keeps only files of these categories ("tests", "docs").
+	Type []string `json:"type,omitempty"`
+	// MaxPerDir caps results per parent directory; 0 uses the server's
+	// max-per-dir setting.
+	MaxPerDir int `json:"max_per_dir,omitempty"`
+	// PerFile is how many non-overlapping chunks of a file may be
+	// returned; 0 means one.
+	PerFile int `json:"per_file,omitempty"`
+}
+
+// Hit is one search result on the wire.
+type Hit = protocol.Hit
+
+// StatsReply is the stats payload on the wire.
+type StatsReply = protocol.Stats
+
+// ReloadReply is the reload payload on the wire: the settings applied to
+// the running server and those that only take effect after a restart.
+type ReloadReply = protocol.Reload
+
+// Response answers a Request. Exactly one of Results, Stats, Reload or
+// Error is set (ping answers with none). It travels as the protocol
+// message Envelope returns.
+type Response struct {
+	ID      int          `json:"id,omitempty"`
+	Results []Hit        `json:"results,omitempty"`
+	Stats   *StatsReply  `json:"stats,omitempty"`
+	Reload  *ReloadReply `json:"reload,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// Envelope returns the protocol message that carries r.
+func (r Response) Envelope() (protocol.Envelope, error) {
+	var env protocol.Envelope
+	var err error
+	switch {
+	case r.Error != "":
+		env, err = protocol.New(protocol.TypeError, protocol.Error{Message: r.Error})
+	case r.Results != nil:
+		env, err = protocol.New(protocol.TypeSearchResult, protocol.SearchResults{Results: r.Results})
+	case r.Stats != nil:
+		env, err = protocol.New(protocol.TypeStats, r.Stats)
+	case r.Reload != nil:
+		env, err = protocol.New(protocol.TypeReload, r.Reload)
+	default:
+		env, err = protocol.New(protocol.TypeOK, nil)
+	}
+	env.ID = r.ID
+	return env, err
+}
+
+// responseFrom is the inverse of Response.Envelope.
+func responseFrom(env protocol.Envelope) (Response, error) {
+	resp := Response{ID: env.ID}
+	var err error
+	switch env.Type {
+	case protocol.TypeError:
+		var e protocol.Error
+		err = env.Decode(&e)
+		resp.Error = e.Message
+	case protocol.TypeSearchResult:
+		var res protocol.SearchResults
+		err = env.Decode(&res)
+		resp.Results = res.Results
+		if resp.Results == nil {
+			resp.Results = []Hit{}
+		}
+	case protocol.TypeStats:
+		resp.Stats = &StatsReply{}
+		err = env.Decode(resp.Stats)
+	case protocol.TypeReload:
+		resp.Reload = &ReloadReply{}
+		err = env.Decode(resp.Reload)
+	}
+	return resp, err
+}
+
+// Server answers requests against a Backend.
+type Server struct {
+	backend  Backend
+	reload   func() (*ReloadReply, error)
+	shutdown func()
+	wg       sync.WaitGroup
+}
+
+// New returns a Server for b.
+func New(b Backend) *Server {
+	return &Server{backend: b}
+}
+
+// SetReloadFunc makes the "reload" op call f to re-read the configuration.
+// Without it, reload requests fail.
+func (s *Server) SetReloadFunc(f func() (*ReloadReply, error)) {
+	s.reload = f
+}
+
+// SetShutdownFunc makes the "shutdown" op call f, which should cancel the
+// context passed to Serve. Without it, shutdown requests fail.
+func (s *Server) SetShutdownFunc(f func()) {
+	s.shutdown = f
+}
+
+// ErrAlreadyRunning is returned by Listen when another server owns the socket.
+var ErrAlreadyRunning = errors.New("a sift server is already listening on this socket")
+
+// Listen creates a Unix socket at path readable only by the current user.
+// A leftover socket file from a crashed server is detected (nothing answers
+// on it) and removed; a live one yields ErrAlreadyRunning.
+func Listen(path string) (net.Listener, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.DialTimeout("unix", path, 200*time.Millisecond); err == nil {
+			conn.Close()
+			return nil, ErrAlreadyRunning
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket %s: %w", path, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("mkdir %s: %w", filepath.Dir(path), err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("chmod %s: %w", path, err)
+	}
+	return ln, nil
+}
+
+// Serve accepts connections on ln until ctx is cancelled, then closes the
+// listener, waits for in-flight requests, and removes the socket file.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	defer func() {
+		if addr, ok := ln.Addr().(*net.UnixAddr); ok {
+			os.Remove(addr.Name)
+		}
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				s.wg.Wait()
+				return nil
+			}
+			return fmt.Errorf("accept: %w", err)
+		}
+		if err := checkPeer(conn); err != nil {
+			conn.Close()
+			continue
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(ctx, conn)
+		}()
+	}
+}
+
+func (s *Server) serveConn(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	// Stopping the server unblocks the read; the callback goes with the
+	// connection, so closed connections leave nothing behind.
+	stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+	defer stop()
+
+	br := bufio.NewReader(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var req Request
+		var resp Response
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp.Error = "invalid request: " + err.Error()
+		} else {
+			resp = s.Handle(ctx, req)
+		}
+		env, err := resp.Envelope()
+		if err != nil {
+			env, _ = Response{ID: resp.ID, Error: err.Error()}.Envelope()
+		}
+		if err := enc.Encode(env); err != nil {
+			return
+		}
+	}
+}
+
+// Handle answers a single request. Cancelling ctx, as stopping the server
+// does, abandons a search in progress.
+func (s *Server) Handle(ctx context.Context, req Request) Response {
+	resp := Response{ID: req.ID}
+	switch req.Op {
+	case "ping":
+	case "search":
+		if index.NormalizeQuery(req.Query) == "" {
+			resp.Error = "query is required"
+			return resp
+		}
+		k := req.K
+		if k <= 0 {
+			k = 10
+		}
+		results, err := s.backend.SearchFiltered(ctx, req.Query, k, index.Filter{Exts: req.Ext, PathPrefix: req.Path, Dirs: req.In, Types: req.Type, MaxPerDir: req.MaxPerDir, MaxPerFile: req.PerFile})
+		if err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		resp.Results = make([]Hit, len(results))
+		for i, r := range results {
+			resp.Results[i] = protocol.NewHit(r)
+		}
+	case "stats":
+		st := s.backend.Stats()
+		resp.Stats = &StatsReply{
+			Chunks:      st.NumChunks,
+			Files:       st.NumFiles,
+			SizeKB:      st.IndexSizeKB,
+			LastUpdated: st.LastUpdated,
+		}
+	case "reload":
+		if s.reload == nil {
+			resp.Error = "reload is not supported by this server"
+			return resp
+		}
+		r, err := s.reload()
+		if err != nil {
+			resp.Error = err.Error()
+			return resp
+		}
+		resp.Reload = r
+	case "shutdown":
+		if s.shutdown == nil {
+			resp.Error = "shutdown is not supported by this server"
+			return resp
+		}
+		// In-flight requests, this reply included, finish before Serve returns.
+		s.shutdown()
+	default:
+		resp.Error = "unknown op: " + req.Op
+	}
+	return resp
+}
@@ -0,0 +1,296 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/protocol"
+)
+
+type mockEmbedder struct{}
+
+func (m *mockEmbedder) Embed(texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i := range texts {
+		v := make([]float32, 384)
+		v[0] = 1.0
+		vecs[i] = v
+	}
+	return vecs, nil
+}
+
+func (m *mockEmbedder) EmbedQuery(query string) ([]float32, error) {
+	v := make([]float32, 384)
+	v[0] = 1.0
+	return v, nil
+}
+
+func (m *mockEmbedder) Close() {}
+
+// startServer serves a one-file index on a temp socket and returns its path.
+func startServer(t *testing.T) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+	dir := t.TempDir()
+	idx := index.NewTestIndex(filepath.Join(dir, ".sift"), &mockEmbedder{})
+	doc := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(doc, []byte("wireguard config lives here"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.AddFile(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	sock := filepath.Join(dir, "s.sock")
+	ln, err := Listen(sock)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- New(idx).Serve(ctx, ln) }()
+	return sock, cancel, done
+}
+
+func TestClientServerRoundTrip(t *testing.T) {
+	sock, cancel, done := startServer(t)
+	defer cancel()
+
+	c, err := Dial(sock)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+
+	results, err := c.Search("wireguard", 5, index.Filter{})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(results) != 1 || filepath.Base(results[0].Meta.Path) != "notes.md" {
+		t.Fatalf("results = %+v, want notes.md", results)
+	}
+	if results[0].Meta.LineNum != 1 || results[0].Meta.Text == "" {
+		t.Errorf("hit lost provenance: %+v", results[0].Meta)
+	}
+
+	// Filters travel over the wire.
+	results, err = c.Search("wireguard", 5, index.Filter{Exts: []string{"go"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("ext filter ignored: %+v", results)
+	}
+
+	resp, err := c.Do(Request{Op: "stats"})
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if resp.Stats == nil || resp.Stats.Chunks != 1 {
+		t.Errorf("stats = %+v", resp.Stats)
+	}
+
+	if _, err := c.Do(Request{Op: "bogus"}); err == nil {
+		t.Error("expected error for unknown op")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve returned %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Errorf("socket file not cleaned up: %v", err)
+	}
+}
+
+func TestWireEnvelope(t *testing.T) {
+	sock, cancel, _ := startServer(t)
+	defer cancel()
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+
+	for _, tc := range []struct{ req, typ string }{
+		{`{"id":3,"op":"search","query":"wireguard"}`, protocol.TypeSearchResult},
+		{`{"id":4,"op":"search","query":"wireguard","ext":["go"]}`, protocol.TypeSearchResult},
+		{`{"id":5,"op":"stats"}`, protocol.TypeStats},
+		{`{"id":6,"op":"ping"}`, protocol.TypeOK},
+		{`{"id":7,"op":"bogus"}`, protocol.TypeError},
+		{`{not json`, protocol.TypeError},
+	} {
+		if _, err := conn.Write([]byte(tc.req + "\n")); err != nil {
+			t.Fatal(err)
+		}
+		line, err := br.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		env, err := protocol.Unmarshal(line)
+		if err != nil || env.Type != tc.typ {
+			t.Errorf("%s: reply %s (%v), want a %s message", tc.req, line, err, tc.typ)
+			continue
+		}
+		var req Request
+		if json.Unmarshal([]byte(tc.req), &req) == nil && env.ID != req.ID {
+			t.Errorf("%s: reply id %d", tc.req, env.ID)
+		}
+		if tc.typ == protocol.TypeSearchResult {
+			// No hits is an empty list, not a missing one.
+			var res map[string]json.RawMessage
+			if err := env.Decode(&res); err != nil || len(res["results"]) < 2 || string(res["results"]) == "null" {
+				t.Errorf("%s: data %s", tc.req, env.Data)
+			}
+		}
+	}
+}
+
+func TestServeConnectionsDoNotLeak(t *testing.T) {
+	sock, cancel, _ := startServer(t)
+	defer cancel()
+	ping := func() {
+		c, err := Dial(sock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if _, err := c.Do(Request{Op: "ping"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// settle waits for the server to finish with the connections closed,
+	// up to a count of limit goroutines.
+	settle := func(limit int) int {
+		n := runtime.NumGoroutine()
+		for deadline := time.Now().Add(2 * time.Second); n > limit && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+			n = runtime.NumGoroutine()
+		}
+		return n
+	}
+
+	ping()
+	time.Sleep(50 * time.Millisecond)
+	before := runtime.NumGoroutine()
+	for range 200 {
+		ping()
+	}
+	if after := settle(before); after > before {
+		t.Errorf("goroutines grew from %d to %d over 200 connections", before, after)
+	}
+}
+
+func TestListenDetectsLiveAndStaleSockets(t *testing.T) {
+	sock, cancel, done := startServer(t)
+	if _, err := Listen(sock); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("second Listen on live socket: got %v, want ErrAlreadyRunning", err)
+	}
+	cancel()
+	<-done
+
+	// Simulate a crashed server: the socket file outlives its listener.
+	stale := filepath.Join(t.TempDir(), "stale.sock")
+	ln, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	if _, err := os.Stat(stale); err != nil {
+		t.Fatalf("expected stale socket file to remain: %v", err)
+	}
+
+	ln2, err := Listen(stale)
+	if err != nil {
+		t.Fatalf("Listen over stale socket: %v", err)
+	}
+	ln2.Close()
+}
+
+func TestDialWithoutServerFails(t *testing.T) {
+	if _, err := Dial(filepath.Join(t.TempDir(), "none.sock")); err == nil {
+		t.Error("expected Dial to fail when no server is running")
+	}
+}
+
+func TestHandleEmptyQuery(t *testing.T) {
+	s := New(index.NewTestIndex(t.TempDir(), &mockEmbedder{}))
+	for _, q := range []string{"", " \t\n"} {
+		if resp := s.Handle(context.Background(), Request{Op: "search", Query: q}); resp.Error != "query is required" {
+			t.Errorf("search %q: error %q, want query is required", q, resp.Error)
+		}
+	}
+}
+
+func TestHandleReload(t *testing.T) {
+	s := New(index.NewTestIndex(t.TempDir(), &mockEmbedder{}))
+	if resp := s.Handle(context.Background(), Request{Op: "reload"}); resp.Error == "" {
+		t.Error("reload without a reload func should fail")
+	}
+
+	calls := 0
+	s.SetReloadFunc(func() (*ReloadReply, error) {
+		calls++
+		return &ReloadReply{Applied: []string{"max-file-kb=64"}, Restart: []string{"model-dir"}}, nil
+	})
+	resp := s.Handle(context.Background(), Request{ID: 7, Op: "reload"})
+	if resp.Error != "" || calls != 1 {
+		t.Fatalf("reload: error %q after %d calls", resp.Error, calls)
+	}
+	if resp.ID != 7 || resp.Reload == nil || resp.Reload.Applied[0] != "max-file-kb=64" || resp.Reload.Restart[0] != "model-dir" {
+		t.Errorf("reload response = %+v", resp)
+	}
+}
+
+func TestShutdownOp(t *testing.T) {
+	s := New(index.NewTestIndex(t.TempDir(), &mockEmbedder{}))
+	if resp := s.Handle(context.Background(), Request{Op: "shutdown"}); resp.Error == "" {
+		t.Error("shutdown without a shutdown func should fail")
+	}
+
+	sock := filepath.Join(t.TempDir(), "s.sock")
+	ln, err := Listen(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.SetShutdownFunc(cancel)
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, ln) }()
+
+	c, err := Dial(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err := c.Do(Request{Op: "shutdown"}); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server still running after shutdown")
+	}
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Errorf("socket left behind: %v", err)
+	}
+}
@@ -0,0 +1,18 @@
+package throttle
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadAverage reads the 1-minute load average from /proc/loadavg.
+func loadAverage() (float64, bool) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, false
+	}
+	field, _, _ := strings.Cut(string(data), " ")
+	load, err := strconv.ParseFloat(field, 64)
+	return load, err == nil
+}
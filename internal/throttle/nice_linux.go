@@ -0,0 +1,58 @@
+package throttle
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// I/O scheduling classes for ioprio_set; see ioprio_set(2).
+const (
+	ioprioWhoProcess = 1
+	ioprioClassNone  = 0 // follow the nice level
+	ioprioClassBE    = 2 // best effort, levels 0 (first) to 7 (last)
+	ioprioClassShift = 13
+)
+
+// Renice sets the nice level of every thread of the process to nice, and
+// with it the I/O priority: the lowest of the best-effort class above 0,
+// the default (derived from the nice level) at 0. Linux keeps both per
+// thread, and threads started later inherit them from the thread starting
+// them. One started meanwhile by a thread not yet reniced would keep the
+// old level, so the threads are listed again until no new one shows up.
+// Lowering the nice level again usually needs privileges, and fails
+// without.
+func Renice(nice int) error {
+	ioprio := ioprioClassNone << ioprioClassShift
+	if nice > 0 {
+		ioprio = ioprioClassBE<<ioprioClassShift | 7
+	}
+	done := make(map[int]bool)
+	for {
+		tasks, err := os.ReadDir("/proc/self/task")
+		if err != nil {
+			return fmt.Errorf("renice: %w", err)
+		}
+		fresh := false
+		for _, t := range tasks {
+			tid, err := strconv.Atoi(t.Name())
+			if err != nil || done[tid] {
+				continue
+			}
+			done[tid], fresh = true, true
+			if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, nice); err != nil {
+				if err == syscall.ESRCH {
+					continue // the thread exited
+				}
+				return fmt.Errorf("renice to %d: %w", nice, err)
+			}
+			if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(ioprio)); errno != 0 && errno != syscall.ESRCH {
+				return fmt.Errorf("set I/O priority: %w", errno)
+			}
+		}
+		if !fresh {
+			return nil
+		}
+	}
+}
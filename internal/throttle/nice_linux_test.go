@@ -0,0 +1,65 @@
+package throttle
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+func TestRenice(t *testing.T) {
+	// getpriority(2) returns 20 - nice on Linux.
+	cur, err := syscall.Getpriority(syscall.PRIO_PROCESS, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nice := min(20-cur+1, 19)
+
+	// Threads keep starting, and exiting, while Renice runs.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				var started sync.WaitGroup
+				started.Add(1)
+				go func() {
+					// Exiting locked ends the thread.
+					runtime.LockOSThread()
+					started.Done()
+				}()
+				started.Wait()
+			}
+		}()
+	}
+	err = Renice(nice)
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range tasks {
+		tid, _ := strconv.Atoi(task.Name())
+		got, err := syscall.Getpriority(syscall.PRIO_PROCESS, tid)
+		if err == syscall.ESRCH {
+			continue
+		}
+		if err != nil || 20-got != nice {
+			t.Errorf("thread %d: nice %d, %v; want %d", tid, 20-got, err, nice)
+		}
+	}
+}
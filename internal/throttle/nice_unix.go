@@ -0,0 +1,17 @@
+//go:build !linux && !windows
+
+package throttle
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Renice sets the nice level of the process to nice. Lowering it again
+// usually needs privileges, and fails without.
+func Renice(nice int) error {
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, nice); err != nil {
+		return fmt.Errorf("renice to %d: %w", nice, err)
+	}
+	return nil
+}
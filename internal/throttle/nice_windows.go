@@ -0,0 +1,12 @@
+package throttle
+
+import "errors"
+
+// Renice is unsupported on Windows, where sift leaves its priority class
+// alone; a nice level of 0, the default, needs no change.
+func Renice(nice int) error {
+	if nice == 0 {
+		return nil
+	}
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,181 @@
+// Package throttle keeps background indexing out of the way of the rest
+// of the machine. In low-priority mode (--low-priority) sift runs at a
+// raised nice level and lowered I/O priority where the platform allows,
+// embeds on one ONNX thread, one batch at a time, and pauses between
+// files while the system is busy, going by its load average. Limits
+// derives those settings from the mode; a Throttle applies the pauses.
+// Every limit can change while sift runs, on a configuration reload.
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Mode is how much of the machine indexing may take.
+type Mode string
+
+const (
+	// Normal takes what the threads and background-sessions settings
+	// allow.
+	Normal Mode = "normal"
+	// Low yields to everything else; see Derive.
+	Low Mode = "low"
+)
+
+// The limits of Low.
+const (
+	// LowNice is the nice level low-priority processes run at.
+	LowNice = 10
+	// LowBusyLoad is the 1-minute load average per CPU from which the
+	// system counts as busy.
+	LowBusyLoad = 0.7
+	// LowPause is how long a low-priority run pauses between two files
+	// while the system is busy.
+	LowPause = 250 * time.Millisecond
+)
+
+// Limits are the resources indexing may use.
+type Limits struct {
+	Mode Mode
+	// Nice is the nice level the process runs at, 0 being the default.
+	// Above 0, its I/O is also made to yield to other processes'.
+	Nice int
+	// Threads is the ONNX intra-op thread count of each inference
+	// session; 0 picks one from the CPU count.
+	Threads int
+	// BackgroundSessions caps the inference sessions background
+	// indexing holds at once; 0 means all.
+	BackgroundSessions int
+	// BusyLoad is the 1-minute load average per CPU from which the
+	// system counts as busy, and Pause how long indexing pauses between
+	// files while it is. Either 0 means never.
+	BusyLoad float64
+	Pause    time.Duration
+}
+
+// Derive returns the limits of low-priority mode if low is set, else of
+// normal mode with the given threads and background-sessions settings.
+func Derive(low bool, threads, backgroundSessions int) Limits {
+	if !low {
+		return Limits{Mode: Normal, Threads: threads, BackgroundSessions: backgroundSessions}
+	}
+	return Limits{
+		Mode:               Low,
+		Nice:               LowNice,
+		Threads:            1,
+		BackgroundSessions: 1,
+		BusyLoad:           LowBusyLoad,
+		Pause:              LowPause,
+	}
+}
+
+// String describes l in a line: "low (nice 10, 1 ONNX thread, 1 batch at
+// a time, 250ms pauses between files above load 0.70 per CPU)".
+func (l Limits) String() string {
+	if l.Mode != Low {
+		return string(l.Mode)
+	}
+	parts := []string{fmt.Sprintf("nice %d", l.Nice)}
+	if l.Threads == 1 {
+		parts = append(parts, "1 ONNX thread")
+	} else if l.Threads > 1 {
+		parts = append(parts, fmt.Sprintf("%d ONNX threads", l.Threads))
+	}
+	if l.BackgroundSessions > 0 {
+		parts = append(parts, fmt.Sprintf("%d batch at a time", l.BackgroundSessions))
+	}
+	if l.BusyLoad > 0 && l.Pause > 0 {
+		parts = append(parts, fmt.Sprintf("%s pauses between files above load %.2f per CPU", l.Pause, l.BusyLoad))
+	}
+	return fmt.Sprintf("%s (%s)", l.Mode, strings.Join(parts, ", "))
+}
+
+// Stats counts a Throttle's pauses.
+type Stats struct {
+	Pauses int
+	Paused time.Duration
+}
+
+// Throttle paces indexing by its Limits. Its methods are safe for
+// concurrent use and do nothing on a nil Throttle.
+type Throttle struct {
+	mu     sync.Mutex
+	limits Limits
+	// load returns the 1-minute load average, and false where it is
+	// unknown; tests replace it.
+	load func() (float64, bool)
+	cpus int
+
+	pauses atomic.Int64
+	paused atomic.Int64 // nanoseconds
+}
+
+// New returns a Throttle pacing by l.
+func New(l Limits) *Throttle {
+	return &Throttle{limits: l, load: loadAverage, cpus: runtime.NumCPU()}
+}
+
+// Set replaces t's limits; a pause under way finishes.
+func (t *Throttle) Set(l Limits) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.limits = l
+}
+
+// Limits returns t's limits.
+func (t *Throttle) Limits() Limits {
+	if t == nil {
+		return Limits{Mode: Normal}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limits
+}
+
+// Busy reports whether the system is busy by t's limits: always false
+// when they never pause, or where the load average is unknown.
+func (t *Throttle) Busy() bool {
+	l := t.Limits()
+	if l.BusyLoad <= 0 || l.Pause <= 0 {
+		return false
+	}
+	load, ok := t.load()
+	return ok && load/float64(max(t.cpus, 1)) >= l.BusyLoad
+}
+
+// Wait is called between two files: while the system is Busy, it pauses
+// for the limits' Pause, or until ctx is done. It returns how long it
+// paused.
+func (t *Throttle) Wait(ctx context.Context) time.Duration {
+	if t == nil || !t.Busy() {
+		return 0
+	}
+	start := time.Now()
+	timer := time.NewTimer(t.Limits().Pause)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+	d := time.Since(start)
+	t.pauses.Add(1)
+	t.paused.Add(int64(d))
+	return d
+}
+
+// Stats returns the pauses t has made.
+func (t *Throttle) Stats() Stats {
+	if t == nil {
+		return Stats{}
+	}
+	return Stats{Pauses: int(t.pauses.Load()), Paused: time.Duration(t.paused.Load())}
+}
@@ -0,0 +1,97 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDerive(t *testing.T) {
+	for _, tc := range []struct {
+		low                 bool
+		threads, background int
+		want                Limits
+	}{
+		{false, 0, 0, Limits{Mode: Normal}},
+		{false, 4, 2, Limits{Mode: Normal, Threads: 4, BackgroundSessions: 2}},
+		// Low overrides the settings it limits.
+		{true, 4, 2, Limits{Mode: Low, Nice: LowNice, Threads: 1, BackgroundSessions: 1, BusyLoad: LowBusyLoad, Pause: LowPause}},
+		{true, 0, 0, Limits{Mode: Low, Nice: LowNice, Threads: 1, BackgroundSessions: 1, BusyLoad: LowBusyLoad, Pause: LowPause}},
+	} {
+		if got := Derive(tc.low, tc.threads, tc.background); got != tc.want {
+			t.Errorf("Derive(%v, %d, %d) = %+v, want %+v", tc.low, tc.threads, tc.background, got, tc.want)
+		}
+	}
+
+	if s := Derive(false, 4, 0).String(); s != "normal" {
+		t.Errorf("normal String = %q", s)
+	}
+	want := "low (nice 10, 1 ONNX thread, 1 batch at a time, 250ms pauses between files above load 0.70 per CPU)"
+	if s := Derive(true, 0, 0).String(); s != want {
+		t.Errorf("low String = %q, want %q", s, want)
+	}
+}
+
+// fakeLoad returns a Throttle on 4 CPUs whose load average is *load.
+func fakeLoad(l Limits, load *float64) *Throttle {
+	th := New(l)
+	th.cpus = 4
+	th.load = func() (float64, bool) { return *load, true }
+	return th
+}
+
+func TestThrottle_Wait(t *testing.T) {
+	load := 1.0
+	low := Derive(true, 0, 0)
+	low.Pause = 5 * time.Millisecond
+	th := fakeLoad(low, &load)
+	ctx := context.Background()
+
+	if d := th.Wait(ctx); d != 0 { // 0.25 per CPU: not busy
+		t.Fatalf("Wait paused %v at load 1 on 4 CPUs", d)
+	}
+	if s := th.Stats(); s.Pauses != 0 {
+		t.Fatalf("paused at load 1 on 4 CPUs: %+v", s)
+	}
+	load = 3.2 // 0.8 per CPU
+	if d := th.Wait(ctx); d < 5*time.Millisecond {
+		t.Errorf("Wait paused %v at load 3.2, want 5ms", d)
+	}
+	th.Wait(ctx)
+	if s := th.Stats(); s.Pauses != 2 || s.Paused < 10*time.Millisecond {
+		t.Errorf("stats at load 3.2 = %+v, want 2 pauses of 5ms", s)
+	}
+
+	// Normal mode never pauses, and the change applies at once.
+	th.Set(Derive(false, 0, 0))
+	th.Wait(ctx)
+	if s := th.Stats(); s.Pauses != 2 {
+		t.Errorf("paused in normal mode: %+v", s)
+	}
+
+	// Cancelling cuts a pause short.
+	low.Pause = time.Hour
+	th.Set(low)
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	done := make(chan struct{})
+	go func() { th.Wait(cctx); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait ignored its cancelled context")
+	}
+
+	// An unknown load average is never busy.
+	th.load = func() (float64, bool) { return 0, false }
+	if th.Busy() {
+		t.Error("busy without a load average")
+	}
+
+	var none *Throttle
+	none.Wait(ctx)
+	none.Set(low)
+	if none.Limits().Mode != Normal || none.Stats() != (Stats{}) {
+		t.Error("nil Throttle is not normal and idle")
+	}
+}
@@ -0,0 +1,145 @@
+package tui
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tejas242/sift/internal/index"
+)
+
+// Pin is a result pinned during a session. Pins stay listed above the
+// results, whatever is searched next, until unpinned; Model.Pins returns
+// them when the program exits.
+type Pin struct {
+	ID   string // chunk ID; a chunk is pinned at most once
+	Path string
+	Line int
+	Text string
+}
+
+// maxPinRows is how many pins the pinned list shows at once; it scrolls
+// to keep the pin cursor in view.
+const maxPinRows = 5
+
+// Pins returns the pinned results, in the order they were pinned.
+func (m Model) Pins() []Pin {
+	return m.pins
+}
+
+// pinned reports whether the chunk with id is pinned.
+func (m Model) pinned(id string) bool {
+	return slices.ContainsFunc(m.pins, func(p Pin) bool { return p.ID == id })
+}
+
+// togglePin pins the result under the cursor, or unpins it if it is
+// pinned; with the pinned list focused, it unpins the pin under the pin
+// cursor.
+func (m *Model) togglePin() {
+	if m.pinFocus {
+		if m.pinCursor < len(m.pins) {
+			m.pins = slices.Delete(m.pins, m.pinCursor, m.pinCursor+1)
+		}
+		if len(m.pins) == 0 {
+			m.pinFocus = false
+		}
+		m.movePinCursor(0)
+		return
+	}
+	if m.cursor >= len(m.results) {
+		return
+	}
+	meta := m.results[m.cursor].Meta
+	if i := slices.IndexFunc(m.pins, func(p Pin) bool { return p.ID == meta.ID }); i >= 0 {
+		m.pins = slices.Delete(m.pins, i, i+1)
+		m.movePinCursor(0)
+		return
+	}
+	m.pins = append(m.pins, pinOf(m.results[m.cursor]))
+}
+
+// movePinCursor moves the pin cursor by delta, within the pins, and
+// scrolls the pinned list to keep it in view.
+func (m *Model) movePinCursor(delta int) {
+	m.pinCursor = clamp(m.pinCursor+delta, 0, max(len(m.pins)-1, 0))
+	if m.pinCursor < m.pinOffset {
+		m.pinOffset = m.pinCursor
+	}
+	if m.pinCursor >= m.pinOffset+maxPinRows {
+		m.pinOffset = m.pinCursor - maxPinRows + 1
+	}
+	m.pinOffset = clamp(m.pinOffset, 0, max(len(m.pins)-maxPinRows, 0))
+}
+
+// pinRows is how many lines renderPins writes.
+func (m Model) pinRows() int {
+	if len(m.pins) == 0 {
+		return 0
+	}
+	return 2 + min(len(m.pins), maxPinRows) // title, pins, divider
+}
+
+// renderPins writes the pinned list, one line per pin, with the pins
+// scrolled out of view counted in its title.
+func (m Model) renderPins(b *strings.Builder) {
+	if len(m.pins) == 0 {
+		return
+	}
+	title := fmt.Sprintf("  pinned (%d)", len(m.pins))
+	if above, below := m.pinOffset, len(m.pins)-m.pinOffset-maxPinRows; above > 0 || below > 0 {
+		title += fmt.Sprintf("  ↑%d ↓%d", above, max(below, 0))
+	}
+	if m.pinFocus {
+		fmt.Fprintln(b, sAccent.Render(title))
+	} else {
+		fmt.Fprintln(b, sMuted.Render(title))
+	}
+	end := min(m.pinOffset+maxPinRows, len(m.pins))
+	for i := m.pinOffset; i < end; i++ {
+		p := m.pins[i]
+		loc := fmt.Sprintf("%s:%d", m.displayPath(p.Path), p.Line)
+		snippet := strings.Join(strings.Fields(p.Text), " ")
+		if room := m.width - len(loc) - 8; room < 10 {
+			snippet = ""
+		} else if len(snippet) > room {
+			snippet = snippet[:room-1] + "…"
+		}
+		line := "  ◆ " + sPath.Render(loc) + "  " + sSnip.Render(snippet)
+		if m.pinFocus && i == m.pinCursor {
+			line = sSel.Render("  ◆ " + sPath.Render(loc) + "  " + sSnip.Render(snippet))
+		}
+		fmt.Fprintln(b, line)
+	}
+	fmt.Fprintln(b, "  "+sDivider.Render(strings.Repeat("─", clamp(m.width-2, 10, 200))))
+}
+
+// pinOf returns the pin of a result, as pinning it would record it.
+func pinOf(r index.SearchResult) Pin {
+	return Pin{ID: r.Meta.ID, Path: r.Meta.Path, Line: r.Meta.LineNum, Text: r.Meta.Text}
+}
+
+// FormatPins renders pins for export, one per line: path:line, or with
+// markdown a Markdown list item that also quotes the pin's first line of
+// text. Paths below cwd are made relative to it.
+func FormatPins(pins []Pin, markdown bool, cwd string) string {
+	var b strings.Builder
+	for _, p := range pins {
+		path := p.Path
+		if rel, err := filepath.Rel(cwd, p.Path); err == nil && filepath.IsLocal(rel) {
+			path = rel
+		}
+		loc := fmt.Sprintf("%s:%d", filepath.ToSlash(path), p.Line)
+		if !markdown {
+			fmt.Fprintln(&b, loc)
+			continue
+		}
+		first, _, _ := strings.Cut(strings.TrimSpace(p.Text), "\n")
+		if first = strings.TrimSpace(first); first != "" {
+			fmt.Fprintf(&b, "- `%s` — %s\n", loc, first)
+		} else {
+			fmt.Fprintf(&b, "- `%s`\n", loc)
+		}
+	}
+	return b.String()
+}
@@ -15,16 +15,24 @@
 package tui
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/tejas242/sift/internal/format"
 	"github.com/tejas242/sift/internal/index"
 )
 
@@ -62,6 +70,36 @@ var (
 			Bold(true)
 )
 
+// SetColor switches every style, including score and selection highlights,
+// to plain text when enabled is false (NO_COLOR, --no-color). When enabled,
+// lipgloss keeps auto-detecting the terminal's capabilities.
+func SetColor(enabled bool) {
+	if !enabled {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
+// absPaths shows result paths whole instead of relative to the working
+// directory; see SetAbsPaths.
+var absPaths bool
+
+// SetAbsPaths shows result and pin paths absolute (--abs) rather than
+// relative to the working directory when they lie below it.
+func SetAbsPaths(enabled bool) {
+	absPaths = enabled
+}
+
+// displayPath returns path as results show it: relative to the working
+// directory when below it, unless SetAbsPaths, and slash-separated.
+func (m Model) displayPath(path string) string {
+	if !absPaths && m.cwd != "" {
+		if rel, err := filepath.Rel(m.cwd, path); err == nil && filepath.IsLocal(rel) {
+			path = rel
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
 // ── Extension → icon map ─────────────────────────────────────────────────────
 
 var extIcon = map[string]string{
@@ -98,12 +136,20 @@ const (
 )
 
 type (
-	searchResultMsg []index.SearchResult
-	errMsg          struct{ err error }
-	debounceMsg     struct {
+	// searchResultMsg is the outcome of the search started for debounceID
+	// id.
+	searchResultMsg struct {
+		id      int
+		results []index.SearchResult
+		err     error
+	}
+	errMsg      struct{ err error }
+	debounceMsg struct {
 		query string
 		id    int
 	}
+	// reindexedMsg reports re-indexing the stale files among the results.
+	reindexedMsg struct{ err error }
 )
 
 // ── Model ─────────────────────────────────────────────────────────────────────
@@ -123,6 +169,18 @@ type Model struct {
 	stats      *index.Stats
 	debounceID int
 	lastQuery  string
+	// cancelSearch abandons the search in flight, once a newer query
+	// supersedes it; nil when none is.
+	cancelSearch context.CancelFunc
+	// pins are the pinned results (see pins.go); with pinFocus set, the
+	// arrow keys move pinCursor through them instead of the results.
+	pins                 []Pin
+	pinFocus             bool
+	pinCursor, pinOffset int
+	// cwd is the working directory, which ctrl+s scopes searches to when
+	// scoped is set; "" if it could not be determined.
+	cwd    string
+	scoped bool
 }
 
 // New creates a new TUI model backed by the given index.
@@ -136,10 +194,12 @@ func New(idx *index.Index) Model {
 	ti.Prompt = "❯ "
 	ti.TextStyle = lipgloss.NewStyle().Foreground(colorText)
 
+	cwd, _ := os.Getwd()
 	return Model{
 		idx:   idx,
 		input: ti,
 		mode:  modeSearch,
+		cwd:   cwd,
 	}
 }
 
@@ -165,6 +225,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c", "ctrl+q":
+			m.stopSearch()
 			return m, tea.Quit
 
 		case "ctrl+i", "tab":
@@ -180,26 +241,68 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, nil
 
+		case "ctrl+s":
+			if m.mode != modeSearch || m.cwd == "" {
+				return m, nil
+			}
+			m.scoped = !m.scoped
+			if index.NormalizeQuery(m.input.Value()) == "" {
+				return m, nil
+			}
+			m.debounceID++
+			return m, m.startSearch(m.input.Value())
+
 		case "esc":
 			m.mode = modeSearch
 			m.input.Focus()
 			m.stats = nil
 			m.err = nil
+			m.pinFocus = false
+			return m, nil
+
+		case "ctrl+t":
+			if m.mode == modeSearch {
+				m.togglePin()
+			}
+			return m, nil
+
+		case "ctrl+r":
+			stale := m.staleFiles()
+			if m.mode != modeSearch || len(stale) == 0 {
+				return m, nil
+			}
+			m.stopSearch()
+			m.searching = true
+			return m, reindexCmd(m.idx, stale)
+
+		case "shift+tab":
+			if m.mode == modeSearch && len(m.pins) > 0 {
+				m.pinFocus = !m.pinFocus
+				m.movePinCursor(0)
+			}
 			return m, nil
 
 		case "up", "ctrl+p":
-			if m.cursor > 0 {
+			if m.pinFocus {
+				m.movePinCursor(-1)
+			} else if m.cursor > 0 {
 				m.cursor--
 			}
 			return m, nil
 
 		case "down", "ctrl+n":
-			if m.cursor < len(m.results)-1 {
+			if m.pinFocus {
+				m.movePinCursor(1)
+			} else if m.cursor < len(m.results)-1 {
 				m.cursor++
 			}
 			return m, nil
 
 		case "enter":
+			if m.mode == modeSearch && m.pinFocus && len(m.pins) > 0 {
+				p := m.pins[m.pinCursor]
+				return m, openInEditor(p.Path, p.Line)
+			}
 			if m.mode == modeSearch && len(m.results) > 0 {
 				res := m.results[m.cursor].Meta
 				return m, openInEditor(res.Path, res.LineNum)
@@ -209,24 +312,45 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case debounceMsg:
 		if msg.id == m.debounceID && msg.query == m.input.Value() {
-			if strings.TrimSpace(msg.query) == "" {
+			if index.NormalizeQuery(msg.query) == "" {
 				m.searching = false
 				m.results = nil
 				return m, nil
 			}
-			m.searching = true
-			m.lastQuery = msg.query
-			return m, searchCmd(m.idx, msg.query)
+			return m, m.startSearch(msg.query)
 		}
 		return m, nil
 
 	case searchResultMsg:
+		if msg.id != m.debounceID {
+			return m, nil // superseded, and cancelled, by a newer query
+		}
+		m.stopSearch()
 		m.searching = false
-		m.results = []index.SearchResult(msg)
-		m.cursor = 0
-		m.err = nil
+		switch {
+		case errors.Is(msg.err, index.ErrEmptyQuery):
+			m.results = nil
+		case msg.err != nil:
+			m.err = msg.err
+		default:
+			m.results = msg.results
+			m.cursor = 0
+			m.err = nil
+		}
 		return m, nil
 
+	case reindexedMsg:
+		m.searching = false
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		if index.NormalizeQuery(m.input.Value()) == "" {
+			return m, nil
+		}
+		m.debounceID++
+		return m, m.startSearch(m.input.Value())
+
 	case errMsg:
 		m.searching = false
 		m.err = msg.err
@@ -240,6 +364,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.input, cmd = m.input.Update(msg)
 		if m.input.Value() != prevVal {
 			m.debounceID++
+			m.stopSearch()
 			id := m.debounceID
 			q := m.input.Value()
 			return m, tea.Batch(cmd, debounceCmd(q, id, 280*time.Millisecond))
@@ -269,14 +394,22 @@ func (m Model) searchView() string {
 
 	// ── Header ───────────────────────────────────────────────────────────────
 	left := "  " + sTitle.Render("sift") + "  " + sMuted.Render("semantic file search")
+	if m.scoped {
+		left += "  " + sBadge.Render(m.scopeBadge())
+	}
 	s := m.idx.Stats()
-	right := sDim.Render(fmt.Sprintf("%d chunks · %d files", s.NumChunks, s.NumFiles))
+	info := format.Count(s.NumChunks) + " chunks · " + format.Count(s.NumFiles) + " files"
+	if !s.LastUpdated.IsZero() {
+		info += " · updated " + format.AgoShort(time.Since(s.LastUpdated))
+	}
+	right := sDim.Render(info)
 	header := padBetween(left, right, w)
 	fmt.Fprintln(&b, header)
 
 	// ── Search bar ───────────────────────────────────────────────────────────
 	fmt.Fprintln(&b, "  "+m.input.View())
 	fmt.Fprintln(&b, "  "+divider)
+	m.renderPins(&b)
 
 	// ── Body ──────────────────────────────────────────────────────────────────
 	if m.err != nil {
@@ -291,11 +424,15 @@ func (m Model) searchView() string {
 		fmt.Fprintln(&b, sDim.Render("  Natural language works: ")+sMuted.Render("\"how does auth work\""))
 	} else if len(m.results) == 0 {
 		fmt.Fprintln(&b, "")
-		fmt.Fprintln(&b, sMuted.Render("  no results for ")+sAccent.Render("\""+m.lastQuery+"\""))
+		none := sMuted.Render("  no results for ") + sAccent.Render("\""+m.lastQuery+"\"")
+		if m.scoped {
+			none += sMuted.Render(" " + m.scopeBadge())
+		}
+		fmt.Fprintln(&b, none)
 		fmt.Fprintln(&b, sDim.Render("  try rephrasing or indexing more files"))
 	} else {
 		// Result list
-		bodyHeight := m.height - 7 // header+input+div+statusbar+padding
+		bodyHeight := m.height - 7 - m.pinRows() // header+input+div+pins+statusbar+padding
 		m.renderResults(&b, bodyHeight)
 	}
 
@@ -320,10 +457,14 @@ func (m *Model) renderResults(b *strings.Builder, maxRows int) {
 			break
 		}
 
-		dir := filepath.Dir(r.Meta.Path)
-		base := filepath.Base(r.Meta.Path)
+		shown := m.displayPath(r.Meta.Path)
+		dir := ""
+		if d := path.Dir(shown); d != "." {
+			dir = strings.TrimSuffix(d, "/") + "/"
+		}
+		base := path.Base(shown)
 		icon := fileIcon(r.Meta.Path)
-		score := fmt.Sprintf("%.2f", r.Score)
+		score := fmt.Sprintf("%.2f", r.ThresholdScore()) // 0–1 when calibrated
 
 		snippet := r.Meta.Text
 		maxSnip := clamp(m.width-8, 20, 120)
@@ -334,17 +475,24 @@ func (m *Model) renderResults(b *strings.Builder, maxRows int) {
 		snippet = strings.Join(strings.Fields(snippet), " ")
 
 		filename := fmt.Sprintf("%s:%d", base, r.Meta.LineNum)
-		pathStr := sDir.Render(dir+"/") + sPath.Render(filename)
-		line1 := fmt.Sprintf("  %s  %s%s", sScore.Render(score), icon, pathStr)
+		if r.Stale {
+			filename += " " + staleGlyph
+		}
+		pathStr := sDir.Render(dir) + sPath.Render(filename)
+		mark := " "
+		if m.pinned(r.Meta.ID) {
+			mark = "◆"
+		}
+		line1 := fmt.Sprintf("  %s %s%s%s", sScore.Render(score), sAccent.Render(mark), icon, pathStr)
 		line2 := fmt.Sprintf("  %s  %s", sDim.Render("    "), sSnip.Render(snippet))
 
 		if i == m.cursor {
 			// Pad to width for full-row highlight
-			raw1 := stripStyle(score) + "  " + icon + dir + "/" + filename
+			raw1 := stripStyle(score) + " " + mark + icon + dir + filename
 			raw2 := "       " + snippet
 			pad1 := clamp(m.width-len(raw1)-3, 0, m.width)
 			pad2 := clamp(m.width-len(raw2)-3, 0, m.width)
-			line1 = sSel.Render("  " + sScore.Render(score) + "  " + icon + sDir.Render(dir+"/") + sPath.Render(filename) + strings.Repeat(" ", pad1))
+			line1 = sSel.Render("  " + sScore.Render(score) + " " + sAccent.Render(mark) + icon + sDir.Render(dir) + sPath.Render(filename) + strings.Repeat(" ", pad1))
 			line2 = sSel.Render("  " + "       " + sSnip.Render(snippet) + strings.Repeat(" ", pad2))
 		}
 
@@ -360,13 +508,20 @@ func (m *Model) renderStatusBar(b *strings.Builder) {
 		if len(m.results) != 1 {
 			left += sGreen.Render("s")
 		}
+		if n := len(m.staleFiles()); n > 0 {
+			left += sErr.Render(fmt.Sprintf("  %s %d modified since indexed (^r reindex)", staleGlyph, n))
+		}
 	} else if m.err != nil {
 		left = "  " + sErr.Render(m.err.Error())
 	} else {
 		left = sDim.Render("  no results")
 	}
 
-	right := sHint.Render("  ^i info  esc clear  ↑↓ nav  enter open  ^q quit  ")
+	hints := "  ^i info  ^s scope  ^t pin  esc clear  ↑↓ nav  enter open  ^q quit  "
+	if len(m.pins) > 0 {
+		hints = "  ^i info  ^s scope  ^t pin  ⇧tab pins  ↑↓ nav  enter open  ^q quit  "
+	}
+	right := sHint.Render(hints)
 	fmt.Fprint(b, padBetween(left, right, m.width))
 }
 
@@ -386,10 +541,10 @@ func (m Model) statsView() string {
 		}
 		row("chunks indexed", sAccent.Render(fmt.Sprintf("%d", s.NumChunks)))
 		row("files indexed", sAccent.Render(fmt.Sprintf("%d", s.NumFiles)))
-		row("index size on disk", sAccent.Render(fmt.Sprintf("%d KB", s.IndexSizeKB)))
+		row("index size on disk", sAccent.Render(format.Bytes(s.SizeBytes)))
 		if !s.LastUpdated.IsZero() {
-			ago := time.Since(s.LastUpdated).Round(time.Second)
-			row("last updated", sMuted.Render(s.LastUpdated.Format("2006-01-02 15:04")+" ("+ago.String()+" ago)"))
+			ago := format.Ago(time.Since(s.LastUpdated))
+			row("last updated", sMuted.Render(ago+" ("+s.LastUpdated.Format("2006-01-02 15:04")+")"))
 		}
 		row("embedding model", sMuted.Render("BGE-small-en-v1.5 (384-dim)"))
 		row("hnsw parameters", sMuted.Render("M=16  ef_build=200  ef_search=50"))
@@ -410,52 +565,177 @@ func debounceCmd(query string, id int, delay time.Duration) tea.Cmd {
 	}
 }
 
-func searchCmd(idx *index.Index, query string) tea.Cmd {
+// filter returns the filter searches run with: scoped to the working
+// directory after ctrl+s.
+func (m Model) filter() index.Filter {
+	if !m.scoped {
+		return index.Filter{}
+	}
+	return index.Filter{Dirs: []string{m.cwd}}
+}
+
+// scopeBadge names the directory searches are scoped to.
+func (m Model) scopeBadge() string {
+	return "in " + strings.TrimSuffix(filepath.ToSlash(filepath.Base(m.cwd)), "/") + "/"
+}
+
+// startSearch abandons the search in flight, if any, and starts one for
+// query, tagged with debounceID so its results are dropped if a newer
+// query supersedes it meanwhile. "type:" terms in query filter on file
+// categories rather than being searched for.
+func (m *Model) startSearch(query string) tea.Cmd {
+	m.stopSearch()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelSearch = cancel
+	m.searching = true
+	m.lastQuery = query
+	f := m.filter()
+	query, f.Types = index.SplitTypes(query)
+	return searchCmd(ctx, m.idx, m.debounceID, query, f)
+}
+
+// stopSearch cancels the search in flight, if any.
+func (m *Model) stopSearch() {
+	if m.cancelSearch != nil {
+		m.cancelSearch()
+		m.cancelSearch = nil
+	}
+}
+
+func searchCmd(ctx context.Context, idx *index.Index, id int, query string, f index.Filter) tea.Cmd {
 	return func() tea.Msg {
-		results, err := idx.Search(query, 10)
-		if err != nil {
-			return errMsg{err}
+		results, err := idx.SearchFiltered(ctx, query, 10, f)
+		index.MarkStale(results)
+		return searchResultMsg{id: id, results: results, err: err}
+	}
+}
+
+// staleGlyph marks a result whose file was modified since it was indexed.
+const staleGlyph = "⚠"
+
+// staleFiles returns the files of the results marked stale, once each.
+func (m Model) staleFiles() []string {
+	var stale []string
+	for _, r := range m.results {
+		if r.Stale && !slices.Contains(stale, r.Meta.Path) {
+			stale = append(stale, r.Meta.Path)
+		}
+	}
+	return stale
+}
+
+// reindexCmd re-indexes the stale files and saves the index, so the
+// search run next finds them as they are now.
+func reindexCmd(idx *index.Index, paths []string) tea.Cmd {
+	return func() tea.Msg {
+		for _, p := range paths {
+			if _, err := idx.ReindexFile(context.Background(), p); err != nil {
+				return reindexedMsg{err: err}
+			}
 		}
-		return searchResultMsg(results)
+		return reindexedMsg{err: idx.Flush()}
 	}
 }
 
+// editorCommand is the editor-command setting split into words, or nil to
+// pick a command for $EDITOR.
+var editorCommand []string
+
+// SetEditorCommand sets the command line results are opened with, split
+// into words holding {path}, {line} and {col} placeholders (see
+// config.ParseEditorCommand). nil, the default, runs $EDITOR, or else the
+// first of editorFallbacks installed, with the arguments editorArgs knows
+// for it.
+func SetEditorCommand(argv []string) {
+	editorCommand = argv
+}
+
 func openInEditor(path string, lineNum int) tea.Cmd {
+	c := editorCmd(path, lineNum)
+	return tea.ExecProcess(c, func(err error) tea.Msg {
+		if err != nil {
+			return errMsg{err}
+		}
+		return nil
+	})
+}
+
+// editorCmd returns the command that opens path at lineNum. Results start
+// at the beginning of a line, so the column is 1.
+func editorCmd(path string, lineNum int) *exec.Cmd {
+	if len(editorCommand) > 0 {
+		argv := renderEditorCommand(editorCommand, path, lineNum, 1)
+		return exec.Command(argv[0], argv[1:]...)
+	}
+
 	editor := os.Getenv("EDITOR")
 	if editor == "" {
 		// Try common editors in order.
-		for _, e := range []string{"nvim", "vim", "nano", "vi"} {
+		for _, e := range editorFallbacks(runtime.GOOS) {
 			if _, err := exec.LookPath(e); err == nil {
 				editor = e
 				break
 			}
 		}
 	}
+	return exec.Command(editor, editorArgs(editor, path, lineNum)...)
+}
+
+// renderEditorCommand fills in the placeholders of an editor-command
+// template's words. Each word stays one argument, whatever the path
+// holds: nothing is run through a shell.
+func renderEditorCommand(argv []string, path string, lineNum, col int) []string {
+	r := strings.NewReplacer("{path}", path, "{line}", strconv.Itoa(max(lineNum, 1)), "{col}", strconv.Itoa(col))
+	out := make([]string, len(argv))
+	for i, w := range argv {
+		out[i] = r.Replace(w)
+	}
+	return out
+}
+
+// editorFallbacks lists the editors tried, in order, when $EDITOR is unset.
+func editorFallbacks(goos string) []string {
+	if goos == "windows" {
+		return []string{"code", "notepad"}
+	}
+	return []string{"nvim", "vim", "nano", "vi"}
+}
+
+// jetbrainsEditors are the launchers of JetBrains IDEs, which all take
+// --line; on Linux they are shell scripts (idea.sh).
+var jetbrainsEditors = map[string]bool{
+	"idea": true, "idea64": true, "goland": true, "goland64": true,
+	"pycharm": true, "pycharm64": true, "charm": true, "webstorm": true,
+	"webstorm64": true, "clion": true, "clion64": true, "phpstorm": true,
+	"phpstorm64": true, "rubymine": true, "rubymine64": true, "rider": true,
+	"rider64": true, "rustrover": true, "rustrover64": true, "studio": true,
+	"studio64": true,
+}
 
+// editorArgs returns the arguments that open path at lineNum in editor,
+// for the editors that accept a line number.
+func editorArgs(editor, path string, lineNum int) []string {
 	args := []string{}
-	baseEditor := filepath.Base(editor)
-	if baseEditor == "nvim" || baseEditor == "vim" || baseEditor == "vi" || baseEditor == "nano" {
-		if lineNum > 0 {
+	baseEditor := strings.TrimSuffix(strings.ToLower(filepath.Base(editor)), ".exe")
+	if lineNum > 0 {
+		switch {
+		case baseEditor == "nvim" || baseEditor == "vim" || baseEditor == "vi" || baseEditor == "nano" ||
+			baseEditor == "kak" || baseEditor == "emacs" || baseEditor == "emacsclient":
 			args = append(args, fmt.Sprintf("+%d", lineNum))
-		}
-	} else if baseEditor == "code" {
-		if lineNum > 0 {
+		case baseEditor == "code":
 			args = append(args, "--goto", fmt.Sprintf("%s:%d", path, lineNum))
 			path = "" // Already included in --goto
+		case baseEditor == "hx" || baseEditor == "helix":
+			path = fmt.Sprintf("%s:%d", path, lineNum)
+		case jetbrainsEditors[strings.TrimSuffix(baseEditor, ".sh")]:
+			args = append(args, "--line", strconv.Itoa(lineNum))
 		}
 	}
 
 	if path != "" {
 		args = append(args, path)
 	}
-
-	c := exec.Command(editor, args...)
-	return tea.ExecProcess(c, func(err error) tea.Msg {
-		if err != nil {
-			return errMsg{err}
-		}
-		return nil
-	})
+	return args
 }
 
 // ── Helpers ───────────────────────────────────────────────────────────────────
@@ -1,6 +1,22 @@
 package tui
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/tejas242/sift/internal/config"
+	"github.com/tejas242/sift/internal/index"
+)
 
 func TestStripStyle(t *testing.T) {
 	cases := []struct {
@@ -39,3 +55,388 @@ func TestVisibleLen(t *testing.T) {
 		}
 	}
 }
+
+type mockEmbedder struct{}
+
+func (m *mockEmbedder) Embed(texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i := range texts {
+		v := make([]float32, 384)
+		v[0] = 1.0
+		vecs[i] = v
+	}
+	return vecs, nil
+}
+
+func (m *mockEmbedder) EmbedQuery(query string) ([]float32, error) {
+	v := make([]float32, 384)
+	v[0] = 1.0
+	return v, nil
+}
+
+func (m *mockEmbedder) Close() {}
+
+func TestSetColorDisablesEscapes(t *testing.T) {
+	defer lipgloss.SetColorProfile(lipgloss.ColorProfile())
+
+	m := New(index.NewTestIndex(t.TempDir(), &mockEmbedder{}))
+	m.width, m.height = 100, 30
+	m.results = []index.SearchResult{
+		{Score: 0.91, Meta: index.ChunkMeta{Path: "src/main.go", LineNum: 12, Text: "func main() {}"}},
+		{Score: 0.42, Meta: index.ChunkMeta{Path: "docs/readme.md", LineNum: 1, Text: "intro"}},
+	}
+
+	// Sanity check: with a colour profile the same view is styled.
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	if !strings.Contains(m.View(), "\x1b[") {
+		t.Fatal("expected escape sequences with colour enabled")
+	}
+
+	SetColor(false)
+	out := m.View()
+	if strings.Contains(out, "\x1b") {
+		t.Errorf("escape sequences present with colour disabled:\n%q", out)
+	}
+	for _, want := range []string{"sift", "main.go:12", "0.91"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("view missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestEditorFallbacks(t *testing.T) {
+	if got := editorFallbacks("windows"); len(got) != 2 || got[0] != "code" || got[1] != "notepad" {
+		t.Errorf("windows fallbacks = %q, want code then notepad", got)
+	}
+	if got := editorFallbacks("linux"); got[0] != "nvim" {
+		t.Errorf("linux fallbacks = %q, want nvim first", got)
+	}
+}
+
+func TestEditorArgs(t *testing.T) {
+	cases := []struct {
+		editor string
+		want   []string
+	}{
+		{"vim", []string{"+12", "a.go"}},
+		{"/usr/bin/nano", []string{"+12", "a.go"}},
+		{"code", []string{"--goto", "a.go:12"}},
+		{"Code.exe", []string{"--goto", "a.go:12"}},
+		{"notepad", []string{"a.go"}},
+		{"hx", []string{"a.go:12"}},
+		{"kak", []string{"+12", "a.go"}},
+		{"emacsclient", []string{"+12", "a.go"}},
+		{"goland", []string{"--line", "12", "a.go"}},
+		{"idea64.exe", []string{"--line", "12", "a.go"}},
+		{"/opt/idea/bin/idea.sh", []string{"--line", "12", "a.go"}},
+	}
+	for _, tc := range cases {
+		got := editorArgs(tc.editor, "a.go", 12)
+		if strings.Join(got, " ") != strings.Join(tc.want, " ") {
+			t.Errorf("editorArgs(%q) = %q, want %q", tc.editor, got, tc.want)
+		}
+	}
+}
+
+func TestEditorCommand(t *testing.T) {
+	t.Cleanup(func() { SetEditorCommand(nil) })
+	path := filepath.Join("my notes", "vpn setup.md")
+	for _, tc := range []struct {
+		template string
+		want     []string
+	}{
+		{"hx {path}:{line}", []string{"hx", path + ":12"}},
+		{"kak +{line}:{col} {path}", []string{"kak", "+12:1", path}},
+		{"emacsclient -n +{line}:{col} {path}", []string{"emacsclient", "-n", "+12:1", path}},
+		{"idea --line {line} --column {col} {path}", []string{"idea", "--line", "12", "--column", "1", path}},
+		{`"/opt/My Editor/bin/ed" --goto "{path}:{line}:{col}"`, []string{"/opt/My Editor/bin/ed", "--goto", path + ":12:1"}},
+	} {
+		argv, err := config.ParseEditorCommand(tc.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		SetEditorCommand(argv)
+		if got := editorCmd(path, 12).Args; !slices.Equal(got, tc.want) {
+			t.Errorf("%s: argv = %q, want %q", tc.template, got, tc.want)
+		}
+	}
+
+	// Unset, $EDITOR is run with the arguments editorArgs knows for it.
+	SetEditorCommand(nil)
+	t.Setenv("EDITOR", "hx")
+	if got, want := editorCmd(path, 12).Args, []string{"hx", path + ":12"}; !slices.Equal(got, want) {
+		t.Errorf("$EDITOR=hx: argv = %q, want %q", got, want)
+	}
+}
+
+func TestScopeToWorkingDir(t *testing.T) {
+	root := t.TempDir()
+	idx := index.NewTestIndex(filepath.Join(root, ".sift"), &mockEmbedder{})
+	for _, name := range []string{"notes.md", "sub/todo.md"} {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("wireguard"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(filepath.Join(root, "sub"))
+
+	model := New(idx)
+	model.width, model.height = 100, 30
+	model.input.SetValue("wireguard")
+	var m tea.Model = model
+	search := func(key tea.KeyMsg) Model {
+		t.Helper()
+		var cmd tea.Cmd
+		m, cmd = m.Update(key)
+		if cmd == nil {
+			t.Fatalf("%s ran no search", key)
+		}
+		m, _ = m.Update(cmd())
+		return m.(Model)
+	}
+
+	got := search(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if len(got.results) != 1 || filepath.Base(got.results[0].Meta.Path) != "todo.md" {
+		t.Errorf("scoped results = %+v, want only sub/todo.md", got.results)
+	}
+	if !strings.Contains(got.View(), "in sub/") {
+		t.Errorf("view has no scope badge:\n%s", got.View())
+	}
+	if got = search(tea.KeyMsg{Type: tea.KeyCtrlS}); len(got.results) != 2 || strings.Contains(got.View(), "in sub/") {
+		t.Errorf("after toggling off: %d results, want 2 and no badge", len(got.results))
+	}
+
+	// Paths below the working directory are shown relative to it, unless
+	// SetAbsPaths.
+	notes := filepath.ToSlash(filepath.Join(root, "notes.md"))
+	if v := got.View(); !strings.Contains(v, " todo.md:1") || !strings.Contains(v, notes+":1") {
+		t.Errorf("view does not show todo.md relative and notes.md whole:\n%s", v)
+	}
+	SetAbsPaths(true)
+	t.Cleanup(func() { SetAbsPaths(false) })
+	if v := got.View(); !strings.Contains(v, filepath.ToSlash(filepath.Join(root, "sub", "todo.md"))+":1") {
+		t.Errorf("with SetAbsPaths, view does not show todo.md whole:\n%s", v)
+	}
+}
+
+func TestStaleResults(t *testing.T) {
+	root := t.TempDir()
+	idx := index.NewTestIndex(filepath.Join(root, ".sift"), &mockEmbedder{})
+	path := filepath.Join(root, "vpn.md")
+	if err := os.WriteFile(path, []byte("wireguard"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.AddFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("wireguard tunnel"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	model := New(idx)
+	model.width, model.height = 100, 30
+	var m tea.Model = model
+	m, _ = m.Update(searchCmd(context.Background(), idx, 0, "wireguard", index.Filter{})())
+	got := m.(Model)
+	if len(got.results) != 1 || !got.results[0].Stale || !strings.Contains(got.View(), "vpn.md:1 "+staleGlyph) {
+		t.Fatalf("modified file not marked stale:\n%s", got.View())
+	}
+
+	// ctrl+r re-indexes it, then searches again.
+	got.input.SetValue("wireguard")
+	m, cmd := got.Update(tea.KeyMsg{Type: tea.KeyCtrlR})
+	if cmd == nil {
+		t.Fatal("ctrl+r did nothing")
+	}
+	m, cmd = m.Update(cmd())
+	if cmd == nil {
+		t.Fatal("re-indexing ran no search")
+	}
+	m, _ = m.Update(cmd())
+	got = m.(Model)
+	if len(got.results) != 1 || got.results[0].Stale || got.results[0].Meta.Text != "wireguard tunnel" {
+		t.Errorf("after ctrl+r: %+v", got.results)
+	}
+	if strings.Contains(got.View(), staleGlyph) {
+		t.Errorf("view still shows %s:\n%s", staleGlyph, got.View())
+	}
+}
+
+func TestEmptyQuery(t *testing.T) {
+	model := New(index.NewTestIndex(filepath.Join(t.TempDir(), ".sift"), &mockEmbedder{}))
+	model.results = []index.SearchResult{{Meta: index.ChunkMeta{Path: "a.md"}}}
+	model.input.SetValue("   ")
+	var m tea.Model = model
+	m, cmd := m.Update(debounceMsg{id: model.debounceID, query: "   "})
+	if got := m.(Model); cmd != nil || got.results != nil || got.searching {
+		t.Errorf("whitespace query: cmd %v, results %+v, searching %t; want no search and results cleared", cmd != nil, got.results, got.searching)
+	}
+
+	// An empty query that reaches the index just clears the results.
+	model.results = []index.SearchResult{{Meta: index.ChunkMeta{Path: "a.md"}}}
+	m, _ = tea.Model(model).Update(searchCmd(context.Background(), model.idx, model.debounceID, " \t ", index.Filter{})())
+	if got := m.(Model); got.err != nil || got.results != nil {
+		t.Errorf("after ErrEmptyQuery: err %v, results %+v; want neither", got.err, got.results)
+	}
+}
+
+func TestSupersededSearch(t *testing.T) {
+	root := t.TempDir()
+	idx := index.NewTestIndex(filepath.Join(root, ".sift"), &mockEmbedder{})
+	p := filepath.Join(root, "notes.md")
+	if err := os.WriteFile(p, []byte("wireguard"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idx.AddFile(p); err != nil {
+		t.Fatal(err)
+	}
+
+	model := New(idx)
+	model.input.SetValue("wireguard")
+	var m tea.Model = model
+	m, first := m.Update(debounceMsg{id: model.debounceID, query: "wireguard"})
+	if first == nil || !m.(Model).searching {
+		t.Fatal("debounce started no search")
+	}
+	// Typing on supersedes the search before it runs: it is cancelled, and
+	// its outcome ignored.
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	msg := first()
+	if res, ok := msg.(searchResultMsg); !ok || !errors.Is(res.err, context.Canceled) {
+		t.Fatalf("superseded search returned %+v, want context.Canceled", msg)
+	}
+	m, _ = m.Update(msg)
+	if got := m.(Model); got.err != nil || got.results != nil {
+		t.Errorf("superseded search shown: err %v, results %+v", got.err, got.results)
+	}
+
+	// The search for the current query is shown.
+	cur := m.(Model)
+	m, next := m.Update(debounceMsg{id: cur.debounceID, query: cur.input.Value()})
+	m, _ = m.Update(next())
+	if got := m.(Model); got.searching || len(got.results) != 1 {
+		t.Errorf("current search: searching %t, %d results; want 1 result", got.searching, len(got.results))
+	}
+}
+
+func TestPins(t *testing.T) {
+	root := t.TempDir()
+	idx := index.NewTestIndex(filepath.Join(root, ".sift"), &mockEmbedder{})
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		p := filepath.Join(root, name)
+		if err := os.WriteFile(p, []byte("wireguard notes in "+name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := idx.AddFile(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	model := New(idx)
+	model.width, model.height = 100, 30
+	var m tea.Model = model
+	key := func(k tea.KeyType) {
+		t.Helper()
+		m, _ = m.Update(tea.KeyMsg{Type: k})
+	}
+	search := func(query string) {
+		t.Helper()
+		cur := m.(Model)
+		cur.input.SetValue(query)
+		var cmd tea.Cmd
+		m, cmd = tea.Model(cur).Update(debounceMsg{id: cur.debounceID, query: query})
+		m, _ = m.Update(cmd())
+	}
+	pinPaths := func() []string {
+		var out []string
+		for _, p := range m.(Model).Pins() {
+			out = append(out, filepath.Base(p.Path))
+		}
+		return out
+	}
+
+	search("wireguard")
+	results := m.(Model).results
+	if len(results) != 3 {
+		t.Fatalf("%d results, want 3", len(results))
+	}
+	first, second := filepath.Base(results[0].Meta.Path), filepath.Base(results[1].Meta.Path)
+	key(tea.KeyCtrlT)
+	key(tea.KeyDown)
+	key(tea.KeyCtrlT)
+	if got := pinPaths(); !slices.Equal(got, []string{first, second}) {
+		t.Fatalf("pinned %q, want %s and %s", got, first, second)
+	}
+	if v := m.(Model).View(); !strings.Contains(v, "pinned (2)") || !strings.Contains(v, "◆") {
+		t.Errorf("view shows no pinned list:\n%s", v)
+	}
+
+	// Pins stay through another search, and pinning a result again, at
+	// whatever rank it now has, unpins it rather than pinning it twice.
+	search("notes")
+	if got := pinPaths(); len(got) != 2 {
+		t.Fatalf("pins after another search = %q, want both kept", got)
+	}
+	cur := m.(Model)
+	cur.cursor = slices.IndexFunc(cur.results, func(r index.SearchResult) bool { return filepath.Base(r.Meta.Path) == first })
+	m, _ = tea.Model(cur).Update(tea.KeyMsg{Type: tea.KeyCtrlT})
+	if got := pinPaths(); !slices.Equal(got, []string{second}) {
+		t.Errorf("pins after unpinning %s = %q, want [%s]", first, got, second)
+	}
+
+	// In the pinned list, ctrl+t unpins the pin under its cursor.
+	key(tea.KeyShiftTab)
+	if !m.(Model).pinFocus {
+		t.Fatal("shift+tab did not focus the pinned list")
+	}
+	key(tea.KeyCtrlT)
+	if got := m.(Model); len(got.Pins()) != 0 || got.pinFocus {
+		t.Errorf("after unpinning the last pin: %d pins, focus %t; want none and the results focused", len(got.Pins()), got.pinFocus)
+	}
+}
+
+func TestPinsScroll(t *testing.T) {
+	model := New(index.NewTestIndex(filepath.Join(t.TempDir(), ".sift"), &mockEmbedder{}))
+	model.width, model.height = 100, 30
+	for i := range maxPinRows + 3 {
+		model.pins = append(model.pins, Pin{ID: strconv.Itoa(i), Path: "/notes/" + strconv.Itoa(i) + ".md", Line: 1})
+	}
+	var m tea.Model = model
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyShiftTab})
+	for range len(model.pins) + 2 { // past the end
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	}
+	got := m.(Model)
+	if got.pinCursor != len(got.pins)-1 || got.pinOffset != 3 {
+		t.Errorf("pin cursor %d, offset %d; want %d and 3", got.pinCursor, got.pinOffset, len(got.pins)-1)
+	}
+	v := got.View()
+	if !strings.Contains(v, "↑3 ↓0") || strings.Contains(v, "/2.md") || !strings.Contains(v, "/7.md") {
+		t.Errorf("scrolled pinned list shows the wrong pins:\n%s", v)
+	}
+}
+
+func TestFormatPins(t *testing.T) {
+	cwd := filepath.FromSlash("/work/proj")
+	pins := []Pin{
+		{ID: "1", Path: filepath.FromSlash("/work/proj/src/auth.go"), Line: 12, Text: "\nfunc refresh() {\n\treturn\n}"},
+		{ID: "2", Path: filepath.FromSlash("/elsewhere/notes.md"), Line: 3},
+	}
+	want := "src/auth.go:12\n" + filepath.ToSlash(filepath.FromSlash("/elsewhere/notes.md")) + ":3\n"
+	if got := FormatPins(pins, false, cwd); got != want {
+		t.Errorf("lines = %q, want %q", got, want)
+	}
+	want = "- `src/auth.go:12` — func refresh() {\n- `" + filepath.ToSlash(filepath.FromSlash("/elsewhere/notes.md")) + ":3`\n"
+	if got := FormatPins(pins, true, cwd); got != want {
+		t.Errorf("markdown = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,333 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tejas242/sift/internal/chunker"
+	"github.com/tejas242/sift/internal/ignore"
+	"github.com/tejas242/sift/internal/throttle"
+)
+
+// A changed file is re-indexed once it has gone unchanged for debounce, so
+// each watched root keeps the set of paths waiting: its pending set. A
+// burst of events (a git clean and checkout in a large tree raises
+// hundreds of thousands) is bounded three ways:
+//
+//   - Once the pending set is half full, files changing in a directory
+//     not yet pending are recorded by their directory instead, whose
+//     supported files are all re-indexed (unchanged ones are skipped by
+//     mtime) when it goes quiet.
+//   - Once it would exceed maxPending, it collapses: every pending entry
+//     is dropped and events are only counted until fewer than a quarter
+//     of maxPending arrive within debounce, when a single rescan of the
+//     watched roots catches up on all of them. Collapsing at maxPending
+//     but resuming only below a quarter of it keeps a steady stream of
+//     events from flapping between the two.
+//   - Messages about single files are logged at most maxLogsPerSecond a
+//     second; the others are counted and reported as suppressed.
+//
+// Status reports the counts.
+
+// defaultMaxPending bounds a watched root's pending set.
+const defaultMaxPending = 4096
+
+// maxLogsPerSecond caps the per-file messages the watcher logs.
+const maxLogsPerSecond = 20
+
+// Status reports a watcher's backlog and how it has coped with bursts of
+// events since it was created.
+type Status struct {
+	Pending    int    // files and directories waiting to be re-indexed
+	MaxPending int    // the bound on each watched root's pending files and directories
+	Collapsed  bool   // a watched root is waiting for a burst to end to rescan
+	Coalesced  uint64 // file events recorded by their directory
+	Dropped    uint64 // events left to a rescan
+	Collapses  uint64 // times a pending set collapsed into a rescan
+	Suppressed uint64 // per-file messages over maxLogsPerSecond
+
+	// Priority is the mode re-indexing runs in (see SetThrottle), and
+	// Pauses and Paused count the pauses it made for a busy system.
+	Priority throttle.Mode
+	Pauses   int
+	Paused   time.Duration
+}
+
+// Status returns the watcher's current Status.
+func (w *Watcher) Status() Status {
+	pauses := w.pace.Stats()
+	return Status{
+		Pending:    int(w.pending.Load()),
+		MaxPending: w.maxPending,
+		Collapsed:  w.collapsed.Load() > 0,
+		Coalesced:  w.coalesced.Load(),
+		Dropped:    w.dropped.Load(),
+		Collapses:  w.collapses.Load(),
+		Suppressed: w.suppressed.Load(),
+		Priority:   w.pace.Limits().Mode,
+		Pauses:     pauses.Pauses,
+		Paused:     pauses.Paused,
+	}
+}
+
+// burst is the pending set of one watched root. Only the goroutine
+// running Watch uses it; timers report to it through due.
+type burst struct {
+	w     *Watcher
+	delay time.Duration // debounce
+	max   int           // maxPending
+	done  <-chan struct{}
+
+	files map[string]pendingEntry
+	dirs  map[string]pendingEntry
+	seq   uint64
+	due   chan dueEntry
+
+	collapsed bool
+	window    int // events since the last tick, while collapsed
+	ticker    *time.Ticker
+
+	// What a quiet file or directory, or the end of a collapse, runs;
+	// tests replace them.
+	reindexFile, reindexDir func(string)
+	rescan                  func()
+}
+
+type pendingEntry struct {
+	timer *time.Timer
+	seq   uint64
+}
+
+// dueEntry is sent by the timer of a pending entry. An entry rescheduled
+// since has a newer seq, so a stale timer that fired anyway is ignored.
+type dueEntry struct {
+	key string
+	dir bool
+	seq uint64
+}
+
+// newBurst returns the pending set of the watched root rootDir, until done
+// is closed.
+func (w *Watcher) newBurst(rootDir string, done <-chan struct{}) *burst {
+	return &burst{
+		w:           w,
+		delay:       debounce,
+		max:         w.maxPending,
+		done:        done,
+		files:       make(map[string]pendingEntry),
+		dirs:        make(map[string]pendingEntry),
+		due:         make(chan dueEntry),
+		reindexFile: w.reindex,
+		reindexDir:  func(dir string) { w.reindexDir(rootDir, dir) },
+		rescan:      func() { w.rescanAll(done) },
+	}
+}
+
+// size is how many files and directories are pending.
+func (b *burst) size() int {
+	return len(b.files) + len(b.dirs)
+}
+
+// change records a change to the file path.
+func (b *burst) change(path string) {
+	if b.collapsed {
+		b.window++
+		b.w.dropped.Add(1)
+		return
+	}
+	if _, ok := b.files[path]; ok {
+		b.schedule(b.files, path, false)
+		return
+	}
+	dir := filepath.Dir(path)
+	if _, ok := b.dirs[dir]; ok {
+		b.w.coalesced.Add(1)
+		b.schedule(b.dirs, dir, true)
+		return
+	}
+	switch {
+	case b.size() >= b.max:
+		b.collapse()
+		b.window++
+		b.w.dropped.Add(1)
+	case b.size() >= b.max/2:
+		b.w.coalesced.Add(1)
+		b.schedule(b.dirs, dir, true)
+	default:
+		b.schedule(b.files, path, false)
+	}
+}
+
+// schedule (re)starts the timer of key in m, a pending set.
+func (b *burst) schedule(m map[string]pendingEntry, key string, dir bool) {
+	if e, ok := m[key]; ok {
+		e.timer.Stop()
+	} else {
+		b.w.pending.Add(1)
+	}
+	b.seq++
+	d := dueEntry{key: key, dir: dir, seq: b.seq}
+	m[key] = pendingEntry{seq: d.seq, timer: time.AfterFunc(b.delay, func() {
+		select {
+		case b.due <- d:
+		case <-b.done:
+		}
+	})}
+}
+
+// fire re-indexes the entry d reports gone quiet, unless it has been
+// rescheduled or dropped since.
+func (b *burst) fire(d dueEntry) {
+	m, run := b.files, b.reindexFile
+	if d.dir {
+		m, run = b.dirs, b.reindexDir
+	}
+	if e, ok := m[d.key]; !ok || e.seq != d.seq {
+		return
+	}
+	delete(m, d.key)
+	b.w.pending.Add(-1)
+	go run(d.key)
+}
+
+// collapse drops every pending entry, leaving them to a rescan once the
+// burst ends; see tick.
+func (b *burst) collapse() {
+	n := b.size()
+	b.clear()
+	b.collapsed = true
+	b.window = 0
+	b.ticker = time.NewTicker(b.delay)
+	b.w.collapsed.Add(1)
+	b.w.collapses.Add(1)
+	b.w.log.Warnf("[watch] more than %d changes pending (%d dropped); rescanning once they stop", b.max, n)
+}
+
+// ticks returns the channel whose ticks end a collapse, or nil when the
+// pending set has not collapsed.
+func (b *burst) ticks() <-chan time.Time {
+	if b.ticker == nil {
+		return nil
+	}
+	return b.ticker.C
+}
+
+// tick ends a collapse with a rescan if fewer than a quarter of maxPending
+// events arrived since the last tick.
+func (b *burst) tick() {
+	if b.window >= b.max/4 {
+		b.window = 0
+		return
+	}
+	b.ticker.Stop()
+	b.ticker = nil
+	b.collapsed = false
+	b.w.collapsed.Add(-1)
+	go b.rescan()
+}
+
+// stop drops the pending set when Watch returns.
+func (b *burst) stop() {
+	b.clear()
+	if b.ticker != nil {
+		b.ticker.Stop()
+		b.w.collapsed.Add(-1)
+	}
+}
+
+// clear stops and forgets every pending entry.
+func (b *burst) clear() {
+	for _, m := range []map[string]pendingEntry{b.files, b.dirs} {
+		for key, e := range m {
+			e.timer.Stop()
+			delete(m, key)
+			b.w.pending.Add(-1)
+		}
+	}
+}
+
+// reindexDir re-indexes the supported files directly in dir, a directory
+// of the watched root rootDir, after changes recorded by directory. The
+// mtime cache skips those that did not change.
+func (w *Watcher) reindexDir(rootDir, dir string) {
+	w.logLimited(w.log.Infof, "[watch] re-indexing %s/", dir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		w.logLimited(w.log.Errorf, "[watch] error: %v", err)
+		return
+	}
+	m := w.matcher(rootDir)
+	added := false
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if e.IsDir() || !chunker.IsSupportedFile(path) || m.SkipFile(ignore.Rel(rootDir, path)) {
+			continue
+		}
+		added = w.addFile(path) || added
+	}
+	if added {
+		w.flush()
+	}
+}
+
+// rescanAll rescans every watched root after a collapse, waiting for
+// any rescan already running to finish first, until done is closed.
+func (w *Watcher) rescanAll(done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for {
+		sum, err := w.Rescan(ctx, w.roots())
+		switch {
+		case errors.Is(err, ErrRescanBusy):
+			select {
+			case <-done:
+				return
+			case <-time.After(debounce):
+				continue
+			}
+		case err != nil && ctx.Err() == nil:
+			w.log.Errorf("[watch] rescan error: %v", err)
+		case err == nil:
+			w.log.Infof("[watch] burst over; rescan: %d updated, %d removed, %d files checked in %s",
+				sum.Updated, sum.Removed, sum.Files, sum.Elapsed.Round(time.Millisecond))
+		}
+		return
+	}
+}
+
+// logLimited logs through logf unless maxLogsPerSecond messages were
+// logged this way in the current second. Messages held back are counted,
+// and reported with the first message of a later second.
+func (w *Watcher) logLimited(logf func(string, ...any), format string, args ...any) {
+	w.logMu.Lock()
+	now := time.Now()
+	var held int
+	if now.Sub(w.logWindow) >= time.Second {
+		held, w.held = w.held, 0
+		w.logWindow, w.logged = now, 0
+	}
+	ok := w.logged < maxLogsPerSecond
+	if ok {
+		w.logged++
+	} else {
+		w.held++
+		w.suppressed.Add(1)
+	}
+	w.logMu.Unlock()
+	if held > 0 {
+		w.log.Warnf("[watch] %d messages suppressed (more than %d a second)", held, maxLogsPerSecond)
+	}
+	if ok {
+		logf(format, args...)
+	}
+}
@@ -3,21 +3,47 @@
 package watcher
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/tejas242/sift/internal/chunker"
+	"github.com/tejas242/sift/internal/embed"
+	"github.com/tejas242/sift/internal/ignore"
 	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/logging"
+	"github.com/tejas242/sift/internal/protocol"
+	"github.com/tejas242/sift/internal/throttle"
 )
 
 // Watcher watches a directory tree for changes and updates the index.
 type Watcher struct {
-	fw  *fsnotify.Watcher
-	idx *index.Index
+	fw     *fsnotify.Watcher
+	idx    *index.Index
+	log    *logging.Logger
+	events *protocol.Encoder  // nil unless SetEvents
+	pace   *throttle.Throttle // nil unless SetThrottle
+
+	rescanMu sync.Mutex // held while a rescan runs
+
+	mu       sync.Mutex
+	matchers map[string]*ignore.Matcher // per watched root; replaced by Reload
+	failing  bool                       // embedding gave up and has not recovered; see reindex
+
+	// Burst bounds and counts; see burst.go.
+	maxPending                                int
+	pending, collapsed                        atomic.Int64
+	coalesced, dropped, collapses, suppressed atomic.Uint64
+	logMu                                     sync.Mutex
+	logWindow                                 time.Time // start of the current second
+	logged, held                              int       // messages logged and held back in it
 }
 
 // New creates a Watcher backed by the given index.
@@ -26,71 +52,334 @@ func New(idx *index.Index) (*Watcher, error) {
 	if err != nil {
 		return nil, fmt.Errorf("fsnotify: %w", err)
 	}
-	return &Watcher{fw: fw, idx: idx}, nil
+	return &Watcher{fw: fw, idx: idx, log: logging.Default(), matchers: make(map[string]*ignore.Matcher), maxPending: defaultMaxPending}, nil
+}
+
+// SetLogger routes watch diagnostics to l; nil silences them.
+func (w *Watcher) SetLogger(l *logging.Logger) {
+	w.log = l
+}
+
+// SetEvents makes the watcher write a protocol message to enc for every
+// file it re-indexes ("watch.file") and every rescan ("watch.rescan").
+func (w *Watcher) SetEvents(enc *protocol.Encoder) {
+	w.events = enc
+}
+
+// SetThrottle makes the watcher pause by t after each file it re-indexes,
+// as index.Index.SetThrottle does between the files of a rescan, and
+// report t's mode in Status. Set it before Watch.
+func (w *Watcher) SetThrottle(t *throttle.Throttle) {
+	w.pace = t
+	w.idx.SetThrottle(t)
+}
+
+// emit writes a message to the event stream, if there is one.
+func (w *Watcher) emit(typ string, data any) {
+	if w.events == nil {
+		return
+	}
+	if err := w.events.Encode(typ, data); err != nil {
+		w.logLimited(w.log.Errorf, "[watch] event: %v", err)
+	}
 }
 
+// background marks the watcher's indexing as background work: its
+// embedding waits behind searches and manual indexing sharing the model
+// (see index.WithEmbedPriority), one batch at a time.
+func background(ctx context.Context) context.Context {
+	return index.WithEmbedPriority(ctx, embed.PriorityBackground)
+}
+
+// debounce is how long a file must go unchanged before it is re-indexed,
+// so a burst of saves costs one re-index.
+const debounce = 500 * time.Millisecond
+
 // Watch adds rootDir (and all subdirectories) to the watch list and begins
 // processing events. It blocks until ctx is cancelled or an unrecoverable
-// error occurs. Call this in a goroutine.
+// error occurs. Call this in a goroutine. rootDir may also be a single
+// file; see watchFile.
 func (w *Watcher) Watch(rootDir string, done <-chan struct{}) error {
+	if fi, err := os.Stat(rootDir); err != nil {
+		return err
+	} else if !fi.IsDir() {
+		return w.watchFile(rootDir, done)
+	}
+
+	// Apply the same --exclude / --include-only / .siftignore rules as indexing.
+	m, err := w.idx.Matcher(rootDir)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.matchers[rootDir] = m
+	w.mu.Unlock()
+
 	// Add all existing subdirectories.
-	if err := w.addDirRecursive(rootDir); err != nil {
+	if err := w.addDirRecursive(rootDir, rootDir, m); err != nil {
 		return err
 	}
 
-	// Debounce map: path→timer
-	pending := make(map[string]*time.Timer)
+	b := w.newBurst(rootDir, done)
+	defer b.stop()
 
 	for {
 		select {
 		case <-done:
 			return w.fw.Close()
 
+		case d := <-b.due:
+			b.fire(d)
+
+		case <-b.ticks():
+			b.tick()
+
 		case event, ok := <-w.fw.Events:
 			if !ok {
 				return nil
 			}
 			path := event.Name
+			m := w.matcher(rootDir)
 
 			// Add new directories to the watch list.
 			if event.Has(fsnotify.Create) {
 				if fi, err := os.Stat(path); err == nil && fi.IsDir() {
-					_ = w.addDirRecursive(path)
+					if !m.SkipDir(ignore.Rel(rootDir, path)) {
+						_ = w.addDirRecursive(rootDir, path, m)
+					}
 				}
 			}
 
-			if !chunker.IsSupportedFile(path) {
+			if !chunker.IsSupportedFile(path) || m.SkipFile(ignore.Rel(rootDir, path)) {
 				continue
 			}
 
 			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) {
-				// Debounce: reset timer on rapid saves.
-				if t, ok := pending[path]; ok {
-					t.Stop()
-				}
-				pending[path] = time.AfterFunc(500*time.Millisecond, func() {
-					fmt.Fprintf(os.Stderr, "[watch] re-indexing %s\n", path)
-					if _, err := w.idx.AddFile(path); err != nil {
-						fmt.Fprintf(os.Stderr, "[watch] error: %v\n", err)
-						return
-					}
-					if err := w.idx.Flush(); err != nil {
-						fmt.Fprintf(os.Stderr, "[watch] flush error: %v\n", err)
-					}
-				})
+				b.change(path)
 			}
 
 		case err, ok := <-w.fw.Errors:
 			if !ok {
 				return nil
 			}
-			fmt.Fprintf(os.Stderr, "[watch] error: %v\n", err)
+			w.log.Errorf("[watch] error: %v", err)
+		}
+	}
+}
+
+// watchFile watches the single file path until done is closed. Editors
+// often save by renaming a new file over the old one, which ends a watch
+// on the file itself, so its directory is watched instead, on a watcher
+// of its own, and events for the directory's other entries are dropped.
+// As when indexing a file root, ignore rules do not apply to it.
+func (w *Watcher) watchFile(path string, done <-chan struct{}) error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("fsnotify: %w", err)
+	}
+	defer fw.Close()
+	if err := fw.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	want := filepath.Clean(path)
+	var pending *time.Timer
+	for {
+		select {
+		case <-done:
+			return nil
+
+		case event, ok := <-fw.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) != want || !(event.Has(fsnotify.Write) || event.Has(fsnotify.Create)) {
+				continue
+			}
+			if pending != nil {
+				pending.Stop()
+			}
+			pending = time.AfterFunc(debounce, func() { w.reindex(path) })
+
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return nil
+			}
+			w.log.Errorf("[watch] error: %v", err)
 		}
 	}
 }
 
-// addDirRecursive adds dir and all non-hidden subdirectories to the watcher.
-func (w *Watcher) addDirRecursive(dir string) error {
+// reindex indexes path after a change and saves the index.
+func (w *Watcher) reindex(path string) {
+	w.logLimited(w.log.Infof, "[watch] re-indexing %s", path)
+	if w.addFile(path) {
+		w.flush()
+	}
+}
+
+// addFile indexes path after a change and reports whether it succeeded.
+// Once the index gives up on a failing embedder (an
+// *index.EmbedAbortError), that is reported once, and then nothing more
+// until a file embeds again, so a broken model does not log an error for
+// every save.
+func (w *Watcher) addFile(path string) bool {
+	skipped, err := w.idx.AddFileCtx(background(context.Background()), path)
+	if err != nil {
+		w.emit(protocol.TypeWatchFile, protocol.WatchFile{Path: path, Error: err.Error()})
+	} else {
+		w.emit(protocol.TypeWatchFile, protocol.WatchFile{Path: path, Skipped: skipped})
+	}
+	var abort *index.EmbedAbortError
+	switch {
+	case errors.As(err, &abort):
+		if w.setFailing(true) {
+			w.log.Errorf("[watch] %v; not reporting further embedding failures until one succeeds", err)
+		}
+		return false
+	case err != nil:
+		w.logLimited(w.log.Errorf, "[watch] error: %v", err)
+		return false
+	}
+	if !w.idx.EmbedFailing() && w.setFailing(false) {
+		w.log.Infof("[watch] embedding works again (%s)", path)
+	}
+	if !skipped {
+		w.pace.Wait(context.Background())
+	}
+	return true
+}
+
+// flush saves the index after changes.
+func (w *Watcher) flush() {
+	if err := w.idx.Flush(); err != nil {
+		w.log.Errorf("[watch] flush error: %v", err)
+	}
+}
+
+// setFailing records whether embedding is failing and reports whether
+// that changed.
+func (w *Watcher) setFailing(failing bool) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changed := w.failing != failing
+	w.failing = failing
+	return changed
+}
+
+// roots returns the watched directories.
+func (w *Watcher) roots() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	roots := make([]string, 0, len(w.matchers))
+	for root := range w.matchers {
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// matcher returns the current path filter for the watched root rootDir.
+func (w *Watcher) matcher(rootDir string) *ignore.Matcher {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.matchers[rootDir]
+}
+
+// Reload re-reads each watched root's ignore rules (.siftignore plus the
+// index's patterns). Events under newly ignored paths are dropped from
+// then on, and directories that are no longer ignored start being watched.
+func (w *Watcher) Reload() error {
+	for _, root := range w.roots() {
+		m, err := w.idx.Matcher(root)
+		if err != nil {
+			return fmt.Errorf("reload %s: %w", root, err)
+		}
+		w.mu.Lock()
+		w.matchers[root] = m
+		w.mu.Unlock()
+		if err := w.addDirRecursive(root, root, m); err != nil {
+			return fmt.Errorf("reload %s: %w", root, err)
+		}
+	}
+	return nil
+}
+
+// ErrRescanBusy is returned by Rescan while another rescan is running.
+var ErrRescanBusy = errors.New("rescan already running")
+
+// RescanSummary reports what one Rescan changed.
+type RescanSummary struct {
+	Files   int // eligible files seen
+	Updated int // files re-indexed because they changed
+	Removed int // deleted files pruned from the index
+	Elapsed time.Duration
+}
+
+// Rescan re-indexes roots incrementally (the mtime cache skips unchanged
+// files) and prunes deleted files, catching changes whose events fsnotify
+// dropped. Rescans never overlap: a call made while one is running
+// returns ErrRescanBusy at once.
+func (w *Watcher) Rescan(ctx context.Context, roots []string) (RescanSummary, error) {
+	if !w.rescanMu.TryLock() {
+		return RescanSummary{}, ErrRescanBusy
+	}
+	defer w.rescanMu.Unlock()
+
+	ctx = background(ctx)
+	var sum RescanSummary
+	start := time.Now()
+	for _, root := range roots {
+		err := w.idx.IndexDirWithProgress(ctx, root, func(_, _ int, _ string, skipped bool) {
+			sum.Files++
+			if !skipped {
+				sum.Updated++
+			}
+		})
+		if err != nil {
+			return sum, err
+		}
+		n, err := w.idx.PruneMissing(root)
+		if err != nil {
+			return sum, err
+		}
+		sum.Removed += n
+	}
+	if sum.Updated > 0 || sum.Removed > 0 {
+		if err := w.idx.Flush(); err != nil {
+			return sum, err
+		}
+	}
+	sum.Elapsed = time.Since(start)
+	w.emit(protocol.TypeWatchRescan, protocol.WatchRescan{Files: sum.Files, Updated: sum.Updated, Removed: sum.Removed, DurationMS: sum.Elapsed.Milliseconds()})
+	return sum, nil
+}
+
+// RescanOn runs Rescan over roots on every tick (normally a time.Ticker's
+// channel) until ctx is done, logging a one-line summary of each. It
+// blocks; call it in a goroutine.
+func (w *Watcher) RescanOn(ctx context.Context, roots []string, tick <-chan time.Time) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+			sum, err := w.Rescan(ctx, roots)
+			switch {
+			case errors.Is(err, ErrRescanBusy):
+				w.log.Debugf("[watch] rescan skipped: previous rescan still running")
+			case err != nil && ctx.Err() == nil:
+				w.log.Errorf("[watch] rescan error: %v", err)
+			case err == nil:
+				w.log.Infof("[watch] rescan: %d updated, %d removed, %d files checked in %s",
+					sum.Updated, sum.Removed, sum.Files, sum.Elapsed.Round(time.Millisecond))
+			}
+		}
+	}
+}
+
+// addDirRecursive adds dir and all non-hidden, non-excluded subdirectories
+// to the watcher. rootDir anchors the matcher's relative paths.
+func (w *Watcher) addDirRecursive(rootDir, dir string, m *ignore.Matcher) error {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return err
@@ -103,9 +392,13 @@ func (w *Watcher) addDirRecursive(dir string) error {
 			continue
 		}
 		if e.IsDir() {
-			if err := w.addDirRecursive(filepath.Join(dir, e.Name())); err != nil {
+			sub := filepath.Join(dir, e.Name())
+			if m.SkipDir(ignore.Rel(rootDir, sub)) {
+				continue
+			}
+			if err := w.addDirRecursive(rootDir, sub, m); err != nil {
 				// Non-fatal: log and continue.
-				fmt.Fprintf(os.Stderr, "[watch] skip dir: %v\n", err)
+				w.log.Warnf("[watch] skip dir: %v", err)
 			}
 		}
 	}
@@ -1,13 +1,22 @@
 package watcher
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/logging"
+	"github.com/tejas242/sift/internal/protocol"
 )
 
 type mockEmbedder struct {
@@ -102,3 +111,481 @@ func TestWatcher_Watch_Incremental(t *testing.T) {
 		t.Error("expected mock embedder to be called to re-index the updated file, but it wasn't")
 	}
 }
+
+// writeLater writes body to path with an mtime in the future, so the
+// index's mtime cache sees a change without any fsnotify event.
+func writeLater(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcher_Rescan(t *testing.T) {
+	tmpDir := t.TempDir()
+	watchDir := filepath.Join(tmpDir, "watch")
+	if err := os.Mkdir(watchDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	changed := filepath.Join(watchDir, "changed.md")
+	deleted := filepath.Join(watchDir, "deleted.md")
+	for _, p := range []string{changed, deleted, filepath.Join(watchDir, "same.md")} {
+		if err := os.WriteFile(p, []byte("original text"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := index.NewTestIndex(filepath.Join(tmpDir, "idx"), &mockEmbedder{})
+	if err := idx.IndexDir(context.Background(), watchDir); err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes made behind the watcher's back.
+	writeLater(t, changed, "edited without an event")
+	if err := os.Remove(deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := w.Rescan(context.Background(), []string{watchDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Files != 2 || sum.Updated != 1 || sum.Removed != 1 {
+		t.Errorf("summary = %+v, want 2 files, 1 updated, 1 removed", sum)
+	}
+	if c := idx.FileChunks(changed); len(c) != 1 || c[0].Text != "edited without an event" {
+		t.Errorf("changed file chunks = %+v", c)
+	}
+	if c := idx.FileChunks(deleted); len(c) != 0 {
+		t.Errorf("deleted file still indexed: %+v", c)
+	}
+
+	// A second rescan finds nothing new.
+	if sum, err := w.Rescan(context.Background(), []string{watchDir}); err != nil || sum.Updated != 0 || sum.Removed != 0 {
+		t.Errorf("second rescan = %+v, %v; want no changes", sum, err)
+	}
+
+	// Rescans never overlap.
+	w.rescanMu.Lock()
+	_, err = w.Rescan(context.Background(), []string{watchDir})
+	w.rescanMu.Unlock()
+	if !errors.Is(err, ErrRescanBusy) {
+		t.Errorf("overlapping rescan: err = %v, want ErrRescanBusy", err)
+	}
+}
+
+func TestWatcher_Events(t *testing.T) {
+	tmpDir := t.TempDir()
+	embedder := &brokenEmbedder{}
+	idx := index.NewTestIndex(filepath.Join(tmpDir, ".sift"), embedder)
+	idx.SetLogger(logging.Discard())
+	idx.SetEmbedFailureLimit(index.EmbedFailureLimit{Consecutive: 1})
+	w, err := New(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetLogger(logging.Discard())
+	var out bytes.Buffer
+	w.SetEvents(protocol.NewEncoder(&out))
+
+	doc := filepath.Join(tmpDir, "doc.md")
+	writeLater(t, doc, "wireguard config")
+	w.reindex(doc)
+	w.reindex(doc)
+	bad := filepath.Join(tmpDir, "bad.md")
+	writeLater(t, bad, "never embedded")
+	embedder.broken.Store(true)
+	w.reindex(bad)
+	embedder.broken.Store(false)
+	if _, err := w.Rescan(context.Background(), []string{tmpDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	var types []string
+	var files []protocol.WatchFile
+	var rescan protocol.WatchRescan
+	for _, line := range strings.SplitAfter(strings.TrimSpace(out.String()), "\n") {
+		env, err := protocol.Unmarshal([]byte(line))
+		if err != nil {
+			t.Fatalf("event %q: %v", line, err)
+		}
+		types = append(types, env.Type)
+		switch env.Type {
+		case protocol.TypeWatchFile:
+			var f protocol.WatchFile
+			if err := env.Decode(&f); err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, f)
+		case protocol.TypeWatchRescan:
+			if err := env.Decode(&rescan); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if want := []string{"watch.file", "watch.file", "watch.file", "watch.rescan"}; !slices.Equal(types, want) {
+		t.Fatalf("events = %q, want %q", types, want)
+	}
+	if files[0] != (protocol.WatchFile{Path: doc}) || files[1] != (protocol.WatchFile{Path: doc, Skipped: true}) {
+		t.Errorf("file events = %+v, want doc.md indexed, then skipped", files[:2])
+	}
+	if files[2].Error == "" {
+		t.Errorf("event for a failed file = %+v, want an error", files[2])
+	}
+	if rescan.Files != 2 || rescan.Updated != 1 {
+		t.Errorf("rescan event = %+v, want bad.md indexed, doc.md unchanged", rescan)
+	}
+}
+
+func TestWatcher_RescanOn(t *testing.T) {
+	tmpDir := t.TempDir()
+	doc := filepath.Join(tmpDir, "doc.md")
+	if err := os.WriteFile(doc, []byte("before"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx := index.NewTestIndex(filepath.Join(tmpDir, ".sift"), &mockEmbedder{})
+	if err := idx.IndexDir(context.Background(), tmpDir); err != nil {
+		t.Fatal(err)
+	}
+	w, err := New(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetLogger(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tick := make(chan time.Time)
+	stopped := make(chan struct{})
+	go func() {
+		w.RescanOn(ctx, []string{tmpDir}, tick)
+		close(stopped)
+	}()
+
+	writeLater(t, doc, "after")
+	tick <- time.Now()
+	// The loop takes the next tick only once the first rescan is done.
+	tick <- time.Now()
+	cancel()
+	<-stopped
+
+	if c := idx.FileChunks(doc); len(c) != 1 || c[0].Text != "after" {
+		t.Errorf("rescan did not pick up the change: %+v", c)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, ".sift", "meta.json")); err != nil {
+		t.Errorf("rescan should flush its changes: %v", err)
+	}
+}
+
+func TestWatcher_Reload(t *testing.T) {
+	tmpDir := t.TempDir()
+	watchDir := filepath.Join(tmpDir, "watch")
+	for _, d := range []string{"docs", "gen"} {
+		if err := os.MkdirAll(filepath.Join(watchDir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := index.NewTestIndex(filepath.Join(tmpDir, "idx"), &mockEmbedder{})
+	w, err := New(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetLogger(nil)
+
+	done := make(chan struct{})
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- w.Watch(watchDir, done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// Ignore gen/ while running, as a SIGHUP after editing .siftignore would.
+	if err := os.WriteFile(filepath.Join(watchDir, ".siftignore"), []byte("gen/**\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	ignored := filepath.Join(watchDir, "gen", "out.md")
+	kept := filepath.Join(watchDir, "docs", "guide.md")
+	for _, p := range []string{ignored, kept} {
+		if err := os.WriteFile(p, []byte("generated or not"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(750 * time.Millisecond)
+	close(done)
+	if err := <-errChan; err != nil {
+		t.Errorf("Watcher returned error: %v", err)
+	}
+
+	if c := idx.FileChunks(ignored); len(c) != 0 {
+		t.Errorf("newly ignored file was re-indexed: %+v", c)
+	}
+	if c := idx.FileChunks(kept); len(c) != 1 {
+		t.Errorf("file outside the ignored dir was not indexed: %+v", c)
+	}
+}
+
+func TestWatcher_WatchFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "notes.md")
+	sibling := filepath.Join(tmpDir, "other.md")
+	if err := os.WriteFile(target, []byte("before"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx := index.NewTestIndex(filepath.Join(tmpDir, ".sift"), &mockEmbedder{})
+	w, err := New(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetLogger(nil)
+
+	done := make(chan struct{})
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- w.Watch(target, done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// Save the target by renaming over it, as many editors do, and touch
+	// a file next to it.
+	tmp := filepath.Join(tmpDir, "notes.md.tmp")
+	if err := os.WriteFile(tmp, []byte("after"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(sibling, []byte("not watched"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(750 * time.Millisecond)
+	close(done)
+	if err := <-errChan; err != nil {
+		t.Errorf("Watcher returned error: %v", err)
+	}
+
+	if c := idx.FileChunks(target); len(c) != 1 || c[0].Text != "after" {
+		t.Errorf("watched file chunks = %+v, want the saved text", c)
+	}
+	if c := idx.FileChunks(sibling); len(c) != 0 {
+		t.Errorf("file next to the watched one was indexed: %+v", c)
+	}
+}
+
+// brokenEmbedder fails every batch while broken is set.
+type brokenEmbedder struct {
+	mockEmbedder
+	broken atomic.Bool
+}
+
+func (e *brokenEmbedder) Embed(texts []string) ([][]float32, error) {
+	if e.broken.Load() {
+		return nil, errors.New("onnxruntime: session closed")
+	}
+	return e.mockEmbedder.Embed(texts)
+}
+
+func TestWatcher_EmbedFailures(t *testing.T) {
+	tmpDir := t.TempDir()
+	embedder := &brokenEmbedder{}
+	idx := index.NewTestIndex(filepath.Join(tmpDir, ".sift"), embedder)
+	idx.SetLogger(logging.Discard())
+	idx.SetEmbedFailureLimit(index.EmbedFailureLimit{Consecutive: 2})
+	w, err := New(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log bytes.Buffer
+	w.SetLogger(logging.New(&log, logging.LevelInfo))
+
+	save := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			path := filepath.Join(tmpDir, fmt.Sprintf("doc%d.md", i))
+			writeLater(t, path, fmt.Sprintf("revision %d", time.Now().UnixNano()))
+			w.reindex(path)
+		}
+	}
+	count := func(s string) int { return strings.Count(log.String(), s) }
+
+	embedder.broken.Store(true)
+	save(6)
+	if n := count("giving up"); n != 1 {
+		t.Errorf("embedding failure reported %d times over 6 saves, want once:\n%s", n, log.String())
+	}
+	if n := count("[watch] error"); n != 0 {
+		t.Errorf("failed saves logged as errors:\n%s", log.String())
+	}
+
+	embedder.broken.Store(false)
+	save(1)
+	if count("embedding works again") != 1 {
+		t.Errorf("recovery not reported:\n%s", log.String())
+	}
+	if c := idx.FileChunks(filepath.Join(tmpDir, "doc0.md")); len(c) != 1 {
+		t.Errorf("file saved after recovery not indexed: %+v", c)
+	}
+
+	embedder.broken.Store(true)
+	save(3)
+	if n := count("giving up"); n != 2 {
+		t.Errorf("failing again reported %d times in all, want 2:\n%s", n, log.String())
+	}
+}
+
+func TestBurst_Flood(t *testing.T) {
+	w, err := New(index.NewTestIndex(t.TempDir(), &mockEmbedder{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetLogger(nil)
+	w.maxPending = 100
+	done := make(chan struct{})
+	defer close(done)
+	b := w.newBurst(t.TempDir(), done)
+	defer b.stop()
+	b.delay = time.Hour // only the events and ticks below drive it
+	var rescans atomic.Int32
+	rescanned := make(chan struct{}, 1)
+	b.rescan = func() {
+		rescans.Add(1)
+		rescanned <- struct{}{}
+	}
+
+	// Half the bound is pending file by file, then by directory.
+	for i := range 50 {
+		b.change(fmt.Sprintf("/tree/d%d/main.go", i))
+	}
+	b.change("/tree/d0/main.go")
+	b.change("/tree/d0/util.go")
+	b.change("/tree/d0/extra.go")
+	if len(b.files) != 50 || len(b.dirs) != 1 || w.Status().Coalesced != 2 {
+		t.Fatalf("pending %d files and %d directories (%+v), want 50 and d0/ coalescing 2 events",
+			len(b.files), len(b.dirs), w.Status())
+	}
+
+	// A flood over many directories never holds more than the bound.
+	for i := range 100_000 {
+		b.change(fmt.Sprintf("/tree/d%d/f%d.go", i%1000, i))
+		if b.size() > 100 {
+			t.Fatalf("after %d events, %d pending; want at most 100", i+1, b.size())
+		}
+	}
+	st := w.Status()
+	if !b.collapsed || !st.Collapsed || st.Collapses != 1 || st.Pending != 0 || st.Dropped == 0 {
+		t.Fatalf("after the flood: %+v, want one collapse with nothing pending", st)
+	}
+
+	// While events keep coming at a quarter of the bound a tick, it stays
+	// collapsed.
+	for range 3 {
+		for range 25 {
+			b.change("/tree/busy.go")
+		}
+		b.tick()
+	}
+	if !b.collapsed || rescans.Load() != 0 {
+		t.Fatalf("collapsed %v after %d rescans, want still collapsed", b.collapsed, rescans.Load())
+	}
+
+	// Once they slow down, one rescan catches up, and changes are pending
+	// file by file again.
+	for range 24 {
+		b.change("/tree/busy.go")
+	}
+	b.tick()
+	select {
+	case <-rescanned:
+	case <-time.After(time.Second):
+		t.Fatal("no rescan after the burst ended")
+	}
+	b.change("/tree/after.go")
+	if st := w.Status(); b.collapsed || st.Collapsed || st.Collapses != 1 || st.Pending != 1 || rescans.Load() != 1 {
+		t.Errorf("after the burst: %+v, %d rescans; want 1 pending file and the single rescan", st, rescans.Load())
+	}
+}
+
+func TestWatcher_WatchFlood(t *testing.T) {
+	tmpDir := t.TempDir()
+	watchDir := filepath.Join(tmpDir, "watch")
+	const dirs, files = 100, 400
+	for i := range dirs {
+		if err := os.MkdirAll(filepath.Join(watchDir, fmt.Sprintf("d%d", i)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx := index.NewTestIndex(filepath.Join(tmpDir, ".sift"), &mockEmbedder{})
+	idx.SetLogger(logging.Discard())
+	w, err := New(idx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetLogger(nil)
+	w.maxPending = 40
+
+	done := make(chan struct{})
+	errc := make(chan error, 1)
+	go func() { errc <- w.Watch(watchDir, done) }()
+	time.Sleep(100 * time.Millisecond)
+
+	// More directories than the bound, each changing several files.
+	for i := range files {
+		p := filepath.Join(watchDir, fmt.Sprintf("d%d", i%dirs), fmt.Sprintf("doc%d.md", i))
+		if err := os.WriteFile(p, []byte(fmt.Sprintf("document %d", i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for idx.Stats().NumFiles < files && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	w.rescanMu.Lock() // let the rescan finish
+	w.rescanMu.Unlock()
+	close(done)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	if n := idx.Stats().NumFiles; n != files {
+		t.Errorf("%d files indexed, want %d", n, files)
+	}
+	if st := w.Status(); st.Collapses != 1 || st.Pending != 0 || st.Collapsed {
+		t.Errorf("status %+v, want a single collapse into a rescan", st)
+	}
+}
+
+func TestWatcher_LogLimit(t *testing.T) {
+	w, err := New(index.NewTestIndex(t.TempDir(), &mockEmbedder{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log bytes.Buffer
+	w.SetLogger(logging.New(&log, logging.LevelInfo))
+
+	for i := range 100 {
+		w.logLimited(w.log.Infof, "[watch] re-indexing doc%d.md", i)
+	}
+	if n := strings.Count(log.String(), "re-indexing"); n != maxLogsPerSecond {
+		t.Errorf("%d messages logged in a second, want %d", n, maxLogsPerSecond)
+	}
+	if n := w.Status().Suppressed; n != 100-maxLogsPerSecond {
+		t.Errorf("%d messages suppressed, want %d", n, 100-maxLogsPerSecond)
+	}
+
+	// The next second reports how many were held back.
+	w.logWindow = w.logWindow.Add(-time.Second)
+	w.logLimited(w.log.Infof, "[watch] re-indexing later.md")
+	if want := fmt.Sprintf("%d messages suppressed", 100-maxLogsPerSecond); !strings.Contains(log.String(), want) ||
+		!strings.Contains(log.String(), "later.md") {
+		t.Errorf("log after a second:\n%s\nwant %q and the new message", log.String(), want)
+	}
+}
@@ -0,0 +1,83 @@
+package sift_test
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/tejas242/sift/pkg/sift"
+)
+
+// wordsEmbedder embeds text as a bag of hashed words: texts sharing words
+// are close. Real programs use the bundled model or a proper one.
+type wordsEmbedder struct{}
+
+func (wordsEmbedder) Embed(texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i, t := range texts {
+		vecs[i] = embedWords(t)
+	}
+	return vecs, nil
+}
+
+func (wordsEmbedder) EmbedQuery(query string) ([]float32, error) {
+	return embedWords(query), nil
+}
+
+func (wordsEmbedder) Close() {}
+
+func embedWords(text string) []float32 {
+	v := make([]float32, 64)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		h := fnv.New32a()
+		h.Write([]byte(w))
+		v[h.Sum32()%64]++
+	}
+	var norm float64
+	for _, x := range v {
+		norm += float64(x * x)
+	}
+	if norm > 0 {
+		for i := range v {
+			v[i] /= float32(math.Sqrt(norm))
+		}
+	}
+	return v
+}
+
+func Example() {
+	dir, err := os.MkdirTemp("", "sift-example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	docs := filepath.Join(dir, "docs")
+	os.MkdirAll(docs, 0o755)
+	os.WriteFile(filepath.Join(docs, "cache.md"), []byte("# Cache\n\nEntries are evicted from the cache when it is full.\n"), 0o644)
+	os.WriteFile(filepath.Join(docs, "deploy.md"), []byte("# Deploy\n\nThe service is deployed by the release pipeline.\n"), 0o644)
+
+	idx, err := sift.Open(sift.Options{IndexDir: filepath.Join(dir, ".sift"), Embedder: wordsEmbedder{}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer idx.Close()
+
+	ctx := context.Background()
+	if err := idx.Index(ctx, docs); err != nil {
+		log.Fatal(err)
+	}
+	results, err := idx.Search(ctx, "when are cache entries evicted", sift.SearchOptions{Limit: 1})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, r := range results {
+		fmt.Printf("%s:%d\n", filepath.Base(r.Path), r.Line)
+	}
+	// Output: cache.md:1
+}
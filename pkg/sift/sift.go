@@ -0,0 +1,313 @@
+// Package sift embeds sift's local semantic search in other programs: it
+// opens an index directory as the sift command does, indexes files and
+// directories into it, keeps it up to date as files change, and searches
+// it by meaning.
+//
+// An index opened here is the same as one built by the CLI: either can
+// search or update what the other wrote, though not both at once.
+//
+// The API of this package is stable: later versions add to it but do not
+// change or remove what is here. Everything else in the module is
+// internal and may change at any time.
+package sift
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/tejas242/sift/internal/chunker"
+	"github.com/tejas242/sift/internal/config"
+	"github.com/tejas242/sift/internal/embed"
+	"github.com/tejas242/sift/internal/index"
+	"github.com/tejas242/sift/internal/logging"
+	"github.com/tejas242/sift/internal/watcher"
+)
+
+// Errors returned by Open and Index methods, possibly wrapped: test for
+// them with errors.Is.
+var (
+	// ErrNoIndex is returned by Search on an index that holds nothing.
+	ErrNoIndex = index.ErrNoIndex
+	// ErrCorrupt is returned by Open for an index whose files cannot be
+	// read back.
+	ErrCorrupt = index.ErrCorrupt
+	// ErrIndexInRoot is returned by Index for a root that holds the index
+	// directory itself, unless it is hidden.
+	ErrIndexInRoot = index.ErrIndexInRoot
+	// ErrUnsupported is returned by AddFile, and by Index for a root that
+	// is a file, of a type sift does not index.
+	ErrUnsupported = index.ErrUnsupported
+	// ErrEmbedder is returned when the embedding model cannot be loaded.
+	ErrEmbedder = index.ErrEmbedder
+	// ErrEmbedQuery is returned by Search when the query cannot be
+	// embedded.
+	ErrEmbedQuery = index.ErrEmbedQuery
+	// ErrEmptyQuery is returned by Search for a query with nothing to
+	// search for.
+	ErrEmptyQuery = index.ErrEmptyQuery
+)
+
+// Embedder turns text into vectors, in place of the bundled ONNX model.
+// Every vector it returns must have the same length, and vectors of
+// related texts should have a high cosine similarity. It is called from
+// one goroutine at a time per method, and Close is called by Index.Close.
+type Embedder interface {
+	// Embed returns one vector per text, in order.
+	Embed(texts []string) ([][]float32, error)
+	// EmbedQuery returns the vector of a search query.
+	EmbedQuery(query string) ([]float32, error)
+	// Close releases the embedder's resources.
+	Close()
+}
+
+// Prefixes are put in front of text before it is embedded, for models
+// trained with instructions ("query: ", "passage: "). Document text gets
+// Document; queries get Query, or Document too when Symmetric is set.
+type Prefixes struct {
+	Query     string
+	Document  string
+	Symmetric bool
+}
+
+// Options says how Open opens an index. The zero value opens ./.sift with
+// the bundled model in ./models, as the sift command does by default.
+type Options struct {
+	// IndexDir is the directory the index is stored in, created on the
+	// first save; ".sift" if empty.
+	IndexDir string
+	// ModelDir holds the bundled BGE-small model (model.onnx and
+	// tokenizer.json); "./models" if empty.
+	ModelDir string
+	// OrtLib is the path to the ONNX Runtime shared library; if empty it
+	// is looked for where the sift command looks ($SIFT_ORT_LIB, next to
+	// the executable, ./lib).
+	OrtLib string
+	// Threads is how many threads the model runs inference on; 0 picks
+	// min(NumCPU, 4).
+	Threads int
+	// Sessions is how many inference sessions the model gets. With 1 (or
+	// 0), concurrent searches embed their queries one at a time; with
+	// more, up to that many in parallel, each using Threads threads and
+	// its own copy of the model's memory.
+	Sessions int
+	// Embedder, if set, embeds instead of the bundled model, and
+	// ModelDir, OrtLib, Threads and Sessions are unused. An index keeps
+	// the vectors it was built with: open it with the embedder that built
+	// it.
+	Embedder Embedder
+	// Prefixes overrides the prefixes text is embedded with: by default
+	// those the bundled model expects, or none with an Embedder.
+	Prefixes *Prefixes
+	// MaxFileKB is the size over which files are not indexed; 512 if 0.
+	MaxFileKB int
+	// LowMemory leaves vectors on disk until searches reach them, for a
+	// smaller footprint on large indexes at some cost in search latency.
+	// Use it for indexes that are mostly searched.
+	LowMemory bool
+	// Preload reads the files LowMemory leaves on disk through once in
+	// the background, so the first search does not wait for them to be
+	// read page by page. Open does not wait for it.
+	Preload bool
+	// Log receives diagnostics such as files skipped and repairs made to
+	// the index; nil discards them.
+	Log io.Writer
+}
+
+// Index is an open sift index. Its methods are safe for concurrent use.
+type Index struct {
+	idx *index.Index
+	log *logging.Logger
+}
+
+// Open opens the index opts describes, or prepares an empty one if its
+// directory holds none yet. The bundled model is loaded on first use.
+func Open(opts Options) (*Index, error) {
+	o := index.Options{
+		Dir:       cmpOr(opts.IndexDir, config.DefaultSiftDir),
+		ModelDir:  cmpOr(opts.ModelDir, config.DefaultModelDir),
+		OrtLib:    config.ResolveOrtLib(opts.OrtLib),
+		Threads:   opts.Threads,
+		Sessions:  opts.Sessions,
+		MaxFileKB: opts.MaxFileKB,
+		LowMemory: opts.LowMemory,
+		Preload:   opts.Preload,
+	}
+	if o.MaxFileKB == 0 {
+		o.MaxFileKB = config.DefaultMaxFile
+	}
+	prefixes := embed.DefaultPrefixes
+	if opts.Embedder != nil {
+		o.Embedder = opts.Embedder
+		prefixes = embed.Prefixes{}
+	}
+	if p := opts.Prefixes; p != nil {
+		prefixes = embed.Prefixes{Query: p.Query, Document: p.Document, Symmetric: p.Symmetric}
+	}
+	idx, err := index.OpenWith(o)
+	if err != nil {
+		return nil, err
+	}
+	log := logging.Discard()
+	if opts.Log != nil {
+		log = logging.New(opts.Log, logging.LevelInfo)
+	}
+	idx.SetLogger(log)
+	idx.SetPrefixes(prefixes)
+	return &Index{idx: idx, log: log}, nil
+}
+
+func cmpOr(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// Index indexes each of roots, directories or single files, skipping
+// files unchanged since they were last indexed. Directories are walked
+// as the sift command walks them: hidden files, files matching the
+// root's .siftignore and unsupported types are left out, and a root
+// given twice or inside another is walked once. It stops at the first
+// error, or when ctx is done. Call Flush or Close to save the result.
+func (ix *Index) Index(ctx context.Context, roots ...string) error {
+	roots, _ = ix.idx.CoverRoots(roots)
+	for _, root := range roots {
+		if err := ix.idx.IndexDirWithProgress(ctx, root, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddFile indexes the file at path, unless it is unchanged since it was
+// last indexed. Unlike Index, it returns an error wrapping ErrUnsupported
+// for a file of a type sift does not index. Call Flush or Close to save
+// the result.
+func (ix *Index) AddFile(ctx context.Context, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s: is a directory", path)
+	}
+	if !chunker.IsSupportedFile(path) {
+		return fmt.Errorf("%s: %w", path, ErrUnsupported)
+	}
+	_, err = ix.idx.AddFileCtx(ctx, path)
+	return err
+}
+
+// Watch keeps roots, directories or single files indexed as they change,
+// re-indexing and saving each file shortly after it is written, until ctx
+// is done. It does not index what changed before it started: call Index
+// first. It returns nil once ctx is done, or the error that stopped
+// watching a root.
+func (ix *Index) Watch(ctx context.Context, roots ...string) error {
+	w, err := watcher.New(ix.idx)
+	if err != nil {
+		return err
+	}
+	w.SetLogger(ix.log)
+	roots, _ = ix.idx.CoverRoots(roots)
+	errs := make(chan error, len(roots))
+	for _, root := range roots {
+		go func() {
+			if err := w.Watch(root, ctx.Done()); err != nil {
+				errs <- fmt.Errorf("watch %s: %w", root, err)
+			}
+		}()
+	}
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errs:
+		return err
+	}
+}
+
+// Result is one search result: a chunk of an indexed file.
+type Result struct {
+	// Path is the file the chunk is from.
+	Path string
+	// Line is the 1-based line the chunk starts on.
+	Line int
+	// Text is the chunk's text.
+	Text string
+	// Score ranks results, higher first: the cosine similarity between
+	// query and chunk, plus a small boost per query word in the chunk.
+	Score float32
+	// ID identifies the chunk across saves, as long as its file and
+	// text are unchanged.
+	ID string
+}
+
+// SearchOptions narrows a search. The zero value returns the 10 best
+// chunks, one per file.
+type SearchOptions struct {
+	// Limit is how many results to return; 10 if 0.
+	Limit int
+	// Exts keeps only files with one of these extensions ("go" or
+	// ".go").
+	Exts []string
+	// Dirs keeps only files under one of these directories: absolute,
+	// or relative to the directory holding the index directory.
+	Dirs []string
+	// Types keeps only files of one of these categories ("tests",
+	// "docs", "config", "source", "other").
+	Types []string
+	// PerFile is how many chunks of one file may be returned; 1 if 0.
+	PerFile int
+}
+
+// Search returns the indexed chunks closest in meaning to query, best
+// first. Cancelling ctx abandons the search, which then returns ctx's
+// error.
+func (ix *Index) Search(ctx context.Context, query string, opts SearchOptions) ([]Result, error) {
+	k := opts.Limit
+	if k <= 0 {
+		k = 10
+	}
+	hits, err := ix.idx.SearchFiltered(ctx, query, k, index.Filter{Exts: opts.Exts, Dirs: opts.Dirs, Types: opts.Types, MaxPerFile: opts.PerFile})
+	if err != nil {
+		return nil, err
+	}
+	if len(hits) == 0 && ix.idx.Stats().NumChunks == 0 {
+		return nil, ErrNoIndex
+	}
+	results := make([]Result, len(hits))
+	for i, h := range hits {
+		results[i] = Result{Path: h.Meta.Path, Line: h.Meta.LineNum, Text: h.Meta.Text, Score: h.Score, ID: h.Meta.ID}
+	}
+	return results, nil
+}
+
+// Stats describes what an index holds.
+type Stats struct {
+	Chunks int
+	Files  int
+	// Roots are the directories and files indexed into it, absolute.
+	Roots []string
+	// Updated is when it last changed, or zero if it never did.
+	Updated time.Time
+}
+
+// Stats returns what ix holds.
+func (ix *Index) Stats() Stats {
+	s := ix.idx.Stats()
+	return Stats{Chunks: s.NumChunks, Files: s.NumFiles, Roots: ix.idx.Roots(), Updated: s.LastUpdated}
+}
+
+// Flush saves the index if it changed since it was opened or last saved.
+func (ix *Index) Flush() error {
+	return ix.idx.Flush()
+}
+
+// Close saves the index, as Flush does, and releases it and its
+// embedder. ix must not be used afterwards.
+func (ix *Index) Close() error {
+	return ix.idx.Close()
+}
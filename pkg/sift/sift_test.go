@@ -0,0 +1,152 @@
+package sift_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tejas242/sift/pkg/sift"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func open(t *testing.T, dir string) *sift.Index {
+	t.Helper()
+	idx, err := sift.Open(sift.Options{IndexDir: filepath.Join(dir, ".sift"), Embedder: wordsEmbedder{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx
+}
+
+func topPath(t *testing.T, idx *sift.Index, query string) string {
+	t.Helper()
+	results, err := idx.Search(context.Background(), query, sift.SearchOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("Search(%q): %v", query, err)
+	}
+	if len(results) == 0 {
+		t.Fatalf("Search(%q): no results", query)
+	}
+	return filepath.Base(results[0].Path)
+}
+
+func TestIndexSearchReopen(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "notes")
+	writeFile(t, filepath.Join(root, "cache.md"), "Entries are evicted from the cache when it is full.\n")
+	writeFile(t, filepath.Join(root, "deploy.md"), "The service is deployed by the release pipeline.\n")
+	writeFile(t, filepath.Join(root, "sub", "auth.go"), "// tokens expire after an hour and are refreshed by the client\npackage auth\n")
+	ctx := context.Background()
+
+	idx := open(t, dir)
+	if _, err := idx.Search(ctx, "cache", sift.SearchOptions{}); !errors.Is(err, sift.ErrNoIndex) {
+		t.Errorf("Search on an empty index: err = %v, want ErrNoIndex", err)
+	}
+	// The nested root is walked once, with its parent.
+	if err := idx.Index(ctx, root, filepath.Join(root, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	if got := topPath(t, idx, "cache entries evicted"); got != "cache.md" {
+		t.Errorf("top result = %s, want cache.md", got)
+	}
+	results, err := idx.Search(ctx, "tokens expire", sift.SearchOptions{Exts: []string{"go"}})
+	if err != nil || len(results) != 1 || results[0].Line != 1 {
+		t.Errorf("Search with Exts = %+v, %v; want the one chunk of auth.go", results, err)
+	}
+	if _, err := idx.Search(ctx, "  ", sift.SearchOptions{}); !errors.Is(err, sift.ErrEmptyQuery) {
+		t.Errorf("Search for blanks: err = %v, want ErrEmptyQuery", err)
+	}
+
+	extra := filepath.Join(dir, "extra.txt")
+	writeFile(t, extra, "Backups run nightly and are kept for thirty days.\n")
+	if err := idx.AddFile(ctx, extra); err != nil {
+		t.Fatal(err)
+	}
+	binary := filepath.Join(dir, "image.png")
+	writeFile(t, binary, "\x89PNG")
+	if err := idx.AddFile(ctx, binary); !errors.Is(err, sift.ErrUnsupported) {
+		t.Errorf("AddFile(image.png): err = %v, want ErrUnsupported", err)
+	}
+	if err := idx.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	idx = open(t, dir)
+	defer idx.Close()
+	s := idx.Stats()
+	if s.Files != 4 || s.Chunks < 4 || s.Updated.IsZero() {
+		t.Errorf("Stats after reopening = %+v, want 4 files", s)
+	}
+	if got := topPath(t, idx, "how long are backups kept"); got != "extra.txt" {
+		t.Errorf("top result after reopening = %s, want extra.txt", got)
+	}
+}
+
+func TestIndexErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a.md"), "Some notes about the project.\n")
+	idx, err := sift.Open(sift.Options{IndexDir: filepath.Join(dir, "index"), Embedder: wordsEmbedder{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	if err := idx.Index(context.Background(), dir); !errors.Is(err, sift.ErrIndexInRoot) {
+		t.Errorf("Index of the directory holding the index: err = %v, want ErrIndexInRoot", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := idx.Search(ctx, "notes", sift.SearchOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("Search with a cancelled context: err = %v, want context.Canceled", err)
+	}
+
+	corrupt := filepath.Join(t.TempDir(), ".sift")
+	writeFile(t, filepath.Join(corrupt, "meta.json"), "{not json")
+	if _, err := sift.Open(sift.Options{IndexDir: corrupt, Embedder: wordsEmbedder{}}); !errors.Is(err, sift.ErrCorrupt) {
+		t.Errorf("Open of a corrupt index: err = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "notes")
+	writeFile(t, filepath.Join(root, "cache.md"), "Entries are evicted from the cache when it is full.\n")
+	idx := open(t, dir)
+	defer idx.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := idx.Index(ctx, root); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- idx.Watch(ctx, root) }()
+
+	// Give the watcher time to start before writing.
+	time.Sleep(200 * time.Millisecond)
+	writeFile(t, filepath.Join(root, "backup.md"), "Backups run nightly and are kept for thirty days.\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for idx.Stats().Files < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("backup.md was not indexed while watching")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if got := topPath(t, idx, "how long are backups kept"); got != "backup.md" {
+		t.Errorf("top result = %s, want backup.md", got)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Watch = %v, want nil once cancelled", err)
+	}
+}